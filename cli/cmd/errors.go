@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"simkube/lib/go/util"
+)
+
+// skctl exits with a different code for each kind of error, so that scripts can tell (e.g.) a
+// simulation that doesn't exist from an API server that's temporarily unavailable
+const (
+	exitFailure    = 1
+	exitValidation = 2
+	exitNotFound   = 3
+	exitConflict   = 4
+	exitRetriable  = 5
+)
+
+// ExitCode returns the exit code for an error, based on its kind (see util.ErrNotFound, etc.)
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case util.IsValidation(err):
+		return exitValidation
+	case util.IsNotFound(err):
+		return exitNotFound
+	case util.IsConflict(err):
+		return exitConflict
+	case util.IsRetriable(err):
+		return exitRetriable
+	default:
+		return exitFailure
+	}
+}
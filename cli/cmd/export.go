@@ -2,10 +2,9 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"io"
 	"io/fs"
-	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -14,6 +13,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/trace"
+	"simkube/lib/go/tracerclient"
 	"simkube/lib/go/util"
 )
 
@@ -28,12 +29,24 @@ func Export() *cobra.Command {
 	export.Flags().String(
 		startTimeFlag,
 		"-30m",
-		"start time; can be a relative duration or absolute (local) timestamp\n"+
-			"    in ISO-8601 extended format (YYYY-MM-DDThh:mm:ss).\n"+
+		"start time; can be a relative duration, a unix timestamp, or an absolute timestamp\n"+
+			"    in RFC3339 format (YYYY-MM-DDThh:mm:ssZ or YYYY-MM-DDThh:mm:ss+hh:mm), or in\n"+
+			"    ISO-8601 extended format (YYYY-MM-DDThh:mm:ss) in the --timezone, or one of\n"+
+			"    now, today, yesterday, or start-of-hour.\n"+
 			"    durations are computed relative to the specified end time,\n"+
 			"    _not_ the current time\n",
 	)
-	export.Flags().String(endTimeFlag, "now", "end time; can be a relative or absolute (local) timestamp\n")
+	export.Flags().String(
+		endTimeFlag,
+		"now",
+		"end time; can be any of the same formats as the start time, or a forward duration\n"+
+			"    (e.g., +2h) relative to the start time\n",
+	)
+	export.Flags().String(
+		timezoneFlag,
+		"Local",
+		"timezone for timestamps without an offset, e.g., UTC or America/New_York\n",
+	)
 	export.Flags().StringArray(
 		excludedNamespacesFlag,
 		[]string{"kube-system", "monitoring", "local-path-storage", "simkube", "cert-manager", "volcano-system"},
@@ -42,10 +55,53 @@ func Export() *cobra.Command {
 	export.Flags().StringArray(
 		excludedLabelsFlag,
 		[]string{},
-		"label selectors to exclude from the trace (key=value pairs)",
+		"label selectors to exclude from the trace, e.g., app=nginx,tier!=frontend",
+	)
+	export.Flags().StringArray(
+		includedKindsFlag,
+		[]string{},
+		"only include these kinds in the trace, in <group>/<version>.<kind> form, e.g., /v1.Pod;\n"+
+			"    all of the kinds must be tracked by the tracer",
+	)
+	export.Flags().StringArray(
+		excludedKindsFlag,
+		[]string{},
+		"kinds to exclude from the trace, in <group>/<version>.<kind> form",
+	)
+	export.Flags().Bool(
+		excludeOwnedObjectsFlag,
+		false,
+		"leave out objects whose owner is also in the trace (e.g., the pods of an exported Deployment)",
+	)
+	export.Flags().Bool(
+		topLevelOnlyFlag,
+		false,
+		"only include objects that aren't owned by anything else",
+	)
+
+	export.Flags().Duration(
+		chunkSizeFlag,
+		0,
+		"export the trace in time slices of this size and join them together,\n"+
+			"    for time windows that are too big to export in one request\n",
+	)
+	export.Flags().Int(
+		parallelismFlag,
+		1,
+		"how many time slices to export at the same time; without --chunk-size, the time window\n"+
+			"    is split into this many slices\n",
 	)
 
 	export.Flags().String(tracerAddrFlag, "http://localhost:7777", "tracer server address\n")
+	export.Flags().String(tracerCAFileFlag, "", "CA certificate to verify the tracer with (for https addresses)\n")
+	export.Flags().String(tracerCertFileFlag, "", "client certificate to present to the tracer (for mutual TLS)\n")
+	export.Flags().String(tracerKeyFileFlag, "", "client key to present to the tracer (for mutual TLS)\n")
+	export.Flags().String(tracerTokenFileFlag, "", "file with a bearer token to send to the tracer\n")
+	export.Flags().Duration(
+		requestTimeoutFlag,
+		0,
+		"how long each request to the tracer can take (0 means no timeout)\n",
+	)
 	export.Flags().StringP(outputFlag, "o", "file:///tmp/kind-node-data", "location to save exported trace\n")
 	return export
 }
@@ -73,70 +129,199 @@ func doExport(cmd *cobra.Command, _ []string) {
 		fmt.Printf("no tracer-addr flag: %v\n", err)
 		os.Exit(1)
 	}
+	tracerCAFile, err := cmd.Flags().GetString(tracerCAFileFlag)
+	if err != nil {
+		fmt.Printf("no tracer-ca-file flag: %v\n", err)
+		os.Exit(1)
+	}
+	tracerCertFile, err := cmd.Flags().GetString(tracerCertFileFlag)
+	if err != nil {
+		fmt.Printf("no tracer-cert-file flag: %v\n", err)
+		os.Exit(1)
+	}
+	tracerKeyFile, err := cmd.Flags().GetString(tracerKeyFileFlag)
+	if err != nil {
+		fmt.Printf("no tracer-key-file flag: %v\n", err)
+		os.Exit(1)
+	}
+	tracerTokenFile, err := cmd.Flags().GetString(tracerTokenFileFlag)
+	if err != nil {
+		fmt.Printf("no tracer-token-file flag: %v\n", err)
+		os.Exit(1)
+	}
+	requestTimeout, err := cmd.Flags().GetDuration(requestTimeoutFlag)
+	if err != nil {
+		fmt.Printf("no request-timeout flag: %v\n", err)
+		os.Exit(1)
+	}
 	output, err := cmd.Flags().GetString(outputFlag)
 	if err != nil {
 		fmt.Printf("no output flag: %v\n", err)
 		os.Exit(1)
 	}
 
-	// TODO actually parse excluded labels
-	// excludedLabels, _ := cmd.Flags().GetStringArray(excludedLabelsFlag)
-
-	endTime, err := util.ParseTimeStr(endTimeStr, time.Time{})
+	excludedLabelsStrs, err := cmd.Flags().GetStringArray(excludedLabelsFlag)
+	if err != nil {
+		fmt.Printf("no excluded-labels flag: %v\n", err)
+		os.Exit(1)
+	}
+	excludedLabels := make([]metav1.LabelSelector, 0, len(excludedLabelsStrs))
+	for _, selStr := range excludedLabelsStrs {
+		sel, err := simkubev1.ParseLabelSelector(selStr)
+		if err != nil {
+			fmt.Printf("could not parse excluded labels %q: %v\n", selStr, err)
+			os.Exit(1)
+		}
+		excludedLabels = append(excludedLabels, sel)
+	}
+	includedKinds, err := cmd.Flags().GetStringArray(includedKindsFlag)
+	if err != nil {
+		fmt.Printf("no included-kinds flag: %v\n", err)
+		os.Exit(1)
+	}
+	excludedKinds, err := cmd.Flags().GetStringArray(excludedKindsFlag)
+	if err != nil {
+		fmt.Printf("no excluded-kinds flag: %v\n", err)
+		os.Exit(1)
+	}
+	excludeOwnedObjects, err := cmd.Flags().GetBool(excludeOwnedObjectsFlag)
+	if err != nil {
+		fmt.Printf("no exclude-owned-objects flag: %v\n", err)
+		os.Exit(1)
+	}
+	topLevelOnly, err := cmd.Flags().GetBool(topLevelOnlyFlag)
+	if err != nil {
+		fmt.Printf("no top-level-only flag: %v\n", err)
+		os.Exit(1)
+	}
+	chunkSize, err := cmd.Flags().GetDuration(chunkSizeFlag)
 	if err != nil {
-		fmt.Printf("could not parse end time: %v", err)
+		fmt.Printf("no chunk-size flag: %v\n", err)
+		os.Exit(1)
+	} else if chunkSize < 0 {
+		fmt.Printf("chunk size can't be negative: %v\n", chunkSize)
 		os.Exit(1)
 	}
-	startTime, err := util.ParseTimeStr(startTimeStr, endTime)
+	parallelism, err := cmd.Flags().GetInt(parallelismFlag)
 	if err != nil {
-		fmt.Printf("could not parse start time: %v", err)
+		fmt.Printf("no parallelism flag: %v\n", err)
+		os.Exit(1)
+	} else if parallelism < 1 {
+		fmt.Printf("parallelism must be at least 1: %d\n", parallelism)
 		os.Exit(1)
 	}
 
-	filters := *simkubev1.NewExportFilters(
-		excludedNamespaces,
-		[]metav1.LabelSelector{},
-		true,
-	)
-	request := simkubev1.NewExportRequest(startTime.Unix(), endTime.Unix(), filters)
-	requestJSON, err := request.MarshalJSON()
+	startTime, endTime, err := util.ParseTimeRange(startTimeStr, endTimeStr, timezone(cmd))
 	if err != nil {
-		fmt.Printf("could not marshal request to JSON: %v\n", err)
+		fmt.Printf("%v\n", err)
 		os.Exit(1)
 	}
 
-	requestBody := bytes.NewReader(requestJSON)
+	filters := *simkubev1.NewExportFilters(excludedNamespaces, excludedLabels, true)
+	if len(includedKinds) > 0 {
+		filters.SetIncludedKinds(includedKinds)
+	}
+	if len(excludedKinds) > 0 {
+		filters.SetExcludedKinds(excludedKinds)
+	}
+	if excludeOwnedObjects {
+		filters.SetExcludeOwnedObjects(true)
+	}
+	if topLevelOnly {
+		filters.SetTopLevelOnly(true)
+	}
+	if err = filters.Validate(); err != nil {
+		fmt.Printf("invalid filters: %v\n", err)
+		os.Exit(exitValidation)
+	}
+
+	client, err := tracerclient.New(tracerclient.Options{
+		Address: tracerAddr,
+		Timeout: requestTimeout,
+		TLS: tracerclient.TLSOptions{
+			CAFile:   tracerCAFile,
+			CertFile: tracerCertFile,
+			KeyFile:  tracerKeyFile,
+		},
+		BearerTokenFile: tracerTokenFile,
+	})
+	if err != nil {
+		fmt.Printf("could not create tracer client: %v\n", err)
+		os.Exit(1)
+	}
 
-	exportUrl := fmt.Sprintf("%s/export", tracerAddr)
 	fmt.Println("exporting trace data")
 	fmt.Printf("start_ts = %v, end_ts = %v\n", startTime, endTime)
-	fmt.Printf("using filters:\n\texcluded_namespaces: %v\n\texcluded_labels: none\n", excludedNamespaces)
-	fmt.Printf("making request to %s\n", exportUrl)
+	fmt.Printf(
+		"using filters:\n\texcluded_namespaces: %v\n\texcluded_labels: %v\n"+
+			"\tincluded_kinds: %v\n\texcluded_kinds: %v\n"+
+			"\texclude_owned_objects: %v\n\ttop_level_only: %v\n",
+		excludedNamespaces,
+		excludedLabelsStrs,
+		includedKinds,
+		excludedKinds,
+		excludeOwnedObjects,
+		topLevelOnly,
+	)
+	fmt.Printf("making request to %s\n", tracerAddr)
 
-	req, err := http.NewRequest(http.MethodPost, exportUrl, requestBody)
+	request := simkubev1.NewExportRequest(startTime.Unix(), endTime.Unix(), filters)
+	data, err := export(context.Background(), client, request, chunkSize, parallelism)
 	if err != nil {
-		fmt.Printf("could not create request: %v\n", err)
+		fmt.Printf("could not export trace: %v\n", err)
+		os.Exit(ExitCode(err))
+	}
+
+	if err = writeOutput(output, data); err != nil {
+		fmt.Printf("could not write trace data to %s: %v\n", output, err)
 		os.Exit(1)
 	}
+}
 
-	//nolint:bodyclose // this gets closed at the end of the function anyways it's fine NBD
-	resp, err := http.DefaultClient.Do(req)
+// export makes a single request for the whole time window, unless a chunk size is set or the
+// export is parallelized
+func export(
+	ctx context.Context,
+	client *tracerclient.Client,
+	request *simkubev1.ExportRequest,
+	chunkSize time.Duration,
+	parallelism int,
+) ([]byte, error) {
+	var joined *trace.Trace
+	var err error
+	if chunkSize > 0 {
+		joined, err = client.ExportChunked(ctx, request, chunkSize, parallelism)
+	} else if parallelism > 1 {
+		joined, err = client.ExportParallel(ctx, request, parallelism)
+	} else {
+		//nolint:wrapcheck // this is just a passthrough
+		return client.Export(ctx, request)
+	}
 	if err != nil {
-		fmt.Printf("error making request: %v\n", err)
-		os.Exit(1)
+		//nolint:wrapcheck // this is just a passthrough
+		return nil, err
 	}
 
-	fmt.Printf("got response status: %d\n", resp.StatusCode)
-	respBody, err := io.ReadAll(resp.Body)
+	var buf bytes.Buffer
+	if err := trace.WriteTrace(&buf, joined); err != nil {
+		return nil, fmt.Errorf("could not encode trace: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// timezone returns the location from the --timezone flag; "Local" is the system's timezone
+func timezone(cmd *cobra.Command) *time.Location {
+	name, err := cmd.Flags().GetString(timezoneFlag)
 	if err != nil {
-		fmt.Printf("could not read response body: %v\n", err)
+		fmt.Printf("no timezone flag: %v\n", err)
 		os.Exit(1)
 	}
-
-	if err = writeOutput(output, respBody); err != nil {
-		fmt.Printf("could not write trace data to %s: %v\n", output, err)
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		fmt.Printf("unknown timezone %s: %v\n", name, err)
 		os.Exit(1)
 	}
+	return loc
 }
 
 func writeOutput(output string, data []byte) error {
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"simkube/lib/go/trace/generate"
+	"simkube/lib/go/util"
+)
+
+const (
+	genCmdName      = "gen"
+	genTraceCmdName = "trace"
+)
+
+func Gen() *cobra.Command {
+	gen := &cobra.Command{
+		Use:   genCmdName,
+		Short: "generate synthetic simulation inputs",
+	}
+	gen.AddCommand(genTraceCmd())
+	return gen
+}
+
+func genTraceCmd() *cobra.Command {
+	genTrace := &cobra.Command{
+		Use:   genTraceCmdName + " <model-file>",
+		Short: "generate a synthetic trace from a workload model",
+		Args:  cobra.ExactArgs(1),
+		Run:   doGenTrace,
+	}
+	genTrace.Flags().String(
+		startTimeFlag,
+		"now",
+		"when the trace starts; can be a relative duration, a unix timestamp, or an absolute timestamp\n"+
+			"    in RFC3339 format, or in ISO-8601 extended format (YYYY-MM-DDThh:mm:ss) in the --timezone,\n"+
+			"    or one of now, today, yesterday, or start-of-hour\n",
+	)
+	genTrace.Flags().String(
+		timezoneFlag,
+		"Local",
+		"timezone for timestamps without an offset, e.g., UTC or America/New_York\n",
+	)
+	genTrace.Flags().Int64(seedFlag, 0, "random seed for the trace (by default, the seed in the model)\n")
+	genTrace.Flags().StringP(outputFlag, "o", "file:///tmp/kind-node-data", "location to save the generated trace\n")
+	return genTrace
+}
+
+func doGenTrace(cmd *cobra.Command, args []string) {
+	startTimeStr, err := cmd.Flags().GetString(startTimeFlag)
+	if err != nil {
+		fmt.Printf("no start time flag: %v\n", err)
+		os.Exit(1)
+	}
+	output, err := cmd.Flags().GetString(outputFlag)
+	if err != nil {
+		fmt.Printf("no output flag: %v\n", err)
+		os.Exit(1)
+	}
+
+	model, err := generate.LoadModel(args[0])
+	if err != nil {
+		fmt.Printf("could not load model: %v\n", err)
+		os.Exit(1)
+	}
+	if cmd.Flags().Changed(seedFlag) {
+		if model.Seed, err = cmd.Flags().GetInt64(seedFlag); err != nil {
+			fmt.Printf("no seed flag: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	startTime, err := util.ParseTimeStrInLocation(startTimeStr, time.Time{}, timezone(cmd))
+	if err != nil {
+		fmt.Printf("could not parse start time: %v\n", err)
+		os.Exit(1)
+	}
+
+	tr, err := generate.Trace(model, startTime.Unix())
+	if err != nil {
+		fmt.Printf("could not generate trace: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("generated %d events with %d objects (seed %d)\n", len(tr.Events), len(tr.Index), model.Seed)
+	writeTrace(output, tr)
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/results"
+)
+
+const resultsCmdName = "results"
+
+func Results(k8sClient client.Client) *cobra.Command {
+	res := &cobra.Command{
+		Use:   resultsCmdName,
+		Short: "show the results of a simulation, and the metrics that were collected",
+		Run:   func(cmd *cobra.Command, _ []string) { doResults(cmd, k8sClient) },
+	}
+	res.Flags().String(simNameFlag, "", "the name of the simulation")
+	res.Flags().StringP(outputFlag, "o", "", "file to write the raw metrics to, as JSON (instead of a summary)")
+	return res
+}
+
+func doResults(cmd *cobra.Command, k8sClient client.Client) {
+	simName, err := cmd.Flags().GetString(simNameFlag)
+	if err != nil || simName == "" {
+		fmt.Printf("no simulation name specified: %v\n", err)
+		os.Exit(1)
+	}
+
+	output, err := cmd.Flags().GetString(outputFlag)
+	if err != nil {
+		fmt.Printf("no output flag: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	var sim simkubev1.Simulation
+	if err = k8sClient.Get(ctx, client.ObjectKey{Name: simName}, &sim); err != nil {
+		fmt.Printf("could not get simulation: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The results that the driver recorded in the simulation status are the most complete, but they
+	// might not be readable from here; the metrics are also in the results ConfigMap, so we fall back
+	// to that
+	var summary *results.Summary
+	var metrics *results.Metrics
+	if sim.Status.Results != nil {
+		if summary, metrics, err = results.ReadLocal(sim.Status.Results); err != nil {
+			fmt.Printf("could not read results from %s: %v\n", sim.Status.Results.Location, err)
+		}
+	}
+	if summary == nil && metrics == nil {
+		if metrics, err = readResultsConfigMap(ctx, k8sClient, &sim); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if output != "" {
+		if metrics == nil {
+			fmt.Printf("no metrics were collected for %s\n", simName)
+			os.Exit(1)
+		}
+		data, err := json.MarshalIndent(metrics, "", "  ")
+		if err != nil {
+			fmt.Printf("could not marshal results: %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(output, data, 0600); err != nil {
+			fmt.Printf("could not write results to %s: %v\n", output, err)
+			os.Exit(1)
+		}
+		fmt.Printf("results successfully stored to %s\n", output)
+		return
+	}
+
+	if summary != nil {
+		printSummary(summary)
+	}
+	if metrics != nil {
+		if summary != nil {
+			fmt.Println()
+		}
+		printResults(metrics)
+	}
+}
+
+func readResultsConfigMap(ctx context.Context, k8sClient client.Client, sim *simkubev1.Simulation) (*results.Metrics, error) {
+	var cm corev1.ConfigMap
+	key := client.ObjectKey{Namespace: sim.Spec.DriverNamespace, Name: results.ConfigMapName(sim.Name)}
+	if err := k8sClient.Get(ctx, key, &cm); apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("no results found for %s (has the simulation finished?)", sim.Name)
+	} else if err != nil {
+		return nil, fmt.Errorf("could not get results: %w", err)
+	}
+
+	metrics, err := results.ReadMetrics(&cm)
+	if err != nil {
+		return nil, fmt.Errorf("could not read results: %w", err)
+	}
+	return metrics, nil
+}
+
+func printSummary(summary *results.Summary) {
+	fmt.Printf("simulation:  %s\n", summary.Simulation)
+	fmt.Printf("trace:       %s\n", summary.Trace)
+	fmt.Printf("phase:       %s\n", summary.Phase)
+	if summary.Error != "" {
+		fmt.Printf("error:       %s\n", summary.Error)
+	}
+	fmt.Printf("started:     %s\n", summary.StartTime.Format(time.RFC3339))
+	fmt.Printf("duration:    %s\n", summary.CompletionTime.Sub(summary.StartTime).Round(time.Second))
+	fmt.Printf("events:      %d/%d\n", summary.EventsReplayed, summary.TotalEvents)
+}
+
+func printResults(metrics *results.Metrics) {
+	if len(metrics.Series) == 0 {
+		fmt.Printf("%s: no samples collected\n", metrics.Simulation)
+		return
+	}
+
+	names := make([]string, 0, len(metrics.Series))
+	width := 0
+	for _, series := range metrics.Series {
+		name := seriesName(series)
+		names = append(names, name)
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	fmt.Printf("%-*s  %7s  %12s  %12s  %12s\n", width, "SERIES", "SAMPLES", "MIN", "MAX", "LAST")
+	for i, series := range metrics.Series {
+		if len(series.Samples) == 0 {
+			fmt.Printf("%-*s  %7d\n", width, names[i], 0)
+			continue
+		}
+
+		minVal, maxVal := series.Samples[0].Value, series.Samples[0].Value
+		for _, s := range series.Samples[1:] {
+			if s.Value < minVal {
+				minVal = s.Value
+			} else if s.Value > maxVal {
+				maxVal = s.Value
+			}
+		}
+		last := series.Samples[len(series.Samples)-1].Value
+		fmt.Printf("%-*s  %7d  %12.4g  %12.4g  %12.4g\n", width, names[i], len(series.Samples), minVal, maxVal, last)
+	}
+}
+
+func seriesName(series results.Series) string {
+	if len(series.Labels) == 0 {
+		return series.Name
+	}
+
+	labels := make([]string, 0, len(series.Labels))
+	for k, v := range series.Labels {
+		labels = append(labels, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(labels)
+	return fmt.Sprintf("%s{%s}", series.Name, strings.Join(labels, ","))
+}
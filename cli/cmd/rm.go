@@ -38,6 +38,6 @@ func doRm(cmd *cobra.Command, k8sClient client.Client) {
 	}
 	if err = k8sClient.Delete(context.Background(), &sim); err != nil {
 		fmt.Printf("could not delete simulation: %v\n", err)
-		os.Exit(1)
+		os.Exit(ExitCode(err))
 	}
 }
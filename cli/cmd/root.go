@@ -1,35 +1,124 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"simkube/lib/go/util"
 )
 
 const (
 	progname = "skctl"
 
 	// Global flags
-	verbosityFlag = "verbosity"
+	verbosityFlag      = "verbosity"
+	redactKeysFlag     = "redact-keys"
+	redactPatternsFlag = "redact-patterns"
 
 	// Subcommand flags
-	endTimeFlag            = "end-time"
-	excludedNamespacesFlag = "excluded-namespaces"
-	excludedLabelsFlag     = "excluded-labels"
-	outputFlag             = "output"
-	simNameFlag            = "sim-name"
-	startTimeFlag          = "start-time"
-	tracerAddrFlag         = "tracer-addr"
+	againstClusterFlag       = "against-cluster"
+	chunkSizeFlag            = "chunk-size"
+	coalesceWindowFlag       = "coalesce-window"
+	endTimeFlag              = "end-time"
+	excludedNamespacesFlag   = "excluded-namespaces"
+	excludedLabelsFlag       = "excluded-labels"
+	excludedKindsFlag        = "excluded-kinds"
+	excludeOwnedObjectsFlag  = "exclude-owned-objects"
+	hashLabelsFlag           = "hash-labels"
+	hashNamesFlag            = "hash-names"
+	includedKindsFlag        = "included-kinds"
+	keepMetadataUpdatesFlag  = "keep-metadata-updates"
+	maxDurationFlag          = "max-duration"
+	namespaceMapFlag         = "namespace-map"
+	outputFlag               = "output"
+	parallelismFlag          = "parallelism"
+	repetitionsFlag          = "repetitions"
+	requestTimeoutFlag       = "request-timeout"
+	saltFlag                 = "salt"
+	scaleFactorFlag          = "scale-factor"
+	seedFlag                 = "seed"
+	sequentialFlag           = "sequential"
+	simNameFlag              = "sim-name"
+	speedFlag                = "speed"
+	startTimeFlag            = "start-time"
+	stripAnnotationsFlag     = "strip-annotations"
+	stripEnvFlag             = "strip-env"
+	stripImageRegistriesFlag = "strip-image-registries"
+	stripSecretRefsFlag      = "strip-secret-refs"
+	stripStatusFlag          = "strip-status"
+	timezoneFlag             = "timezone"
+	topLevelOnlyFlag         = "top-level-only"
+	tracerAddrFlag           = "tracer-addr"
+	tracerCAFileFlag         = "tracer-ca-file"
+	tracerCertFileFlag       = "tracer-cert-file"
+	tracerKeyFileFlag        = "tracer-key-file"
+	tracerTokenFileFlag      = "tracer-token-file"
+	virtualNsPrefixFlag      = "virtual-ns-prefix"
+	waitFlag                 = "wait"
 )
 
 func Root(k8sClient client.Client) *cobra.Command {
 	root := &cobra.Command{
 		Use:   progname,
 		Short: "simkube CLI utility for exporting and running simulations",
+
+		PersistentPreRun: func(cmd *cobra.Command, _ []string) { setupLogging(cmd) },
 	}
 
 	root.PersistentFlags().IntP(verbosityFlag, "v", 2, "log level output (higher is more verbose)")
+	root.PersistentFlags().StringSlice(
+		redactKeysFlag,
+		nil,
+		"additional log field names and keys whose values are masked in the logs (passwords, tokens, etc. always are)",
+	)
+	root.PersistentFlags().StringArray(
+		redactPatternsFlag,
+		nil,
+		"regular expression whose matches are masked in the logs (can be repeated)",
+	)
 	root.AddCommand(Export())
+	root.AddCommand(Gen())
 	root.AddCommand(Run(k8sClient))
+	root.AddCommand(Results(k8sClient))
 	root.AddCommand(Rm(k8sClient))
+	root.AddCommand(Status(k8sClient))
+	root.AddCommand(Trace())
+	root.AddCommand(Validate(k8sClient))
 	return root
 }
+
+// setupLogging applies the global logging flags to the logs from the simkube libraries (e.g.,
+// while reading or exporting a trace)
+func setupLogging(cmd *cobra.Command) {
+	verbosity, err := cmd.Flags().GetInt(verbosityFlag)
+	if err != nil {
+		fmt.Printf("no verbosity flag: %v\n", err)
+		os.Exit(1)
+	}
+	level, err := util.ParseLogLevel(strconv.Itoa(verbosity))
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(exitValidation)
+	}
+	log.SetLevel(level)
+
+	redactKeys, err := cmd.Flags().GetStringSlice(redactKeysFlag)
+	if err != nil {
+		fmt.Printf("no redact-keys flag: %v\n", err)
+		os.Exit(1)
+	}
+	redactPatterns, err := cmd.Flags().GetStringArray(redactPatternsFlag)
+	if err != nil {
+		fmt.Printf("no redact-patterns flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err = util.SetupRedaction(util.RedactionOptions{Keys: redactKeys, Patterns: redactPatterns}); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(ExitCode(err))
+	}
+}
@@ -3,8 +3,10 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"math"
 	"os"
 
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -26,6 +28,20 @@ func Run(k8sClient client.Client) *cobra.Command {
 		Run:   func(cmd *cobra.Command, _ []string) { doRun(cmd, k8sClient) },
 	}
 	run.Flags().String(simNameFlag, "", "the name of simulation to run")
+	run.Flags().Float64(speedFlag, 1, "how much faster than real time to replay the trace")
+	run.Flags().Duration(
+		maxDurationFlag,
+		0,
+		"stop the simulation after this long, even if the trace isn't finished (0 means no limit)",
+	)
+	run.Flags().Int32(repetitionsFlag, 1, "how many times to replay the trace")
+	run.Flags().Int64(seedFlag, 0, "random seed for the simulation (by default, no seed is set)")
+	run.Flags().StringToString(
+		namespaceMapFlag,
+		nil,
+		"replay a namespace from the trace into another namespace, e.g. prod=sim-prod (can be repeated)",
+	)
+	run.Flags().Float64(scaleFactorFlag, 1, "multiply the replicas in the trace by this much, e.g. 2 for twice the load")
 	return run
 }
 
@@ -38,15 +54,67 @@ func doRun(cmd *cobra.Command, k8sClient client.Client) {
 		os.Exit(1)
 	}
 
+	speed, err := cmd.Flags().GetFloat64(speedFlag)
+	if err != nil || speed <= 0 {
+		fmt.Printf("invalid speed %v: %v\n", speed, err)
+		os.Exit(1)
+	}
+
+	maxDuration, err := cmd.Flags().GetDuration(maxDurationFlag)
+	if err != nil {
+		fmt.Printf("no max-duration flag: %v\n", err)
+		os.Exit(1)
+	}
+
+	repetitions, err := cmd.Flags().GetInt32(repetitionsFlag)
+	if err != nil {
+		fmt.Printf("no repetitions flag: %v\n", err)
+		os.Exit(1)
+	}
+
+	namespaceMap, err := cmd.Flags().GetStringToString(namespaceMapFlag)
+	if err != nil {
+		fmt.Printf("no namespace-map flag: %v\n", err)
+		os.Exit(1)
+	}
+
+	scaleFactor, err := cmd.Flags().GetFloat64(scaleFactorFlag)
+	if err != nil || scaleFactor <= 0 {
+		fmt.Printf("invalid scale factor %v: %v\n", scaleFactor, err)
+		os.Exit(1)
+	}
+
 	sim := simkubev1.Simulation{
 		ObjectMeta: metav1.ObjectMeta{Name: simName},
 		Spec: simkubev1.SimulationSpec{
 			DriverNamespace: driverNamespace,
 			Trace:           traceFile,
+			Speed:           speed,
+			Repetitions:     repetitions,
+			NamespaceMap:    namespaceMap,
+			ScaleFactor:     scaleFactor,
 		},
 	}
+	if maxDuration != 0 {
+		// Durations shorter than a second get rounded up, so they're still a limit
+		sim.Spec.MaxDurationSeconds = lo.ToPtr(int64(math.Ceil(maxDuration.Seconds())))
+	}
+	if cmd.Flags().Changed(seedFlag) {
+		seed, err := cmd.Flags().GetInt64(seedFlag)
+		if err != nil {
+			fmt.Printf("no seed flag: %v\n", err)
+			os.Exit(1)
+		}
+		sim.Spec.Seed = &seed
+	}
+
+	sim.Spec.Default()
+	if err = sim.Spec.Validate(); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(exitValidation)
+	}
 	if err = k8sClient.Create(context.Background(), &sim); err != nil {
 		fmt.Printf("could not create simulation: %v\n", err)
-		os.Exit(1)
+		os.Exit(ExitCode(err))
 	}
 }
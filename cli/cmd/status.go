@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	simkubev1 "simkube/lib/go/api/v1"
+)
+
+const (
+	statusCmdName = "status"
+
+	statusPollInterval = 5 * time.Second
+)
+
+func Status(k8sClient client.Client) *cobra.Command {
+	status := &cobra.Command{
+		Use:   statusCmdName,
+		Short: "show the status of a simulation",
+		Run:   func(cmd *cobra.Command, _ []string) { doStatus(cmd, k8sClient) },
+	}
+	status.Flags().String(simNameFlag, "", "the name of the simulation")
+	status.Flags().Bool(waitFlag, false, "wait for the simulation to finish before showing its status")
+	return status
+}
+
+func doStatus(cmd *cobra.Command, k8sClient client.Client) {
+	simName, err := cmd.Flags().GetString(simNameFlag)
+	if err != nil || simName == "" {
+		fmt.Printf("no simulation name specified: %v\n", err)
+		os.Exit(1)
+	}
+
+	wait, err := cmd.Flags().GetBool(waitFlag)
+	if err != nil {
+		fmt.Printf("no wait flag: %v\n", err)
+		os.Exit(1)
+	}
+
+	var sim simkubev1.Simulation
+	for {
+		if err = k8sClient.Get(context.Background(), client.ObjectKey{Name: simName}, &sim); err != nil {
+			fmt.Printf("could not get simulation: %v\n", err)
+			os.Exit(ExitCode(err))
+		}
+		if !wait || sim.Status.Finished() {
+			break
+		}
+		time.Sleep(statusPollInterval)
+	}
+
+	printStatus(&sim)
+	if sim.Status.Phase == simkubev1.SimulationFailed {
+		os.Exit(1)
+	}
+}
+
+func printStatus(sim *simkubev1.Simulation) {
+	phase := sim.Status.Phase
+	if phase == "" {
+		// The controller hasn't gotten to the simulation yet
+		phase = simkubev1.SimulationPending
+	}
+	fmt.Printf("%s: %s\n", sim.Name, phase)
+
+	if progress := sim.Status.Progress(); progress != "" {
+		fmt.Printf("  events replayed: %s\n", progress)
+	}
+	if sim.Status.StartTime != nil {
+		fmt.Printf("  started:         %s\n", sim.Status.StartTime.Format(time.RFC3339))
+	}
+	if sim.Status.CompletionTime != nil {
+		fmt.Printf("  completed:       %s\n", sim.Status.CompletionTime.Format(time.RFC3339))
+	}
+
+	for _, cond := range sim.Status.Conditions {
+		msg := fmt.Sprintf("  %s=%s (%s)", cond.Type, cond.Status, cond.Reason)
+		if cond.Message != "" {
+			msg += ": " + cond.Message
+		}
+		fmt.Println(msg)
+	}
+}
@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"simkube/lib/go/trace"
+	"simkube/lib/go/trace/anonymize"
+	"simkube/lib/go/trace/chaos"
+	"simkube/lib/go/trace/compact"
+)
+
+const (
+	traceCmdName     = "trace"
+	mergeCmdName     = "merge"
+	anonymizeCmdName = "anonymize"
+	chaosCmdName     = "chaos"
+	compactCmdName   = "compact"
+)
+
+func Trace() *cobra.Command {
+	traceCmd := &cobra.Command{
+		Use:   traceCmdName,
+		Short: "work with exported trace files",
+	}
+	traceCmd.AddCommand(mergeCmd())
+	traceCmd.AddCommand(anonymizeCmd())
+	traceCmd.AddCommand(chaosCmd())
+	traceCmd.AddCommand(compactCmd())
+	return traceCmd
+}
+
+func mergeCmd() *cobra.Command {
+	merge := &cobra.Command{
+		Use:   mergeCmdName + " <trace-file> <trace-file>...",
+		Short: "combine multiple traces into one",
+		Args:  cobra.MinimumNArgs(2),
+		Run:   doMerge,
+	}
+	merge.Flags().Bool(
+		sequentialFlag,
+		false,
+		"replay the traces one after another, instead of starting them all at the same time\n",
+	)
+	merge.Flags().StringP(outputFlag, "o", "file:///tmp/kind-node-data", "location to save the merged trace\n")
+	return merge
+}
+
+func doMerge(cmd *cobra.Command, tracePaths []string) {
+	sequential, err := cmd.Flags().GetBool(sequentialFlag)
+	if err != nil {
+		fmt.Printf("no sequential flag: %v\n", err)
+		os.Exit(1)
+	}
+	output, err := cmd.Flags().GetString(outputFlag)
+	if err != nil {
+		fmt.Printf("no output flag: %v\n", err)
+		os.Exit(1)
+	}
+
+	traces := make([]*trace.Trace, 0, len(tracePaths))
+	for _, path := range tracePaths {
+		traces = append(traces, readTraceFile(path))
+	}
+
+	merged, renames, err := trace.Merge(traces, trace.MergeOptions{Sequential: sequential})
+	if err != nil {
+		fmt.Printf("could not merge traces: %v\n", err)
+		os.Exit(1)
+	}
+	for _, rename := range renames {
+		fmt.Printf("renamed namespace %s to %s in %s\n", rename.From, rename.To, tracePaths[rename.Trace])
+	}
+
+	writeTrace(output, merged)
+}
+
+func anonymizeCmd() *cobra.Command {
+	defaults := anonymize.DefaultPolicy()
+	anon := &cobra.Command{
+		Use:   anonymizeCmdName + " <trace-file>",
+		Short: "anonymize the objects in a trace",
+		Args:  cobra.ExactArgs(1),
+		Run:   doAnonymize,
+	}
+	anon.Flags().String(saltFlag, "", "salt to mix into the hashed names and labels (random if unset)\n")
+	anon.Flags().Bool(hashNamesFlag, defaults.HashNames, "hash object names and namespaces\n")
+	anon.Flags().Bool(hashLabelsFlag, defaults.HashLabels, "hash label values and selectors\n")
+	anon.Flags().Bool(stripEnvFlag, defaults.StripEnv, "remove all env vars from containers\n")
+	anon.Flags().Bool(
+		stripSecretRefsFlag,
+		defaults.StripSecretRefs,
+		"remove secret env vars, secret volumes, and image pull secrets\n",
+	)
+	anon.Flags().Bool(stripImageRegistriesFlag, defaults.StripImageRegistries, "remove registries from images\n")
+	anon.Flags().Bool(stripAnnotationsFlag, defaults.StripAnnotations, "remove all annotations\n")
+	anon.Flags().StringP(outputFlag, "o", "file:///tmp/kind-node-data", "location to save the anonymized trace\n")
+	return anon
+}
+
+func doAnonymize(cmd *cobra.Command, args []string) {
+	var policy anonymize.Policy
+	var err error
+	if policy.Salt, err = cmd.Flags().GetString(saltFlag); err != nil {
+		fmt.Printf("no salt flag: %v\n", err)
+		os.Exit(1)
+	} else if policy.Salt == "" {
+		if policy.Salt, err = anonymize.NewSalt(); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("using salt %s (pass it to --salt to get the same hashes in other traces)\n", policy.Salt)
+	}
+	for flag, field := range map[string]*bool{
+		hashNamesFlag:            &policy.HashNames,
+		hashLabelsFlag:           &policy.HashLabels,
+		stripEnvFlag:             &policy.StripEnv,
+		stripSecretRefsFlag:      &policy.StripSecretRefs,
+		stripImageRegistriesFlag: &policy.StripImageRegistries,
+		stripAnnotationsFlag:     &policy.StripAnnotations,
+	} {
+		if *field, err = cmd.Flags().GetBool(flag); err != nil {
+			fmt.Printf("no %s flag: %v\n", flag, err)
+			os.Exit(1)
+		}
+	}
+	output, err := cmd.Flags().GetString(outputFlag)
+	if err != nil {
+		fmt.Printf("no output flag: %v\n", err)
+		os.Exit(1)
+	}
+
+	tr := readTraceFile(args[0])
+	writeTrace(output, anonymize.New(policy).Trace(tr))
+}
+
+func chaosCmd() *cobra.Command {
+	chaosTrace := &cobra.Command{
+		Use:   chaosCmdName + " <trace-file> <scenario-file>",
+		Short: "splice chaos events (bursts, scale-downs, evictions) into a trace",
+		Args:  cobra.ExactArgs(2),
+		Run:   doChaos,
+	}
+	chaosTrace.Flags().Int64(
+		seedFlag,
+		0,
+		"random seed for picking objects to evict (by default, the seed in the scenario)\n",
+	)
+	chaosTrace.Flags().StringP(outputFlag, "o", "file:///tmp/kind-node-data", "location to save the new trace\n")
+	return chaosTrace
+}
+
+func doChaos(cmd *cobra.Command, args []string) {
+	output, err := cmd.Flags().GetString(outputFlag)
+	if err != nil {
+		fmt.Printf("no output flag: %v\n", err)
+		os.Exit(1)
+	}
+
+	scenario, err := chaos.LoadScenario(args[1])
+	if err != nil {
+		fmt.Printf("could not load scenario: %v\n", err)
+		os.Exit(1)
+	}
+	if cmd.Flags().Changed(seedFlag) {
+		if scenario.Seed, err = cmd.Flags().GetInt64(seedFlag); err != nil {
+			fmt.Printf("no seed flag: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	tr := readTraceFile(args[0])
+	injected, err := chaos.Inject(tr, scenario)
+	if err != nil {
+		fmt.Printf("could not inject chaos events: %v\n", err)
+		os.Exit(1)
+	}
+	writeTrace(output, injected)
+}
+
+func compactCmd() *cobra.Command {
+	compactTrace := &cobra.Command{
+		Use:   compactCmdName + " <trace-file>",
+		Short: "remove redundant updates from a trace",
+		Args:  cobra.ExactArgs(1),
+		Run:   doCompact,
+	}
+	compactTrace.Flags().Duration(
+		coalesceWindowFlag,
+		0,
+		"only keep the last of a burst of updates to an object that are closer together than this",
+	)
+	compactTrace.Flags().Bool(
+		keepMetadataUpdatesFlag,
+		false,
+		"keep updates that only change an object's labels or annotations",
+	)
+	compactTrace.Flags().Bool(
+		stripStatusFlag,
+		false,
+		"remove the status from every object, except the fields that the tracer records",
+	)
+	compactTrace.Flags().StringP(outputFlag, "o", "file:///tmp/kind-node-data", "location to save the compacted trace\n")
+	return compactTrace
+}
+
+func doCompact(cmd *cobra.Command, args []string) {
+	var opts compact.Options
+	var err error
+	if opts.CoalesceWindow, err = cmd.Flags().GetDuration(coalesceWindowFlag); err != nil {
+		fmt.Printf("no coalesce window flag: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.KeepMetadataUpdates, err = cmd.Flags().GetBool(keepMetadataUpdatesFlag); err != nil {
+		fmt.Printf("no keep metadata updates flag: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.StripStatus, err = cmd.Flags().GetBool(stripStatusFlag); err != nil {
+		fmt.Printf("no strip status flag: %v\n", err)
+		os.Exit(1)
+	}
+	output, err := cmd.Flags().GetString(outputFlag)
+	if err != nil {
+		fmt.Printf("no output flag: %v\n", err)
+		os.Exit(1)
+	}
+
+	tr := readTraceFile(args[0])
+	compacted, stats := compact.Trace(tr, opts)
+	fmt.Println(stats)
+	writeTrace(output, compacted)
+}
+
+func readTraceFile(path string) *trace.Trace {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("could not read %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	tr, err := trace.ReadTrace(bytes.NewReader(data))
+	if err != nil {
+		fmt.Printf("could not parse trace %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	return tr
+}
+
+func writeTrace(output string, tr *trace.Trace) {
+	var buf bytes.Buffer
+	if err := trace.WriteTrace(&buf, tr); err != nil {
+		fmt.Printf("could not encode trace: %v\n", err)
+		os.Exit(1)
+	}
+	if err := writeOutput(output, buf.Bytes()); err != nil {
+		fmt.Printf("could not write trace data to %s: %v\n", output, err)
+		os.Exit(1)
+	}
+}
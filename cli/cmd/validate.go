@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	"simkube/lib/go/driver"
+)
+
+const validateCmdName = "validate"
+
+func Validate(k8sClient client.Client) *cobra.Command {
+	validate := &cobra.Command{
+		Use:   validateCmdName + " <trace-file>",
+		Short: "check that a trace can be replayed",
+		Args:  cobra.ExactArgs(1),
+		Run:   func(cmd *cobra.Command, args []string) { doValidate(cmd, args[0], k8sClient) },
+	}
+	validate.Flags().Bool(
+		againstClusterFlag,
+		false,
+		"also check that the trace's kinds are served by the current cluster,\n"+
+			"    and that the virtual namespaces can be created\n",
+	)
+	validate.Flags().String(virtualNsPrefixFlag, "virtual", "prefix for the virtual namespaces\n")
+	return validate
+}
+
+func doValidate(cmd *cobra.Command, tracePath string, k8sClient client.Client) {
+	againstCluster, err := cmd.Flags().GetBool(againstClusterFlag)
+	if err != nil {
+		fmt.Printf("no against-cluster flag: %v\n", err)
+		os.Exit(1)
+	}
+	virtualNsPrefix, err := cmd.Flags().GetString(virtualNsPrefixFlag)
+	if err != nil {
+		fmt.Printf("no virtual-ns-prefix flag: %v\n", err)
+		os.Exit(1)
+	}
+
+	tr := readTraceFile(tracePath)
+	opts := driver.Options{VirtualNsPrefix: virtualNsPrefix}
+	if !againstCluster {
+		err = driver.ValidateTrace(opts, tr)
+	} else {
+		var dynamicClient dynamic.Interface
+		if dynamicClient, err = newDynamicClient(); err != nil {
+			fmt.Printf("could not construct Kubernetes client: %v\n", err)
+			os.Exit(1)
+		}
+		err = driver.CheckClusterCompatibility(context.Background(), opts, tr, dynamicClient, k8sClient.RESTMapper())
+	}
+
+	if err != nil {
+		fmt.Printf("%s: %v\n", tracePath, err)
+		os.Exit(ExitCode(err))
+	}
+	fmt.Printf("%s: ok\n", tracePath)
+}
+
+func newDynamicClient() (*dynamic.DynamicClient, error) {
+	restConfig, err := config.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("could not get client config: %w", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize Kubernetes dynamic client: %w", err)
+	}
+	return dynamicClient, nil
+}
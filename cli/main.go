@@ -6,6 +6,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/scale/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
@@ -26,12 +27,13 @@ func main() {
 
 	if err := cmd.Root(k8sClient).Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(cmd.ExitCode(err))
 	}
 }
 
 //nolint:gochecknoinits // generated by kubebuilder
 func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(simulationScheme))
 	utilruntime.Must(scheme.AddToScheme(simulationScheme))
 	utilruntime.Must(simkubev1.AddToScheme(simulationScheme))
 }
@@ -1,20 +1,72 @@
 package main
 
 import (
+	"context"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"simkube/cloudprov"
+	"simkube/lib/go/audit"
+	"simkube/lib/go/debugserver"
+	"simkube/lib/go/k8s"
 	"simkube/lib/go/util"
 )
 
 const (
 	progname = "sk-cloudprov"
 
-	verbosityFlag = "verbosity"
-	jsonLogsFlag  = "jsonlogs"
-	appLabelFlag  = "applabel"
+	verbosityFlag        = "verbosity"
+	jsonLogsFlag         = "jsonlogs"
+	otlpLogsEndpointFlag = "otlp-logs-endpoint"
+	redactKeysFlag       = "redact-keys"
+	redactPatternsFlag   = "redact-patterns"
+	debugAddrFlag        = "debug-addr"
+	auditSinkFlag        = "audit-sink"
+	logRPCFlag           = "log-rpc"
+	logSampleRateFlag    = "log-sample-rate"
+	reflectionFlag       = "grpc-reflection"
+	listenAddrFlag       = "listen-addr"
+	appLabelFlag         = "applabel"
+	nodeGroupResFlag     = "node-group-resources"
+	watchNamespacesFlag  = "watch-namespaces"
+	fleetsFlag           = "fleets"
+	nodeGroupsConfigFlag = "node-groups-config"
+	karpenterConfigFlag  = "karpenter-config"
+	maxNodeGroupSizeFlag = "max-node-group-size"
+	priceTableFlag       = "price-table"
+	nodeSkeletonFlag     = "node-skeleton"
+	gpuLabelFlag         = "gpu-label"
+	gpuTypesFlag         = "gpu-types"
+	instanceTimeoutFlag  = "instance-creation-timeout"
+	provDelayFlag        = "provisioning-delay"
+	provDelayMaxFlag     = "provisioning-delay-max"
+	cleanupPolicyFlag    = "cleanup-policy"
+	priorityNSFlag       = "priority-expander-namespace"
+	clientQPSFlag        = "kube-api-qps"
+	clientBurstFlag      = "kube-api-burst"
+	clientTimeoutFlag    = "kube-api-timeout"
+	tlsCertFlag          = "tls-cert-file"
+	tlsKeyFlag           = "tls-key-file"
+	tlsClientCAFlag      = "tls-client-ca-file"
+	keepaliveTimeFlag    = "keepalive-time"
+	keepaliveTimeoutFlag = "keepalive-timeout"
+	keepaliveMinFlag     = "keepalive-min-time"
+	keepalivePermitFlag  = "keepalive-permit-without-stream"
+	maxConnIdleFlag      = "max-connection-idle"
+	maxConnAgeFlag       = "max-connection-age"
+	maxConnAgeGraceFlag  = "max-connection-age-grace"
+	maxRecvMsgSizeFlag   = "max-recv-msg-size"
+	maxSendMsgSizeFlag   = "max-send-msg-size"
+	leaderElectFlag      = "leader-elect"
+	leaderNamespaceFlag  = "leader-election-namespace"
+	leaderLeaseFlag      = "leader-election-lease-name"
+	metricsAPIAddrFlag   = "metrics-api-addr"
+	metricsAPICPUFlag    = "metrics-api-cpu-utilization"
+	metricsAPIMemFlag    = "metrics-api-memory-utilization"
+
+	customMetricsAPIAddrFlag = "custom-metrics-api-addr"
 )
 
 func rootCmd() *cobra.Command {
@@ -26,7 +78,204 @@ func rootCmd() *cobra.Command {
 
 	root.PersistentFlags().IntP(verbosityFlag, "v", 2, "log level output (higher is more verbose")
 	root.PersistentFlags().Bool(jsonLogsFlag, false, "structured JSON logging output")
+	root.PersistentFlags().String(
+		otlpLogsEndpointFlag,
+		"",
+		"OTLP/HTTP endpoint to export logs to, e.g., http://otel-collector:4318/v1/logs\n"+
+			"    (defaults to $OTEL_EXPORTER_OTLP_LOGS_ENDPOINT; if neither is set, logs aren't exported)",
+	)
+	root.PersistentFlags().StringSlice(
+		redactKeysFlag,
+		nil,
+		"additional log field names and keys whose values are masked in the logs (passwords, tokens, etc. always are)",
+	)
+	root.PersistentFlags().StringArray(
+		redactPatternsFlag,
+		nil,
+		"regular expression whose matches are masked in the logs (can be repeated)",
+	)
+	root.PersistentFlags().String(
+		debugAddrFlag,
+		"",
+		"listen address for the debug HTTP server, with pprof profiles and expvar stats (empty to disable)",
+	)
+	root.PersistentFlags().String(
+		auditSinkFlag,
+		"",
+		"file path (- for stdout) or http(s) URL to record lifecycle decisions to, as JSON (empty to disable)",
+	)
+	root.PersistentFlags().Bool(logRPCFlag, false, "log every gRPC request (if unset, only failed requests are logged)")
+	root.PersistentFlags().Int(
+		logSampleRateFlag,
+		1,
+		"only log 1 in N of the successful requests and routine messages for each method (failures are always logged)",
+	)
+	root.PersistentFlags().Bool(
+		reflectionFlag,
+		false,
+		"enable the gRPC reflection service, so that tools like grpcurl can inspect the API",
+	)
+	root.PersistentFlags().String(
+		listenAddrFlag,
+		":8086",
+		"listen address for the gRPC server (e.g., 127.0.0.1:8086 to only accept local connections)",
+	)
 	root.PersistentFlags().StringP(appLabelFlag, "A", "sk-vnode", "app label selector for virtual nodes")
+	root.PersistentFlags().StringSlice(
+		nodeGroupResFlag,
+		[]string{k8s.DefaultNodeGroupResource},
+		"kinds of objects that are used as node groups, as <resource>.<version>.<group>",
+	)
+	root.PersistentFlags().StringSlice(
+		watchNamespacesFlag,
+		nil,
+		"namespaces to discover node groups in (if unset, all namespaces are watched)",
+	)
+	root.PersistentFlags().String(
+		fleetsFlag,
+		"",
+		"location of a file that defines multiple fleets of node groups (if unset, --applabel selects the node groups)",
+	)
+	root.PersistentFlags().String(
+		nodeGroupsConfigFlag,
+		"",
+		"location of a file that declares node groups for the cloud provider to create and own",
+	)
+	root.PersistentFlags().String(
+		karpenterConfigFlag,
+		"",
+		"location of a file that configures virtual nodes for Karpenter NodeClaims (enables the NodeClaim controller)",
+	)
+	root.PersistentFlags().Int32(
+		maxNodeGroupSizeFlag,
+		10,
+		"maximum size of node groups without a simkube.io/max-size annotation",
+	)
+	root.PersistentFlags().String(
+		priceTableFlag,
+		"",
+		"location of a file with node and pod prices (if unset, pricing is not supported)",
+	)
+	root.PersistentFlags().String(
+		nodeSkeletonFlag,
+		"",
+		"node skeleton (or directory of node templates) used to scale up empty node groups",
+	)
+	root.PersistentFlags().String(gpuLabelFlag, "simkube.io/gpu-type", "label that records the GPU type of nodes")
+	root.PersistentFlags().StringSlice(
+		gpuTypesFlag,
+		[]string{},
+		"GPU types that are available, in addition to those of the existing node groups",
+	)
+	root.PersistentFlags().Duration(
+		instanceTimeoutFlag,
+		5*time.Minute,
+		"how long a virtual node pod can go without registering before it's reported as a failed instance",
+	)
+	root.PersistentFlags().Duration(
+		provDelayFlag,
+		0,
+		"how long a scale-up takes before the node group is scaled (models cloud API latency)",
+	)
+	root.PersistentFlags().Duration(
+		provDelayMaxFlag,
+		0,
+		"if larger than --provisioning-delay, the provisioning delay is chosen uniformly at random up to this value",
+	)
+	root.PersistentFlags().String(
+		cleanupPolicyFlag,
+		"none",
+		"what to do with the node groups when Cluster Autoscaler shuts down (none, min-size, or zero)",
+	)
+	root.PersistentFlags().String(
+		priorityNSFlag,
+		"",
+		"namespace of Cluster Autoscaler's priority expander ConfigMap, which is kept in sync with the node group "+
+			"priorities (if unset, it isn't managed)",
+	)
+	root.PersistentFlags().Float32(clientQPSFlag, 5, "maximum rate of requests to the Kubernetes API server")
+	root.PersistentFlags().Int(clientBurstFlag, 10, "maximum burst of requests to the Kubernetes API server")
+	root.PersistentFlags().Duration(
+		clientTimeoutFlag,
+		0,
+		"how long each request to the Kubernetes API server can take (0 means no timeout)",
+	)
+	root.PersistentFlags().String(tlsCertFlag, "", "server certificate for the gRPC server (if unset, TLS is disabled)")
+	root.PersistentFlags().String(tlsKeyFlag, "", "private key for the server certificate")
+	root.PersistentFlags().String(
+		tlsClientCAFlag,
+		"",
+		"CA bundle used to verify client certificates (if unset, client certificates are not required)",
+	)
+	root.PersistentFlags().Duration(
+		keepaliveTimeFlag,
+		0,
+		"how long a gRPC connection can be idle before the server pings the client (if unset, 2h)",
+	)
+	root.PersistentFlags().Duration(
+		keepaliveTimeoutFlag,
+		0,
+		"how long the server waits for a keepalive ping to be acknowledged (if unset, 20s)",
+	)
+	root.PersistentFlags().Duration(
+		keepaliveMinFlag,
+		0,
+		"minimum interval between keepalive pings from clients; clients that ping more often are disconnected "+
+			"(if unset, 5m)",
+	)
+	root.PersistentFlags().Bool(
+		keepalivePermitFlag,
+		false,
+		"allow clients to send keepalive pings when there are no active requests",
+	)
+	root.PersistentFlags().Duration(
+		maxConnIdleFlag,
+		0,
+		"how long a gRPC connection can go without requests before it's closed (if unset, forever)",
+	)
+	root.PersistentFlags().Duration(
+		maxConnAgeFlag,
+		0,
+		"how long a gRPC connection can stay open before it's closed (if unset, forever)",
+	)
+	root.PersistentFlags().Duration(
+		maxConnAgeGraceFlag,
+		0,
+		"how long outstanding requests get to complete after --max-connection-age (if unset, forever)",
+	)
+	root.PersistentFlags().Int(maxRecvMsgSizeFlag, 0, "largest gRPC message the server will receive (if unset, 4MiB)")
+	root.PersistentFlags().Int(maxSendMsgSizeFlag, 0, "largest gRPC message the server will send (if unset, 2GiB)")
+	root.PersistentFlags().Bool(
+		leaderElectFlag,
+		false,
+		"elect a leader among the replicas of the cloud provider; only the leader answers Cluster Autoscaler",
+	)
+	root.PersistentFlags().String(
+		leaderNamespaceFlag,
+		"",
+		"namespace of the leader election lease (if unset, the POD_NAMESPACE environment variable is used)",
+	)
+	root.PersistentFlags().String(leaderLeaseFlag, progname, "name of the leader election lease")
+	root.PersistentFlags().String(
+		metricsAPIAddrFlag,
+		"",
+		"listen address for the resource metrics API for virtual nodes (requires TLS; empty to disable)",
+	)
+	root.PersistentFlags().Float64(
+		metricsAPICPUFlag,
+		0.5,
+		"fraction of their CPU requests that simulated containers use, unless the pod has a simkube.io/cpu-load annotation",
+	)
+	root.PersistentFlags().Float64(
+		metricsAPIMemFlag,
+		0.5,
+		"fraction of their memory requests that simulated containers use",
+	)
+	root.PersistentFlags().String(
+		customMetricsAPIAddrFlag,
+		"",
+		"listen address for the custom and external metrics APIs for simulations (requires TLS; empty to disable)",
+	)
 	return root
 }
 
@@ -41,12 +290,307 @@ func start(cmd *cobra.Command, _ []string) {
 		panic(err)
 	}
 
-	util.SetupLogging(level, jsonLogs)
+	otlpLogsEndpoint, err := cmd.PersistentFlags().GetString(otlpLogsEndpointFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	redactKeys, err := cmd.PersistentFlags().GetStringSlice(redactKeysFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	redactPatterns, err := cmd.PersistentFlags().GetStringArray(redactPatternsFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	debugAddr, err := cmd.PersistentFlags().GetString(debugAddrFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	auditSink, err := cmd.PersistentFlags().GetString(auditSinkFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	flushLogs := util.SetupLogging(level, jsonLogs, util.OTLPLogOptions{Endpoint: otlpLogsEndpoint, Component: progname})
+	defer flushLogs()
+	if err := util.SetupRedaction(util.RedactionOptions{Keys: redactKeys, Patterns: redactPatterns}); err != nil {
+		panic(err)
+	}
+	if debugAddr != "" {
+		debugserver.Run(context.Background(), debugAddr)
+	}
+	flushAudit, err := audit.Setup(audit.Options{Sink: auditSink, Component: progname})
+	if err != nil {
+		panic(err)
+	}
+	defer flushAudit()
+	util.HandleLogLevelSignal()
+	logRPC, err := cmd.PersistentFlags().GetBool(logRPCFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	logSampleRate, err := cmd.PersistentFlags().GetInt(logSampleRateFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	reflection, err := cmd.PersistentFlags().GetBool(reflectionFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	listenAddr, err := cmd.PersistentFlags().GetString(listenAddrFlag)
+	if err != nil {
+		panic(err)
+	}
+
 	appLabel, err := cmd.PersistentFlags().GetString(appLabelFlag)
 	if err != nil {
 		panic(err)
 	}
-	cloudprov.Run(appLabel)
+
+	nodeGroupResources, err := cmd.PersistentFlags().GetStringSlice(nodeGroupResFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	watchNamespaces, err := cmd.PersistentFlags().GetStringSlice(watchNamespacesFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	fleetsFile, err := cmd.PersistentFlags().GetString(fleetsFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	nodeGroupsConfigFile, err := cmd.PersistentFlags().GetString(nodeGroupsConfigFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	karpenterConfigFile, err := cmd.PersistentFlags().GetString(karpenterConfigFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	maxNodeGroupSize, err := cmd.PersistentFlags().GetInt32(maxNodeGroupSizeFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	priceTableFile, err := cmd.PersistentFlags().GetString(priceTableFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	nodeSkeletonPath, err := cmd.PersistentFlags().GetString(nodeSkeletonFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	gpuLabel, err := cmd.PersistentFlags().GetString(gpuLabelFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	gpuTypes, err := cmd.PersistentFlags().GetStringSlice(gpuTypesFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	instanceTimeout, err := cmd.PersistentFlags().GetDuration(instanceTimeoutFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	provDelay, err := cmd.PersistentFlags().GetDuration(provDelayFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	provDelayMax, err := cmd.PersistentFlags().GetDuration(provDelayMaxFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	cleanupPolicy, err := cmd.PersistentFlags().GetString(cleanupPolicyFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	priorityNamespace, err := cmd.PersistentFlags().GetString(priorityNSFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	clientQPS, err := cmd.PersistentFlags().GetFloat32(clientQPSFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	clientBurst, err := cmd.PersistentFlags().GetInt(clientBurstFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	clientTimeout, err := cmd.PersistentFlags().GetDuration(clientTimeoutFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	tlsCertFile, err := cmd.PersistentFlags().GetString(tlsCertFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	tlsKeyFile, err := cmd.PersistentFlags().GetString(tlsKeyFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	tlsClientCAFile, err := cmd.PersistentFlags().GetString(tlsClientCAFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	keepaliveTime, err := cmd.PersistentFlags().GetDuration(keepaliveTimeFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	keepaliveTimeout, err := cmd.PersistentFlags().GetDuration(keepaliveTimeoutFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	keepaliveMinTime, err := cmd.PersistentFlags().GetDuration(keepaliveMinFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	keepalivePermit, err := cmd.PersistentFlags().GetBool(keepalivePermitFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	maxConnIdle, err := cmd.PersistentFlags().GetDuration(maxConnIdleFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	maxConnAge, err := cmd.PersistentFlags().GetDuration(maxConnAgeFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	maxConnAgeGrace, err := cmd.PersistentFlags().GetDuration(maxConnAgeGraceFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	maxRecvMsgSize, err := cmd.PersistentFlags().GetInt(maxRecvMsgSizeFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	maxSendMsgSize, err := cmd.PersistentFlags().GetInt(maxSendMsgSizeFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	leaderElect, err := cmd.PersistentFlags().GetBool(leaderElectFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	leaderNamespace, err := cmd.PersistentFlags().GetString(leaderNamespaceFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	leaderLease, err := cmd.PersistentFlags().GetString(leaderLeaseFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	metricsAPIAddr, err := cmd.PersistentFlags().GetString(metricsAPIAddrFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	metricsAPICPU, err := cmd.PersistentFlags().GetFloat64(metricsAPICPUFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	metricsAPIMem, err := cmd.PersistentFlags().GetFloat64(metricsAPIMemFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	customMetricsAPIAddr, err := cmd.PersistentFlags().GetString(customMetricsAPIAddrFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	cloudprov.Run(cloudprov.Options{
+		ListenAddr:              listenAddr,
+		AppLabel:                appLabel,
+		NodeGroupResources:      nodeGroupResources,
+		WatchNamespaces:         watchNamespaces,
+		FleetsFile:              fleetsFile,
+		NodeGroupsConfigFile:    nodeGroupsConfigFile,
+		KarpenterConfigFile:     karpenterConfigFile,
+		MaxNodeGroupSize:        maxNodeGroupSize,
+		PriceTableFile:          priceTableFile,
+		NodeSkeletonPath:        nodeSkeletonPath,
+		GPULabel:                gpuLabel,
+		GPUTypes:                gpuTypes,
+		InstanceCreationTimeout: instanceTimeout,
+		ProvisioningDelay:       provDelay,
+		ProvisioningDelayMax:    provDelayMax,
+		CleanupPolicy:           cleanupPolicy,
+		PriorityExpanderNS:      priorityNamespace,
+		ClientQPS:               clientQPS,
+		ClientBurst:             clientBurst,
+		ClientTimeout:           clientTimeout,
+		LogRPC:                  logRPC,
+		LogSampleRate:           logSampleRate,
+		Reflection:              reflection,
+		TLS: cloudprov.TLSOptions{
+			CertFile:     tlsCertFile,
+			KeyFile:      tlsKeyFile,
+			ClientCAFile: tlsClientCAFile,
+		},
+		Connection: cloudprov.ConnectionOptions{
+			KeepaliveTime:                keepaliveTime,
+			KeepaliveTimeout:             keepaliveTimeout,
+			KeepaliveMinTime:             keepaliveMinTime,
+			KeepalivePermitWithoutStream: keepalivePermit,
+			MaxConnectionIdle:            maxConnIdle,
+			MaxConnectionAge:             maxConnAge,
+			MaxConnectionAgeGrace:        maxConnAgeGrace,
+			MaxRecvMsgSize:               maxRecvMsgSize,
+			MaxSendMsgSize:               maxSendMsgSize,
+		},
+		LeaderElection: cloudprov.LeaderElectionOptions{
+			Enabled:   leaderElect,
+			Namespace: leaderNamespace,
+			LeaseName: leaderLease,
+		},
+		MetricsAPI: cloudprov.MetricsAPIOptions{
+			Addr:              metricsAPIAddr,
+			CPUUtilization:    metricsAPICPU,
+			MemoryUtilization: metricsAPIMem,
+		},
+		CustomMetricsAPIAddr: customMetricsAPIAddr,
+	})
 }
 
 func main() {
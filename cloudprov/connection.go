@@ -0,0 +1,78 @@
+package cloudprov
+
+import (
+	"errors"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// ConnectionOptions tunes how the gRPC server manages its connections to Cluster Autoscaler,
+// e.g., to keep them from going stale behind a service mesh or a load balancer that drops idle
+// connections.  The zero value of each field uses the gRPC default.
+type ConnectionOptions struct {
+	// KeepaliveTime is how long a connection can be idle before the server pings the client,
+	// and KeepaliveTimeout is how long the server waits for the ping to be acknowledged
+	// before closing the connection
+	KeepaliveTime    time.Duration
+	KeepaliveTimeout time.Duration
+
+	// KeepaliveMinTime is the shortest interval at which clients are allowed to send pings
+	// (more frequent pings close the connection), and KeepalivePermitWithoutStream allows
+	// clients to send pings when there are no active RPCs
+	KeepaliveMinTime             time.Duration
+	KeepalivePermitWithoutStream bool
+
+	// MaxConnectionIdle is how long a connection can go without any RPCs before it's closed,
+	// and MaxConnectionAge is how long any connection can stay open; after MaxConnectionAge,
+	// the outstanding RPCs get MaxConnectionAgeGrace to complete
+	MaxConnectionIdle     time.Duration
+	MaxConnectionAge      time.Duration
+	MaxConnectionAgeGrace time.Duration
+
+	// MaxRecvMsgSize and MaxSendMsgSize are the largest messages (in bytes) that the server
+	// will receive and send
+	MaxRecvMsgSize int
+	MaxSendMsgSize int
+}
+
+func (self *ConnectionOptions) serverOptions() ([]grpc.ServerOption, error) {
+	for _, d := range []time.Duration{
+		self.KeepaliveTime,
+		self.KeepaliveTimeout,
+		self.KeepaliveMinTime,
+		self.MaxConnectionIdle,
+		self.MaxConnectionAge,
+		self.MaxConnectionAgeGrace,
+	} {
+		if d < 0 {
+			return nil, errors.New("keepalive and connection durations cannot be negative")
+		}
+	}
+	if self.MaxRecvMsgSize < 0 || self.MaxSendMsgSize < 0 {
+		return nil, errors.New("message size limits cannot be negative")
+	}
+
+	// gRPC replaces the zero values in the keepalive parameters with its defaults
+	opts := []grpc.ServerOption{
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionIdle:     self.MaxConnectionIdle,
+			MaxConnectionAge:      self.MaxConnectionAge,
+			MaxConnectionAgeGrace: self.MaxConnectionAgeGrace,
+			Time:                  self.KeepaliveTime,
+			Timeout:               self.KeepaliveTimeout,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             self.KeepaliveMinTime,
+			PermitWithoutStream: self.KeepalivePermitWithoutStream,
+		}),
+	}
+	if self.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(self.MaxRecvMsgSize))
+	}
+	if self.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(self.MaxSendMsgSize))
+	}
+	return opts, nil
+}
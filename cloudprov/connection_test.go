@@ -0,0 +1,67 @@
+package cloudprov
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+)
+
+func TestConnectionServerOptionsInvalid(t *testing.T) {
+	cases := map[string]ConnectionOptions{
+		"negative keepalive time":  {KeepaliveTime: -time.Second},
+		"negative connection age":  {MaxConnectionAge: -time.Second},
+		"negative recv size limit": {MaxRecvMsgSize: -1},
+	}
+
+	for name, opts := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := opts.serverOptions()
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestConnectionMaxRecvMsgSize(t *testing.T) {
+	connOpts := ConnectionOptions{MaxRecvMsgSize: 1024, MaxConnectionAge: time.Minute}
+	serverOpts, err := connOpts.serverOptions()
+	assert.Nil(t, err)
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(serverOpts...)
+	protos.RegisterCloudProviderServer(srv, &protos.UnimplementedCloudProviderServer{})
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			panic(err)
+		}
+	}()
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(
+		"bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.Nil(t, err)
+	defer func() { assert.Nil(t, conn.Close()) }()
+	client := protos.NewCloudProviderClient(conn)
+
+	// Small requests get through to the (unimplemented) handler, but large ones are rejected
+	_, err = client.NodeGroupTargetSize(context.TODO(), &protos.NodeGroupTargetSizeRequest{Id: "test/group"})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+
+	_, err = client.NodeGroupTargetSize(
+		context.TODO(),
+		&protos.NodeGroupTargetSizeRequest{Id: strings.Repeat("a", 2048)},
+	)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
@@ -0,0 +1,135 @@
+package cloudprov
+
+import (
+	"context"
+	"path"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+
+	"simkube/lib/go/util"
+)
+
+// loggingInterceptor logs every RPC from Cluster Autoscaler with its method, node group,
+// latency, and result code, so that the whole conversation between Cluster Autoscaler and
+// the cloud provider can be reconstructed from the logs of a simulation.  If logAll is
+// false, only the failed RPCs are logged, and otherwise the successful ones are sampled for each
+// method.  The method and the request fields are also added to the logger in the request
+// context, so that everything that the handler logs has them.
+func loggingInterceptor(logAll bool, sampler *util.LogSampler) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		start := time.Now()
+		ctx = util.WithLogFields(ctx, rpcFields(req, nil))
+		ctx = util.WithLogFields(ctx, log.Fields{"method": path.Base(info.FullMethod)})
+		resp, err := handler(ctx, req)
+		if err == nil && !logAll {
+			return resp, err
+		}
+
+		logger := util.LoggerFromContext(ctx).WithFields(rpcFields(req, resp)).WithFields(log.Fields{
+			"latency": time.Since(start).String(),
+			"code":    status.Code(err).String(),
+		})
+		if err != nil {
+			logger.Errorf("RPC failed: %s", err)
+		} else {
+			sampler.Sample(info.FullMethod, logger).Info("RPC completed")
+		}
+
+		//nolint:wrapcheck // the interceptor has to return the handler's error unchanged
+		return resp, err
+	}
+}
+
+// errorStatusInterceptor turns the errors that the handlers return into gRPC statuses, based
+// on their kind (see util.ErrNotFound, etc.), so that Cluster Autoscaler can tell a missing
+// node group from a bad request or a transient failure; errors that already carry a status
+// are returned unchanged, and anything else is left as Unknown.
+func errorStatusInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		_ *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		return resp, errorStatus(err)
+	}
+}
+
+func errorStatus(err error) error {
+	if err == nil {
+		return nil
+	} else if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	code := codes.Unknown
+	switch {
+	case util.IsNotFound(err):
+		code = codes.NotFound
+	case util.IsValidation(err):
+		code = codes.InvalidArgument
+	case util.IsConflict(err):
+		code = codes.Aborted
+	case util.IsRetriable(err):
+		code = codes.Unavailable
+	}
+	//nolint:wrapcheck // the status keeps the original error message
+	return status.Error(code, err.Error())
+}
+
+// The request (and response) types don't share an interface, but the generated getters let
+// us pull out the fields that identify what Cluster Autoscaler was asking about
+type nodeGroupRequest interface {
+	GetId() string
+}
+
+type nodeGroupResponse interface {
+	GetNodeGroup() *protos.NodeGroup
+}
+
+type nodeRequest interface {
+	GetNode() *protos.ExternalGrpcNode
+}
+
+type nodesRequest interface {
+	GetNodes() []*protos.ExternalGrpcNode
+}
+
+type deltaRequest interface {
+	GetDelta() int32
+}
+
+func rpcFields(req, resp interface{}) log.Fields {
+	fields := log.Fields{}
+	if r, ok := req.(nodeGroupRequest); ok {
+		fields["nodeGroup"] = r.GetId()
+	}
+	if r, ok := resp.(nodeGroupResponse); ok && r.GetNodeGroup() != nil {
+		fields["nodeGroup"] = r.GetNodeGroup().GetId()
+	}
+	if r, ok := req.(nodeRequest); ok && r.GetNode() != nil {
+		fields["node"] = r.GetNode().GetName()
+	}
+	if r, ok := req.(nodesRequest); ok {
+		nodes := make([]string, len(r.GetNodes()))
+		for i, n := range r.GetNodes() {
+			nodes[i] = n.GetName()
+		}
+		fields["nodes"] = nodes
+	}
+	if r, ok := req.(deltaRequest); ok {
+		fields["delta"] = r.GetDelta()
+	}
+	return fields
+}
@@ -0,0 +1,172 @@
+package cloudprov
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+
+	"simkube/lib/go/util"
+)
+
+func TestLoggingInterceptor(t *testing.T) {
+	cases := map[string]struct {
+		logAll         bool
+		method         string
+		req            interface{}
+		resp           interface{}
+		err            error
+		expectedLevel  log.Level
+		expectedFields log.Fields
+	}{
+		"success": {
+			logAll:        true,
+			method:        "NodeGroupIncreaseSize",
+			req:           &protos.NodeGroupIncreaseSizeRequest{Id: "test/group", Delta: 2},
+			resp:          &protos.NodeGroupIncreaseSizeResponse{},
+			expectedLevel: log.InfoLevel,
+			expectedFields: log.Fields{
+				"nodeGroup": "test/group",
+				"delta":     int32(2),
+				"code":      "OK",
+			},
+		},
+		"success not logged": {
+			method: "NodeGroupIncreaseSize",
+			req:    &protos.NodeGroupIncreaseSizeRequest{Id: "test/group", Delta: 2},
+			resp:   &protos.NodeGroupIncreaseSizeResponse{},
+		},
+		"node group from response": {
+			logAll: true,
+			method: "NodeGroupForNode",
+			req:    &protos.NodeGroupForNodeRequest{Node: &protos.ExternalGrpcNode{Name: "node1"}},
+			resp: &protos.NodeGroupForNodeResponse{
+				NodeGroup: &protos.NodeGroup{Id: "test/group"},
+			},
+			expectedLevel: log.InfoLevel,
+			expectedFields: log.Fields{
+				"nodeGroup": "test/group",
+				"node":      "node1",
+				"code":      "OK",
+			},
+		},
+		"failure": {
+			method: "NodeGroupDeleteNodes",
+			req: &protos.NodeGroupDeleteNodesRequest{
+				Id:    "test/group",
+				Nodes: []*protos.ExternalGrpcNode{{Name: "node1"}, {Name: "node2"}},
+			},
+			err:           status.Error(codes.NotFound, "unknown node group"),
+			expectedLevel: log.ErrorLevel,
+			expectedFields: log.Fields{
+				"nodeGroup": "test/group",
+				"nodes":     []string{"node1", "node2"},
+				"code":      "NotFound",
+			},
+		},
+		"failure without status": {
+			method:        "Refresh",
+			req:           &protos.RefreshRequest{},
+			err:           errors.New("informers have not been started"),
+			expectedLevel: log.ErrorLevel,
+			expectedFields: log.Fields{
+				"code": "Unknown",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			hook := test.NewGlobal()
+			defer hook.Reset()
+
+			info := &grpc.UnaryServerInfo{
+				FullMethod: "/clusterautoscaler.cloudprovider.v1.externalgrpc.CloudProvider/" + tc.method,
+			}
+			handler := func(context.Context, interface{}) (interface{}, error) { return tc.resp, tc.err }
+
+			resp, err := loggingInterceptor(tc.logAll, nil)(context.TODO(), tc.req, info, handler)
+			assert.Equal(t, tc.resp, resp)
+			assert.Equal(t, tc.err, err)
+
+			if tc.expectedFields == nil {
+				assert.Empty(t, hook.AllEntries())
+				return
+			}
+
+			entry := hook.LastEntry()
+			if assert.NotNil(t, entry) {
+				assert.Equal(t, tc.expectedLevel, entry.Level)
+				assert.Equal(t, tc.method, entry.Data["method"])
+				assert.Contains(t, entry.Data, "latency")
+				for k, v := range tc.expectedFields {
+					assert.Equal(t, v, entry.Data[k], k)
+				}
+			}
+		})
+	}
+}
+
+func TestLoggingInterceptorContext(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	info := &grpc.UnaryServerInfo{
+		FullMethod: "/clusterautoscaler.cloudprovider.v1.externalgrpc.CloudProvider/NodeGroupIncreaseSize",
+	}
+	handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		util.LoggerFromContext(ctx).Info("scaling up")
+		return &protos.NodeGroupIncreaseSizeResponse{}, nil
+	}
+
+	req := &protos.NodeGroupIncreaseSizeRequest{Id: "test/group", Delta: 2}
+	_, err := loggingInterceptor(false, nil)(context.TODO(), req, info, handler)
+	assert.Nil(t, err)
+
+	entries := hook.AllEntries()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "scaling up", entries[0].Message)
+		assert.Equal(t, "NodeGroupIncreaseSize", entries[0].Data["method"])
+		assert.Equal(t, "test/group", entries[0].Data["nodeGroup"])
+		assert.Equal(t, int32(2), entries[0].Data["delta"])
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	gr := schema.GroupResource{Group: "simkube.io", Resource: "virtualnodegroups"}
+	cases := map[string]struct {
+		err          error
+		expectedCode codes.Code
+	}{
+		"nil":        {expectedCode: codes.OK},
+		"status":     {err: status.Error(codes.NotFound, "unknown node group"), expectedCode: codes.NotFound},
+		"not found":  {err: fmt.Errorf("%w: foo", util.ErrNotFound), expectedCode: codes.NotFound},
+		"validation": {err: util.WithKind(util.ErrValidation, errors.New("bad")), expectedCode: codes.InvalidArgument},
+		"k8s conflict": {
+			err:          fmt.Errorf("could not scale: %w", apierrors.NewConflict(gr, "test", errors.New("stale"))),
+			expectedCode: codes.Aborted,
+		},
+		"k8s throttled": {err: apierrors.NewTooManyRequests("slow down", 1), expectedCode: codes.Unavailable},
+		"unknown":       {err: errors.New("something went wrong"), expectedCode: codes.Unknown},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := errorStatus(tc.err)
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.err != nil {
+				assert.Contains(t, err.Error(), tc.err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package cloudprov
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"k8s.io/client-go/kubernetes"
+
+	"simkube/lib/go/k8s"
+)
+
+// LeaderElectionOptions configures leader election between replicas of the cloud provider;
+// if Namespace is empty, the lease is created in the cloud provider's own namespace (from the
+// POD_NAMESPACE environment variable).
+type LeaderElectionOptions struct {
+	Enabled   bool
+	Namespace string
+	LeaseName string
+}
+
+// leader tracks whether this replica of the cloud provider is the leader.  Every replica
+// serves gRPC requests, but until it's elected, everything except the health check fails with
+// Unavailable (so that standby replicas never scale anything), and the health check reports
+// NOT_SERVING (so that a readiness probe keeps Cluster Autoscaler's requests on the leader).
+type leader struct {
+	isLeader     atomic.Bool
+	healthServer *health.Server
+}
+
+func newLeader(healthServer *health.Server, isLeader bool) *leader {
+	l := &leader{healthServer: healthServer}
+	l.setLeader(isLeader)
+	return l
+}
+
+func (self *leader) setLeader(isLeader bool) {
+	self.isLeader.Store(isLeader)
+	servingStatus := healthpb.HealthCheckResponse_NOT_SERVING
+	if isLeader {
+		servingStatus = healthpb.HealthCheckResponse_SERVING
+	}
+	self.healthServer.SetServingStatus("", servingStatus)
+}
+
+func (self *leader) interceptor() grpc.UnaryServerInterceptor {
+	healthPrefix := fmt.Sprintf("/%s/", healthpb.Health_ServiceDesc.ServiceName)
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if !self.isLeader.Load() && !strings.HasPrefix(info.FullMethod, healthPrefix) {
+			return nil, status.Error(codes.Unavailable, "this replica of the cloud provider is not the leader")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// elect runs leader election in the background until ctx is cancelled; onStartedLeading starts
+// anything that only the leader runs.  Once the leader loses its lease, another replica might
+// already be scaling node groups, so there's no safe way to keep going; onStoppedLeading should
+// exit the process, so that it comes back as a standby.
+func (self *leader) elect(
+	ctx context.Context,
+	client kubernetes.Interface,
+	opts LeaderElectionOptions,
+	onStartedLeading func(context.Context),
+	onStoppedLeading func(),
+) error {
+	elector, err := k8s.NewLeaderElector(
+		client,
+		k8s.LeaderElectionOptions{Namespace: opts.Namespace, LeaseName: opts.LeaseName},
+		k8s.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				self.setLeader(true)
+				onStartedLeading(ctx)
+			},
+			OnStoppedLeading: func() {
+				self.setLeader(false)
+				onStoppedLeading()
+			},
+		},
+	)
+	if err != nil {
+		//nolint:wrapcheck // already wrapped
+		return err
+	}
+
+	go elector.Run(ctx)
+	return nil
+}
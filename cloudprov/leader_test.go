@@ -0,0 +1,109 @@
+package cloudprov
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"simkube/lib/go/k8s"
+)
+
+func TestLeaderInterceptor(t *testing.T) {
+	cases := map[string]struct {
+		isLeader     bool
+		method       string
+		expectedCode codes.Code
+	}{
+		"leader": {
+			isLeader: true,
+			method:   "/clusterautoscaler.cloudprovider.v1.externalgrpc.CloudProvider/NodeGroupIncreaseSize",
+		},
+		"standby": {
+			method:       "/clusterautoscaler.cloudprovider.v1.externalgrpc.CloudProvider/NodeGroupIncreaseSize",
+			expectedCode: codes.Unavailable,
+		},
+		"standby health check": {
+			method: "/grpc.health.v1.Health/Check",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := newLeader(health.NewServer(), tc.isLeader)
+			called := false
+			handler := func(context.Context, interface{}) (interface{}, error) {
+				called = true
+				return struct{}{}, nil
+			}
+
+			_, err := l.interceptor()(context.TODO(), nil, &grpc.UnaryServerInfo{FullMethod: tc.method}, handler)
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			assert.Equal(t, tc.expectedCode == codes.OK, called)
+		})
+	}
+}
+
+func TestLeaderHealth(t *testing.T) {
+	healthServer := health.NewServer()
+	l := newLeader(healthServer, false)
+
+	resp, err := healthServer.Check(context.TODO(), &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+	l.setLeader(true)
+	resp, err = healthServer.Check(context.TODO(), &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+}
+
+func TestLeaderElect(t *testing.T) {
+	t.Setenv(k8s.PodNameEnvKey, "sk-cloudprov-1")
+	t.Setenv(k8s.PodNamespaceEnvKey, "simkube")
+
+	client := fake.NewSimpleClientset()
+	l := newLeader(health.NewServer(), false)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var started atomic.Bool
+	err := l.elect(
+		ctx,
+		client,
+		LeaderElectionOptions{Enabled: true, LeaseName: "sk-cloudprov"},
+		func(context.Context) { started.Store(true) },
+		func() {},
+	)
+	assert.Nil(t, err)
+	assert.Eventually(t, l.isLeader.Load, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, started.Load, 5*time.Second, 10*time.Millisecond)
+
+	lease, err := client.CoordinationV1().Leases("simkube").Get(context.TODO(), "sk-cloudprov", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "sk-cloudprov-1", *lease.Spec.HolderIdentity)
+}
+
+func TestLeaderElectNoNamespace(t *testing.T) {
+	t.Setenv(k8s.PodNamespaceEnvKey, "")
+
+	l := newLeader(health.NewServer(), false)
+	err := l.elect(
+		context.TODO(),
+		fake.NewSimpleClientset(),
+		LeaderElectionOptions{Enabled: true},
+		func(context.Context) {},
+		func() {},
+	)
+	assert.NotNil(t, err)
+	assert.False(t, l.isLeader.Load())
+}
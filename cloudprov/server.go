@@ -1,37 +1,279 @@
 package cloudprov
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
 
 	"simkube/lib/go/cloudprov"
+	"simkube/lib/go/custommetrics"
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/metricsapi"
+	"simkube/lib/go/util"
 )
 
-const (
-	address = ":8086"
-)
+type Options struct {
+	ListenAddr           string
+	AppLabel             string
+	NodeGroupResources   []string
+	WatchNamespaces      []string
+	FleetsFile           string
+	NodeGroupsConfigFile string
+	MaxNodeGroupSize     int32
+	PriceTableFile       string
+	NodeSkeletonPath     string
+	GPULabel             string
+	GPUTypes             []string
+	KarpenterConfigFile  string
+
+	InstanceCreationTimeout time.Duration
+	ProvisioningDelay       time.Duration
+	ProvisioningDelayMax    time.Duration
+	CleanupPolicy           string
+	PriorityExpanderNS      string
+	ClientQPS               float32
+	ClientBurst             int
+	ClientTimeout           time.Duration
+	LogRPC                  bool
+	LogSampleRate           int
+	Reflection              bool
+	TLS                     TLSOptions
+	Connection              ConnectionOptions
+	LeaderElection          LeaderElectionOptions
+	MetricsAPI              MetricsAPIOptions
+	CustomMetricsAPIAddr    string
+}
+
+// MetricsAPIOptions configures the resource metrics API for the virtual nodes; if Addr is
+// empty, it isn't served.  Containers use the given fractions of their CPU and memory requests.
+type MetricsAPIOptions struct {
+	Addr              string
+	CPUUtilization    float64
+	MemoryUtilization float64
+}
+
+func (self Options) clientOptions() k8s.ClientOptions {
+	return k8s.ClientOptions{
+		QPS:     self.ClientQPS,
+		Burst:   self.ClientBurst,
+		Timeout: self.ClientTimeout,
+	}
+}
+
+func Run(opts Options) {
+	serverOpts, err := opts.TLS.serverOptions()
+	if err != nil {
+		log.Fatalf("could not configure TLS: %s", err)
+	}
+	if !opts.TLS.enabled() {
+		log.Warn("TLS is not configured, the gRPC server is listening in plaintext")
+	}
+
+	connOpts, err := opts.Connection.serverOptions()
+	if err != nil {
+		log.Fatalf("could not configure gRPC connections: %s", err)
+	}
+	serverOpts = append(serverOpts, connOpts...)
 
-func Run(appLabel string) {
-	srv := grpc.NewServer()
+	// Without leader election, this is the only replica, so it's always the leader
+	healthServer := health.NewServer()
+	l := newLeader(healthServer, !opts.LeaderElection.Enabled)
+	serverOpts = append(serverOpts, grpc.ChainUnaryInterceptor(
+		loggingInterceptor(opts.LogRPC, util.NewLogSampler(opts.LogSampleRate)),
+		errorStatusInterceptor(),
+		l.interceptor(),
+	))
+	srv := grpc.NewServer(serverOpts...)
 
-	//nolint:gosec // this is fine.jpg
-	lis, err := net.Listen("tcp", address)
+	lis, err := net.Listen("tcp", opts.ListenAddr)
 	if err != nil {
 		log.Fatalf("failed to listen: %s", err)
 	}
 
-	cp, err := cloudprov.New(fmt.Sprintf("app=%s", appLabel))
+	var priceTable *cloudprov.PriceTable
+	if opts.PriceTableFile != "" {
+		if priceTable, err = cloudprov.LoadPriceTable(opts.PriceTableFile); err != nil {
+			log.Fatalf("could not load price table: %s", err)
+		}
+	}
+
+	var fleets []cloudprov.Fleet
+	if opts.FleetsFile != "" {
+		if fleets, err = cloudprov.LoadFleets(opts.FleetsFile); err != nil {
+			log.Fatalf("could not load fleets: %s", err)
+		}
+	}
+
+	var nodeGroupConfig *cloudprov.NodeGroupConfig
+	if opts.NodeGroupsConfigFile != "" {
+		if nodeGroupConfig, err = cloudprov.LoadNodeGroupConfig(opts.NodeGroupsConfigFile); err != nil {
+			log.Fatalf("could not load node group config: %s", err)
+		}
+	}
+
+	nodeGroupResources := make([]schema.GroupVersionResource, len(opts.NodeGroupResources))
+	for i, resource := range opts.NodeGroupResources {
+		if nodeGroupResources[i], err = k8s.ParseGroupVersionResource(resource); err != nil {
+			log.Fatalf("could not parse node group resources: %s", err)
+		}
+	}
+
+	cp, err := cloudprov.New(
+		fmt.Sprintf("app=%s", opts.AppLabel),
+		cloudprov.Options{
+			MaxNodeGroupSize:   opts.MaxNodeGroupSize,
+			PriceTable:         priceTable,
+			GPULabel:           opts.GPULabel,
+			GPUTypes:           opts.GPUTypes,
+			NodeGroupResources: nodeGroupResources,
+			WatchNamespaces:    opts.WatchNamespaces,
+			Fleets:             fleets,
+			NodeGroupConfig:    nodeGroupConfig,
+			NodeSkeletonPath:   opts.NodeSkeletonPath,
+
+			InstanceCreationTimeout:   opts.InstanceCreationTimeout,
+			ProvisioningDelay:         opts.ProvisioningDelay,
+			ProvisioningDelayMax:      opts.ProvisioningDelayMax,
+			CleanupPolicy:             opts.CleanupPolicy,
+			PriorityExpanderNamespace: opts.PriorityExpanderNS,
+			Client:                    opts.clientOptions(),
+			LogSampleRate:             opts.LogSampleRate,
+		},
+	)
 	if err != nil {
 		log.Fatalf("could not create cloud provider: %s", err)
 	}
 
+	if err := cp.Start(context.Background()); err != nil {
+		log.Fatalf("could not start cloud provider: %s", err)
+	}
+
+	// The metrics APIs are read-only, so every replica serves them, leader or not
+	if opts.MetricsAPI.Addr != "" {
+		runMetricsAPI(opts)
+	}
+	if opts.CustomMetricsAPIAddr != "" {
+		runCustomMetricsAPI(opts)
+	}
+
+	// The NodeClaim controller launches virtual nodes, and the managed node groups are created
+	// (and deleted) from the config, so (like scaling node groups) only the leader can do either
+	var nodeClaimCtrl *cloudprov.NodeClaimController
+	if opts.KarpenterConfigFile != "" {
+		karpenterConfig, err := cloudprov.LoadKarpenterConfig(opts.KarpenterConfigFile)
+		if err != nil {
+			log.Fatalf("could not load Karpenter config: %s", err)
+		}
+		if nodeClaimCtrl, err = cloudprov.NewNodeClaimController(karpenterConfig, opts.clientOptions()); err != nil {
+			log.Fatalf("could not create NodeClaim controller: %s", err)
+		}
+	}
+	onStartedLeading := func(ctx context.Context) {
+		if err := cp.ApplyManagedNodeGroups(ctx); err != nil {
+			log.Fatalf("could not apply managed node groups: %s", err)
+		}
+		if nodeClaimCtrl == nil {
+			return
+		}
+		if err := nodeClaimCtrl.Run(ctx); err != nil {
+			log.Fatalf("could not start NodeClaim controller: %s", err)
+		}
+	}
+
+	if !opts.LeaderElection.Enabled {
+		onStartedLeading(context.Background())
+	} else {
+		client, err := k8s.NewClient(opts.clientOptions())
+		if err != nil {
+			log.Fatalf("could not create leader election client: %s", err)
+		}
+
+		onStoppedLeading := func() { log.Fatal("lost the leader election lease, exiting") }
+		if err := l.elect(
+			context.Background(),
+			client,
+			opts.LeaderElection,
+			onStartedLeading,
+			onStoppedLeading,
+		); err != nil {
+			log.Fatalf("could not start leader election: %s", err)
+		}
+	}
+
 	// serve
-	protos.RegisterCloudProviderServer(srv, cp)
+	registerServices(srv, cp, healthServer, opts.Reflection)
 	if err := srv.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
 }
+
+func runMetricsAPI(opts Options) {
+	client, err := k8s.NewClient(opts.clientOptions())
+	if err != nil {
+		log.Fatalf("could not initialize Kubernetes client: %s", err)
+	}
+	tlsConfig, err := opts.TLS.metricsAPIConfig(context.Background(), client)
+	if err != nil {
+		log.Fatalf("could not configure TLS for the resource metrics API: %s", err)
+	}
+
+	srv, err := metricsapi.NewServer(
+		metricsapi.UsageModel{
+			CPUUtilization:    opts.MetricsAPI.CPUUtilization,
+			MemoryUtilization: opts.MetricsAPI.MemoryUtilization,
+		},
+		opts.clientOptions(),
+	)
+	if err != nil {
+		log.Fatalf("could not create resource metrics API server: %s", err)
+	}
+	if err := srv.Start(context.Background()); err != nil {
+		log.Fatalf("could not start resource metrics API server: %s", err)
+	}
+	srv.Run(context.Background(), opts.MetricsAPI.Addr, tlsConfig)
+}
+
+func runCustomMetricsAPI(opts Options) {
+	client, err := k8s.NewClient(opts.clientOptions())
+	if err != nil {
+		log.Fatalf("could not initialize Kubernetes client: %s", err)
+	}
+	tlsConfig, err := opts.TLS.metricsAPIConfig(context.Background(), client)
+	if err != nil {
+		log.Fatalf("could not configure TLS for the custom metrics APIs: %s", err)
+	}
+
+	srv, err := custommetrics.NewServer(opts.clientOptions())
+	if err != nil {
+		log.Fatalf("could not create custom metrics API server: %s", err)
+	}
+	if err := srv.Start(context.Background()); err != nil {
+		log.Fatalf("could not start custom metrics API server: %s", err)
+	}
+	srv.Run(context.Background(), opts.CustomMetricsAPIAddr, tlsConfig)
+}
+
+// The reflection service lets debugging tools (e.g., grpcurl) list and call the cloud provider
+// methods without having the externalgrpc protos; it's a streaming service, so it isn't subject
+// to the leader election interceptor and works on standby replicas too
+func registerServices(
+	srv *grpc.Server,
+	cp protos.CloudProviderServer,
+	healthServer healthpb.HealthServer,
+	enableReflection bool,
+) {
+	protos.RegisterCloudProviderServer(srv, cp)
+	healthpb.RegisterHealthServer(srv, healthServer)
+	if enableReflection {
+		reflection.Register(srv)
+	}
+}
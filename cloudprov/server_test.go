@@ -0,0 +1,45 @@
+package cloudprov
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+)
+
+func TestRegisterServices(t *testing.T) {
+	cases := map[string]struct {
+		reflection       bool
+		expectedServices []string
+	}{
+		"default": {
+			expectedServices: []string{
+				"clusterautoscaler.cloudprovider.v1.externalgrpc.CloudProvider",
+				"grpc.health.v1.Health",
+			},
+		},
+		"reflection": {
+			reflection: true,
+			expectedServices: []string{
+				"clusterautoscaler.cloudprovider.v1.externalgrpc.CloudProvider",
+				"grpc.health.v1.Health",
+				"grpc.reflection.v1alpha.ServerReflection",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := grpc.NewServer()
+			registerServices(srv, &protos.UnimplementedCloudProviderServer{}, health.NewServer(), tc.reflection)
+
+			services := []string{}
+			for service := range srv.GetServiceInfo() {
+				services = append(services, service)
+			}
+			assert.ElementsMatch(t, tc.expectedServices, services)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package cloudprov
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// The API server publishes the CA (and allowed names) for the front proxy client certificate
+// that it uses when proxying requests to aggregated APIs in this ConfigMap
+const (
+	authConfigMapNamespace       = "kube-system"
+	authConfigMapName            = "extension-apiserver-authentication"
+	requestHeaderCAKey           = "requestheader-client-ca-file"
+	requestHeaderAllowedNamesKey = "requestheader-allowed-names"
+)
+
+// TLSOptions configures TLS on the gRPC server; if CertFile and KeyFile are both empty,
+// the server listens in plaintext.  If ClientCAFile is set, clients must present a
+// certificate signed by one of the CAs in the file (i.e., mutual TLS).
+type TLSOptions struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+func (self *TLSOptions) enabled() bool {
+	return self.CertFile != "" || self.KeyFile != ""
+}
+
+func (self *TLSOptions) serverOptions() ([]grpc.ServerOption, error) {
+	if !self.enabled() {
+		if self.ClientCAFile != "" {
+			return nil, errors.New("a client CA requires a server certificate and key")
+		}
+		return nil, nil
+	}
+
+	config, err := self.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	return []grpc.ServerOption{grpc.Creds(credentials.NewTLS(config))}, nil
+}
+
+func (self *TLSOptions) tlsConfig() (*tls.Config, error) {
+	if self.CertFile == "" || self.KeyFile == "" {
+		return nil, errors.New("both a server certificate and key are required for TLS")
+	}
+
+	cert, err := tls.LoadX509KeyPair(self.CertFile, self.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load server certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if self.ClientCAFile != "" {
+		caBytes, err := os.ReadFile(self.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not open %s: %w", self.ClientCAFile, err)
+		}
+
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certificates found in %s", self.ClientCAFile)
+		}
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// The metrics APIs have to be served over TLS, with the same certificate as the gRPC
+// server.  The aggregated API server authenticates with its own (front proxy) client
+// certificate, which isn't signed by the gRPC client CA; it has already authorized the
+// request against the caller's RBAC rules before proxying it, so the metrics APIs only
+// accept clients with a certificate signed by the request header CA (and, if the cluster
+// restricts them, one of the allowed common names) from the extension-apiserver-authentication
+// ConfigMap
+func (self *TLSOptions) metricsAPIConfig(ctx context.Context, k8sClient kubernetes.Interface) (*tls.Config, error) {
+	if !self.enabled() {
+		return nil, errors.New("a server certificate and key are required")
+	}
+
+	config, err := self.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	cm, err := k8sClient.CoreV1().ConfigMaps(authConfigMapNamespace).Get(ctx, authConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not get %s/%s: %w", authConfigMapNamespace, authConfigMapName, err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM([]byte(cm.Data[requestHeaderCAKey])) {
+		return nil, fmt.Errorf("no certificates found in %s of %s/%s",
+			requestHeaderCAKey, authConfigMapNamespace, authConfigMapName)
+	}
+
+	var allowedNames []string
+	if names := cm.Data[requestHeaderAllowedNamesKey]; names != "" {
+		if err := json.Unmarshal([]byte(names), &allowedNames); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", requestHeaderAllowedNamesKey, err)
+		}
+	}
+
+	config.ClientCAs = clientCAs
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	config.VerifyPeerCertificate = verifyAllowedNames(allowedNames)
+	return config, nil
+}
+
+// An empty list of allowed names means any certificate signed by the request header CA is
+// accepted, which is how the API server itself treats it
+func verifyAllowedNames(allowedNames []string) func([][]byte, [][]*x509.Certificate) error {
+	return func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(allowedNames) == 0 {
+			return nil
+		}
+
+		for _, chain := range verifiedChains {
+			if len(chain) == 0 {
+				continue
+			}
+			for _, name := range allowedNames {
+				if chain[0].Subject.CommonName == name {
+					return nil
+				}
+			}
+		}
+		return errors.New("client certificate common name is not in " + requestHeaderAllowedNamesKey)
+	}
+}
@@ -0,0 +1,197 @@
+package cloudprov
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// writeTestCert writes a self-signed certificate and its key to dir, and returns their paths
+func writeTestCert(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "sk-cloudprov"},
+		DNSNames:              []string{"sk-cloudprov"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		panic(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		panic(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		panic(err)
+	}
+	return certFile, keyFile
+}
+
+func TestServerOptions(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir)
+	emptyFile := filepath.Join(dir, "empty.crt")
+	if err := os.WriteFile(emptyFile, []byte{}, 0600); err != nil {
+		panic(err)
+	}
+
+	cases := map[string]struct {
+		opts              TLSOptions
+		expectedNumOpts   int
+		expectErr         bool
+		expectedAuthLevel tls.ClientAuthType
+	}{
+		"plaintext":      {},
+		"client CA only": {opts: TLSOptions{ClientCAFile: certFile}, expectErr: true},
+		"missing key":    {opts: TLSOptions{CertFile: certFile}, expectErr: true},
+		"missing cert":   {opts: TLSOptions{KeyFile: keyFile}, expectErr: true},
+		"bad cert":       {opts: TLSOptions{CertFile: emptyFile, KeyFile: keyFile}, expectErr: true},
+		"server TLS":     {opts: TLSOptions{CertFile: certFile, KeyFile: keyFile}, expectedNumOpts: 1},
+		"missing client CA": {
+			opts:      TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: "/does/not/exist"},
+			expectErr: true,
+		},
+		"empty client CA": {
+			opts:      TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: emptyFile},
+			expectErr: true,
+		},
+		"mutual TLS": {
+			opts:              TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile},
+			expectedNumOpts:   1,
+			expectedAuthLevel: tls.RequireAndVerifyClientCert,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			serverOpts, err := tc.opts.serverOptions()
+			if tc.expectErr {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Len(t, serverOpts, tc.expectedNumOpts)
+			if tc.opts.enabled() {
+				config, err := tc.opts.tlsConfig()
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expectedAuthLevel, config.ClientAuth)
+			}
+		})
+	}
+}
+
+func TestMetricsAPIConfig(t *testing.T) {
+	certFile, keyFile := writeTestCert(t, t.TempDir())
+	caPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		panic(err)
+	}
+	opts := TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile}
+
+	cases := map[string]struct {
+		opts      TLSOptions
+		data      map[string]string
+		expectErr bool
+	}{
+		"no server certificate": {data: map[string]string{requestHeaderCAKey: string(caPEM)}, expectErr: true},
+		"no ConfigMap":          {opts: opts, expectErr: true},
+		"no request header CA":  {opts: opts, data: map[string]string{}, expectErr: true},
+		"bad allowed names": {
+			opts:      opts,
+			data:      map[string]string{requestHeaderCAKey: string(caPEM), requestHeaderAllowedNamesKey: "front-proxy"},
+			expectErr: true,
+		},
+		"request header CA": {
+			opts: opts,
+			data: map[string]string{requestHeaderCAKey: string(caPEM), requestHeaderAllowedNamesKey: `["front-proxy"]`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			if tc.data != nil {
+				_, err := client.CoreV1().ConfigMaps(authConfigMapNamespace).Create(
+					context.Background(),
+					&corev1.ConfigMap{
+						ObjectMeta: metav1.ObjectMeta{Namespace: authConfigMapNamespace, Name: authConfigMapName},
+						Data:       tc.data,
+					},
+					metav1.CreateOptions{},
+				)
+				if err != nil {
+					panic(err)
+				}
+			}
+
+			config, err := tc.opts.metricsAPIConfig(context.Background(), client)
+			if tc.expectErr {
+				assert.NotNil(t, err)
+				return
+			}
+
+			// The front proxy certificate is verified against the request header CA, not the gRPC client CA
+			assert.Nil(t, err)
+			assert.Len(t, config.Certificates, 1)
+			assert.Equal(t, tls.RequireAndVerifyClientCert, config.ClientAuth)
+			assert.NotNil(t, config.ClientCAs)
+			assert.NotNil(t, config.VerifyPeerCertificate)
+		})
+	}
+}
+
+func TestVerifyAllowedNames(t *testing.T) {
+	chains := [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "front-proxy"}}}}
+
+	cases := map[string]struct {
+		allowedNames []string
+		expectErr    bool
+	}{
+		"any name":     {},
+		"allowed name": {allowedNames: []string{"aggregator", "front-proxy"}},
+		"other name":   {allowedNames: []string{"aggregator"}, expectErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := verifyAllowedNames(tc.allowedNames)(nil, chains)
+			assert.Equal(t, tc.expectErr, err != nil)
+		})
+	}
+}
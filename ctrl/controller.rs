@@ -2,10 +2,18 @@ use std::ops::Deref;
 use std::sync::Arc;
 
 use anyhow::bail;
+use chrono::SecondsFormat;
 use k8s_openapi::api::admissionregistration::v1 as admissionv1;
 use k8s_openapi::api::batch::v1 as batchv1;
+use kube::api::Patch;
 use kube::runtime::controller::Action;
 use kube::ResourceExt;
+use serde_json::json;
+use simkube::api::v1::{
+    SimulationStatusConditions,
+    SimulationStatusConditionsStatus,
+    SimulationStatusPhase,
+};
 use simkube::errors::*;
 use simkube::k8s::label_selector;
 use simkube::prelude::*;
@@ -16,6 +24,10 @@ use super::*;
 const REQUEUE_DURATION: Duration = Duration::from_secs(5);
 const REQUEUE_ERROR_DURATION: Duration = Duration::from_secs(300);
 
+// These have to match the condition types in lib/go/api/v1/simulation_types.go
+const DRIVER_CREATED_CONDITION: &str = "DriverCreated";
+const FINISHED_CONDITION: &str = "Finished";
+
 async fn do_global_setup(ctx: &SimulationContext, sim: &Simulation) -> anyhow::Result<SimulationRoot> {
     info!("performing global setup");
 
@@ -68,6 +80,7 @@ async fn setup_driver(ctx: &SimulationContext, sim: &Simulation, root: &Simulati
     let driver_cert_secret_name = match secrets.items.len() {
         0 => {
             info!("waiting for secret to be created");
+            update_status(ctx, sim, None).await?;
             return Ok(Action::requeue(REQUEUE_DURATION));
         },
         x if x > 1 => bail!("found multiple secrets for experiment"),
@@ -76,16 +89,107 @@ async fn setup_driver(ctx: &SimulationContext, sim: &Simulation, root: &Simulati
 
     // TODO should check if there are any other simulations running and block/wait until
     // they're done before proceeding
-    let driver = jobs_api.get_opt(&ctx.driver_name).await?;
-    if driver.is_none() {
-        info!("creating driver job {}", ctx.driver_name);
-        let obj = build_driver_job(ctx, sim, &driver_cert_secret_name, &sim.spec.trace)?;
-        jobs_api.create(&Default::default(), &obj).await?;
-    }
+    let driver = match jobs_api.get_opt(&ctx.driver_name).await? {
+        None => {
+            info!("creating driver job {}", ctx.driver_name);
+            let obj = build_driver_job(ctx, sim, &driver_cert_secret_name, &sim.spec.trace)?;
+            jobs_api.create(&Default::default(), &obj).await?
+        },
+        Some(job) => job,
+    };
+    update_status(ctx, sim, Some(&driver)).await?;
 
+    // Changes to the driver job trigger another reconcile (see main.rs), so we don't need to
+    // poll for it to finish
     Ok(Action::await_change())
 }
 
+async fn update_status(ctx: &SimulationContext, sim: &Simulation, driver: Option<&batchv1::Job>) -> EmptyResult {
+    let sim_api = kube::Api::<Simulation>::all(ctx.client.clone());
+    let now = metav1::Time(chrono::Utc::now());
+    let status = build_status(sim, driver, &now);
+
+    // This is a merge patch, and the progress fields are left unset, so we don't overwrite the
+    // progress that the driver reports
+    sim_api
+        .patch_status(&ctx.name, &Default::default(), &Patch::Merge(json!({ "status": status })))
+        .await?;
+    Ok(())
+}
+
+// The phase and the conditions are based on the status of the driver job; how far the driver
+// has gotten through the trace is reported by the driver itself
+fn build_status(sim: &Simulation, driver: Option<&batchv1::Job>, now: &metav1::Time) -> SimulationStatus {
+    let mut conditions = sim.status.as_ref().and_then(|s| s.conditions.clone()).unwrap_or_default();
+    let job_status = driver.and_then(|job| job.status.clone()).unwrap_or_default();
+
+    if driver.is_some() {
+        set_condition(&mut conditions, DRIVER_CREATED_CONDITION, "JobCreated", "", now);
+    }
+
+    let finished = job_status
+        .conditions
+        .unwrap_or_default()
+        .into_iter()
+        .find(|c| c.status == "True" && (c.type_ == "Complete" || c.type_ == "Failed"));
+    let phase = match &finished {
+        Some(c) if c.type_ == "Complete" => SimulationStatusPhase::Succeeded,
+        Some(_) => SimulationStatusPhase::Failed,
+        None if job_status.start_time.is_some() => SimulationStatusPhase::Running,
+        None => SimulationStatusPhase::Pending,
+    };
+
+    let mut completion_time = None;
+    if let Some(c) = finished {
+        let reason = if c.type_ == "Complete" { "Succeeded" } else { "Failed" };
+        set_condition(&mut conditions, FINISHED_CONDITION, reason, &c.message.unwrap_or_default(), now);
+        completion_time = c.last_transition_time.or(job_status.completion_time);
+    }
+
+    SimulationStatus {
+        completion_time: completion_time.as_ref().map(format_time),
+        conditions: if conditions.is_empty() { None } else { Some(conditions) },
+        events_replayed: None,
+        phase: Some(phase),
+        results: None,
+        start_time: job_status.start_time.as_ref().map(format_time),
+        total_events: None,
+    }
+}
+
+// Like SetStatusCondition in apimachinery, the transition time is only updated when the
+// condition's status changes; all of the simulation's conditions are only ever set to true
+fn set_condition(
+    conditions: &mut Vec<SimulationStatusConditions>,
+    type_: &str,
+    reason: &str,
+    message: &str,
+    now: &metav1::Time,
+) {
+    match conditions.iter_mut().find(|c| c.r#type == type_) {
+        Some(c) => {
+            if !matches!(c.status, SimulationStatusConditionsStatus::True) {
+                c.status = SimulationStatusConditionsStatus::True;
+                c.last_transition_time = format_time(now);
+            }
+            c.reason = reason.into();
+            c.message = message.into();
+        },
+        None => conditions.push(SimulationStatusConditions {
+            last_transition_time: format_time(now),
+            message: message.into(),
+            observed_generation: None,
+            reason: reason.into(),
+            status: SimulationStatusConditionsStatus::True,
+            r#type: type_.into(),
+        }),
+    }
+}
+
+fn format_time(t: &metav1::Time) -> String {
+    t.0.to_rfc3339_opts(SecondsFormat::Secs, true)
+}
+
 #[instrument(parent=None, skip_all, fields(simulation=sim.name_any()))]
 pub(crate) async fn reconcile(sim: Arc<Simulation>, ctx: Arc<SimulationContext>) -> Result<Action, AnyhowError> {
     let sim = sim.deref();
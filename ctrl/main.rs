@@ -11,7 +11,10 @@ use futures::{
     future,
     StreamExt,
 };
+use k8s_openapi::api::batch::v1 as batchv1;
 use kube::runtime::controller::Controller;
+use kube::runtime::reflector::ObjectRef;
+use kube::runtime::watcher;
 use kube::ResourceExt;
 use simkube::prelude::*;
 use thiserror::Error;
@@ -103,8 +106,16 @@ impl SimulationContext {
 async fn run(opts: Options) -> EmptyResult {
     let client = kube::Client::try_default().await?;
     let sim_api = kube::Api::<Simulation>::all(client.clone());
+    let jobs_api = kube::Api::<batchv1::Job>::all(client.clone());
 
+    // The driver jobs are labeled with the name of their simulation, so that the simulation's
+    // status gets updated when the driver starts and finishes; the simulated objects have the
+    // same label, but they're also labeled as virtual, so we skip those.
+    let driver_selector = format!("{SIMULATION_LABEL_KEY},!{VIRTUAL_LABEL_KEY}");
     let ctrl = Controller::new(sim_api, Default::default())
+        .watches(jobs_api, watcher::Config::default().labels(&driver_selector), |job| {
+            job.labels().get(SIMULATION_LABEL_KEY).map(|name| ObjectRef::<Simulation>::new(name))
+        })
         .run(reconcile, error_policy, Arc::new(SimulationContext::new(client, opts)))
         .for_each(|_| future::ready(()));
 
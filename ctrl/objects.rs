@@ -13,7 +13,10 @@ use simkube::prelude::*;
 use simkube::store::storage;
 
 use super::cert_manager::DRIVER_CERT_NAME;
-use super::trace::get_local_trace_volume;
+use super::trace::{
+    get_local_results_volume,
+    get_local_trace_volume,
+};
 use crate::SimulationContext;
 
 const WEBHOOK_NAME: &str = "mutatepods.simkube.io";
@@ -100,6 +103,12 @@ pub(super) fn build_driver_job(
         storage::Scheme::AmazonS3 => todo!(),
         storage::Scheme::Local => get_local_trace_volume(&trace_url)?,
     };
+
+    // The results go wherever the trace came from
+    let (results_vm, results_volume, results_mount_path, results_location) = match storage::get_scheme(&trace_url)? {
+        storage::Scheme::AmazonS3 => todo!(),
+        storage::Scheme::Local => get_local_results_volume(&trace_url, &ctx.name)?,
+    };
     let (cert_vm, cert_volume, cert_mount_path) = build_certificate_volumes(cert_secret_name);
 
     let service_account = Some(env::var("POD_SVC_ACCOUNT")?);
@@ -113,18 +122,25 @@ pub(super) fn build_driver_job(
                     containers: vec![corev1::Container {
                         name: "driver".into(),
                         command: Some(vec!["/sk-driver".into()]),
-                        args: Some(build_driver_args(ctx, cert_mount_path, trace_mount_path)),
+                        args: Some(build_driver_args(
+                            ctx,
+                            owner,
+                            cert_mount_path,
+                            trace_mount_path,
+                            results_mount_path,
+                            results_location,
+                        )),
                         image: Some(ctx.opts.driver_image.clone()),
                         env: Some(vec![corev1::EnvVar {
                             name: "RUST_BACKTRACE".into(),
                             value: Some("1".into()),
                             ..Default::default()
                         }]),
-                        volume_mounts: Some(vec![trace_vm, cert_vm]),
+                        volume_mounts: Some(vec![trace_vm, results_vm, cert_vm]),
                         ..Default::default()
                     }],
                     restart_policy: Some("Never".into()),
-                    volumes: Some(vec![trace_volume, cert_volume]),
+                    volumes: Some(vec![trace_volume, results_volume, cert_volume]),
                     service_account,
                     ..Default::default()
                 }),
@@ -136,8 +152,15 @@ pub(super) fn build_driver_job(
     })
 }
 
-fn build_driver_args(ctx: &SimulationContext, cert_mount_path: String, trace_mount_path: String) -> Vec<String> {
-    vec![
+fn build_driver_args(
+    ctx: &SimulationContext,
+    owner: &Simulation,
+    cert_mount_path: String,
+    trace_mount_path: String,
+    results_mount_path: String,
+    results_location: String,
+) -> Vec<String> {
+    let mut args = vec![
         "--cert-path".into(),
         format!("{cert_mount_path}/tls.crt"),
         "--key-path".into(),
@@ -150,9 +173,47 @@ fn build_driver_args(ctx: &SimulationContext, cert_mount_path: String, trace_mou
         ctx.root.clone(),
         "--sim-name".into(),
         ctx.name.clone(),
+        "--results-dir".into(),
+        results_mount_path,
+        "--results-location".into(),
+        results_location,
         "--verbosity".into(),
         ctx.opts.verbosity.clone(),
-    ]
+    ];
+
+    if let Some(speed) = owner.spec.speed {
+        args.extend(["--speed".into(), speed.to_string()]);
+    }
+    if let Some(max_duration_seconds) = owner.spec.max_duration_seconds {
+        args.extend(["--max-duration-seconds".into(), max_duration_seconds.to_string()]);
+    }
+    if let Some(repetitions) = owner.spec.repetitions {
+        args.extend(["--repetitions".into(), repetitions.to_string()]);
+    }
+    if let Some(seed) = owner.spec.seed {
+        args.extend(["--seed".into(), seed.to_string()]);
+    }
+    for (from, to) in owner.spec.namespace_map.iter().flatten() {
+        args.extend(["--namespace-map".into(), format!("{from}={to}")]);
+    }
+    if let Some(scale_factor) = owner.spec.scale_factor {
+        args.extend(["--scale-factor".into(), scale_factor.to_string()]);
+    }
+    if let Some(metrics) = &owner.spec.metrics {
+        args.extend([
+            "--prometheus-url".into(),
+            metrics.prometheus_url.clone(),
+            "--results-namespace".into(),
+            ctx.driver_ns.clone(),
+        ]);
+        if let Some(interval_seconds) = metrics.interval_seconds {
+            args.extend(["--metrics-interval-seconds".into(), interval_seconds.to_string()]);
+        }
+        for q in metrics.queries.iter().flatten() {
+            args.extend(["--metrics-query".into(), format!("{}={}", q.name, q.query)]);
+        }
+    }
+    args
 }
 
 fn build_certificate_volumes(cert_secret_name: &str) -> (corev1::VolumeMount, corev1::Volume, String) {
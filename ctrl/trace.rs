@@ -6,6 +6,8 @@ use simkube::prelude::*;
 
 const TRACE_VOLUME_NAME: &str = "trace-data";
 const TRACE_PATH: &str = "/trace-data";
+const RESULTS_VOLUME_NAME: &str = "results-data";
+const RESULTS_PATH: &str = "/results-data";
 
 pub(super) fn get_local_trace_volume(path: &Url) -> anyhow::Result<(corev1::VolumeMount, corev1::Volume, String)> {
     let fp = path
@@ -38,3 +40,44 @@ pub(super) fn get_local_trace_volume(path: &Url) -> anyhow::Result<(corev1::Volu
         mount_path_str.into(),
     ))
 }
+
+// The results are written to a directory next to the trace (on the same host), so that they're
+// stored in the same place; the location that's returned is what the driver records in the
+// simulation status
+pub(super) fn get_local_results_volume(
+    path: &Url,
+    sim_name: &str,
+) -> anyhow::Result<(corev1::VolumeMount, corev1::Volume, String, String)> {
+    let fp = path
+        .to_file_path()
+        .map_err(|_| anyhow!("could not parse trace path: {}", path))?;
+    let mut host_path = fp
+        .parent()
+        .ok_or(anyhow!("trace path has no parent directory: {}", fp.display()))?
+        .to_path_buf();
+    host_path.push(format!("sk-{sim_name}-results"));
+
+    let host_path_str = host_path
+        .into_os_string()
+        .into_string()
+        .map_err(|osstr| anyhow!("could not parse host path: {:?}", osstr))?;
+    let location = format!("file://{host_path_str}");
+
+    Ok((
+        corev1::VolumeMount {
+            name: RESULTS_VOLUME_NAME.into(),
+            mount_path: RESULTS_PATH.into(),
+            ..Default::default()
+        },
+        corev1::Volume {
+            name: RESULTS_VOLUME_NAME.into(),
+            host_path: Some(corev1::HostPathVolumeSource {
+                path: host_path_str,
+                type_: Some("DirectoryOrCreate".into()),
+            }),
+            ..Default::default()
+        },
+        RESULTS_PATH.into(),
+        location,
+    ))
+}
@@ -1,6 +1,9 @@
+mod metrics;
 mod mutation;
+mod results;
 mod runner;
 
+use std::collections::HashMap;
 use std::fs;
 use std::net::{
     IpAddr,
@@ -9,7 +12,11 @@ use std::net::{
 use std::sync::Arc;
 use std::time::Duration;
 
-use anyhow::anyhow;
+use anyhow::{
+    anyhow,
+    ensure,
+};
+use chrono::Utc;
 use clap::Parser;
 use rocket::config::TlsConfig;
 use simkube::k8s::{
@@ -25,7 +32,9 @@ use tokio::sync::Mutex;
 use tokio::time::sleep;
 use tracing::*;
 
+use crate::metrics::MetricsCollector;
 use crate::mutation::MutationData;
+use crate::results::write_results;
 use crate::runner::TraceRunner;
 
 #[derive(Clone, Debug, Parser)]
@@ -51,6 +60,59 @@ struct Options {
     #[arg(long)]
     trace_path: String,
 
+    // How much faster than real time to replay the trace; the pod lifetimes are scaled by the same
+    // amount, so a 24-hour trace with a speed of 12 is replayed in 2 hours
+    #[arg(long, default_value_t = 1.0)]
+    speed: f64,
+
+    // Stop the simulation after this many seconds, even if the trace isn't finished
+    #[arg(long)]
+    max_duration_seconds: Option<u64>,
+
+    // How many times to replay the trace; each repetition starts from a clean slate
+    #[arg(long, default_value_t = 1)]
+    repetitions: u32,
+
+    // The driver doesn't make any random choices yet, but we log the seed so that it's recorded
+    // along with the rest of the simulation's output
+    #[arg(long)]
+    seed: Option<i64>,
+
+    // Replay a namespace from the trace into another namespace instead of a virtual namespace, as
+    // from=to (can be repeated)
+    #[arg(long)]
+    namespace_map: Vec<String>,
+
+    // Multiply the replicas of the objects in the trace (and for jobs, their parallelism and
+    // completions) by this much, e.g., 2 to replay the trace at twice the load
+    #[arg(long, default_value_t = 1.0)]
+    scale_factor: f64,
+
+    // Collect metrics from this Prometheus server while the simulation is running; the results are
+    // saved in a ConfigMap in the --results-namespace
+    #[arg(long)]
+    prometheus_url: Option<String>,
+
+    #[arg(long, default_value_t = 15)]
+    metrics_interval_seconds: u64,
+
+    // PromQL queries to collect, as name=query (can be repeated); by default, a few scheduling
+    // metrics are collected
+    #[arg(long)]
+    metrics_query: Vec<String>,
+
+    #[arg(long)]
+    results_namespace: Option<String>,
+
+    // Write the results summary (and the metrics) to this directory when the simulation is over;
+    // --results-location is where the directory can be found outside of the driver, and is
+    // recorded in the simulation status (by default, file://<results-dir>)
+    #[arg(long)]
+    results_dir: Option<String>,
+
+    #[arg(long)]
+    results_location: Option<String>,
+
     #[arg(short, long, default_value = "info")]
     verbosity: String,
 }
@@ -60,12 +122,59 @@ pub struct DriverContext {
     name: String,
     sim_root: String,
     virtual_ns_prefix: String,
+    speed: f64,
+    repetitions: u32,
+    max_duration: Option<Duration>,
+    namespace_map: HashMap<String, String>,
+    scale_factor: f64,
     owners_cache: Arc<Mutex<OwnersCache>>,
     store: Arc<dyn TraceStorable + Send + Sync>,
 }
 
+impl DriverContext {
+    // The namespace that objects from original_ns in the trace are replayed into
+    fn virtual_ns(&self, original_ns: &str) -> String {
+        match self.namespace_map.get(original_ns) {
+            Some(ns) => ns.clone(),
+            None => format!("{}-{}", self.virtual_ns_prefix, original_ns),
+        }
+    }
+}
+
 #[instrument(ret, err)]
 async fn run(opts: Options) -> EmptyResult {
+    ensure!(opts.speed > 0.0, "speed must be positive: {}", opts.speed);
+    ensure!(opts.scale_factor > 0.0, "scale factor must be positive: {}", opts.scale_factor);
+    ensure!(opts.repetitions > 0, "repetitions must be at least 1: {}", opts.repetitions);
+    ensure!(opts.max_duration_seconds != Some(0), "max duration must be at least 1 second");
+    ensure!(opts.metrics_interval_seconds > 0, "metrics interval must be at least 1 second");
+    ensure!(
+        opts.prometheus_url.is_none() || opts.results_namespace.is_some(),
+        "--results-namespace is required when collecting metrics"
+    );
+    if let Some(seed) = opts.seed {
+        info!("random seed: {seed}");
+    }
+
+    let mut namespace_map = HashMap::new();
+    for m in &opts.namespace_map {
+        let (from, to) = m
+            .split_once('=')
+            .filter(|(from, to)| !from.is_empty() && !to.is_empty())
+            .ok_or(anyhow!("invalid namespace mapping {m:?}: must be from=to"))?;
+        namespace_map.insert(from.to_string(), to.to_string());
+    }
+
+    let metrics = match &opts.prometheus_url {
+        Some(url) => Some(MetricsCollector::new(
+            &opts.sim_name,
+            url,
+            Duration::from_secs(opts.metrics_interval_seconds),
+            &opts.metrics_query,
+        )?),
+        None => None,
+    };
+
     let client = kube::Client::try_default().await?;
 
     let trace_data = fs::read(opts.trace_path)?;
@@ -76,6 +185,11 @@ async fn run(opts: Options) -> EmptyResult {
         name: opts.sim_name.clone(),
         sim_root: opts.sim_root.clone(),
         virtual_ns_prefix: opts.virtual_ns_prefix.clone(),
+        speed: opts.speed,
+        repetitions: opts.repetitions,
+        max_duration: opts.max_duration_seconds.map(Duration::from_secs),
+        namespace_map,
+        scale_factor: opts.scale_factor,
         owners_cache,
         store,
     };
@@ -96,9 +210,11 @@ async fn run(opts: Options) -> EmptyResult {
     // Give the mutation handler a bit of time to come online before starting the sim
     sleep(Duration::from_secs(5)).await;
 
+    let start_time = Utc::now();
     let runner = TraceRunner::new(ctx.clone()).await?;
+    let metrics_task = metrics.clone().map(|m| tokio::spawn(m.run()));
 
-    tokio::select! {
+    let res = tokio::select! {
         res = server_task => Err(anyhow!("server terminated: {res:#?}")),
         res = tokio::spawn(runner.run()) => {
             match res {
@@ -106,7 +222,28 @@ async fn run(opts: Options) -> EmptyResult {
                 Err(err) => Err(err.into()),
             }
         },
+    };
+
+    // The metrics are saved even if the simulation failed, since they might help figure out why
+    if let Some(task) = metrics_task {
+        task.abort();
+    }
+    if let (Some(metrics), Some(namespace)) = (&metrics, &opts.results_namespace) {
+        if let Err(err) = metrics.save(client.clone(), namespace).await {
+            error!("could not save simulation metrics: {err}");
+        }
+    }
+    if let Some(dir) = &opts.results_dir {
+        let location = opts.results_location.clone().unwrap_or_else(|| format!("file://{dir}"));
+        let collected = match &metrics {
+            Some(m) => Some(m.metrics().await),
+            None => None,
+        };
+        if let Err(err) = write_results(client, &opts.sim_name, dir, &location, start_time, &res, collected).await {
+            error!("could not write simulation results: {err}");
+        }
     }
+    res
 }
 
 #[tokio::main]
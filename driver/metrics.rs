@@ -0,0 +1,245 @@
+use std::collections::BTreeMap;
+use std::sync::Arc;
+use std::time::Duration;
+
+use anyhow::{
+    anyhow,
+    bail,
+    ensure,
+};
+use chrono::Utc;
+use kube::api::{
+    Patch,
+    PatchParams,
+};
+use reqwest::Url;
+use serde::{
+    Deserialize,
+    Serialize,
+};
+use serde_json as json;
+use simkube::k8s::add_common_metadata;
+use simkube::macros::*;
+use simkube::prelude::*;
+use tokio::sync::Mutex;
+use tokio::time::sleep;
+use tracing::*;
+
+// These have to match the ones in lib/go/results
+const METRICS_KEY: &str = "metrics.json";
+const DEFAULT_QUERIES: [(&str, &str); 5] = [
+    ("pending_pods", r#"sum(kube_pod_status_phase{phase="Pending"})"#),
+    ("nodes", "count(kube_node_info)"),
+    (
+        "scheduling_latency_p99",
+        "histogram_quantile(0.99, sum(rate(scheduler_pod_scheduling_duration_seconds_bucket[1m])) by (le))",
+    ),
+    ("pods_by_scheduler", "sum(simkube_vnode_pods_scheduled_total) by (scheduler)"),
+    (
+        "scheduling_latency_by_scheduler",
+        concat!(
+            "sum(rate(simkube_vnode_pod_scheduling_latency_seconds_total[1m])) by (scheduler)",
+            " / sum(rate(simkube_vnode_pods_scheduled_total[1m])) by (scheduler)",
+        ),
+    ),
+];
+
+const QUERY_TIMEOUT: Duration = Duration::from_secs(10);
+
+#[derive(Clone, Debug, Deserialize, Serialize)]
+pub struct MetricsQuery {
+    pub name: String,
+    pub query: String,
+}
+
+#[derive(Clone, Debug, Deserialize, Serialize)]
+pub struct Sample {
+    pub ts: i64,
+    pub value: f64,
+}
+
+#[derive(Clone, Debug, Deserialize, Serialize)]
+pub struct Series {
+    pub name: String,
+    #[serde(default, skip_serializing_if = "BTreeMap::is_empty")]
+    pub labels: BTreeMap<String, String>,
+    pub samples: Vec<Sample>,
+}
+
+#[derive(Clone, Debug, Deserialize, Serialize)]
+pub struct Metrics {
+    pub simulation: String,
+    pub queries: Vec<MetricsQuery>,
+    pub series: Vec<Series>,
+}
+
+type SeriesKey = (String, BTreeMap<String, String>);
+
+// The collector runs the Prometheus queries at a regular interval for as long as the simulation is
+// running, and keeps the results in memory until the simulation is over.  Queries that fail are
+// logged and skipped, since missing a few samples shouldn't stop the simulation.
+#[derive(Clone)]
+pub struct MetricsCollector {
+    sim_name: String,
+    prometheus_url: Url,
+    interval: Duration,
+    queries: Vec<MetricsQuery>,
+
+    http_client: reqwest::Client,
+    series: Arc<Mutex<BTreeMap<SeriesKey, Vec<Sample>>>>,
+}
+
+impl MetricsCollector {
+    pub fn new(
+        sim_name: &str,
+        prometheus_url: &str,
+        interval: Duration,
+        queries: &[String],
+    ) -> anyhow::Result<MetricsCollector> {
+        let prometheus_url = Url::parse(prometheus_url)?;
+        ensure!(
+            matches!(prometheus_url.scheme(), "http" | "https"),
+            "invalid Prometheus URL {prometheus_url}: must be an http or https URL"
+        );
+
+        let mut parsed_queries = vec![];
+        for q in queries {
+            let (name, query) = q
+                .split_once('=')
+                .filter(|(name, query)| !name.is_empty() && !query.is_empty())
+                .ok_or(anyhow!("invalid metrics query {q:?}: must be name=query"))?;
+            parsed_queries.push(MetricsQuery { name: name.into(), query: query.into() });
+        }
+        if parsed_queries.is_empty() {
+            parsed_queries = DEFAULT_QUERIES
+                .iter()
+                .map(|(name, query)| MetricsQuery { name: name.to_string(), query: query.to_string() })
+                .collect();
+        }
+
+        Ok(MetricsCollector {
+            sim_name: sim_name.into(),
+            prometheus_url,
+            interval,
+            queries: parsed_queries,
+            http_client: reqwest::Client::builder().timeout(QUERY_TIMEOUT).build()?,
+            series: Arc::new(Mutex::new(BTreeMap::new())),
+        })
+    }
+
+    // Runs the queries right away, and then once per interval; this never returns, so the task
+    // that it's running in should be aborted when the simulation is over
+    pub async fn run(self) {
+        loop {
+            self.collect().await;
+            sleep(self.interval).await;
+        }
+    }
+
+    pub async fn metrics(&self) -> Metrics {
+        let series = self.series.lock().await;
+        Metrics {
+            simulation: self.sim_name.clone(),
+            queries: self.queries.clone(),
+            series: series
+                .iter()
+                .map(|((name, labels), samples)| Series {
+                    name: name.clone(),
+                    labels: labels.clone(),
+                    samples: samples.clone(),
+                })
+                .collect(),
+        }
+    }
+
+    // The results are stored in a ConfigMap that's owned by the Simulation (not the
+    // SimulationRoot), so that they stick around after the simulation is over
+    pub async fn save(&self, client: kube::Client, namespace: &str) -> EmptyResult {
+        let sims_api: kube::Api<Simulation> = kube::Api::all(client.clone());
+        let cm_api: kube::Api<corev1::ConfigMap> = kube::Api::namespaced(client, namespace);
+
+        let name = format!("sk-{}-results", self.sim_name);
+        let metrics = self.metrics().await;
+        let mut cm = corev1::ConfigMap {
+            metadata: metav1::ObjectMeta {
+                namespace: Some(namespace.into()),
+                name: Some(name.clone()),
+                ..Default::default()
+            },
+            data: Some(BTreeMap::from([(METRICS_KEY.into(), json::to_string(&metrics)?)])),
+            ..Default::default()
+        };
+        match sims_api.get(&self.sim_name).await {
+            Ok(sim) => add_common_metadata(&self.sim_name, &sim, &mut cm.metadata)?,
+            Err(err) => {
+                warn!("results won't be cleaned up with the simulation: {err}");
+                cm.metadata.labels = klabel!(SIMULATION_LABEL_KEY => self.sim_name);
+            },
+        }
+
+        cm_api.patch(&name, &PatchParams::apply("simkube").force(), &Patch::Apply(&cm)).await?;
+        info!("saved simulation metrics to {namespace}/{name}");
+        Ok(())
+    }
+
+    async fn collect(&self) {
+        // We use our own timestamps instead of Prometheus', so that all of the samples from the
+        // same round of queries line up
+        let now = Utc::now().timestamp();
+        for q in &self.queries {
+            match self.query(&q.query, now).await {
+                Ok(samples) => {
+                    let mut series = self.series.lock().await;
+                    for (labels, value) in samples {
+                        // JSON can't represent NaN or infinity, and they don't tell us anything
+                        if value.is_finite() {
+                            series.entry((q.name.clone(), labels)).or_default().push(Sample { ts: now, value });
+                        }
+                    }
+                },
+                Err(err) => warn!("could not run query {}: {err}", q.name),
+            }
+        }
+    }
+
+    // Runs an instant query (see https://prometheus.io/docs/prometheus/latest/querying/api/); only
+    // vector and scalar results are supported, since those are the only ones that make sense to
+    // sample over time
+    async fn query(&self, query: &str, now: i64) -> anyhow::Result<Vec<(BTreeMap<String, String>, f64)>> {
+        let mut url = self.prometheus_url.clone();
+        url.path_segments_mut()
+            .map_err(|_| anyhow!("invalid Prometheus URL: {}", self.prometheus_url))?
+            .pop_if_empty()
+            .extend(["api", "v1", "query"]);
+        url.query_pairs_mut()
+            .append_pair("query", query)
+            .append_pair("time", &now.to_string());
+
+        // Prometheus sends back errors in the same format, so we parse the body either way
+        let resp: json::Value = self.http_client.get(url).send().await?.json().await?;
+        if resp["status"] != "success" {
+            bail!("query failed: {}", resp["error"]);
+        }
+
+        let result = &resp["data"]["result"];
+        match resp["data"]["resultType"].as_str() {
+            Some("vector") => result
+                .as_array()
+                .ok_or(anyhow!("invalid vector result: {result}"))?
+                .iter()
+                .map(|elem| {
+                    let labels: BTreeMap<String, String> =
+                        json::from_value(elem["metric"].clone()).unwrap_or_default();
+                    Ok((labels, parse_value(&elem["value"])?))
+                })
+                .collect(),
+            Some("scalar") => Ok(vec![(BTreeMap::new(), parse_value(result)?)]),
+            t => bail!("unsupported result type: {t:?}"),
+        }
+    }
+}
+
+// Prometheus values are [<timestamp>, "<value>"]
+fn parse_value(value: &json::Value) -> anyhow::Result<f64> {
+    Ok(value[1].as_str().ok_or(anyhow!("invalid sample value: {value}"))?.parse()?)
+}
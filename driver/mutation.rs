@@ -123,7 +123,7 @@ fn add_lifecycle_annotation(
             let seq = mut_data.count(hash);
 
             let lifecycle = ctx.store.lookup_pod_lifecycle(&owner_ns_name, hash, seq);
-            if let Some(patch) = lifecycle.to_annotation_patch() {
+            if let Some(patch) = lifecycle.to_annotation_patch(ctx.speed) {
                 info!("applying lifecycle annotations (hash={hash}, seq={seq})");
                 if pod.metadata.annotations.is_none() {
                     patches.push(PatchOperation::Add(AddOperation {
@@ -0,0 +1,103 @@
+use std::fs;
+use std::path::Path;
+
+use chrono::{
+    DateTime,
+    SecondsFormat,
+    Utc,
+};
+use kube::api::Patch;
+use serde::Serialize;
+use serde_json as json;
+use simkube::api::v1::{
+    SimulationStatusPhase,
+    SimulationStatusResults,
+};
+use simkube::prelude::*;
+use tracing::*;
+
+use crate::metrics::Metrics;
+
+// Besides the metrics ConfigMap, the results of a simulation are written to a directory next to
+// the trace: a summary of how the simulation went, and the metrics bundle (if any metrics were
+// collected).  These have to match the ones in lib/go/results.
+const SUMMARY_FILE: &str = "summary.json";
+const METRICS_FILE: &str = "metrics.json";
+const SUMMARY_VERSION: i64 = 1;
+
+// The trace and the progress are copied from the Simulation, so that the results make sense on
+// their own
+#[derive(Debug, Serialize)]
+#[serde(rename_all = "camelCase")]
+struct Summary {
+    version: i64,
+    simulation: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    trace: Option<String>,
+    phase: SimulationStatusPhase,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    error: Option<String>,
+    start_time: String,
+    completion_time: String,
+    events_replayed: i64,
+    total_events: i64,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    metrics: Option<String>,
+}
+
+// Writes the summary (and the metrics, if there are any) to dir, and records where they are in the
+// simulation status; location is where dir can be found from outside of the driver (e.g., the host
+// path that it's mounted from).
+pub async fn write_results(
+    client: kube::Client,
+    sim_name: &str,
+    dir: &str,
+    location: &str,
+    start_time: DateTime<Utc>,
+    res: &EmptyResult,
+    metrics: Option<Metrics>,
+) -> EmptyResult {
+    let sims_api: kube::Api<Simulation> = kube::Api::all(client);
+
+    let mut summary = Summary {
+        version: SUMMARY_VERSION,
+        simulation: sim_name.into(),
+        trace: None,
+        phase: if res.is_ok() { SimulationStatusPhase::Succeeded } else { SimulationStatusPhase::Failed },
+        error: res.as_ref().err().map(|err| format!("{err:#}")),
+        start_time: start_time.to_rfc3339_opts(SecondsFormat::Secs, true),
+        completion_time: Utc::now().to_rfc3339_opts(SecondsFormat::Secs, true),
+        events_replayed: 0,
+        total_events: 0,
+        metrics: None,
+    };
+
+    // The summary is still useful without the trace and the progress, so we don't fail here
+    match sims_api.get(sim_name).await {
+        Ok(sim) => {
+            summary.trace = Some(sim.spec.trace.clone());
+            if let Some(status) = sim.status {
+                summary.events_replayed = status.events_replayed.unwrap_or_default();
+                summary.total_events = status.total_events.unwrap_or_default();
+            }
+        },
+        Err(err) => warn!("results summary will be incomplete: {err}"),
+    }
+
+    fs::create_dir_all(dir)?;
+    if let Some(metrics) = metrics {
+        fs::write(Path::new(dir).join(METRICS_FILE), json::to_vec_pretty(&metrics)?)?;
+        summary.metrics = Some(METRICS_FILE.into());
+    }
+    fs::write(Path::new(dir).join(SUMMARY_FILE), json::to_vec_pretty(&summary)?)?;
+    info!("wrote simulation results to {dir}");
+
+    let results = SimulationStatusResults {
+        location: location.into(),
+        summary: SUMMARY_FILE.into(),
+        metrics: summary.metrics,
+    };
+    let status = json::json!({"status": {"results": results}});
+    sims_api.patch_status(sim_name, &Default::default(), &Patch::Merge(status)).await?;
+    Ok(())
+}
@@ -1,4 +1,8 @@
-use std::cmp::max;
+use std::cmp::{
+    max,
+    min,
+};
+use std::collections::HashSet;
 use std::time::Duration;
 
 use anyhow::anyhow;
@@ -20,11 +24,18 @@ use simkube::macros::*;
 use simkube::prelude::*;
 use tokio::runtime::Handle;
 use tokio::task::block_in_place;
-use tokio::time::sleep;
+use tokio::time::{
+    sleep,
+    Instant,
+};
 use tracing::*;
 
 use super::*;
 
+// While the simulation is paused, and while we're waiting for the next event, we check the
+// Simulation this often to see if it's been paused or resumed
+const PAUSE_POLL_INTERVAL: Duration = Duration::from_secs(5);
+
 fn build_virtual_ns(ctx: &DriverContext, owner: &SimulationRoot, namespace: &str) -> anyhow::Result<corev1::Namespace> {
     let mut ns = corev1::Namespace {
         metadata: build_global_object_meta(namespace, &ctx.name, owner)?,
@@ -64,11 +75,33 @@ fn build_virtual_obj(
         true,
     )?;
     jsonutils::patch_ext::remove("", "status", &mut vobj.data)?;
+    scale_replicas(&mut vobj.data, ctx.scale_factor);
 
 
     Ok(vobj)
 }
 
+// Multiply the object's replicas (or for jobs, its parallelism and completions) by the scale
+// factor; we round to the nearest whole number, but never scale an object down to no replicas at
+// all.  The extra pods reuse the lifecycles of the pods in the trace.
+fn scale_replicas(data: &mut serde_json::Value, scale_factor: f64) {
+    if let Some(spec) = data.get_mut("spec").and_then(|spec| spec.as_object_mut()) {
+        for field in ["replicas", "parallelism", "completions"] {
+            if let Some(count) = spec.get(field).and_then(|count| count.as_i64()).filter(|count| *count > 0) {
+                let scaled = max(1, (count as f64 * scale_factor).round() as i64);
+                spec.insert(field.into(), json!(scaled));
+            }
+        }
+    }
+}
+
+// The deadline is when the simulation reaches its max duration (if it has one); it gets pushed back
+// whenever the simulation is paused, since the time spent paused doesn't count
+struct ReplayState {
+    deadline: Option<Instant>,
+    paused: bool,
+}
+
 pub struct TraceRunner {
     ctx: DriverContext,
     client: kube::Client,
@@ -86,16 +119,63 @@ impl TraceRunner {
 
     #[instrument(parent=None, skip_all, fields(simulation=self.ctx.name))]
     pub async fn run(self) -> EmptyResult {
+        let mut state = ReplayState {
+            deadline: self.ctx.max_duration.map(|d| Instant::now() + d),
+            paused: false,
+        };
+        if !self.replay_all(&mut state).await? {
+            info!("simulation reached its maximum duration of {:?}, stopping", self.ctx.max_duration.unwrap());
+        }
+        Ok(())
+    }
+
+    // Returns false if the simulation reached its max duration before the last repetition finished
+    async fn replay_all(&self, state: &mut ReplayState) -> anyhow::Result<bool> {
         let ns_api: kube::Api<corev1::Namespace> = kube::Api::all(self.client.clone());
         let mut apiset = ApiSet::new(self.client.clone());
+
+        // The objects from the trace that have been applied and not deleted yet, so that they
+        // can be cleaned up before the next repetition
+        let mut live_objs = HashSet::new();
+        let mut events_replayed = 0;
+        for rep in 0..self.ctx.repetitions {
+            if rep > 0 {
+                info!("starting repetition {} of {}", rep + 1, self.ctx.repetitions);
+                for (gvk, virtual_ns, name) in live_objs.drain() {
+                    info!("deleting leftover object {virtual_ns}/{name}");
+                    apiset
+                        .namespaced_api_for(&gvk, virtual_ns)
+                        .await?
+                        .delete(&name, &Default::default())
+                        .await?;
+                }
+            }
+            if !self.replay(&ns_api, &mut apiset, &mut live_objs, &mut events_replayed, state).await? {
+                return Ok(false);
+            }
+        }
+
+        Ok(true)
+    }
+
+    async fn replay(
+        &self,
+        ns_api: &kube::Api<corev1::Namespace>,
+        apiset: &mut ApiSet,
+        live_objs: &mut HashSet<(GVK, String, String)>,
+        events_replayed: &mut i64,
+        state: &mut ReplayState,
+    ) -> anyhow::Result<bool> {
         let mut sim_ts = self.ctx.store.start_ts().ok_or(anyhow!("no trace data"))?;
         for (evt, next_ts) in self.ctx.store.iter() {
+            self.wait_while_paused(state).await;
+
             // We're currently assuming that all tracked objects are namespace-scoped,
             // this will panic/fail if that is not true.
             for obj in &evt.applied_objs {
                 let gvk = GVK::from_dynamic_obj(obj)?;
                 let original_ns = obj.namespace().unwrap();
-                let virtual_ns = format!("{}-{}", self.ctx.virtual_ns_prefix, original_ns);
+                let virtual_ns = self.ctx.virtual_ns(&original_ns);
 
                 if ns_api.get_opt(&virtual_ns).await?.is_none() {
                     info!("creating virtual namespace: {virtual_ns}");
@@ -113,6 +193,7 @@ impl TraceRunner {
                     build_virtual_obj(&self.ctx, &self.root, &original_ns, &virtual_ns, obj, pod_spec_template_path)?;
 
                 info!("applying object {}", vobj.namespaced_name());
+                live_objs.insert((gvk.clone(), virtual_ns.clone(), vobj.name_any()));
                 apiset
                     .namespaced_api_for(&gvk, virtual_ns)
                     .await?
@@ -123,7 +204,8 @@ impl TraceRunner {
             for obj in &evt.deleted_objs {
                 info!("deleting object {}", obj.namespaced_name());
                 let gvk = GVK::from_dynamic_obj(obj)?;
-                let virtual_ns = format!("{}-{}", self.ctx.virtual_ns_prefix, obj.namespace().unwrap());
+                let virtual_ns = self.ctx.virtual_ns(&obj.namespace().unwrap());
+                live_objs.remove(&(gvk.clone(), virtual_ns.clone(), obj.name_any()));
                 apiset
                     .namespaced_api_for(&gvk, virtual_ns)
                     .await?
@@ -131,15 +213,88 @@ impl TraceRunner {
                     .await?;
             }
 
+            *events_replayed += 1;
+            self.report_progress(*events_replayed).await;
+
             if let Some(ts) = next_ts {
-                let sleep_duration = max(0, ts - sim_ts);
+                let sleep_duration = max(0, ts - sim_ts) as f64 / self.ctx.speed;
                 sim_ts = ts;
-                info!("next event happens in {sleep_duration} seconds, sleeping");
-                sleep(Duration::from_secs(sleep_duration as u64)).await;
+                info!("next event happens in {sleep_duration:.1} seconds, sleeping");
+                if !self.sleep(Duration::from_secs_f64(sleep_duration), state).await {
+                    return Ok(false);
+                }
             }
         }
 
-        Ok(())
+        Ok(true)
+    }
+
+    // Waits for the given duration, not counting the time that the simulation spends paused;
+    // returns false if the deadline passes first
+    async fn sleep(&self, duration: Duration, state: &mut ReplayState) -> bool {
+        let mut wake = Instant::now() + duration;
+        loop {
+            let now = Instant::now();
+            if matches!(state.deadline, Some(deadline) if now >= deadline) {
+                return false;
+            } else if now >= wake {
+                return true;
+            }
+
+            let mut step = min(wake - now, PAUSE_POLL_INTERVAL);
+            if let Some(deadline) = state.deadline {
+                step = min(step, deadline - now);
+            }
+            sleep(step).await;
+
+            // If we're already at the next event, the pause check happens before it gets replayed
+            if Instant::now() < wake {
+                wake += self.wait_while_paused(state).await;
+            }
+        }
+    }
+
+    // Blocks for as long as the simulation is paused, and returns how long that was
+    async fn wait_while_paused(&self, state: &mut ReplayState) -> Duration {
+        if !self.check_paused(state).await {
+            return Duration::ZERO;
+        }
+
+        info!("simulation paused, waiting for it to be resumed");
+        let start = Instant::now();
+        while state.paused {
+            sleep(PAUSE_POLL_INTERVAL).await;
+            self.check_paused(state).await;
+        }
+
+        let paused_for = start.elapsed();
+        if let Some(deadline) = state.deadline.as_mut() {
+            *deadline += paused_for;
+        }
+        info!("simulation resumed after {paused_for:?}");
+        paused_for
+    }
+
+    // If we can't get the Simulation, we stick with what we knew before, so that a flaky API
+    // server doesn't pause (or resume) the simulation
+    async fn check_paused(&self, state: &mut ReplayState) -> bool {
+        let sims_api: kube::Api<Simulation> = kube::Api::all(self.client.clone());
+        match sims_api.get(&self.ctx.name).await {
+            Ok(sim) => state.paused = sim.spec.paused.unwrap_or(false),
+            Err(err) => warn!("could not check whether the simulation is paused: {err}"),
+        }
+        state.paused
+    }
+
+    // The controller sets the rest of the simulation status; we only report how far through the
+    // trace we are.  This is best-effort, since it doesn't affect the simulation itself.
+    async fn report_progress(&self, events_replayed: i64) {
+        let sims_api: kube::Api<Simulation> = kube::Api::all(self.client.clone());
+        let total_events = self.ctx.store.iter().count() as i64 * self.ctx.repetitions as i64;
+        let status = json!({"status": {"eventsReplayed": events_replayed, "totalEvents": total_events}});
+        if let Err(err) = sims_api.patch_status(&self.ctx.name, &Default::default(), &Patch::Merge(status)).await {
+            warn!("could not update simulation status: {err}");
+        }
     }
 }
 
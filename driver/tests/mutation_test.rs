@@ -38,6 +38,11 @@ fn ctx(
         name: TEST_SIM_NAME.into(),
         sim_root: TEST_SIM_ROOT_NAME.into(),
         virtual_ns_prefix: "virtual".into(),
+        speed: 1.0,
+        repetitions: 1,
+        max_duration: None,
+        namespace_map: HashMap::new(),
+        scale_factor: 1.0,
         owners_cache: Arc::new(Mutex::new(OwnersCache::new_from_parts(apiset, owners))),
         store: Arc::new(store),
     }
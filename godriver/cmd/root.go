@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"simkube/godriver"
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/debugserver"
+	"simkube/lib/go/driver"
+	"simkube/lib/go/results"
+	"simkube/lib/go/util"
+)
+
+const (
+	progname = "sk-godriver"
+
+	verbosityFlag        = "verbosity"
+	jsonLogsFlag         = "jsonlogs"
+	otlpLogsEndpointFlag = "otlp-logs-endpoint"
+	redactKeysFlag       = "redact-keys"
+	redactPatternsFlag   = "redact-patterns"
+	debugAddrFlag        = "debug-addr"
+
+	simNameFlag              = "sim-name"
+	simRootFlag              = "sim-root"
+	virtualNsPrefixFlag      = "virtual-ns-prefix"
+	admissionWebhookPortFlag = "admission-webhook-port"
+	certPathFlag             = "cert-path"
+	keyPathFlag              = "key-path"
+	tracePathFlag            = "trace-path"
+	speedFlag                = "speed"
+	maxDurationSecondsFlag   = "max-duration-seconds"
+	repetitionsFlag          = "repetitions"
+	seedFlag                 = "seed"
+	namespaceMapFlag         = "namespace-map"
+	scaleFactorFlag          = "scale-factor"
+	prometheusURLFlag        = "prometheus-url"
+	metricsIntervalFlag      = "metrics-interval-seconds"
+	metricsQueryFlag         = "metrics-query"
+	resultsNamespaceFlag     = "results-namespace"
+	resultsDirFlag           = "results-dir"
+	resultsLocationFlag      = "results-location"
+)
+
+func rootCmd() *cobra.Command {
+	root := &cobra.Command{
+		Use:   progname,
+		Short: "Replay a simkube trace (Go version of sk-driver)",
+		Run:   start,
+	}
+
+	root.PersistentFlags().IntP(verbosityFlag, "v", 2, "log level output (higher is more verbose")
+	root.PersistentFlags().Bool(jsonLogsFlag, false, "structured JSON logging output")
+	root.PersistentFlags().String(
+		otlpLogsEndpointFlag,
+		"",
+		"OTLP/HTTP endpoint to export logs to, e.g., http://otel-collector:4318/v1/logs\n"+
+			"    (defaults to $OTEL_EXPORTER_OTLP_LOGS_ENDPOINT; if neither is set, logs aren't exported)",
+	)
+	root.PersistentFlags().StringSlice(
+		redactKeysFlag,
+		nil,
+		"additional log field names and keys whose values are masked in the logs (passwords, tokens, etc. always are)",
+	)
+	root.PersistentFlags().StringArray(
+		redactPatternsFlag,
+		nil,
+		"regular expression whose matches are masked in the logs (can be repeated)",
+	)
+	root.PersistentFlags().String(
+		debugAddrFlag,
+		"",
+		"listen address for the debug HTTP server, with pprof profiles and expvar stats (empty to disable)",
+	)
+	root.PersistentFlags().String(simNameFlag, "", "name of the simulation")
+	root.PersistentFlags().String(simRootFlag, "", "name of the SimulationRoot that owns the simulation's objects")
+	root.PersistentFlags().String(virtualNsPrefixFlag, "virtual", "prefix for the virtual namespaces")
+	root.PersistentFlags().Int(admissionWebhookPortFlag, 8888, "port for the mutating admission webhook")
+	root.PersistentFlags().String(certPathFlag, "", "location of the admission webhook's TLS certificate")
+	root.PersistentFlags().String(keyPathFlag, "", "location of the admission webhook's TLS key")
+	root.PersistentFlags().String(tracePathFlag, "", "location of the trace file to replay")
+	root.PersistentFlags().Float64(
+		speedFlag,
+		1,
+		"how much faster than real time to replay the trace (pod lifetimes are scaled by the same amount)",
+	)
+	root.PersistentFlags().Int64(
+		maxDurationSecondsFlag,
+		0,
+		"stop the simulation after this many seconds, even if the trace isn't finished (0 means no limit)",
+	)
+	root.PersistentFlags().Int(repetitionsFlag, 1, "how many times to replay the trace")
+	root.PersistentFlags().Int64(seedFlag, 0, "random seed for the simulation")
+	root.PersistentFlags().StringToString(
+		namespaceMapFlag,
+		nil,
+		"replay a namespace from the trace into another namespace, as from=to (can be repeated)",
+	)
+	root.PersistentFlags().Float64(
+		scaleFactorFlag,
+		1,
+		"multiply the replicas of the objects in the trace by this much (e.g., 2 to replay at twice the load)",
+	)
+	root.PersistentFlags().String(
+		prometheusURLFlag,
+		"",
+		"collect metrics from this Prometheus server while the simulation is running",
+	)
+	root.PersistentFlags().Int32(
+		metricsIntervalFlag,
+		simkubev1.DefaultMetricsIntervalSeconds,
+		"how often to collect metrics, in seconds",
+	)
+	root.PersistentFlags().StringArray(
+		metricsQueryFlag,
+		nil,
+		"a PromQL query to collect, as name=query (can be repeated; by default, a few scheduling metrics)",
+	)
+	root.PersistentFlags().String(resultsNamespaceFlag, "", "namespace to save the simulation's results in")
+	root.PersistentFlags().String(resultsDirFlag, "", "directory to write the simulation's results to")
+	root.PersistentFlags().String(
+		resultsLocationFlag,
+		"",
+		"where the results can be found outside of the driver, for the simulation status (default file://<results-dir>)",
+	)
+
+	for _, flag := range []string{simNameFlag, simRootFlag, certPathFlag, keyPathFlag, tracePathFlag} {
+		if err := root.MarkPersistentFlagRequired(flag); err != nil {
+			panic(err)
+		}
+	}
+	return root
+}
+
+func start(cmd *cobra.Command, _ []string) {
+	jsonLogs, err := cmd.PersistentFlags().GetBool(jsonLogsFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	level, err := cmd.PersistentFlags().GetInt(verbosityFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	otlpLogsEndpoint, err := cmd.PersistentFlags().GetString(otlpLogsEndpointFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	redactKeys, err := cmd.PersistentFlags().GetStringSlice(redactKeysFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	redactPatterns, err := cmd.PersistentFlags().GetStringArray(redactPatternsFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	debugAddr, err := cmd.PersistentFlags().GetString(debugAddrFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	simName, err := cmd.PersistentFlags().GetString(simNameFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	simRoot, err := cmd.PersistentFlags().GetString(simRootFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	virtualNsPrefix, err := cmd.PersistentFlags().GetString(virtualNsPrefixFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	admissionWebhookPort, err := cmd.PersistentFlags().GetInt(admissionWebhookPortFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	certPath, err := cmd.PersistentFlags().GetString(certPathFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	keyPath, err := cmd.PersistentFlags().GetString(keyPathFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	tracePath, err := cmd.PersistentFlags().GetString(tracePathFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	speed, err := cmd.PersistentFlags().GetFloat64(speedFlag)
+	if err != nil {
+		panic(err)
+	}
+	if speed <= 0 {
+		panic(fmt.Sprintf("speed must be positive: %v", speed))
+	}
+
+	maxDurationSeconds, err := cmd.PersistentFlags().GetInt64(maxDurationSecondsFlag)
+	if err != nil {
+		panic(err)
+	}
+	if maxDurationSeconds < 0 {
+		panic(fmt.Sprintf("max duration can't be negative: %v", maxDurationSeconds))
+	}
+
+	repetitions, err := cmd.PersistentFlags().GetInt(repetitionsFlag)
+	if err != nil {
+		panic(err)
+	}
+	if repetitions < 1 {
+		panic(fmt.Sprintf("repetitions must be at least 1: %v", repetitions))
+	}
+
+	seed, err := cmd.PersistentFlags().GetInt64(seedFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	namespaceMap, err := cmd.PersistentFlags().GetStringToString(namespaceMapFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	scaleFactor, err := cmd.PersistentFlags().GetFloat64(scaleFactorFlag)
+	if err != nil {
+		panic(err)
+	}
+	if scaleFactor <= 0 {
+		panic(fmt.Sprintf("scale factor must be positive: %v", scaleFactor))
+	}
+
+	prometheusURL, err := cmd.PersistentFlags().GetString(prometheusURLFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	metricsInterval, err := cmd.PersistentFlags().GetInt32(metricsIntervalFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	metricsQueries, err := cmd.PersistentFlags().GetStringArray(metricsQueryFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	resultsNamespace, err := cmd.PersistentFlags().GetString(resultsNamespaceFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	resultsDir, err := cmd.PersistentFlags().GetString(resultsDirFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	resultsLocation, err := cmd.PersistentFlags().GetString(resultsLocationFlag)
+	if err != nil {
+		panic(err)
+	}
+	if resultsDir != "" && resultsLocation == "" {
+		absDir, err := filepath.Abs(resultsDir)
+		if err != nil {
+			panic(err)
+		}
+		resultsLocation = "file://" + absDir
+	}
+
+	var metrics *simkubev1.SimulationMetricsConfig
+	if prometheusURL != "" {
+		if resultsNamespace == "" {
+			panic("--results-namespace is required when collecting metrics")
+		}
+		metrics = &simkubev1.SimulationMetricsConfig{PrometheusURL: prometheusURL, IntervalSeconds: metricsInterval}
+		for _, q := range metricsQueries {
+			query, err := results.ParseQuery(q)
+			if err != nil {
+				panic(err)
+			}
+			metrics.Queries = append(metrics.Queries, query)
+		}
+	}
+
+	flushLogs := util.SetupLogging(level, jsonLogs, util.OTLPLogOptions{
+		Endpoint:   otlpLogsEndpoint,
+		Component:  progname,
+		Simulation: simName,
+	})
+	defer flushLogs()
+	if err := util.SetupRedaction(util.RedactionOptions{Keys: redactKeys, Patterns: redactPatterns}); err != nil {
+		panic(err)
+	}
+	if debugAddr != "" {
+		debugserver.Run(context.Background(), debugAddr)
+	}
+
+	// The driver doesn't make any random choices yet, but we log the seed so that it's recorded
+	// along with the rest of the simulation's output
+	if cmd.PersistentFlags().Changed(seedFlag) {
+		log.Infof("random seed: %d", seed)
+	}
+
+	opts := godriver.Options{
+		Driver: driver.Options{
+			SimName:         simName,
+			SimRoot:         simRoot,
+			VirtualNsPrefix: virtualNsPrefix,
+			Speed:           speed,
+			Repetitions:     repetitions,
+			MaxDuration:     time.Duration(maxDurationSeconds) * time.Second,
+			NamespaceMap:    namespaceMap,
+			ScaleFactor:     scaleFactor,
+		},
+		AdmissionWebhookPort: admissionWebhookPort,
+		CertPath:             certPath,
+		KeyPath:              keyPath,
+		TracePath:            tracePath,
+		Metrics:              metrics,
+		ResultsNamespace:     resultsNamespace,
+		ResultsDir:           resultsDir,
+		ResultsLocation:      resultsLocation,
+	}
+	if err := godriver.Run(opts); err != nil {
+		log.WithError(err).Error("simulation failed")
+		os.Exit(1)
+	}
+}
+
+func main() {
+	if err := rootCmd().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,291 @@
+package godriver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/driver"
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/results"
+	"simkube/lib/go/trace"
+)
+
+const (
+	webhookReadHeaderTimeout = 5 * time.Second
+	webhookShutdownTimeout   = 5 * time.Second
+
+	// Give the mutation handler a bit of time to come online before starting the simulation
+	webhookStartupDelay = 5 * time.Second
+
+	saveResultsTimeout = 30 * time.Second
+)
+
+type Options struct {
+	Driver driver.Options
+
+	AdmissionWebhookPort int
+	CertPath             string
+	KeyPath              string
+	TracePath            string
+
+	// If Metrics is set, the driver runs the Prometheus queries for as long as the simulation is
+	// running, and saves the results in ResultsNamespace
+	Metrics          *simkubev1.SimulationMetricsConfig
+	ResultsNamespace string
+
+	// If ResultsDir is set, the driver writes the results summary (and the metrics) there when the
+	// simulation is over, and records ResultsLocation in the simulation status
+	ResultsDir      string
+	ResultsLocation string
+}
+
+// Run serves the mutating admission webhook for the simulation's pods, and replays the trace;
+// it returns when the trace is finished, or if either the webhook or the replay fails
+func Run(opts Options) error {
+	logger := log.WithFields(log.Fields{"simulation": opts.Driver.SimName})
+
+	traceData, err := os.ReadFile(opts.TracePath)
+	if err != nil {
+		return fmt.Errorf("could not read trace: %w", err)
+	}
+	tr, err := trace.ReadTrace(bytes.NewReader(traceData))
+	if err != nil {
+		return fmt.Errorf("could not parse trace %s: %w", opts.TracePath, err)
+	}
+
+	k8sClient, err := k8s.NewClient(k8s.ClientOptions{})
+	if err != nil {
+		return err
+	}
+	dynamicClient, err := k8s.NewDynamicClient(k8s.ClientOptions{})
+	if err != nil {
+		return err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(k8sClient.Discovery()))
+
+	var collector *results.Collector
+	if opts.Metrics != nil {
+		if collector, err = results.NewCollector(opts.Driver.SimName, *opts.Metrics); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", opts.AdmissionWebhookPort),
+		Handler:           driver.NewMutationHandler(opts.Driver, tr, dynamicClient, mapper),
+		ReadHeaderTimeout: webhookReadHeaderTimeout,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Infof("admission webhook listening on %s", srv.Addr)
+		serverErr <- srv.ListenAndServeTLS(opts.CertPath, opts.KeyPath)
+	}()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.WithError(err).Warn("could not shut down admission webhook")
+		}
+	}()
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("admission webhook terminated: %w", err)
+	case <-ctx.Done():
+		return fmt.Errorf("simulation interrupted: %w", ctx.Err())
+	case <-time.After(webhookStartupDelay):
+	}
+
+	startTime := time.Now()
+	stopMetrics := func() {}
+	if collector != nil {
+		stopMetrics = collectMetrics(ctx, opts, collector, k8sClient, dynamicClient, logger)
+	}
+
+	// If the webhook fails, we stop the runner, and wait for it to clean up the simulation
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	runnerErr := make(chan error, 1)
+	go func() { runnerErr <- driver.NewRunner(opts.Driver, tr, dynamicClient, mapper).Run(runCtx) }()
+
+	select {
+	case err = <-serverErr:
+		cancel()
+		<-runnerErr
+		err = fmt.Errorf("admission webhook terminated: %w", err)
+	case err = <-runnerErr:
+	}
+
+	stopMetrics()
+	if opts.ResultsDir != "" {
+		//nolint:contextcheck // the results get written even if the simulation was interrupted
+		writeResults(opts, collector, dynamicClient, startTime, err, logger)
+	}
+	return err
+}
+
+// collectMetrics runs the collector in the background; the function that it returns stops the
+// collector and saves the results.  Failing to save the results is logged, but doesn't fail the
+// simulation.
+func collectMetrics(
+	ctx context.Context,
+	opts Options,
+	collector *results.Collector,
+	k8sClient kubernetes.Interface,
+	dynamicClient dynamic.Interface,
+	logger *log.Entry,
+) func() {
+	metricsCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		collector.Run(metricsCtx)
+		close(done)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+
+		//nolint:contextcheck // the results get saved even if the simulation was interrupted
+		saveCtx, cancelSave := context.WithTimeout(context.Background(), saveResultsTimeout)
+		defer cancelSave()
+
+		owner, err := simulationOwner(saveCtx, dynamicClient, opts.Driver.SimName)
+		if err != nil {
+			logger.WithError(err).Warn("results won't be cleaned up with the simulation")
+		}
+		cm, err := results.BuildConfigMap(opts.ResultsNamespace, owner, collector.Metrics())
+		if err == nil {
+			err = results.Save(saveCtx, k8sClient, cm)
+		}
+		if err != nil {
+			logger.WithError(err).Error("could not save simulation metrics")
+		} else {
+			logger.Infof("saved simulation metrics to %s/%s", cm.Namespace, cm.Name)
+		}
+	}
+}
+
+// writeResults writes the results summary (and the metrics, if there are any) to the results
+// directory, and records where they are in the simulation status.  Like saving the metrics, this is
+// best-effort: failures are logged, but don't fail the simulation.
+func writeResults(
+	opts Options,
+	collector *results.Collector,
+	dynamicClient dynamic.Interface,
+	startTime time.Time,
+	runErr error,
+	logger *log.Entry,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), saveResultsTimeout)
+	defer cancel()
+
+	summary := &results.Summary{
+		Version:        results.SummaryVersion,
+		Simulation:     opts.Driver.SimName,
+		Phase:          simkubev1.SimulationSucceeded,
+		StartTime:      startTime,
+		CompletionTime: time.Now(),
+	}
+	if runErr != nil {
+		summary.Phase = simkubev1.SimulationFailed
+		summary.Error = runErr.Error()
+	}
+
+	// The trace and the progress come from the simulation, so the summary is still useful without it
+	if sim, err := getSimulation(ctx, dynamicClient, opts.Driver.SimName); err != nil {
+		logger.WithError(err).Warn("results summary will be incomplete")
+	} else {
+		summary.Trace = sim.Spec.Trace
+		summary.EventsReplayed = sim.Status.EventsReplayed
+		summary.TotalEvents = sim.Status.TotalEvents
+	}
+
+	var metrics *results.Metrics
+	if collector != nil {
+		metrics = collector.Metrics()
+	}
+	ref, err := results.WriteLocal(opts.ResultsDir, opts.ResultsLocation, summary, metrics)
+	if err != nil {
+		logger.WithError(err).Error("could not write simulation results")
+		return
+	}
+	logger.Infof("wrote simulation results to %s", opts.ResultsDir)
+
+	patch, err := json.Marshal(map[string]interface{}{"status": map[string]interface{}{"results": ref}})
+	if err != nil {
+		logger.WithError(err).Error("could not record simulation results")
+		return
+	}
+	_, err = dynamicClient.Resource(simulationGVR()).Patch(
+		ctx,
+		opts.Driver.SimName,
+		types.MergePatchType,
+		patch,
+		metav1.PatchOptions{},
+		"status",
+	)
+	if err != nil {
+		logger.WithError(err).Error("could not record simulation results")
+	}
+}
+
+func getSimulation(
+	ctx context.Context,
+	dynamicClient dynamic.Interface,
+	simName string,
+) (*simkubev1.Simulation, error) {
+	obj, err := dynamicClient.Resource(simulationGVR()).Get(ctx, simName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not get simulation %s: %w", simName, err)
+	}
+
+	var sim simkubev1.Simulation
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &sim); err != nil {
+		return nil, fmt.Errorf("could not parse simulation %s: %w", simName, err)
+	}
+	return &sim, nil
+}
+
+func simulationGVR() schema.GroupVersionResource {
+	return simkubev1.GroupVersion.WithResource("simulations")
+}
+
+func simulationOwner(
+	ctx context.Context,
+	dynamicClient dynamic.Interface,
+	simName string,
+) (*metav1.OwnerReference, error) {
+	sim, err := getSimulation(ctx, dynamicClient, simName)
+	if err != nil {
+		return nil, err
+	}
+	return &metav1.OwnerReference{
+		APIVersion: simkubev1.GroupVersion.String(),
+		Kind:       "Simulation",
+		Name:       simName,
+		UID:        sim.GetUID(),
+	}, nil
+}
@@ -5,6 +5,7 @@ from cdk8s import Chart
 from constructs import Construct
 from fireconfig import k8s
 from fireconfig.types import Capability
+from fireconfig.types import DownwardAPIField
 from fireconfig.types import TaintEffect
 
 CLOUDPROV_ID = "sk-cloudprov"
@@ -22,11 +23,15 @@ class SKCloudProv(Chart):
 
         with open(os.getenv('BUILD_DIR') + f'/{CLOUDPROV_ID}-image') as f:
             image = f.read()
+        env = (fire.EnvBuilder()
+            .with_field_ref("POD_NAME", DownwardAPIField.NAME)
+            .with_field_ref("POD_NAMESPACE", DownwardAPIField.NAMESPACE)
+        )
         container = fire.ContainerBuilder(
             name=CLOUDPROV_ID,
             image=image,
             args=["/sk-cloudprov"],
-        ).with_ports(GRPC_PORT).with_security_context(Capability.DEBUG)
+        ).with_ports(GRPC_PORT).with_env(env).with_security_context(Capability.DEBUG)
 
         self._depl = (fire.DeploymentBuilder(namespace=namespace, selector={APP_KEY: CLOUDPROV_ID})
             .with_containers(container)
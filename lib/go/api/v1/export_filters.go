@@ -23,9 +23,13 @@ var _ MappedNullable = &ExportFilters{}
 
 // ExportFilters struct for ExportFilters
 type ExportFilters struct {
-	ExcludedNamespaces []string               `json:"excluded_namespaces"`
-	ExcludedLabels     []metav1.LabelSelector `json:"excluded_labels"`
-	ExcludeDaemonsets  bool                   `json:"exclude_daemonsets"`
+	ExcludedNamespaces  []string               `json:"excluded_namespaces"`
+	ExcludedLabels      []metav1.LabelSelector `json:"excluded_labels"`
+	ExcludeDaemonsets   bool                   `json:"exclude_daemonsets"`
+	IncludedKinds       []string               `json:"included_kinds,omitempty"`
+	ExcludedKinds       []string               `json:"excluded_kinds,omitempty"`
+	ExcludeOwnedObjects *bool                  `json:"exclude_owned_objects,omitempty"`
+	TopLevelOnly        *bool                  `json:"top_level_only,omitempty"`
 }
 
 // NewExportFilters instantiates a new ExportFilters object
@@ -120,6 +124,134 @@ func (o *ExportFilters) SetExcludeDaemonsets(v bool) {
 	o.ExcludeDaemonsets = v
 }
 
+// GetIncludedKinds returns the IncludedKinds field value if set, zero value otherwise.
+func (o *ExportFilters) GetIncludedKinds() []string {
+	if o == nil || IsNil(o.IncludedKinds) {
+		var ret []string
+		return ret
+	}
+	return o.IncludedKinds
+}
+
+// GetIncludedKindsOk returns a tuple with the IncludedKinds field value if set, nil otherwise
+// and a boolean to check if the value has been set.
+func (o *ExportFilters) GetIncludedKindsOk() ([]string, bool) {
+	if o == nil || IsNil(o.IncludedKinds) {
+		return nil, false
+	}
+	return o.IncludedKinds, true
+}
+
+// HasIncludedKinds returns a boolean if a field has been set.
+func (o *ExportFilters) HasIncludedKinds() bool {
+	if o != nil && !IsNil(o.IncludedKinds) {
+		return true
+	}
+
+	return false
+}
+
+// SetIncludedKinds gets a reference to the given []string and assigns it to the IncludedKinds field.
+func (o *ExportFilters) SetIncludedKinds(v []string) {
+	o.IncludedKinds = v
+}
+
+// GetExcludedKinds returns the ExcludedKinds field value if set, zero value otherwise.
+func (o *ExportFilters) GetExcludedKinds() []string {
+	if o == nil || IsNil(o.ExcludedKinds) {
+		var ret []string
+		return ret
+	}
+	return o.ExcludedKinds
+}
+
+// GetExcludedKindsOk returns a tuple with the ExcludedKinds field value if set, nil otherwise
+// and a boolean to check if the value has been set.
+func (o *ExportFilters) GetExcludedKindsOk() ([]string, bool) {
+	if o == nil || IsNil(o.ExcludedKinds) {
+		return nil, false
+	}
+	return o.ExcludedKinds, true
+}
+
+// HasExcludedKinds returns a boolean if a field has been set.
+func (o *ExportFilters) HasExcludedKinds() bool {
+	if o != nil && !IsNil(o.ExcludedKinds) {
+		return true
+	}
+
+	return false
+}
+
+// SetExcludedKinds gets a reference to the given []string and assigns it to the ExcludedKinds field.
+func (o *ExportFilters) SetExcludedKinds(v []string) {
+	o.ExcludedKinds = v
+}
+
+// GetExcludeOwnedObjects returns the ExcludeOwnedObjects field value if set, zero value otherwise.
+func (o *ExportFilters) GetExcludeOwnedObjects() bool {
+	if o == nil || IsNil(o.ExcludeOwnedObjects) {
+		var ret bool
+		return ret
+	}
+	return *o.ExcludeOwnedObjects
+}
+
+// GetExcludeOwnedObjectsOk returns a tuple with the ExcludeOwnedObjects field value if set, nil otherwise
+// and a boolean to check if the value has been set.
+func (o *ExportFilters) GetExcludeOwnedObjectsOk() (*bool, bool) {
+	if o == nil || IsNil(o.ExcludeOwnedObjects) {
+		return nil, false
+	}
+	return o.ExcludeOwnedObjects, true
+}
+
+// HasExcludeOwnedObjects returns a boolean if a field has been set.
+func (o *ExportFilters) HasExcludeOwnedObjects() bool {
+	if o != nil && !IsNil(o.ExcludeOwnedObjects) {
+		return true
+	}
+
+	return false
+}
+
+// SetExcludeOwnedObjects gets a reference to the given bool and assigns it to the ExcludeOwnedObjects field.
+func (o *ExportFilters) SetExcludeOwnedObjects(v bool) {
+	o.ExcludeOwnedObjects = &v
+}
+
+// GetTopLevelOnly returns the TopLevelOnly field value if set, zero value otherwise.
+func (o *ExportFilters) GetTopLevelOnly() bool {
+	if o == nil || IsNil(o.TopLevelOnly) {
+		var ret bool
+		return ret
+	}
+	return *o.TopLevelOnly
+}
+
+// GetTopLevelOnlyOk returns a tuple with the TopLevelOnly field value if set, nil otherwise
+// and a boolean to check if the value has been set.
+func (o *ExportFilters) GetTopLevelOnlyOk() (*bool, bool) {
+	if o == nil || IsNil(o.TopLevelOnly) {
+		return nil, false
+	}
+	return o.TopLevelOnly, true
+}
+
+// HasTopLevelOnly returns a boolean if a field has been set.
+func (o *ExportFilters) HasTopLevelOnly() bool {
+	if o != nil && !IsNil(o.TopLevelOnly) {
+		return true
+	}
+
+	return false
+}
+
+// SetTopLevelOnly gets a reference to the given bool and assigns it to the TopLevelOnly field.
+func (o *ExportFilters) SetTopLevelOnly(v bool) {
+	o.TopLevelOnly = &v
+}
+
 func (o ExportFilters) MarshalJSON() ([]byte, error) {
 	toSerialize, err := o.ToMap()
 	if err != nil {
@@ -133,6 +265,18 @@ func (o ExportFilters) ToMap() (map[string]interface{}, error) {
 	toSerialize["excluded_namespaces"] = o.ExcludedNamespaces
 	toSerialize["excluded_labels"] = o.ExcludedLabels
 	toSerialize["exclude_daemonsets"] = o.ExcludeDaemonsets
+	if !IsNil(o.IncludedKinds) {
+		toSerialize["included_kinds"] = o.IncludedKinds
+	}
+	if !IsNil(o.ExcludedKinds) {
+		toSerialize["excluded_kinds"] = o.ExcludedKinds
+	}
+	if !IsNil(o.ExcludeOwnedObjects) {
+		toSerialize["exclude_owned_objects"] = o.ExcludeOwnedObjects
+	}
+	if !IsNil(o.TopLevelOnly) {
+		toSerialize["top_level_only"] = o.TopLevelOnly
+	}
 	return toSerialize, nil
 }
 
@@ -0,0 +1,62 @@
+package v1
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// These helpers build the kind filters for ExportFilters (see label_selectors.go).  Kinds are
+// written in the same <group>/<version>.<kind> form that the tracer config uses, e.g.,
+// "apps/v1.Deployment", or "/v1.Pod" for kinds in the core group.  If there are any included
+// kinds, only objects of those kinds are exported, and objects of the excluded kinds are never
+// exported; the tracer rejects requests that include kinds it isn't tracking.
+
+// ParseKind parses a GVK in <group>/<version>.<kind> form; the kind can't have dots in it,
+// but the group can
+func ParseKind(kind string) (schema.GroupVersionKind, error) {
+	group, versionKind, found := strings.Cut(kind, "/")
+	version, k, _ := strings.Cut(versionKind, ".")
+	if !found || version == "" || k == "" || strings.ContainsAny(k, "./") {
+		return schema.GroupVersionKind{}, fmt.Errorf("invalid GVK %q, expected <group>/<version>.<kind>", kind)
+	}
+	return schema.GroupVersionKind{Group: group, Version: version, Kind: k}, nil
+}
+
+func KindString(gvk schema.GroupVersionKind) string {
+	return fmt.Sprintf("%s/%s.%s", gvk.Group, gvk.Version, gvk.Kind)
+}
+
+func (o *ExportFilters) IncludeKinds(gvks ...schema.GroupVersionKind) {
+	for _, gvk := range gvks {
+		o.IncludedKinds = append(o.IncludedKinds, KindString(gvk))
+	}
+}
+
+func (o *ExportFilters) ExcludeKinds(gvks ...schema.GroupVersionKind) {
+	for _, gvk := range gvks {
+		o.ExcludedKinds = append(o.ExcludedKinds, KindString(gvk))
+	}
+}
+
+// validateKinds checks that all of the kinds can be parsed, and that none of them are both
+// included and excluded, since the tracer would silently drop them
+func (o *ExportFilters) validateKinds() error {
+	included := map[string]bool{}
+	for _, kind := range o.IncludedKinds {
+		if _, err := ParseKind(kind); err != nil {
+			return fmt.Errorf("included kind: %w", err)
+		}
+		included[kind] = true
+	}
+
+	for _, kind := range o.ExcludedKinds {
+		if _, err := ParseKind(kind); err != nil {
+			return fmt.Errorf("excluded kind: %w", err)
+		} else if included[kind] {
+			return fmt.Errorf("kind %s is both included and excluded", kind)
+		}
+	}
+	return nil
+}
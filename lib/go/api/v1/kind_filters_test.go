@@ -0,0 +1,87 @@
+package v1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseKind(t *testing.T) {
+	cases := map[string]struct {
+		expected  schema.GroupVersionKind
+		expectErr bool
+	}{
+		"apps/v1.Deployment": {expected: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}},
+		"/v1.Pod":            {expected: schema.GroupVersionKind{Version: "v1", Kind: "Pod"}},
+		"argoproj.io/v1alpha1.Workflow": {
+			expected: schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Workflow"},
+		},
+		"Pod":                             {expectErr: true},
+		"/v1":                             {expectErr: true},
+		"argoproj.io/v1alpha1.Workflow.x": {expectErr: true},
+	}
+
+	for kind, tc := range cases {
+		t.Run(kind, func(t *testing.T) {
+			gvk, err := ParseKind(kind)
+			if tc.expectErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expected, gvk)
+				assert.Equal(t, kind, KindString(gvk))
+			}
+		})
+	}
+}
+
+func TestExportFiltersValidateKinds(t *testing.T) {
+	cases := map[string]struct {
+		included  []string
+		excluded  []string
+		expectErr bool
+	}{
+		"no kinds":         {},
+		"included":         {included: []string{"/v1.Pod", "argoproj.io/v1alpha1.Workflow"}},
+		"excluded":         {excluded: []string{"apps/v1.Deployment"}},
+		"both":             {included: []string{"/v1.Pod"}, excluded: []string{"apps/v1.Deployment"}},
+		"invalid included": {included: []string{"Pod"}, expectErr: true},
+		"invalid excluded": {excluded: []string{"apps/v1/Deployment"}, expectErr: true},
+		"conflict":         {included: []string{"/v1.Pod"}, excluded: []string{"/v1.Pod"}, expectErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			filters := NewExportFilters(nil, nil, false)
+			filters.SetIncludedKinds(tc.included)
+			filters.SetExcludedKinds(tc.excluded)
+			err := filters.Validate()
+			if tc.expectErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestExportFiltersKindsJSON(t *testing.T) {
+	filters := NewExportFilters(nil, nil, true)
+	data, err := json.Marshal(filters)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"excluded_namespaces": null, "excluded_labels": null, "exclude_daemonsets": true}`, string(data))
+
+	filters.IncludeKinds(schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Workflow"})
+	filters.ExcludeKinds(schema.GroupVersionKind{Version: "v1", Kind: "Pod"})
+	data, err = json.Marshal(filters)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{
+		"excluded_namespaces": null,
+		"excluded_labels": null,
+		"exclude_daemonsets": true,
+		"included_kinds": ["argoproj.io/v1alpha1.Workflow"],
+		"excluded_kinds": ["/v1.Pod"]
+	}`, string(data))
+}
@@ -0,0 +1,126 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// These helpers build the label selectors for ExportFilters; the export_filters.go file is
+// generated from api/v1/simkube.yml, so they live here instead.  A selector matches an
+// object if all of its labels and expressions match, and an object is excluded from the
+// trace if it matches any of the excluded selectors.
+
+// MatchLabels builds an equality-based selector, i.e., one that matches objects that have
+// all of the given labels
+func MatchLabels(labels map[string]string) metav1.LabelSelector {
+	return metav1.LabelSelector{MatchLabels: labels}
+}
+
+// MatchExpressions builds a set-based selector from the requirements below
+func MatchExpressions(reqs ...metav1.LabelSelectorRequirement) metav1.LabelSelector {
+	return metav1.LabelSelector{MatchExpressions: reqs}
+}
+
+func LabelIn(key string, values ...string) metav1.LabelSelectorRequirement {
+	return labelRequirement(key, metav1.LabelSelectorOpIn, values)
+}
+
+func LabelNotIn(key string, values ...string) metav1.LabelSelectorRequirement {
+	return labelRequirement(key, metav1.LabelSelectorOpNotIn, values)
+}
+
+func LabelExists(key string) metav1.LabelSelectorRequirement {
+	return labelRequirement(key, metav1.LabelSelectorOpExists, nil)
+}
+
+func LabelDoesNotExist(key string) metav1.LabelSelectorRequirement {
+	return labelRequirement(key, metav1.LabelSelectorOpDoesNotExist, nil)
+}
+
+// The values are sorted so that the same selector is always serialized the same way
+func labelRequirement(key string, op metav1.LabelSelectorOperator, values []string) metav1.LabelSelectorRequirement {
+	var sorted []string
+	if len(values) > 0 {
+		sorted = append(sorted, values...)
+		sort.Strings(sorted)
+	}
+	return metav1.LabelSelectorRequirement{Key: key, Operator: op, Values: sorted}
+}
+
+// ParseLabelSelector parses a selector in the same format that kubectl uses, e.g.,
+// "app=nginx,tier!=frontend" or "env in (prod,staging),!canary"; the "<" and ">" operators
+// aren't supported, since they can't be expressed in a LabelSelector
+func ParseLabelSelector(selector string) (metav1.LabelSelector, error) {
+	reqs, err := labels.ParseToRequirements(selector)
+	if err != nil {
+		return metav1.LabelSelector{}, fmt.Errorf("invalid label selector: %w", err)
+	}
+
+	var sel metav1.LabelSelector
+	for _, req := range reqs {
+		values := req.Values().List()
+		switch req.Operator() {
+		case selection.Equals, selection.DoubleEquals:
+			if sel.MatchLabels == nil {
+				sel.MatchLabels = map[string]string{}
+			}
+			sel.MatchLabels[req.Key()] = values[0]
+		case selection.NotEquals, selection.NotIn:
+			sel.MatchExpressions = append(sel.MatchExpressions, LabelNotIn(req.Key(), values...))
+		case selection.In:
+			sel.MatchExpressions = append(sel.MatchExpressions, LabelIn(req.Key(), values...))
+		case selection.Exists:
+			sel.MatchExpressions = append(sel.MatchExpressions, LabelExists(req.Key()))
+		case selection.DoesNotExist:
+			sel.MatchExpressions = append(sel.MatchExpressions, LabelDoesNotExist(req.Key()))
+		default:
+			return metav1.LabelSelector{}, fmt.Errorf("invalid label selector: %q is not supported", req.Operator())
+		}
+	}
+
+	if err = ValidateLabelSelector(sel); err != nil {
+		return metav1.LabelSelector{}, err
+	}
+	return sel, nil
+}
+
+// ValidateLabelSelector checks that the selector's keys and values are valid labels, and
+// that each expression has values if (and only if) its operator needs them, since the tracer
+// can't apply invalid selectors.  The empty selector isn't allowed either, since it would
+// exclude everything from the trace.
+func ValidateLabelSelector(sel metav1.LabelSelector) error {
+	if len(sel.MatchLabels) == 0 && len(sel.MatchExpressions) == 0 {
+		return errors.New("invalid label selector: selector is empty")
+	}
+
+	errs := metav1validation.ValidateLabelSelector(
+		&sel,
+		metav1validation.LabelSelectorValidationOptions{},
+		field.NewPath("excluded_labels"),
+	)
+	if err := errs.ToAggregate(); err != nil {
+		return fmt.Errorf("invalid label selector: %w", err)
+	}
+	return nil
+}
+
+// Validate checks all of the excluded label selectors, the included and excluded kinds, and
+// that at most one of the owner filters is set
+func (o *ExportFilters) Validate() error {
+	for i, sel := range o.ExcludedLabels {
+		if err := ValidateLabelSelector(sel); err != nil {
+			return fmt.Errorf("excluded label selector %d: %w", i, err)
+		}
+	}
+	if o.GetExcludeOwnedObjects() && o.GetTopLevelOnly() {
+		return errors.New("exclude_owned_objects and top_level_only can't both be set")
+	}
+	return o.validateKinds()
+}
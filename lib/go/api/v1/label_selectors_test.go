@@ -0,0 +1,131 @@
+package v1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	cases := map[string]struct {
+		expected  metav1.LabelSelector
+		expectErr bool
+	}{
+		"app=nginx": {
+			expected: MatchLabels(map[string]string{"app": "nginx"}),
+		},
+		"app=nginx,tier!=frontend": {
+			expected: metav1.LabelSelector{
+				MatchLabels:      map[string]string{"app": "nginx"},
+				MatchExpressions: []metav1.LabelSelectorRequirement{LabelNotIn("tier", "frontend")},
+			},
+		},
+		"env in (staging,prod),!canary,team": {
+			expected: MatchExpressions(
+				LabelDoesNotExist("canary"),
+				LabelIn("env", "prod", "staging"),
+				LabelExists("team"),
+			),
+		},
+		"":          {expectErr: true},
+		"replicas>": {expectErr: true},
+		"gen>1":     {expectErr: true},
+		"app=$":     {expectErr: true},
+	}
+
+	for selector, tc := range cases {
+		t.Run(selector, func(t *testing.T) {
+			sel, err := ParseLabelSelector(selector)
+			if tc.expectErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expected, sel)
+			}
+		})
+	}
+}
+
+func TestValidateLabelSelector(t *testing.T) {
+	cases := map[string]struct {
+		sel       metav1.LabelSelector
+		expectErr bool
+	}{
+		"equality":      {sel: MatchLabels(map[string]string{"app": "nginx"})},
+		"set-based":     {sel: MatchExpressions(LabelIn("env", "prod"), LabelExists("team"))},
+		"empty":         {sel: MatchLabels(nil), expectErr: true},
+		"invalid key":   {sel: MatchLabels(map[string]string{"not a key": "nginx"}), expectErr: true},
+		"invalid value": {sel: MatchExpressions(LabelNotIn("env", "not a value")), expectErr: true},
+		"no values":     {sel: MatchExpressions(LabelIn("env")), expectErr: true},
+		"extra values": {
+			sel: MatchExpressions(metav1.LabelSelectorRequirement{
+				Key:      "team",
+				Operator: metav1.LabelSelectorOpExists,
+				Values:   []string{"foo"},
+			}),
+			expectErr: true,
+		},
+		"invalid operator": {
+			sel: MatchExpressions(metav1.LabelSelectorRequirement{
+				Key:      "env",
+				Operator: "Gt",
+				Values:   []string{"1"},
+			}),
+			expectErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateLabelSelector(tc.sel)
+			if tc.expectErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestExportFiltersValidate(t *testing.T) {
+	filters := NewExportFilters(nil, []metav1.LabelSelector{MatchLabels(map[string]string{"app": "nginx"})}, true)
+	assert.Nil(t, filters.Validate())
+
+	filters.ExcludedLabels = append(filters.ExcludedLabels, MatchExpressions(LabelIn("env")))
+	assert.NotNil(t, filters.Validate())
+}
+
+func TestExportFiltersLabelsJSON(t *testing.T) {
+	filters := NewExportFilters(
+		[]string{"kube-system"},
+		[]metav1.LabelSelector{MatchExpressions(LabelIn("env", "staging", "prod"))},
+		false,
+	)
+	data, err := json.Marshal(filters)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{
+		"excluded_namespaces": ["kube-system"],
+		"excluded_labels": [{"matchExpressions": [{"key": "env", "operator": "In", "values": ["prod", "staging"]}]}],
+		"exclude_daemonsets": false
+	}`, string(data))
+}
+
+func TestExportFiltersOwners(t *testing.T) {
+	filters := NewExportFilters(nil, nil, true)
+	filters.SetExcludeOwnedObjects(true)
+	assert.Nil(t, filters.Validate())
+
+	data, err := json.Marshal(filters)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{
+		"excluded_namespaces": null,
+		"excluded_labels": null,
+		"exclude_daemonsets": true,
+		"exclude_owned_objects": true
+	}`, string(data))
+
+	filters.SetTopLevelOnly(true)
+	assert.ErrorContains(t, filters.Validate(), "can't both be set")
+}
@@ -0,0 +1,186 @@
+package v1
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// These are the same defaults that the CRD fills in (see simulation_types.go)
+const (
+	DefaultSimulationSpeed        = 1
+	DefaultSimulationRepetitions  = 1
+	DefaultSimulationScaleFactor  = 1
+	DefaultMetricsIntervalSeconds = 15
+)
+
+var metricsQueryNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`) //nolint:gochecknoglobals
+
+// Default fills in the optional fields that have defaults, for clients that build a Simulation
+// and want to know what it'll look like once the API server has defaulted it
+func (o *SimulationSpec) Default() {
+	if o.Speed == 0 {
+		o.Speed = DefaultSimulationSpeed
+	}
+	if o.Repetitions == 0 {
+		o.Repetitions = DefaultSimulationRepetitions
+	}
+	if o.ScaleFactor == 0 {
+		o.ScaleFactor = DefaultSimulationScaleFactor
+	}
+	if o.Metrics != nil && o.Metrics.IntervalSeconds == 0 {
+		o.Metrics.IntervalSeconds = DefaultMetricsIntervalSeconds
+	}
+}
+
+// Validate does the same checks as the CRD's schema, so that clients find out about invalid
+// simulations before they're submitted; unset optional fields are allowed
+func (o *SimulationSpec) Validate() error {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if o.DriverNamespace == "" {
+		errs = append(errs, field.Required(specPath.Child("driverNamespace"), ""))
+	}
+	if o.Trace == "" {
+		errs = append(errs, field.Required(specPath.Child("trace"), ""))
+	}
+	if o.Speed < 0 {
+		errs = append(errs, field.Invalid(specPath.Child("speed"), o.Speed, "must be positive"))
+	}
+	if o.MaxDurationSeconds != nil && *o.MaxDurationSeconds < 1 {
+		errs = append(errs, field.Invalid(
+			specPath.Child("maxDurationSeconds"),
+			*o.MaxDurationSeconds,
+			"must be at least 1",
+		))
+	}
+	if o.Repetitions < 0 {
+		errs = append(errs, field.Invalid(specPath.Child("repetitions"), o.Repetitions, "must be at least 1"))
+	}
+	errs = append(errs, validateNamespaceMap(specPath.Child("namespaceMap"), o.NamespaceMap)...)
+	if o.ScaleFactor < 0 {
+		errs = append(errs, field.Invalid(specPath.Child("scaleFactor"), o.ScaleFactor, "must be positive"))
+	}
+	if o.Metrics != nil {
+		errs = append(errs, o.Metrics.validate(specPath.Child("metrics"))...)
+	}
+	errs = append(errs, validateCustomMetrics(specPath.Child("customMetrics"), o.CustomMetrics)...)
+
+	if err := errs.ToAggregate(); err != nil {
+		return fmt.Errorf("invalid simulation: %w", err)
+	}
+	return nil
+}
+
+func (o *SimulationMetricsConfig) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if o.PrometheusURL == "" {
+		errs = append(errs, field.Required(path.Child("prometheusURL"), ""))
+	} else if u, err := url.Parse(o.PrometheusURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		errs = append(errs, field.Invalid(path.Child("prometheusURL"), o.PrometheusURL, "must be an http or https URL"))
+	}
+	if o.IntervalSeconds < 0 {
+		errs = append(errs, field.Invalid(path.Child("intervalSeconds"), o.IntervalSeconds, "must be at least 1"))
+	}
+
+	names := map[string]bool{}
+	for i, query := range o.Queries {
+		queryPath := path.Child("queries").Index(i)
+		if !metricsQueryNameRegexp.MatchString(query.Name) {
+			errs = append(errs, field.Invalid(
+				queryPath.Child("name"),
+				query.Name,
+				"must start with a letter or an underscore, and contain only letters, digits, and underscores",
+			))
+		} else if names[query.Name] {
+			errs = append(errs, field.Duplicate(queryPath.Child("name"), query.Name))
+		}
+		names[query.Name] = true
+
+		if query.Query == "" {
+			errs = append(errs, field.Required(queryPath.Child("query"), ""))
+		}
+	}
+	return errs
+}
+
+// validateCustomMetrics checks that each metric has somewhere to get its values from, and that
+// no two metrics of the same type have the same name in the same namespace
+func validateCustomMetrics(path *field.Path, metrics []CustomMetric) field.ErrorList {
+	var errs field.ErrorList
+	seen := map[string]bool{}
+	for i, metric := range metrics {
+		metricPath := path.Index(i)
+		if metric.Name == "" {
+			errs = append(errs, field.Required(metricPath.Child("name"), ""))
+		} else if key := fmt.Sprintf("%s/%s/%s", metric.Type, metric.Namespace, metric.Name); seen[key] {
+			errs = append(errs, field.Duplicate(metricPath.Child("name"), metric.Name))
+		} else {
+			seen[key] = true
+		}
+
+		switch metric.Type {
+		case CustomMetricObject, CustomMetricPods, CustomMetricExternal:
+		default:
+			errs = append(errs, field.NotSupported(
+				metricPath.Child("type"),
+				metric.Type,
+				[]string{string(CustomMetricObject), string(CustomMetricPods), string(CustomMetricExternal)},
+			))
+		}
+
+		if metric.Namespace != "" {
+			if msgs := validation.IsDNS1123Label(metric.Namespace); len(msgs) > 0 {
+				errs = append(errs, field.Invalid(metricPath.Child("namespace"), metric.Namespace, strings.Join(msgs, "; ")))
+			}
+		}
+
+		if metric.FromTrace && len(metric.Values) > 0 {
+			errs = append(errs, field.Forbidden(metricPath.Child("values"), "may not be set with fromTrace"))
+		} else if !metric.FromTrace && len(metric.Values) == 0 {
+			errs = append(errs, field.Required(metricPath.Child("values"), "required unless fromTrace is set"))
+		}
+		for j, value := range metric.Values {
+			if value.OffsetSeconds < 0 {
+				errs = append(errs, field.Invalid(
+					metricPath.Child("values").Index(j).Child("offsetSeconds"),
+					value.OffsetSeconds,
+					"must be at least 0",
+				))
+			}
+		}
+	}
+	return errs
+}
+
+// validateNamespaceMap checks that both sides of the map are namespace names, and that no two
+// namespaces in the trace are replayed into the same namespace (since their objects could collide)
+func validateNamespaceMap(path *field.Path, namespaceMap map[string]string) field.ErrorList {
+	var errs field.ErrorList
+	froms := make([]string, 0, len(namespaceMap))
+	for from := range namespaceMap {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+
+	tos := map[string]bool{}
+	for _, from := range froms {
+		to := namespaceMap[from]
+		if msgs := validation.IsDNS1123Label(from); len(msgs) > 0 {
+			errs = append(errs, field.Invalid(path, from, strings.Join(msgs, "; ")))
+		}
+		if msgs := validation.IsDNS1123Label(to); len(msgs) > 0 {
+			errs = append(errs, field.Invalid(path.Key(from), to, strings.Join(msgs, "; ")))
+		} else if tos[to] {
+			errs = append(errs, field.Duplicate(path.Key(from), to))
+		}
+		tos[to] = true
+	}
+	return errs
+}
@@ -0,0 +1,196 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestSimulationSpecDefault(t *testing.T) {
+	spec := SimulationSpec{DriverNamespace: "simkube", Trace: "file:///data/trace"}
+	spec.Default()
+	assert.Equal(t, SimulationSpec{
+		DriverNamespace: "simkube",
+		Trace:           "file:///data/trace",
+		Speed:           1,
+		Repetitions:     1,
+		ScaleFactor:     1,
+	}, spec)
+
+	// Fields that are already set aren't overwritten
+	spec = SimulationSpec{Speed: 12, Repetitions: 3, Seed: lo.ToPtr(int64(0)), ScaleFactor: 0.5}
+	spec.Default()
+	assert.Equal(t, SimulationSpec{Speed: 12, Repetitions: 3, Seed: lo.ToPtr(int64(0)), ScaleFactor: 0.5}, spec)
+
+	spec = SimulationSpec{Metrics: &SimulationMetricsConfig{PrometheusURL: "http://prometheus:9090"}}
+	spec.Default()
+	assert.Equal(t, int32(15), spec.Metrics.IntervalSeconds)
+}
+
+func TestSimulationSpecValidate(t *testing.T) {
+	valid := func() SimulationSpec {
+		return SimulationSpec{DriverNamespace: "simkube", Trace: "file:///data/trace"}
+	}
+
+	cases := map[string]struct {
+		mutate      func(*SimulationSpec)
+		expectedErr string
+	}{
+		"minimal": {mutate: func(*SimulationSpec) {}},
+		"all fields": {mutate: func(spec *SimulationSpec) {
+			spec.Speed = 0.5
+			spec.MaxDurationSeconds = lo.ToPtr(int64(3600))
+			spec.Repetitions = 3
+			spec.Seed = lo.ToPtr(int64(-42))
+			spec.NamespaceMap = map[string]string{"prod": "sim-prod", "staging": "sim-staging"}
+			spec.ScaleFactor = 2.5
+			spec.Metrics = &SimulationMetricsConfig{
+				PrometheusURL:   "http://prometheus:9090",
+				IntervalSeconds: 30,
+				Queries: []MetricsQuery{
+					{Name: "pending_pods", Query: `sum(kube_pod_status_phase{phase="Pending"})`},
+				},
+			}
+			spec.CustomMetrics = []CustomMetric{
+				{
+					Name:      "queue_length",
+					Type:      CustomMetricExternal,
+					Namespace: "prod",
+					Values:    []CustomMetricValue{{OffsetSeconds: 0, Value: resource.MustParse("10")}},
+				},
+				{Name: "queue_length", Type: CustomMetricPods, FromTrace: true},
+			}
+		}},
+		"default metrics": {mutate: func(spec *SimulationSpec) {
+			spec.Metrics = &SimulationMetricsConfig{PrometheusURL: "https://prometheus:9090"}
+		}},
+		"missing trace": {
+			mutate:      func(spec *SimulationSpec) { spec.Trace = "" },
+			expectedErr: "spec.trace: Required value",
+		},
+		"negative speed": {
+			mutate:      func(spec *SimulationSpec) { spec.Speed = -1 },
+			expectedErr: "spec.speed: Invalid value: -1: must be positive",
+		},
+		"zero max duration": {
+			mutate:      func(spec *SimulationSpec) { spec.MaxDurationSeconds = lo.ToPtr(int64(0)) },
+			expectedErr: "spec.maxDurationSeconds: Invalid value: 0: must be at least 1",
+		},
+		"negative repetitions": {
+			mutate:      func(spec *SimulationSpec) { spec.Repetitions = -2 },
+			expectedErr: "spec.repetitions: Invalid value: -2: must be at least 1",
+		},
+		"negative scale factor": {
+			mutate:      func(spec *SimulationSpec) { spec.ScaleFactor = -2 },
+			expectedErr: "spec.scaleFactor: Invalid value: -2: must be positive",
+		},
+		"missing prometheus URL": {
+			mutate:      func(spec *SimulationSpec) { spec.Metrics = &SimulationMetricsConfig{} },
+			expectedErr: "spec.metrics.prometheusURL: Required value",
+		},
+		"invalid prometheus URL": {
+			mutate: func(spec *SimulationSpec) {
+				spec.Metrics = &SimulationMetricsConfig{PrometheusURL: "prometheus:9090"}
+			},
+			expectedErr: `spec.metrics.prometheusURL: Invalid value: "prometheus:9090": must be an http or https URL`,
+		},
+		"invalid query name": {
+			mutate: func(spec *SimulationSpec) {
+				spec.Metrics = &SimulationMetricsConfig{
+					PrometheusURL: "http://prometheus:9090",
+					Queries:       []MetricsQuery{{Name: "pending-pods", Query: "up"}},
+				}
+			},
+			expectedErr: `spec.metrics.queries[0].name: Invalid value: "pending-pods"`,
+		},
+		"duplicate query name": {
+			mutate: func(spec *SimulationSpec) {
+				spec.Metrics = &SimulationMetricsConfig{
+					PrometheusURL: "http://prometheus:9090",
+					Queries:       []MetricsQuery{{Name: "up", Query: "up"}, {Name: "up", Query: "up == 1"}},
+				}
+			},
+			expectedErr: `spec.metrics.queries[1].name: Duplicate value: "up"`,
+		},
+		"empty query": {
+			mutate: func(spec *SimulationSpec) {
+				spec.Metrics = &SimulationMetricsConfig{
+					PrometheusURL: "http://prometheus:9090",
+					Queries:       []MetricsQuery{{Name: "up"}},
+				}
+			},
+			expectedErr: "spec.metrics.queries[0].query: Required value",
+		},
+		"duplicate custom metric": {
+			mutate: func(spec *SimulationSpec) {
+				spec.CustomMetrics = []CustomMetric{
+					{Name: "rps", Type: CustomMetricObject, FromTrace: true},
+					{Name: "rps", Type: CustomMetricObject, FromTrace: true},
+				}
+			},
+			expectedErr: `spec.customMetrics[1].name: Duplicate value: "rps"`,
+		},
+		"invalid custom metric type": {
+			mutate: func(spec *SimulationSpec) {
+				spec.CustomMetrics = []CustomMetric{{Name: "rps", Type: "Resource", FromTrace: true}}
+			},
+			expectedErr: `spec.customMetrics[0].type: Unsupported value: "Resource"`,
+		},
+		"custom metric without values": {
+			mutate: func(spec *SimulationSpec) {
+				spec.CustomMetrics = []CustomMetric{{Name: "rps", Type: CustomMetricObject}}
+			},
+			expectedErr: "spec.customMetrics[0].values: Required value",
+		},
+		"custom metric with values and fromTrace": {
+			mutate: func(spec *SimulationSpec) {
+				spec.CustomMetrics = []CustomMetric{{
+					Name:      "rps",
+					Type:      CustomMetricObject,
+					Values:    []CustomMetricValue{{OffsetSeconds: 0, Value: resource.MustParse("1")}},
+					FromTrace: true,
+				}}
+			},
+			expectedErr: "spec.customMetrics[0].values: Forbidden",
+		},
+		"negative custom metric offset": {
+			mutate: func(spec *SimulationSpec) {
+				spec.CustomMetrics = []CustomMetric{{
+					Name:   "rps",
+					Type:   CustomMetricObject,
+					Values: []CustomMetricValue{{OffsetSeconds: -5, Value: resource.MustParse("1")}},
+				}}
+			},
+			expectedErr: "spec.customMetrics[0].values[0].offsetSeconds: Invalid value: -5",
+		},
+		"invalid namespace mapping": {
+			mutate:      func(spec *SimulationSpec) { spec.NamespaceMap = map[string]string{"prod": "Sim_Prod"} },
+			expectedErr: `spec.namespaceMap[prod]: Invalid value: "Sim_Prod"`,
+		},
+		"invalid mapped namespace": {
+			mutate:      func(spec *SimulationSpec) { spec.NamespaceMap = map[string]string{"prod/": "sim-prod"} },
+			expectedErr: `spec.namespaceMap: Invalid value: "prod/"`,
+		},
+		"namespaces mapped together": {
+			mutate: func(spec *SimulationSpec) {
+				spec.NamespaceMap = map[string]string{"prod": "sim", "staging": "sim"}
+			},
+			expectedErr: `spec.namespaceMap[staging]: Duplicate value: "sim"`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			spec := valid()
+			tc.mutate(&spec)
+			err := spec.Validate()
+			if tc.expectedErr == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
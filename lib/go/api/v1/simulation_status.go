@@ -0,0 +1,19 @@
+package v1
+
+import (
+	"fmt"
+)
+
+// Finished is true once the simulation has either succeeded or failed
+func (o *SimulationStatus) Finished() bool {
+	return o.Phase == SimulationSucceeded || o.Phase == SimulationFailed
+}
+
+// Progress is how many of the events the driver has replayed, e.g. "12/40"; it's empty until
+// the driver has reported anything
+func (o *SimulationStatus) Progress() string {
+	if o.TotalEvents == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", o.EventsReplayed, o.TotalEvents)
+}
@@ -0,0 +1,38 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulationStatus(t *testing.T) {
+	cases := map[string]struct {
+		status           SimulationStatus
+		expectedFinished bool
+		expectedProgress string
+	}{
+		"not started": {},
+		"pending":     {status: SimulationStatus{Phase: SimulationPending}},
+		"running": {
+			status:           SimulationStatus{Phase: SimulationRunning, EventsReplayed: 12, TotalEvents: 40},
+			expectedProgress: "12/40",
+		},
+		"succeeded": {
+			status:           SimulationStatus{Phase: SimulationSucceeded, EventsReplayed: 40, TotalEvents: 40},
+			expectedFinished: true,
+			expectedProgress: "40/40",
+		},
+		"failed before replaying anything": {
+			status:           SimulationStatus{Phase: SimulationFailed},
+			expectedFinished: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedFinished, tc.status.Finished())
+			assert.Equal(t, tc.expectedProgress, tc.status.Progress())
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -8,14 +9,209 @@ import (
 type SimulationSpec struct {
 	DriverNamespace string `json:"driverNamespace"`
 	Trace           string `json:"trace"`
+
+	// Speed is how much faster than real time to replay the trace (e.g., a 24-hour trace with a
+	// speed of 12 is replayed in 2 hours); pod lifetimes are scaled by the same amount.
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:ExclusiveMinimum=true
+	//+kubebuilder:default=1
+	//+optional
+	Speed float64 `json:"speed,omitempty"`
+
+	// MaxDurationSeconds stops the simulation after this many seconds (of real time), even if
+	// the trace hasn't finished replaying; by default, the simulation runs until the end of the
+	// trace.
+	//+kubebuilder:validation:Minimum=1
+	//+optional
+	MaxDurationSeconds *int64 `json:"maxDurationSeconds,omitempty"`
+
+	// Repetitions is how many times to replay the trace; each repetition starts from a clean
+	// slate, i.e., the objects left over from the previous repetition are deleted first.
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:default=1
+	//+optional
+	Repetitions int32 `json:"repetitions,omitempty"`
+
+	// Seed is the random seed for the simulation, so that experiments can be reproduced.
+	//+optional
+	Seed *int64 `json:"seed,omitempty"`
+
+	// NamespaceMap maps namespaces in the trace to the namespaces that they're replayed into,
+	// e.g., to replay a trace from the prod namespace into sim-prod; namespaces that aren't in
+	// the map are replayed into virtual-<namespace>, like always.
+	//+optional
+	NamespaceMap map[string]string `json:"namespaceMap,omitempty"`
+
+	// ScaleFactor multiplies the replica counts of the objects in the trace (and for jobs, their
+	// parallelism and completions), e.g., a scale factor of 2 replays the trace at twice the
+	// load; counts are rounded to the nearest whole number, but are never scaled down to 0.
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:ExclusiveMinimum=true
+	//+kubebuilder:default=1
+	//+optional
+	ScaleFactor float64 `json:"scaleFactor,omitempty"`
+
+	// Metrics configures the Prometheus queries that the driver runs while the simulation is
+	// running; the results are stored in a ConfigMap in the driver namespace.
+	//+optional
+	Metrics *SimulationMetricsConfig `json:"metrics,omitempty"`
+
+	// CustomMetrics are served through the custom and external metrics APIs (by sk-cloudprov)
+	// while the simulation is running, so that HPAs that scale on them work in simulations.
+	//+optional
+	CustomMetrics []CustomMetric `json:"customMetrics,omitempty"`
+
+	// Paused suspends a running simulation; while it's set, the driver doesn't replay any more
+	// events, and the lifetimes of the simulated pods are frozen.  Unsetting it picks the
+	// simulation back up where it left off; the time spent paused doesn't count towards
+	// MaxDurationSeconds.
+	//+optional
+	Paused bool `json:"paused,omitempty"`
+}
+
+// SimulationMetricsConfig says where to find Prometheus and what to ask it
+type SimulationMetricsConfig struct {
+	// PrometheusURL is the base URL of the Prometheus server, e.g. http://prometheus.monitoring:9090.
+	PrometheusURL string `json:"prometheusURL"`
+
+	// IntervalSeconds is how often to run the queries.
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:default=15
+	//+optional
+	IntervalSeconds int32 `json:"intervalSeconds,omitempty"`
+
+	// Queries are the PromQL queries to run; if there aren't any, the driver records the number of
+	// pending pods, the number of nodes, and the 99th percentile pod scheduling latency.
+	//+optional
+	Queries []MetricsQuery `json:"queries,omitempty"`
+}
+
+// MetricsQuery is a PromQL query, along with the name that its results are stored under
+type MetricsQuery struct {
+	//+kubebuilder:validation:Pattern=`^[a-zA-Z_][a-zA-Z0-9_]*$`
+	Name  string `json:"name"`
+	Query string `json:"query"`
 }
 
+//+kubebuilder:validation:Enum=Object;Pods;External
+
+// CustomMetricType is the kind of metric source that an HPA uses to refer to the metric
+type CustomMetricType string
+
+const (
+	CustomMetricObject   CustomMetricType = "Object"
+	CustomMetricPods     CustomMetricType = "Pods"
+	CustomMetricExternal CustomMetricType = "External"
+)
+
+// CustomMetric is a metric whose value changes over the course of the simulation; the values are
+// either given in the spec, or derived from the HPAs in the trace.
+type CustomMetric struct {
+	//+kubebuilder:validation:MinLength=1
+	Name string           `json:"name"`
+	Type CustomMetricType `json:"type"`
+
+	// Namespace is the namespace in the trace that the metric is served for; it's mapped to the
+	// namespace that the trace is replayed into, like the trace's objects.  By default, the
+	// metric is served in every namespace.
+	//+optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Values is the value of the metric over time; each value takes effect at its offset (in
+	// seconds of trace time) from the start of the simulation and lasts until the next one.  For
+	// Pods metrics, the value is the total over all of the matching pods, and it's split evenly
+	// between them.
+	//+optional
+	Values []CustomMetricValue `json:"values,omitempty"`
+
+	// FromTrace derives the values from the status of the HPAs in the trace that use the metric,
+	// instead of from Values.
+	//+optional
+	FromTrace bool `json:"fromTrace,omitempty"`
+}
+
+type CustomMetricValue struct {
+	//+kubebuilder:validation:Minimum=0
+	OffsetSeconds int64             `json:"offsetSeconds"`
+	Value         resource.Quantity `json:"value"`
+}
+
+//+kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed
+
+// SimulationPhase is a summary of where the simulation is in its lifecycle
+type SimulationPhase string
+
+const (
+	SimulationPending   SimulationPhase = "Pending"
+	SimulationRunning   SimulationPhase = "Running"
+	SimulationSucceeded SimulationPhase = "Succeeded"
+	SimulationFailed    SimulationPhase = "Failed"
+)
+
+// These are the types of the conditions that sk-ctrl sets on the simulation status
+const (
+	// SimulationDriverCreated is true once the driver job for the simulation has been created
+	SimulationDriverCreated = "DriverCreated"
+
+	// SimulationFinished is true once the driver job has finished; the reason is either
+	// "Succeeded" or "Failed"
+	SimulationFinished = "Finished"
+)
+
 // SimulationStatus defines the observed state of the Simulation
 type SimulationStatus struct {
+	// Phase is set by the controller, based on the status of the driver job.
+	//+optional
+	Phase SimulationPhase `json:"phase,omitempty"`
+
+	//+listType=map
+	//+listMapKey=type
+	//+optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// StartTime is when the driver job started running.
+	//+optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the driver job finished, whether or not it succeeded.
+	//+optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// EventsReplayed is how many of the trace's events the driver has replayed so far.
+	//+optional
+	EventsReplayed int64 `json:"eventsReplayed,omitempty"`
+
+	// TotalEvents is how many events the driver will replay, counting all of the repetitions.
+	//+optional
+	TotalEvents int64 `json:"totalEvents,omitempty"`
+
+	// Results is set by the driver once it has written the results of the simulation.
+	//+optional
+	Results *SimulationResults `json:"results,omitempty"`
+}
+
+// SimulationResults says where the results of the simulation are stored; the format of the
+// files is defined in lib/go/results.
+type SimulationResults struct {
+	// Location is the directory that the results are stored in, e.g. file:///data/sk-testing-results.
+	Location string `json:"location"`
+
+	// Summary is the name of the results summary file in the Location directory.
+	Summary string `json:"summary"`
+
+	// Metrics is the name of the metrics bundle in the Location directory, if the simulation
+	// collected any metrics.
+	//+optional
+	Metrics string `json:"metrics,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Paused",type=boolean,JSONPath=`.spec.paused`
+//+kubebuilder:printcolumn:name="Replayed",type=integer,JSONPath=`.status.eventsReplayed`
+//+kubebuilder:printcolumn:name="Total",type=integer,JSONPath=`.status.totalEvents`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 //+kubebuilder:resource:shortName={sim,sims,simulation,simulations},scope=Cluster
 
 // Simulation is the Schema for the simulations API
@@ -0,0 +1,224 @@
+package audit
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+	maxQueuedEvents      = 8192
+)
+
+type Action string
+
+// These are the lifecycle decisions that the simulated components make
+const (
+	PodCreated           Action = "PodCreated"
+	PodRejected          Action = "PodRejected"
+	PodTerminated        Action = "PodTerminated"
+	PodDeleted           Action = "PodDeleted"
+	NodeCreated          Action = "NodeCreated"
+	NodeDeleted          Action = "NodeDeleted"
+	NodeConditionChanged Action = "NodeConditionChanged"
+	NodeCrashed          Action = "NodeCrashed"
+	NodeTerminated       Action = "NodeTerminated"
+	NodeGroupScaledUp    Action = "NodeGroupScaledUp"
+	NodeGroupScaledDown  Action = "NodeGroupScaledDown"
+)
+
+// An Event records a single lifecycle decision; Object is the namespace/name of the pod, or the
+// name of the node or node group, that the decision was about
+type Event struct {
+	Time       time.Time         `json:"time"`
+	Action     Action            `json:"action"`
+	Component  string            `json:"component,omitempty"`
+	Node       string            `json:"node,omitempty"`
+	Simulation string            `json:"simulation,omitempty"`
+	Object     string            `json:"object"`
+	Reason     string            `json:"reason,omitempty"`
+	Details    map[string]string `json:"details,omitempty"`
+}
+
+// Options configures where the audit events go; if Sink is empty, nothing is recorded.  The
+// component, node, and simulation are added to every event that doesn't have its own.
+type Options struct {
+	Sink       string
+	Component  string
+	Node       string
+	Simulation string
+
+	// Events are written in batches of up to BatchSize, at least every FlushInterval
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+type recorder struct {
+	sink          Sink
+	opts          Options
+	flushInterval time.Duration
+
+	mutex   sync.Mutex
+	events  []Event
+	dropped int
+
+	flushNow chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+//nolint:gochecknoglobals
+var (
+	recorderMutex  sync.Mutex
+	activeRecorder *recorder
+)
+
+// Setup starts recording audit events to the sink (replacing any previous one), and returns a
+// function that writes out everything that's still queued and closes the sink.  Recording is
+// asynchronous, so that the simulated components never wait for the sink.
+func Setup(opts Options) (func(), error) {
+	var rec *recorder
+	if opts.Sink != "" {
+		sink, err := NewSink(opts.Sink)
+		if err != nil {
+			return nil, err
+		}
+
+		rec = &recorder{
+			sink:          sink,
+			opts:          opts,
+			flushInterval: opts.FlushInterval,
+			flushNow:      make(chan struct{}, 1),
+			stop:          make(chan struct{}),
+			stopped:       make(chan struct{}),
+		}
+		if rec.opts.BatchSize <= 0 {
+			rec.opts.BatchSize = defaultBatchSize
+		}
+		if rec.flushInterval <= 0 {
+			rec.flushInterval = defaultFlushInterval
+		}
+	}
+
+	recorderMutex.Lock()
+	defer recorderMutex.Unlock()
+	if activeRecorder != nil {
+		activeRecorder.shutdown()
+	}
+
+	activeRecorder = rec
+	if rec == nil {
+		return func() {}, nil
+	}
+	go rec.run()
+	return rec.shutdown, nil
+}
+
+// SetNode sets the node on the events that are recorded from now on, for components that don't
+// know it when the audit sink is set up (i.e., sk-vnode); it does nothing if events aren't
+// being recorded
+func SetNode(node string) {
+	recorderMutex.Lock()
+	defer recorderMutex.Unlock()
+	if activeRecorder == nil {
+		return
+	}
+
+	activeRecorder.mutex.Lock()
+	defer activeRecorder.mutex.Unlock()
+	activeRecorder.opts.Node = node
+}
+
+// Record queues the event for the audit sink; the time is set to now if it's empty.  It does
+// nothing if events aren't being recorded.
+func Record(event Event) {
+	recorderMutex.Lock()
+	rec := activeRecorder
+	recorderMutex.Unlock()
+	if rec == nil {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Time = event.Time.UTC()
+
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	if event.Component == "" {
+		event.Component = rec.opts.Component
+	}
+	if event.Node == "" {
+		event.Node = rec.opts.Node
+	}
+	if event.Simulation == "" {
+		event.Simulation = rec.opts.Simulation
+	}
+
+	if len(rec.events) >= maxQueuedEvents {
+		rec.events = rec.events[1:]
+		rec.dropped++
+	}
+	rec.events = append(rec.events, event)
+	if len(rec.events) >= rec.opts.BatchSize {
+		select {
+		case rec.flushNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (self *recorder) run() {
+	defer close(self.stopped)
+	ticker := time.NewTicker(self.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-self.stop:
+			self.flush()
+			if err := self.sink.Close(); err != nil {
+				log.WithError(err).Warn("could not close audit sink")
+			}
+			return
+		case <-ticker.C:
+			self.flush()
+		case <-self.flushNow:
+			self.flush()
+		}
+	}
+}
+
+func (self *recorder) shutdown() {
+	self.stopOnce.Do(func() { close(self.stop) })
+	<-self.stopped
+}
+
+// flush writes out everything that's queued; the events are taken off the queue first, so that
+// recording doesn't wait for the sink
+func (self *recorder) flush() {
+	self.mutex.Lock()
+	events := self.events
+	self.events = nil
+	dropped := self.dropped
+	self.dropped = 0
+	self.mutex.Unlock()
+
+	if dropped > 0 {
+		log.Warnf("dropped %d audit events that couldn't be written in time", dropped)
+	}
+	for start := 0; start < len(events); start += self.opts.BatchSize {
+		end := start + self.opts.BatchSize
+		if end > len(events) {
+			end = len(events)
+		}
+		if err := self.sink.Write(events[start:end]); err != nil {
+			log.WithError(err).Warnf("could not write %d audit events", end-start)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"simkube/lib/go/util"
+)
+
+func readEvents(t *testing.T, path string) []Event {
+	f, err := os.Open(path)
+	require.Nil(t, err)
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		require.Nil(t, json.Unmarshal(scanner.Bytes(), &e))
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestRecordToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "events.jsonl")
+	flush, err := Setup(Options{Sink: "file://" + path, Component: "sk-vnode", Node: "node-1"})
+	require.Nil(t, err)
+
+	endTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	Record(Event{Action: PodCreated, Object: "default/foo"})
+	SetNode("node-2")
+	Record(Event{Time: endTime, Action: PodTerminated, Object: "default/foo", Reason: "LifetimeExpired"})
+	flush()
+
+	// Nothing is recorded once the recorder is shut down
+	Record(Event{Action: PodDeleted, Object: "default/foo"})
+
+	events := readEvents(t, path)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, PodCreated, events[0].Action)
+		assert.Equal(t, "sk-vnode", events[0].Component)
+		assert.Equal(t, "node-1", events[0].Node)
+		assert.False(t, events[0].Time.IsZero())
+		assert.Equal(t, endTime, events[1].Time)
+		assert.Equal(t, "node-2", events[1].Node)
+		assert.Equal(t, "LifetimeExpired", events[1].Reason)
+	}
+
+	flush, err = Setup(Options{})
+	require.Nil(t, err)
+	flush()
+}
+
+func TestRecordToWebhook(t *testing.T) {
+	var mutex sync.Mutex
+	var received []Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []Event
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		received = append(received, batch...)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	flush, err := Setup(Options{Sink: srv.URL, Component: "sk-cloudprov", BatchSize: 2})
+	require.Nil(t, err)
+	for _, action := range []Action{NodeGroupScaledUp, NodeGroupScaledUp, NodeGroupScaledDown} {
+		Record(Event{Action: action, Object: "default/ng"})
+	}
+	flush()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if assert.Len(t, received, 3) {
+		assert.Equal(t, NodeGroupScaledDown, received[2].Action)
+		assert.Equal(t, "sk-cloudprov", received[2].Component)
+	}
+}
+
+func TestNewSinkUnsupported(t *testing.T) {
+	_, err := NewSink("s3://bucket/audit")
+	assert.True(t, util.IsValidation(err))
+}
@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"simkube/lib/go/util"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// A Sink writes out batches of audit events; it's only ever called from one goroutine
+type Sink interface {
+	Write([]Event) error
+	Close() error
+}
+
+// NewSink returns the sink for the given location:
+//
+//   - a file path or file:// URL appends the events to the file as JSON lines ("-" is stdout)
+//   - an http:// or https:// URL POSTs each batch of events to the URL as a JSON array
+//
+// Object stores aren't supported directly; the bucket can be mounted into the pod (e.g., with a
+// CSI driver) and used as a file, or the events can be sent to a webhook that uploads them.
+func NewSink(location string) (Sink, error) {
+	if location == "-" {
+		return &fileSink{w: bufio.NewWriter(os.Stdout)}, nil
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid audit sink %q: %w", util.ErrValidation, location, err)
+	}
+
+	switch u.Scheme {
+	case "":
+		return newFileSink(location)
+	case "file":
+		return newFileSink(u.Path)
+	case "http", "https":
+		return &webhookSink{url: location, client: &http.Client{Timeout: webhookTimeout}}, nil
+	default:
+		return nil, fmt.Errorf(
+			"%w: unsupported audit sink %q (use a file path or an http(s) URL)",
+			util.ErrValidation,
+			location,
+		)
+	}
+}
+
+type fileSink struct {
+	w *bufio.Writer
+	f *os.File
+}
+
+func newFileSink(path string) (*fileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), fs.ModeDir|0755); err != nil {
+		return nil, fmt.Errorf("could not create audit directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644) //nolint:gosec // not a secret
+	if err != nil {
+		return nil, fmt.Errorf("could not open audit file: %w", err)
+	}
+	return &fileSink{w: bufio.NewWriter(f), f: f}, nil
+}
+
+func (self *fileSink) Write(events []Event) error {
+	enc := json.NewEncoder(self.w)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("could not write audit event: %w", err)
+		}
+	}
+	return self.w.Flush() //nolint:wrapcheck // this is just a passthrough
+}
+
+func (self *fileSink) Close() error {
+	if err := self.w.Flush(); err != nil {
+		return fmt.Errorf("could not write audit events: %w", err)
+	}
+	if self.f == nil {
+		return nil
+	}
+	return self.f.Close() //nolint:wrapcheck // this is just a passthrough
+}
+
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func (self *webhookSink) Write(events []Event) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("could not encode audit events: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, self.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := self.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not send audit events: %w", err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return fmt.Errorf("could not read audit webhook response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("audit webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (self *webhookSink) Close() error {
+	return nil
+}
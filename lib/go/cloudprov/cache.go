@@ -0,0 +1,177 @@
+package cloudprov
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/util"
+)
+
+const (
+	informerResyncPeriod = 0
+	nodeGroupIndex       = "nodeGroup"
+)
+
+var errorInformersNotStarted = util.WithKind(util.ErrRetriable, errors.New("informers have not been started"))
+
+type nodeGroupLister struct {
+	fleet    *Fleet
+	resource schema.GroupVersionResource
+	lister   cache.GenericLister
+}
+
+// Start runs the informers that back the node group cache; instead of listing every node
+// group and node from the apiserver each time Cluster Autoscaler calls Refresh, we watch
+// them and rebuild the node groups from the informer caches.  Every replica runs the
+// informers, but only the leader creates the managed node groups (see ApplyManagedNodeGroups);
+// they show up in the caches once they're created.
+func (self *SimkubeCloudProvider) Start(ctx context.Context) error {
+	var nodeGroupFactories []dynamicinformer.DynamicSharedInformerFactory
+	var listers []nodeGroupLister
+	fleets := self.fleets()
+	for i := range fleets {
+		fleet := &fleets[i]
+		for _, namespace := range self.fleetNamespaces(fleet) {
+			nodeGroupFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(
+				self.dynamicClient,
+				informerResyncPeriod,
+				namespace,
+				func(opts *metav1.ListOptions) { opts.LabelSelector = fleet.Selector },
+			)
+			nodeGroupFactories = append(nodeGroupFactories, nodeGroupFactory)
+
+			for _, resource := range self.nodeGroupResources() {
+				lister := nodeGroupFactory.ForResource(resource).Lister()
+				listers = append(listers, nodeGroupLister{fleet, resource, lister})
+			}
+		}
+	}
+
+	// We only care about virtual nodes, so only nodes that belong to a node group are cached
+	nodeFactory := informers.NewSharedInformerFactoryWithOptions(
+		self.k8sClient,
+		informerResyncPeriod,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = util.NodeGroupNameLabel
+		}),
+	)
+	nodeInformer := nodeFactory.Core().V1().Nodes().Informer()
+	if err := nodeInformer.AddIndexers(cache.Indexers{nodeGroupIndex: nodeGroupIndexFunc}); err != nil {
+		return fmt.Errorf("could not index nodes: %w", err)
+	}
+
+	for _, nodeGroupFactory := range nodeGroupFactories {
+		nodeGroupFactory.Start(ctx.Done())
+	}
+	nodeFactory.Start(ctx.Done())
+	for _, nodeGroupFactory := range nodeGroupFactories {
+		for resource, synced := range nodeGroupFactory.WaitForCacheSync(ctx.Done()) {
+			if !synced {
+				return fmt.Errorf("could not sync %s informer", resource)
+			}
+		}
+	}
+	for _, synced := range nodeFactory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return errors.New("could not sync node informer")
+		}
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.nodeGroupListers = listers
+	self.nodeIndexer = nodeInformer.GetIndexer()
+	return nil
+}
+
+// Node groups are only discovered in the watched namespaces (unless a fleet has its own), so
+// that the cloud provider doesn't need cluster-wide access to them, and so that multiple
+// simkube installations can run in the same cluster
+func (self *SimkubeCloudProvider) watchNamespaces() []string {
+	if len(self.opts.WatchNamespaces) > 0 {
+		return self.opts.WatchNamespaces
+	}
+	return []string{corev1.NamespaceAll}
+}
+
+func (self *SimkubeCloudProvider) listNodeGroups(resource nodeGroupLister) ([]*unstructured.Unstructured, error) {
+	objs, err := resource.lister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("could not list %s for fleet %s: %w", resource.resource, resource.fleet.Name, err)
+	}
+
+	nodeGroups := make([]*unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		if u, ok := obj.(*unstructured.Unstructured); ok {
+			nodeGroups = append(nodeGroups, u)
+		}
+	}
+	return nodeGroups, nil
+}
+
+func listNodeGroupNodes(nodeIndexer cache.Indexer, nodeGroupName string) ([]*corev1.Node, error) {
+	objs, err := nodeIndexer.ByIndex(nodeGroupIndex, nodeGroupName)
+	if err != nil {
+		return nil, fmt.Errorf("could not get nodes for node group %s: %w", nodeGroupName, err)
+	}
+
+	nodes := make([]*corev1.Node, 0, len(objs))
+	for _, obj := range objs {
+		if n, ok := obj.(*corev1.Node); ok {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, nil
+}
+
+// Most scalable resources keep their replica count in spec.replicas, which we can read
+// from the cache; for anything else, we have to ask the scale subresource
+func (self *SimkubeCloudProvider) nodeGroupReplicas(
+	ctx context.Context,
+	resource schema.GroupVersionResource,
+	obj *unstructured.Unstructured,
+) (int32, error) {
+	name := k8s.NamespacedName(obj.GetNamespace(), obj.GetName())
+	if replicas, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas"); err == nil && found {
+		return int32(replicas), nil
+	}
+
+	var scale *unstructured.Unstructured
+	if err := k8s.Retry(func() (err error) {
+		scale, err = self.dynamicClient.Resource(resource).Namespace(obj.GetNamespace()).
+			Get(ctx, obj.GetName(), metav1.GetOptions{}, "scale")
+		//nolint:wrapcheck // wrapped below
+		return err
+	}); err != nil {
+		return 0, fmt.Errorf("could not get scale for node group %s: %w", name, err)
+	}
+	replicas, _, err := unstructured.NestedInt64(scale.Object, "spec", "replicas")
+	if err != nil {
+		return 0, fmt.Errorf("could not get replicas for node group %s: %w", name, err)
+	}
+	return int32(replicas), nil
+}
+
+func nodeGroupIndexFunc(obj interface{}) ([]string, error) {
+	n, ok := obj.(*corev1.Node)
+	if !ok {
+		return nil, nil
+	}
+
+	name, ok := n.ObjectMeta.Labels[util.NodeGroupNameLabel]
+	if !ok {
+		return nil, nil
+	}
+	return []string{k8s.NamespacedName(n.ObjectMeta.Labels[util.NodeGroupNamespaceLabel], name)}, nil
+}
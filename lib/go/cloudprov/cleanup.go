@@ -0,0 +1,79 @@
+package cloudprov
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/util"
+)
+
+const (
+	// CleanupPolicyNone leaves the node groups alone when Cluster Autoscaler shuts down
+	CleanupPolicyNone = "none"
+
+	// CleanupPolicyMinSize scales the node groups down to their min size
+	CleanupPolicyMinSize = "min-size"
+
+	// CleanupPolicyZero scales the node groups down to zero, regardless of their min size
+	CleanupPolicyZero = "zero"
+)
+
+var errorInvalidCleanupPolicy = util.WithKind(util.ErrValidation, errors.New("invalid cleanup policy"))
+
+func validateCleanupPolicy(policy string) error {
+	switch policy {
+	case "", CleanupPolicyNone, CleanupPolicyMinSize, CleanupPolicyZero:
+		return nil
+	default:
+		return fmt.Errorf("%w %q", errorInvalidCleanupPolicy, policy)
+	}
+}
+
+// Cluster Autoscaler calls Cleanup when it shuts down; when a simulation ends, that's the
+// last chance to get rid of the virtual nodes, which would otherwise keep running (and using
+// resources) until someone deletes them.  After scaling down, the cache is cleared, so that
+// nothing reports the old node groups before the next refresh.
+func (self *SimkubeCloudProvider) Cleanup(
+	ctx context.Context,
+	_ *protos.CleanupRequest,
+) (*protos.CleanupResponse, error) {
+	if self.opts.CleanupPolicy == "" || self.opts.CleanupPolicy == CleanupPolicyNone {
+		return &protos.CleanupResponse{}, nil
+	}
+
+	// Holding the refresh lock keeps a refresh that's already running from putting the old
+	// node groups back into the cache afterwards
+	self.refreshMutex.Lock()
+	defer self.refreshMutex.Unlock()
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	var errs []error
+	for id, ng := range self.nodeGroups {
+		targetSize := int32(0)
+		if self.opts.CleanupPolicy == CleanupPolicyMinSize {
+			targetSize = ng.data.MinSize
+		}
+		if ng.targetSize <= targetSize {
+			continue
+		}
+
+		self.logger.Infof("scaling node group %s down to %d", id, targetSize)
+		namespace, name := k8s.SplitNamespacedName(id)
+		if err := ng.scaler.ScaleTo(ctx, ng.resource, namespace, name, targetSize); err != nil {
+			errs = append(errs, fmt.Errorf("could not scale node group %s: %w", id, err))
+		}
+	}
+
+	self.nodeGroups = map[string]*cachedNodeGroup{}
+	self.scaleExpectations = nil
+	self.pendingScaleUps = nil
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &protos.CleanupResponse{}, nil
+}
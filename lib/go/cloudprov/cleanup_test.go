@@ -0,0 +1,92 @@
+package cloudprov
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+)
+
+func TestCleanup(t *testing.T) {
+	cases := map[string]struct {
+		policy             string
+		minSize            int32
+		expectedTargetSize int32
+		expectedScale      bool
+	}{
+		"no policy": {},
+		"none": {
+			policy: CleanupPolicyNone,
+		},
+		"min size": {
+			policy:             CleanupPolicyMinSize,
+			minSize:            1,
+			expectedTargetSize: 1,
+			expectedScale:      true,
+		},
+		"min size already reached": {
+			policy:  CleanupPolicyMinSize,
+			minSize: 3,
+		},
+		"zero": {
+			policy:             CleanupPolicyZero,
+			minSize:            1,
+			expectedTargetSize: 0,
+			expectedScale:      true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			scalingClient := &mockScaler{}
+			if tc.expectedScale {
+				scalingClient.On(
+					"ScaleTo",
+					context.TODO(),
+					testDeploymentResource,
+					testNodeGroupNamespace,
+					testNodeGroupName,
+					tc.expectedTargetSize,
+				).Return(nil).Once()
+			}
+			skprov := fakeCloudProvider(scalingClient)
+			skprov.opts.CleanupPolicy = tc.policy
+			ng := skprov.nodeGroups[testNodeGroupFullName]
+			ng.data = &protos.NodeGroup{Id: testNodeGroupFullName, MinSize: tc.minSize, MaxSize: 13}
+			ng.targetSize = 3
+
+			resp, err := skprov.Cleanup(context.TODO(), &protos.CleanupRequest{})
+			assert.Nil(t, err)
+			assert.Equal(t, &protos.CleanupResponse{}, resp)
+			if tc.policy == "" || tc.policy == CleanupPolicyNone {
+				assert.Contains(t, skprov.nodeGroups, testNodeGroupFullName)
+			} else {
+				assert.Empty(t, skprov.nodeGroups)
+			}
+			scalingClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCleanupError(t *testing.T) {
+	scalingClient := &mockScaler{}
+	scalingClient.On("ScaleTo", mock.Anything, mock.Anything, mock.Anything, mock.Anything, int32(0)).
+		Return(errors.New("scale failed")).Once()
+	skprov := fakeCloudProvider(scalingClient)
+	skprov.opts.CleanupPolicy = CleanupPolicyZero
+
+	_, err := skprov.Cleanup(context.TODO(), &protos.CleanupRequest{})
+	assert.ErrorContains(t, err, testNodeGroupFullName)
+	assert.Empty(t, skprov.nodeGroups)
+	scalingClient.AssertExpectations(t)
+}
+
+func TestValidateCleanupPolicy(t *testing.T) {
+	for _, policy := range []string{"", CleanupPolicyNone, CleanupPolicyMinSize, CleanupPolicyZero} {
+		assert.Nil(t, validateCleanupPolicy(policy), policy)
+	}
+	assert.ErrorIs(t, validateCleanupPolicy("everything"), errorInvalidCleanupPolicy)
+}
@@ -2,75 +2,206 @@ package cloudprov
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/jonboulle/clockwork"
 	"github.com/samber/lo"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/anypb"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 
+	"simkube/lib/go/audit"
 	"simkube/lib/go/k8s"
+	"simkube/lib/go/node"
 	"simkube/lib/go/util"
 )
 
 const (
-	maxNodeGroupSize = 10
-	providerName     = "sk-cloudprov"
-	podDeletionCost  = "-9999"
+	defaultMaxNodeGroupSize = 10
+	providerName            = "sk-cloudprov"
+	podDeletionCost         = "-9999"
 )
 
-var errorUnknownNodeGroup = errors.New("unknown node group")
+var errorUnknownNodeGroup = status.Error(codes.NotFound, "unknown node group")
 
 // In _theory_, nothing is changing the node group size aside from
 // cluster autoscaler, so we can "reasonably" expect that these values
 // are correct and have not been modified externally
 type cachedNodeGroup struct {
 	data       *protos.NodeGroup
+	resource   schema.GroupVersionResource
+	scaler     Scaler
 	instances  []*protos.Instance
+	nodeCount  int32
 	targetSize int32
+
+	// These are used to build the template node for node groups that are scaled to zero
+	nodeTemplate  string
+	gpuType       string
+	instanceType  string
+	instanceShape corev1.ResourceList
+	zone          string
+	labels        map[string]string
+
+	// priority is used to configure Cluster Autoscaler's priority expander
+	priority int32
+}
+
+// Options controls the behaviour of the cloud provider; the zero value uses the defaults
+// for everything
+type Options struct {
+	// MaxNodeGroupSize is the maximum size of node groups that don't have a
+	// simkube.io/max-size annotation; if 0, the default (10) is used
+	MaxNodeGroupSize int32
+
+	// PriceTable is used to answer Cluster Autoscaler's pricing requests; if nil, the
+	// pricing methods are unimplemented
+	PriceTable *PriceTable
+
+	// GPULabel is the node label that records the GPU type; if empty, simkube.io/gpu-type
+	// is used.  GPUTypes are reported as available in addition to the GPU types of the
+	// existing node groups.
+	GPULabel string
+	GPUTypes []string
+
+	// NodeGroupResources are the kinds of objects that are treated as node groups; anything
+	// with a scale subresource (e.g., StatefulSets or a custom resource) works.  If empty,
+	// only Deployments are used.
+	NodeGroupResources []schema.GroupVersionResource
+
+	// WatchNamespaces are the namespaces that node groups are discovered in; if empty, all
+	// namespaces are watched
+	WatchNamespaces []string
+
+	// Fleets are the sets of node groups that the cloud provider manages; if empty, there's
+	// one fleet with the node group selector that the cloud provider was created with
+	Fleets []Fleet
+
+	// NodeGroupConfig declares node groups that the cloud provider creates and owns, in
+	// addition to the ones it discovers; if nil, there aren't any
+	NodeGroupConfig *NodeGroupConfig
+
+	// InstanceCreationTimeout is how long a virtual node pod can go without registering a
+	// node before it's reported to Cluster Autoscaler as a failed instance (e.g., because
+	// the pod is unschedulable or crash looping); if 0, the default (5m) is used
+	InstanceCreationTimeout time.Duration
+
+	// ProvisioningDelay is how long a scale-up takes before the node group is actually scaled,
+	// to model the latency (or capacity shortages) of a real cloud provider; if
+	// ProvisioningDelayMax is larger, the delay is chosen at random between the two
+	ProvisioningDelay    time.Duration
+	ProvisioningDelayMax time.Duration
+
+	// NodeSkeletonPath is the node skeleton (or directory of node templates) that the virtual
+	// nodes use; it's used to build template nodes for scaling up node groups that don't
+	// have any nodes.  If empty, NodeGroupTemplateNodeInfo is unimplemented.
+	NodeSkeletonPath string
+
+	// Client configures rate limiting for the cloud provider's Kubernetes clients
+	Client k8s.ClientOptions
+
+	// CleanupPolicy is what happens to the node groups when Cluster Autoscaler calls Cleanup
+	// (i.e., when it shuts down at the end of a simulation); if empty, they're left alone
+	CleanupPolicy string
+
+	// ScalingBackends are added to the built-in scaling backends (or replace them, if they
+	// have the same name); node groups pick one with the simkube.io/scaling-backend
+	// annotation
+	ScalingBackends map[string]Scaler
+
+	// PriorityExpanderNamespace is where the ConfigMap for Cluster Autoscaler's priority expander
+	// is kept in sync with the node group priorities; if empty, it isn't managed
+	PriorityExpanderNamespace string
+
+	// Only 1 in LogSampleRate of the routine messages from the read-only methods, which
+	// Cluster Autoscaler calls for every node group on every loop, are logged
+	LogSampleRate int
 }
 
 type SimkubeCloudProvider struct {
 	protos.UnimplementedCloudProviderServer
 
-	mutex sync.Mutex
+	// Cluster Autoscaler calls the read-only methods a lot (e.g., while simulating scale-ups),
+	// so they share the lock; Refresh builds the new cache without holding it, and only
+	// takes the write lock to swap the cache in at the end.
+	mutex        sync.RWMutex
+	refreshMutex sync.Mutex
+
+	k8sClient         kubernetes.Interface
+	dynamicClient     dynamic.Interface
+	scalingBackends   map[string]Scaler
+	nodeGroupSelector string
+	opts              Options
+
+	nodeGroupListers []nodeGroupLister
+	nodeIndexer      cache.Indexer
+
+	nodeGroups        map[string]*cachedNodeGroup
+	scaleExpectations map[string]*scaleExpectation
+	pendingScaleUps   map[string]int32
+	gpuTypes          map[string]bool
+	priorityConfig    string
+	clock             clockwork.Clock
+	logger            *log.Entry
+	logSampler        *util.LogSampler
+}
 
-	k8sClient          kubernetes.Interface
-	scalingClient      scalerI
-	deploymentSelector string
+func New(nodeGroupSelector string, opts Options) (*SimkubeCloudProvider, error) {
+	if err := validateCleanupPolicy(opts.CleanupPolicy); err != nil {
+		return nil, err
+	}
 
-	nodeGroups map[string]*cachedNodeGroup
-	logger     *log.Entry
-}
+	k8sClient, err := k8s.NewClient(opts.Client)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize Kubernetes client: %w", err)
+	}
 
-func New(deploymentSelector string) (*SimkubeCloudProvider, error) {
-	k8sClient, err := k8s.NewClient()
+	dynamicClient, err := k8s.NewDynamicClient(opts.Client)
 	if err != nil {
 		return nil, fmt.Errorf("could not initialize Kubernetes client: %w", err)
 	}
 
 	return &SimkubeCloudProvider{
-		k8sClient:          k8sClient,
-		scalingClient:      &scaler{k8sClient},
-		deploymentSelector: deploymentSelector,
-
-		logger: log.WithFields(log.Fields{"provider": providerName}),
+		k8sClient:         k8sClient,
+		dynamicClient:     dynamicClient,
+		scalingBackends:   newScalingBackends(k8sClient, dynamicClient, opts.ScalingBackends),
+		nodeGroupSelector: nodeGroupSelector,
+		opts:              opts,
+
+		clock:      clockwork.NewRealClock(),
+		logger:     log.WithFields(log.Fields{"provider": providerName}),
+		logSampler: util.NewLogSampler(opts.LogSampleRate),
 	}, nil
 }
 
+// requestLogger returns the logger for a gRPC request, which has the cloud provider's fields
+// along with any per-request fields that the caller put in the context (see util.WithLogger)
+func (self *SimkubeCloudProvider) requestLogger(ctx context.Context) *log.Entry {
+	return util.LoggerFromContext(ctx).WithFields(self.logger.Data)
+}
+
 func (self *SimkubeCloudProvider) NodeGroups(
 	context.Context,
 	*protos.NodeGroupsRequest, // NodeGroupsRequest is empty
 ) (*protos.NodeGroupsResponse, error) {
-	self.mutex.Lock()
-	defer self.mutex.Unlock()
-
-	self.logger.Debug("NodeGroups called")
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
 
 	ngs := lo.MapToSlice(
 		self.nodeGroups,
@@ -83,22 +214,21 @@ func (self *SimkubeCloudProvider) NodeGroupForNode(
 	ctx context.Context,
 	req *protos.NodeGroupForNodeRequest,
 ) (*protos.NodeGroupForNodeResponse, error) {
-	self.mutex.Lock()
-	defer self.mutex.Unlock()
-
-	self.logger.Debugf("NodeGroupForNode called with %s", req.Node.Name)
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
 
 	if nodeGroupName, ok := req.Node.Labels[util.NodeGroupNameLabel]; ok {
 		if nodeGroupNamespace, ok := req.Node.Labels[util.NodeGroupNamespaceLabel]; ok {
 			fullName := k8s.NamespacedName(nodeGroupNamespace, nodeGroupName)
 			if nodeGroup, ok := self.nodeGroups[fullName]; ok {
-				self.logger.Infof("found node group %s for node %s", nodeGroup.data.Id, req.Node.Name)
+				self.logSampler.Sample("NodeGroupForNode", self.requestLogger(ctx)).
+					Infof("found node group %s for node %s", nodeGroup.data.Id, req.Node.Name)
 				return &protos.NodeGroupForNodeResponse{NodeGroup: nodeGroup.data}, nil
 			}
 		}
 	}
 
-	self.logger.Warnf("No node group found for %s", req.Node.Name)
+	self.requestLogger(ctx).Warnf("No node group found for %s", req.Node.Name)
 	return &protos.NodeGroupForNodeResponse{NodeGroup: nil}, nil
 }
 
@@ -106,15 +236,16 @@ func (self *SimkubeCloudProvider) NodeGroupNodes(
 	ctx context.Context,
 	req *protos.NodeGroupNodesRequest,
 ) (*protos.NodeGroupNodesResponse, error) {
-	self.mutex.Lock()
-	defer self.mutex.Unlock()
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
 
-	logger := self.logger.WithFields(log.Fields{"nodeGroup": req.Id})
-	logger.Debugf("NodeGroupNodes called")
+	logger := self.logSampler.Sample(
+		"NodeGroupNodes/"+req.Id,
+		self.requestLogger(ctx).WithFields(log.Fields{"nodeGroup": req.Id}),
+	)
 
 	ng, ok := self.nodeGroups[req.Id]
 	if !ok {
-		logger.Error("could not find node group")
 		return nil, errorUnknownNodeGroup
 	}
 
@@ -126,15 +257,16 @@ func (self *SimkubeCloudProvider) NodeGroupTargetSize(
 	ctx context.Context,
 	req *protos.NodeGroupTargetSizeRequest,
 ) (*protos.NodeGroupTargetSizeResponse, error) {
-	self.mutex.Lock()
-	defer self.mutex.Unlock()
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
 
-	logger := self.logger.WithFields(log.Fields{"nodeGroup": req.Id})
-	logger.Debug("NodeGroupTargetSize called")
+	logger := self.logSampler.Sample(
+		"NodeGroupTargetSize/"+req.Id,
+		self.requestLogger(ctx).WithFields(log.Fields{"nodeGroup": req.Id}),
+	)
 
 	ng, ok := self.nodeGroups[req.Id]
 	if !ok {
-		logger.Error("could not find node group")
 		return nil, errorUnknownNodeGroup
 	}
 
@@ -149,22 +281,36 @@ func (self *SimkubeCloudProvider) NodeGroupIncreaseSize(
 	self.mutex.Lock()
 	defer self.mutex.Unlock()
 
-	logger := self.logger.WithFields(log.Fields{"nodeGroup": req.Id})
-	logger.Infof("NodeGroupIncreaseSize called with delta: %d", req.Delta)
+	logger := self.requestLogger(ctx).WithFields(log.Fields{"nodeGroup": req.Id})
 
 	ng, ok := self.nodeGroups[req.Id]
 	if !ok {
-		logger.Error("could not find node group")
 		return nil, errorUnknownNodeGroup
 	}
 
-	logger.Infof("increasing size: %d -> %d", ng.targetSize, ng.targetSize+req.Delta)
+	targetSize, err := increasedTargetSize(ng, req.Delta)
+	if err != nil {
+		return nil, err
+	}
+
+	if delay := self.provisioningDelay(); delay > 0 {
+		logger.Infof("increasing size: %d -> %d, provisioning in %v", ng.targetSize, targetSize, delay)
+		recordScale(audit.NodeGroupScaledUp, req.Id, ng.targetSize, targetSize, "IncreaseSize", map[string]string{
+			"provisioningDelay": delay.String(),
+		})
+		self.delayScaleUp(req.Id, ng, req.Delta, delay)
+		return &protos.NodeGroupIncreaseSizeResponse{}, nil
+	}
+
+	logger.Infof("increasing size: %d -> %d", ng.targetSize, targetSize)
 	namespace, name := k8s.SplitNamespacedName(req.Id)
-	if err := self.scalingClient.ScaleTo(ctx, namespace, name, ng.targetSize+req.Delta); err != nil {
+	appliedSize := targetSize - self.pendingScaleUps[req.Id]
+	if err := ng.scaler.ScaleTo(ctx, ng.resource, namespace, name, appliedSize); err != nil {
 		err = fmt.Errorf("could not scale node group: %w", err)
-		logger.Error(err)
 		return nil, err
 	}
+	recordScale(audit.NodeGroupScaledUp, req.Id, ng.targetSize, targetSize, "IncreaseSize", nil)
+	self.setTargetSize(req.Id, ng, targetSize)
 
 	logger.Infof("increased target size for node group to %d", ng.targetSize)
 	return &protos.NodeGroupIncreaseSizeResponse{}, nil
@@ -177,44 +323,35 @@ func (self *SimkubeCloudProvider) NodeGroupDeleteNodes(
 	self.mutex.Lock()
 	defer self.mutex.Unlock()
 
-	nodeNames := lo.Map(req.Nodes, func(n *protos.ExternalGrpcNode, _ int) string { return n.Name })
-
-	logger := self.logger.WithFields(log.Fields{"nodeGroup": req.Id})
-	logger.Infof("NodeGroupDeleteNodes called for nodes %v", nodeNames)
+	logger := self.requestLogger(ctx).WithFields(log.Fields{"nodeGroup": req.Id})
 
 	ng, ok := self.nodeGroups[req.Id]
 	if !ok {
-		logger.Error("could not find node group")
 		return nil, errorUnknownNodeGroup
 	}
 
-	delta := int32(len(req.Nodes))
+	targetSize, err := deletedNodesTargetSize(ng, len(req.Nodes))
+	if err != nil {
+		return nil, err
+	}
+
 	namespace, name := k8s.SplitNamespacedName(req.Id)
-	for _, nodeName := range nodeNames {
-		podName := k8s.NamespacedName(namespace, nodeName)
-		pod, err := self.k8sClient.CoreV1().Pods(namespace).Get(ctx, nodeName, metav1.GetOptions{})
-		if err != nil {
-			err = fmt.Errorf("could not get pod %s: %w", podName, err)
-			logger.Error(err)
-			return nil, err
-		}
-		if pod.ObjectMeta.Annotations == nil {
-			pod.ObjectMeta.Annotations = map[string]string{}
-		}
-		pod.ObjectMeta.Annotations[corev1.PodDeletionCost] = podDeletionCost
-		if _, err := self.k8sClient.CoreV1().Pods(namespace).Update(ctx, pod, metav1.UpdateOptions{}); err != nil {
-			err = fmt.Errorf("could not update pod %s: %w", podName, err)
-			logger.Error(err)
-			return nil, err
-		}
+	if err := self.deleteNodeGroupPods(ctx, ng, req.Nodes); err != nil {
+		err = fmt.Errorf("could not delete nodes: %w", err)
+		return nil, err
 	}
-	if err := self.scalingClient.ScaleTo(ctx, namespace, name, ng.targetSize-delta); err != nil {
+	appliedSize := targetSize - self.pendingScaleUps[req.Id]
+	if err := ng.scaler.ScaleTo(ctx, ng.resource, namespace, name, appliedSize); err != nil {
 		err = fmt.Errorf("could not scale node group: %w", err)
-		logger.Error(err)
 		return nil, err
 	}
+	nodeNames := lo.Map(req.Nodes, func(n *protos.ExternalGrpcNode, _ int) string { return n.Name })
+	recordScale(audit.NodeGroupScaledDown, req.Id, ng.targetSize, targetSize, "DeleteNodes", map[string]string{
+		"nodes": strings.Join(nodeNames, ","),
+	})
+	self.setTargetSize(req.Id, ng, targetSize)
 
-	logger.Infof("Successfully deleted nodes; new target size: %d", ng.targetSize)
+	logger.Infof("Successfully deleted nodes %v; new target size: %d", nodeNames, ng.targetSize)
 	return &protos.NodeGroupDeleteNodesResponse{}, nil
 }
 
@@ -225,22 +362,32 @@ func (self *SimkubeCloudProvider) NodeGroupDecreaseTargetSize(
 	self.mutex.Lock()
 	defer self.mutex.Unlock()
 
-	logger := self.logger.WithFields(log.Fields{"nodeGroup": req.Id})
-	logger.Infof("NodeGroupDecreaseTargetSize called with delta: %d", req.Delta)
+	logger := self.requestLogger(ctx).WithFields(log.Fields{"nodeGroup": req.Id})
 
 	ng, ok := self.nodeGroups[req.Id]
 	if !ok {
-		logger.Error("could not find node group")
 		return nil, errorUnknownNodeGroup
 	}
 
-	namespace, name := k8s.SplitNamespacedName(req.Id)
-	if err := self.scalingClient.ScaleTo(ctx, namespace, name, ng.targetSize-req.Delta); err != nil {
-		err = fmt.Errorf("could not scale node group: %w", err)
-		logger.Error(err)
+	targetSize, err := decreasedTargetSize(ng, req.Delta)
+	if err != nil {
 		return nil, err
 	}
 
+	if cancelled := self.cancelScaleUps(req.Id, ng, -req.Delta); cancelled > 0 {
+		logger.Infof("cancelled %d pending nodes", cancelled)
+	}
+	if ng.targetSize > targetSize {
+		namespace, name := k8s.SplitNamespacedName(req.Id)
+		appliedSize := targetSize - self.pendingScaleUps[req.Id]
+		if err := ng.scaler.ScaleTo(ctx, ng.resource, namespace, name, appliedSize); err != nil {
+			err = fmt.Errorf("could not scale node group: %w", err)
+			return nil, err
+		}
+		recordScale(audit.NodeGroupScaledDown, req.Id, ng.targetSize, targetSize, "DecreaseTargetSize", nil)
+		self.setTargetSize(req.Id, ng, targetSize)
+	}
+
 	logger.Infof("Successfully reduced target size to %d", ng.targetSize)
 	return &protos.NodeGroupDecreaseTargetSizeResponse{}, nil
 }
@@ -249,94 +396,240 @@ func (self *SimkubeCloudProvider) Refresh(
 	ctx context.Context,
 	req *protos.RefreshRequest,
 ) (*protos.RefreshResponse, error) {
-	self.mutex.Lock()
-	defer self.mutex.Unlock()
+	// Only one refresh runs at a time, but the other methods can be called while it's running
+	self.refreshMutex.Lock()
+	defer self.refreshMutex.Unlock()
 
 	self.logger.Info("Refreshing node group cache")
 
-	deployments, err := self.k8sClient.AppsV1().Deployments("").List(ctx, metav1.ListOptions{
-		LabelSelector: self.deploymentSelector,
-	})
-	if err != nil {
-		err = fmt.Errorf("could not fetch node groups: %w", err)
-		self.logger.Error(err)
-		return nil, err
-	}
+	self.mutex.RLock()
+	listers, nodeIndexer := self.nodeGroupListers, self.nodeIndexer
+	self.mutex.RUnlock()
 
-	self.nodeGroups = make(map[string]*cachedNodeGroup, len(deployments.Items))
-	for _, d := range deployments.Items {
-		name := k8s.NamespacedNameFromObjectMeta(d.ObjectMeta)
+	if nodeIndexer == nil {
+		return nil, errorInformersNotStarted
+	}
 
-		nodes, err := self.k8sClient.CoreV1().Nodes().List(
-			ctx,
-			metav1.ListOptions{LabelSelector: fmt.Sprintf(
-				"%s=%s,%s=%s",
-				util.NodeGroupNamespaceLabel,
-				d.ObjectMeta.Namespace,
-				util.NodeGroupNameLabel,
-				d.ObjectMeta.Name,
-			)},
-		)
+	nodeGroups := map[string]*cachedNodeGroup{}
+	gpuTypes := lo.SliceToMap(self.opts.GPUTypes, func(t string) (string, bool) { return t, true })
+	for _, resource := range listers {
+		objs, err := self.listNodeGroups(resource)
 		if err != nil {
-			err = fmt.Errorf("could not get nodes for node group: %w", err)
-			self.logger.Error(err)
+			err = fmt.Errorf("could not fetch node groups: %w", err)
 			return nil, err
 		}
 
-		instances := make([]*protos.Instance, len(nodes.Items))
-		for i, n := range nodes.Items {
-			instances[i] = &protos.Instance{
-				Id:     n.Spec.ProviderID,
-				Status: nodeStatusToInstanceStatus(n.Status),
+		for _, obj := range objs {
+			err := self.refreshNodeGroup(ctx, nodeIndexer, resource, obj, nodeGroups, gpuTypes)
+			if err != nil {
+				return nil, err
 			}
 		}
+	}
 
-		self.nodeGroups[name] = &cachedNodeGroup{
-			data: &protos.NodeGroup{
-				Id:      name,
-				MinSize: 0,
-				MaxSize: maxNodeGroupSize,
-			},
-			instances:  instances,
-			targetSize: *d.Spec.Replicas,
+	if self.opts.PriorityExpanderNamespace != "" {
+		if err := self.updatePriorityExpander(ctx, nodeGroups); err != nil {
+			self.logger.WithError(err).Warn("could not update the priority expander config, will retry")
 		}
 	}
 
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.reconcileTargetSizes(nodeGroups)
+	self.nodeGroups, self.gpuTypes = nodeGroups, gpuTypes
+
 	self.logger.Infof("found the following node groups: %v", self.nodeGroups)
 	return &protos.RefreshResponse{}, nil
 }
 
-func (self *SimkubeCloudProvider) Cleanup(context.Context, *protos.CleanupRequest) (*protos.CleanupResponse, error) {
-	self.logger.Info("Cleanup called")
+// Node groups are identified by <namespace>/<name>, since that's all that the virtual nodes
+// record about their node group; if two kinds of node group (or two fleets) share a name,
+// only the first one is used.
+func (self *SimkubeCloudProvider) refreshNodeGroup(
+	ctx context.Context,
+	nodeIndexer cache.Indexer,
+	source nodeGroupLister,
+	obj *unstructured.Unstructured,
+	nodeGroups map[string]*cachedNodeGroup,
+	gpuTypes map[string]bool,
+) error {
+	name := k8s.NamespacedName(obj.GetNamespace(), obj.GetName())
+	resource := source.resource
+	if ng, ok := nodeGroups[name]; ok {
+		self.logger.Warnf("node group %s (%s) is already defined by %s, ignoring", name, resource, ng.resource)
+		return nil
+	}
+
+	if gpuType, ok := obj.GetAnnotations()[util.GPUTypeAnnotation]; ok {
+		gpuTypes[gpuType] = true
+	}
+
+	replicas, err := self.nodeGroupReplicas(ctx, resource, obj)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := listNodeGroupNodes(nodeIndexer, name)
+	if err != nil {
+		return err
+	}
+
+	instances := make([]*protos.Instance, len(nodes))
+	for i, n := range nodes {
+		if gpuType, ok := n.ObjectMeta.Labels[self.gpuLabel()]; ok {
+			gpuTypes[gpuType] = true
+		}
+
+		instances[i] = &protos.Instance{
+			Id:     n.Spec.ProviderID,
+			Status: nodeStatusToInstanceStatus(n.Status),
+		}
+	}
+
+	failed, err := self.failedInstances(ctx, obj, nodes, replicas)
+	if err != nil {
+		return fmt.Errorf("could not check instances for node group %s: %w", name, err)
+	}
+	instances = append(instances, failed...)
+
+	annotations := obj.GetAnnotations()
+	instanceShape, err := node.InstanceShape(
+		annotations[util.InstanceCPUAnnotation],
+		annotations[util.InstanceMemoryAnnotation],
+	)
+	if err != nil {
+		self.logger.WithError(err).Warnf("invalid instance shape for node group %s, ignoring", name)
+	}
+
+	var nodeLabels map[string]string
+	if spec, ok := annotations[util.NodeLabelsAnnotation]; ok {
+		if nodeLabels, err = node.ParseNodeLabels(spec); err != nil {
+			self.logger.WithError(err).Warnf("invalid node labels for node group %s, ignoring", name)
+		}
+	}
+
+	minSize, maxSize := self.nodeGroupSizeBounds(obj, source.fleet)
+	priority := self.nodeGroupIntAnnotation(obj, util.NodeGroupPriorityAnnotation, 0, math.MinInt32)
+	nodeGroups[name] = &cachedNodeGroup{
+		data: &protos.NodeGroup{
+			Id:      name,
+			MinSize: minSize,
+			MaxSize: maxSize,
+			Debug:   nodeGroupDebug(annotations, nodeLabels, priority),
+		},
+		resource:   resource,
+		scaler:     self.nodeGroupScaler(resource, obj),
+		instances:  instances,
+		nodeCount:  int32(len(nodes)),
+		targetSize: replicas,
+
+		nodeTemplate:  annotations[util.NodeTemplateAnnotation],
+		gpuType:       annotations[util.GPUTypeAnnotation],
+		instanceType:  annotations[util.InstanceTypeAnnotation],
+		instanceShape: instanceShape,
+		zone:          annotations[util.ZoneAnnotation],
+		labels:        nodeLabels,
+		priority:      priority,
+	}
+	return nil
+}
+
+// Cluster Autoscaler logs the debug string of each node group when it considers scaling it, so
+// it includes everything that sets the node groups apart
+func nodeGroupDebug(annotations map[string]string, nodeLabels map[string]string, priority int32) string {
+	fields := []string{fmt.Sprintf("priority=%d", priority)}
+	for _, key := range []string{util.InstanceTypeAnnotation, util.ZoneAnnotation, util.GPUTypeAnnotation} {
+		if value, ok := annotations[key]; ok {
+			fields = append(fields, fmt.Sprintf("%s=%s", strings.TrimPrefix(key, "simkube.io/"), value))
+		}
+	}
+	if len(nodeLabels) > 0 {
+		fields = append(fields, fmt.Sprintf("labels=%s", labels.Set(nodeLabels)))
+	}
+	return strings.Join(fields, " ")
+}
 
-	return &protos.CleanupResponse{}, nil
+func (self *SimkubeCloudProvider) nodeGroupResources() []schema.GroupVersionResource {
+	if len(self.opts.NodeGroupResources) > 0 {
+		return self.opts.NodeGroupResources
+	}
+	return []schema.GroupVersionResource{appsv1.SchemeGroupVersion.WithResource("deployments")}
 }
 
 func (self *SimkubeCloudProvider) GPULabel(context.Context, *protos.GPULabelRequest) (*protos.GPULabelResponse, error) {
-	self.logger.Debug("GPULabel called")
-
-	return &protos.GPULabelResponse{Label: "simkube.io/notimplemented"}, nil
+	return &protos.GPULabelResponse{Label: self.gpuLabel()}, nil
 }
 
 func (self *SimkubeCloudProvider) GetAvailableGPUTypes(
 	context.Context,
 	*protos.GetAvailableGPUTypesRequest,
 ) (*protos.GetAvailableGPUTypesResponse, error) {
-	self.logger.Debug("GetAvailableGPUTypes called")
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
 
-	return &protos.GetAvailableGPUTypesResponse{GpuTypes: map[string]*anypb.Any{}}, nil
+	// Cluster Autoscaler only looks at the GPU type names, so the values are left empty
+	gpuTypes := make(map[string]*anypb.Any, len(self.gpuTypes))
+	for gpuType := range self.gpuTypes {
+		gpuTypes[gpuType] = &anypb.Any{}
+	}
+	return &protos.GetAvailableGPUTypesResponse{GpuTypes: gpuTypes}, nil
+}
+
+func (self *SimkubeCloudProvider) gpuLabel() string {
+	if self.opts.GPULabel != "" {
+		return self.opts.GPULabel
+	}
+	return util.GPULabel
 }
 
 func (self *SimkubeCloudProvider) NodeGroupGetOptions(
 	_ context.Context,
 	req *protos.NodeGroupAutoscalingOptionsRequest,
 ) (*protos.NodeGroupAutoscalingOptionsResponse, error) {
-	logger := self.logger.WithFields(log.Fields{"nodeGroup": req.Id})
-	logger.Debug("NodeGroupGetOptions called")
-
 	return &protos.NodeGroupAutoscalingOptionsResponse{NodeGroupAutoscalingOptions: req.Defaults}, nil
 }
 
+// The min and max sizes can be set per node group with annotations on the node group object
+// (like the bounds of an ASG); otherwise they fall back to the bounds of the node group's fleet,
+// and then to the --max-node-group-size flag and 0.
+func (self *SimkubeCloudProvider) nodeGroupSizeBounds(obj metav1.Object, fleet *Fleet) (int32, int32) {
+	defaultMaxSize := fleet.MaxNodeGroupSize
+	if defaultMaxSize <= 0 {
+		defaultMaxSize = self.opts.MaxNodeGroupSize
+	}
+	if defaultMaxSize <= 0 {
+		defaultMaxSize = defaultMaxNodeGroupSize
+	}
+
+	minSize := self.nodeGroupIntAnnotation(obj, util.NodeGroupMinSizeAnnotation, fleet.MinNodeGroupSize, 0)
+	maxSize := self.nodeGroupIntAnnotation(obj, util.NodeGroupMaxSizeAnnotation, defaultMaxSize, 0)
+	if minSize > maxSize {
+		self.logger.Warnf(
+			"min size %d is larger than max size %d for node group %s, using 0",
+			minSize, maxSize, k8s.NamespacedName(obj.GetNamespace(), obj.GetName()),
+		)
+		minSize = 0
+	}
+	return minSize, maxSize
+}
+
+func (self *SimkubeCloudProvider) nodeGroupIntAnnotation(obj metav1.Object, key string, def, minValue int32) int32 {
+	value, ok := obj.GetAnnotations()[key]
+	if !ok {
+		return def
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || parsed < int64(minValue) {
+		self.logger.Warnf(
+			"invalid %s annotation %q on node group %s, using %d",
+			key, value, k8s.NamespacedName(obj.GetNamespace(), obj.GetName()), def,
+		)
+		return def
+	}
+	return int32(parsed)
+}
+
 func nodeStatusToInstanceStatus(s corev1.NodeStatus) *protos.InstanceStatus {
 	var is protos.InstanceStatus_InstanceState
 	switch s.Phase {
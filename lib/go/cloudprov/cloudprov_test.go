@@ -3,14 +3,24 @@ package cloudprov
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/jonboulle/clockwork"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
 
 	"simkube/lib/go/k8s"
 	"simkube/lib/go/testutils"
@@ -22,40 +32,75 @@ const (
 	testDeploymentLabelValue = "fake"
 	testNodeGroupNamespace   = "testing"
 	testNodeGroupName        = "simkube-node-group"
+	testReplicaSetName       = "simkube-node-group-abcd"
 	testNodeName             = "simkube-node-group-1234"
 )
 
 //nolint:gochecknoglobals
 var (
-	testNodeGroupFullName = k8s.NamespacedName(testNodeGroupNamespace, testNodeGroupName)
-	testNodeGroup         = &protos.NodeGroup{Id: testNodeGroupFullName, MinSize: 0, MaxSize: 13}
-	testNodeProviderID    = k8s.ProviderID(testNodeName)
+	testNodeGroupFullName  = k8s.NamespacedName(testNodeGroupNamespace, testNodeGroupName)
+	testNodeGroup          = &protos.NodeGroup{Id: testNodeGroupFullName, MinSize: 0, MaxSize: 13}
+	testNodeProviderID     = k8s.ProviderID(testNodeName)
+	testDeploymentResource = appsv1.SchemeGroupVersion.WithResource("deployments")
 )
 
 type mockScaler struct {
 	mock.Mock
 }
 
-func (self *mockScaler) ScaleTo(ctx context.Context, namespace, name string, target int32) error {
-	retvals := self.Called(ctx, namespace, name, target)
+func (self *mockScaler) ScaleTo(
+	ctx context.Context,
+	resource schema.GroupVersionResource,
+	namespace, name string,
+	target int32,
+) error {
+	retvals := self.Called(ctx, resource, namespace, name, target)
 	return retvals.Error(0)
 }
 
+func testDeployment() *appsv1.Deployment {
+	replicas := int32(1)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testNodeGroupNamespace,
+			Name:      testNodeGroupName,
+			Labels:    map[string]string{testDeploymentLabelKey: testDeploymentLabelValue},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "fakeNode"}},
+			Replicas: &replicas,
+		},
+	}
+}
+
+func testStatefulSet(name string, replicas int32) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testNodeGroupNamespace,
+			Name:      name,
+			Labels:    map[string]string{testDeploymentLabelKey: testDeploymentLabelValue},
+		},
+		Spec: appsv1.StatefulSetSpec{Replicas: &replicas},
+	}
+}
+
 func fakeCloudProvider(scalingClient *mockScaler) *SimkubeCloudProvider {
 	k8sClient := fake.NewSimpleClientset()
-	replicas := int32(1)
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(scheme.Scheme, testDeployment())
 
-	if _, err := k8sClient.AppsV1().Deployments(testNodeGroupNamespace).Create(
+	isController := true
+	if _, err := k8sClient.AppsV1().ReplicaSets(testNodeGroupNamespace).Create(
 		context.TODO(),
-		&appsv1.Deployment{
+		&appsv1.ReplicaSet{
 			ObjectMeta: metav1.ObjectMeta{
 				Namespace: testNodeGroupNamespace,
-				Name:      testNodeGroupName,
-				Labels:    map[string]string{testDeploymentLabelKey: testDeploymentLabelValue},
-			},
-			Spec: appsv1.DeploymentSpec{
-				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "fakeNode"}},
-				Replicas: &replicas,
+				Name:      testReplicaSetName,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: appsv1.SchemeGroupVersion.String(),
+					Kind:       "Deployment",
+					Name:       testNodeGroupName,
+					Controller: &isController,
+				}},
 			},
 		},
 		metav1.CreateOptions{},
@@ -69,6 +114,12 @@ func fakeCloudProvider(scalingClient *mockScaler) *SimkubeCloudProvider {
 			ObjectMeta: metav1.ObjectMeta{
 				Namespace: testNodeGroupNamespace,
 				Name:      testNodeName,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: appsv1.SchemeGroupVersion.String(),
+					Kind:       "ReplicaSet",
+					Name:       testReplicaSetName,
+					Controller: &isController,
+				}},
 			},
 		},
 		metav1.CreateOptions{},
@@ -114,20 +165,51 @@ func fakeCloudProvider(scalingClient *mockScaler) *SimkubeCloudProvider {
 	}}
 
 	return &SimkubeCloudProvider{
-		k8sClient:          k8sClient,
-		scalingClient:      scalingClient,
-		deploymentSelector: "app=fake",
+		k8sClient:     k8sClient,
+		dynamicClient: dynamicClient,
+		scalingBackends: map[string]Scaler{
+			DeploymentScalingBackend:       scalingClient,
+			ScaleSubresourceScalingBackend: scalingClient,
+		},
+		nodeGroupSelector: "app=fake",
 		nodeGroups: map[string]*cachedNodeGroup{
 			testNodeGroupFullName: {
 				data:       testNodeGroup,
+				resource:   testDeploymentResource,
+				scaler:     scalingClient,
 				instances:  instances,
+				nodeCount:  int32(len(instances)),
 				targetSize: int32(len(instances)),
 			},
 		},
+		clock:  clockwork.NewFakeClock(),
 		logger: testutils.GetFakeLogger(),
 	}
 }
 
+// The informers list everything when they start, so any changes to the fake clients that
+// the test needs to see should be made before calling this
+func startInformers(t testing.TB, skprov *SimkubeCloudProvider) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := skprov.Start(ctx); err != nil {
+		panic(err)
+	}
+}
+
+func setNodeGroupAnnotations(t *testing.T, skprov *SimkubeCloudProvider, annotations map[string]string) {
+	t.Helper()
+
+	client := skprov.dynamicClient.Resource(testDeploymentResource).Namespace(testNodeGroupNamespace)
+	d, err := client.Get(context.TODO(), testNodeGroupName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	d.SetAnnotations(annotations)
+	_, err = client.Update(context.TODO(), d, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+}
+
 func makeExternalGrpcNode(namespace, name string) *protos.ExternalGrpcNode {
 	return &protos.ExternalGrpcNode{
 		ProviderID: testNodeProviderID,
@@ -218,12 +300,14 @@ func TestNodeGroupTargetSize(t *testing.T) {
 
 func TestNodeGroupIncreaseSize(t *testing.T) {
 	scalingClient := &mockScaler{}
-	scalingClient.On("ScaleTo", context.TODO(), testNodeGroupNamespace, testNodeGroupName, int32(43)).Return(nil).Once()
+	scalingClient.On(
+		"ScaleTo", context.TODO(), testDeploymentResource, testNodeGroupNamespace, testNodeGroupName, int32(13),
+	).Return(nil).Once()
 	skprov := fakeCloudProvider(scalingClient)
 
 	resp, err := skprov.NodeGroupIncreaseSize(
 		context.TODO(),
-		&protos.NodeGroupIncreaseSizeRequest{Id: testNodeGroupFullName, Delta: 42},
+		&protos.NodeGroupIncreaseSizeRequest{Id: testNodeGroupFullName, Delta: 12},
 	)
 
 	assert.Nil(t, err)
@@ -233,7 +317,9 @@ func TestNodeGroupIncreaseSize(t *testing.T) {
 
 func TestNodeGroupDeleteNodes(t *testing.T) {
 	scalingClient := &mockScaler{}
-	scalingClient.On("ScaleTo", context.TODO(), testNodeGroupNamespace, testNodeGroupName, int32(0)).Return(nil).Once()
+	scalingClient.On(
+		"ScaleTo", context.TODO(), testDeploymentResource, testNodeGroupNamespace, testNodeGroupName, int32(0),
+	).Return(nil).Once()
 	skprov := fakeCloudProvider(scalingClient)
 
 	resp, err := skprov.NodeGroupDeleteNodes(
@@ -246,12 +332,141 @@ func TestNodeGroupDeleteNodes(t *testing.T) {
 
 	assert.Nil(t, err)
 	assert.Equal(t, &protos.NodeGroupDeleteNodesResponse{}, resp)
+	_, err = skprov.k8sClient.CoreV1().Pods(testNodeGroupNamespace).
+		Get(context.TODO(), testNodeName, metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+	scalingClient.AssertExpectations(t)
+}
+
+func TestNodeGroupDeleteNodesTemplatedName(t *testing.T) {
+	scalingClient := &mockScaler{}
+	scalingClient.On(
+		"ScaleTo", context.TODO(), testDeploymentResource, testNodeGroupNamespace, testNodeGroupName, int32(0),
+	).Return(nil).Once()
+	skprov := fakeCloudProvider(scalingClient)
+
+	n := makeExternalGrpcNode(testNodeGroupNamespace, testNodeGroupName)
+	n.Name = "sim-node-0"
+	n.Annotations = map[string]string{util.PodNameAnnotation: testNodeName}
+	_, err := skprov.NodeGroupDeleteNodes(
+		context.TODO(),
+		&protos.NodeGroupDeleteNodesRequest{Id: testNodeGroupFullName, Nodes: []*protos.ExternalGrpcNode{n}},
+	)
+
+	assert.Nil(t, err)
+	_, err = skprov.k8sClient.CoreV1().Pods(testNodeGroupNamespace).
+		Get(context.TODO(), testNodeName, metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+	scalingClient.AssertExpectations(t)
+}
+
+func TestNodeGroupDeleteNodesRetries(t *testing.T) {
+	scalingClient := &mockScaler{}
+	scalingClient.On(
+		"ScaleTo", context.TODO(), testDeploymentResource, testNodeGroupNamespace, testNodeGroupName, int32(0),
+	).Return(nil).Once()
+	skprov := fakeCloudProvider(scalingClient)
+
+	// The first request for the pod fails, but the second one goes through to the fake clientset
+	fakeClient, ok := skprov.k8sClient.(*fake.Clientset)
+	if !ok {
+		panic("not a fake clientset")
+	}
+	failures := 1
+	fakeClient.PrependReactor("get", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if failures > 0 {
+			failures -= 1
+			return true, nil, apierrors.NewServiceUnavailable("try again")
+		}
+		return false, nil, nil
+	})
+
+	_, err := skprov.NodeGroupDeleteNodes(
+		context.TODO(),
+		&protos.NodeGroupDeleteNodesRequest{
+			Id:    testNodeGroupFullName,
+			Nodes: []*protos.ExternalGrpcNode{makeExternalGrpcNode(testNodeGroupNamespace, testNodeGroupName)},
+		},
+	)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, failures)
+	scalingClient.AssertExpectations(t)
+}
+
+func TestNodeGroupDeleteNodesStatefulSet(t *testing.T) {
+	statefulSetResource := appsv1.SchemeGroupVersion.WithResource("statefulsets")
+	scalingClient := &mockScaler{}
+	scalingClient.On("ScaleTo", context.TODO(), statefulSetResource, testNodeGroupNamespace, "zone-a", int32(2)).
+		Return(nil).Once()
+	skprov := fakeCloudProvider(scalingClient)
+	skprov.nodeGroups["testing/zone-a"] = &cachedNodeGroup{
+		data:       &protos.NodeGroup{Id: "testing/zone-a", MaxSize: 10},
+		resource:   statefulSetResource,
+		scaler:     scalingClient,
+		targetSize: 3,
+	}
+
+	isController := true
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: testNodeGroupNamespace,
+		Name:      "zone-a-1",
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: appsv1.SchemeGroupVersion.String(),
+			Kind:       "StatefulSet",
+			Name:       "zone-a",
+			Controller: &isController,
+		}},
+	}}
+	_, err := skprov.k8sClient.CoreV1().Pods(testNodeGroupNamespace).Create(context.TODO(), pod, metav1.CreateOptions{})
+	assert.Nil(t, err)
+
+	_, err = skprov.NodeGroupDeleteNodes(
+		context.TODO(),
+		&protos.NodeGroupDeleteNodesRequest{
+			Id:    "testing/zone-a",
+			Nodes: []*protos.ExternalGrpcNode{{Name: "zone-a-1"}},
+		},
+	)
+
+	// StatefulSet pods would just be recreated, so they're not deleted
+	assert.Nil(t, err)
+	pod, err = skprov.k8sClient.CoreV1().Pods(testNodeGroupNamespace).
+		Get(context.TODO(), "zone-a-1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, podDeletionCost, pod.ObjectMeta.Annotations[corev1.PodDeletionCost])
 	scalingClient.AssertExpectations(t)
 }
 
+func TestNodeGroupDeleteNodesWrongNodeGroup(t *testing.T) {
+	scalingClient := &mockScaler{}
+	skprov := fakeCloudProvider(scalingClient)
+	skprov.nodeGroups["testing/other"] = &cachedNodeGroup{
+		data:       &protos.NodeGroup{Id: "testing/other", MaxSize: 10},
+		resource:   testDeploymentResource,
+		scaler:     scalingClient,
+		targetSize: 1,
+	}
+
+	_, err := skprov.NodeGroupDeleteNodes(
+		context.TODO(),
+		&protos.NodeGroupDeleteNodesRequest{
+			Id:    "testing/other",
+			Nodes: []*protos.ExternalGrpcNode{makeExternalGrpcNode(testNodeGroupNamespace, "other")},
+		},
+	)
+
+	assert.NotNil(t, err)
+	_, err = skprov.k8sClient.CoreV1().Pods(testNodeGroupNamespace).
+		Get(context.TODO(), testNodeName, metav1.GetOptions{})
+	assert.Nil(t, err)
+	scalingClient.AssertNotCalled(t, "ScaleTo")
+}
+
 func TestRefresh(t *testing.T) {
 	skprov := fakeCloudProvider(nil)
 	skprov.nodeGroups = map[string]*cachedNodeGroup{}
+	startInformers(t, skprov)
 
 	_, err := skprov.Refresh(context.TODO(), &protos.RefreshRequest{})
 
@@ -264,3 +479,315 @@ func TestRefresh(t *testing.T) {
 	assert.Equal(t, testNodeProviderID, ng.instances[0].Id)
 	assert.Equal(t, protos.InstanceStatus_instanceRunning, ng.instances[0].Status.InstanceState)
 }
+
+func TestRefreshNotStarted(t *testing.T) {
+	skprov := fakeCloudProvider(nil)
+
+	_, err := skprov.Refresh(context.TODO(), &protos.RefreshRequest{})
+
+	assert.ErrorIs(t, err, errorInformersNotStarted)
+}
+
+func TestRefreshWatchesNodes(t *testing.T) {
+	skprov := fakeCloudProvider(nil)
+	startInformers(t, skprov)
+
+	_, err := skprov.k8sClient.CoreV1().Nodes().Create(
+		context.TODO(),
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "simkube-node-group-5678",
+				Labels: map[string]string{
+					util.NodeGroupNamespaceLabel: testNodeGroupNamespace,
+					util.NodeGroupNameLabel:      testNodeGroupName,
+				},
+			},
+		},
+		metav1.CreateOptions{},
+	)
+	assert.Nil(t, err)
+
+	// The new node shows up once the informer sees it, without Refresh making any API calls
+	assert.Eventually(t, func() bool {
+		if _, err := skprov.Refresh(context.TODO(), &protos.RefreshRequest{}); err != nil {
+			return false
+		}
+		return len(skprov.nodeGroups[testNodeGroupFullName].instances) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRefreshNodeGroupResources(t *testing.T) {
+	statefulSetResource := appsv1.SchemeGroupVersion.WithResource("statefulsets")
+	customResource := schema.GroupVersionResource{Group: "example.com", Version: "v1alpha1", Resource: "nodegroups"}
+	customNodeGroup := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1alpha1",
+		"kind":       "NodeGroup",
+		"metadata": map[string]interface{}{
+			"namespace": testNodeGroupNamespace,
+			"name":      "zone-b",
+			"labels":    map[string]interface{}{testDeploymentLabelKey: testDeploymentLabelValue},
+		},
+		"spec": map[string]interface{}{"replicas": int64(2)},
+	}}
+
+	skprov := fakeCloudProvider(nil)
+	skprov.opts.NodeGroupResources = []schema.GroupVersionResource{
+		testDeploymentResource,
+		statefulSetResource,
+		customResource,
+	}
+	skprov.dynamicClient = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		scheme.Scheme,
+		map[schema.GroupVersionResource]string{customResource: "NodeGroupList"},
+		testDeployment(),
+		testStatefulSet("zone-a", 3),
+		testStatefulSet(testNodeGroupName, 5), // has the same name as the Deployment, so it's ignored
+		customNodeGroup,
+	)
+	startInformers(t, skprov)
+
+	_, err := skprov.Refresh(context.TODO(), &protos.RefreshRequest{})
+	assert.Nil(t, err)
+
+	expected := map[string]struct {
+		resource   schema.GroupVersionResource
+		targetSize int32
+	}{
+		testNodeGroupFullName:                                {testDeploymentResource, 1},
+		k8s.NamespacedName(testNodeGroupNamespace, "zone-a"): {statefulSetResource, 3},
+		k8s.NamespacedName(testNodeGroupNamespace, "zone-b"): {customResource, 2},
+	}
+	assert.Len(t, skprov.nodeGroups, len(expected))
+	for name, e := range expected {
+		if assert.Contains(t, skprov.nodeGroups, name) {
+			assert.Equal(t, e.resource, skprov.nodeGroups[name].resource)
+			assert.Equal(t, e.targetSize, skprov.nodeGroups[name].targetSize)
+		}
+	}
+}
+
+func TestRefreshWatchNamespaces(t *testing.T) {
+	otherDeployment := testDeployment()
+	otherDeployment.ObjectMeta.Namespace = "other"
+
+	skprov := fakeCloudProvider(nil)
+	skprov.opts.WatchNamespaces = []string{testNodeGroupNamespace, "empty"}
+	skprov.dynamicClient = dynamicfake.NewSimpleDynamicClient(scheme.Scheme, testDeployment(), otherDeployment)
+	startInformers(t, skprov)
+
+	_, err := skprov.Refresh(context.TODO(), &protos.RefreshRequest{})
+	assert.Nil(t, err)
+	assert.Len(t, skprov.nodeGroups, 1)
+	assert.Contains(t, skprov.nodeGroups, testNodeGroupFullName)
+}
+
+func TestNodeGroupIncreaseSizeStatefulSet(t *testing.T) {
+	statefulSetResource := appsv1.SchemeGroupVersion.WithResource("statefulsets")
+	scalingClient := &mockScaler{}
+	scalingClient.On("ScaleTo", context.TODO(), statefulSetResource, testNodeGroupNamespace, "zone-a", int32(4)).
+		Return(nil).Once()
+	skprov := fakeCloudProvider(scalingClient)
+	skprov.nodeGroups["testing/zone-a"] = &cachedNodeGroup{
+		data:       &protos.NodeGroup{Id: "testing/zone-a", MaxSize: 10},
+		resource:   statefulSetResource,
+		scaler:     scalingClient,
+		targetSize: 3,
+	}
+
+	_, err := skprov.NodeGroupIncreaseSize(
+		context.TODO(),
+		&protos.NodeGroupIncreaseSizeRequest{Id: "testing/zone-a", Delta: 1},
+	)
+
+	assert.Nil(t, err)
+	scalingClient.AssertExpectations(t)
+}
+
+func TestRefreshSizeBounds(t *testing.T) {
+	cases := map[string]struct {
+		flag            int32
+		annotations     map[string]string
+		expectedMinSize int32
+		expectedMaxSize int32
+	}{
+		"default": {
+			expectedMaxSize: defaultMaxNodeGroupSize,
+		},
+		"flag": {
+			flag:            100,
+			expectedMaxSize: 100,
+		},
+		"annotations": {
+			flag: 100,
+			annotations: map[string]string{
+				util.NodeGroupMinSizeAnnotation: "3",
+				util.NodeGroupMaxSizeAnnotation: "1000",
+			},
+			expectedMinSize: 3,
+			expectedMaxSize: 1000,
+		},
+		"invalid annotations": {
+			flag: 100,
+			annotations: map[string]string{
+				util.NodeGroupMinSizeAnnotation: "-1",
+				util.NodeGroupMaxSizeAnnotation: "lots",
+			},
+			expectedMaxSize: 100,
+		},
+		"min larger than max": {
+			annotations: map[string]string{
+				util.NodeGroupMinSizeAnnotation: "5",
+				util.NodeGroupMaxSizeAnnotation: "2",
+			},
+			expectedMaxSize: 2,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			skprov := fakeCloudProvider(nil)
+			skprov.opts.MaxNodeGroupSize = tc.flag
+			if tc.annotations != nil {
+				setNodeGroupAnnotations(t, skprov, tc.annotations)
+			}
+			startInformers(t, skprov)
+
+			_, err := skprov.Refresh(context.TODO(), &protos.RefreshRequest{})
+
+			assert.Nil(t, err)
+			ng := skprov.nodeGroups[testNodeGroupFullName]
+			assert.Equal(t, tc.expectedMinSize, ng.data.MinSize)
+			assert.Equal(t, tc.expectedMaxSize, ng.data.MaxSize)
+		})
+	}
+}
+
+func TestRefreshNodeGroupMetadata(t *testing.T) {
+	cases := map[string]struct {
+		annotations      map[string]string
+		expectedLabels   map[string]string
+		expectedPriority int32
+		expectedDebug    string
+	}{
+		"default": {
+			expectedDebug: "priority=0",
+		},
+		"annotations": {
+			annotations: map[string]string{
+				util.InstanceTypeAnnotation:      "m6i.xlarge",
+				util.ZoneAnnotation:              "us-east-1/us-east-1b",
+				util.NodeLabelsAnnotation:        "team=ml,capacity-type=spot",
+				util.NodeGroupPriorityAnnotation: "20",
+			},
+			expectedLabels:   map[string]string{"team": "ml", "capacity-type": "spot"},
+			expectedPriority: 20,
+			expectedDebug:    "priority=20 instance-type=m6i.xlarge zone=us-east-1/us-east-1b labels=capacity-type=spot,team=ml",
+		},
+		"negative priority": {
+			annotations:      map[string]string{util.NodeGroupPriorityAnnotation: "-5"},
+			expectedPriority: -5,
+			expectedDebug:    "priority=-5",
+		},
+		"invalid annotations": {
+			annotations: map[string]string{
+				util.NodeLabelsAnnotation:        "spot",
+				util.NodeGroupPriorityAnnotation: "high",
+			},
+			expectedDebug: "priority=0",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			skprov := fakeCloudProvider(nil)
+			if tc.annotations != nil {
+				setNodeGroupAnnotations(t, skprov, tc.annotations)
+			}
+			startInformers(t, skprov)
+
+			_, err := skprov.Refresh(context.TODO(), &protos.RefreshRequest{})
+
+			assert.Nil(t, err)
+			ng := skprov.nodeGroups[testNodeGroupFullName]
+			assert.Equal(t, tc.expectedLabels, ng.labels)
+			assert.Equal(t, tc.expectedPriority, ng.priority)
+			assert.Equal(t, tc.expectedDebug, ng.data.Debug)
+		})
+	}
+}
+
+func TestGPULabel(t *testing.T) {
+	skprov := fakeCloudProvider(nil)
+
+	resp, err := skprov.GPULabel(context.TODO(), &protos.GPULabelRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, util.GPULabel, resp.Label)
+
+	skprov.opts.GPULabel = "k8s.amazonaws.com/accelerator"
+	resp, err = skprov.GPULabel(context.TODO(), &protos.GPULabelRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, "k8s.amazonaws.com/accelerator", resp.Label)
+}
+
+func TestGetAvailableGPUTypes(t *testing.T) {
+	skprov := fakeCloudProvider(nil)
+	skprov.opts.GPUTypes = []string{"nvidia-a100"}
+
+	setNodeGroupAnnotations(t, skprov, map[string]string{util.GPUTypeAnnotation: "nvidia-tesla-t4"})
+
+	n, _ := skprov.k8sClient.CoreV1().Nodes().Get(context.TODO(), testNodeName, metav1.GetOptions{})
+	n.ObjectMeta.Labels[util.GPULabel] = "nvidia-l4"
+	_, err := skprov.k8sClient.CoreV1().Nodes().Update(context.TODO(), n, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+	startInformers(t, skprov)
+
+	_, err = skprov.Refresh(context.TODO(), &protos.RefreshRequest{})
+	assert.Nil(t, err)
+
+	resp, err := skprov.GetAvailableGPUTypes(context.TODO(), &protos.GetAvailableGPUTypesRequest{})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"nvidia-a100", "nvidia-tesla-t4", "nvidia-l4"}, lo.Keys(resp.GpuTypes))
+}
+
+// Cluster Autoscaler makes lots of concurrent read-only calls while it simulates a scale-up,
+// and these shouldn't have to wait on each other or on a refresh; compare the results with
+// `go test -bench . -cpu 1,4,8` to see how well they run in parallel
+func BenchmarkConcurrentReads(b *testing.B) {
+	skprov := fakeCloudProvider(nil)
+	startInformers(b, skprov)
+	node := makeExternalGrpcNode(testNodeGroupNamespace, testNodeGroupName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for ctx.Err() == nil {
+			if _, err := skprov.Refresh(ctx, &protos.RefreshRequest{}); err != nil && ctx.Err() == nil {
+				panic(err)
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := skprov.NodeGroupForNode(
+				context.TODO(),
+				&protos.NodeGroupForNodeRequest{Node: node},
+			); err != nil {
+				panic(err)
+			}
+			if _, err := skprov.NodeGroupTargetSize(
+				context.TODO(),
+				&protos.NodeGroupTargetSizeRequest{Id: testNodeGroupFullName},
+			); err != nil {
+				panic(err)
+			}
+			if _, err := skprov.NodeGroupNodes(
+				context.TODO(),
+				&protos.NodeGroupNodesRequest{Id: testNodeGroupFullName},
+			); err != nil {
+				panic(err)
+			}
+		}
+	})
+}
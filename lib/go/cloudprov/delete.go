@@ -0,0 +1,141 @@
+package cloudprov
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+
+	"simkube/lib/go/k8s"
+)
+
+// Cluster Autoscaler picks the specific nodes that it wants to remove, so we delete their
+// pods directly before scaling down the node group.  The ReplicaSet controller might create
+// replacements in the meantime, but those are newer (and usually not running yet), so they're
+// the first ones to go when the ReplicaSet is scaled down.  Pods that aren't managed by a
+// ReplicaSet (e.g., StatefulSet pods) would just be recreated if we deleted them, so for those
+// we fall back to setting the pod deletion cost and letting the controller choose.
+func (self *SimkubeCloudProvider) deleteNodeGroupPods(
+	ctx context.Context,
+	ng *cachedNodeGroup,
+	nodes []*protos.ExternalGrpcNode,
+) error {
+	namespace, name := k8s.SplitNamespacedName(ng.data.Id)
+	pods := make([]*corev1.Pod, len(nodes))
+	deletable := make([]bool, len(nodes))
+	for i, n := range nodes {
+		var err error
+		if pods[i], deletable[i], err = self.nodeGroupPod(ctx, ng.resource, namespace, name, n); err != nil {
+			return err
+		}
+	}
+
+	for i, pod := range pods {
+		podName := k8s.NamespacedName(namespace, pod.ObjectMeta.Name)
+		if deletable[i] {
+			self.requestLogger(ctx).Infof("deleting pod %s for node %s", podName, nodes[i].Name)
+			if err := k8s.Retry(func() error {
+				//nolint:wrapcheck // wrapped below
+				return self.k8sClient.CoreV1().Pods(namespace).Delete(
+					ctx,
+					pod.ObjectMeta.Name,
+					metav1.DeleteOptions{Preconditions: metav1.NewUIDPreconditions(string(pod.ObjectMeta.UID))},
+				)
+			}); err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("could not delete pod %s: %w", podName, err)
+			}
+			continue
+		}
+
+		// If the update fails (e.g., because of a conflict), the pod is re-read before trying again
+		current := pod
+		if err := k8s.Retry(func() (err error) {
+			if current == nil {
+				if current, err = self.k8sClient.CoreV1().Pods(namespace).
+					Get(ctx, pod.ObjectMeta.Name, metav1.GetOptions{}); err != nil {
+					//nolint:wrapcheck // wrapped below
+					return err
+				}
+			}
+			err = self.setPodDeletionCost(ctx, current)
+			current = nil
+			return err
+		}); err != nil {
+			return fmt.Errorf("could not update pod %s: %w", podName, err)
+		}
+	}
+	return nil
+}
+
+func (self *SimkubeCloudProvider) setPodDeletionCost(ctx context.Context, pod *corev1.Pod) error {
+	if pod.ObjectMeta.Annotations[corev1.PodDeletionCost] == podDeletionCost {
+		return nil
+	}
+
+	pod = pod.DeepCopy()
+	if pod.ObjectMeta.Annotations == nil {
+		pod.ObjectMeta.Annotations = map[string]string{}
+	}
+	pod.ObjectMeta.Annotations[corev1.PodDeletionCost] = podDeletionCost
+	_, err := self.k8sClient.CoreV1().Pods(pod.ObjectMeta.Namespace).Update(ctx, pod, metav1.UpdateOptions{})
+	//nolint:wrapcheck // wrapped by the caller
+	return err
+}
+
+// The node records the name of its pod (which isn't necessarily the node name), but we have
+// to follow the pod's owner references to make sure that it actually belongs to the node group
+// before we delete it; we also return whether the pod is managed by a ReplicaSet.
+func (self *SimkubeCloudProvider) nodeGroupPod(
+	ctx context.Context,
+	resource schema.GroupVersionResource,
+	namespace, name string,
+	n *protos.ExternalGrpcNode,
+) (*corev1.Pod, bool, error) {
+	podName := externalNodePodName(n)
+	var pod *corev1.Pod
+	if err := k8s.Retry(func() (err error) {
+		pod, err = self.k8sClient.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+		//nolint:wrapcheck // wrapped below
+		return err
+	}); err != nil {
+		return nil, false, fmt.Errorf("could not get pod %s for node %s: %w", podName, n.Name, err)
+	}
+
+	owner := metav1.GetControllerOf(pod)
+	replicaSetResource := appsv1.SchemeGroupVersion.WithResource("replicasets")
+	isReplicaSet := owner != nil && isOwnedBy(owner, replicaSetResource, "ReplicaSet")
+	if isReplicaSet {
+		var rs *appsv1.ReplicaSet
+		if err := k8s.Retry(func() (err error) {
+			rs, err = self.k8sClient.AppsV1().ReplicaSets(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+			//nolint:wrapcheck // wrapped below
+			return err
+		}); err != nil {
+			return nil, false, fmt.Errorf("could not get replicaset %s for pod %s: %w", owner.Name, podName, err)
+		}
+		owner = metav1.GetControllerOf(rs)
+	}
+
+	if owner == nil || owner.Name != name || !isOwnedBy(owner, resource, "") {
+		return nil, false, fmt.Errorf(
+			"pod %s for node %s does not belong to node group %s",
+			podName, n.Name, k8s.NamespacedName(namespace, name),
+		)
+	}
+	return pod, isReplicaSet, nil
+}
+
+// Owner references only have the kind, not the resource, so we can only check the API group
+// (and the kind, if we know it)
+func isOwnedBy(owner *metav1.OwnerReference, resource schema.GroupVersionResource, kind string) bool {
+	gv, err := schema.ParseGroupVersion(owner.APIVersion)
+	if err != nil || gv.Group != resource.Group {
+		return false
+	}
+	return kind == "" || owner.Kind == kind
+}
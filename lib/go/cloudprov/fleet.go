@@ -0,0 +1,96 @@
+package cloudprov
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+
+	"simkube/lib/go/util"
+)
+
+const defaultFleetName = "default"
+
+var errorInvalidFleet = util.WithKind(util.ErrValidation, errors.New("invalid fleet"))
+
+// A Fleet is a set of node groups that are discovered with the same label selector; one
+// cloud provider can serve several fleets (e.g., simulations for different teams), each
+// in its own namespaces and with its own default node group size bounds.
+type Fleet struct {
+	Name     string `json:"name"`
+	Selector string `json:"selector"`
+
+	// Namespaces are the namespaces that the fleet's node groups are discovered in; if
+	// empty, the cloud provider's WatchNamespaces are used
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// MinNodeGroupSize and MaxNodeGroupSize are the bounds of node groups in the fleet that
+	// don't have simkube.io/min-size or simkube.io/max-size annotations; if the max size
+	// is 0, the cloud provider's MaxNodeGroupSize is used
+	MinNodeGroupSize int32 `json:"minNodeGroupSize,omitempty"`
+	MaxNodeGroupSize int32 `json:"maxNodeGroupSize,omitempty"`
+}
+
+type fleetList struct {
+	Fleets []Fleet `json:"fleets"`
+}
+
+func LoadFleets(fleetsFile string) ([]Fleet, error) {
+	fleetBytes, err := os.ReadFile(fleetsFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", fleetsFile, err)
+	}
+
+	var fl fleetList
+	if err = yaml.UnmarshalStrict(fleetBytes, &fl); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", fleetsFile, err)
+	}
+	if err = validateFleets(fl.Fleets); err != nil {
+		return nil, fmt.Errorf("could not load %s: %w", fleetsFile, err)
+	}
+	return fl.Fleets, nil
+}
+
+func validateFleets(fleets []Fleet) error {
+	names := map[string]bool{}
+	for _, f := range fleets {
+		if f.Name == "" {
+			return fmt.Errorf("%w: fleets must have a name", errorInvalidFleet)
+		} else if names[f.Name] {
+			return fmt.Errorf("%w: duplicate fleet %s", errorInvalidFleet, f.Name)
+		}
+		names[f.Name] = true
+
+		if _, err := labels.Parse(f.Selector); err != nil || f.Selector == "" {
+			return fmt.Errorf("%w: fleet %s has an invalid selector %q", errorInvalidFleet, f.Name, f.Selector)
+		}
+		if f.MinNodeGroupSize < 0 || f.MaxNodeGroupSize < 0 ||
+			(f.MaxNodeGroupSize > 0 && f.MinNodeGroupSize > f.MaxNodeGroupSize) {
+			return fmt.Errorf("%w: fleet %s has invalid node group size bounds", errorInvalidFleet, f.Name)
+		}
+	}
+	return nil
+}
+
+// If no fleets are configured, there's a single fleet made of the node groups that match
+// the selector that the cloud provider was created with; the managed node groups (if any)
+// come first, so that they win if a discovered node group has the same name
+func (self *SimkubeCloudProvider) fleets() []Fleet {
+	fleets := self.opts.Fleets
+	if len(fleets) == 0 {
+		fleets = []Fleet{{Name: defaultFleetName, Selector: self.nodeGroupSelector}}
+	}
+	if self.opts.NodeGroupConfig != nil {
+		fleets = append([]Fleet{managedFleet(self.opts.NodeGroupConfig)}, fleets...)
+	}
+	return fleets
+}
+
+func (self *SimkubeCloudProvider) fleetNamespaces(f *Fleet) []string {
+	if len(f.Namespaces) > 0 {
+		return f.Namespaces
+	}
+	return self.watchNamespaces()
+}
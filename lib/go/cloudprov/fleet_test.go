@@ -0,0 +1,108 @@
+package cloudprov
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"simkube/lib/go/k8s"
+)
+
+func TestLoadFleets(t *testing.T) {
+	fleetsFile := filepath.Join(t.TempDir(), "fleets.yml")
+	contents := "fleets:\n- name: a\n  selector: app=a\n  namespaces: [team-a]\n  maxNodeGroupSize: 20\n"
+	assert.Nil(t, os.WriteFile(fleetsFile, []byte(contents), 0o600))
+
+	fleets, err := LoadFleets(fleetsFile)
+	assert.Nil(t, err)
+	assert.Equal(t, []Fleet{{
+		Name:             "a",
+		Selector:         "app=a",
+		Namespaces:       []string{"team-a"},
+		MaxNodeGroupSize: 20,
+	}}, fleets)
+
+	assert.Nil(t, os.WriteFile(fleetsFile, []byte("fleets:\n- name: a\n  selectr: app=a\n"), 0o600))
+	_, err = LoadFleets(fleetsFile)
+	assert.NotNil(t, err)
+}
+
+func TestValidateFleets(t *testing.T) {
+	cases := map[string]struct {
+		fleets    []Fleet
+		expectErr bool
+	}{
+		"valid": {
+			fleets: []Fleet{{Name: "a", Selector: "app=a"}, {Name: "b", Selector: "app in (b, c)"}},
+		},
+		"no name": {fleets: []Fleet{{Selector: "app=a"}}, expectErr: true},
+		"duplicate name": {
+			fleets:    []Fleet{{Name: "a", Selector: "app=a"}, {Name: "a", Selector: "app=b"}},
+			expectErr: true,
+		},
+		"no selector":      {fleets: []Fleet{{Name: "a"}}, expectErr: true},
+		"invalid selector": {fleets: []Fleet{{Name: "a", Selector: "app in (a"}}, expectErr: true},
+		"negative size":    {fleets: []Fleet{{Name: "a", Selector: "app=a", MinNodeGroupSize: -1}}, expectErr: true},
+		"min larger than max": {
+			fleets:    []Fleet{{Name: "a", Selector: "app=a", MinNodeGroupSize: 5, MaxNodeGroupSize: 2}},
+			expectErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateFleets(tc.fleets)
+			if tc.expectErr {
+				assert.ErrorIs(t, err, errorInvalidFleet)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestRefreshFleets(t *testing.T) {
+	// This one matches the "b" selector but isn't in the "b" namespace
+	strayDeployment := testDeployment()
+	strayDeployment.ObjectMeta.Name = "stray"
+	strayDeployment.ObjectMeta.Labels = map[string]string{"fleet": "b"}
+
+	fleetBDeployment := testDeployment()
+	fleetBDeployment.ObjectMeta.Namespace = "team-b"
+	fleetBDeployment.ObjectMeta.Labels = map[string]string{"fleet": "b"}
+
+	skprov := fakeCloudProvider(nil)
+	skprov.opts.Fleets = []Fleet{
+		{Name: "a", Selector: "app=fake", MinNodeGroupSize: 1},
+		{Name: "b", Selector: "fleet=b", Namespaces: []string{"team-b"}, MaxNodeGroupSize: 50},
+	}
+	skprov.dynamicClient = dynamicfake.NewSimpleDynamicClient(
+		scheme.Scheme,
+		testDeployment(),
+		strayDeployment,
+		fleetBDeployment,
+	)
+	startInformers(t, skprov)
+
+	_, err := skprov.Refresh(context.TODO(), &protos.RefreshRequest{})
+	assert.Nil(t, err)
+
+	assert.Len(t, skprov.nodeGroups, 2)
+	if assert.Contains(t, skprov.nodeGroups, testNodeGroupFullName) {
+		ng := skprov.nodeGroups[testNodeGroupFullName].data
+		assert.Equal(t, int32(1), ng.MinSize)
+		assert.Equal(t, int32(defaultMaxNodeGroupSize), ng.MaxSize)
+	}
+	fleetBName := k8s.NamespacedName("team-b", testNodeGroupName)
+	if assert.Contains(t, skprov.nodeGroups, fleetBName) {
+		ng := skprov.nodeGroups[fleetBName].data
+		assert.Equal(t, int32(0), ng.MinSize)
+		assert.Equal(t, int32(50), ng.MaxSize)
+	}
+}
@@ -0,0 +1,181 @@
+package cloudprov
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/util"
+)
+
+const (
+	defaultInstanceCreationTimeout = 5 * time.Minute
+
+	// These match cloudprovider.OutOfResourcesErrorClass and cloudprovider.OtherErrorClass
+	// in Cluster Autoscaler
+	outOfResourcesErrorClass = 1
+	otherErrorClass          = 99
+
+	crashLoopBackOffReason = "CrashLoopBackOff"
+	unschedulableErrorCode = "VirtualNodeUnschedulable"
+	failedErrorCode        = "VirtualNodeFailed"
+	pendingErrorCode       = "VirtualNodePending"
+)
+
+// Cluster Autoscaler only knows that a scale-up failed if the cloud provider reports
+// instances with creation errors; otherwise it waits for --max-node-provision-time and then
+// assumes the nodes are just slow.  The virtual node pods that haven't registered a node are
+// checked for problems, but only while the node group is missing nodes, since otherwise
+// there's nothing to find (and we'd have to list pods on every refresh).
+func (self *SimkubeCloudProvider) failedInstances(
+	ctx context.Context,
+	obj *unstructured.Unstructured,
+	nodes []*corev1.Node,
+	targetSize int32,
+) ([]*protos.Instance, error) {
+	if int32(len(nodes)) >= targetSize {
+		return nil, nil
+	}
+
+	// Custom node groups might not have a pod selector, in which case we can't tell which
+	// pods belong to them
+	value, _, err := unstructured.NestedFieldNoCopy(obj.Object, "spec", "selector")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse pod selector: %w", err)
+	}
+	selector, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	var labelSelector metav1.LabelSelector
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(selector, &labelSelector); err != nil {
+		return nil, fmt.Errorf("could not parse pod selector: %w", err)
+	}
+	podSelector, err := metav1.LabelSelectorAsSelector(&labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse pod selector: %w", err)
+	}
+
+	var pods *corev1.PodList
+	if err := k8s.Retry(func() (err error) {
+		pods, err = self.k8sClient.CoreV1().Pods(obj.GetNamespace()).List(
+			ctx,
+			metav1.ListOptions{LabelSelector: podSelector.String()},
+		)
+		//nolint:wrapcheck // wrapped below
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("could not list pods: %w", err)
+	}
+
+	registered := lo.SliceToMap(nodes, func(n *corev1.Node) (string, bool) { return nodePodName(n), true })
+	timeout := self.instanceCreationTimeout()
+	now := time.Now()
+
+	var instances []*protos.Instance
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if registered[pod.ObjectMeta.Name] || pod.ObjectMeta.DeletionTimestamp != nil ||
+			now.Sub(pod.ObjectMeta.CreationTimestamp.Time) < timeout {
+			continue
+		}
+
+		if errorInfo := podErrorInfo(pod); errorInfo != nil {
+			instances = append(instances, &protos.Instance{
+				Id: k8s.ProviderID(pod.ObjectMeta.Name),
+				Status: &protos.InstanceStatus{
+					InstanceState: protos.InstanceStatus_instanceCreating,
+					ErrorInfo:     errorInfo,
+				},
+			})
+		}
+	}
+	return instances, nil
+}
+
+func (self *SimkubeCloudProvider) instanceCreationTimeout() time.Duration {
+	if self.opts.InstanceCreationTimeout > 0 {
+		return self.opts.InstanceCreationTimeout
+	}
+	return defaultInstanceCreationTimeout
+}
+
+// A virtual node pod that can't be scheduled is the simulated equivalent of the cloud
+// provider being out of capacity; anything else that keeps it from starting is reported as
+// a generic error
+func podErrorInfo(pod *corev1.Pod) *protos.InstanceErrorInfo {
+	switch pod.Status.Phase {
+	case corev1.PodFailed:
+		return &protos.InstanceErrorInfo{
+			ErrorCode:          failedErrorCode,
+			ErrorMessage:       fmt.Sprintf("virtual node pod %s failed: %s", pod.ObjectMeta.Name, pod.Status.Message),
+			InstanceErrorClass: otherErrorClass,
+		}
+	case corev1.PodPending:
+		if c, ok := lo.Find(pod.Status.Conditions, func(c corev1.PodCondition) bool {
+			return c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse
+		}); ok {
+			return &protos.InstanceErrorInfo{
+				ErrorCode: unschedulableErrorCode,
+				ErrorMessage: fmt.Sprintf(
+					"virtual node pod %s is unschedulable: %s",
+					pod.ObjectMeta.Name, c.Message,
+				),
+				InstanceErrorClass: outOfResourcesErrorClass,
+			}
+		}
+	}
+
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason != "" {
+			code := pendingErrorCode
+			if cs.State.Waiting.Reason == crashLoopBackOffReason {
+				code = crashLoopBackOffReason
+			}
+			return &protos.InstanceErrorInfo{
+				ErrorCode: code,
+				ErrorMessage: fmt.Sprintf(
+					"virtual node pod %s is not running: %s",
+					pod.ObjectMeta.Name, strings.TrimSpace(cs.State.Waiting.Reason+" "+cs.State.Waiting.Message),
+				),
+				InstanceErrorClass: otherErrorClass,
+			}
+		}
+	}
+
+	if pod.Status.Phase == corev1.PodPending {
+		return &protos.InstanceErrorInfo{
+			ErrorCode:          pendingErrorCode,
+			ErrorMessage:       fmt.Sprintf("virtual node pod %s is still pending", pod.ObjectMeta.Name),
+			InstanceErrorClass: otherErrorClass,
+		}
+	}
+	return nil
+}
+
+// The node name only matches the pod name if the virtual node doesn't use a name template
+func nodePodName(n *corev1.Node) string {
+	if name, ok := n.ObjectMeta.Annotations[util.PodNameAnnotation]; ok {
+		return name
+	}
+	return n.ObjectMeta.Name
+}
+
+// When Cluster Autoscaler deletes an instance that never registered a node, it makes up a
+// node whose name is the instance ID, i.e., simkube://<pod name>
+func externalNodePodName(n *protos.ExternalGrpcNode) string {
+	if name, ok := n.Annotations[util.PodNameAnnotation]; ok {
+		return name
+	}
+	return strings.TrimPrefix(n.Name, k8s.ProviderID(""))
+}
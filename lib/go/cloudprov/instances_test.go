@@ -0,0 +1,160 @@
+package cloudprov
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/util"
+)
+
+func TestPodErrorInfo(t *testing.T) {
+	cases := map[string]struct {
+		status        corev1.PodStatus
+		expectedCode  string
+		expectedClass int32
+	}{
+		"running": {
+			status: corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+		"unschedulable": {
+			status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				Conditions: []corev1.PodCondition{{
+					Type:    corev1.PodScheduled,
+					Status:  corev1.ConditionFalse,
+					Message: "0/3 nodes are available",
+				}},
+			},
+			expectedCode:  unschedulableErrorCode,
+			expectedClass: outOfResourcesErrorClass,
+		},
+		"crash looping": {
+			status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					State: corev1.ContainerState{
+						Waiting: &corev1.ContainerStateWaiting{Reason: crashLoopBackOffReason},
+					},
+				}},
+			},
+			expectedCode:  crashLoopBackOffReason,
+			expectedClass: otherErrorClass,
+		},
+		"image pull": {
+			status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}},
+				}},
+			},
+			expectedCode:  pendingErrorCode,
+			expectedClass: otherErrorClass,
+		},
+		"pending": {
+			status:        corev1.PodStatus{Phase: corev1.PodPending},
+			expectedCode:  pendingErrorCode,
+			expectedClass: otherErrorClass,
+		},
+		"failed": {
+			status:        corev1.PodStatus{Phase: corev1.PodFailed},
+			expectedCode:  failedErrorCode,
+			expectedClass: otherErrorClass,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			errorInfo := podErrorInfo(&corev1.Pod{Status: tc.status})
+			if tc.expectedCode == "" {
+				assert.Nil(t, errorInfo)
+			} else {
+				assert.Equal(t, tc.expectedCode, errorInfo.ErrorCode)
+				assert.Equal(t, tc.expectedClass, errorInfo.InstanceErrorClass)
+			}
+		})
+	}
+}
+
+func TestFailedInstances(t *testing.T) {
+	skprov := fakeCloudProvider(nil)
+	podLabels := testDeployment().Spec.Selector.MatchLabels
+	stuckStatus := corev1.PodStatus{
+		Phase: corev1.PodPending,
+		Conditions: []corev1.PodCondition{{
+			Type:   corev1.PodScheduled,
+			Status: corev1.ConditionFalse,
+		}},
+	}
+
+	longAgo := metav1.NewTime(time.Now().Add(-time.Hour))
+	for _, pod := range []*corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "registered", CreationTimestamp: longAgo}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "stuck", CreationTimestamp: longAgo}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "new", CreationTimestamp: metav1.Now()}},
+	} {
+		pod.ObjectMeta.Namespace = testNodeGroupNamespace
+		pod.ObjectMeta.Labels = podLabels
+		pod.Status = stuckStatus
+		_, err := skprov.k8sClient.CoreV1().Pods(testNodeGroupNamespace).
+			Create(context.TODO(), pod, metav1.CreateOptions{})
+		assert.Nil(t, err)
+	}
+
+	d, err := runtime.DefaultUnstructuredConverter.ToUnstructured(testDeployment())
+	if err != nil {
+		panic(err)
+	}
+	obj := &unstructured.Unstructured{Object: d}
+	nodes := []*corev1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "registered"}}}
+
+	// If all of the nodes are there, we don't look for stuck pods
+	instances, err := skprov.failedInstances(context.TODO(), obj, nodes, 1)
+	assert.Nil(t, err)
+	assert.Empty(t, instances)
+
+	instances, err = skprov.failedInstances(context.TODO(), obj, nodes, 3)
+	assert.Nil(t, err)
+	if assert.Len(t, instances, 1) {
+		assert.Equal(t, k8s.ProviderID("stuck"), instances[0].Id)
+		assert.Equal(t, protos.InstanceStatus_instanceCreating, instances[0].Status.InstanceState)
+		assert.Equal(t, unschedulableErrorCode, instances[0].Status.ErrorInfo.ErrorCode)
+	}
+}
+
+func TestExternalNodePodName(t *testing.T) {
+	cases := map[string]struct {
+		node     *protos.ExternalGrpcNode
+		expected string
+	}{
+		"node name": {
+			node:     &protos.ExternalGrpcNode{Name: testNodeName},
+			expected: testNodeName,
+		},
+		"templated name": {
+			node: &protos.ExternalGrpcNode{
+				Name:        "sim-node-0",
+				Annotations: map[string]string{util.PodNameAnnotation: testNodeName},
+			},
+			expected: testNodeName,
+		},
+		"unregistered instance": {
+			node:     &protos.ExternalGrpcNode{Name: k8s.ProviderID(testNodeName)},
+			expected: testNodeName,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, externalNodePodName(tc.node))
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package cloudprov
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/yaml"
+
+	"simkube/lib/go/audit"
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/karpenter"
+	"simkube/lib/go/util"
+)
+
+const (
+	nodeClaimLabel = "simkube.io/karpenter-nodeclaim"
+
+	// NodeClaims are re-checked periodically, so that a failed launch is retried, and a
+	// virtual node pod that was deleted out from under its NodeClaim comes back
+	nodeClaimResyncPeriod = time.Minute
+)
+
+var errorInvalidKarpenterConfig = util.WithKind(util.ErrValidation, errors.New("invalid Karpenter config"))
+
+// A KarpenterConfig tells the NodeClaim controller how to launch virtual nodes for Karpenter:
+// every NodeClaim gets a pod (named after the NodeClaim) built from the template.
+type KarpenterConfig struct {
+	// Namespace is where the virtual node pods are created
+	Namespace string `json:"namespace"`
+
+	// Template is the pod template for the virtual nodes (i.e., it runs sk-vnode); the NodeClaim
+	// is passed to the virtual node in the KARPENTER_NODECLAIM environment variable
+	Template corev1.PodTemplateSpec `json:"template"`
+}
+
+func LoadKarpenterConfig(configFile string) (*KarpenterConfig, error) {
+	configBytes, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", configFile, err)
+	}
+
+	var cfg KarpenterConfig
+	if err = yaml.UnmarshalStrict(configBytes, &cfg); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", configFile, err)
+	}
+	if cfg.Namespace == "" {
+		return nil, fmt.Errorf("could not load %s: %w: no namespace", configFile, errorInvalidKarpenterConfig)
+	} else if len(cfg.Template.Spec.Containers) == 0 {
+		return nil, fmt.Errorf(
+			"could not load %s: %w: pod template has no containers",
+			configFile,
+			errorInvalidKarpenterConfig,
+		)
+	}
+	return &cfg, nil
+}
+
+// The NodeClaimController stands in for the instances that a Karpenter cloud provider would
+// launch: for every NodeClaim, it assigns a provider ID (if the cloud provider didn't) and
+// starts a virtual node, which registers a node that matches the NodeClaim.  When the NodeClaim
+// is deleted, so is the virtual node.
+type NodeClaimController struct {
+	k8sClient     kubernetes.Interface
+	dynamicClient dynamic.Interface
+	cfg           *KarpenterConfig
+	logger        *log.Entry
+}
+
+func NewNodeClaimController(cfg *KarpenterConfig, clientOpts k8s.ClientOptions) (*NodeClaimController, error) {
+	k8sClient, err := k8s.NewClient(clientOpts)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize Kubernetes client: %w", err)
+	}
+
+	dynamicClient, err := k8s.NewDynamicClient(clientOpts)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize Kubernetes client: %w", err)
+	}
+
+	return newNodeClaimController(cfg, k8sClient, dynamicClient), nil
+}
+
+func newNodeClaimController(
+	cfg *KarpenterConfig,
+	k8sClient kubernetes.Interface,
+	dynamicClient dynamic.Interface,
+) *NodeClaimController {
+	return &NodeClaimController{
+		k8sClient:     k8sClient,
+		dynamicClient: dynamicClient,
+		cfg:           cfg,
+		logger:        log.WithFields(log.Fields{"controller": "nodeclaim"}),
+	}
+}
+
+// Run watches NodeClaims in the background until ctx is cancelled
+func (self *NodeClaimController) Run(ctx context.Context) error {
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(self.dynamicClient, nodeClaimResyncPeriod)
+	informer := factory.ForResource(karpenter.NodeClaimResource).Informer()
+
+	handle := func(obj interface{}) {
+		claim, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return
+		}
+		if err := self.reconcile(ctx, claim); err != nil {
+			self.logger.WithError(err).Warnf("could not reconcile NodeClaim %s", claim.GetName())
+		}
+	}
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    handle,
+		UpdateFunc: func(_, obj interface{}) { handle(obj) },
+	}); err != nil {
+		return fmt.Errorf("could not watch NodeClaims: %w", err)
+	}
+
+	self.logger.Infof("launching virtual nodes for Karpenter NodeClaims in %s", self.cfg.Namespace)
+	factory.Start(ctx.Done())
+	return nil
+}
+
+func (self *NodeClaimController) reconcile(ctx context.Context, obj *unstructured.Unstructured) error {
+	claim, err := karpenter.FromUnstructured(obj)
+	if err != nil {
+		//nolint:wrapcheck // already wrapped
+		return err
+	}
+
+	pods := self.k8sClient.CoreV1().Pods(self.cfg.Namespace)
+	if claim.ObjectMeta.DeletionTimestamp != nil {
+		err := pods.Delete(ctx, claim.Name, metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("could not delete virtual node: %w", err)
+		}
+		self.logger.Infof("NodeClaim %s is being deleted, deleted its virtual node", claim.Name)
+		return nil
+	}
+
+	if claim.Status.ProviderID == "" {
+		if err := self.setProviderID(ctx, obj); err != nil {
+			return err
+		}
+	}
+
+	if _, err := pods.Get(ctx, claim.Name, metav1.GetOptions{}); err == nil {
+		return nil
+	} else if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("could not get virtual node: %w", err)
+	}
+
+	if _, err := pods.Create(ctx, self.nodeClaimPod(claim), metav1.CreateOptions{}); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil
+		}
+		return fmt.Errorf("could not create virtual node: %w", err)
+	}
+
+	self.logger.Infof("launched virtual node %s/%s for NodeClaim %s", self.cfg.Namespace, claim.Name, claim.Name)
+	audit.Record(audit.Event{
+		Action:  audit.NodeCreated,
+		Object:  claim.Name,
+		Reason:  "NodeClaimLaunched",
+		Details: map[string]string{"nodePool": claim.ObjectMeta.Labels[karpenter.NodePoolLabel]},
+	})
+	return nil
+}
+
+// Karpenter matches the node to the NodeClaim by provider ID, which the cloud provider would
+// normally fill in when it launches the instance; the virtual node reads it back off the
+// NodeClaim when it registers
+func (self *NodeClaimController) setProviderID(ctx context.Context, obj *unstructured.Unstructured) error {
+	claim := obj.DeepCopy()
+	if err := unstructured.SetNestedField(
+		claim.Object,
+		k8s.ProviderID(claim.GetName()),
+		"status",
+		"providerID",
+	); err != nil {
+		return fmt.Errorf("could not set provider ID: %w", err)
+	}
+
+	_, err := self.dynamicClient.Resource(karpenter.NodeClaimResource).UpdateStatus(ctx, claim, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("could not set provider ID: %w", err)
+	}
+	return nil
+}
+
+// The virtual node pod is owned by the NodeClaim, so that it's garbage collected if the
+// NodeClaim disappears without us seeing it being deleted
+func (self *NodeClaimController) nodeClaimPod(claim *karpenter.NodeClaim) *corev1.Pod {
+	template := self.cfg.Template.DeepCopy()
+	if template.ObjectMeta.Labels == nil {
+		template.ObjectMeta.Labels = map[string]string{}
+	}
+	template.ObjectMeta.Labels[nodeClaimLabel] = claim.Name
+	for i := range template.Spec.Containers {
+		template.Spec.Containers[i].Env = withDefaultEnv(
+			template.Spec.Containers[i].Env,
+			append([]corev1.EnvVar{{Name: karpenter.NodeClaimEnvKey, Value: claim.Name}}, podEnv()...),
+		)
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   self.cfg.Namespace,
+			Name:        claim.Name,
+			Labels:      template.ObjectMeta.Labels,
+			Annotations: template.ObjectMeta.Annotations,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: karpenter.NodeClaimResource.GroupVersion().String(),
+				Kind:       "NodeClaim",
+				Name:       claim.Name,
+				UID:        claim.UID,
+			}},
+		},
+		Spec: template.Spec,
+	}
+}
@@ -0,0 +1,103 @@
+package cloudprov
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"simkube/lib/go/karpenter"
+	"simkube/lib/go/testutils"
+)
+
+const testKarpenterConfig = `---
+namespace: simkube
+template:
+  metadata:
+    labels:
+      app: sk-vnode
+  spec:
+    containers:
+      - name: sk-vnode
+        image: sk-vnode:latest
+`
+
+func testNodeClaimObject() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "karpenter.sh/v1",
+		"kind":       "NodeClaim",
+		"metadata": map[string]interface{}{
+			"name":   "default-abcde",
+			"uid":    "1234",
+			"labels": map[string]interface{}{karpenter.NodePoolLabel: "default"},
+		},
+	}}
+}
+
+func TestLoadKarpenterConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "karpenter.yml")
+	assert.Nil(t, os.WriteFile(configFile, []byte(testKarpenterConfig), 0o600))
+
+	cfg, err := LoadKarpenterConfig(configFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "simkube", cfg.Namespace)
+	assert.Equal(t, "sk-vnode:latest", cfg.Template.Spec.Containers[0].Image)
+
+	for _, contents := range []string{"namespace: simkube\n", "template:\n  spec:\n    containers: [{name: foo}]\n"} {
+		assert.Nil(t, os.WriteFile(configFile, []byte(contents), 0o600))
+		_, err = LoadKarpenterConfig(configFile)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestNodeClaimControllerReconcile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "karpenter.yml")
+	assert.Nil(t, os.WriteFile(configFile, []byte(testKarpenterConfig), 0o600))
+	cfg, err := LoadKarpenterConfig(configFile)
+	assert.Nil(t, err)
+
+	obj := testNodeClaimObject()
+	k8sClient := fake.NewSimpleClientset()
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{karpenter.NodeClaimResource: "NodeClaimList"},
+		obj,
+	)
+	ctrl := newNodeClaimController(cfg, k8sClient, dynamicClient)
+	ctrl.logger = testutils.GetFakeLogger()
+
+	// Reconciling is idempotent, so the second time around nothing changes
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, ctrl.reconcile(context.TODO(), obj))
+	}
+
+	claim, err := karpenter.GetNodeClaim(context.TODO(), dynamicClient, "default-abcde")
+	assert.Nil(t, err)
+	assert.Equal(t, "simkube://default-abcde", claim.Status.ProviderID)
+
+	pod, err := k8sClient.CoreV1().Pods("simkube").Get(context.TODO(), "default-abcde", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"app": "sk-vnode", nodeClaimLabel: "default-abcde"}, pod.ObjectMeta.Labels)
+	assert.Equal(t, "NodeClaim", pod.ObjectMeta.OwnerReferences[0].Kind)
+	assert.Contains(
+		t,
+		pod.Spec.Containers[0].Env,
+		corev1.EnvVar{Name: karpenter.NodeClaimEnvKey, Value: "default-abcde"},
+	)
+
+	now := metav1.Now()
+	obj.SetDeletionTimestamp(&now)
+	assert.Nil(t, ctrl.reconcile(context.TODO(), obj))
+	_, err = k8sClient.CoreV1().Pods("simkube").Get(context.TODO(), "default-abcde", metav1.GetOptions{})
+	assert.NotNil(t, err)
+	assert.Nil(t, ctrl.reconcile(context.TODO(), obj))
+}
@@ -0,0 +1,260 @@
+package cloudprov
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/samber/lo"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/node"
+	"simkube/lib/go/util"
+)
+
+const (
+	managedFleetName      = "managed"
+	managedByLabel        = "app.kubernetes.io/managed-by"
+	managedNodeGroupLabel = "simkube.io/managed-node-group"
+
+	// These are the environment variables that the virtual nodes use to find their node group
+	nodeGroupEnvKey = "POD_OWNER"
+	namespaceEnvKey = "POD_NAMESPACE"
+	podNameEnvKey   = "POD_NAME"
+)
+
+var errorInvalidNodeGroupConfig = util.WithKind(util.ErrValidation, errors.New("invalid node group config"))
+
+// A NodeGroupConfig declares node groups that the cloud provider creates and owns itself,
+// instead of discovering Deployments that someone else created; each node group is a
+// Deployment of virtual nodes built from the shared pod template.
+type NodeGroupConfig struct {
+	// Namespace is where the node group Deployments are created
+	Namespace string `json:"namespace"`
+
+	// Template is the pod template for the virtual nodes (i.e., it runs sk-vnode); the
+	// node group settings are passed to the virtual nodes with annotations on the Deployment
+	Template corev1.PodTemplateSpec `json:"template"`
+
+	NodeGroups []NodeGroupSpec `json:"nodeGroups"`
+}
+
+type NodeGroupSpec struct {
+	Name string `json:"name"`
+
+	// MinSize and MaxSize are the bounds of the node group; if the max size is 0, the
+	// cloud provider's MaxNodeGroupSize is used
+	MinSize int32 `json:"minSize,omitempty"`
+	MaxSize int32 `json:"maxSize,omitempty"`
+
+	// InstanceType, its shape (CPU and Memory), Zone (<region>/<zone>), and NodeTemplate (the
+	// skeleton to use, if the virtual nodes' skeleton path is a directory) are optional, and
+	// are only used if the skeleton doesn't set them
+	InstanceType string `json:"instanceType,omitempty"`
+	CPU          string `json:"cpu,omitempty"`
+	Memory       string `json:"memory,omitempty"`
+	Zone         string `json:"zone,omitempty"`
+	NodeTemplate string `json:"nodeTemplate,omitempty"`
+
+	// Labels are added to the virtual nodes (unless the skeleton sets them), and Priority is
+	// used by Cluster Autoscaler's priority expander
+	Labels   map[string]string `json:"labels,omitempty"`
+	Priority int32             `json:"priority,omitempty"`
+}
+
+func LoadNodeGroupConfig(configFile string) (*NodeGroupConfig, error) {
+	configBytes, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", configFile, err)
+	}
+
+	var cfg NodeGroupConfig
+	if err = yaml.UnmarshalStrict(configBytes, &cfg); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", configFile, err)
+	}
+	if err = validateNodeGroupConfig(&cfg); err != nil {
+		return nil, fmt.Errorf("could not load %s: %w", configFile, err)
+	}
+	return &cfg, nil
+}
+
+func validateNodeGroupConfig(cfg *NodeGroupConfig) error {
+	if cfg.Namespace == "" {
+		return fmt.Errorf("%w: no namespace", errorInvalidNodeGroupConfig)
+	} else if len(cfg.Template.Spec.Containers) == 0 {
+		return fmt.Errorf("%w: pod template has no containers", errorInvalidNodeGroupConfig)
+	}
+
+	names := map[string]bool{}
+	for _, ng := range cfg.NodeGroups {
+		if errs := validation.IsDNS1123Subdomain(ng.Name); len(errs) > 0 {
+			return fmt.Errorf("%w: invalid node group name %q", errorInvalidNodeGroupConfig, ng.Name)
+		} else if names[ng.Name] {
+			return fmt.Errorf("%w: duplicate node group %s", errorInvalidNodeGroupConfig, ng.Name)
+		}
+		names[ng.Name] = true
+
+		if ng.MinSize < 0 || ng.MaxSize < 0 || (ng.MaxSize > 0 && ng.MinSize > ng.MaxSize) {
+			return fmt.Errorf("%w: node group %s has invalid size bounds", errorInvalidNodeGroupConfig, ng.Name)
+		}
+		if _, err := node.InstanceShape(ng.CPU, ng.Memory); err != nil {
+			return fmt.Errorf("%w: node group %s has an invalid instance shape", errorInvalidNodeGroupConfig, ng.Name)
+		}
+		if _, err := node.ParseZones([]string{ng.Zone}); ng.Zone != "" && err != nil {
+			return fmt.Errorf("%w: node group %s has an invalid zone", errorInvalidNodeGroupConfig, ng.Name)
+		}
+		for key, value := range ng.Labels {
+			if len(validation.IsQualifiedName(key)) > 0 || len(validation.IsValidLabelValue(value)) > 0 {
+				return fmt.Errorf("%w: node group %s has an invalid label %s", errorInvalidNodeGroupConfig, ng.Name, key)
+			}
+		}
+	}
+	return nil
+}
+
+// The managed node groups are discovered like any other node groups, as their own fleet;
+// their bounds are set with annotations, so the fleet doesn't need any.
+func managedFleet(cfg *NodeGroupConfig) Fleet {
+	return Fleet{
+		Name:       managedFleetName,
+		Selector:   fmt.Sprintf("%s=%s", managedByLabel, providerName),
+		Namespaces: []string{cfg.Namespace},
+	}
+}
+
+// ApplyManagedNodeGroups makes the node group Deployments match the config (if there is one):
+// missing ones are created, existing ones are updated, and the ones that we created earlier but
+// aren't in the config anymore are deleted.  The replica count of existing Deployments is left
+// alone, since it's owned by Cluster Autoscaler.  Like scaling, this must only be done by the
+// leader; otherwise a standby replica with an old config could delete the node groups that the
+// leader just created.
+func (self *SimkubeCloudProvider) ApplyManagedNodeGroups(ctx context.Context) error {
+	cfg := self.opts.NodeGroupConfig
+	if cfg == nil {
+		return nil
+	}
+	deployments := self.k8sClient.AppsV1().Deployments(cfg.Namespace)
+
+	return k8s.Retry(func() error {
+		existing, err := deployments.List(ctx, metav1.ListOptions{LabelSelector: managedFleet(cfg).Selector})
+		if err != nil {
+			return fmt.Errorf("could not list managed node groups: %w", err)
+		}
+
+		stale := map[string]*appsv1.Deployment{}
+		for i := range existing.Items {
+			stale[existing.Items[i].Name] = &existing.Items[i]
+		}
+
+		for i := range cfg.NodeGroups {
+			desired := managedDeployment(cfg, &cfg.NodeGroups[i])
+			if current, ok := stale[desired.Name]; ok {
+				desired.ObjectMeta.ResourceVersion = current.ObjectMeta.ResourceVersion
+				desired.Spec.Replicas = current.Spec.Replicas
+				if _, err := deployments.Update(ctx, desired, metav1.UpdateOptions{}); err != nil {
+					return fmt.Errorf("could not update node group %s: %w", desired.Name, err)
+				}
+				delete(stale, desired.Name)
+			} else {
+				self.logger.Infof("creating node group %s/%s", cfg.Namespace, desired.Name)
+				if _, err := deployments.Create(ctx, desired, metav1.CreateOptions{}); err != nil {
+					return fmt.Errorf("could not create node group %s: %w", desired.Name, err)
+				}
+			}
+		}
+
+		for name := range stale {
+			self.logger.Infof("deleting node group %s/%s, which is no longer configured", cfg.Namespace, name)
+			err := deployments.Delete(ctx, name, metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("could not delete node group %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+}
+
+func managedDeployment(cfg *NodeGroupConfig, ng *NodeGroupSpec) *appsv1.Deployment {
+	selector := map[string]string{managedNodeGroupLabel: ng.Name}
+	annotations := map[string]string{util.NodeGroupMinSizeAnnotation: strconv.Itoa(int(ng.MinSize))}
+	if ng.MaxSize > 0 {
+		annotations[util.NodeGroupMaxSizeAnnotation] = strconv.Itoa(int(ng.MaxSize))
+	}
+	for key, value := range map[string]string{
+		util.InstanceTypeAnnotation:   ng.InstanceType,
+		util.InstanceCPUAnnotation:    ng.CPU,
+		util.InstanceMemoryAnnotation: ng.Memory,
+		util.ZoneAnnotation:           ng.Zone,
+		util.NodeTemplateAnnotation:   ng.NodeTemplate,
+	} {
+		if value != "" {
+			annotations[key] = value
+		}
+	}
+	if len(ng.Labels) > 0 {
+		annotations[util.NodeLabelsAnnotation] = labels.Set(ng.Labels).String()
+	}
+	if ng.Priority != 0 {
+		annotations[util.NodeGroupPriorityAnnotation] = strconv.Itoa(int(ng.Priority))
+	}
+
+	template := cfg.Template.DeepCopy()
+	if template.ObjectMeta.Labels == nil {
+		template.ObjectMeta.Labels = map[string]string{}
+	}
+	template.ObjectMeta.Labels[managedNodeGroupLabel] = ng.Name
+	for i := range template.Spec.Containers {
+		template.Spec.Containers[i].Env = withNodeGroupEnv(template.Spec.Containers[i].Env, ng.Name)
+	}
+
+	replicas := ng.MinSize
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   cfg.Namespace,
+			Name:        ng.Name,
+			Labels:      map[string]string{managedByLabel: providerName},
+			Annotations: annotations,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: selector},
+			Template: *template,
+		},
+	}
+}
+
+// The virtual nodes need to know which node group they're in, and what their pod name is; if
+// the pod template already sets any of these, it's left alone
+func withNodeGroupEnv(env []corev1.EnvVar, nodeGroupName string) []corev1.EnvVar {
+	return withDefaultEnv(env, append([]corev1.EnvVar{{Name: nodeGroupEnvKey, Value: nodeGroupName}}, podEnv()...))
+}
+
+// podEnv tells the virtual node its own namespace and pod name
+func podEnv() []corev1.EnvVar {
+	fieldRef := func(path string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: path}}
+	}
+
+	return []corev1.EnvVar{
+		{Name: namespaceEnvKey, ValueFrom: fieldRef("metadata.namespace")},
+		{Name: podNameEnvKey, ValueFrom: fieldRef("metadata.name")},
+	}
+}
+
+func withDefaultEnv(env []corev1.EnvVar, defaults []corev1.EnvVar) []corev1.EnvVar {
+	for _, v := range defaults {
+		if !lo.ContainsBy(env, func(e corev1.EnvVar) bool { return e.Name == v.Name }) {
+			env = append(env, v)
+		}
+	}
+	return env
+}
@@ -0,0 +1,217 @@
+package cloudprov
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"simkube/lib/go/testutils"
+	"simkube/lib/go/util"
+)
+
+const testNodeGroupConfig = `---
+namespace: simkube
+template:
+  spec:
+    containers:
+      - name: sk-vnode
+        image: sk-vnode:latest
+nodeGroups:
+  - name: m6i-large
+    maxSize: 5
+    instanceType: m6i.large
+    cpu: "2"
+    memory: 8Gi
+    zone: us-east-1/us-east-1a
+    labels:
+      capacity-type: spot
+    priority: 10
+  - name: gpu
+    minSize: 1
+    maxSize: 2
+    nodeTemplate: gpu
+`
+
+func testManagedConfig() *NodeGroupConfig {
+	return &NodeGroupConfig{
+		Namespace: "simkube",
+		Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "sk-vnode",
+				Env:  []corev1.EnvVar{{Name: podNameEnvKey, Value: "override"}},
+			}}},
+		},
+		NodeGroups: testManagedNodeGroups(),
+	}
+}
+
+func testManagedNodeGroups() []NodeGroupSpec {
+	return []NodeGroupSpec{
+		{
+			Name:         "m6i-large",
+			MaxSize:      5,
+			InstanceType: "m6i.large",
+			CPU:          "2",
+			Memory:       "8Gi",
+			Zone:         "us-east-1/us-east-1a",
+			Labels:       map[string]string{"capacity-type": "spot"},
+			Priority:     10,
+		},
+		{Name: "gpu", MinSize: 1, MaxSize: 2, NodeTemplate: "gpu"},
+	}
+}
+
+func TestLoadNodeGroupConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "node-groups.yml")
+	assert.Nil(t, os.WriteFile(configFile, []byte(testNodeGroupConfig), 0o600))
+
+	cfg, err := LoadNodeGroupConfig(configFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "simkube", cfg.Namespace)
+	assert.Equal(t, "sk-vnode:latest", cfg.Template.Spec.Containers[0].Image)
+	assert.Equal(t, testManagedNodeGroups(), cfg.NodeGroups)
+
+	assert.Nil(t, os.WriteFile(configFile, []byte("namespace: simkube\nnodegroups: []\n"), 0o600))
+	_, err = LoadNodeGroupConfig(configFile)
+	assert.NotNil(t, err)
+}
+
+func TestValidateNodeGroupConfig(t *testing.T) {
+	cases := map[string]struct {
+		modify    func(*NodeGroupConfig)
+		expectErr bool
+	}{
+		"valid":         {modify: func(*NodeGroupConfig) {}},
+		"no namespace":  {modify: func(cfg *NodeGroupConfig) { cfg.Namespace = "" }, expectErr: true},
+		"no containers": {modify: func(cfg *NodeGroupConfig) { cfg.Template.Spec.Containers = nil }, expectErr: true},
+		"invalid name": {
+			modify:    func(cfg *NodeGroupConfig) { cfg.NodeGroups[0].Name = "M6i_Large" },
+			expectErr: true,
+		},
+		"duplicate name": {
+			modify:    func(cfg *NodeGroupConfig) { cfg.NodeGroups[1].Name = cfg.NodeGroups[0].Name },
+			expectErr: true,
+		},
+		"min larger than max": {
+			modify:    func(cfg *NodeGroupConfig) { cfg.NodeGroups[1].MinSize = 3 },
+			expectErr: true,
+		},
+		"invalid shape": {
+			modify:    func(cfg *NodeGroupConfig) { cfg.NodeGroups[0].Memory = "lots" },
+			expectErr: true,
+		},
+		"invalid label": {
+			modify:    func(cfg *NodeGroupConfig) { cfg.NodeGroups[0].Labels["team"] = "not a label value" },
+			expectErr: true,
+		},
+		"invalid zone": {
+			modify:    func(cfg *NodeGroupConfig) { cfg.NodeGroups[0].Zone = "us-east-1/" },
+			expectErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := testManagedConfig()
+			tc.modify(cfg)
+			err := validateNodeGroupConfig(cfg)
+			if tc.expectErr {
+				assert.ErrorIs(t, err, errorInvalidNodeGroupConfig)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestManagedDeployment(t *testing.T) {
+	cfg := testManagedConfig()
+	depl := managedDeployment(cfg, &cfg.NodeGroups[0])
+
+	assert.Equal(t, "simkube", depl.ObjectMeta.Namespace)
+	assert.Equal(t, "m6i-large", depl.ObjectMeta.Name)
+	assert.Equal(t, int32(0), *depl.Spec.Replicas)
+	assert.Equal(t, map[string]string{
+		util.NodeGroupMinSizeAnnotation:  "0",
+		util.NodeGroupMaxSizeAnnotation:  "5",
+		util.InstanceTypeAnnotation:      "m6i.large",
+		util.InstanceCPUAnnotation:       "2",
+		util.InstanceMemoryAnnotation:    "8Gi",
+		util.ZoneAnnotation:              "us-east-1/us-east-1a",
+		util.NodeLabelsAnnotation:        "capacity-type=spot",
+		util.NodeGroupPriorityAnnotation: "10",
+	}, depl.ObjectMeta.Annotations)
+	assert.Equal(t, depl.Spec.Selector.MatchLabels, depl.Spec.Template.ObjectMeta.Labels)
+
+	env := depl.Spec.Template.Spec.Containers[0].Env
+	assert.Contains(t, env, corev1.EnvVar{Name: nodeGroupEnvKey, Value: "m6i-large"})
+	assert.Contains(t, env, corev1.EnvVar{Name: podNameEnvKey, Value: "override"})
+	assert.Len(t, env, 3)
+
+	// The shared template isn't modified
+	assert.Nil(t, cfg.Template.ObjectMeta.Labels)
+	assert.Len(t, cfg.Template.Spec.Containers[0].Env, 1)
+}
+
+func TestApplyManagedNodeGroups(t *testing.T) {
+	cfg := testManagedConfig()
+
+	// The "gpu" node group has been scaled up since it was created, "stale" isn't configured
+	// anymore, and "unmanaged" was created by someone else
+	var replicas int32 = 2
+	gpu := managedDeployment(cfg, &cfg.NodeGroups[1])
+	gpu.Spec.Replicas = &replicas
+	gpu.ObjectMeta.Annotations = nil
+	stale := managedDeployment(cfg, &NodeGroupSpec{Name: "stale"})
+	unmanaged := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "simkube", Name: "unmanaged"}}
+
+	k8sClient := fake.NewSimpleClientset(gpu, stale, unmanaged)
+	skprov := &SimkubeCloudProvider{
+		k8sClient: k8sClient,
+		opts:      Options{NodeGroupConfig: cfg},
+		logger:    testutils.GetFakeLogger(),
+	}
+	assert.Nil(t, skprov.ApplyManagedNodeGroups(context.TODO()))
+
+	deployments, err := k8sClient.AppsV1().Deployments("simkube").List(context.TODO(), metav1.ListOptions{})
+	assert.Nil(t, err)
+	byName := map[string]appsv1.Deployment{}
+	for _, d := range deployments.Items {
+		byName[d.ObjectMeta.Name] = d
+	}
+
+	assert.Len(t, byName, 3)
+	assert.Contains(t, byName, "m6i-large")
+	assert.Contains(t, byName, "unmanaged")
+	assert.Equal(t, int32(2), *byName["gpu"].Spec.Replicas)
+	assert.Equal(t, "gpu", byName["gpu"].ObjectMeta.Annotations[util.NodeTemplateAnnotation])
+}
+
+// Standby replicas start the informers too, but mustn't touch the managed node groups
+func TestStartDoesNotApplyManagedNodeGroups(t *testing.T) {
+	skprov := fakeCloudProvider(nil)
+	skprov.opts.NodeGroupConfig = testManagedConfig()
+	startInformers(t, skprov)
+
+	deployments, err := skprov.k8sClient.AppsV1().Deployments("simkube").List(context.TODO(), metav1.ListOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, deployments.Items)
+}
+
+func TestFleetsManaged(t *testing.T) {
+	skprov := fakeCloudProvider(nil)
+	skprov.opts.NodeGroupConfig = testManagedConfig()
+
+	fleets := skprov.fleets()
+	assert.Len(t, fleets, 2)
+	assert.Equal(t, managedFleetName, fleets[0].Name)
+	assert.Equal(t, []string{"simkube"}, fleets[0].Namespaces)
+	assert.Equal(t, defaultFleetName, fleets[1].Name)
+}
@@ -0,0 +1,146 @@
+package cloudprov
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+	"sigs.k8s.io/yaml"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/util"
+)
+
+const bytesPerGiB = 1 << 30
+
+var (
+	errorUnknownNodePrice = util.WithKind(util.ErrNotFound, errors.New("no price found for node"))
+	errorInvalidPeriod    = util.WithKind(util.ErrValidation, errors.New("invalid pricing period"))
+	errorMissingPod       = util.WithKind(util.ErrValidation, errors.New("no pod specified"))
+)
+
+// PriceTable describes how much it costs to run nodes and pods in the simulated cluster;
+// all prices are per hour.  Node prices are looked up by node group (<namespace>/<name>)
+// first, then by instance type; nodes in a node group with an instance shape are priced by
+// their CPU and memory (like pods), and anything else falls back to the default node price.
+// Pod prices are computed from the pod's resource requests.
+type PriceTable struct {
+	NodeGroups       map[string]float64 `json:"nodeGroups,omitempty"`
+	InstanceTypes    map[string]float64 `json:"instanceTypes,omitempty"`
+	DefaultNodePrice *float64           `json:"defaultNodePrice,omitempty"`
+
+	CPUPrice       float64 `json:"cpuPrice"`
+	MemoryGiBPrice float64 `json:"memoryGiBPrice"`
+}
+
+func LoadPriceTable(priceTableFile string) (*PriceTable, error) {
+	tableBytes, err := os.ReadFile(priceTableFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", priceTableFile, err)
+	}
+
+	var table PriceTable
+	if err = yaml.UnmarshalStrict(tableBytes, &table); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", priceTableFile, err)
+	}
+	return &table, nil
+}
+
+// If no price table is configured, the pricing methods are unimplemented, which tells
+// Cluster Autoscaler that pricing isn't supported by this cloud provider
+func (self *SimkubeCloudProvider) PricingNodePrice(
+	ctx context.Context,
+	req *protos.PricingNodePriceRequest,
+) (*protos.PricingNodePriceResponse, error) {
+	if self.opts.PriceTable == nil {
+		//nolint:wrapcheck // this returns the gRPC Unimplemented status
+		return self.UnimplementedCloudProviderServer.PricingNodePrice(ctx, req)
+	}
+
+	hours, err := periodHours(req.StartTime, req.EndTime)
+	if err != nil {
+		return nil, err
+	}
+
+	hourlyPrice, ok := self.opts.PriceTable.nodePrice(req.Node, self.nodeInstanceShape(req.Node))
+	if !ok {
+		err := fmt.Errorf("%w %s", errorUnknownNodePrice, req.Node.GetName())
+		return nil, err
+	}
+	return &protos.PricingNodePriceResponse{Price: hourlyPrice * hours}, nil
+}
+
+func (self *SimkubeCloudProvider) PricingPodPrice(
+	ctx context.Context,
+	req *protos.PricingPodPriceRequest,
+) (*protos.PricingPodPriceResponse, error) {
+	if self.opts.PriceTable == nil {
+		//nolint:wrapcheck // this returns the gRPC Unimplemented status
+		return self.UnimplementedCloudProviderServer.PricingPodPrice(ctx, req)
+	} else if req.Pod == nil {
+		return nil, errorMissingPod
+	}
+
+	hours, err := periodHours(req.StartTime, req.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	return &protos.PricingPodPriceResponse{Price: self.opts.PriceTable.podPrice(req.Pod) * hours}, nil
+}
+
+func (self *SimkubeCloudProvider) nodeInstanceShape(n *protos.ExternalGrpcNode) corev1.ResourceList {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	labels := n.GetLabels()
+	fullName := k8s.NamespacedName(labels[util.NodeGroupNamespaceLabel], labels[util.NodeGroupNameLabel])
+	if ng, ok := self.nodeGroups[fullName]; ok {
+		return ng.instanceShape
+	}
+	return nil
+}
+
+func (self *PriceTable) nodePrice(n *protos.ExternalGrpcNode, instanceShape corev1.ResourceList) (float64, bool) {
+	labels := n.GetLabels()
+	if nodeGroupName, ok := labels[util.NodeGroupNameLabel]; ok {
+		fullName := k8s.NamespacedName(labels[util.NodeGroupNamespaceLabel], nodeGroupName)
+		if price, ok := self.NodeGroups[fullName]; ok {
+			return price, true
+		}
+	}
+
+	if price, ok := self.InstanceTypes[labels[corev1.LabelInstanceTypeStable]]; ok {
+		return price, true
+	}
+
+	if len(instanceShape) > 0 && (self.CPUPrice > 0 || self.MemoryGiBPrice > 0) {
+		return self.resourcePrice(instanceShape), true
+	}
+
+	if self.DefaultNodePrice != nil {
+		return *self.DefaultNodePrice, true
+	}
+	return 0, false
+}
+
+// Pods are charged for what the scheduler reserves for them on the node (see k8s.PodRequests)
+func (self *PriceTable) podPrice(pod *corev1.Pod) float64 {
+	return self.resourcePrice(k8s.PodRequests(pod))
+}
+
+func (self *PriceTable) resourcePrice(resources corev1.ResourceList) float64 {
+	cpu := resources.Cpu().AsApproximateFloat64()
+	memGiB := resources.Memory().AsApproximateFloat64() / bytesPerGiB
+	return cpu*self.CPUPrice + memGiB*self.MemoryGiBPrice
+}
+
+func periodHours(start, end *metav1.Time) (float64, error) {
+	if start == nil || end == nil || end.Before(start) {
+		return 0, errorInvalidPeriod
+	}
+	return end.Sub(start.Time).Hours(), nil
+}
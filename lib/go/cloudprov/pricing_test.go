@@ -0,0 +1,179 @@
+package cloudprov
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/util"
+)
+
+func makePriceTable() *PriceTable {
+	defaultPrice := 0.5
+	return &PriceTable{
+		NodeGroups:       map[string]float64{testNodeGroupFullName: 2},
+		InstanceTypes:    map[string]float64{"m6i.large": 1},
+		DefaultNodePrice: &defaultPrice,
+		CPUPrice:         0.25,
+		MemoryGiBPrice:   0.125,
+	}
+}
+
+func makePricingPeriod(d time.Duration) (*metav1.Time, *metav1.Time) {
+	start := metav1.Unix(1688169600, 0)
+	end := metav1.NewTime(start.Add(d))
+	return &start, &end
+}
+
+func TestPricingNodePrice(t *testing.T) {
+	cases := map[string]struct {
+		labels        map[string]string
+		noDefault     bool
+		expectedPrice float64
+		expectErr     bool
+	}{
+		"node group": {
+			labels: map[string]string{
+				util.NodeGroupNamespaceLabel:   testNodeGroupNamespace,
+				util.NodeGroupNameLabel:        testNodeGroupName,
+				corev1.LabelInstanceTypeStable: "m6i.large",
+			},
+			expectedPrice: 4,
+		},
+		"instance type": {
+			labels:        map[string]string{corev1.LabelInstanceTypeStable: "m6i.large"},
+			expectedPrice: 2,
+		},
+		"instance shape": {
+			labels: map[string]string{
+				util.NodeGroupNamespaceLabel:   testNodeGroupNamespace,
+				util.NodeGroupNameLabel:        "shaped",
+				corev1.LabelInstanceTypeStable: "c5.xlarge",
+			},
+			expectedPrice: 6,
+		},
+		"default": {
+			labels:        map[string]string{corev1.LabelInstanceTypeStable: "c5.xlarge"},
+			expectedPrice: 1,
+		},
+		"unknown": {
+			labels:    map[string]string{corev1.LabelInstanceTypeStable: "c5.xlarge"},
+			noDefault: true,
+			expectErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			skprov := fakeCloudProvider(nil)
+			skprov.opts.PriceTable = makePriceTable()
+			skprov.nodeGroups[k8s.NamespacedName(testNodeGroupNamespace, "shaped")] = &cachedNodeGroup{
+				instanceShape: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("4"),
+					corev1.ResourceMemory: resource.MustParse("16Gi"),
+				},
+			}
+			if tc.noDefault {
+				skprov.opts.PriceTable.DefaultNodePrice = nil
+			}
+
+			start, end := makePricingPeriod(2 * time.Hour)
+			resp, err := skprov.PricingNodePrice(context.TODO(), &protos.PricingNodePriceRequest{
+				Node:      &protos.ExternalGrpcNode{Name: testNodeName, Labels: tc.labels},
+				StartTime: start,
+				EndTime:   end,
+			})
+			if tc.expectErr {
+				assert.ErrorIs(t, err, errorUnknownNodePrice)
+			} else {
+				assert.Nil(t, err)
+				assert.InDelta(t, tc.expectedPrice, resp.Price, 1e-9)
+			}
+		})
+	}
+}
+
+func TestPricingPodPrice(t *testing.T) {
+	skprov := fakeCloudProvider(nil)
+	skprov.opts.PriceTable = makePriceTable()
+
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		InitContainers: []corev1.Container{{Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("4"),
+			corev1.ResourceMemory: resource.MustParse("1Gi"),
+		}}}},
+		Containers: []corev1.Container{
+			{Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("500m"),
+				corev1.ResourceMemory: resource.MustParse("2Gi"),
+			}}},
+			{Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("500m"),
+				corev1.ResourceMemory: resource.MustParse("2Gi"),
+			}}},
+		},
+		Overhead: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+	}}
+
+	start, end := makePricingPeriod(30 * time.Minute)
+	resp, err := skprov.PricingPodPrice(
+		context.TODO(),
+		&protos.PricingPodPriceRequest{Pod: pod, StartTime: start, EndTime: end},
+	)
+
+	// 4 CPUs (from the init container) plus 1 CPU of overhead, and 4GiB (from the regular
+	// containers) for half an hour
+	assert.Nil(t, err)
+	assert.InDelta(t, (5*0.25+4*0.125)/2, resp.Price, 1e-9)
+}
+
+func TestPricingInvalidPeriod(t *testing.T) {
+	skprov := fakeCloudProvider(nil)
+	skprov.opts.PriceTable = makePriceTable()
+
+	end, start := makePricingPeriod(time.Hour)
+	_, err := skprov.PricingPodPrice(
+		context.TODO(),
+		&protos.PricingPodPriceRequest{Pod: &corev1.Pod{}, StartTime: start, EndTime: end},
+	)
+	assert.ErrorIs(t, err, errorInvalidPeriod)
+}
+
+func TestPricingUnimplemented(t *testing.T) {
+	skprov := fakeCloudProvider(nil)
+
+	_, err := skprov.PricingNodePrice(context.TODO(), &protos.PricingNodePriceRequest{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+
+	_, err = skprov.PricingPodPrice(context.TODO(), &protos.PricingPodPriceRequest{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestLoadPriceTable(t *testing.T) {
+	priceTableFile := filepath.Join(t.TempDir(), "prices.yml")
+	contents := "instanceTypes:\n  m6i.large: 0.096\ncpuPrice: 0.03\nmemoryGiBPrice: 0.004\n"
+	assert.Nil(t, os.WriteFile(priceTableFile, []byte(contents), 0o600))
+
+	table, err := LoadPriceTable(priceTableFile)
+	assert.Nil(t, err)
+	assert.Equal(t, &PriceTable{
+		InstanceTypes:  map[string]float64{"m6i.large": 0.096},
+		CPUPrice:       0.03,
+		MemoryGiBPrice: 0.004,
+	}, table)
+
+	assert.Nil(t, os.WriteFile(priceTableFile, []byte("cpuPrce: 0.03\n"), 0o600))
+	_, err = LoadPriceTable(priceTableFile)
+	assert.NotNil(t, err)
+}
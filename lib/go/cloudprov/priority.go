@@ -0,0 +1,95 @@
+package cloudprov
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"simkube/lib/go/k8s"
+)
+
+const (
+	priorityExpanderConfigMap = "cluster-autoscaler-priority-expander"
+	priorityExpanderKey       = "priorities"
+)
+
+// Cluster Autoscaler's priority expander doesn't ask the cloud provider for node group
+// priorities; it reads them from a ConfigMap that maps each priority to a list of regexes of
+// node group IDs.  Every node group is listed (with priority 0 if it doesn't have a
+// simkube.io/priority annotation), since the expander ignores node groups that don't match
+// any of the regexes.
+func priorityExpanderConfig(nodeGroups map[string]*cachedNodeGroup) string {
+	byPriority := map[int32][]string{}
+	for id, ng := range nodeGroups {
+		byPriority[ng.priority] = append(byPriority[ng.priority], id)
+	}
+
+	priorities := lo.Keys(byPriority)
+	sort.Slice(priorities, func(i, j int) bool { return priorities[i] > priorities[j] })
+
+	var config strings.Builder
+	for _, priority := range priorities {
+		ids := byPriority[priority]
+		sort.Strings(ids)
+		fmt.Fprintf(&config, "%d:\n", priority)
+		for _, id := range ids {
+			fmt.Fprintf(&config, "  - '^%s$'\n", regexp.QuoteMeta(id))
+		}
+	}
+	return config.String()
+}
+
+// updatePriorityExpander keeps the priority expander's ConfigMap in sync with the node groups;
+// it's only written when the priorities change, so that refreshing doesn't put any load on
+// the apiserver.  This is only called from Refresh, which holds the refresh lock.
+func (self *SimkubeCloudProvider) updatePriorityExpander(
+	ctx context.Context,
+	nodeGroups map[string]*cachedNodeGroup,
+) error {
+	config := priorityExpanderConfig(nodeGroups)
+	if config == self.priorityConfig {
+		return nil
+	}
+
+	configMaps := self.k8sClient.CoreV1().ConfigMaps(self.opts.PriorityExpanderNamespace)
+	if err := k8s.Retry(func() error {
+		cm, err := configMaps.Get(ctx, priorityExpanderConfigMap, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: self.opts.PriorityExpanderNamespace,
+					Name:      priorityExpanderConfigMap,
+				},
+				Data: map[string]string{priorityExpanderKey: config},
+			}
+			if _, err = configMaps.Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+				return fmt.Errorf("could not create %s: %w", priorityExpanderConfigMap, err)
+			}
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("could not get %s: %w", priorityExpanderConfigMap, err)
+		}
+
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[priorityExpanderKey] = config
+		if _, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("could not update %s: %w", priorityExpanderConfigMap, err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	self.logger.Infof("updated node group priorities for the priority expander:\n%s", config)
+	self.priorityConfig = config
+	return nil
+}
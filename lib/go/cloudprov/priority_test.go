@@ -0,0 +1,68 @@
+package cloudprov
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"simkube/lib/go/testutils"
+)
+
+func TestPriorityExpanderConfig(t *testing.T) {
+	nodeGroups := map[string]*cachedNodeGroup{
+		"simkube/spot":       {priority: 20},
+		"simkube/m6i.large":  {},
+		"simkube/on-demand":  {priority: 10},
+		"simkube/spot-large": {priority: 20},
+	}
+
+	expected := `20:
+  - '^simkube/spot$'
+  - '^simkube/spot-large$'
+10:
+  - '^simkube/on-demand$'
+0:
+  - '^simkube/m6i\.large$'
+`
+	assert.Equal(t, expected, priorityExpanderConfig(nodeGroups))
+	assert.Empty(t, priorityExpanderConfig(map[string]*cachedNodeGroup{}))
+}
+
+func TestUpdatePriorityExpander(t *testing.T) {
+	k8sClient := fake.NewSimpleClientset()
+	skprov := &SimkubeCloudProvider{
+		k8sClient: k8sClient,
+		opts:      Options{PriorityExpanderNamespace: "kube-system"},
+		logger:    testutils.GetFakeLogger(),
+	}
+	configMaps := k8sClient.CoreV1().ConfigMaps("kube-system")
+
+	// The ConfigMap is created if it doesn't exist
+	nodeGroups := map[string]*cachedNodeGroup{"simkube/spot": {priority: 20}}
+	assert.Nil(t, skprov.updatePriorityExpander(context.TODO(), nodeGroups))
+	cm, err := configMaps.Get(context.TODO(), priorityExpanderConfigMap, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "20:\n  - '^simkube/spot$'\n", cm.Data[priorityExpanderKey])
+
+	// It's only written when the priorities change
+	assert.Nil(t, configMaps.Delete(context.TODO(), priorityExpanderConfigMap, metav1.DeleteOptions{}))
+	assert.Nil(t, skprov.updatePriorityExpander(context.TODO(), nodeGroups))
+	_, err = configMaps.Get(context.TODO(), priorityExpanderConfigMap, metav1.GetOptions{})
+	assert.NotNil(t, err)
+
+	// Other keys in an existing ConfigMap are left alone
+	_, err = configMaps.Create(context.TODO(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: priorityExpanderConfigMap},
+		Data:       map[string]string{"other": "value"},
+	}, metav1.CreateOptions{})
+	assert.Nil(t, err)
+	nodeGroups["simkube/spot"].priority = 5
+	assert.Nil(t, skprov.updatePriorityExpander(context.TODO(), nodeGroups))
+	cm, err = configMaps.Get(context.TODO(), priorityExpanderConfigMap, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"other": "value", priorityExpanderKey: "5:\n  - '^simkube/spot$'\n"}, cm.Data)
+}
@@ -0,0 +1,97 @@
+package cloudprov
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"simkube/lib/go/k8s"
+)
+
+// Real cloud providers take a while to act on a scale-up (API latency, or waiting for capacity
+// to become available), on top of the time the new nodes take to boot.  To model this, the node
+// group object isn't scaled up until the provisioning delay has elapsed; in the meantime, the
+// requested nodes are pending, and they're counted in the target size so that Cluster Autoscaler
+// sees them as upcoming.  If ProvisioningDelayMax is larger than ProvisioningDelay, the delay is
+// chosen uniformly at random from that range for each scale-up.
+func (self *SimkubeCloudProvider) provisioningDelay() time.Duration {
+	delay := self.opts.ProvisioningDelay
+	if self.opts.ProvisioningDelayMax > delay {
+		//nolint:gosec // this doesn't need to be cryptographically secure
+		delay += time.Duration(rand.Int63n(int64(self.opts.ProvisioningDelayMax - delay)))
+	}
+	return delay
+}
+
+// delayScaleUp must be called with the write lock held
+func (self *SimkubeCloudProvider) delayScaleUp(id string, ng *cachedNodeGroup, delta int32, delay time.Duration) {
+	if self.pendingScaleUps == nil {
+		self.pendingScaleUps = map[string]int32{}
+	}
+	self.pendingScaleUps[id] += delta
+	ng.targetSize += delta
+	self.clock.AfterFunc(delay, func() { self.provision(id, delta) })
+}
+
+// cancelScaleUps must be called with the write lock held; Cluster Autoscaler decreases the
+// target size to give up on nodes that haven't registered yet, and the pending ones are the
+// furthest from registering, so they're cancelled first.  It returns the number of nodes that
+// were cancelled.
+func (self *SimkubeCloudProvider) cancelScaleUps(id string, ng *cachedNodeGroup, count int32) int32 {
+	pending := self.pendingScaleUps[id]
+	if count > pending {
+		count = pending
+	}
+	self.removeScaleUps(id, count)
+	ng.targetSize -= count
+	return count
+}
+
+func (self *SimkubeCloudProvider) removeScaleUps(id string, count int32) {
+	if count <= 0 {
+		return
+	}
+
+	self.pendingScaleUps[id] -= count
+	if self.pendingScaleUps[id] <= 0 {
+		delete(self.pendingScaleUps, id)
+	}
+}
+
+func (self *SimkubeCloudProvider) provision(id string, delta int32) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	// Some of the nodes might have been cancelled in the meantime, or the node group might be
+	// gone (e.g., it was deleted, or Cleanup cleared the cache)
+	if pending := self.pendingScaleUps[id]; delta > pending {
+		delta = pending
+	}
+	ng, ok := self.nodeGroups[id]
+	if delta <= 0 || !ok {
+		return
+	}
+
+	// The nodes are taken out of the target size and the pending scale-ups together, so that the
+	// target size is right whether or not the node group can be scaled
+	logger := self.logger.WithFields(log.Fields{"nodeGroup": id})
+	self.removeScaleUps(id, delta)
+	ng.targetSize -= delta
+
+	targetSize := ng.targetSize + delta
+	logger.Infof("provisioning %d nodes", delta)
+	namespace, name := k8s.SplitNamespacedName(id)
+	if err := ng.scaler.ScaleTo(
+		context.Background(),
+		ng.resource,
+		namespace,
+		name,
+		targetSize-self.pendingScaleUps[id],
+	); err != nil {
+		logger.Errorf("could not scale node group, dropping %d pending nodes: %s", delta, err)
+		return
+	}
+	self.setTargetSize(id, ng, targetSize)
+}
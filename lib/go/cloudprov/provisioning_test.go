@@ -0,0 +1,164 @@
+package cloudprov
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+)
+
+const testProvisioningDelay = 30 * time.Second
+
+func delayedCloudProvider(scalingClient *mockScaler) (*SimkubeCloudProvider, clockwork.FakeClock) {
+	clock := clockwork.NewFakeClock()
+	skprov := fakeCloudProvider(scalingClient)
+	skprov.opts.ProvisioningDelay = testProvisioningDelay
+	skprov.clock = clock
+	return skprov, clock
+}
+
+func pendingScaleUps(skprov *SimkubeCloudProvider) int32 {
+	skprov.mutex.RLock()
+	defer skprov.mutex.RUnlock()
+	return skprov.pendingScaleUps[testNodeGroupFullName]
+}
+
+func TestNodeGroupIncreaseSizeDelayed(t *testing.T) {
+	scalingClient := &mockScaler{}
+	skprov, clock := delayedCloudProvider(scalingClient)
+
+	_, err := skprov.NodeGroupIncreaseSize(
+		context.TODO(),
+		&protos.NodeGroupIncreaseSizeRequest{Id: testNodeGroupFullName, Delta: 2},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), pendingScaleUps(skprov))
+	assert.Equal(t, int32(3), skprov.nodeGroups[testNodeGroupFullName].targetSize)
+	scalingClient.AssertNotCalled(t, "ScaleTo")
+
+	scalingClient.On(
+		"ScaleTo", context.Background(), testDeploymentResource, testNodeGroupNamespace, testNodeGroupName, int32(3),
+	).Return(nil).Once()
+	clock.Advance(testProvisioningDelay)
+	assert.Eventually(t, func() bool { return pendingScaleUps(skprov) == 0 }, time.Second, 10*time.Millisecond)
+
+	skprov.mutex.RLock()
+	defer skprov.mutex.RUnlock()
+	assert.Equal(t, int32(3), skprov.nodeGroups[testNodeGroupFullName].targetSize)
+	assert.Equal(t, int32(3), skprov.scaleExpectations[testNodeGroupFullName].targetSize)
+	scalingClient.AssertExpectations(t)
+}
+
+func TestNodeGroupDecreaseTargetSizeCancelsPending(t *testing.T) {
+	cases := map[string]struct {
+		delta              int32
+		expectedScale      bool
+		expectedTargetSize int32
+		expectedPending    int32
+	}{
+		"some pending nodes": {
+			delta:              -1,
+			expectedTargetSize: 4,
+			expectedPending:    1,
+		},
+		"all pending nodes": {
+			delta:              -2,
+			expectedTargetSize: 3,
+		},
+		"pending and provisioned nodes": {
+			delta:              -3,
+			expectedScale:      true,
+			expectedTargetSize: 2,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			scalingClient := &mockScaler{}
+			if tc.expectedScale {
+				scalingClient.On(
+					"ScaleTo",
+					context.TODO(),
+					testDeploymentResource,
+					testNodeGroupNamespace,
+					testNodeGroupName,
+					tc.expectedTargetSize,
+				).Return(nil).Once()
+			}
+			skprov, _ := delayedCloudProvider(scalingClient)
+			ng := skprov.nodeGroups[testNodeGroupFullName]
+			ng.targetSize = 3
+
+			_, err := skprov.NodeGroupIncreaseSize(
+				context.TODO(),
+				&protos.NodeGroupIncreaseSizeRequest{Id: testNodeGroupFullName, Delta: 2},
+			)
+			assert.Nil(t, err)
+
+			_, err = skprov.NodeGroupDecreaseTargetSize(
+				context.TODO(),
+				&protos.NodeGroupDecreaseTargetSizeRequest{Id: testNodeGroupFullName, Delta: tc.delta},
+			)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedTargetSize, ng.targetSize)
+			assert.Equal(t, tc.expectedPending, pendingScaleUps(skprov))
+			scalingClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProvisionCancelled(t *testing.T) {
+	scalingClient := &mockScaler{}
+	skprov, _ := delayedCloudProvider(scalingClient)
+
+	_, err := skprov.NodeGroupIncreaseSize(
+		context.TODO(),
+		&protos.NodeGroupIncreaseSizeRequest{Id: testNodeGroupFullName, Delta: 2},
+	)
+	assert.Nil(t, err)
+	_, err = skprov.NodeGroupDecreaseTargetSize(
+		context.TODO(),
+		&protos.NodeGroupDecreaseTargetSizeRequest{Id: testNodeGroupFullName, Delta: -2},
+	)
+	assert.Nil(t, err)
+
+	// This is what the timer would do, but there's nothing left to provision
+	skprov.provision(testNodeGroupFullName, 2)
+	assert.Equal(t, int32(1), skprov.nodeGroups[testNodeGroupFullName].targetSize)
+	scalingClient.AssertNotCalled(t, "ScaleTo")
+}
+
+func TestRefreshKeepsPendingScaleUps(t *testing.T) {
+	scalingClient := &mockScaler{}
+	skprov, _ := delayedCloudProvider(scalingClient)
+	startInformers(t, skprov)
+
+	_, err := skprov.NodeGroupIncreaseSize(
+		context.TODO(),
+		&protos.NodeGroupIncreaseSizeRequest{Id: testNodeGroupFullName, Delta: 2},
+	)
+	assert.Nil(t, err)
+
+	_, err = skprov.Refresh(context.TODO(), &protos.RefreshRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), skprov.nodeGroups[testNodeGroupFullName].targetSize)
+	assert.Equal(t, int32(2), pendingScaleUps(skprov))
+}
+
+func TestProvisioningDelay(t *testing.T) {
+	skprov := fakeCloudProvider(nil)
+	assert.Equal(t, time.Duration(0), skprov.provisioningDelay())
+
+	skprov.opts.ProvisioningDelay = time.Minute
+	assert.Equal(t, time.Minute, skprov.provisioningDelay())
+
+	skprov.opts.ProvisioningDelayMax = 2 * time.Minute
+	for i := 0; i < 10; i++ {
+		delay := skprov.provisioningDelay()
+		assert.GreaterOrEqual(t, delay, time.Minute)
+		assert.Less(t, delay, 2*time.Minute)
+	}
+}
@@ -2,32 +2,141 @@ package cloudprov
 
 import (
 	"context"
+	"fmt"
 
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	confautoscalingv1 "k8s.io/client-go/applyconfigurations/autoscaling/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/util"
+)
+
+const (
+	// DeploymentScalingBackend scales Deployments through the typed apps/v1 client
+	DeploymentScalingBackend = "deployment"
+
+	// ScaleSubresourceScalingBackend scales anything with a scale subresource through the
+	// dynamic client
+	ScaleSubresourceScalingBackend = "scale"
 )
 
-type scalerI interface {
-	ScaleTo(context.Context, string, string, int32) error
+// Scaler changes the number of virtual nodes in a node group; the resource, namespace, and
+// name identify the node group object.  Each node group picks a Scaler by name from the
+// cloud provider's scaling backends, so simulations that host their virtual nodes some
+// other way can plug in their own.
+type Scaler interface {
+	ScaleTo(ctx context.Context, resource schema.GroupVersionResource, namespace, name string, target int32) error
+}
+
+func newScalingBackends(
+	k8sClient kubernetes.Interface,
+	dynamicClient dynamic.Interface,
+	extra map[string]Scaler,
+) map[string]Scaler {
+	backends := map[string]Scaler{
+		DeploymentScalingBackend:       &deploymentScaler{k8sClient},
+		ScaleSubresourceScalingBackend: &scaleSubresourceScaler{dynamicClient},
+	}
+	for name, backend := range extra {
+		backends[name] = backend
+	}
+	return backends
+}
+
+// The scaling backend can be set per node group with an annotation; otherwise Deployments use
+// the typed client and everything else uses the scale subresource
+func (self *SimkubeCloudProvider) nodeGroupScaler(resource schema.GroupVersionResource, obj metav1.Object) Scaler {
+	backend := ScaleSubresourceScalingBackend
+	if resource == appsv1.SchemeGroupVersion.WithResource("deployments") {
+		backend = DeploymentScalingBackend
+	}
+
+	if name, ok := obj.GetAnnotations()[util.ScalingBackendAnnotation]; ok {
+		if _, ok := self.scalingBackends[name]; ok {
+			backend = name
+		} else {
+			self.logger.Warnf(
+				"unknown scaling backend %q for node group %s, using %s",
+				name, k8s.NamespacedName(obj.GetNamespace(), obj.GetName()), backend,
+			)
+		}
+	}
+	return self.scalingBackends[backend]
 }
 
-type scaler struct {
+type deploymentScaler struct {
 	k8sClient kubernetes.Interface
 }
 
-func (self *scaler) ScaleTo(ctx context.Context, namespace, name string, target int32) error {
+func (self *deploymentScaler) ScaleTo(
+	ctx context.Context,
+	resource schema.GroupVersionResource,
+	namespace, name string,
+	target int32,
+) error {
+	if resource.GroupResource() != appsv1.SchemeGroupVersion.WithResource("deployments").GroupResource() {
+		return fmt.Errorf("%s backend cannot scale %s", DeploymentScalingBackend, resource)
+	}
+
 	scale := confautoscalingv1.Scale().WithSpec(&confautoscalingv1.ScaleSpecApplyConfiguration{
 		Replicas: &target,
 	})
-	if _, err := self.k8sClient.AppsV1().Deployments(namespace).ApplyScale(
-		ctx,
-		name,
-		scale,
-		metav1.ApplyOptions{Force: true, FieldManager: providerName},
-	); err != nil {
-		//nolint:wrapcheck // this is just a passthrough interface for testing
+	if err := k8s.Retry(func() error {
+		_, err := self.k8sClient.AppsV1().Deployments(namespace).ApplyScale(
+			ctx,
+			name,
+			scale,
+			metav1.ApplyOptions{Force: true, FieldManager: providerName},
+		)
+		//nolint:wrapcheck // wrapped below
+		return err
+	}); err != nil {
+		return fmt.Errorf("could not scale deployment: %w", err)
+	}
+	return nil
+}
+
+type scaleSubresourceScaler struct {
+	dynamicClient dynamic.Interface
+}
+
+// Every resource that has a scale subresource uses the autoscaling/v1 Scale object for
+// it, so we can scale any kind of node group the same way
+func (self *scaleSubresourceScaler) ScaleTo(
+	ctx context.Context,
+	resource schema.GroupVersionResource,
+	namespace, name string,
+	target int32,
+) error {
+	scale := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": autoscalingv1.SchemeGroupVersion.String(),
+		"kind":       "Scale",
+		"metadata": map[string]interface{}{
+			"namespace": namespace,
+			"name":      name,
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(target),
+		},
+	}}
+	if err := k8s.Retry(func() error {
+		_, err := self.dynamicClient.Resource(resource).Namespace(namespace).Apply(
+			ctx,
+			name,
+			scale,
+			metav1.ApplyOptions{Force: true, FieldManager: providerName},
+			"scale",
+		)
+		//nolint:wrapcheck // wrapped below
 		return err
+	}); err != nil {
+		return fmt.Errorf("could not scale %s: %w", resource.Resource, err)
 	}
 	return nil
 }
@@ -0,0 +1,94 @@
+package cloudprov
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+
+	"simkube/lib/go/testutils"
+	"simkube/lib/go/util"
+)
+
+func TestNodeGroupScaler(t *testing.T) {
+	customScaler := &mockScaler{}
+	skprov := &SimkubeCloudProvider{
+		scalingBackends: newScalingBackends(
+			fake.NewSimpleClientset(),
+			dynamicfake.NewSimpleDynamicClient(scheme.Scheme),
+			map[string]Scaler{"vnode": customScaler},
+		),
+		logger: testutils.GetFakeLogger(),
+	}
+	statefulSetResource := appsv1.SchemeGroupVersion.WithResource("statefulsets")
+
+	cases := map[string]struct {
+		resource    schema.GroupVersionResource
+		annotations map[string]string
+		expected    Scaler
+	}{
+		"deployment": {
+			resource: testDeploymentResource,
+			expected: skprov.scalingBackends[DeploymentScalingBackend],
+		},
+		"statefulset": {
+			resource: statefulSetResource,
+			expected: skprov.scalingBackends[ScaleSubresourceScalingBackend],
+		},
+		"annotation": {
+			resource:    testDeploymentResource,
+			annotations: map[string]string{util.ScalingBackendAnnotation: ScaleSubresourceScalingBackend},
+			expected:    skprov.scalingBackends[ScaleSubresourceScalingBackend],
+		},
+		"custom backend": {
+			resource:    statefulSetResource,
+			annotations: map[string]string{util.ScalingBackendAnnotation: "vnode"},
+			expected:    customScaler,
+		},
+		"unknown backend": {
+			resource:    statefulSetResource,
+			annotations: map[string]string{util.ScalingBackendAnnotation: "asdf"},
+			expected:    skprov.scalingBackends[ScaleSubresourceScalingBackend],
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			obj := &metav1.ObjectMeta{Namespace: testNodeGroupNamespace, Name: "foo", Annotations: tc.annotations}
+			assert.Same(t, tc.expected, skprov.nodeGroupScaler(tc.resource, obj))
+		})
+	}
+}
+
+func TestDeploymentScaler(t *testing.T) {
+	k8sClient := fake.NewSimpleClientset()
+	var patch []byte
+
+	// The fake client records this as a patch to the status subresource, so we don't check it
+	k8sClient.PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		a, ok := action.(k8stesting.PatchAction)
+		if !ok || a.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		patch = a.GetPatch()
+		return true, nil, nil
+	})
+
+	s := &deploymentScaler{k8sClient}
+	err := s.ScaleTo(context.TODO(), testDeploymentResource, testNodeGroupNamespace, testNodeGroupName, 3)
+	assert.Nil(t, err)
+	assert.Contains(t, string(patch), `"replicas":3`)
+
+	statefulSetResource := appsv1.SchemeGroupVersion.WithResource("statefulsets")
+	err = s.ScaleTo(context.TODO(), statefulSetResource, testNodeGroupNamespace, testNodeGroupName, 3)
+	assert.NotNil(t, err)
+}
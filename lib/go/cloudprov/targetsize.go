@@ -0,0 +1,96 @@
+package cloudprov
+
+import (
+	"strconv"
+	"time"
+
+	"simkube/lib/go/audit"
+)
+
+const scaleExpectationTimeout = time.Minute
+
+// When we scale a node group, the informer cache doesn't see the new replica count right
+// away, so a refresh that happens in between would report the old target size (and the
+// next scale-up would be computed from it).  Instead, we remember what we asked for, and
+// keep reporting it until the node group catches up.
+type scaleExpectation struct {
+	targetSize   int32
+	observedSize int32
+	timestamp    time.Time
+}
+
+// setTargetSize must be called with the write lock held, after the node group was successfully
+// scaled.  The target size includes the pending scale-ups, but the node group object was only
+// scaled to the part that has been provisioned, so that's what we expect to see.
+func (self *SimkubeCloudProvider) setTargetSize(name string, ng *cachedNodeGroup, targetSize int32) {
+	pending := self.pendingScaleUps[name]
+	observedSize := ng.targetSize - pending
+	if exp, ok := self.scaleExpectations[name]; ok {
+		observedSize = exp.observedSize
+	}
+
+	if self.scaleExpectations == nil {
+		self.scaleExpectations = map[string]*scaleExpectation{}
+	}
+	self.scaleExpectations[name] = &scaleExpectation{
+		targetSize:   targetSize - pending,
+		observedSize: observedSize,
+		timestamp:    self.clock.Now(),
+	}
+	ng.targetSize = targetSize
+}
+
+// recordScale records the cluster autoscaler's decision to resize a node group in the audit log
+func recordScale(action audit.Action, name string, from, to int32, reason string, details map[string]string) {
+	if details == nil {
+		details = map[string]string{}
+	}
+	details["fromSize"] = strconv.Itoa(int(from))
+	details["toSize"] = strconv.Itoa(int(to))
+	audit.Record(audit.Event{Action: action, Object: name, Reason: reason, Details: details})
+}
+
+// reconcileTargetSizes must be called with the write lock held.  There are three cases for each
+// outstanding expectation:
+//
+//  1. the node group has the replica count we asked for, so the expectation is satisfied;
+//  2. the node group still has its old replica count, so we haven't seen the change yet and
+//     keep reporting the expected target size (unless we've been waiting for too long);
+//  3. the node group has some other replica count (e.g., something else scaled it, or only
+//     part of the scale-up was applied), so we trust the observed value instead.
+//
+// Afterwards, the scale-ups that haven't been provisioned yet are added to the target sizes.
+func (self *SimkubeCloudProvider) reconcileTargetSizes(nodeGroups map[string]*cachedNodeGroup) {
+	for name, exp := range self.scaleExpectations {
+		ng, ok := nodeGroups[name]
+		if !ok {
+			delete(self.scaleExpectations, name)
+			continue
+		}
+
+		switch {
+		case ng.targetSize == exp.targetSize:
+			delete(self.scaleExpectations, name)
+		case ng.targetSize == exp.observedSize && self.clock.Since(exp.timestamp) < scaleExpectationTimeout:
+			self.logger.Debugf(
+				"node group %s has not been scaled to %d yet (currently %d)",
+				name, exp.targetSize, ng.targetSize,
+			)
+			ng.targetSize = exp.targetSize
+		default:
+			self.logger.Warnf(
+				"node group %s has %d replicas, expected %d; using the observed size",
+				name, ng.targetSize, exp.targetSize,
+			)
+			delete(self.scaleExpectations, name)
+		}
+	}
+
+	for name, pending := range self.pendingScaleUps {
+		if ng, ok := nodeGroups[name]; ok {
+			ng.targetSize += pending
+		} else {
+			delete(self.pendingScaleUps, name)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package cloudprov
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+)
+
+func TestNodeGroupIncreaseSizeTwice(t *testing.T) {
+	scalingClient := &mockScaler{}
+	for _, target := range []int32{3, 5} {
+		scalingClient.On(
+			"ScaleTo", context.TODO(), testDeploymentResource, testNodeGroupNamespace, testNodeGroupName, target,
+		).Return(nil).Once()
+	}
+	skprov := fakeCloudProvider(scalingClient)
+	startInformers(t, skprov)
+
+	// The fake scaler doesn't change the Deployment, so the refresh in between the scale-ups
+	// looks like the informer hasn't caught up yet
+	for _, delta := range []int32{2, 2} {
+		_, err := skprov.NodeGroupIncreaseSize(
+			context.TODO(),
+			&protos.NodeGroupIncreaseSizeRequest{Id: testNodeGroupFullName, Delta: delta},
+		)
+		assert.Nil(t, err)
+
+		_, err = skprov.Refresh(context.TODO(), &protos.RefreshRequest{})
+		assert.Nil(t, err)
+	}
+
+	resp, err := skprov.NodeGroupTargetSize(
+		context.TODO(),
+		&protos.NodeGroupTargetSizeRequest{Id: testNodeGroupFullName},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(5), resp.TargetSize)
+	assert.Equal(t, int32(1), skprov.scaleExpectations[testNodeGroupFullName].observedSize)
+	scalingClient.AssertExpectations(t)
+}
+
+func TestReconcileTargetSizes(t *testing.T) {
+	cases := map[string]struct {
+		observedSize        int32
+		elapsed             time.Duration
+		missing             bool
+		expectedTargetSize  int32
+		expectedExpectation bool
+	}{
+		"satisfied": {
+			observedSize:       5,
+			expectedTargetSize: 5,
+		},
+		"not observed yet": {
+			observedSize:        1,
+			expectedTargetSize:  5,
+			expectedExpectation: true,
+		},
+		"not observed after timeout": {
+			observedSize:       1,
+			elapsed:            2 * scaleExpectationTimeout,
+			expectedTargetSize: 1,
+		},
+		"partially applied": {
+			observedSize:       3,
+			expectedTargetSize: 3,
+		},
+		"node group removed": {
+			missing: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			clock := clockwork.NewFakeClock()
+			skprov := fakeCloudProvider(nil)
+			skprov.clock = clock
+			skprov.scaleExpectations = map[string]*scaleExpectation{
+				testNodeGroupFullName: {targetSize: 5, observedSize: 1, timestamp: clock.Now()},
+			}
+			clock.Advance(tc.elapsed)
+
+			nodeGroups := map[string]*cachedNodeGroup{}
+			if !tc.missing {
+				nodeGroups[testNodeGroupFullName] = &cachedNodeGroup{data: testNodeGroup, targetSize: tc.observedSize}
+			}
+			skprov.reconcileTargetSizes(nodeGroups)
+
+			if !tc.missing {
+				assert.Equal(t, tc.expectedTargetSize, nodeGroups[testNodeGroupFullName].targetSize)
+			}
+			if tc.expectedExpectation {
+				assert.Contains(t, skprov.scaleExpectations, testNodeGroupFullName)
+			} else {
+				assert.NotContains(t, skprov.scaleExpectations, testNodeGroupFullName)
+			}
+		})
+	}
+}
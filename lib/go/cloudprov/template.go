@@ -0,0 +1,51 @@
+package cloudprov
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/node"
+)
+
+// Cluster Autoscaler can only scale up a node group that has no nodes if it knows what the
+// nodes would look like, so we build one from the same skeleton the virtual nodes use (and
+// the node group's simkube.io/node-template annotation, if the skeleton path is a directory,
+// along with its instance type, instance shape, zone, and node label annotations)
+func (self *SimkubeCloudProvider) NodeGroupTemplateNodeInfo(
+	ctx context.Context,
+	req *protos.NodeGroupTemplateNodeInfoRequest,
+) (*protos.NodeGroupTemplateNodeInfoResponse, error) {
+	if self.opts.NodeSkeletonPath == "" {
+		//nolint:wrapcheck // this returns the gRPC Unimplemented status
+		return self.UnimplementedCloudProviderServer.NodeGroupTemplateNodeInfo(ctx, req)
+	}
+
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	ng, ok := self.nodeGroups[req.Id]
+	if !ok {
+		return nil, errorUnknownNodeGroup
+	}
+
+	namespace, name := k8s.SplitNamespacedName(req.Id)
+	templateNode, err := node.TemplateNode(self.opts.NodeSkeletonPath, node.TemplateNodeOptions{
+		NodeGroupNamespace: namespace,
+		NodeGroupName:      name,
+		NodeTemplate:       ng.nodeTemplate,
+		GPULabel:           self.gpuLabel(),
+		GPUType:            ng.gpuType,
+		InstanceType:       ng.instanceType,
+		InstanceShape:      ng.instanceShape,
+		Zone:               ng.zone,
+		Labels:             ng.labels,
+	})
+	if err != nil {
+		err = fmt.Errorf("could not build template node: %w", err)
+		return nil, err
+	}
+	return &protos.NodeGroupTemplateNodeInfoResponse{NodeInfo: templateNode}, nil
+}
@@ -0,0 +1,46 @@
+package cloudprov
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+
+	"simkube/lib/go/util"
+)
+
+const testSkelFile = "../testutils/manifests/skeleton-node.yml"
+
+func TestNodeGroupTemplateNodeInfoUnimplemented(t *testing.T) {
+	skprov := fakeCloudProvider(nil)
+
+	_, err := skprov.NodeGroupTemplateNodeInfo(
+		context.TODO(),
+		&protos.NodeGroupTemplateNodeInfoRequest{Id: testNodeGroupFullName},
+	)
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestNodeGroupTemplateNodeInfo(t *testing.T) {
+	skprov := fakeCloudProvider(nil)
+	skprov.opts.NodeSkeletonPath = testSkelFile
+	skprov.nodeGroups[testNodeGroupFullName].labels = map[string]string{"capacity-type": "spot"}
+
+	resp, err := skprov.NodeGroupTemplateNodeInfo(
+		context.TODO(),
+		&protos.NodeGroupTemplateNodeInfoRequest{Id: testNodeGroupFullName},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, testNodeGroupNamespace, resp.NodeInfo.ObjectMeta.Labels[util.NodeGroupNamespaceLabel])
+	assert.Equal(t, testNodeGroupName, resp.NodeInfo.ObjectMeta.Labels[util.NodeGroupNameLabel])
+	assert.Equal(t, "spot", resp.NodeInfo.ObjectMeta.Labels["capacity-type"])
+
+	_, err = skprov.NodeGroupTemplateNodeInfo(
+		context.TODO(),
+		&protos.NodeGroupTemplateNodeInfoRequest{Id: "foo/bar"},
+	)
+	assert.ErrorIs(t, err, errorUnknownNodeGroup)
+}
@@ -0,0 +1,60 @@
+package cloudprov
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Cluster Autoscaler expects the size-changing methods to respect the node group's bounds, and
+// DecreaseTargetSize to only give up nodes that haven't registered yet (registered nodes are
+// removed with DeleteNodes).  We return gRPC status errors when a request breaks these rules,
+// instead of scaling the node group anyway, so that simulations can also exercise Cluster
+// Autoscaler's error handling.
+
+func increasedTargetSize(ng *cachedNodeGroup, delta int32) (int32, error) {
+	if delta <= 0 {
+		return 0, status.Errorf(codes.InvalidArgument, "size increase must be positive, got %d", delta)
+	} else if delta > ng.data.MaxSize-ng.targetSize {
+		return 0, status.Errorf(
+			codes.OutOfRange,
+			"size increase too large: target size %d + %d, max size %d",
+			ng.targetSize, delta, ng.data.MaxSize,
+		)
+	}
+	return ng.targetSize + delta, nil
+}
+
+// Cluster Autoscaler passes a negative delta to DecreaseTargetSize
+func decreasedTargetSize(ng *cachedNodeGroup, delta int32) (int32, error) {
+	if delta >= 0 {
+		return 0, status.Errorf(codes.InvalidArgument, "size decrease must be negative, got %d", delta)
+	}
+
+	targetSize := ng.targetSize + delta
+	if targetSize < ng.nodeCount {
+		return 0, status.Errorf(
+			codes.FailedPrecondition,
+			"attempt to delete existing nodes: target size %d, %d registered nodes",
+			targetSize, ng.nodeCount,
+		)
+	} else if targetSize < ng.data.MinSize {
+		return 0, status.Errorf(
+			codes.OutOfRange,
+			"size decrease too large: target size %d, min size %d",
+			targetSize, ng.data.MinSize,
+		)
+	}
+	return targetSize, nil
+}
+
+func deletedNodesTargetSize(ng *cachedNodeGroup, count int) (int32, error) {
+	targetSize := ng.targetSize - int32(count)
+	if targetSize < ng.data.MinSize {
+		return 0, status.Errorf(
+			codes.OutOfRange,
+			"min size reached: target size %d - %d nodes, min size %d",
+			ng.targetSize, count, ng.data.MinSize,
+		)
+	}
+	return targetSize, nil
+}
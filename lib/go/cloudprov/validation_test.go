@@ -0,0 +1,134 @@
+package cloudprov
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/externalgrpc/protos"
+)
+
+func TestSizeChangeValidation(t *testing.T) {
+	// The test node group has one registered node, a target size of 3, and bounds of [1, 13]
+	cases := map[string]struct {
+		call               func(*SimkubeCloudProvider) error
+		expectedCode       codes.Code
+		expectedTargetSize int32
+	}{
+		"increase": {
+			call:               increaseSize(10),
+			expectedTargetSize: 13,
+		},
+		"increase above max": {
+			call:         increaseSize(11),
+			expectedCode: codes.OutOfRange,
+		},
+		"increase overflow": {
+			call:         increaseSize(1<<31 - 1),
+			expectedCode: codes.OutOfRange,
+		},
+		"increase zero": {
+			call:         increaseSize(0),
+			expectedCode: codes.InvalidArgument,
+		},
+		"increase negative": {
+			call:         increaseSize(-1),
+			expectedCode: codes.InvalidArgument,
+		},
+		"decrease": {
+			call:               decreaseTargetSize(-2),
+			expectedTargetSize: 1,
+		},
+		"decrease positive": {
+			call:         decreaseTargetSize(2),
+			expectedCode: codes.InvalidArgument,
+		},
+		"decrease existing nodes": {
+			call:         decreaseTargetSize(-3),
+			expectedCode: codes.FailedPrecondition,
+		},
+		"delete nodes": {
+			call:               deleteNodes(2),
+			expectedTargetSize: 1,
+		},
+		"delete nodes below min": {
+			call:         deleteNodes(3),
+			expectedCode: codes.OutOfRange,
+		},
+		"unknown node group": {
+			call: func(skprov *SimkubeCloudProvider) error {
+				_, err := skprov.NodeGroupIncreaseSize(
+					context.TODO(),
+					&protos.NodeGroupIncreaseSizeRequest{Id: "testing/missing", Delta: 1},
+				)
+				return err
+			},
+			expectedCode: codes.NotFound,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			scalingClient := &mockScaler{}
+			if tc.expectedCode == codes.OK {
+				scalingClient.On(
+					"ScaleTo",
+					context.TODO(),
+					testDeploymentResource,
+					testNodeGroupNamespace,
+					testNodeGroupName,
+					tc.expectedTargetSize,
+				).Return(nil).Once()
+			}
+			skprov := fakeCloudProvider(scalingClient)
+			ng := skprov.nodeGroups[testNodeGroupFullName]
+			ng.data = &protos.NodeGroup{Id: testNodeGroupFullName, MinSize: 1, MaxSize: 13}
+			ng.targetSize = 3
+
+			err := tc.call(skprov)
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.expectedCode != codes.OK {
+				assert.Equal(t, int32(3), ng.targetSize)
+			}
+			scalingClient.AssertExpectations(t)
+		})
+	}
+}
+
+func increaseSize(delta int32) func(*SimkubeCloudProvider) error {
+	return func(skprov *SimkubeCloudProvider) error {
+		_, err := skprov.NodeGroupIncreaseSize(
+			context.TODO(),
+			&protos.NodeGroupIncreaseSizeRequest{Id: testNodeGroupFullName, Delta: delta},
+		)
+		return err
+	}
+}
+
+func decreaseTargetSize(delta int32) func(*SimkubeCloudProvider) error {
+	return func(skprov *SimkubeCloudProvider) error {
+		_, err := skprov.NodeGroupDecreaseTargetSize(
+			context.TODO(),
+			&protos.NodeGroupDecreaseTargetSizeRequest{Id: testNodeGroupFullName, Delta: delta},
+		)
+		return err
+	}
+}
+
+// Only the size check is exercised here, so the nodes are all the same (deleting the pod for
+// the first one removes it, and the rest are skipped as not found)
+func deleteNodes(count int) func(*SimkubeCloudProvider) error {
+	return func(skprov *SimkubeCloudProvider) error {
+		nodes := make([]*protos.ExternalGrpcNode, count)
+		for i := range nodes {
+			nodes[i] = makeExternalGrpcNode(testNodeGroupNamespace, testNodeGroupName)
+		}
+		_, err := skprov.NodeGroupDeleteNodes(
+			context.TODO(),
+			&protos.NodeGroupDeleteNodesRequest{Id: testNodeGroupFullName, Nodes: nodes},
+		)
+		return err
+	}
+}
@@ -0,0 +1,113 @@
+package custommetrics
+
+import (
+	"sort"
+	"time"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/trace"
+)
+
+const hpaKind = "HorizontalPodAutoscaler"
+
+type sample struct {
+	offset time.Duration
+	value  resource.Quantity
+}
+
+// A series is the value of a metric over the course of the simulation: each sample takes effect
+// at its offset (in trace time) from the start of the simulation and lasts until the next one.
+type series []sample
+
+func seriesFromValues(values []simkubev1.CustomMetricValue) series {
+	res := make(series, 0, len(values))
+	for _, v := range values {
+		res = append(res, sample{offset: time.Duration(v.OffsetSeconds) * time.Second, value: v.Value})
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].offset < res[j].offset })
+	return res
+}
+
+// at returns the value of the metric at the given offset; before the first sample, the metric
+// doesn't have a value yet
+func (self series) at(offset time.Duration) (resource.Quantity, bool) {
+	i := sort.Search(len(self), func(i int) bool { return self[i].offset > offset })
+	if i == 0 {
+		return resource.Quantity{}, false
+	}
+	return self[i-1].value, true
+}
+
+// tracedSeries derives the values of a metric from the HPAs in the trace that use it: every time
+// an HPA was recorded, its status had the current value of each of its metrics.  The series are
+// keyed by the namespace (in the trace) of the HPAs.  Values that the HPA reports per pod are
+// multiplied back out by the number of replicas, since that's how they're served (see
+// metricValue).
+func tracedSeries(tr *trace.Trace, metric *simkubev1.CustomMetric) map[string]series {
+	res := map[string]series{}
+	if len(tr.Events) == 0 {
+		return res
+	}
+
+	startTs := tr.Events[0].Ts
+	for _, evt := range tr.Events {
+		offset := time.Duration(evt.Ts-startTs) * time.Second
+		for _, obj := range evt.AppliedObjs {
+			if obj.GetKind() != hpaKind || (metric.Namespace != "" && obj.GetNamespace() != metric.Namespace) {
+				continue
+			}
+
+			statusObj, ok := obj.Object["status"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			var status autoscalingv2.HorizontalPodAutoscalerStatus
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(statusObj, &status); err != nil {
+				continue
+			}
+
+			for i := range status.CurrentMetrics {
+				if value, ok := currentMetricValue(&status.CurrentMetrics[i], metric, int64(status.CurrentReplicas)); ok {
+					res[obj.GetNamespace()] = append(res[obj.GetNamespace()], sample{offset: offset, value: value})
+				}
+			}
+		}
+	}
+	return res
+}
+
+func currentMetricValue(
+	current *autoscalingv2.MetricStatus,
+	metric *simkubev1.CustomMetric,
+	replicas int64,
+) (resource.Quantity, bool) {
+	if string(current.Type) != string(metric.Type) {
+		return resource.Quantity{}, false
+	}
+
+	var name string
+	var value autoscalingv2.MetricValueStatus
+	switch {
+	case current.Object != nil:
+		name, value = current.Object.Metric.Name, current.Object.Current
+	case current.Pods != nil:
+		name, value = current.Pods.Metric.Name, current.Pods.Current
+	case current.External != nil:
+		name, value = current.External.Metric.Name, current.External.Current
+	default:
+		return resource.Quantity{}, false
+	}
+
+	if name != metric.Name {
+		return resource.Quantity{}, false
+	} else if value.Value != nil {
+		return *value.Value, true
+	} else if value.AverageValue != nil {
+		return *resource.NewMilliQuantity(value.AverageValue.MilliValue()*replicas, value.AverageValue.Format), true
+	}
+	return resource.Quantity{}, false
+}
@@ -0,0 +1,112 @@
+package custommetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/trace"
+)
+
+func TestSeriesAt(t *testing.T) {
+	values := seriesFromValues([]simkubev1.CustomMetricValue{
+		{OffsetSeconds: 60, Value: resource.MustParse("30")},
+		{OffsetSeconds: 10, Value: resource.MustParse("10")},
+	})
+
+	for name, tc := range map[string]struct {
+		offset   time.Duration
+		expected string
+	}{
+		"before first":   {offset: 5 * time.Second},
+		"at first":       {offset: 10 * time.Second, expected: "10"},
+		"between":        {offset: 30 * time.Second, expected: "10"},
+		"at second":      {offset: time.Minute, expected: "30"},
+		"after the last": {offset: time.Hour, expected: "30"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			value, ok := values.at(tc.offset)
+			assert.Equal(t, tc.expected != "", ok)
+			if ok {
+				assert.Equal(t, tc.expected, value.String())
+			}
+		})
+	}
+}
+
+func testHPAObj(namespace string, replicas int64, metrics ...interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "autoscaling/v2",
+		"kind":       hpaKind,
+		"metadata":   map[string]interface{}{"name": "web", "namespace": namespace},
+		"status":     map[string]interface{}{"currentReplicas": replicas, "currentMetrics": metrics},
+	}}
+}
+
+func testPodsMetric(name, averageValue string) interface{} {
+	return map[string]interface{}{
+		"type": "Pods",
+		"pods": map[string]interface{}{
+			"metric":  map[string]interface{}{"name": name},
+			"current": map[string]interface{}{"averageValue": averageValue},
+		},
+	}
+}
+
+func testExternalMetric(name, value string) interface{} {
+	return map[string]interface{}{
+		"type": "External",
+		"external": map[string]interface{}{
+			"metric":  map[string]interface{}{"name": name},
+			"current": map[string]interface{}{"value": value},
+		},
+	}
+}
+
+func TestTracedSeries(t *testing.T) {
+	tr := &trace.Trace{Events: []trace.Event{
+		{Ts: 100, AppliedObjs: []*unstructured.Unstructured{
+			testHPAObj("default", 2, testPodsMetric("rps", "5"), testExternalMetric("lag", "4")),
+			testHPAObj("other", 1, testPodsMetric("rps", "1")),
+		}},
+		{Ts: 130, AppliedObjs: []*unstructured.Unstructured{
+			testHPAObj("default", 3, testPodsMetric("rps", "10")),
+		}},
+	}}
+
+	for name, tc := range map[string]struct {
+		metric   simkubev1.CustomMetric
+		expected map[string][]string
+	}{
+		"pods": {
+			metric:   simkubev1.CustomMetric{Name: "rps", Type: simkubev1.CustomMetricPods},
+			expected: map[string][]string{"default": {"0s=10", "30s=30"}, "other": {"0s=1"}},
+		},
+		"pods one namespace": {
+			metric:   simkubev1.CustomMetric{Name: "rps", Type: simkubev1.CustomMetricPods, Namespace: "other"},
+			expected: map[string][]string{"other": {"0s=1"}},
+		},
+		"external": {
+			metric:   simkubev1.CustomMetric{Name: "lag", Type: simkubev1.CustomMetricExternal},
+			expected: map[string][]string{"default": {"0s=4"}},
+		},
+		"wrong type": {
+			metric:   simkubev1.CustomMetric{Name: "lag", Type: simkubev1.CustomMetricPods},
+			expected: map[string][]string{},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			res := map[string][]string{}
+			for namespace, values := range tracedSeries(tr, &tc.metric) {
+				for _, s := range values {
+					res[namespace] = append(res[namespace], s.offset.String()+"="+s.value.String())
+				}
+			}
+			assert.Equal(t, tc.expected, res)
+		})
+	}
+}
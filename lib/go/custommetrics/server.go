@@ -0,0 +1,463 @@
+package custommetrics
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/samber/lo"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/k8s"
+)
+
+const (
+	readHeaderTimeout = 5 * time.Second
+	shutdownTimeout   = 5 * time.Second
+	resyncPeriod      = 30 * time.Second
+
+	// The values are looked up on every request, but the HPA expects them to cover some window
+	// of time; this is the default Prometheus scrape interval
+	windowSeconds = 15
+
+	// The HPA asks for Pods metrics for all of the pods that match its selector at once
+	allPods = "*"
+)
+
+var simulationGVR = simkubev1.GroupVersion.WithResource("simulations") //nolint:gochecknoglobals
+
+// The Server serves the custom and external metrics APIs for running simulations, so that it
+// can be registered as an aggregated API (in place of a metrics adapter like prometheus-adapter)
+// and HPAs that scale on custom or external metrics work in simulations.  The values of the
+// metrics come from the simulation spec, or from the HPAs in the trace (see CustomMetric).
+type Server struct {
+	k8sClient     kubernetes.Interface
+	dynamicClient dynamic.Interface
+	loadTrace     traceLoader
+	clock         clockwork.Clock
+	logger        *log.Entry
+
+	podLister corev1listers.PodLister
+
+	// sims are the custom metrics for each running simulation, along with the parts of the
+	// spec that they were built from (so we only rebuild them, and re-read the trace, when
+	// those change)
+	mutex sync.Mutex
+	sims  map[string]*simMetrics
+	specs map[string]simkubev1.SimulationSpec
+}
+
+func NewServer(clientOpts k8s.ClientOptions) (*Server, error) {
+	k8sClient, err := k8s.NewClient(clientOpts)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize Kubernetes client: %w", err)
+	}
+	dynamicClient, err := k8s.NewDynamicClient(clientOpts)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize dynamic client: %w", err)
+	}
+	return newServer(k8sClient, dynamicClient), nil
+}
+
+func newServer(k8sClient kubernetes.Interface, dynamicClient dynamic.Interface) *Server {
+	return &Server{
+		k8sClient:     k8sClient,
+		dynamicClient: dynamicClient,
+		loadTrace:     readTrace,
+		clock:         clockwork.NewRealClock(),
+		logger:        log.WithFields(log.Fields{"component": "custom-metrics-api"}),
+		sims:          map[string]*simMetrics{},
+		specs:         map[string]simkubev1.SimulationSpec{},
+	}
+}
+
+// Start watches the simulations and the pods that are running on any node, and waits for the
+// caches to sync
+func (self *Server) Start(ctx context.Context) error {
+	podFactory := informers.NewSharedInformerFactoryWithOptions(
+		self.k8sClient,
+		resyncPeriod,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = "spec.nodeName!=,status.phase=Running"
+		}),
+	)
+	self.podLister = podFactory.Core().V1().Pods().Lister()
+
+	simFactory := dynamicinformer.NewDynamicSharedInformerFactory(self.dynamicClient, resyncPeriod)
+	if _, err := simFactory.ForResource(simulationGVR).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    self.simulationChanged,
+		UpdateFunc: func(_, obj interface{}) { self.simulationChanged(obj) },
+		DeleteFunc: self.simulationDeleted,
+	}); err != nil {
+		return fmt.Errorf("could not watch simulations: %w", err)
+	}
+
+	podFactory.Start(ctx.Done())
+	simFactory.Start(ctx.Done())
+	for informerType, synced := range podFactory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("could not sync %v informer", informerType)
+		}
+	}
+	for gvr, synced := range simFactory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("could not sync %v informer", gvr)
+		}
+	}
+	return nil
+}
+
+// Run serves the APIs on addr in the background, until ctx is cancelled; the aggregated API
+// server only talks to it over TLS
+func (self *Server) Run(ctx context.Context, addr string, tlsConfig *tls.Config) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           self.Handler(),
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			self.logger.WithError(err).Warn("could not shut down custom metrics API server")
+		}
+	}()
+
+	go func() {
+		self.logger.Infof("serving the custom and external metrics APIs on %s", addr)
+		if err := srv.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			self.logger.WithError(err).Error("custom metrics API server failed")
+		}
+	}()
+}
+
+func (self *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, gv := range []schema.GroupVersion{CustomGroupVersion, ExternalGroupVersion} {
+		mux.HandleFunc("/apis/"+gv.Group, groupHandler(gv))
+		mux.HandleFunc("/apis/"+gv.String(), self.handleResources)
+	}
+	mux.HandleFunc("/apis/"+CustomGroupVersion.String()+"/", self.handleCustomMetrics)
+	mux.HandleFunc("/apis/"+ExternalGroupVersion.String()+"/", self.handleExternalMetrics)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) { fmt.Fprintln(w, "ok") })
+	return mux
+}
+
+func (self *Server) simulationChanged(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		self.logger.Warnf("unexpected simulation object: %T", obj)
+		return
+	}
+
+	var sim simkubev1.Simulation
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &sim); err != nil {
+		self.logger.WithError(err).Warnf("invalid simulation %s", u.GetName())
+		return
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	now := self.clock.Now()
+	if sim.Status.Phase != simkubev1.SimulationRunning || sim.Status.StartTime == nil || len(sim.Spec.CustomMetrics) == 0 {
+		delete(self.sims, sim.Name)
+		delete(self.specs, sim.Name)
+		return
+	}
+
+	// Pausing or resuming the simulation doesn't change the metrics, just where we are in them
+	spec := *sim.Spec.DeepCopy()
+	spec.Paused = false
+	if existing, ok := self.sims[sim.Name]; ok && equality.Semantic.DeepEqual(spec, self.specs[sim.Name]) {
+		existing.setPaused(sim.Spec.Paused, now)
+		return
+	}
+
+	metrics, err := newSimMetrics(&sim, self.loadTrace, now)
+	if err != nil {
+		self.logger.WithError(err).Warnf("could not load custom metrics for simulation %s", sim.Name)
+		delete(self.sims, sim.Name)
+		delete(self.specs, sim.Name)
+		return
+	}
+	self.logger.Infof("serving %d custom metrics for simulation %s", len(metrics.metrics), sim.Name)
+	self.sims[sim.Name] = metrics
+	self.specs[sim.Name] = spec
+}
+
+func (self *Server) simulationDeleted(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if sim, ok := obj.(metav1.Object); ok {
+		self.mutex.Lock()
+		defer self.mutex.Unlock()
+		delete(self.sims, sim.GetName())
+		delete(self.specs, sim.GetName())
+	}
+}
+
+// lookup returns the current value of the metric from the first running simulation (by name)
+// that has it
+func (self *Server) lookup(metricType simkubev1.CustomMetricType, namespace, name string) (resource.Quantity, bool) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	now := self.clock.Now()
+	simNames := lo.Keys(self.sims)
+	sort.Strings(simNames)
+	for _, simName := range simNames {
+		if value, ok := self.sims[simName].lookup(metricType, namespace, name, now); ok {
+			return value, true
+		}
+	}
+	return resource.Quantity{}, false
+}
+
+func groupHandler(gv schema.GroupVersion) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		version := metav1.GroupVersionForDiscovery{GroupVersion: gv.String(), Version: gv.Version}
+		writeJSON(w, http.StatusOK, &metav1.APIGroup{
+			TypeMeta:         metav1.TypeMeta{Kind: "APIGroup", APIVersion: "v1"},
+			Name:             gv.Group,
+			Versions:         []metav1.GroupVersionForDiscovery{version},
+			PreferredVersion: version,
+		})
+	}
+}
+
+// handleResources lists the metrics that the running simulations have; Object metrics are
+// served for any kind of object, so they're listed under "*"
+func (self *Server) handleResources(w http.ResponseWriter, r *http.Request) {
+	gv, kind := CustomGroupVersion, metricValueListKind
+	if strings.HasPrefix(r.URL.Path, "/apis/"+ExternalGroupVersion.Group) {
+		gv, kind = ExternalGroupVersion, externalMetricValueListKind
+	}
+
+	self.mutex.Lock()
+	names := map[string]bool{}
+	for _, sim := range self.sims {
+		for _, metric := range sim.metrics {
+			switch {
+			case metric.metricType == simkubev1.CustomMetricExternal && gv == ExternalGroupVersion:
+				names[metric.name] = true
+			case metric.metricType == simkubev1.CustomMetricPods && gv == CustomGroupVersion:
+				names["pods/"+metric.name] = true
+			case metric.metricType == simkubev1.CustomMetricObject && gv == CustomGroupVersion:
+				names["*/"+metric.name] = true
+			}
+		}
+	}
+	self.mutex.Unlock()
+
+	resources := make([]metav1.APIResource, 0, len(names))
+	for _, name := range lo.Keys(names) {
+		resources = append(resources, metav1.APIResource{Name: name, Kind: kind, Namespaced: true, Verbs: []string{"get"}})
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].Name < resources[j].Name })
+	writeJSON(w, http.StatusOK, &metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+		GroupVersion: gv.String(),
+		APIResources: resources,
+	})
+}
+
+// handleCustomMetrics serves namespaces/<ns>/<resource>/<name>/<metric> (and the same for
+// cluster-scoped objects without the namespace); for pods, the name can be * to get the metric
+// for all of the pods that match the labelSelector
+func (self *Server) handleCustomMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeStatus(w, apierrors.NewMethodNotSupported(CustomGroupVersion.WithResource("").GroupResource(), r.Method))
+		return
+	}
+
+	selector, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		writeStatus(w, apierrors.NewBadRequest(fmt.Sprintf("invalid label selector: %v", err)))
+		return
+	}
+
+	var namespace, resourceName, name, metricName string
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/apis/"+CustomGroupVersion.String()+"/"), "/")
+	switch {
+	case len(path) == 5 && path[0] == "namespaces":
+		namespace, resourceName, name, metricName = path[1], path[2], path[3], path[4]
+	case len(path) == 3:
+		resourceName, name, metricName = path[0], path[1], path[2]
+	default:
+		writeStatus(w, apierrors.NewNotFound(CustomGroupVersion.WithResource("").GroupResource(), r.URL.Path))
+		return
+	}
+
+	if resourceName == "pods" && namespace != "" {
+		if total, ok := self.lookup(simkubev1.CustomMetricPods, namespace, metricName); ok {
+			self.writePodMetrics(w, namespace, name, metricName, selector, total)
+			return
+		}
+	}
+
+	value, ok := self.lookup(simkubev1.CustomMetricObject, namespace, metricName)
+	if !ok || name == allPods {
+		writeStatus(w, apierrors.NewNotFound(CustomGroupVersion.WithResource(resourceName).GroupResource(), metricName))
+		return
+	}
+
+	described := corev1.ObjectReference{Namespace: namespace, Name: name}
+	if resourceName == "pods" {
+		described.Kind, described.APIVersion = "Pod", "v1"
+	}
+	writeJSON(w, http.StatusOK, &MetricValueList{
+		TypeMeta: metav1.TypeMeta{Kind: metricValueListKind, APIVersion: CustomGroupVersion.String()},
+		Items:    []MetricValue{self.metricValue(described, metricName, value)},
+	})
+}
+
+// Pods metrics are the total over all of the pods, which is split evenly between them, so that
+// scaling up lowers the value for each pod like real traffic would, and the HPA settles on a
+// replica count.  When the HPA asks for all of the pods that match its selector, the total is
+// split between those pods; when a single pod is asked for, it's split between the running pods
+// with the same owner.
+func (self *Server) writePodMetrics(
+	w http.ResponseWriter,
+	namespace, name, metricName string,
+	selector labels.Selector,
+	total resource.Quantity,
+) {
+	var pods []*corev1.Pod
+	var shares int
+	if name == allPods {
+		var err error
+		if pods, err = self.podLister.Pods(namespace).List(selector); err != nil {
+			writeStatus(w, apierrors.NewInternalError(err))
+			return
+		}
+		shares = len(pods)
+	} else {
+		pod, err := self.podLister.Pods(namespace).Get(name)
+		if err != nil {
+			writeStatus(w, apierrors.NewNotFound(CustomGroupVersion.WithResource("pods").GroupResource(), name))
+			return
+		}
+		pods = []*corev1.Pod{pod}
+		if shares, err = self.ownerPodCount(pod); err != nil {
+			writeStatus(w, apierrors.NewInternalError(err))
+			return
+		}
+	}
+
+	list := MetricValueList{
+		TypeMeta: metav1.TypeMeta{Kind: metricValueListKind, APIVersion: CustomGroupVersion.String()},
+		Items:    make([]MetricValue, 0, len(pods)),
+	}
+	for _, pod := range pods {
+		share := *resource.NewMilliQuantity(total.MilliValue()/int64(shares), total.Format)
+		described := corev1.ObjectReference{Kind: "Pod", APIVersion: "v1", Namespace: pod.Namespace, Name: pod.Name}
+		list.Items = append(list.Items, self.metricValue(described, metricName, share))
+	}
+	writeJSON(w, http.StatusOK, &list)
+}
+
+func (self *Server) ownerPodCount(pod *corev1.Pod) (int, error) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return 1, nil
+	}
+
+	pods, err := self.podLister.Pods(pod.Namespace).List(labels.Everything())
+	if err != nil {
+		return 0, fmt.Errorf("could not list pods: %w", err)
+	}
+	return lo.CountBy(pods, func(p *corev1.Pod) bool {
+		ref := metav1.GetControllerOf(p)
+		return ref != nil && ref.UID == owner.UID
+	}), nil
+}
+
+// handleExternalMetrics serves namespaces/<ns>/<metric>; the labelSelector is accepted, but
+// since the simulated metrics don't have any labels, it doesn't change the result
+func (self *Server) handleExternalMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeStatus(w, apierrors.NewMethodNotSupported(ExternalGroupVersion.WithResource("").GroupResource(), r.Method))
+		return
+	}
+
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/apis/"+ExternalGroupVersion.String()+"/"), "/")
+	if len(path) != 3 || path[0] != "namespaces" {
+		writeStatus(w, apierrors.NewNotFound(ExternalGroupVersion.WithResource("").GroupResource(), r.URL.Path))
+		return
+	}
+
+	namespace, metricName := path[1], path[2]
+	value, ok := self.lookup(simkubev1.CustomMetricExternal, namespace, metricName)
+	if !ok {
+		writeStatus(w, apierrors.NewNotFound(ExternalGroupVersion.WithResource("").GroupResource(), metricName))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &ExternalMetricValueList{
+		TypeMeta: metav1.TypeMeta{Kind: externalMetricValueListKind, APIVersion: ExternalGroupVersion.String()},
+		Items: []ExternalMetricValue{{
+			MetricName:    metricName,
+			MetricLabels:  map[string]string{},
+			Timestamp:     metav1.Time{Time: self.clock.Now()},
+			WindowSeconds: lo.ToPtr(int64(windowSeconds)),
+			Value:         value,
+		}},
+	})
+}
+
+func (self *Server) metricValue(
+	described corev1.ObjectReference,
+	metricName string,
+	value resource.Quantity,
+) MetricValue {
+	return MetricValue{
+		DescribedObject: described,
+		Metric:          MetricIdentifier{Name: metricName},
+		Timestamp:       metav1.Time{Time: self.clock.Now()},
+		WindowSeconds:   lo.ToPtr(int64(windowSeconds)),
+		Value:           value,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(obj); err != nil {
+		log.WithError(err).Warn("could not write custom metrics API response")
+	}
+}
+
+func writeStatus(w http.ResponseWriter, err *apierrors.StatusError) {
+	status := err.Status()
+	status.TypeMeta = metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}
+	writeJSON(w, int(status.Code), &status)
+}
@@ -0,0 +1,212 @@
+package custommetrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	simkubev1 "simkube/lib/go/api/v1"
+)
+
+var testStart = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) //nolint:gochecknoglobals
+
+func testPod(name string, owner types.UID) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "virtual-default",
+			Labels:          map[string]string{"app": "web"},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", UID: owner, Controller: lo.ToPtr(true)}},
+		},
+		Spec:   corev1.PodSpec{NodeName: "sk-vnode-1"},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func testSimulationObj(t *testing.T) *unstructured.Unstructured {
+	values := func(offsets ...int64) []simkubev1.CustomMetricValue {
+		res := make([]simkubev1.CustomMetricValue, 0, len(offsets))
+		for i, offset := range offsets {
+			value := *resource.NewQuantity(int64(10*(i+1)), resource.DecimalSI)
+			res = append(res, simkubev1.CustomMetricValue{OffsetSeconds: offset, Value: value})
+		}
+		return res
+	}
+	sim := &simkubev1.Simulation{
+		TypeMeta:   metav1.TypeMeta{APIVersion: simkubev1.GroupVersion.String(), Kind: "Simulation"},
+		ObjectMeta: metav1.ObjectMeta{Name: "test-sim"},
+		Spec: simkubev1.SimulationSpec{
+			Trace: "file:///data/trace",
+			Speed: 2,
+			CustomMetrics: []simkubev1.CustomMetric{
+				{Name: "requests_per_second", Type: simkubev1.CustomMetricPods, Namespace: "default", Values: values(0, 50)},
+				{Name: "queue_depth", Type: simkubev1.CustomMetricObject, Values: values(0)},
+				{Name: "backlog", Type: simkubev1.CustomMetricExternal, Values: values(120)},
+				{Name: "lag", Type: simkubev1.CustomMetricExternal, Namespace: "default", Values: values(0)},
+			},
+		},
+		Status: simkubev1.SimulationStatus{
+			Phase:     simkubev1.SimulationRunning,
+			StartTime: &metav1.Time{Time: testStart},
+		},
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(sim)
+	require.Nil(t, err)
+	return &unstructured.Unstructured{Object: obj}
+}
+
+func startTestServer(t *testing.T) *httptest.Server {
+	client := fake.NewSimpleClientset(
+		testPod("web-1", "abcd"),
+		testPod("web-2", "abcd"),
+		testPod("web-3", "abcd"),
+		testPod("other-1", "efgh"),
+	)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{simulationGVR: "SimulationList"},
+		testSimulationObj(t),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	srv := newServer(client, dynamicClient)
+	srv.clock = clockwork.NewFakeClockAt(testStart.Add(30 * time.Second))
+	require.Nil(t, srv.Start(ctx))
+	require.Eventually(t, func() bool {
+		srv.mutex.Lock()
+		defer srv.mutex.Unlock()
+		return len(srv.sims) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	httpSrv := httptest.NewServer(srv.Handler())
+	t.Cleanup(httpSrv.Close)
+	return httpSrv
+}
+
+func get(t *testing.T, srv *httptest.Server, path string, into interface{}) int {
+	resp, err := http.Get(srv.URL + path)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(into))
+	return resp.StatusCode
+}
+
+func TestServerDiscovery(t *testing.T) {
+	srv := startTestServer(t)
+
+	var resources metav1.APIResourceList
+	assert.Equal(t, http.StatusOK, get(t, srv, "/apis/custom.metrics.k8s.io/v1beta2", &resources))
+	assert.Equal(t, "custom.metrics.k8s.io/v1beta2", resources.GroupVersion)
+	assert.Equal(t, []string{"*/queue_depth", "pods/requests_per_second"}, resourceNames(resources))
+
+	assert.Equal(t, http.StatusOK, get(t, srv, "/apis/external.metrics.k8s.io/v1beta1", &resources))
+	assert.Equal(t, "external.metrics.k8s.io/v1beta1", resources.GroupVersion)
+	assert.Equal(t, []string{"backlog", "lag"}, resourceNames(resources))
+
+	var group metav1.APIGroup
+	assert.Equal(t, http.StatusOK, get(t, srv, "/apis/external.metrics.k8s.io", &group))
+	assert.Equal(t, "external.metrics.k8s.io/v1beta1", group.PreferredVersion.GroupVersion)
+}
+
+func resourceNames(resources metav1.APIResourceList) []string {
+	names := make([]string, 0, len(resources.APIResources))
+	for _, r := range resources.APIResources {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func TestServerCustomMetrics(t *testing.T) {
+	srv := startTestServer(t)
+
+	// The simulation is 30s in at double speed, so the second value of requests_per_second (20)
+	// is current, and it's split between the pods
+	for name, tc := range map[string]struct {
+		path     string
+		expected map[string]string
+	}{
+		"all pods": {
+			path:     "/namespaces/virtual-default/pods/*/requests_per_second?labelSelector=app%3Dweb",
+			expected: map[string]string{"web-1": "5", "web-2": "5", "web-3": "5", "other-1": "5"},
+		},
+		"selected pods": {
+			path:     "/namespaces/virtual-default/pods/*/requests_per_second?labelSelector=app%3Dnothing",
+			expected: map[string]string{},
+		},
+		"one pod": {
+			path:     "/namespaces/virtual-default/pods/web-1/requests_per_second",
+			expected: map[string]string{"web-1": "6666m"},
+		},
+		"object": {
+			path:     "/namespaces/virtual-default/services/web/queue_depth",
+			expected: map[string]string{"web": "10"},
+		},
+		"cluster-scoped object": {
+			path:     "/nodes/sk-vnode-1/queue_depth",
+			expected: map[string]string{"sk-vnode-1": "10"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var list MetricValueList
+			assert.Equal(t, http.StatusOK, get(t, srv, "/apis/custom.metrics.k8s.io/v1beta2"+tc.path, &list))
+			assert.Equal(t, metricValueListKind, list.Kind)
+			values := map[string]string{}
+			for _, item := range list.Items {
+				values[item.DescribedObject.Name] = item.Value.String()
+				assert.Equal(t, testStart.Add(30*time.Second), item.Timestamp.Time.UTC())
+			}
+			assert.Equal(t, tc.expected, values)
+		})
+	}
+}
+
+func TestServerCustomMetricsNotFound(t *testing.T) {
+	srv := startTestServer(t)
+
+	for _, path := range []string{
+		"/namespaces/default/pods/*/requests_per_second",
+		"/namespaces/virtual-default/pods/missing/requests_per_second",
+		"/namespaces/virtual-default/services/*/queue_depth",
+		"/namespaces/virtual-default/services/web/missing",
+	} {
+		var status metav1.Status
+		assert.Equal(t, http.StatusNotFound, get(t, srv, "/apis/custom.metrics.k8s.io/v1beta2"+path, &status), path)
+		assert.Equal(t, metav1.StatusReasonNotFound, status.Reason, path)
+	}
+}
+
+func TestServerExternalMetrics(t *testing.T) {
+	srv := startTestServer(t)
+
+	var list ExternalMetricValueList
+	path := "/apis/external.metrics.k8s.io/v1beta1/namespaces/virtual-default/lag"
+	assert.Equal(t, http.StatusOK, get(t, srv, path, &list))
+	assert.Equal(t, externalMetricValueListKind, list.Kind)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "lag", list.Items[0].MetricName)
+	assert.Equal(t, "10", list.Items[0].Value.String())
+
+	// backlog doesn't have a value until 120s into the trace, and lag is only in virtual-default
+	var status metav1.Status
+	for _, path := range []string{"namespaces/virtual-default/backlog", "namespaces/test/lag"} {
+		assert.Equal(t, http.StatusNotFound, get(t, srv, "/apis/external.metrics.k8s.io/v1beta1/"+path, &status), path)
+	}
+}
@@ -0,0 +1,163 @@
+package custommetrics
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/trace"
+)
+
+// This is the prefix that sk-ctrl tells the driver to replay the trace's namespaces into
+const virtualNsPrefix = "virtual"
+
+type servedMetric struct {
+	name       string
+	metricType simkubev1.CustomMetricType
+
+	// namespace is the namespace that the trace is replayed into, i.e., the one that HPAs ask
+	// for the metric in; if empty, the metric is served in every namespace
+	namespace string
+	values    series
+}
+
+// simMetrics are the custom metrics for a running simulation.  The metrics follow the trace, so
+// (like the lifetimes of the simulated pods) they're frozen while the simulation is paused, and
+// they move faster if the trace is replayed faster.
+type simMetrics struct {
+	start   time.Time
+	speed   float64
+	metrics []servedMetric
+
+	paused      bool
+	pausedAt    time.Time
+	pausedTotal time.Duration
+}
+
+type traceLoader func(location string) (*trace.Trace, error)
+
+func newSimMetrics(sim *simkubev1.Simulation, loadTrace traceLoader, now time.Time) (*simMetrics, error) {
+	res := &simMetrics{
+		start: sim.Status.StartTime.Time,
+		speed: sim.Spec.Speed,
+	}
+	if res.speed <= 0 {
+		res.speed = simkubev1.DefaultSimulationSpeed
+	}
+	res.setPaused(sim.Spec.Paused, now)
+
+	var tr *trace.Trace
+	for i := range sim.Spec.CustomMetrics {
+		metric := &sim.Spec.CustomMetrics[i]
+		if !metric.FromTrace {
+			res.addMetric(&sim.Spec, metric, metric.Namespace, seriesFromValues(metric.Values))
+			continue
+		}
+
+		if tr == nil {
+			var err error
+			if tr, err = loadTrace(sim.Spec.Trace); err != nil {
+				return nil, err
+			}
+		}
+		for namespace, values := range tracedSeries(tr, metric) {
+			res.addMetric(&sim.Spec, metric, namespace, values)
+		}
+	}
+	return res, nil
+}
+
+func (self *simMetrics) addMetric(
+	spec *simkubev1.SimulationSpec,
+	metric *simkubev1.CustomMetric,
+	namespace string,
+	values series,
+) {
+	if namespace != "" {
+		namespace = virtualNamespace(spec, namespace)
+	}
+	self.metrics = append(self.metrics, servedMetric{
+		name:       metric.Name,
+		metricType: metric.Type,
+		namespace:  namespace,
+		values:     values,
+	})
+}
+
+func (self *simMetrics) setPaused(paused bool, now time.Time) {
+	if paused && !self.paused {
+		self.pausedAt = now
+	} else if !paused && self.paused {
+		self.pausedTotal += now.Sub(self.pausedAt)
+	}
+	self.paused = paused
+}
+
+// offset is how far into the trace the simulation is
+func (self *simMetrics) offset(now time.Time) time.Duration {
+	if self.paused {
+		now = self.pausedAt
+	}
+	elapsed := now.Sub(self.start) - self.pausedTotal
+	return time.Duration(float64(elapsed) * self.speed)
+}
+
+// lookup returns the current value of the metric in the namespace; metrics that are only served
+// in that namespace take precedence over ones that are served everywhere
+func (self *simMetrics) lookup(
+	metricType simkubev1.CustomMetricType,
+	namespace, name string,
+	now time.Time,
+) (resource.Quantity, bool) {
+	var fallback *servedMetric
+	for i := range self.metrics {
+		metric := &self.metrics[i]
+		if metric.metricType != metricType || metric.name != name {
+			continue
+		} else if metric.namespace == namespace {
+			return metric.values.at(self.offset(now))
+		} else if metric.namespace == "" && fallback == nil {
+			fallback = metric
+		}
+	}
+
+	if fallback == nil {
+		return resource.Quantity{}, false
+	}
+	return fallback.values.at(self.offset(now))
+}
+
+func virtualNamespace(spec *simkubev1.SimulationSpec, origNamespace string) string {
+	if ns, ok := spec.NamespaceMap[origNamespace]; ok {
+		return ns
+	}
+	return fmt.Sprintf("%s-%s", virtualNsPrefix, origNamespace)
+}
+
+// readTrace reads the simulation's trace; like the simulation results, only local (file://)
+// traces are supported, so the trace has to be mounted into the pod at the same path as in the
+// driver
+func readTrace(location string) (*trace.Trace, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trace location %s: %w", location, err)
+	} else if u.Scheme != "file" && u.Scheme != "" {
+		return nil, fmt.Errorf("only local traces are supported: %s", location)
+	}
+
+	f, err := os.Open(u.Path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open trace %s: %w", location, err)
+	}
+	defer f.Close()
+
+	tr, err := trace.ReadTrace(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not read trace %s: %w", location, err)
+	}
+	return tr, nil
+}
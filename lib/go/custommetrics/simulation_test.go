@@ -0,0 +1,83 @@
+package custommetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/trace"
+)
+
+func TestSimMetricsOffset(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	metrics := &simMetrics{start: start, speed: 2}
+
+	assert.Equal(t, 20*time.Second, metrics.offset(start.Add(10*time.Second)))
+
+	metrics.setPaused(true, start.Add(10*time.Second))
+	assert.Equal(t, 20*time.Second, metrics.offset(start.Add(time.Minute)))
+
+	metrics.setPaused(false, start.Add(time.Minute))
+	assert.Equal(t, 30*time.Second, metrics.offset(start.Add(time.Minute+5*time.Second)))
+}
+
+func TestSimMetricsLookup(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	value := func(v string) []simkubev1.CustomMetricValue {
+		return []simkubev1.CustomMetricValue{{Value: resource.MustParse(v)}}
+	}
+	sim := &simkubev1.Simulation{
+		Spec: simkubev1.SimulationSpec{
+			Trace:        "file:///data/trace",
+			NamespaceMap: map[string]string{"prod": "sim-prod"},
+			CustomMetrics: []simkubev1.CustomMetric{
+				{Name: "rps", Type: simkubev1.CustomMetricPods, Values: value("1")},
+				{Name: "rps", Type: simkubev1.CustomMetricPods, Namespace: "prod", Values: value("2")},
+				{Name: "rps", Type: simkubev1.CustomMetricPods, Namespace: "default", Values: value("3")},
+				{Name: "lag", Type: simkubev1.CustomMetricExternal, FromTrace: true},
+			},
+		},
+		Status: simkubev1.SimulationStatus{StartTime: &metav1.Time{Time: start}},
+	}
+	loadTrace := func(location string) (*trace.Trace, error) {
+		assert.Equal(t, "file:///data/trace", location)
+		return &trace.Trace{Events: []trace.Event{
+			{AppliedObjs: []*unstructured.Unstructured{testHPAObj("prod", 1, testExternalMetric("lag", "4"))}},
+		}}, nil
+	}
+	metrics, err := newSimMetrics(sim, loadTrace, start)
+	require.Nil(t, err)
+
+	for name, tc := range map[string]struct {
+		metricType simkubev1.CustomMetricType
+		namespace  string
+		name       string
+		expected   string
+	}{
+		"served everywhere": {metricType: simkubev1.CustomMetricPods, namespace: "test", name: "rps", expected: "1"},
+		"mapped namespace":  {metricType: simkubev1.CustomMetricPods, namespace: "sim-prod", name: "rps", expected: "2"},
+		"virtual namespace": {
+			metricType: simkubev1.CustomMetricPods,
+			namespace:  "virtual-default",
+			name:       "rps",
+			expected:   "3",
+		},
+		"from trace":       {metricType: simkubev1.CustomMetricExternal, namespace: "sim-prod", name: "lag", expected: "4"},
+		"not in namespace": {metricType: simkubev1.CustomMetricExternal, namespace: "test", name: "lag"},
+		"wrong type":       {metricType: simkubev1.CustomMetricObject, namespace: "test", name: "rps"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			value, ok := metrics.lookup(tc.metricType, tc.namespace, tc.name, start.Add(time.Second))
+			assert.Equal(t, tc.expected != "", ok)
+			if ok {
+				assert.Equal(t, tc.expected, value.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package custommetrics
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// These are the parts of the custom metrics API (custom.metrics.k8s.io/v1beta2) and the external
+// metrics API (external.metrics.k8s.io/v1beta1) that the HPA uses; like the resource metrics
+// API, we don't import k8s.io/metrics for a handful of types
+const (
+	metricValueListKind         = "MetricValueList"
+	externalMetricValueListKind = "ExternalMetricValueList"
+)
+
+//nolint:gochecknoglobals
+var (
+	CustomGroupVersion   = schema.GroupVersion{Group: "custom.metrics.k8s.io", Version: "v1beta2"}
+	ExternalGroupVersion = schema.GroupVersion{Group: "external.metrics.k8s.io", Version: "v1beta1"}
+)
+
+type MetricValueList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []MetricValue `json:"items"`
+}
+
+type MetricValue struct {
+	DescribedObject corev1.ObjectReference `json:"describedObject"`
+	Metric          MetricIdentifier       `json:"metric"`
+	Timestamp       metav1.Time            `json:"timestamp"`
+	WindowSeconds   *int64                 `json:"windowSeconds,omitempty"`
+	Value           resource.Quantity      `json:"value"`
+}
+
+type MetricIdentifier struct {
+	Name     string                `json:"name"`
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+type ExternalMetricValueList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ExternalMetricValue `json:"items"`
+}
+
+type ExternalMetricValue struct {
+	MetricName    string            `json:"metricName"`
+	MetricLabels  map[string]string `json:"metricLabels"`
+	Timestamp     metav1.Time       `json:"timestamp"`
+	WindowSeconds *int64            `json:"window,omitempty"`
+	Value         resource.Quantity `json:"value"`
+}
@@ -0,0 +1,82 @@
+package debugserver
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	readHeaderTimeout = 5 * time.Second
+	shutdownTimeout   = 5 * time.Second
+
+	// The CPU profile and execution trace endpoints take as long as the client asks for
+	// (30s by default for the profile), so the write timeout has to be a lot longer
+	writeTimeout = 5 * time.Minute
+)
+
+//nolint:gochecknoglobals
+var publishRuntimeStats sync.Once
+
+// Handler serves the standard Go diagnostics: the net/http/pprof profiles under /debug/pprof/,
+// and the expvar variables (the memory stats, the command line, and anything else that the
+// component publishes, e.g., the leader election state) as JSON at /debug/vars, plus a few
+// runtime stats (the number of goroutines, CPUs, and cgo calls) under "runtime"
+func Handler() http.Handler {
+	publishRuntimeStats.Do(func() {
+		expvar.Publish("runtime", expvar.Func(func() interface{} {
+			return map[string]interface{}{
+				"goroutines": runtime.NumGoroutine(),
+				"cpus":       runtime.NumCPU(),
+				"gomaxprocs": runtime.GOMAXPROCS(0),
+				"cgo_calls":  runtime.NumCgoCall(),
+				"go_version": runtime.Version(),
+			}
+		}))
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// Run serves the diagnostics on addr in the background, until ctx is cancelled; the profiles
+// can reveal a lot about the process (e.g., its command line), so addr should not be reachable
+// from outside the cluster
+func Run(ctx context.Context, addr string) {
+	logger := log.WithFields(log.Fields{"component": "debug-server"})
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+		WriteTimeout:      writeTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logger.WithError(err).Warn("could not shut down debug server")
+		}
+	}()
+
+	go func() {
+		logger.Infof("debug server listening on %s", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.WithError(err).Error("debug server failed")
+		}
+	}()
+}
@@ -0,0 +1,52 @@
+package debugserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	cases := map[string]struct {
+		path         string
+		expectedCode int
+	}{
+		"pprof index": {path: "/debug/pprof/", expectedCode: http.StatusOK},
+		"heap":        {path: "/debug/pprof/heap?debug=1", expectedCode: http.StatusOK},
+		"goroutines":  {path: "/debug/pprof/goroutine?debug=1", expectedCode: http.StatusOK},
+		"cmdline":     {path: "/debug/pprof/cmdline", expectedCode: http.StatusOK},
+		"expvar":      {path: "/debug/vars", expectedCode: http.StatusOK},
+		"unknown":     {path: "/foo", expectedCode: http.StatusNotFound},
+	}
+
+	handler := Handler()
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestHandlerRuntimeStats(t *testing.T) {
+	// Handler can be called more than once without publishing the runtime stats twice
+	Handler()
+	handler := Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var vars struct {
+		MemStats map[string]interface{} `json:"memstats"`
+		Runtime  map[string]interface{} `json:"runtime"`
+	}
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &vars))
+	assert.Contains(t, vars.MemStats, "HeapAlloc")
+	assert.Greater(t, vars.Runtime["goroutines"], float64(0))
+}
@@ -0,0 +1,234 @@
+package dra
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+
+	"simkube/lib/go/k8s"
+)
+
+// podResource is used to read the pod's claims, since the pod types that we build against
+// have the claim names in a different place than newer versions of Kubernetes
+//
+//nolint:gochecknoglobals
+var podResource = corev1.SchemeGroupVersion.WithResource("pods")
+
+// The Allocator stands in for the parts of DRA that would make a pod's ResourceClaims usable
+// on the node: if the scheduler hasn't allocated a claim already (e.g., because DRA isn't enabled
+// in the scheduler), it's allocated from the node's devices, and every claim is reserved for the
+// pod.  Device classes and selectors aren't evaluated; any free device on the node will do.
+type Allocator struct {
+	dynamicClient dynamic.Interface
+	nodeName      string
+	cfg           *DeviceConfig
+
+	// Allocations are serialized, so that two pods can't be given the same device
+	mutex sync.Mutex
+}
+
+func NewAllocator(dynamicClient dynamic.Interface, nodeName string, cfg *DeviceConfig) *Allocator {
+	return &Allocator{dynamicClient: dynamicClient, nodeName: nodeName, cfg: cfg}
+}
+
+// AllocateClaims returns true once all of the pod's ResourceClaims are allocated and reserved
+// for the pod; it returns false if a claim doesn't exist yet, or if there aren't enough free
+// devices on the node to allocate it.
+func (self *Allocator) AllocateClaims(ctx context.Context, pod *corev1.Pod) (bool, error) {
+	claimNames, ok, err := self.podClaimNames(ctx, pod)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	claims := self.dynamicClient.Resource(ResourceClaimResource).Namespace(pod.Namespace)
+	for _, name := range claimNames {
+		obj, err := claims.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("could not get ResourceClaim %s: %w", name, err)
+		}
+		claim, err := ClaimFromUnstructured(obj)
+		if err != nil {
+			return false, err
+		}
+
+		reserved := lo.ContainsBy(
+			claim.Status.ReservedFor,
+			func(ref ResourceClaimConsumerReference) bool { return ref.UID == pod.UID },
+		)
+		if claim.Status.Allocation != nil && reserved {
+			continue
+		}
+
+		if claim.Status.Allocation == nil {
+			inUse, err := self.devicesInUse(ctx)
+			if err != nil {
+				return false, err
+			}
+			if claim.Status.Allocation = self.allocate(claim, inUse); claim.Status.Allocation == nil {
+				return false, nil
+			}
+		}
+		if !reserved {
+			claim.Status.ReservedFor = append(claim.Status.ReservedFor, ResourceClaimConsumerReference{
+				Resource: "pods",
+				Name:     pod.Name,
+				UID:      pod.UID,
+			})
+		}
+
+		if err := self.updateClaimStatus(ctx, obj, claim); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// The claims are listed in the pod spec, either by name or (for claims generated from a
+// ResourceClaimTemplate) in the pod status once the claim has been created; the second return
+// value is false if the generated claims don't exist yet.  Newer versions of Kubernetes have
+// the claim name directly in the spec, older ones have it under "source".
+func (self *Allocator) podClaimNames(ctx context.Context, pod *corev1.Pod) ([]string, bool, error) {
+	obj, err := self.dynamicClient.Resource(podResource).Namespace(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, false, fmt.Errorf("could not get pod %s: %w", pod.Name, err)
+	}
+
+	generated := map[string]string{}
+	statuses, _, _ := unstructured.NestedSlice(obj.Object, "status", "resourceClaimStatuses")
+	for _, s := range statuses {
+		if s, ok := s.(map[string]interface{}); ok {
+			name, _, _ := unstructured.NestedString(s, "name")
+			generated[name], _, _ = unstructured.NestedString(s, "resourceClaimName")
+		}
+	}
+
+	var names []string
+	podClaims, _, _ := unstructured.NestedSlice(obj.Object, "spec", "resourceClaims")
+	for _, c := range podClaims {
+		c, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		podClaimName, _, _ := unstructured.NestedString(c, "name")
+		name, _, _ := unstructured.NestedString(c, "resourceClaimName")
+		if name == "" {
+			name, _, _ = unstructured.NestedString(c, "source", "resourceClaimName")
+		}
+		if name == "" {
+			// An empty generated name means that the claim isn't needed
+			if name, ok = generated[podClaimName]; !ok {
+				return nil, false, nil
+			} else if name == "" {
+				continue
+			}
+		}
+		names = append(names, name)
+	}
+	return names, true, nil
+}
+
+// devicesInUse are the devices on this node that are allocated to any claim, by us or by the
+// scheduler
+func (self *Allocator) devicesInUse(ctx context.Context) (map[string]bool, error) {
+	objs, err := self.dynamicClient.Resource(ResourceClaimResource).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not list ResourceClaims: %w", err)
+	}
+
+	inUse := map[string]bool{}
+	for i := range objs.Items {
+		claim, err := ClaimFromUnstructured(&objs.Items[i])
+		if err != nil || claim.Status.Allocation == nil {
+			continue
+		}
+		for _, res := range claim.Status.Allocation.Devices.Results {
+			if res.Driver == self.cfg.Driver && res.Pool == self.nodeName {
+				inUse[res.Device] = true
+			}
+		}
+	}
+	return inUse, nil
+}
+
+// allocate picks free devices for each of the claim's requests, in the order that they're
+// configured; it returns nil if there aren't enough
+func (self *Allocator) allocate(claim *ResourceClaim, inUse map[string]bool) *AllocationResult {
+	free := lo.Filter(self.cfg.DeviceNames(), func(name string, _ int) bool { return !inUse[name] })
+
+	var results []DeviceRequestAllocationResult
+	for _, req := range claim.Spec.Devices.Requests {
+		count := int(req.Count)
+		if req.AllocationMode == DeviceAllocationModeAll {
+			count = len(free)
+		} else if count == 0 {
+			count = 1
+		}
+		if count == 0 || count > len(free) {
+			return nil
+		}
+
+		for _, device := range free[:count] {
+			results = append(results, DeviceRequestAllocationResult{
+				Request: req.Name,
+				Driver:  self.cfg.Driver,
+				Pool:    self.nodeName,
+				Device:  device,
+			})
+		}
+		free = free[count:]
+	}
+
+	return &AllocationResult{
+		Devices: DeviceAllocationResult{Results: results},
+		NodeSelector: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+			MatchFields: []corev1.NodeSelectorRequirement{{
+				Key:      "metadata.name",
+				Operator: corev1.NodeSelectorOpIn,
+				Values:   []string{self.nodeName},
+			}},
+		}}},
+	}
+}
+
+// Only the allocation and reservations are changed, so that any other fields in the status
+// (which our types don't know about) are left alone
+func (self *Allocator) updateClaimStatus(
+	ctx context.Context,
+	obj *unstructured.Unstructured,
+	claim *ResourceClaim,
+) error {
+	status, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&claim.Status)
+	if err != nil {
+		return fmt.Errorf("could not build status for ResourceClaim %s: %w", claim.Name, err)
+	}
+
+	obj = obj.DeepCopy()
+	if current, ok := obj.Object["status"].(map[string]interface{}); !ok || current == nil {
+		obj.Object["status"] = map[string]interface{}{}
+	}
+	for _, field := range []string{"allocation", "reservedFor"} {
+		if err := unstructured.SetNestedField(obj.Object, status[field], "status", field); err != nil {
+			return fmt.Errorf("could not set status for ResourceClaim %s: %w", claim.Name, err)
+		}
+	}
+
+	return k8s.Retry(func() error {
+		_, err := self.dynamicClient.Resource(ResourceClaimResource).Namespace(claim.Namespace).
+			UpdateStatus(ctx, obj, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("could not update ResourceClaim %s: %w", claim.Name, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,170 @@
+package dra
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+const (
+	testNamespace = "test"
+	testNodeName  = "test-node"
+)
+
+func testPod(name string, uid string, claims []interface{}, statuses []interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"namespace": testNamespace, "name": name, "uid": uid},
+		"spec":       map[string]interface{}{"resourceClaims": claims},
+		"status":     map[string]interface{}{"resourceClaimStatuses": statuses},
+	}}
+}
+
+func testClaim(name string, requests []interface{}, status map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "resource.k8s.io/v1beta1",
+		"kind":       "ResourceClaim",
+		"metadata":   map[string]interface{}{"namespace": testNamespace, "name": name},
+		"spec":       map[string]interface{}{"devices": map[string]interface{}{"requests": requests}},
+		"status":     status,
+	}}
+}
+
+func request(name string, mode string, count int64) map[string]interface{} {
+	return map[string]interface{}{
+		"name":            name,
+		"deviceClassName": "gpu.nvidia.com",
+		"allocationMode":  mode,
+		"count":           count,
+	}
+}
+
+func newTestAllocator(objs ...runtime.Object) *Allocator {
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			ResourceClaimResource: "ResourceClaimList",
+			podResource:           "PodList",
+		},
+		objs...,
+	)
+	return NewAllocator(dynamicClient, testNodeName, testConfig())
+}
+
+func getClaim(t *testing.T, alloc *Allocator, name string) *ResourceClaim {
+	t.Helper()
+
+	obj, err := alloc.dynamicClient.Resource(ResourceClaimResource).Namespace(testNamespace).
+		Get(context.TODO(), name, metav1.GetOptions{})
+	assert.Nil(t, err)
+	claim, err := ClaimFromUnstructured(obj)
+	assert.Nil(t, err)
+	return claim
+}
+
+func allocatedDevices(claim *ResourceClaim) []string {
+	if claim.Status.Allocation == nil {
+		return nil
+	}
+
+	var devices []string
+	for _, res := range claim.Status.Allocation.Devices.Results {
+		devices = append(devices, res.Device)
+	}
+	return devices
+}
+
+func TestAllocateClaims(t *testing.T) {
+	alloc := newTestAllocator(
+		// pod-a refers to its claim by name (new API) and from a template; pod-b uses the old API
+		testPod("pod-a", "uid-a",
+			[]interface{}{
+				map[string]interface{}{"name": "gpus", "resourceClaimName": "claim-a"},
+				map[string]interface{}{"name": "mig", "resourceClaimTemplateName": "mig-template"},
+			},
+			[]interface{}{map[string]interface{}{"name": "mig", "resourceClaimName": "pod-a-mig-xyz"}},
+		),
+		testPod("pod-b", "uid-b",
+			[]interface{}{map[string]interface{}{
+				"name":   "gpus",
+				"source": map[string]interface{}{"resourceClaimName": "claim-b"},
+			}},
+			nil,
+		),
+		testClaim("claim-a", []interface{}{request("gpus", DeviceAllocationModeExactCount, 2)}, nil),
+		testClaim("pod-a-mig-xyz", []interface{}{request("mig", "", 0)}, nil),
+		testClaim("claim-b", []interface{}{request("gpus", DeviceAllocationModeAll, 0)}, nil),
+	)
+
+	allocated, err := alloc.AllocateClaims(context.TODO(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "pod-a", UID: "uid-a"},
+	})
+	assert.Nil(t, err)
+	assert.True(t, allocated)
+
+	claimA := getClaim(t, alloc, "claim-a")
+	assert.Equal(t, []string{"gpu-0", "gpu-1"}, allocatedDevices(claimA))
+	assert.Equal(t, testNodeName, claimA.Status.Allocation.Devices.Results[0].Pool)
+	assert.Equal(t, testNodeName, claimA.Status.Allocation.NodeSelector.NodeSelectorTerms[0].MatchFields[0].Values[0])
+	assert.Equal(t, []ResourceClaimConsumerReference{{Resource: "pods", Name: "pod-a", UID: "uid-a"}},
+		claimA.Status.ReservedFor)
+	assert.Equal(t, []string{"mig"}, allocatedDevices(getClaim(t, alloc, "pod-a-mig-xyz")))
+
+	// Every device is in use, so pod-b's claim can't be allocated
+	allocated, err = alloc.AllocateClaims(context.TODO(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "pod-b", UID: "uid-b"},
+	})
+	assert.Nil(t, err)
+	assert.False(t, allocated)
+	assert.Nil(t, getClaim(t, alloc, "claim-b").Status.Allocation)
+}
+
+func TestAllocateClaimsAlreadyAllocated(t *testing.T) {
+	// The scheduler allocated the claim to a device that we didn't pick ourselves
+	status := map[string]interface{}{"allocation": map[string]interface{}{
+		"devices": map[string]interface{}{"results": []interface{}{map[string]interface{}{
+			"request": "gpus",
+			"driver":  "gpu.nvidia.com",
+			"pool":    testNodeName,
+			"device":  "gpu-1",
+		}}},
+	}}
+	alloc := newTestAllocator(
+		testPod("pod-a", "uid-a", []interface{}{map[string]interface{}{"name": "gpus", "resourceClaimName": "claim-a"}}, nil),
+		testClaim("claim-a", []interface{}{request("gpus", "", 1)}, status),
+	)
+
+	allocated, err := alloc.AllocateClaims(context.TODO(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "pod-a", UID: "uid-a"},
+	})
+	assert.Nil(t, err)
+	assert.True(t, allocated)
+
+	claim := getClaim(t, alloc, "claim-a")
+	assert.Equal(t, []string{"gpu-1"}, allocatedDevices(claim))
+	assert.Len(t, claim.Status.ReservedFor, 1)
+}
+
+func TestAllocateClaimsNotCreated(t *testing.T) {
+	// The claim for the template hasn't been generated yet
+	alloc := newTestAllocator(
+		testPod("pod-a", "uid-a",
+			[]interface{}{map[string]interface{}{"name": "mig", "resourceClaimTemplateName": "mig-template"}},
+			nil,
+		),
+	)
+
+	allocated, err := alloc.AllocateClaims(context.TODO(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "pod-a", UID: "uid-a"},
+	})
+	assert.Nil(t, err)
+	assert.False(t, allocated)
+}
@@ -0,0 +1,177 @@
+package dra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/util"
+)
+
+var errorInvalidDeviceConfig = util.WithKind(util.ErrValidation, errors.New("invalid DRA device config"))
+
+// A DeviceConfig describes the devices that a virtual node advertises for Dynamic Resource
+// Allocation, as though a DRA driver were running on the node
+type DeviceConfig struct {
+	// Driver is the name of the (simulated) DRA driver, e.g., gpu.nvidia.com
+	Driver string `json:"driver"`
+
+	Devices []DeviceSpec `json:"devices"`
+}
+
+type DeviceSpec struct {
+	// Name is the device name; if Count is more than 1, the devices are named <name>-0,
+	// <name>-1, and so on
+	Name  string `json:"name"`
+	Count int    `json:"count,omitempty"`
+
+	Attributes map[string]DeviceAttribute   `json:"attributes,omitempty"`
+	Capacity   map[string]resource.Quantity `json:"capacity,omitempty"`
+}
+
+func LoadDeviceConfig(configFile string) (*DeviceConfig, error) {
+	configBytes, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", configFile, err)
+	}
+
+	var cfg DeviceConfig
+	if err = yaml.UnmarshalStrict(configBytes, &cfg); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", configFile, err)
+	}
+	if err = validateDeviceConfig(&cfg); err != nil {
+		return nil, fmt.Errorf("could not load %s: %w", configFile, err)
+	}
+	return &cfg, nil
+}
+
+func validateDeviceConfig(cfg *DeviceConfig) error {
+	if errs := validation.IsDNS1123Subdomain(cfg.Driver); len(errs) > 0 {
+		return fmt.Errorf("%w: invalid driver name %q", errorInvalidDeviceConfig, cfg.Driver)
+	}
+
+	names := map[string]bool{}
+	for _, d := range cfg.Devices {
+		if d.Count < 0 {
+			return fmt.Errorf("%w: device %s has a negative count", errorInvalidDeviceConfig, d.Name)
+		}
+		for _, name := range d.deviceNames() {
+			if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+				return fmt.Errorf("%w: invalid device name %q", errorInvalidDeviceConfig, name)
+			} else if names[name] {
+				return fmt.Errorf("%w: duplicate device %s", errorInvalidDeviceConfig, name)
+			}
+			names[name] = true
+		}
+	}
+	return nil
+}
+
+func (self *DeviceSpec) deviceNames() []string {
+	if self.Count == 0 {
+		return []string{self.Name}
+	}
+
+	names := make([]string, self.Count)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-%d", self.Name, i)
+	}
+	return names
+}
+
+// DeviceNames are the names of all of the devices, in the order that they're allocated
+func (self *DeviceConfig) DeviceNames() []string {
+	var names []string
+	for i := range self.Devices {
+		names = append(names, self.Devices[i].deviceNames()...)
+	}
+	return names
+}
+
+// ResourceSlice builds the slice that advertises the node's devices; every node has its own
+// pool (named after the node) with a single slice in it.  The slice is owned by the node, so
+// that it's garbage collected when the node is deleted.
+func (self *DeviceConfig) ResourceSlice(node *corev1.Node) *ResourceSlice {
+	var devices []Device
+	for i := range self.Devices {
+		spec := &self.Devices[i]
+		capacity := make(map[string]DeviceCapacity, len(spec.Capacity))
+		for name, q := range spec.Capacity {
+			capacity[name] = DeviceCapacity{Value: q.DeepCopy()}
+		}
+
+		for _, name := range spec.deviceNames() {
+			devices = append(devices, Device{
+				Name:  name,
+				Basic: &BasicDevice{Attributes: spec.Attributes, Capacity: capacity},
+			})
+		}
+	}
+
+	return &ResourceSlice{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: ResourceSliceResource.GroupVersion().String(),
+			Kind:       "ResourceSlice",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("%s-%s", node.Name, self.Driver),
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: corev1.SchemeGroupVersion.String(),
+				Kind:       "Node",
+				Name:       node.Name,
+				UID:        node.UID,
+			}},
+		},
+		Spec: ResourceSliceSpec{
+			Driver:   self.Driver,
+			Pool:     ResourcePool{Name: node.Name, Generation: 1, ResourceSliceCount: 1},
+			NodeName: node.Name,
+			Devices:  devices,
+		},
+	}
+}
+
+// PublishResourceSlice creates (or replaces) the node's ResourceSlice
+func PublishResourceSlice(
+	ctx context.Context,
+	dynamicClient dynamic.Interface,
+	cfg *DeviceConfig,
+	node *corev1.Node,
+) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cfg.ResourceSlice(node))
+	if err != nil {
+		return fmt.Errorf("could not build ResourceSlice: %w", err)
+	}
+	slice := &unstructured.Unstructured{Object: content}
+	slices := dynamicClient.Resource(ResourceSliceResource)
+
+	return k8s.Retry(func() error {
+		current, err := slices.Get(ctx, slice.GetName(), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			if _, err = slices.Create(ctx, slice, metav1.CreateOptions{}); err != nil {
+				return fmt.Errorf("could not create ResourceSlice %s: %w", slice.GetName(), err)
+			}
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("could not get ResourceSlice %s: %w", slice.GetName(), err)
+		}
+
+		slice.SetResourceVersion(current.GetResourceVersion())
+		if _, err = slices.Update(ctx, slice, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("could not update ResourceSlice %s: %w", slice.GetName(), err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,121 @@
+package dra
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+const testDeviceConfig = `---
+driver: gpu.nvidia.com
+devices:
+  - name: gpu
+    count: 2
+    attributes:
+      model: {string: A100}
+    capacity:
+      memory: 80Gi
+  - name: mig
+`
+
+func testConfig() *DeviceConfig {
+	return &DeviceConfig{
+		Driver: "gpu.nvidia.com",
+		Devices: []DeviceSpec{
+			{
+				Name:       "gpu",
+				Count:      2,
+				Attributes: map[string]DeviceAttribute{"model": {StringValue: lo.ToPtr("A100")}},
+				Capacity:   map[string]resource.Quantity{"memory": resource.MustParse("80Gi")},
+			},
+			{Name: "mig"},
+		},
+	}
+}
+
+func TestLoadDeviceConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "devices.yml")
+	assert.Nil(t, os.WriteFile(configFile, []byte(testDeviceConfig), 0o600))
+
+	cfg, err := LoadDeviceConfig(configFile)
+	assert.Nil(t, err)
+	assert.Equal(t, testConfig(), cfg)
+	assert.Equal(t, []string{"gpu-0", "gpu-1", "mig"}, cfg.DeviceNames())
+
+	assert.Nil(t, os.WriteFile(configFile, []byte("driver: gpu.nvidia.com\ndevice: []\n"), 0o600))
+	_, err = LoadDeviceConfig(configFile)
+	assert.NotNil(t, err)
+}
+
+func TestValidateDeviceConfig(t *testing.T) {
+	cases := map[string]struct {
+		modify    func(*DeviceConfig)
+		expectErr bool
+	}{
+		"valid":     {modify: func(*DeviceConfig) {}},
+		"no driver": {modify: func(cfg *DeviceConfig) { cfg.Driver = "" }, expectErr: true},
+		"invalid name": {
+			modify:    func(cfg *DeviceConfig) { cfg.Devices[1].Name = "MIG" },
+			expectErr: true,
+		},
+		"negative count": {
+			modify:    func(cfg *DeviceConfig) { cfg.Devices[0].Count = -1 },
+			expectErr: true,
+		},
+		"duplicate name": {
+			modify:    func(cfg *DeviceConfig) { cfg.Devices[1].Name = "gpu-1" },
+			expectErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := testConfig()
+			tc.modify(cfg)
+			err := validateDeviceConfig(cfg)
+			if tc.expectErr {
+				assert.ErrorIs(t, err, errorInvalidDeviceConfig)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestPublishResourceSlice(t *testing.T) {
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{ResourceSliceResource: "ResourceSliceList"},
+	)
+	n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node", UID: "1234"}}
+
+	// Publishing again replaces the slice
+	cfg := testConfig()
+	assert.Nil(t, PublishResourceSlice(context.TODO(), dynamicClient, cfg, n))
+	cfg.Devices = cfg.Devices[:1]
+	assert.Nil(t, PublishResourceSlice(context.TODO(), dynamicClient, cfg, n))
+
+	obj, err := dynamicClient.Resource(ResourceSliceResource).
+		Get(context.TODO(), "test-node-gpu.nvidia.com", metav1.GetOptions{})
+	assert.Nil(t, err)
+
+	var slice ResourceSlice
+	assert.Nil(t, runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &slice))
+	assert.Equal(t, "gpu.nvidia.com", slice.Spec.Driver)
+	assert.Equal(t, "test-node", slice.Spec.NodeName)
+	assert.Equal(t, ResourcePool{Name: "test-node", Generation: 1, ResourceSliceCount: 1}, slice.Spec.Pool)
+	assert.Equal(t, []string{"gpu-0", "gpu-1"}, lo.Map(slice.Spec.Devices, func(d Device, _ int) string { return d.Name }))
+	assert.Equal(t, "A100", *slice.Spec.Devices[0].Basic.Attributes["model"].StringValue)
+	assert.Equal(t, resource.MustParse("80Gi"), slice.Spec.Devices[1].Basic.Capacity["memory"].Value)
+	assert.Equal(t, n.UID, slice.ObjectMeta.OwnerReferences[0].UID)
+}
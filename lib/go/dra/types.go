@@ -0,0 +1,141 @@
+package dra
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ResourceSliceResource (cluster-scoped) and ResourceClaimResource are the Dynamic Resource
+// Allocation resources, from the resource.k8s.io/v1beta1 API
+//
+//nolint:gochecknoglobals
+var (
+	ResourceSliceResource = schema.GroupVersionResource{
+		Group:    "resource.k8s.io",
+		Version:  "v1beta1",
+		Resource: "resourceslices",
+	}
+	ResourceClaimResource = schema.GroupVersionResource{
+		Group:    "resource.k8s.io",
+		Version:  "v1beta1",
+		Resource: "resourceclaims",
+	}
+)
+
+const (
+	// DeviceAllocationModeExactCount (the default) allocates Count devices for a request, and
+	// DeviceAllocationModeAll allocates all of the matching devices
+	DeviceAllocationModeExactCount = "ExactCount"
+	DeviceAllocationModeAll        = "All"
+)
+
+// These are the subset of the DRA API that the virtual nodes use; the Kubernetes API that we
+// build against predates ResourceSlices and structured parameters, so like the Karpenter types,
+// they're accessed with the dynamic client.
+type ResourceSlice struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ResourceSliceSpec `json:"spec"`
+}
+
+type ResourceSliceSpec struct {
+	Driver   string       `json:"driver"`
+	Pool     ResourcePool `json:"pool"`
+	NodeName string       `json:"nodeName,omitempty"`
+	Devices  []Device     `json:"devices,omitempty"`
+}
+
+type ResourcePool struct {
+	Name               string `json:"name"`
+	Generation         int64  `json:"generation"`
+	ResourceSliceCount int64  `json:"resourceSliceCount"`
+}
+
+type Device struct {
+	Name  string       `json:"name"`
+	Basic *BasicDevice `json:"basic,omitempty"`
+}
+
+type BasicDevice struct {
+	Attributes map[string]DeviceAttribute `json:"attributes,omitempty"`
+	Capacity   map[string]DeviceCapacity  `json:"capacity,omitempty"`
+}
+
+// A DeviceAttribute has exactly one of its values set
+type DeviceAttribute struct {
+	IntValue     *int64  `json:"int,omitempty"`
+	BoolValue    *bool   `json:"bool,omitempty"`
+	StringValue  *string `json:"string,omitempty"`
+	VersionValue *string `json:"version,omitempty"`
+}
+
+type DeviceCapacity struct {
+	Value resource.Quantity `json:"value"`
+}
+
+type ResourceClaim struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ResourceClaimSpec   `json:"spec"`
+	Status ResourceClaimStatus `json:"status,omitempty"`
+}
+
+type ResourceClaimSpec struct {
+	Devices DeviceClaim `json:"devices,omitempty"`
+}
+
+type DeviceClaim struct {
+	Requests []DeviceRequest `json:"requests,omitempty"`
+}
+
+type DeviceRequest struct {
+	Name            string `json:"name"`
+	DeviceClassName string `json:"deviceClassName"`
+	AllocationMode  string `json:"allocationMode,omitempty"`
+	Count           int64  `json:"count,omitempty"`
+}
+
+type ResourceClaimStatus struct {
+	Allocation  *AllocationResult                `json:"allocation,omitempty"`
+	ReservedFor []ResourceClaimConsumerReference `json:"reservedFor,omitempty"`
+}
+
+type AllocationResult struct {
+	Devices      DeviceAllocationResult `json:"devices,omitempty"`
+	NodeSelector *corev1.NodeSelector   `json:"nodeSelector,omitempty"`
+}
+
+type DeviceAllocationResult struct {
+	Results []DeviceRequestAllocationResult `json:"results,omitempty"`
+}
+
+type DeviceRequestAllocationResult struct {
+	Request string `json:"request"`
+	Driver  string `json:"driver"`
+	Pool    string `json:"pool"`
+	Device  string `json:"device"`
+}
+
+type ResourceClaimConsumerReference struct {
+	APIGroup string    `json:"apiGroup,omitempty"`
+	Resource string    `json:"resource"`
+	Name     string    `json:"name"`
+	UID      types.UID `json:"uid"`
+}
+
+func ClaimFromUnstructured(obj *unstructured.Unstructured) (*ResourceClaim, error) {
+	var claim ResourceClaim
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), &claim); err != nil {
+		return nil, fmt.Errorf("could not parse ResourceClaim %s: %w", obj.GetName(), err)
+	}
+	return &claim, nil
+}
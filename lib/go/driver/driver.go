@@ -0,0 +1,176 @@
+package driver
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	simkubev1 "simkube/lib/go/api/v1"
+)
+
+// These have to match the ones that sk-driver uses (see lib/rust/constants.rs), since the
+// controller and the virtual nodes look for them
+const (
+	simulationLabel          = "simkube.io/simulation"
+	virtualLabel             = "simkube.io/virtual"
+	origNamespaceAnnotation  = "simkube.io/original-namespace"
+	lifetimeAnnotation       = "simkube.io/lifetime-seconds"
+	virtualNodeTolerationKey = "simkube.io/virtual-node"
+
+	fieldManager = "simkube"
+)
+
+// Options are the same as sk-driver's command-line options: the name of the simulation, the
+// name of the SimulationRoot that all of the simulation's objects are owned by, the prefix
+// for the virtual namespaces that the trace is replayed into, and how much faster than real
+// time to replay the trace (0 means real time).  The trace is replayed Repetitions times (0
+// means once), and the simulation stops after MaxDuration even if the trace isn't finished
+// (0 means no limit).  Namespaces in NamespaceMap are replayed into the namespace they're
+// mapped to, instead of a virtual namespace, and the replica counts of the objects in the
+// trace are multiplied by ScaleFactor (0 means they aren't changed).
+type Options struct {
+	SimName         string
+	SimRoot         string
+	VirtualNsPrefix string
+	Speed           float64
+	Repetitions     int
+	MaxDuration     time.Duration
+	NamespaceMap    map[string]string
+	ScaleFactor     float64
+}
+
+func (self Options) virtualNamespace(origNamespace string) string {
+	if ns, ok := self.NamespaceMap[origNamespace]; ok {
+		return ns
+	}
+	return fmt.Sprintf("%s-%s", self.VirtualNsPrefix, origNamespace)
+}
+
+// scaleDuration converts a number of seconds in the trace into simulation time
+func (self Options) scaleDuration(seconds int64) time.Duration {
+	return time.Duration(float64(seconds) * float64(time.Second) / self.speed())
+}
+
+// scaleLifetime converts a pod lifetime in the trace into simulation time; like sk-driver, we
+// round up, so that pods that ran for a short time don't terminate immediately
+func (self Options) scaleLifetime(seconds int64) int64 {
+	return int64(math.Ceil(float64(seconds) / self.speed()))
+}
+
+func (self Options) speed() float64 {
+	if self.Speed <= 0 {
+		return 1
+	}
+	return self.Speed
+}
+
+func (self Options) scaleFactor() float64 {
+	if self.ScaleFactor <= 0 {
+		return 1
+	}
+	return self.ScaleFactor
+}
+
+func (self Options) repetitions() int {
+	if self.Repetitions <= 0 {
+		return 1
+	}
+	return self.Repetitions
+}
+
+// addCommonMetadata labels the object with the simulation name, and makes it owned by the
+// simulation root, so that it gets cleaned up when the simulation is over
+func addCommonMetadata(obj metav1.Object, simName string, root metav1.Object) {
+	obj.SetLabels(withLabel(obj.GetLabels(), simulationLabel, simName))
+	obj.SetOwnerReferences(append(obj.GetOwnerReferences(), metav1.OwnerReference{
+		APIVersion: simkubev1.GroupVersion.String(),
+		Kind:       "SimulationRoot",
+		Name:       root.GetName(),
+		UID:        root.GetUID(),
+	}))
+}
+
+func buildVirtualNamespace(opts Options, root metav1.Object, namespace string) *unstructured.Unstructured {
+	ns := &unstructured.Unstructured{}
+	ns.SetAPIVersion("v1")
+	ns.SetKind("Namespace")
+	ns.SetName(namespace)
+	addCommonMetadata(ns, opts.SimName, root)
+	ns.SetLabels(withLabel(ns.GetLabels(), virtualLabel, "true"))
+	return ns
+}
+
+// buildVirtualObj copies the object from the trace into its virtual namespace, and scales its
+// replicas by the scale factor; the pods that the object creates are annotated with the
+// original namespace, so that the mutation handler can find their lifecycle data.  Objects
+// that don't create pods (e.g., Kueue LocalQueues) have no pod template path.
+func buildVirtualObj(
+	opts Options,
+	root metav1.Object,
+	obj *unstructured.Unstructured,
+	podSpecTemplatePath string,
+) (*unstructured.Unstructured, error) {
+	vobj := obj.DeepCopy()
+	addCommonMetadata(vobj, opts.SimName, root)
+	vobj.SetNamespace(opts.virtualNamespace(obj.GetNamespace()))
+	vobj.SetLabels(withLabel(vobj.GetLabels(), virtualLabel, "true"))
+	scaleReplicas(vobj, opts.scaleFactor())
+	suspendQueuedObj(vobj)
+
+	var templates []map[string]interface{}
+	if podSpecTemplatePath != "" {
+		var err error
+		if templates, err = podTemplates(vobj.Object, podSpecTemplatePath); err != nil {
+			return nil, fmt.Errorf("could not find pod template for %s: %w", obj.GetName(), err)
+		}
+	}
+	for _, template := range templates {
+		meta, ok := template["metadata"].(map[string]interface{})
+		if !ok {
+			meta = map[string]interface{}{}
+			template["metadata"] = meta
+		}
+		annotations, ok := meta["annotations"].(map[string]interface{})
+		if !ok {
+			annotations = map[string]interface{}{}
+			meta["annotations"] = annotations
+		}
+		annotations[origNamespaceAnnotation] = obj.GetNamespace()
+	}
+
+	unstructured.RemoveNestedField(vobj.Object, "status")
+	return vobj, nil
+}
+
+// scaleReplicas multiplies the object's replicas (or for jobs, its parallelism and completions)
+// by the scale factor; like sk-driver, we round to the nearest whole number, but never scale an
+// object down to no replicas at all.  There's no lifecycle data for the extra pods, so they
+// reuse the lifecycles of the pods in the trace.
+func scaleReplicas(obj *unstructured.Unstructured, scaleFactor float64) {
+	spec, ok := obj.Object["spec"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, field := range []string{"replicas", "parallelism", "completions"} {
+		count, ok := spec[field].(int64)
+		if !ok || count <= 0 {
+			continue
+		}
+		scaled := int64(math.Round(float64(count) * scaleFactor))
+		if scaled < 1 {
+			scaled = 1
+		}
+		spec[field] = scaled
+	}
+}
+
+func withLabel(labels map[string]string, key, value string) map[string]string {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[key] = value
+	return labels
+}
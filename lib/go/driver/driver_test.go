@@ -0,0 +1,182 @@
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	testSimName      = "test-sim"
+	testSimRoot      = "sk-test-sim-root"
+	testSimRootUID   = "abcd"
+	testNamespace    = "default"
+	testVirtualNs    = "virtual-default"
+	testDeployment   = "nginx"
+	testReplicaSet   = "nginx-abcde"
+	testTemplatePath = "/spec/template"
+)
+
+func testOptions() Options {
+	return Options{SimName: testSimName, SimRoot: testSimRoot, VirtualNsPrefix: "virtual"}
+}
+
+func testRoot() *metav1.ObjectMeta {
+	return &metav1.ObjectMeta{Name: testSimRoot, UID: types.UID(testSimRootUID)}
+}
+
+func testRootOwnerRef() metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: "simkube.io/v1",
+		Kind:       "SimulationRoot",
+		Name:       testSimRoot,
+		UID:        types.UID(testSimRootUID),
+	}
+}
+
+func testDeploymentObj() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"namespace": testNamespace,
+			"name":      testDeployment,
+			"labels":    map[string]interface{}{"app": "nginx"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{map[string]interface{}{"name": "nginx", "image": "nginx"}},
+				},
+			},
+		},
+		"status": map[string]interface{}{"replicas": int64(3)},
+	}}
+}
+
+func TestBuildVirtualObj(t *testing.T) {
+	obj := testDeploymentObj()
+	vobj, err := buildVirtualObj(testOptions(), testRoot(), obj, testTemplatePath)
+	assert.Nil(t, err)
+
+	assert.Equal(t, testVirtualNs, vobj.GetNamespace())
+	assert.Equal(t, map[string]string{
+		"app":           "nginx",
+		simulationLabel: testSimName,
+		virtualLabel:    "true",
+	}, vobj.GetLabels())
+	assert.Equal(t, []metav1.OwnerReference{testRootOwnerRef()}, vobj.GetOwnerReferences())
+
+	annotations, _, err := unstructured.NestedStringMap(vobj.Object, "spec", "template", "metadata", "annotations")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{origNamespaceAnnotation: testNamespace}, annotations)
+
+	_, found := vobj.Object["status"]
+	assert.False(t, found)
+
+	// The original object isn't modified
+	assert.Equal(t, testDeploymentObj(), obj)
+}
+
+func TestBuildVirtualObjNamespaceMap(t *testing.T) {
+	opts := testOptions()
+	opts.NamespaceMap = map[string]string{testNamespace: "sim-default"}
+	vobj, err := buildVirtualObj(opts, testRoot(), testDeploymentObj(), testTemplatePath)
+	assert.Nil(t, err)
+
+	// The pods still know where they came from, so that they can be matched up with the trace
+	assert.Equal(t, "sim-default", vobj.GetNamespace())
+	annotations, _, err := unstructured.NestedStringMap(vobj.Object, "spec", "template", "metadata", "annotations")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{origNamespaceAnnotation: testNamespace}, annotations)
+
+	// Namespaces that aren't in the map are replayed into virtual namespaces
+	assert.Equal(t, "virtual-kube-system", opts.virtualNamespace("kube-system"))
+}
+
+func TestBuildVirtualObjScaleFactor(t *testing.T) {
+	cases := map[string]struct {
+		scaleFactor      float64
+		expectedReplicas int64
+	}{
+		"default":      {scaleFactor: 0, expectedReplicas: 3},
+		"doubled":      {scaleFactor: 2, expectedReplicas: 6},
+		"rounded":      {scaleFactor: 1.5, expectedReplicas: 5},
+		"scaled down":  {scaleFactor: 0.5, expectedReplicas: 2},
+		"at least one": {scaleFactor: 0.01, expectedReplicas: 1},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			opts := testOptions()
+			opts.ScaleFactor = tc.scaleFactor
+			vobj, err := buildVirtualObj(opts, testRoot(), testDeploymentObj(), testTemplatePath)
+			assert.Nil(t, err)
+
+			replicas, _, err := unstructured.NestedInt64(vobj.Object, "spec", "replicas")
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedReplicas, replicas)
+		})
+	}
+}
+
+func TestScaleReplicasJob(t *testing.T) {
+	job := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"parallelism": int64(2), "completions": int64(10)},
+	}}
+	scaleReplicas(job, 3)
+	assert.Equal(t, map[string]interface{}{"parallelism": int64(6), "completions": int64(30)}, job.Object["spec"])
+}
+
+func TestBuildVirtualObjInvalidPath(t *testing.T) {
+	_, err := buildVirtualObj(testOptions(), testRoot(), testDeploymentObj(), "/spec/jobs/*/template")
+	assert.NotNil(t, err)
+}
+
+func TestBuildVirtualObjNoTemplatePath(t *testing.T) {
+	queue := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "kueue.x-k8s.io/v1beta1",
+		"kind":       "LocalQueue",
+		"metadata":   map[string]interface{}{"namespace": testNamespace, "name": "team-a"},
+		"spec":       map[string]interface{}{"clusterQueue": "cluster-queue"},
+	}}
+	vobj, err := buildVirtualObj(testOptions(), testRoot(), queue, "")
+	assert.Nil(t, err)
+	assert.Equal(t, testVirtualNs, vobj.GetNamespace())
+	assert.Equal(t, map[string]interface{}{"clusterQueue": "cluster-queue"}, vobj.Object["spec"])
+}
+
+func TestBuildVirtualNamespace(t *testing.T) {
+	ns := buildVirtualNamespace(testOptions(), testRoot(), testVirtualNs)
+	assert.Equal(t, "Namespace", ns.GetKind())
+	assert.Equal(t, testVirtualNs, ns.GetName())
+	assert.Equal(t, map[string]string{simulationLabel: testSimName, virtualLabel: "true"}, ns.GetLabels())
+	assert.Equal(t, []metav1.OwnerReference{testRootOwnerRef()}, ns.GetOwnerReferences())
+}
+
+func TestOptionsScale(t *testing.T) {
+	cases := map[string]struct {
+		speed            float64
+		expectedDuration time.Duration
+		expectedLifetime int64
+	}{
+		"real time": {speed: 1, expectedDuration: 60 * time.Second, expectedLifetime: 60},
+		"default":   {speed: 0, expectedDuration: 60 * time.Second, expectedLifetime: 60},
+		"sped up":   {speed: 12, expectedDuration: 5 * time.Second, expectedLifetime: 5},
+		"rounds up": {speed: 7, expectedDuration: 60 * time.Second / 7, expectedLifetime: 9},
+		"slowed":    {speed: 0.5, expectedDuration: 120 * time.Second, expectedLifetime: 120},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			opts := Options{Speed: tc.speed}
+			assert.Equal(t, tc.expectedDuration, opts.scaleDuration(60))
+			assert.Equal(t, tc.expectedLifetime, opts.scaleLifetime(60))
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package driver
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"simkube/lib/go/kueue"
+)
+
+// Kueue admits a job by unsuspending it, so a job that was recorded after it was admitted would
+// start as soon as it's replayed, without waiting in its queue (or counting against its quota);
+// jobs that are submitted to a queue are always replayed suspended, and Kueue unsuspends them
+// again once they're admitted in the simulation.
+func suspendQueuedObj(obj *unstructured.Unstructured) {
+	if _, ok := obj.GetLabels()[kueue.QueueNameLabel]; !ok {
+		return
+	}
+
+	spec, ok := obj.Object["spec"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	if _, ok := spec["suspend"].(bool); ok {
+		spec["suspend"] = true
+	}
+}
+
+// isJobWorkload returns true if the object is a Workload that Kueue created for a job; Kueue
+// creates a new one for the replayed job, so these aren't replayed themselves.  Workloads that
+// were created directly are replayed, and get queued and admitted like any other.
+func isJobWorkload(obj *unstructured.Unstructured) bool {
+	if !kueue.IsWorkload(obj.GroupVersionKind()) {
+		return false
+	}
+	_, ok := obj.GetLabels()[kueue.JobUIDLabel]
+	return ok
+}
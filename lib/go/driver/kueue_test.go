@@ -0,0 +1,82 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"simkube/lib/go/kueue"
+)
+
+func testJobObj(labels map[string]interface{}, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata":   map[string]interface{}{"namespace": testNamespace, "name": "train", "labels": labels},
+		"spec":       spec,
+	}}
+}
+
+func TestSuspendQueuedObj(t *testing.T) {
+	queued := map[string]interface{}{kueue.QueueNameLabel: "team-a"}
+	cases := map[string]struct {
+		obj             *unstructured.Unstructured
+		expectedSuspend interface{}
+	}{
+		"admitted": {
+			obj:             testJobObj(queued, map[string]interface{}{"suspend": false}),
+			expectedSuspend: true,
+		},
+		"not queued": {
+			obj:             testJobObj(nil, map[string]interface{}{"suspend": false}),
+			expectedSuspend: false,
+		},
+		"not suspendable": {
+			obj:             testJobObj(queued, map[string]interface{}{}),
+			expectedSuspend: nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			suspendQueuedObj(tc.obj)
+			spec, _ := tc.obj.Object["spec"].(map[string]interface{})
+			assert.Equal(t, tc.expectedSuspend, spec["suspend"])
+		})
+	}
+}
+
+func TestIsJobWorkload(t *testing.T) {
+	cases := map[string]struct {
+		apiVersion string
+		kind       string
+		labels     map[string]interface{}
+		expected   bool
+	}{
+		"job workload": {
+			apiVersion: "kueue.x-k8s.io/v1beta1",
+			kind:       "Workload",
+			labels:     map[string]interface{}{kueue.JobUIDLabel: "abcd"},
+			expected:   true,
+		},
+		"standalone workload": {apiVersion: "kueue.x-k8s.io/v1beta1", kind: "Workload", expected: false},
+		"job": {
+			apiVersion: "batch/v1",
+			kind:       "Job",
+			labels:     map[string]interface{}{kueue.JobUIDLabel: "abcd"},
+			expected:   false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": tc.apiVersion,
+				"kind":       tc.kind,
+				"metadata":   map[string]interface{}{"namespace": testNamespace, "name": "wl", "labels": tc.labels},
+			}}
+			assert.Equal(t, tc.expected, isJobWorkload(obj))
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/samber/lo"
+	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/trace"
+)
+
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+func addOperation(path string, value interface{}) patchOperation {
+	return patchOperation{Op: "add", Path: path, Value: value}
+}
+
+// A MutationHandler is a native Go version of sk-driver's mutating admission webhook: pods
+// that are owned by the simulation are labelled with the simulation name, scheduled onto the
+// virtual nodes, and (if the trace has lifecycle data for them) annotated with how long they
+// should run for.
+type MutationHandler struct {
+	opts  Options
+	trace *trace.Trace
+
+	// The mutex protects the owners cache and the pod counts, since the HTTP server calls the
+	// handler from multiple goroutines; podCounts is the number of pods with each spec hash
+	// that we've seen, so that each pod gets the lifecycle of the next pod in the trace
+	mutex     sync.Mutex
+	owners    *k8s.OwnersCache
+	podCounts map[uint64]int
+
+	logger *log.Entry
+}
+
+func NewMutationHandler(
+	opts Options,
+	tr *trace.Trace,
+	dynamicClient dynamic.Interface,
+	mapper meta.RESTMapper,
+) *MutationHandler {
+	return &MutationHandler{
+		opts:      opts,
+		trace:     tr,
+		owners:    k8s.NewOwnersCache(dynamicClient, mapper),
+		podCounts: map[uint64]int{},
+		logger:    log.WithFields(log.Fields{"component": "mutation-handler", "simulation": opts.SimName}),
+	}
+}
+
+func (self *MutationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var review admissionv1.AdmissionReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil || review.Request == nil {
+		self.logger.WithError(err).Error("could not parse request")
+		self.writeReview(w, &admissionv1.AdmissionResponse{
+			Result: &metav1.Status{Status: metav1.StatusFailure, Message: "invalid admission request"},
+		})
+		return
+	}
+
+	resp := &admissionv1.AdmissionResponse{UID: review.Request.UID, Allowed: true}
+	if len(review.Request.Object.Raw) > 0 {
+		if err := self.mutate(r.Context(), review.Request, resp); err != nil {
+			self.logger.WithError(err).Error("could not perform mutation, blocking pod object")
+			resp.Allowed = false
+			resp.Result = &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: err.Error(),
+				Code:    http.StatusForbidden,
+			}
+		}
+	}
+	self.writeReview(w, resp)
+}
+
+func (self *MutationHandler) mutate(
+	ctx context.Context,
+	req *admissionv1.AdmissionRequest,
+	resp *admissionv1.AdmissionResponse,
+) error {
+	var pod corev1.Pod
+	if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
+		return fmt.Errorf("could not parse pod: %w", err)
+	}
+
+	// The pod's namespace isn't always filled in yet, but the owners are in the same namespace
+	if pod.Namespace == "" {
+		pod.Namespace = req.Namespace
+	}
+
+	patches, err := self.mutatePod(ctx, &pod)
+	if err != nil {
+		return err
+	} else if patches == nil {
+		return nil
+	}
+
+	patch, err := json.Marshal(patches)
+	if err != nil {
+		return fmt.Errorf("could not encode patch: %w", err)
+	}
+	patchType := admissionv1.PatchTypeJSONPatch
+	resp.Patch = patch
+	resp.PatchType = &patchType
+	return nil
+}
+
+// mutatePod returns the patches for the pod, or nil if the pod isn't part of the simulation;
+// when we get the pod, it usually doesn't have a name yet (it only has a generateName), so
+// it isn't stored in the owners cache
+func (self *MutationHandler) mutatePod(ctx context.Context, pod *corev1.Pod) ([]patchOperation, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	owners, err := self.owners.OwnerChain(ctx, pod)
+	if err != nil {
+		return nil, fmt.Errorf("could not compute owners: %w", err)
+	}
+
+	if !lo.ContainsBy(owners, func(owner metav1.OwnerReference) bool { return owner.Name == self.opts.SimRoot }) {
+		self.logger.Info("pod not owned by simulation, no mutation performed")
+		return nil, nil
+	}
+
+	patches := simulationLabelPatches(pod, self.opts.SimName)
+	lifecyclePatches, err := self.lifecyclePatches(pod, owners)
+	if err != nil {
+		return nil, err
+	}
+	patches = append(patches, lifecyclePatches...)
+	patches = append(patches, schedulingPatches(pod)...)
+	return patches, nil
+}
+
+// lifecyclePatches looks up the lifecycle of the pod in the trace, using the first owner that
+// has finished pod lifecycle data
+func (self *MutationHandler) lifecyclePatches(
+	pod *corev1.Pod,
+	owners []metav1.OwnerReference,
+) ([]patchOperation, error) {
+	origNs, ok := pod.Annotations[origNamespaceAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	for _, owner := range owners {
+		ownerNsName := k8s.NamespacedName(origNs, owner.Name)
+		if !self.trace.HasObj(ownerNsName) {
+			continue
+		}
+
+		hash, err := trace.StablePodSpecHash(pod)
+		if err != nil {
+			return nil, fmt.Errorf("could not hash pod spec: %w", err)
+		}
+		seq := self.podCounts[hash]
+		self.podCounts[hash]++
+
+		lifecycle := self.trace.LookupPodLifecycle(ownerNsName, hash, seq)
+		if !lifecycle.Finished() {
+			self.logger.Warnf("no pod lifecycle data found for %s (hash=%d, seq=%d)", ownerNsName, hash, seq)
+			continue
+		}
+
+		self.logger.Infof("applying lifecycle annotations for %s (hash=%d, seq=%d)", ownerNsName, hash, seq)
+		var patches []patchOperation
+		if pod.Annotations == nil {
+			patches = append(patches, addOperation("/metadata/annotations", map[string]string{}))
+		}
+		return append(patches, addOperation(
+			"/metadata/annotations/"+escapeJSONPointer(lifetimeAnnotation),
+			fmt.Sprintf("%d", self.opts.scaleLifetime(lifecycle.EndTs-lifecycle.StartTs)),
+		)), nil
+	}
+	return nil, nil
+}
+
+func (self *MutationHandler) writeReview(w http.ResponseWriter, resp *admissionv1.AdmissionResponse) {
+	review := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: admissionv1.SchemeGroupVersion.String(), Kind: "AdmissionReview"},
+		Response: resp,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		self.logger.WithError(err).Error("could not write admission response")
+	}
+}
+
+func simulationLabelPatches(pod *corev1.Pod, simName string) []patchOperation {
+	var patches []patchOperation
+	if pod.Labels == nil {
+		patches = append(patches, addOperation("/metadata/labels", map[string]string{}))
+	}
+	return append(patches, addOperation("/metadata/labels/"+escapeJSONPointer(simulationLabel), simName))
+}
+
+func schedulingPatches(pod *corev1.Pod) []patchOperation {
+	var patches []patchOperation
+	if pod.Spec.Tolerations == nil {
+		patches = append(patches, addOperation("/spec/tolerations", []interface{}{}))
+	}
+	return append(
+		patches,
+		addOperation("/spec/nodeSelector", map[string]string{"type": "virtual"}),
+		addOperation("/spec/tolerations/-", map[string]string{"key": virtualNodeTolerationKey, "value": "true"}),
+	)
+}
+
+func escapeJSONPointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,203 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/testutils"
+	"simkube/lib/go/trace"
+)
+
+func testPod(annotations map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: testReplicaSet + "-",
+			Annotations:  annotations,
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: testReplicaSet},
+			},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "nginx", Image: "nginx"}}},
+	}
+}
+
+func newTestMutationHandler(t *testing.T, deploymentOwners []metav1.OwnerReference) *MutationHandler {
+	deployment := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       testVirtualNs,
+			Name:            testDeployment,
+			OwnerReferences: deploymentOwners,
+		},
+	}
+	replicaSet := &appsv1.ReplicaSet{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "ReplicaSet"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testVirtualNs,
+			Name:      testReplicaSet,
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "Deployment", Name: testDeployment},
+			},
+		},
+	}
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("ReplicaSet"), meta.RESTScopeNamespace)
+	mapper.Add(simkubev1.GroupVersion.WithKind("SimulationRoot"), meta.RESTScopeRoot)
+
+	hash, err := trace.StablePodSpecHash(testPod(nil))
+	assert.Nil(t, err)
+	tr := testTrace()
+	tr.PodLifecycles = map[string]map[uint64][]trace.PodLifecycleData{
+		testNamespace + "/" + testDeployment: {hash: {{StartTs: 100, EndTs: 160}, {StartTs: 100}}},
+	}
+
+	handler := NewMutationHandler(
+		testOptions(),
+		tr,
+		newApplyDynamicClient(deployment, replicaSet),
+		mapper,
+	)
+	handler.logger = testutils.GetFakeLogger()
+	return handler
+}
+
+func admit(t *testing.T, handler http.Handler, pod *corev1.Pod) *admissionv1.AdmissionResponse {
+	raw, err := json.Marshal(pod)
+	assert.Nil(t, err)
+	body, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       types.UID("12345"),
+			Namespace: testVirtualNs,
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	})
+	assert.Nil(t, err)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var review admissionv1.AdmissionReview
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&review))
+	return review.Response
+}
+
+func TestMutationHandler(t *testing.T) {
+	lifetimePatches := []patchOperation{
+		{Op: "add", Path: "/metadata/annotations/simkube.io~1lifetime-seconds", Value: "60"},
+	}
+	labelPatches := []patchOperation{
+		{Op: "add", Path: "/metadata/labels", Value: map[string]interface{}{}},
+		{Op: "add", Path: "/metadata/labels/simkube.io~1simulation", Value: testSimName},
+	}
+	schedulingPatches := []patchOperation{
+		{Op: "add", Path: "/spec/tolerations", Value: []interface{}{}},
+		{Op: "add", Path: "/spec/nodeSelector", Value: map[string]interface{}{"type": "virtual"}},
+		{
+			Op:    "add",
+			Path:  "/spec/tolerations/-",
+			Value: map[string]interface{}{"key": "simkube.io/virtual-node", "value": "true"},
+		},
+	}
+
+	cases := map[string]struct {
+		owned       bool
+		annotations map[string]string
+		expected    [][]patchOperation
+	}{
+		"not owned": {
+			annotations: map[string]string{origNamespaceAnnotation: testNamespace},
+		},
+		"no original namespace": {
+			owned:    true,
+			expected: [][]patchOperation{append(labelPatches, schedulingPatches...)},
+		},
+		"lifecycle data": {
+			owned:       true,
+			annotations: map[string]string{origNamespaceAnnotation: testNamespace},
+			// The second pod in the trace is still running, so it doesn't get a lifetime;
+			// the third pod wraps around to the first one
+			expected: [][]patchOperation{
+				append(append(labelPatches, lifetimePatches...), schedulingPatches...),
+				append(labelPatches, schedulingPatches...),
+				append(append(labelPatches, lifetimePatches...), schedulingPatches...),
+			},
+		},
+		"unknown namespace": {
+			owned:       true,
+			annotations: map[string]string{origNamespaceAnnotation: "foo"},
+			expected:    [][]patchOperation{append(labelPatches, schedulingPatches...)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var owners []metav1.OwnerReference
+			if tc.owned {
+				owners = []metav1.OwnerReference{testRootOwnerRef()}
+			}
+			handler := newTestMutationHandler(t, owners)
+
+			expected := tc.expected
+			if len(expected) == 0 {
+				expected = [][]patchOperation{nil}
+			}
+			for _, patches := range expected {
+				resp := admit(t, handler, testPod(tc.annotations))
+				assert.True(t, resp.Allowed)
+				assert.Equal(t, types.UID("12345"), resp.UID)
+				if patches == nil {
+					assert.Nil(t, resp.Patch)
+					continue
+				}
+
+				var actual []patchOperation
+				assert.Nil(t, json.Unmarshal(resp.Patch, &actual))
+				assert.Equal(t, patches, actual)
+				assert.Equal(t, admissionv1.PatchTypeJSONPatch, *resp.PatchType)
+			}
+		})
+	}
+}
+
+func TestMutationHandlerMissingOwner(t *testing.T) {
+	handler := newTestMutationHandler(t, nil)
+	pod := testPod(nil)
+	pod.OwnerReferences[0].Name = "foo"
+
+	resp := admit(t, handler, pod)
+	assert.False(t, resp.Allowed)
+	assert.Equal(t, int32(http.StatusForbidden), resp.Result.Code)
+}
+
+func TestMutationHandlerInvalidRequest(t *testing.T) {
+	handler := newTestMutationHandler(t, nil)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("foo"))))
+	var review admissionv1.AdmissionReview
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&review))
+	assert.False(t, review.Response.Allowed)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
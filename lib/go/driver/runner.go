@@ -0,0 +1,375 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/trace"
+)
+
+// While the simulation is paused, and while we're waiting for the next event, we check the
+// Simulation this often to see if it's been paused or resumed
+const pausePollInterval = 5 * time.Second
+
+//nolint:gochecknoglobals
+var (
+	simulationGVR     = simkubev1.GroupVersion.WithResource("simulations")
+	simulationRootGVR = simkubev1.GroupVersion.WithResource("simulationroots")
+	namespaceGVR      = corev1.SchemeGroupVersion.WithResource("namespaces")
+)
+
+// A Runner is a native Go version of sk-driver's trace runner: it replays the events in the
+// trace into the virtual namespaces, sleeping in between events for as long as the trace says,
+// and deletes the simulation root (and thus everything that it owns) when it's done.  Like
+// sk-driver, it assumes that all of the tracked objects are namespaced.
+type Runner struct {
+	opts  Options
+	trace *trace.Trace
+
+	dynamicClient dynamic.Interface
+	mapper        meta.RESTMapper
+
+	// liveObjs are the objects from the trace that have been applied and not deleted yet, so
+	// that they can be cleaned up before the next repetition
+	liveObjs map[objKey]*unstructured.Unstructured
+
+	// eventsReplayed counts the events from all of the repetitions, for the simulation status
+	eventsReplayed int64
+
+	// deadline is when the simulation reaches its max duration (if it has one); it gets pushed
+	// back whenever the simulation is paused, since the time spent paused doesn't count
+	deadline time.Time
+	paused   bool
+
+	clock  clockwork.Clock
+	logger *log.Entry
+}
+
+type objKey struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+func keyFor(obj *unstructured.Unstructured) objKey {
+	return objKey{obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName()}
+}
+
+func NewRunner(opts Options, tr *trace.Trace, dynamicClient dynamic.Interface, mapper meta.RESTMapper) *Runner {
+	return &Runner{
+		opts:          opts,
+		trace:         tr,
+		dynamicClient: dynamicClient,
+		mapper:        mapper,
+		liveObjs:      map[objKey]*unstructured.Unstructured{},
+		clock:         clockwork.NewRealClock(),
+		logger:        log.WithFields(log.Fields{"component": "driver", "simulation": opts.SimName}),
+	}
+}
+
+func (self *Runner) Run(ctx context.Context) error {
+	if len(self.trace.Events) == 0 {
+		return errors.New("no trace data")
+	}
+
+	root, err := self.dynamicClient.Resource(simulationRootGVR).Get(ctx, self.opts.SimRoot, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("could not get simulation root %s: %w", self.opts.SimRoot, err)
+	}
+	//nolint:contextcheck // the simulation root gets cleaned up even if ctx is cancelled
+	defer self.cleanup()
+
+	if self.opts.MaxDuration > 0 {
+		self.deadline = self.clock.Now().Add(self.opts.MaxDuration)
+	}
+
+	repetitions := self.opts.repetitions()
+	for rep := 0; rep < repetitions; rep++ {
+		if rep > 0 {
+			self.logger.Infof("starting repetition %d of %d", rep+1, repetitions)
+			if err := self.deleteLiveObjs(ctx); err != nil {
+				return err
+			}
+		}
+
+		if finished, err := self.replay(ctx, root); err != nil {
+			return err
+		} else if !finished {
+			self.logger.Infof("simulation reached its maximum duration of %s, stopping", self.opts.MaxDuration)
+			return nil
+		}
+	}
+	return nil
+}
+
+// replay runs through the trace once; it returns false if the deadline passed before the end
+// of the trace
+func (self *Runner) replay(ctx context.Context, root metav1.Object) (bool, error) {
+	simTs := self.trace.Events[0].Ts
+	for i := range self.trace.Events {
+		if _, err := self.waitWhilePaused(ctx); err != nil {
+			return false, err
+		}
+
+		evt := &self.trace.Events[i]
+		for _, obj := range evt.AppliedObjs {
+			if err := self.applyObj(ctx, root, obj); err != nil {
+				return false, err
+			}
+		}
+		for _, obj := range evt.DeletedObjs {
+			if err := self.deleteObj(ctx, obj); err != nil {
+				return false, err
+			}
+		}
+		self.eventsReplayed++
+		self.reportProgress(ctx)
+
+		if i+1 < len(self.trace.Events) {
+			nextTs := self.trace.Events[i+1].Ts
+			sleepSeconds := nextTs - simTs
+			if sleepSeconds < 0 {
+				sleepSeconds = 0
+			}
+			simTs = nextTs
+
+			sleepDuration := self.opts.scaleDuration(sleepSeconds)
+			self.logger.Infof("next event happens in %s, sleeping", sleepDuration)
+			if finished, err := self.sleep(ctx, sleepDuration); err != nil || !finished {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// sleep waits for d, not counting the time that the simulation spends paused; it returns false if
+// the deadline passes first
+func (self *Runner) sleep(ctx context.Context, d time.Duration) (bool, error) {
+	wake := self.clock.Now().Add(d)
+	for {
+		now := self.clock.Now()
+		if !self.deadline.IsZero() && !now.Before(self.deadline) {
+			return false, nil
+		} else if !now.Before(wake) {
+			return true, nil
+		}
+
+		step := wake.Sub(now)
+		if step > pausePollInterval {
+			step = pausePollInterval
+		}
+		if !self.deadline.IsZero() && step > self.deadline.Sub(now) {
+			step = self.deadline.Sub(now)
+		}
+		select {
+		case <-ctx.Done():
+			return false, fmt.Errorf("simulation interrupted: %w", ctx.Err())
+		case <-self.clock.After(step):
+		}
+
+		// If we're already at the next event, the pause check happens before it gets replayed
+		if self.clock.Now().Before(wake) {
+			pausedFor, err := self.waitWhilePaused(ctx)
+			if err != nil {
+				return false, err
+			}
+			wake = wake.Add(pausedFor)
+		}
+	}
+}
+
+// waitWhilePaused blocks for as long as the simulation is paused, and returns how long that was
+func (self *Runner) waitWhilePaused(ctx context.Context) (time.Duration, error) {
+	if !self.checkPaused(ctx) {
+		return 0, nil
+	}
+
+	self.logger.Info("simulation paused, waiting for it to be resumed")
+	start := self.clock.Now()
+	for self.paused {
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("simulation interrupted: %w", ctx.Err())
+		case <-self.clock.After(pausePollInterval):
+		}
+		self.checkPaused(ctx)
+	}
+
+	pausedFor := self.clock.Since(start)
+	if !self.deadline.IsZero() {
+		self.deadline = self.deadline.Add(pausedFor)
+	}
+	self.logger.Infof("simulation resumed after %s", pausedFor)
+	return pausedFor, nil
+}
+
+// checkPaused looks at the Simulation's spec to see if it's been paused or resumed; if we can't
+// get the Simulation, we stick with what we knew before, so that a flaky API server doesn't
+// pause (or resume) the simulation.
+func (self *Runner) checkPaused(ctx context.Context) bool {
+	sim, err := self.dynamicClient.Resource(simulationGVR).Get(ctx, self.opts.SimName, metav1.GetOptions{})
+	if err != nil {
+		self.logger.WithError(err).Warn("could not check whether the simulation is paused")
+		return self.paused
+	}
+
+	paused, _, err := unstructured.NestedBool(sim.Object, "spec", "paused")
+	if err != nil {
+		self.logger.WithError(err).Warn("invalid paused field on simulation")
+		return self.paused
+	}
+	self.paused = paused
+	return paused
+}
+
+func (self *Runner) applyObj(ctx context.Context, root metav1.Object, obj *unstructured.Unstructured) error {
+	gvk := obj.GroupVersionKind()
+	kind := simkubev1.KindString(gvk)
+	objConfig, ok := self.trace.Config.TrackedObjects[kind]
+	if !ok {
+		return fmt.Errorf("unknown simulated object: %s", kind)
+	}
+
+	if createdByController(obj) {
+		self.logger.Debugf("not applying %s %s/%s, its controller creates it", kind, obj.GetNamespace(), obj.GetName())
+		return nil
+	}
+
+	gvr, err := self.namespacedResource(obj)
+	if err != nil {
+		return err
+	}
+
+	virtualNs := self.opts.virtualNamespace(obj.GetNamespace())
+	if err := self.ensureVirtualNamespace(ctx, root, virtualNs); err != nil {
+		return err
+	}
+
+	vobj, err := buildVirtualObj(self.opts, root, obj, objConfig.PodSpecTemplatePath)
+	if err != nil {
+		return err
+	}
+
+	client := self.dynamicClient.Resource(gvr).Namespace(virtualNs)
+
+	self.logger.Infof("applying object %s/%s", virtualNs, vobj.GetName())
+	if _, err := client.Apply(ctx, vobj.GetName(), vobj, metav1.ApplyOptions{FieldManager: fieldManager}); err != nil {
+		return fmt.Errorf("could not apply %s/%s: %w", virtualNs, vobj.GetName(), err)
+	}
+	self.liveObjs[keyFor(obj)] = obj
+	return nil
+}
+
+// Some of the objects in the trace are created by controllers (e.g., Kueue or Volcano) for other
+// objects in the trace; those are created again when the other objects are replayed, so
+// replaying them too would just leave duplicates behind
+func createdByController(obj *unstructured.Unstructured) bool {
+	return isJobWorkload(obj) || isOwnedPodGroup(obj)
+}
+
+func (self *Runner) deleteObj(ctx context.Context, obj *unstructured.Unstructured) error {
+	if createdByController(obj) {
+		return nil
+	}
+
+	virtualNs := self.opts.virtualNamespace(obj.GetNamespace())
+	gvr, err := self.namespacedResource(obj)
+	if err != nil {
+		return err
+	}
+	client := self.dynamicClient.Resource(gvr).Namespace(virtualNs)
+
+	self.logger.Infof("deleting object %s/%s", virtualNs, obj.GetName())
+	if err := client.Delete(ctx, obj.GetName(), metav1.DeleteOptions{}); apierrors.IsNotFound(err) {
+		self.logger.Warnf("object %s/%s was already deleted", virtualNs, obj.GetName())
+	} else if err != nil {
+		return fmt.Errorf("could not delete %s/%s: %w", virtualNs, obj.GetName(), err)
+	}
+	delete(self.liveObjs, keyFor(obj))
+	return nil
+}
+
+// deleteLiveObjs deletes everything that's left over from the previous repetition, so that
+// the next one starts from a clean slate
+func (self *Runner) deleteLiveObjs(ctx context.Context) error {
+	for _, obj := range self.liveObjs {
+		if err := self.deleteObj(ctx, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *Runner) ensureVirtualNamespace(ctx context.Context, root metav1.Object, namespace string) error {
+	client := self.dynamicClient.Resource(namespaceGVR)
+	if _, err := client.Get(ctx, namespace, metav1.GetOptions{}); err == nil {
+		return nil
+	} else if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("could not get virtual namespace %s: %w", namespace, err)
+	}
+
+	self.logger.Infof("creating virtual namespace: %s", namespace)
+	ns := buildVirtualNamespace(self.opts, root, namespace)
+	if _, err := client.Create(ctx, ns, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("could not create virtual namespace %s: %w", namespace, err)
+	}
+	return nil
+}
+
+func (self *Runner) namespacedResource(obj *unstructured.Unstructured) (schema.GroupVersionResource, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := self.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return schema.GroupVersionResource{}, fmt.Errorf("could not find resource for %s: %w", gvk.Kind, err)
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return schema.GroupVersionResource{}, fmt.Errorf("cluster-scoped objects are not supported: %s", gvk.Kind)
+	}
+	return mapping.Resource, nil
+}
+
+// reportProgress updates the simulation status with how far through the trace we are; the rest of
+// the status is set by sk-ctrl.  This is best-effort, since it doesn't affect the simulation itself.
+func (self *Runner) reportProgress(ctx context.Context) {
+	totalEvents := int64(len(self.trace.Events) * self.opts.repetitions())
+	patch := fmt.Sprintf(`{"status":{"eventsReplayed":%d,"totalEvents":%d}}`, self.eventsReplayed, totalEvents)
+	_, err := self.dynamicClient.Resource(simulationGVR).Patch(
+		ctx,
+		self.opts.SimName,
+		types.MergePatchType,
+		[]byte(patch),
+		metav1.PatchOptions{},
+		"status",
+	)
+	if err != nil {
+		self.logger.WithError(err).Warn("could not update simulation status")
+	}
+}
+
+// cleanup deletes the simulation root, which cleans up all the virtual namespaces and objects
+func (self *Runner) cleanup() {
+	self.logger.Infof("cleaning up simulation %s", self.opts.SimName)
+	err := self.dynamicClient.Resource(simulationRootGVR).Delete(
+		context.Background(),
+		self.opts.SimRoot,
+		metav1.DeleteOptions{},
+	)
+	if err != nil {
+		self.logger.WithError(err).Error("could not delete simulation root")
+	}
+}
@@ -0,0 +1,358 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+
+	"simkube/lib/go/testutils"
+	"simkube/lib/go/trace"
+)
+
+var deploymentGVR = appsv1.SchemeGroupVersion.WithResource("deployments") //nolint:gochecknoglobals
+
+func testTrace() *trace.Trace {
+	deployment := testDeploymentObj()
+	scaled := testDeploymentObj()
+	unstructured.SetNestedField(scaled.Object, int64(5), "spec", "replicas") //nolint:errcheck // can't fail
+
+	return &trace.Trace{
+		Version: trace.CurrentTraceFormat,
+		Config:  trace.DefaultTracerConfig(),
+		Events: []trace.Event{
+			{Ts: 100, AppliedObjs: []*unstructured.Unstructured{deployment}},
+			{Ts: 110, AppliedObjs: []*unstructured.Unstructured{scaled}},
+			{Ts: 115, DeletedObjs: []*unstructured.Unstructured{testDeploymentObj()}},
+		},
+		Index: map[string]uint64{testNamespace + "/" + testDeployment: 1234},
+	}
+}
+
+// The fake dynamic client doesn't support server-side apply; this reactor creates or
+// replaces the object with the applied configuration instead
+func newApplyDynamicClient(objs ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	root := &unstructured.Unstructured{}
+	root.SetAPIVersion("simkube.io/v1")
+	root.SetKind("SimulationRoot")
+	root.SetName(testSimRoot)
+	root.SetUID(types.UID(testSimRootUID))
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		scheme.Scheme,
+		map[schema.GroupVersionResource]string{
+			simulationGVR:     "SimulationList",
+			simulationRootGVR: "SimulationRootList",
+		},
+		append(objs, root)...,
+	)
+	dynamicClient.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch, ok := action.(k8stesting.PatchAction)
+		if !ok || patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(patch.GetPatch()); err != nil {
+			return true, nil, err
+		}
+
+		tracker := dynamicClient.Tracker()
+		gvr, ns := action.GetResource(), action.GetNamespace()
+		if _, err := tracker.Get(gvr, ns, patch.GetName()); apierrors.IsNotFound(err) {
+			return true, obj, tracker.Create(gvr, obj, ns)
+		}
+		return true, obj, tracker.Update(gvr, obj, ns)
+	})
+	return dynamicClient
+}
+
+func newTestRunner(
+	tr *trace.Trace,
+	objs ...runtime.Object,
+) (*Runner, *dynamicfake.FakeDynamicClient, clockwork.FakeClock) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Node"), meta.RESTScopeRoot)
+
+	dynamicClient := newApplyDynamicClient(objs...)
+	runner := NewRunner(testOptions(), tr, dynamicClient, mapper)
+	clock := clockwork.NewFakeClock()
+	runner.clock = clock
+	runner.logger = testutils.GetFakeLogger()
+	return runner, dynamicClient, clock
+}
+
+func virtualReplicas(t *testing.T, dynamicClient *dynamicfake.FakeDynamicClient) int64 {
+	vobj, err := dynamicClient.Resource(deploymentGVR).Namespace(testVirtualNs).Get(
+		context.TODO(),
+		testDeployment,
+		metav1.GetOptions{},
+	)
+	assert.Nil(t, err)
+	replicas, _, err := unstructured.NestedInt64(vobj.Object, "spec", "replicas")
+	assert.Nil(t, err)
+	return replicas
+}
+
+func TestRunnerRun(t *testing.T) {
+	runner, dynamicClient, clock := newTestRunner(testTrace())
+	ctx := context.TODO()
+
+	done := make(chan error)
+	go func() { done <- runner.Run(ctx) }()
+
+	clock.BlockUntil(1)
+	ns, err := dynamicClient.Resource(namespaceGVR).Get(ctx, testVirtualNs, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "true", ns.GetLabels()[virtualLabel])
+	assert.Equal(t, int64(3), virtualReplicas(t, dynamicClient))
+
+	// The runner shouldn't move on until the next event happens
+	clock.Advance(9 * time.Second)
+	clock.BlockUntil(1)
+	assert.Equal(t, int64(3), virtualReplicas(t, dynamicClient))
+
+	clock.Advance(time.Second)
+	clock.BlockUntil(1)
+	assert.Equal(t, int64(5), virtualReplicas(t, dynamicClient))
+
+	clock.Advance(5 * time.Second)
+	assert.Nil(t, <-done)
+
+	_, err = dynamicClient.Resource(deploymentGVR).Namespace(testVirtualNs).Get(
+		ctx,
+		testDeployment,
+		metav1.GetOptions{},
+	)
+	assert.True(t, apierrors.IsNotFound(err))
+	_, err = dynamicClient.Resource(simulationRootGVR).Get(ctx, testSimRoot, metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestRunnerRunSpeed(t *testing.T) {
+	runner, dynamicClient, clock := newTestRunner(testTrace())
+	runner.opts.Speed = 10
+
+	done := make(chan error)
+	go func() { done <- runner.Run(context.TODO()) }()
+
+	clock.BlockUntil(1)
+	assert.Equal(t, int64(3), virtualReplicas(t, dynamicClient))
+
+	clock.Advance(time.Second)
+	clock.BlockUntil(1)
+	assert.Equal(t, int64(5), virtualReplicas(t, dynamicClient))
+
+	clock.Advance(500 * time.Millisecond)
+	assert.Nil(t, <-done)
+}
+
+func TestRunnerRunRepetitions(t *testing.T) {
+	// Without the delete event, the deployment is still around at the end of the trace
+	tr := testTrace()
+	tr.Events = tr.Events[:2]
+	runner, dynamicClient, clock := newTestRunner(tr)
+	runner.opts.Repetitions = 2
+
+	done := make(chan error)
+	go func() { done <- runner.Run(context.TODO()) }()
+
+	clock.BlockUntil(1)
+	assert.Equal(t, int64(3), virtualReplicas(t, dynamicClient))
+
+	// The second repetition deletes the leftover deployment and starts over
+	clock.Advance(10 * time.Second)
+	clock.BlockUntil(1)
+	assert.Equal(t, int64(3), virtualReplicas(t, dynamicClient))
+	deletes := lo.Filter(dynamicClient.Actions(), func(action k8stesting.Action, _ int) bool {
+		return action.GetVerb() == "delete" && action.GetResource() == deploymentGVR
+	})
+	assert.Len(t, deletes, 1)
+
+	clock.Advance(10 * time.Second)
+	assert.Nil(t, <-done)
+	assert.Equal(t, int64(5), virtualReplicas(t, dynamicClient))
+}
+
+func TestRunnerRunReportsProgress(t *testing.T) {
+	sim := &unstructured.Unstructured{}
+	sim.SetAPIVersion("simkube.io/v1")
+	sim.SetKind("Simulation")
+	sim.SetName(testSimName)
+
+	runner, dynamicClient, clock := newTestRunner(testTrace(), sim)
+	runner.opts.Repetitions = 2
+	ctx := context.TODO()
+
+	progress := func() (int64, int64) {
+		obj, err := dynamicClient.Resource(simulationGVR).Get(ctx, testSimName, metav1.GetOptions{})
+		assert.Nil(t, err)
+		//nolint:errcheck // missing fields are zero
+		replayed, _, _ := unstructured.NestedInt64(obj.Object, "status", "eventsReplayed")
+		//nolint:errcheck // missing fields are zero
+		total, _, _ := unstructured.NestedInt64(obj.Object, "status", "totalEvents")
+		return replayed, total
+	}
+
+	done := make(chan error)
+	go func() { done <- runner.Run(ctx) }()
+
+	clock.BlockUntil(1)
+	replayed, total := progress()
+	assert.Equal(t, int64(1), replayed)
+	assert.Equal(t, int64(6), total)
+
+	for i := 0; i < 3; i++ {
+		clock.Advance(10 * time.Second)
+		clock.BlockUntil(1)
+	}
+	clock.Advance(10 * time.Second)
+	assert.Nil(t, <-done)
+
+	replayed, total = progress()
+	assert.Equal(t, int64(6), replayed)
+	assert.Equal(t, int64(6), total)
+}
+
+func TestRunnerRunMaxDuration(t *testing.T) {
+	runner, dynamicClient, clock := newTestRunner(testTrace())
+	runner.opts.MaxDuration = 12 * time.Second
+
+	done := make(chan error)
+	go func() { done <- runner.Run(context.TODO()) }()
+
+	clock.BlockUntil(1)
+	clock.Advance(10 * time.Second)
+	clock.BlockUntil(1)
+	assert.Equal(t, int64(5), virtualReplicas(t, dynamicClient))
+
+	// The simulation stops before the deployment gets deleted, and that's not an error
+	clock.Advance(2 * time.Second)
+	assert.Nil(t, <-done)
+	assert.Equal(t, int64(5), virtualReplicas(t, dynamicClient))
+
+	_, err := dynamicClient.Resource(simulationRootGVR).Get(context.TODO(), testSimRoot, metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestRunnerRunPaused(t *testing.T) {
+	sim := &unstructured.Unstructured{}
+	sim.SetAPIVersion("simkube.io/v1")
+	sim.SetKind("Simulation")
+	sim.SetName(testSimName)
+	unstructured.SetNestedField(sim.Object, true, "spec", "paused") //nolint:errcheck // can't fail
+
+	runner, dynamicClient, clock := newTestRunner(testTrace(), sim)
+	runner.opts.MaxDuration = 12 * time.Second
+	ctx := context.TODO()
+
+	setPaused := func(paused bool) {
+		obj, err := dynamicClient.Resource(simulationGVR).Get(ctx, testSimName, metav1.GetOptions{})
+		assert.Nil(t, err)
+		unstructured.SetNestedField(obj.Object, paused, "spec", "paused") //nolint:errcheck // can't fail
+		_, err = dynamicClient.Resource(simulationGVR).Update(ctx, obj, metav1.UpdateOptions{})
+		assert.Nil(t, err)
+	}
+	applied := func() bool {
+		_, err := dynamicClient.Resource(deploymentGVR).Namespace(testVirtualNs).Get(
+			ctx,
+			testDeployment,
+			metav1.GetOptions{},
+		)
+		return err == nil
+	}
+
+	done := make(chan error)
+	go func() { done <- runner.Run(ctx) }()
+
+	// Nothing happens until the simulation is resumed
+	clock.BlockUntil(1)
+	assert.False(t, applied())
+	setPaused(false)
+	clock.Advance(pausePollInterval)
+	clock.BlockUntil(1)
+	assert.Equal(t, int64(3), virtualReplicas(t, dynamicClient))
+
+	// Pausing in between events pushes the next event back by however long we were paused
+	setPaused(true)
+	clock.Advance(5 * time.Second)
+	clock.BlockUntil(1)
+	clock.Advance(5 * time.Second)
+	clock.BlockUntil(1)
+	assert.Equal(t, int64(3), virtualReplicas(t, dynamicClient))
+
+	setPaused(false)
+	clock.Advance(5 * time.Second)
+	clock.BlockUntil(1)
+	assert.Equal(t, int64(3), virtualReplicas(t, dynamicClient))
+	clock.Advance(5 * time.Second)
+	clock.BlockUntil(1)
+	assert.Equal(t, int64(5), virtualReplicas(t, dynamicClient))
+
+	// The 15 seconds that the simulation was paused don't count towards the max duration
+	clock.Advance(2 * time.Second)
+	assert.Nil(t, <-done)
+	assert.Equal(t, int64(5), virtualReplicas(t, dynamicClient))
+}
+
+func TestRunnerRunErrors(t *testing.T) {
+	node := &unstructured.Unstructured{}
+	node.SetAPIVersion("v1")
+	node.SetKind("Node")
+	node.SetName("node-1")
+
+	untracked := testTrace()
+	untracked.Config.TrackedObjects = map[string]trace.TrackedObjectConfig{}
+
+	clusterScoped := testTrace()
+	clusterScoped.Config.TrackedObjects["/v1.Node"] = trace.TrackedObjectConfig{PodSpecTemplatePath: "/spec"}
+	clusterScoped.Events[0].AppliedObjs = []*unstructured.Unstructured{node}
+
+	cases := map[string]*trace.Trace{
+		"no events":      {Config: trace.DefaultTracerConfig()},
+		"untracked kind": untracked,
+		"cluster-scoped": clusterScoped,
+	}
+
+	for name, tr := range cases {
+		t.Run(name, func(t *testing.T) {
+			runner, dynamicClient, _ := newTestRunner(tr)
+			assert.NotNil(t, runner.Run(context.TODO()))
+
+			// The simulation root is only cleaned up if we got far enough to find it
+			_, err := dynamicClient.Resource(simulationRootGVR).Get(context.TODO(), testSimRoot, metav1.GetOptions{})
+			assert.Equal(t, len(tr.Events) > 0, apierrors.IsNotFound(err))
+		})
+	}
+}
+
+func TestRunnerRunCancelled(t *testing.T) {
+	runner, dynamicClient, clock := newTestRunner(testTrace())
+	ctx, cancel := context.WithCancel(context.TODO())
+
+	done := make(chan error)
+	go func() { done <- runner.Run(ctx) }()
+
+	clock.BlockUntil(1)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	_, err := dynamicClient.Resource(simulationRootGVR).Get(context.TODO(), testSimRoot, metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
@@ -0,0 +1,57 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// podTemplates returns the pod templates that the path points to in the object.  The path is
+// a JSON pointer (like "/spec/template"), with one extension that sk-driver also supports: a
+// "*" matches every element of an array, so "/spec/jobs/*/template" points to the template
+// of every job.
+func podTemplates(obj map[string]interface{}, path string) ([]map[string]interface{}, error) {
+	if path == "" || path[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer: %q", path)
+	}
+
+	values := []interface{}{obj}
+	for _, token := range strings.Split(path[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+
+		var next []interface{}
+		for _, v := range values {
+			switch val := v.(type) {
+			case map[string]interface{}:
+				child, ok := val[token]
+				if !ok {
+					return nil, fmt.Errorf("invalid JSON pointer: %s not found in %q", token, path)
+				}
+				next = append(next, child)
+			case []interface{}:
+				if token == "*" {
+					next = append(next, val...)
+					continue
+				}
+				i, err := strconv.Atoi(token)
+				if err != nil || i < 0 || i >= len(val) {
+					return nil, fmt.Errorf("index %s out of bounds in %q", token, path)
+				}
+				next = append(next, val[i])
+			default:
+				return nil, fmt.Errorf("unexpected type at %s in %q", token, path)
+			}
+		}
+		values = next
+	}
+
+	templates := make([]map[string]interface{}, 0, len(values))
+	for _, v := range values {
+		template, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected type at %q", path)
+		}
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
@@ -0,0 +1,54 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPodTemplates(t *testing.T) {
+	cases := map[string]struct {
+		path      string
+		expected  []map[string]interface{}
+		expectErr bool
+	}{
+		"template":    {path: "/spec/template", expected: []map[string]interface{}{{"name": "foo"}}},
+		"array index": {path: "/spec/jobs/1/template", expected: []map[string]interface{}{{"name": "baz"}}},
+		"array wildcard": {
+			path:     "/spec/jobs/*/template",
+			expected: []map[string]interface{}{{"name": "bar"}, {"name": "baz"}},
+		},
+		"escaped":         {path: "/spec/a~1b~0c", expected: []map[string]interface{}{{"name": "quux"}}},
+		"not a pointer":   {path: "spec/template", expectErr: true},
+		"empty":           {path: "", expectErr: true},
+		"missing field":   {path: "/spec/foo", expectErr: true},
+		"out of bounds":   {path: "/spec/jobs/2/template", expectErr: true},
+		"not an array":    {path: "/spec/template/*", expectErr: true},
+		"not an object":   {path: "/spec/replicas", expectErr: true},
+		"through scalars": {path: "/spec/replicas/foo", expectErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			obj := map[string]interface{}{
+				"spec": map[string]interface{}{
+					"replicas": int64(3),
+					"template": map[string]interface{}{"name": "foo"},
+					"jobs": []interface{}{
+						map[string]interface{}{"template": map[string]interface{}{"name": "bar"}},
+						map[string]interface{}{"template": map[string]interface{}{"name": "baz"}},
+					},
+					"a/b~c": map[string]interface{}{"name": "quux"},
+				},
+			}
+
+			templates, err := podTemplates(obj, tc.path)
+			if tc.expectErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expected, templates)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/trace"
+	"simkube/lib/go/util"
+)
+
+var ErrIncompatibleTrace = util.WithKind(util.ErrValidation, errors.New("trace can't be replayed"))
+
+//nolint:gochecknoglobals
+var selfSubjectAccessReviewGVR = schema.GroupVersionResource{
+	Group:    "authorization.k8s.io",
+	Version:  "v1",
+	Resource: "selfsubjectaccessreviews",
+}
+
+// ValidateTrace checks that the runner can replay the trace, without talking to a cluster: the
+// trace has events, all of the objects in it are namespaced and tracked in the trace config, their
+// pod templates can be found, and the virtual namespaces that they'd be replayed into have valid
+// names.  All of the problems are reported at once.
+func ValidateTrace(opts Options, tr *trace.Trace) error {
+	return problemsError(validateTrace(opts, tr))
+}
+
+// CheckClusterCompatibility does the same checks as ValidateTrace, and also checks the trace
+// against the target cluster: all of the kinds in the trace must be served by the cluster (i.e.,
+// the CRDs are installed and the API versions are available), and the virtual namespaces must
+// either exist already, or we must be allowed to create them.  Problems with the trace are
+// reported as ErrIncompatibleTrace; any other error means that the cluster couldn't be checked.
+func CheckClusterCompatibility(
+	ctx context.Context,
+	opts Options,
+	tr *trace.Trace,
+	dynamicClient dynamic.Interface,
+	mapper meta.RESTMapper,
+) error {
+	problems := validateTrace(opts, tr)
+
+	for _, gvk := range traceKinds(tr) {
+		kindProblems, err := checkKind(mapper, gvk)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, kindProblems...)
+	}
+
+	nsProblems, err := checkVirtualNamespaces(ctx, opts, tr, dynamicClient)
+	if err != nil {
+		return err
+	}
+	problems = append(problems, nsProblems...)
+
+	return problemsError(problems)
+}
+
+func validateTrace(opts Options, tr *trace.Trace) []string {
+	if len(tr.Events) == 0 {
+		return []string{"no trace data"}
+	}
+
+	var problems []string
+	for _, gvk := range traceKinds(tr) {
+		kind := simkubev1.KindString(gvk)
+		if _, ok := tr.Config.TrackedObjects[kind]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: not tracked in the trace config", kind))
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, evt := range tr.Events {
+		for _, obj := range evt.AppliedObjs {
+			kind := simkubev1.KindString(obj.GroupVersionKind())
+			if obj.GetNamespace() == "" {
+				problems = appendOnce(problems, seen, fmt.Sprintf("%s: cluster-scoped objects are not supported", kind))
+				continue
+			}
+
+			objConfig, ok := tr.Config.TrackedObjects[kind]
+			if !ok || objConfig.PodSpecTemplatePath == "" {
+				continue
+			}
+			if _, err := podTemplates(obj.Object, objConfig.PodSpecTemplatePath); err != nil {
+				msg := fmt.Sprintf("%s %s/%s: %v", kind, obj.GetNamespace(), obj.GetName(), err)
+				problems = appendOnce(problems, seen, msg)
+			}
+		}
+	}
+
+	for _, namespace := range traceNamespaces(tr) {
+		virtualNs := opts.virtualNamespace(namespace)
+		for _, msg := range validation.IsDNS1123Label(virtualNs) {
+			problems = append(problems, fmt.Sprintf("virtual namespace %q (for %s): %s", virtualNs, namespace, msg))
+		}
+	}
+	return problems
+}
+
+// checkKind looks the kind up on the cluster; if the kind exists, but not at the version in the
+// trace, we list the versions that the cluster does have, since that's probably what the user
+// needs to fix
+func checkKind(mapper meta.RESTMapper, gvk schema.GroupVersionKind) ([]string, error) {
+	kind := simkubev1.KindString(gvk)
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		mappings, err := mapper.RESTMappings(gvk.GroupKind())
+		if err != nil && !meta.IsNoMatchError(err) {
+			return nil, fmt.Errorf("could not look up %s: %w", kind, err)
+		} else if len(mappings) == 0 {
+			return []string{fmt.Sprintf("%s: kind is not available on the cluster (is the CRD installed?)", kind)}, nil
+		}
+
+		versions := make([]string, 0, len(mappings))
+		for _, m := range mappings {
+			versions = append(versions, m.GroupVersionKind.Version)
+		}
+		sort.Strings(versions)
+		return []string{fmt.Sprintf(
+			"%s: version %s is not served by the cluster (available versions: %s)",
+			kind, gvk.Version, strings.Join(versions, ", "),
+		)}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not look up %s: %w", kind, err)
+	}
+
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return []string{fmt.Sprintf("%s: cluster-scoped objects are not supported", kind)}, nil
+	}
+	return nil, nil
+}
+
+// checkVirtualNamespaces makes sure the runner will be able to use the virtual namespaces; ones
+// that already exist are reused (unless they're being deleted), and the rest are created, so we
+// ask the API server if we're allowed to create namespaces
+func checkVirtualNamespaces(
+	ctx context.Context,
+	opts Options,
+	tr *trace.Trace,
+	dynamicClient dynamic.Interface,
+) ([]string, error) {
+	var problems, missing []string
+	for _, namespace := range traceNamespaces(tr) {
+		virtualNs := opts.virtualNamespace(namespace)
+		if len(validation.IsDNS1123Label(virtualNs)) > 0 {
+			// This was already reported by validateTrace
+			continue
+		}
+
+		ns, err := dynamicClient.Resource(namespaceGVR).Get(ctx, virtualNs, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			missing = append(missing, virtualNs)
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("could not get virtual namespace %s: %w", virtualNs, err)
+		}
+
+		if ns.GetDeletionTimestamp() != nil {
+			problems = append(problems, fmt.Sprintf("virtual namespace %s is being deleted", virtualNs))
+		}
+	}
+
+	if len(missing) == 0 {
+		return problems, nil
+	}
+
+	allowed, reason, err := canCreateNamespaces(ctx, dynamicClient)
+	if err != nil {
+		return nil, err
+	} else if !allowed {
+		msg := fmt.Sprintf("not allowed to create virtual namespaces %s", strings.Join(missing, ", "))
+		if reason != "" {
+			msg += ": " + reason
+		}
+		problems = append(problems, msg)
+	}
+	return problems, nil
+}
+
+func canCreateNamespaces(ctx context.Context, dynamicClient dynamic.Interface) (bool, string, error) {
+	review := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "authorization.k8s.io/v1",
+		"kind":       "SelfSubjectAccessReview",
+		"spec": map[string]interface{}{
+			"resourceAttributes": map[string]interface{}{
+				"verb":     "create",
+				"version":  "v1",
+				"resource": "namespaces",
+			},
+		},
+	}}
+
+	resp, err := dynamicClient.Resource(selfSubjectAccessReviewGVR).Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, "", fmt.Errorf("could not check permissions for creating namespaces: %w", err)
+	}
+
+	//nolint:errcheck // a missing or invalid field means we're not allowed
+	allowed, _, _ := unstructured.NestedBool(resp.Object, "status", "allowed")
+	//nolint:errcheck // the reason is optional
+	reason, _, _ := unstructured.NestedString(resp.Object, "status", "reason")
+	return allowed, reason, nil
+}
+
+// traceKinds returns all of the kinds of objects that get applied during the trace, sorted
+func traceKinds(tr *trace.Trace) []schema.GroupVersionKind {
+	kinds := map[string]schema.GroupVersionKind{}
+	for _, evt := range tr.Events {
+		for _, obj := range evt.AppliedObjs {
+			gvk := obj.GroupVersionKind()
+			kinds[simkubev1.KindString(gvk)] = gvk
+		}
+	}
+
+	keys := make([]string, 0, len(kinds))
+	for key := range kinds {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	gvks := make([]schema.GroupVersionKind, 0, len(keys))
+	for _, key := range keys {
+		gvks = append(gvks, kinds[key])
+	}
+	return gvks
+}
+
+// traceNamespaces returns all of the (original) namespaces that objects get applied to
+// during the trace, sorted
+func traceNamespaces(tr *trace.Trace) []string {
+	seen := map[string]bool{}
+	var namespaces []string
+	for _, evt := range tr.Events {
+		for _, obj := range evt.AppliedObjs {
+			if obj.GetNamespace() != "" {
+				namespaces = appendOnce(namespaces, seen, obj.GetNamespace())
+			}
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+func appendOnce(items []string, seen map[string]bool, item string) []string {
+	if seen[item] {
+		return items
+	}
+	seen[item] = true
+	return append(items, item)
+}
+
+func problemsError(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("%w:\n  - %s", ErrIncompatibleTrace, strings.Join(problems, "\n  - "))
+}
@@ -0,0 +1,218 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+
+	"simkube/lib/go/trace"
+)
+
+func testNodeObj() *unstructured.Unstructured {
+	node := &unstructured.Unstructured{}
+	node.SetAPIVersion("v1")
+	node.SetKind("Node")
+	node.SetName("node-1")
+	return node
+}
+
+func testCronJobObj() *unstructured.Unstructured {
+	cronJob := &unstructured.Unstructured{}
+	cronJob.SetAPIVersion("batch/v1beta1")
+	cronJob.SetKind("CronJob")
+	cronJob.SetNamespace(testNamespace)
+	cronJob.SetName("backup")
+	return cronJob
+}
+
+// newValidateDynamicClient answers SelfSubjectAccessReviews for creating namespaces with
+// `allowed`
+func newValidateDynamicClient(allowed bool, objs ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(scheme.Scheme, objs...)
+	dynamicClient.PrependReactor(
+		"create",
+		"selfsubjectaccessreviews",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			create, ok := action.(k8stesting.CreateAction)
+			if !ok {
+				return false, nil, nil
+			}
+			//nolint:errcheck // the test would fail if this isn't unstructured
+			review := create.GetObject().(*unstructured.Unstructured).DeepCopy()
+			//nolint:errcheck // can't fail
+			unstructured.SetNestedField(review.Object, allowed, "status", "allowed")
+			if !allowed {
+				//nolint:errcheck // can't fail
+				unstructured.SetNestedField(review.Object, "no RBAC policy matched", "status", "reason")
+			}
+			return true, review, nil
+		},
+	)
+	return dynamicClient
+}
+
+func testMapper() *meta.DefaultRESTMapper {
+	batchv1 := schema.GroupVersion{Group: "batch", Version: "v1"}
+	mapper := meta.NewDefaultRESTMapper(
+		[]schema.GroupVersion{appsv1.SchemeGroupVersion, corev1.SchemeGroupVersion, batchv1},
+	)
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Node"), meta.RESTScopeRoot)
+	mapper.Add(batchv1.WithKind("CronJob"), meta.RESTScopeNamespace)
+	return mapper
+}
+
+func problems(err error) []string {
+	if err == nil {
+		return nil
+	}
+	_, list, _ := strings.Cut(err.Error(), ":\n  - ")
+	return strings.Split(list, "\n  - ")
+}
+
+func TestValidateTrace(t *testing.T) {
+	badTemplate := testTrace()
+	badTemplate.Config.TrackedObjects["apps/v1.Deployment"] = trace.TrackedObjectConfig{
+		PodSpecTemplatePath: "/spec/jobs",
+	}
+
+	untracked := testTrace()
+	untracked.Events[1].AppliedObjs = append(untracked.Events[1].AppliedObjs, testCronJobObj())
+
+	clusterScoped := testTrace()
+	clusterScoped.Config.TrackedObjects["/v1.Node"] = trace.TrackedObjectConfig{PodSpecTemplatePath: "/spec"}
+	clusterScoped.Events[0].AppliedObjs = append(clusterScoped.Events[0].AppliedObjs, testNodeObj())
+
+	longNs := testTrace()
+	longNs.Events[0].AppliedObjs[0].SetNamespace(strings.Repeat("a", 60))
+
+	for name, tc := range map[string]struct {
+		tr               *trace.Trace
+		expectedProblems []string
+	}{
+		"valid": {tr: testTrace()},
+		"no events": {
+			tr:               &trace.Trace{Config: trace.DefaultTracerConfig()},
+			expectedProblems: []string{"no trace data"},
+		},
+		"bad template path": {
+			tr: badTemplate,
+			expectedProblems: []string{
+				`apps/v1.Deployment default/nginx: invalid JSON pointer: jobs not found in "/spec/jobs"`,
+			},
+		},
+		"untracked kind": {
+			tr:               untracked,
+			expectedProblems: []string{"batch/v1beta1.CronJob: not tracked in the trace config"},
+		},
+		"cluster-scoped": {
+			tr:               clusterScoped,
+			expectedProblems: []string{"/v1.Node: cluster-scoped objects are not supported"},
+		},
+		"virtual namespace too long": {
+			tr: longNs,
+			expectedProblems: []string{
+				`virtual namespace "virtual-` + strings.Repeat("a", 60) + `" (for ` + strings.Repeat("a", 60) +
+					"): must be no more than 63 characters",
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateTrace(testOptions(), tc.tr)
+			assert.Equal(t, tc.expectedProblems, problems(err))
+			if tc.expectedProblems != nil {
+				assert.ErrorIs(t, err, ErrIncompatibleTrace)
+			}
+		})
+	}
+}
+
+func TestCheckClusterCompatibility(t *testing.T) {
+	oldVersion := testTrace()
+	oldVersion.Config.TrackedObjects["batch/v1beta1.CronJob"] = trace.TrackedObjectConfig{PodSpecTemplatePath: "/spec"}
+	cronJob := testCronJobObj()
+	unstructured.SetNestedField(cronJob.Object, "* * * * *", "spec", "schedule") //nolint:errcheck // can't fail
+	oldVersion.Events[0].AppliedObjs = append(oldVersion.Events[0].AppliedObjs, cronJob)
+
+	missingCRD := testTrace()
+	missingCRD.Config.TrackedObjects["example.com/v1.Widget"] = trace.TrackedObjectConfig{PodSpecTemplatePath: "/spec"}
+	widget := testDeploymentObj()
+	widget.SetAPIVersion("example.com/v1")
+	widget.SetKind("Widget")
+	missingCRD.Events[0].AppliedObjs = append(missingCRD.Events[0].AppliedObjs, widget)
+
+	terminating := &unstructured.Unstructured{}
+	terminating.SetAPIVersion("v1")
+	terminating.SetKind("Namespace")
+	terminating.SetName(testVirtualNs)
+	now := metav1.Now()
+	terminating.SetDeletionTimestamp(&now)
+
+	existing := terminating.DeepCopy()
+	existing.SetDeletionTimestamp(nil)
+
+	for name, tc := range map[string]struct {
+		tr               *trace.Trace
+		allowed          bool
+		objs             []runtime.Object
+		expectedProblems []string
+	}{
+		"compatible":       {tr: testTrace(), allowed: true},
+		"namespace exists": {tr: testTrace(), objs: []runtime.Object{existing}},
+		"can't create virtual namespace": {
+			tr: testTrace(),
+			expectedProblems: []string{
+				"not allowed to create virtual namespaces virtual-default: no RBAC policy matched",
+			},
+		},
+		"virtual namespace is terminating": {
+			tr:               testTrace(),
+			objs:             []runtime.Object{terminating},
+			expectedProblems: []string{"virtual namespace virtual-default is being deleted"},
+		},
+		"version not served": {
+			tr:      oldVersion,
+			allowed: true,
+			expectedProblems: []string{
+				"batch/v1beta1.CronJob: version v1beta1 is not served by the cluster (available versions: v1)",
+			},
+		},
+		"CRD not installed": {
+			tr:      missingCRD,
+			allowed: true,
+			expectedProblems: []string{
+				"example.com/v1.Widget: kind is not available on the cluster (is the CRD installed?)",
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dynamicClient := newValidateDynamicClient(tc.allowed, tc.objs...)
+			err := CheckClusterCompatibility(context.TODO(), testOptions(), tc.tr, dynamicClient, testMapper())
+			assert.Equal(t, tc.expectedProblems, problems(err))
+		})
+	}
+}
+
+func TestCheckClusterCompatibilityError(t *testing.T) {
+	dynamicClient := newValidateDynamicClient(true)
+	dynamicClient.PrependReactor("get", "namespaces", func(_ k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+
+	err := CheckClusterCompatibility(context.TODO(), testOptions(), testTrace(), dynamicClient, testMapper())
+	assert.ErrorContains(t, err, "connection refused")
+	assert.NotErrorIs(t, err, ErrIncompatibleTrace)
+}
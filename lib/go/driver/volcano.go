@@ -0,0 +1,21 @@
+package driver
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"simkube/lib/go/volcano"
+)
+
+// isOwnedPodGroup returns true if the object is a Volcano PodGroup that was created for another
+// object (e.g., a VolcanoJob, or a ReplicaSet whose pods use the Volcano scheduler); Volcano
+// creates a new one when the owner is replayed, so these aren't replayed themselves.  PodGroups
+// that were created directly (for pods that name them in the scheduling.k8s.io/group-name
+// annotation) are replayed without their status, so the gang is scheduled again.  The owner
+// references are stripped from the trace, so the tracers record the owner in an annotation.
+func isOwnedPodGroup(obj *unstructured.Unstructured) bool {
+	if !volcano.IsPodGroup(obj.GroupVersionKind()) {
+		return false
+	}
+	_, ok := obj.GetAnnotations()[volcano.OwnerKindAnnotation]
+	return ok || len(obj.GetOwnerReferences()) > 0
+}
@@ -0,0 +1,51 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"simkube/lib/go/trace"
+)
+
+func TestIsOwnedPodGroup(t *testing.T) {
+	vcjob := metav1.OwnerReference{APIVersion: "batch.volcano.sh/v1alpha1", Kind: "Job", Name: "train", UID: "abcd"}
+	cases := map[string]struct {
+		apiVersion string
+		kind       string
+		owners     []metav1.OwnerReference
+		expected   bool
+	}{
+		"owned": {
+			apiVersion: "scheduling.volcano.sh/v1beta1",
+			kind:       "PodGroup",
+			owners:     []metav1.OwnerReference{vcjob},
+			expected:   true,
+		},
+		"standalone": {apiVersion: "scheduling.volcano.sh/v1beta1", kind: "PodGroup", expected: false},
+		"not a podgroup": {
+			apiVersion: "apps/v1",
+			kind:       "ReplicaSet",
+			owners:     []metav1.OwnerReference{vcjob},
+			expected:   false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": tc.apiVersion,
+				"kind":       tc.kind,
+				"metadata":   map[string]interface{}{"namespace": testNamespace, "name": "train"},
+			}}
+			obj.SetOwnerReferences(tc.owners)
+
+			// The owner references don't make it into the trace
+			sanitized := trace.SanitizeObj(obj.GroupVersionKind(), obj)
+			assert.Empty(t, sanitized.GetOwnerReferences())
+			assert.Equal(t, tc.expected, isOwnedPodGroup(sanitized))
+		})
+	}
+}
@@ -1,20 +1,92 @@
 package k8s
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
-func NewClient() (*kubernetes.Clientset, error) {
-	config, err := rest.InClusterConfig()
+// DefaultNodeGroupResource is the kind of object that virtual node groups are made of,
+// in <resource>.<version>.<group> form
+const DefaultNodeGroupResource = "deployments.v1.apps"
+
+// ClientOptions configures client-side rate limiting for the Kubernetes clients; if QPS or
+// Burst is 0, the client-go default (5 QPS with a burst of 10) is used.  Each request can take
+// up to Timeout (0 means no timeout), and requests are sent with UserAgent (by default,
+// simkube/<program name>, so that simkube's requests stand out in the API server's logs and
+// metrics).  Outside of a cluster, the clients are configured from Kubeconfig (by default,
+// $KUBECONFIG or ~/.kube/config), using Context if it's set, or the kubeconfig's current
+// context otherwise.
+type ClientOptions struct {
+	QPS        float32
+	Burst      int
+	Timeout    time.Duration
+	UserAgent  string
+	Kubeconfig string
+	Context    string
+}
+
+// DefaultUserAgent is the user agent for the running program
+func DefaultUserAgent() string {
+	return fmt.Sprintf("simkube/%s", filepath.Base(os.Args[0]))
+}
+
+func (self ClientOptions) restConfig() (*rest.Config, error) {
+	config, err := self.loadConfig()
 	if err != nil {
 		return nil, fmt.Errorf("could not get client config: %w", err)
 	}
 
+	if self.QPS > 0 {
+		config.QPS = self.QPS
+	}
+	if self.Burst > 0 {
+		config.Burst = self.Burst
+	}
+	if self.Timeout > 0 {
+		config.Timeout = self.Timeout
+	}
+	config.UserAgent = self.UserAgent
+	if config.UserAgent == "" {
+		config.UserAgent = DefaultUserAgent()
+	}
+	return config, nil
+}
+
+// loadConfig uses the in-cluster config when we're running in a pod, unless a kubeconfig or a
+// context was asked for explicitly; otherwise it follows the same loading rules as kubectl
+func (self ClientOptions) loadConfig() (*rest.Config, error) {
+	if self.Kubeconfig == "" && self.Context == "" {
+		config, err := rest.InClusterConfig()
+		if err == nil {
+			return config, nil
+		} else if !errors.Is(err, rest.ErrNotInCluster) {
+			return nil, err
+		}
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = self.Kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: self.Context}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+}
+
+func NewClient(opts ClientOptions) (*kubernetes.Clientset, error) {
+	config, err := opts.restConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	k8sClient, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("could not initialize Kubernetes client: %w", err)
@@ -23,6 +95,34 @@ func NewClient() (*kubernetes.Clientset, error) {
 	return k8sClient, nil
 }
 
+func NewDynamicClient(opts ClientOptions) (*dynamic.DynamicClient, error) {
+	config, err := opts.restConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize Kubernetes dynamic client: %w", err)
+	}
+
+	return dynamicClient, nil
+}
+
+// ParseGroupVersionResource parses a fully-qualified resource name, e.g.
+// "statefulsets.v1.apps"; resources in the core group have an empty group, e.g.
+// "replicationcontrollers.v1.".
+func ParseGroupVersionResource(resource string) (schema.GroupVersionResource, error) {
+	gvr, _ := schema.ParseResourceArg(resource)
+	if gvr == nil || gvr.Resource == "" || gvr.Version == "" {
+		return schema.GroupVersionResource{}, fmt.Errorf(
+			"invalid resource %q, expected <resource>.<version>.<group>",
+			resource,
+		)
+	}
+	return *gvr, nil
+}
+
 func NamespacedNameFromObjectMeta(objmeta metav1.ObjectMeta) string {
 	return NamespacedName(objmeta.Namespace, objmeta.Name)
 }
@@ -0,0 +1,134 @@
+package k8s
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const testKubeconfig = `
+apiVersion: v1
+kind: Config
+clusters:
+  - name: dev
+    cluster:
+      server: https://dev.example.com:6443
+  - name: prod
+    cluster:
+      server: https://prod.example.com:6443
+contexts:
+  - name: dev
+    context:
+      cluster: dev
+      user: me
+  - name: prod
+    context:
+      cluster: prod
+      user: me
+current-context: dev
+users:
+  - name: me
+    user:
+      token: abcd
+`
+
+func writeTestKubeconfig(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "config")
+	require.Nil(t, os.WriteFile(path, []byte(testKubeconfig), 0600))
+	return path
+}
+
+func TestRestConfigKubeconfig(t *testing.T) {
+	kubeconfig := writeTestKubeconfig(t)
+
+	// Without any options, we fall back to $KUBECONFIG when we're not in a cluster
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBECONFIG", kubeconfig)
+	config, err := ClientOptions{}.restConfig()
+	require.Nil(t, err)
+	assert.Equal(t, "https://dev.example.com:6443", config.Host)
+	assert.Equal(t, "abcd", config.BearerToken)
+
+	config, err = ClientOptions{Context: "prod"}.restConfig()
+	require.Nil(t, err)
+	assert.Equal(t, "https://prod.example.com:6443", config.Host)
+
+	// An explicit kubeconfig wins over $KUBECONFIG, and over the in-cluster config
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "missing"))
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "443")
+	config, err = ClientOptions{Kubeconfig: kubeconfig}.restConfig()
+	require.Nil(t, err)
+	assert.Equal(t, "https://dev.example.com:6443", config.Host)
+}
+
+func TestRestConfigOptions(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBECONFIG", writeTestKubeconfig(t))
+
+	config, err := ClientOptions{}.restConfig()
+	require.Nil(t, err)
+	assert.Equal(t, float32(0), config.QPS)
+	assert.Equal(t, 0, config.Burst)
+	assert.Equal(t, time.Duration(0), config.Timeout)
+	assert.Equal(t, "simkube/k8s.test", config.UserAgent)
+
+	config, err = ClientOptions{QPS: 50, Burst: 100, Timeout: 30 * time.Second, UserAgent: "sk-vnode/test"}.restConfig()
+	require.Nil(t, err)
+	assert.Equal(t, float32(50), config.QPS)
+	assert.Equal(t, 100, config.Burst)
+	assert.Equal(t, 30*time.Second, config.Timeout)
+	assert.Equal(t, "sk-vnode/test", config.UserAgent)
+}
+
+func TestRestConfigErrors(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBECONFIG", writeTestKubeconfig(t))
+
+	_, err := ClientOptions{Context: "staging"}.restConfig()
+	assert.ErrorContains(t, err, "could not get client config")
+
+	_, err = ClientOptions{Kubeconfig: filepath.Join(t.TempDir(), "missing")}.restConfig()
+	assert.ErrorContains(t, err, "could not get client config")
+}
+
+func TestParseGroupVersionResource(t *testing.T) {
+	cases := map[string]struct {
+		resource  string
+		expected  schema.GroupVersionResource
+		expectErr bool
+	}{
+		"deployments": {
+			resource: DefaultNodeGroupResource,
+			expected: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+		},
+		"custom resource": {
+			resource: "nodegroups.v1alpha1.example.com",
+			expected: schema.GroupVersionResource{Group: "example.com", Version: "v1alpha1", Resource: "nodegroups"},
+		},
+		"core group": {
+			resource: "replicationcontrollers.v1.",
+			expected: schema.GroupVersionResource{Version: "v1", Resource: "replicationcontrollers"},
+		},
+		"no version":     {resource: "statefulsets.apps", expectErr: true},
+		"only resource":  {resource: "statefulsets", expectErr: true},
+		"empty resource": {resource: ".v1.apps", expectErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			gvr, err := ParseGroupVersionResource(tc.resource)
+			if tc.expectErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expected, gvr)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	DefaultLeaseDuration = 15 * time.Second
+	DefaultRenewDeadline = 10 * time.Second
+	DefaultRetryPeriod   = 2 * time.Second
+
+	PodNameEnvKey      = "POD_NAME"
+	PodNamespaceEnvKey = "POD_NAMESPACE"
+)
+
+// leaderElectionMetrics has the state of every lease that this process competes for, keyed
+// by "<lease name>.<metric>": "leader" is 1 while we hold the lease, "transitions" counts how
+// many times we've acquired or lost it, and "last_transition" is the unix time of the last one
+//
+//nolint:gochecknoglobals
+var leaderElectionMetrics = expvar.NewMap("leader_election")
+
+// LeaderElectionOptions configures a lease-based leader election.  If Namespace is empty, the
+// lease is created in the component's own namespace (from the POD_NAMESPACE environment
+// variable), and if Identity is empty, the pod name (from POD_NAME) or the hostname is used.
+// The lease timings default to the same values as the Kubernetes controller managers.
+type LeaderElectionOptions struct {
+	Namespace string
+	LeaseName string
+	Identity  string
+
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// LeaderCallbacks are called as the election progresses; all of them are optional.  Once the
+// leader's context is cancelled (or it fails to renew the lease), OnStoppedLeading is called;
+// components that can't safely keep going as a standby should exit from it.
+type LeaderCallbacks struct {
+	OnStartedLeading func(context.Context)
+	OnStoppedLeading func()
+	OnNewLeader      func(identity string)
+}
+
+// LeaderElector wraps the client-go leader elector with our defaults, logging, and metrics
+type LeaderElector struct {
+	identity  string
+	leaseName string
+	isLeader  atomic.Bool
+	elector   *leaderelection.LeaderElector
+}
+
+func (self *LeaderElectionOptions) namespace() (string, error) {
+	if self.Namespace != "" {
+		return self.Namespace, nil
+	} else if namespace := os.Getenv(PodNamespaceEnvKey); namespace != "" {
+		return namespace, nil
+	}
+	return "", errors.New("a namespace for the leader election lease is required")
+}
+
+// The pod name is unique among the replicas (the hostname is too, unless the pod uses the
+// host network)
+func (self *LeaderElectionOptions) identity() (string, error) {
+	if self.Identity != "" {
+		return self.Identity, nil
+	} else if name := os.Getenv(PodNameEnvKey); name != "" {
+		return name, nil
+	}
+
+	//nolint:wrapcheck // wrapped by the caller
+	return os.Hostname()
+}
+
+func NewLeaderElector(
+	client kubernetes.Interface,
+	opts LeaderElectionOptions,
+	callbacks LeaderCallbacks,
+) (*LeaderElector, error) {
+	if opts.LeaseName == "" {
+		return nil, errors.New("a name for the leader election lease is required")
+	}
+
+	namespace, err := opts.namespace()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := opts.identity()
+	if err != nil {
+		return nil, fmt.Errorf("could not determine leader election identity: %w", err)
+	}
+
+	self := &LeaderElector{identity: id, leaseName: opts.LeaseName}
+	self.elector, err = leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Namespace: namespace, Name: opts.LeaseName},
+			Client:     client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: id},
+		},
+		LeaseDuration:   durationOrDefault(opts.LeaseDuration, DefaultLeaseDuration),
+		RenewDeadline:   durationOrDefault(opts.RenewDeadline, DefaultRenewDeadline),
+		RetryPeriod:     durationOrDefault(opts.RetryPeriod, DefaultRetryPeriod),
+		ReleaseOnCancel: true,
+		Name:            opts.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				log.Infof("%s is now the leader for %s", id, opts.LeaseName)
+				self.setLeader(true)
+				if callbacks.OnStartedLeading != nil {
+					callbacks.OnStartedLeading(ctx)
+				}
+			},
+			OnStoppedLeading: func() {
+				log.Infof("%s is no longer the leader for %s", id, opts.LeaseName)
+				self.setLeader(false)
+				if callbacks.OnStoppedLeading != nil {
+					callbacks.OnStoppedLeading()
+				}
+			},
+			OnNewLeader: func(leaderID string) {
+				if leaderID != id {
+					log.Infof("waiting for the leader for %s (%s) to step down", opts.LeaseName, leaderID)
+				}
+				if callbacks.OnNewLeader != nil {
+					callbacks.OnNewLeader(leaderID)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not configure leader election: %w", err)
+	}
+
+	leaderElectionMetrics.Set(opts.LeaseName+".leader", new(expvar.Int))
+	return self, nil
+}
+
+// Run competes for the lease until ctx is cancelled; it blocks, so it's usually called in
+// a separate goroutine
+func (self *LeaderElector) Run(ctx context.Context) {
+	self.elector.Run(ctx)
+}
+
+func (self *LeaderElector) IsLeader() bool {
+	return self.isLeader.Load()
+}
+
+func (self *LeaderElector) Identity() string {
+	return self.identity
+}
+
+func (self *LeaderElector) setLeader(isLeader bool) {
+	if self.isLeader.Swap(isLeader) == isLeader {
+		return
+	}
+
+	var leader expvar.Int
+	if isLeader {
+		leader.Set(1)
+	}
+	var lastTransition expvar.Int
+	lastTransition.Set(time.Now().Unix())
+
+	leaderElectionMetrics.Set(self.leaseName+".leader", &leader)
+	leaderElectionMetrics.Set(self.leaseName+".last_transition", &lastTransition)
+	leaderElectionMetrics.Add(self.leaseName+".transitions", 1)
+}
+
+func durationOrDefault(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,94 @@
+package k8s
+
+import (
+	"context"
+	"expvar"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func transitionCount(leaseName string) int64 {
+	if v, ok := leaderElectionMetrics.Get(leaseName + ".transitions").(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestLeaderElector(t *testing.T) {
+	t.Setenv(PodNameEnvKey, "sk-test-1")
+	t.Setenv(PodNamespaceEnvKey, "simkube")
+
+	client := fake.NewSimpleClientset()
+	started := make(chan struct{})
+	var newLeader atomic.Value
+	elector, err := NewLeaderElector(
+		client,
+		LeaderElectionOptions{LeaseName: "sk-test"},
+		LeaderCallbacks{
+			OnStartedLeading: func(context.Context) { close(started) },
+			OnNewLeader:      func(id string) { newLeader.Store(id) },
+		},
+	)
+	require.Nil(t, err)
+	transitions := transitionCount("sk-test")
+	assert.Equal(t, "sk-test-1", elector.Identity())
+	assert.False(t, elector.IsLeader())
+	assert.Equal(t, "0", leaderElectionMetrics.Get("sk-test.leader").String())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go elector.Run(ctx)
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("never became the leader")
+	}
+	assert.True(t, elector.IsLeader())
+	assert.Eventually(t, func() bool { return newLeader.Load() == "sk-test-1" }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "1", leaderElectionMetrics.Get("sk-test.leader").String())
+	assert.Equal(t, transitions+1, transitionCount("sk-test"))
+
+	lease, err := client.CoordinationV1().Leases("simkube").Get(context.TODO(), "sk-test", metav1.GetOptions{})
+	require.Nil(t, err)
+	assert.Equal(t, "sk-test-1", *lease.Spec.HolderIdentity)
+}
+
+func TestLeaderElectorOptions(t *testing.T) {
+	t.Setenv(PodNamespaceEnvKey, "")
+
+	cases := map[string]struct {
+		opts        LeaderElectionOptions
+		expectedErr string
+	}{
+		"no lease name": {
+			opts:        LeaderElectionOptions{Namespace: "simkube"},
+			expectedErr: "lease is required",
+		},
+		"no namespace": {
+			opts:        LeaderElectionOptions{LeaseName: "sk-test"},
+			expectedErr: "namespace for the leader election lease is required",
+		},
+		"explicit identity": {
+			opts: LeaderElectionOptions{Namespace: "simkube", LeaseName: "sk-test", Identity: "foo"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			elector, err := NewLeaderElector(fake.NewSimpleClientset(), tc.opts, LeaderCallbacks{})
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			} else {
+				require.Nil(t, err)
+				assert.Equal(t, tc.opts.Identity, elector.Identity())
+			}
+		})
+	}
+}
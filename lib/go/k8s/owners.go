@@ -0,0 +1,99 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// An OwnersCache looks up the ownership chain of objects, i.e., all of the object's owners,
+// and their owners, and so on; the chains are cached, since they don't change.  It isn't safe
+// to use from multiple goroutines at once.
+type OwnersCache struct {
+	dynamicClient dynamic.Interface
+	mapper        meta.RESTMapper
+	owners        map[string][]metav1.OwnerReference
+}
+
+func NewOwnersCache(dynamicClient dynamic.Interface, mapper meta.RESTMapper) *OwnersCache {
+	return &OwnersCache{
+		dynamicClient: dynamicClient,
+		mapper:        mapper,
+		owners:        map[string][]metav1.OwnerReference{},
+	}
+}
+
+// OwnerChain returns the object's owners, followed by the owners of each of them, and so on;
+// objects without a name (e.g., pods that are being admitted with a generateName) aren't
+// cached, but their owners are
+func (self *OwnersCache) OwnerChain(ctx context.Context, obj metav1.Object) ([]metav1.OwnerReference, error) {
+	nsName := NamespacedName(obj.GetNamespace(), obj.GetName())
+	if owners, ok := self.owners[nsName]; ok {
+		return owners, nil
+	}
+
+	owners := append([]metav1.OwnerReference{}, obj.GetOwnerReferences()...)
+	for _, rf := range obj.GetOwnerReferences() {
+		owner, err := self.getOwner(ctx, obj.GetNamespace(), rf)
+		if err != nil {
+			return nil, fmt.Errorf("could not find owner %s/%s of %s: %w", rf.Kind, rf.Name, nsName, err)
+		}
+
+		ownerOwners, err := self.OwnerChain(ctx, owner)
+		if err != nil {
+			return nil, err
+		}
+		owners = append(owners, ownerOwners...)
+	}
+
+	if obj.GetName() != "" {
+		self.owners[nsName] = owners
+	}
+	return owners, nil
+}
+
+func (self *OwnersCache) Lookup(namespace, name string) ([]metav1.OwnerReference, bool) {
+	owners, ok := self.owners[NamespacedName(namespace, name)]
+	return owners, ok
+}
+
+// Forget removes the object from the cache, e.g., once it's been deleted
+func (self *OwnersCache) Forget(namespace, name string) {
+	delete(self.owners, NamespacedName(namespace, name))
+}
+
+func (self *OwnersCache) getOwner(
+	ctx context.Context,
+	namespace string,
+	rf metav1.OwnerReference,
+) (*unstructured.Unstructured, error) {
+	gv, err := schema.ParseGroupVersion(rf.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid owner reference: %w", err)
+	}
+	mapping, err := self.mapper.RESTMapping(gv.WithKind(rf.Kind).GroupKind(), gv.Version)
+	if err != nil {
+		return nil, fmt.Errorf("could not find resource: %w", err)
+	}
+
+	var client dynamic.ResourceInterface = self.dynamicClient.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		client = self.dynamicClient.Resource(mapping.Resource).Namespace(namespace)
+	}
+
+	var owner *unstructured.Unstructured
+	err = Retry(func() (err error) {
+		owner, err = client.Get(ctx, rf.Name, metav1.GetOptions{})
+		//nolint:wrapcheck // wrapped below
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get owner: %w", err)
+	}
+	return owner, nil
+}
@@ -0,0 +1,61 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func TestOwnerChain(t *testing.T) {
+	deploymentRef := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "nginx"}
+	replicaSetRef := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "nginx-abcde"}
+	deployment := &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "nginx"},
+	}
+	replicaSet := &appsv1.ReplicaSet{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "ReplicaSet"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            "nginx-abcde",
+			OwnerReferences: []metav1.OwnerReference{deploymentRef},
+		},
+	}
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("ReplicaSet"), meta.RESTScopeNamespace)
+	cache := NewOwnersCache(dynamicfake.NewSimpleDynamicClient(scheme.Scheme, deployment, replicaSet), mapper)
+
+	// Pods that don't have a name yet aren't cached
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:       "default",
+		GenerateName:    "nginx-abcde-",
+		OwnerReferences: []metav1.OwnerReference{replicaSetRef},
+	}}
+	owners, err := cache.OwnerChain(context.TODO(), pod)
+	assert.Nil(t, err)
+	assert.Equal(t, []metav1.OwnerReference{replicaSetRef, deploymentRef}, owners)
+	_, ok := cache.Lookup("default", "")
+	assert.False(t, ok)
+
+	owners, ok = cache.Lookup("default", "nginx-abcde")
+	assert.True(t, ok)
+	assert.Equal(t, []metav1.OwnerReference{deploymentRef}, owners)
+
+	cache.Forget("default", "nginx-abcde")
+	_, ok = cache.Lookup("default", "nginx-abcde")
+	assert.False(t, ok)
+
+	// Missing owners are an error
+	pod.ObjectMeta.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "foo"}}
+	_, err = cache.OwnerChain(context.TODO(), pod)
+	assert.NotNil(t, err)
+}
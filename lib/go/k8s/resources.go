@@ -0,0 +1,71 @@
+package k8s
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	NvidiaGPUResource corev1.ResourceName = "nvidia.com/gpu"
+)
+
+// These mirror the helpers in k8s.io/kubernetes/pkg/apis/core/v1/helper, which we can't
+// import directly
+
+func IsHugePageResourceName(name corev1.ResourceName) bool {
+	return strings.HasPrefix(string(name), corev1.ResourceHugePagesPrefix)
+}
+
+// Extended resources are any resources that are fully-qualified and don't live in the
+// kubernetes.io namespace, e.g., nvidia.com/gpu
+func IsExtendedResourceName(name corev1.ResourceName) bool {
+	nameStr := string(name)
+	if !strings.Contains(nameStr, "/") || strings.HasPrefix(nameStr, corev1.ResourceDefaultNamespacePrefix) {
+		return false
+	}
+	return !strings.HasPrefix(nameStr, corev1.DefaultResourceRequestsPrefix)
+}
+
+// PodRequests computes the effective resource requests for a pod, following the same
+// rules as the scheduler: the sum of all the app containers' requests, or the largest
+// init container request, whichever is larger.  Extended resources can't be overcommitted,
+// so if only the limit is set it is used as the request.
+func PodRequests(pod *corev1.Pod) corev1.ResourceList {
+	reqs := corev1.ResourceList{}
+	for i := range pod.Spec.Containers {
+		for name, q := range containerRequests(&pod.Spec.Containers[i]) {
+			total := reqs[name]
+			total.Add(q)
+			reqs[name] = total
+		}
+	}
+
+	for i := range pod.Spec.InitContainers {
+		for name, q := range containerRequests(&pod.Spec.InitContainers[i]) {
+			if current, ok := reqs[name]; !ok || q.Cmp(current) > 0 {
+				reqs[name] = q.DeepCopy()
+			}
+		}
+	}
+
+	for name, q := range pod.Spec.Overhead {
+		total := reqs[name]
+		total.Add(q)
+		reqs[name] = total
+	}
+	return reqs
+}
+
+func containerRequests(c *corev1.Container) corev1.ResourceList {
+	reqs := c.Resources.Requests.DeepCopy()
+	if reqs == nil {
+		reqs = corev1.ResourceList{}
+	}
+	for name, q := range c.Resources.Limits {
+		if _, ok := reqs[name]; !ok && (IsExtendedResourceName(name) || IsHugePageResourceName(name)) {
+			reqs[name] = q.DeepCopy()
+		}
+	}
+	return reqs
+}
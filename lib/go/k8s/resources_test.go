@@ -0,0 +1,56 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestIsExtendedResourceName(t *testing.T) {
+	cases := map[corev1.ResourceName]bool{
+		corev1.ResourceCPU:              false,
+		corev1.ResourceHugePagesPrefix:  false,
+		"kubernetes.io/foo":             false,
+		"requests.example.com/foo":      false,
+		NvidiaGPUResource:               true,
+		"example.com/some-fpga-cluster": true,
+	}
+
+	for name, expected := range cases {
+		assert.Equal(t, expected, IsExtendedResourceName(name), name)
+	}
+}
+
+func TestPodRequests(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+				},
+			}},
+			Containers: []corev1.Container{
+				{
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+						Limits:   corev1.ResourceList{NvidiaGPUResource: resource.MustParse("1")},
+					},
+				},
+				{
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU: resource.MustParse("1"),
+							NvidiaGPUResource:  resource.MustParse("2"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	reqs := PodRequests(pod)
+	assert.True(t, resource.MustParse("4").Equal(reqs[corev1.ResourceCPU]))
+	assert.True(t, resource.MustParse("3").Equal(reqs[NvidiaGPUResource]))
+}
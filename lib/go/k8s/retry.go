@@ -0,0 +1,41 @@
+package k8s
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+
+	"simkube/lib/go/util"
+)
+
+// DefaultBackoff doubles the wait between attempts, starting at 100ms, for up to 5 attempts
+// (about 1.5s in total); the client-go default is too short to ride out a throttled or
+// restarting API server.
+//
+//nolint:gochecknoglobals
+var DefaultBackoff = wait.Backoff{
+	Steps:    5,
+	Duration: 100 * time.Millisecond,
+	Factor:   2.0,
+	Jitter:   0.1,
+	Cap:      5 * time.Second,
+}
+
+// IsRetriable returns true for errors that are likely to go away if the request is made
+// again; see util.IsRetriable for the details
+func IsRetriable(err error) bool {
+	return util.IsRetriable(err)
+}
+
+// Retry calls fn until it succeeds or returns an error that isn't retriable, backing off
+// exponentially between attempts; if it runs out of attempts, the last error is returned
+func Retry(fn func() error) error {
+	return RetryWithBackoff(DefaultBackoff, fn)
+}
+
+// RetryWithBackoff is like Retry, but with a custom backoff
+func RetryWithBackoff(backoff wait.Backoff, fn func() error) error {
+	//nolint:wrapcheck // the error comes from fn, which wraps it if needed
+	return retry.OnError(backoff, IsRetriable, fn)
+}
@@ -0,0 +1,65 @@
+package k8s
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestIsRetriable(t *testing.T) {
+	podsResource := schema.GroupResource{Resource: "pods"}
+	cases := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"throttled":   {apierrors.NewTooManyRequests("slow down", 1), true},
+		"timeout":     {apierrors.NewServerTimeout(podsResource, "get", 1), true},
+		"unavailable": {apierrors.NewServiceUnavailable("try again"), true},
+		"conflict":    {apierrors.NewConflict(podsResource, "foo", errors.New("changed")), true},
+		"not found":   {apierrors.NewNotFound(podsResource, "foo"), false},
+		"forbidden":   {apierrors.NewForbidden(podsResource, "foo", errors.New("no")), false},
+		"other":       {errors.New("asdf"), false},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsRetriable(tc.err))
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	calls := 0
+	err := Retry(func() error {
+		calls += 1
+		if calls < 3 {
+			return apierrors.NewServiceUnavailable("try again")
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = Retry(func() error {
+		calls += 1
+		return apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "foo")
+	})
+	assert.True(t, apierrors.IsNotFound(err))
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryWithBackoffGivesUp(t *testing.T) {
+	calls := 0
+	err := RetryWithBackoff(wait.Backoff{Steps: 3, Duration: time.Millisecond, Factor: 2.0}, func() error {
+		calls += 1
+		return apierrors.NewTooManyRequests("slow down", 1)
+	})
+	assert.True(t, apierrors.IsTooManyRequests(err))
+	assert.Equal(t, 3, calls)
+}
@@ -0,0 +1,93 @@
+package karpenter
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// NodeClaimEnvKey is the environment variable that tells a virtual node which NodeClaim it
+	// was launched for
+	NodeClaimEnvKey = "KARPENTER_NODECLAIM"
+
+	NodePoolLabel     = "karpenter.sh/nodepool"
+	CapacityTypeLabel = "karpenter.sh/capacity-type"
+
+	defaultCapacityType = "on-demand"
+)
+
+// NodeClaimResource is the (cluster-scoped) Karpenter NodeClaim resource
+//
+//nolint:gochecknoglobals
+var NodeClaimResource = schema.GroupVersionResource{Group: "karpenter.sh", Version: "v1", Resource: "nodeclaims"}
+
+// A NodeClaim is the subset of Karpenter's NodeClaim that the virtual nodes care about; we don't
+// import the Karpenter API, since it would pull in the whole of Karpenter (and a different
+// version of controller-runtime) for a handful of fields
+type NodeClaim struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NodeClaimSpec   `json:"spec,omitempty"`
+	Status NodeClaimStatus `json:"status,omitempty"`
+}
+
+type NodeClaimSpec struct {
+	Taints        []corev1.Taint            `json:"taints,omitempty"`
+	StartupTaints []corev1.Taint            `json:"startupTaints,omitempty"`
+	Requirements  []NodeSelectorRequirement `json:"requirements,omitempty"`
+}
+
+type NodeSelectorRequirement struct {
+	Key      string                      `json:"key"`
+	Operator corev1.NodeSelectorOperator `json:"operator"`
+	Values   []string                    `json:"values,omitempty"`
+}
+
+type NodeClaimStatus struct {
+	ProviderID  string              `json:"providerID,omitempty"`
+	NodeName    string              `json:"nodeName,omitempty"`
+	Capacity    corev1.ResourceList `json:"capacity,omitempty"`
+	Allocatable corev1.ResourceList `json:"allocatable,omitempty"`
+}
+
+func FromUnstructured(obj *unstructured.Unstructured) (*NodeClaim, error) {
+	var claim NodeClaim
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), &claim); err != nil {
+		return nil, fmt.Errorf("could not parse NodeClaim %s: %w", obj.GetName(), err)
+	}
+	return &claim, nil
+}
+
+func GetNodeClaim(ctx context.Context, dynamicClient dynamic.Interface, name string) (*NodeClaim, error) {
+	obj, err := dynamicClient.Resource(NodeClaimResource).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not get NodeClaim %s: %w", name, err)
+	}
+	return FromUnstructured(obj)
+}
+
+// NodeLabels returns the labels that the node needs for Karpenter to consider it a match for the
+// NodeClaim: the NodeClaim's own labels, plus a value for every requirement that has to be "In"
+// some set of values (normally the cloud provider picks one, e.g., the cheapest instance type;
+// we just take the first).  Karpenter also needs to know the capacity type, which is on-demand
+// unless the requirements say otherwise.
+func (self *NodeClaim) NodeLabels() map[string]string {
+	labels := map[string]string{CapacityTypeLabel: defaultCapacityType}
+	for _, req := range self.Spec.Requirements {
+		if req.Operator == corev1.NodeSelectorOpIn && len(req.Values) > 0 {
+			labels[req.Key] = req.Values[0]
+		}
+	}
+	for key, value := range self.ObjectMeta.Labels {
+		labels[key] = value
+	}
+	return labels
+}
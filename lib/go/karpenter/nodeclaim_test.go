@@ -0,0 +1,123 @@
+package karpenter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func testNodeClaim() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "karpenter.sh/v1",
+		"kind":       "NodeClaim",
+		"metadata": map[string]interface{}{
+			"name":   "default-abcde",
+			"labels": map[string]interface{}{NodePoolLabel: "default"},
+		},
+		"spec": map[string]interface{}{
+			"requirements": []interface{}{
+				map[string]interface{}{
+					"key":      "node.kubernetes.io/instance-type",
+					"operator": "In",
+					"values":   []interface{}{"m6i.large", "m6i.xlarge"},
+				},
+				map[string]interface{}{
+					"key":      "karpenter.k8s.aws/instance-family",
+					"operator": "NotIn",
+					"values":   []interface{}{"t3"},
+				},
+			},
+			"taints": []interface{}{
+				map[string]interface{}{"key": "dedicated", "value": "batch", "effect": "NoSchedule"},
+			},
+		},
+		"status": map[string]interface{}{
+			"providerID": "simkube://default-abcde",
+			"capacity":   map[string]interface{}{"cpu": "2", "memory": "8Gi"},
+		},
+	}}
+}
+
+func TestGetNodeClaim(t *testing.T) {
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{NodeClaimResource: "NodeClaimList"},
+		testNodeClaim(),
+	)
+
+	claim, err := GetNodeClaim(context.TODO(), dynamicClient, "default-abcde")
+	assert.Nil(t, err)
+	assert.Equal(t, "simkube://default-abcde", claim.Status.ProviderID)
+	assert.Equal(
+		t,
+		[]corev1.Taint{{Key: "dedicated", Value: "batch", Effect: corev1.TaintEffectNoSchedule}},
+		claim.Spec.Taints,
+	)
+	assert.Equal(t, corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("2"),
+		corev1.ResourceMemory: resource.MustParse("8Gi"),
+	}, claim.Status.Capacity)
+
+	_, err = GetNodeClaim(context.TODO(), dynamicClient, "missing")
+	assert.NotNil(t, err)
+}
+
+func TestNodeLabels(t *testing.T) {
+	cases := map[string]struct {
+		labels       map[string]string
+		requirements []NodeSelectorRequirement
+		expected     map[string]string
+	}{
+		"empty": {
+			expected: map[string]string{CapacityTypeLabel: "on-demand"},
+		},
+		"requirements": {
+			labels: map[string]string{NodePoolLabel: "default"},
+			requirements: []NodeSelectorRequirement{
+				{
+					Key:      "node.kubernetes.io/instance-type",
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   []string{"c6i.large", "c6i.xlarge"},
+				},
+				{Key: CapacityTypeLabel, Operator: corev1.NodeSelectorOpIn, Values: []string{"spot"}},
+				{Key: "karpenter.k8s.aws/instance-family", Operator: corev1.NodeSelectorOpNotIn, Values: []string{"t3"}},
+				{Key: "topology.kubernetes.io/zone", Operator: corev1.NodeSelectorOpIn},
+			},
+			expected: map[string]string{
+				NodePoolLabel:                      "default",
+				CapacityTypeLabel:                  "spot",
+				"node.kubernetes.io/instance-type": "c6i.large",
+			},
+		},
+		"labels win": {
+			labels: map[string]string{"node.kubernetes.io/instance-type": "c6i.xlarge"},
+			requirements: []NodeSelectorRequirement{
+				{
+					Key:      "node.kubernetes.io/instance-type",
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   []string{"c6i.large", "c6i.xlarge"},
+				},
+			},
+			expected: map[string]string{
+				CapacityTypeLabel:                  "on-demand",
+				"node.kubernetes.io/instance-type": "c6i.xlarge",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			claim := &NodeClaim{}
+			claim.ObjectMeta.Labels = tc.labels
+			claim.Spec.Requirements = tc.requirements
+			assert.Equal(t, tc.expected, claim.NodeLabels())
+		})
+	}
+}
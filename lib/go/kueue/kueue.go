@@ -0,0 +1,44 @@
+package kueue
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// These are the labels and scheduling gate that Kueue puts on the objects it manages; we don't
+// import the Kueue API for a handful of strings
+const (
+	Group = "kueue.x-k8s.io"
+
+	// QueueNameLabel picks the LocalQueue that a job (or pod) is submitted to, and
+	// PriorityClassLabel picks its WorkloadPriorityClass
+	QueueNameLabel     = "kueue.x-k8s.io/queue-name"
+	PriorityClassLabel = "kueue.x-k8s.io/priority-class"
+
+	// JobUIDLabel is set on the Workloads that Kueue creates for jobs, to point back at the job
+	JobUIDLabel = "kueue.x-k8s.io/job-uid"
+
+	// AdmissionGate keeps a pod from being scheduled until Kueue admits its Workload
+	AdmissionGate = "kueue.x-k8s.io/admission"
+
+	workloadKind = "Workload"
+)
+
+// IsWorkload returns true if the GVK is a Kueue Workload (of any version)
+func IsWorkload(gvk schema.GroupVersionKind) bool {
+	return gvk.Group == Group && gvk.Kind == workloadKind
+}
+
+// QueueDetails returns the queue name and priority class from the labels (if they're set), e.g.,
+// to add to log fields or audit events; it returns nil if the object isn't managed by Kueue
+func QueueDetails(labels map[string]string) map[string]string {
+	queue, ok := labels[QueueNameLabel]
+	if !ok {
+		return nil
+	}
+
+	details := map[string]string{"queue": queue}
+	if priorityClass, ok := labels[PriorityClassLabel]; ok {
+		details["priorityClass"] = priorityClass
+	}
+	return details
+}
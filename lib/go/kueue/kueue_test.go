@@ -0,0 +1,49 @@
+package kueue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestIsWorkload(t *testing.T) {
+	cases := map[string]struct {
+		gvk      schema.GroupVersionKind
+		expected bool
+	}{
+		"v1beta1":     {gvk: schema.GroupVersionKind{Group: Group, Version: "v1beta1", Kind: "Workload"}, expected: true},
+		"local queue": {gvk: schema.GroupVersionKind{Group: Group, Version: "v1beta1", Kind: "LocalQueue"}},
+		"other group": {gvk: schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Workload"}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsWorkload(tc.gvk))
+		})
+	}
+}
+
+func TestQueueDetails(t *testing.T) {
+	cases := map[string]struct {
+		labels   map[string]string
+		expected map[string]string
+	}{
+		"not queued": {labels: map[string]string{"app": "batch"}},
+		"queued": {
+			labels:   map[string]string{QueueNameLabel: "team-a"},
+			expected: map[string]string{"queue": "team-a"},
+		},
+		"queued with priority": {
+			labels:   map[string]string{QueueNameLabel: "team-a", PriorityClassLabel: "high"},
+			expected: map[string]string{"queue": "team-a", "priorityClass": "high"},
+		},
+		"priority without queue": {labels: map[string]string{PriorityClassLabel: "high"}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, QueueDetails(tc.labels))
+		})
+	}
+}
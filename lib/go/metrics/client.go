@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+
+	clientmetrics "k8s.io/client-go/tools/metrics"
+)
+
+type requestErrors struct {
+	counter *Counter
+}
+
+// client-go reports the status code of every request, or "<error>" if there was no response
+func (self requestErrors) Increment(_ context.Context, code string, method string, _ string) {
+	if status, err := strconv.Atoi(code); err == nil && status < 400 {
+		return
+	}
+	self.counter.Inc(method, code)
+}
+
+// CountClientErrors counts the failed requests made by every Kubernetes client in the process,
+// by HTTP method and status code; the counter must have exactly those two labels.  client-go
+// only accepts the first set of client metrics that's registered, so this must only be called
+// once.
+func CountClientErrors(counter *Counter) {
+	clientmetrics.Register(clientmetrics.RegisterOpts{RequestResult: requestErrors{counter: counter}})
+}
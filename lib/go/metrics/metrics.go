@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Default is the registry that the simkube components register their metrics with
+//
+//nolint:gochecknoglobals
+var Default = NewRegistry()
+
+type sample struct {
+	labels string
+	value  float64
+}
+
+type metric interface {
+	kind() string
+	samples() []sample
+}
+
+type entry struct {
+	help string
+	m    metric
+}
+
+// A Registry collects metrics and writes them out in the Prometheus text exposition format.  We
+// only export a handful of counters and gauges, so this avoids pulling in the Prometheus client
+// library (and its dependencies) for them.
+type Registry struct {
+	mutex   sync.Mutex
+	metrics map[string]entry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]entry{}}
+}
+
+func (self *Registry) register(name, help string, m metric) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.metrics[name] = entry{help: help, m: m}
+}
+
+// NewCounter registers a counter with the given label names; the label values are passed in
+// (in the same order) when the counter is incremented.  Registering a metric with the same name
+// as an existing one replaces it.
+func (self *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{labelNames: labelNames, values: map[string]float64{}}
+	self.register(name, help, c)
+	return c
+}
+
+// GaugeFunc registers a gauge whose value is computed by fn every time the metrics are scraped
+func (self *Registry) GaugeFunc(name, help string, fn func() float64) {
+	self.register(name, help, gaugeFunc(func() map[string]float64 { return map[string]float64{"": fn()} }))
+}
+
+// GaugeVecFunc registers a gauge with one label, whose values (by label value) are computed by fn
+// every time the metrics are scraped
+func (self *Registry) GaugeVecFunc(name, help, labelName string, fn func() map[string]float64) {
+	self.register(name, help, gaugeFunc(func() map[string]float64 {
+		values := map[string]float64{}
+		for labelValue, v := range fn() {
+			values[formatLabels([]string{labelName}, []string{labelValue})] = v
+		}
+		return values
+	}))
+}
+
+func (self *Registry) Unregister(name string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	delete(self.metrics, name)
+}
+
+// Write writes out all of the metrics, sorted by name
+func (self *Registry) Write(w io.Writer) error {
+	self.mutex.Lock()
+	names := make([]string, 0, len(self.metrics))
+	entries := make(map[string]entry, len(self.metrics))
+	for name, e := range self.metrics {
+		names = append(names, name)
+		entries[name] = e
+	}
+	self.mutex.Unlock()
+	sort.Strings(names)
+
+	// The gauge functions are called outside the lock, since they might take locks of their own
+	var b strings.Builder
+	for _, name := range names {
+		e := entries[name]
+		fmt.Fprintf(&b, "# HELP %s %s\n", name, helpEscaper.Replace(e.help))
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, e.m.kind())
+		for _, s := range e.m.samples() {
+			fmt.Fprintf(&b, "%s%s %s\n", name, s.labels, strconv.FormatFloat(s.value, 'g', -1, 64))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err //nolint:wrapcheck // the caller knows what it's writing to
+}
+
+func (self *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		if err := self.Write(w); err != nil {
+			log.WithError(err).Warn("could not write metrics")
+		}
+	})
+}
+
+// A Counter is a set of monotonically increasing values, one for each combination of labels
+type Counter struct {
+	mutex      sync.Mutex
+	labelNames []string
+	values     map[string]float64
+}
+
+func (self *Counter) Inc(labelValues ...string) {
+	self.Add(1, labelValues...)
+}
+
+func (self *Counter) Add(v float64, labelValues ...string) {
+	labels := formatLabels(self.labelNames, labelValues)
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.values[labels] += v
+}
+
+func (self *Counter) Value(labelValues ...string) float64 {
+	labels := formatLabels(self.labelNames, labelValues)
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.values[labels]
+}
+
+func (*Counter) kind() string {
+	return "counter"
+}
+
+func (self *Counter) samples() []sample {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return sortedSamples(self.values)
+}
+
+type gaugeFunc func() map[string]float64
+
+func (gaugeFunc) kind() string {
+	return "gauge"
+}
+
+func (self gaugeFunc) samples() []sample {
+	return sortedSamples(self())
+}
+
+func sortedSamples(values map[string]float64) []sample {
+	samples := make([]sample, 0, len(values))
+	for labels, v := range values {
+		samples = append(samples, sample{labels: labels, value: v})
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].labels < samples[j].labels })
+	return samples
+}
+
+// formatLabels renders the labels as {name="value",...}; missing values are empty, and extra
+// values are ignored
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		var value string
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, labelEscaper.Replace(value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+//nolint:gochecknoglobals
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	reg := NewRegistry()
+	counter := reg.NewCounter("test_requests_total", "Number of requests", "method", "code")
+	counter.Inc("GET", "200")
+	counter.Add(2, "POST", "500")
+	reg.GaugeFunc("test_up", "Whether the thing\nis up", func() float64 { return 1 })
+	reg.GaugeVecFunc("test_pods", "Number of pods", "phase", func() map[string]float64 {
+		return map[string]float64{"Running": 3, `we"ird`: 0.5}
+	})
+
+	var b strings.Builder
+	assert.Nil(t, reg.Write(&b))
+	assert.Equal(t, `# HELP test_pods Number of pods
+# TYPE test_pods gauge
+test_pods{phase="Running"} 3
+test_pods{phase="we\"ird"} 0.5
+# HELP test_requests_total Number of requests
+# TYPE test_requests_total counter
+test_requests_total{method="GET",code="200"} 1
+test_requests_total{method="POST",code="500"} 2
+# HELP test_up Whether the thing\nis up
+# TYPE test_up gauge
+test_up 1
+`, b.String())
+
+	reg.Unregister("test_pods")
+	reg.Unregister("test_up")
+	b.Reset()
+	assert.Nil(t, reg.Write(&b))
+	assert.NotContains(t, b.String(), "test_pods")
+	assert.Equal(t, float64(2), counter.Value("POST", "500"))
+}
+
+func TestRegistryHandler(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounter("test_total", "A counter").Inc()
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, contentType, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "test_total 1\n")
+
+	rec = httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestRequestErrors(t *testing.T) {
+	counter := NewRegistry().NewCounter("test_errors_total", "Errors", "method", "code")
+	errs := requestErrors{counter: counter}
+	errs.Increment(context.TODO(), "200", "GET", "localhost")
+	errs.Increment(context.TODO(), "404", "GET", "localhost")
+	errs.Increment(context.TODO(), "<error>", "PUT", "localhost")
+
+	assert.Equal(t, float64(0), counter.Value("GET", "200"))
+	assert.Equal(t, float64(1), counter.Value("GET", "404"))
+	assert.Equal(t, float64(1), counter.Value("PUT", "<error>"))
+}
@@ -0,0 +1,372 @@
+package metricsapi
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	"simkube/lib/go/k8s"
+)
+
+const (
+	readHeaderTimeout = 5 * time.Second
+	shutdownTimeout   = 5 * time.Second
+	resyncPeriod      = 30 * time.Second
+
+	// The usage is synthesized on every request, but the HPA expects the metrics to cover some
+	// window of time; this is how often metrics-server scrapes by default
+	metricsWindow = 15 * time.Second
+
+	virtualNodeSelector = "type=virtual"
+)
+
+// The Server serves the resource metrics API for the virtual nodes and their pods, so that it
+// can be registered as an aggregated API (in place of metrics-server) and the HPA and kubectl top
+// work in simulations
+type Server struct {
+	k8sClient kubernetes.Interface
+	model     UsageModel
+	clock     clockwork.Clock
+	logger    *log.Entry
+
+	nodeLister corev1listers.NodeLister
+	podLister  corev1listers.PodLister
+}
+
+func NewServer(model UsageModel, clientOpts k8s.ClientOptions) (*Server, error) {
+	k8sClient, err := k8s.NewClient(clientOpts)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize Kubernetes client: %w", err)
+	}
+	return newServer(model, k8sClient), nil
+}
+
+func newServer(model UsageModel, k8sClient kubernetes.Interface) *Server {
+	return &Server{
+		k8sClient: k8sClient,
+		model:     model,
+		clock:     clockwork.NewRealClock(),
+		logger:    log.WithFields(log.Fields{"component": "metrics-api"}),
+	}
+}
+
+// Start watches the virtual nodes and the pods that are running on any node, and waits for the
+// caches to sync
+func (self *Server) Start(ctx context.Context) error {
+	nodeFactory := informers.NewSharedInformerFactoryWithOptions(
+		self.k8sClient,
+		resyncPeriod,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) { opts.LabelSelector = virtualNodeSelector }),
+	)
+	podFactory := informers.NewSharedInformerFactoryWithOptions(
+		self.k8sClient,
+		resyncPeriod,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = "spec.nodeName!=,status.phase=Running"
+		}),
+	)
+	self.nodeLister = nodeFactory.Core().V1().Nodes().Lister()
+	self.podLister = podFactory.Core().V1().Pods().Lister()
+
+	nodeFactory.Start(ctx.Done())
+	podFactory.Start(ctx.Done())
+	for _, factory := range []informers.SharedInformerFactory{nodeFactory, podFactory} {
+		for informerType, synced := range factory.WaitForCacheSync(ctx.Done()) {
+			if !synced {
+				return fmt.Errorf("could not sync %v informer", informerType)
+			}
+		}
+	}
+	return nil
+}
+
+// Run serves the API on addr in the background, until ctx is cancelled; the aggregated API
+// server only talks to it over TLS
+func (self *Server) Run(ctx context.Context, addr string, tlsConfig *tls.Config) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           self.Handler(),
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			self.logger.WithError(err).Warn("could not shut down metrics API server")
+		}
+	}()
+
+	go func() {
+		self.logger.Infof("serving the resource metrics API on %s", addr)
+		if err := srv.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			self.logger.WithError(err).Error("metrics API server failed")
+		}
+	}()
+}
+
+func (self *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/apis/"+GroupVersion.Group, self.handleGroup)
+	mux.HandleFunc("/apis/"+GroupVersion.String(), self.handleResources)
+	mux.HandleFunc("/apis/"+GroupVersion.String()+"/", self.handleMetrics)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) { fmt.Fprintln(w, "ok") })
+	return mux
+}
+
+func (self *Server) handleGroup(w http.ResponseWriter, _ *http.Request) {
+	version := metav1.GroupVersionForDiscovery{GroupVersion: GroupVersion.String(), Version: GroupVersion.Version}
+	writeJSON(w, http.StatusOK, &metav1.APIGroup{
+		TypeMeta:         metav1.TypeMeta{Kind: "APIGroup", APIVersion: "v1"},
+		Name:             GroupVersion.Group,
+		Versions:         []metav1.GroupVersionForDiscovery{version},
+		PreferredVersion: version,
+	})
+}
+
+func (self *Server) handleResources(w http.ResponseWriter, _ *http.Request) {
+	verbs := metav1.Verbs{"get", "list"}
+	writeJSON(w, http.StatusOK, &metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+		GroupVersion: GroupVersion.String(),
+		APIResources: []metav1.APIResource{
+			{Name: "nodes", Kind: nodeMetricsKind, Namespaced: false, Verbs: verbs},
+			{Name: "pods", Kind: podMetricsKind, Namespaced: true, Verbs: verbs},
+		},
+	})
+}
+
+// handleMetrics serves nodes, nodes/<name>, pods, namespaces/<ns>/pods, and
+// namespaces/<ns>/pods/<name>; lists can be filtered with a labelSelector
+func (self *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeStatus(w, apierrors.NewMethodNotSupported(GroupVersion.WithResource("").GroupResource(), r.Method))
+		return
+	}
+
+	selector, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		writeStatus(w, apierrors.NewBadRequest(fmt.Sprintf("invalid label selector: %v", err)))
+		return
+	}
+
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/apis/"+GroupVersion.String()+"/"), "/")
+	switch {
+	case len(path) == 1 && path[0] == "nodes":
+		self.listNodeMetrics(w, selector)
+	case len(path) == 2 && path[0] == "nodes":
+		self.getNodeMetrics(w, path[1])
+	case len(path) == 1 && path[0] == "pods":
+		self.listPodMetrics(w, corev1.NamespaceAll, selector)
+	case len(path) == 3 && path[0] == "namespaces" && path[2] == "pods":
+		self.listPodMetrics(w, path[1], selector)
+	case len(path) == 4 && path[0] == "namespaces" && path[2] == "pods":
+		self.getPodMetrics(w, path[1], path[3])
+	default:
+		writeStatus(w, apierrors.NewNotFound(GroupVersion.WithResource("").GroupResource(), r.URL.Path))
+	}
+}
+
+func (self *Server) listNodeMetrics(w http.ResponseWriter, selector labels.Selector) {
+	nodes, err := self.nodeLister.List(selector)
+	if err != nil {
+		writeStatus(w, apierrors.NewInternalError(err))
+		return
+	}
+
+	usage, err := self.usage()
+	if err != nil {
+		writeStatus(w, apierrors.NewInternalError(err))
+		return
+	}
+
+	list := NodeMetricsList{
+		TypeMeta: metav1.TypeMeta{Kind: nodeMetricsListKind, APIVersion: GroupVersion.String()},
+		Items:    make([]NodeMetrics, 0, len(nodes)),
+	}
+	for _, node := range nodes {
+		list.Items = append(list.Items, self.nodeMetrics(node, usage))
+	}
+	writeJSON(w, http.StatusOK, &list)
+}
+
+func (self *Server) getNodeMetrics(w http.ResponseWriter, name string) {
+	node, err := self.nodeLister.Get(name)
+	if err != nil {
+		writeStatus(w, apierrors.NewNotFound(GroupVersion.WithResource("nodes").GroupResource(), name))
+		return
+	}
+
+	usage, err := self.usage()
+	if err != nil {
+		writeStatus(w, apierrors.NewInternalError(err))
+		return
+	}
+	metrics := self.nodeMetrics(node, usage)
+	writeJSON(w, http.StatusOK, &metrics)
+}
+
+func (self *Server) listPodMetrics(w http.ResponseWriter, namespace string, selector labels.Selector) {
+	pods, err := self.podLister.Pods(namespace).List(selector)
+	if err != nil {
+		writeStatus(w, apierrors.NewInternalError(err))
+		return
+	}
+
+	usage, err := self.usage()
+	if err != nil {
+		writeStatus(w, apierrors.NewInternalError(err))
+		return
+	}
+
+	list := PodMetricsList{
+		TypeMeta: metav1.TypeMeta{Kind: podMetricsListKind, APIVersion: GroupVersion.String()},
+		Items:    make([]PodMetrics, 0, len(pods)),
+	}
+	for _, pod := range pods {
+		if containers, ok := usage.pods[k8s.NamespacedNameFromObjectMeta(pod.ObjectMeta)]; ok {
+			list.Items = append(list.Items, self.podMetrics(pod, containers))
+		}
+	}
+	writeJSON(w, http.StatusOK, &list)
+}
+
+func (self *Server) getPodMetrics(w http.ResponseWriter, namespace, name string) {
+	usage, err := self.usage()
+	if err != nil {
+		writeStatus(w, apierrors.NewInternalError(err))
+		return
+	}
+
+	pod, err := self.podLister.Pods(namespace).Get(name)
+	containers, ok := usage.pods[k8s.NamespacedName(namespace, name)]
+	if err != nil || !ok {
+		writeStatus(w, apierrors.NewNotFound(GroupVersion.WithResource("pods").GroupResource(), name))
+		return
+	}
+	metrics := self.podMetrics(pod, containers)
+	writeJSON(w, http.StatusOK, &metrics)
+}
+
+type clusterUsage struct {
+	pods  map[string][]ContainerMetrics
+	nodes map[string]corev1.ResourceList
+}
+
+// usage computes the usage of all of the pods on virtual nodes (pods on other nodes have real
+// usage, which is metrics-server's business), scales it by the node's utilization factor, and
+// adds it up for each node
+func (self *Server) usage() (*clusterUsage, error) {
+	nodes, err := self.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("could not list virtual nodes: %w", err)
+	}
+	factors := make(map[string]float64, len(nodes))
+	for _, node := range nodes {
+		factors[node.Name] = utilizationFactor(node)
+	}
+
+	allPods, err := self.podLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("could not list pods: %w", err)
+	}
+	pods := make([]*corev1.Pod, 0, len(allPods))
+	for _, pod := range allPods {
+		if _, ok := factors[pod.Spec.NodeName]; ok {
+			pods = append(pods, pod)
+		}
+	}
+
+	usage := &clusterUsage{pods: self.model.podUsage(pods), nodes: map[string]corev1.ResourceList{}}
+	for _, pod := range pods {
+		podName := k8s.NamespacedNameFromObjectMeta(pod.ObjectMeta)
+		if containers, ok := usage.pods[podName]; ok {
+			usage.pods[podName] = scaleUsage(containers, factors[pod.Spec.NodeName])
+		}
+
+		nodeUsage, ok := usage.nodes[pod.Spec.NodeName]
+		if !ok {
+			nodeUsage = corev1.ResourceList{}
+			usage.nodes[pod.Spec.NodeName] = nodeUsage
+		}
+		for _, c := range usage.pods[podName] {
+			for name, quantity := range c.Usage {
+				total := nodeUsage[name]
+				total.Add(quantity)
+				nodeUsage[name] = total
+			}
+		}
+	}
+	return usage, nil
+}
+
+func (self *Server) nodeMetrics(node *corev1.Node, usage *clusterUsage) NodeMetrics {
+	nodeUsage, ok := usage.nodes[node.Name]
+	if !ok {
+		nodeUsage = corev1.ResourceList{
+			corev1.ResourceCPU:    *resource.NewMilliQuantity(0, resource.DecimalSI),
+			corev1.ResourceMemory: *resource.NewQuantity(0, resource.BinarySI),
+		}
+	}
+
+	now := metav1.Time{Time: self.clock.Now()}
+	return NodeMetrics{
+		TypeMeta: metav1.TypeMeta{Kind: nodeMetricsKind, APIVersion: GroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              node.Name,
+			Labels:            node.Labels,
+			CreationTimestamp: now,
+		},
+		Timestamp: now,
+		Window:    metav1.Duration{Duration: metricsWindow},
+		Usage:     nodeUsage,
+	}
+}
+
+func (self *Server) podMetrics(pod *corev1.Pod, containers []ContainerMetrics) PodMetrics {
+	now := metav1.Time{Time: self.clock.Now()}
+	return PodMetrics{
+		TypeMeta: metav1.TypeMeta{Kind: podMetricsKind, APIVersion: GroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         pod.Namespace,
+			Name:              pod.Name,
+			Labels:            pod.Labels,
+			CreationTimestamp: now,
+		},
+		Timestamp:  now,
+		Window:     metav1.Duration{Duration: metricsWindow},
+		Containers: containers,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(obj); err != nil {
+		log.WithError(err).Warn("could not write metrics API response")
+	}
+}
+
+func writeStatus(w http.ResponseWriter, err *apierrors.StatusError) {
+	status := err.Status()
+	status.TypeMeta = metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}
+	writeJSON(w, int(status.Code), &status)
+}
@@ -0,0 +1,146 @@
+package metricsapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testNodeObj(name string, nodeType string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"type": nodeType}}}
+}
+
+func startTestServer(t *testing.T) *httptest.Server {
+	realPod := testPod("real", "", "", corev1.PodRunning, "1")
+	realPod.Spec.NodeName = "real-node"
+	hotNode := testNodeObj("sk-vnode-2", "virtual")
+	hotNode.ObjectMeta.Labels[UtilizationFactorLabel] = "2"
+	hotPod := testPod("batch-1", "", "", corev1.PodRunning, "1")
+	hotPod.ObjectMeta.Labels = map[string]string{"app": "batch"}
+	hotPod.Spec.NodeName = hotNode.Name
+	client := fake.NewSimpleClientset(
+		testNodeObj(testNode, "virtual"),
+		hotNode,
+		testNodeObj("real-node", "real"),
+		testPod("web-1", "abcd", "3", corev1.PodRunning, "1"),
+		testPod("web-2", "abcd", "3", corev1.PodRunning, "1"),
+		hotPod,
+		realPod,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	srv := newServer(UsageModel{CPUUtilization: 0.5, MemoryUtilization: 0.5}, client)
+	srv.clock = clockwork.NewFakeClockAt(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	require.Nil(t, srv.Start(ctx))
+
+	httpSrv := httptest.NewServer(srv.Handler())
+	t.Cleanup(httpSrv.Close)
+	return httpSrv
+}
+
+func get(t *testing.T, srv *httptest.Server, path string, into interface{}) int {
+	resp, err := http.Get(srv.URL + path)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(into))
+	return resp.StatusCode
+}
+
+func TestServerDiscovery(t *testing.T) {
+	srv := startTestServer(t)
+
+	var resources metav1.APIResourceList
+	assert.Equal(t, http.StatusOK, get(t, srv, "/apis/metrics.k8s.io/v1beta1", &resources))
+	assert.Equal(t, "metrics.k8s.io/v1beta1", resources.GroupVersion)
+	assert.Len(t, resources.APIResources, 2)
+
+	var group metav1.APIGroup
+	assert.Equal(t, http.StatusOK, get(t, srv, "/apis/metrics.k8s.io", &group))
+	assert.Equal(t, "metrics.k8s.io/v1beta1", group.PreferredVersion.GroupVersion)
+}
+
+func TestServerNodeMetrics(t *testing.T) {
+	srv := startTestServer(t)
+
+	var list NodeMetricsList
+	assert.Equal(t, http.StatusOK, get(t, srv, "/apis/metrics.k8s.io/v1beta1/nodes", &list))
+	assert.Equal(t, nodeMetricsListKind, list.Kind)
+	usage := map[string]int64{}
+	for _, item := range list.Items {
+		usage[item.Name] = item.Usage.Cpu().MilliValue()
+	}
+	assert.Equal(t, map[string]int64{testNode: 3000, "sk-vnode-2": 1000}, usage)
+
+	var node NodeMetrics
+	assert.Equal(t, http.StatusOK, get(t, srv, "/apis/metrics.k8s.io/v1beta1/nodes/"+testNode, &node))
+	assert.Equal(t, int64(1024*1024*1024), node.Usage.Memory().Value())
+	assert.Equal(t, 15*time.Second, node.Window.Duration)
+
+	var status metav1.Status
+	assert.Equal(t, http.StatusNotFound, get(t, srv, "/apis/metrics.k8s.io/v1beta1/nodes/real-node", &status))
+}
+
+func TestServerPodMetrics(t *testing.T) {
+	cases := map[string]struct {
+		path          string
+		expectedCode  int
+		expectedNames []string
+	}{
+		"all namespaces": {
+			path:          "/apis/metrics.k8s.io/v1beta1/pods",
+			expectedCode:  http.StatusOK,
+			expectedNames: []string{"web-1", "web-2", "batch-1"},
+		},
+		"label selector": {
+			path:          "/apis/metrics.k8s.io/v1beta1/namespaces/default/pods?labelSelector=app%3Dweb",
+			expectedCode:  http.StatusOK,
+			expectedNames: []string{"web-1", "web-2"},
+		},
+		"no matches": {
+			path:          "/apis/metrics.k8s.io/v1beta1/namespaces/default/pods?labelSelector=app%3Ddb",
+			expectedCode:  http.StatusOK,
+			expectedNames: []string{},
+		},
+		"invalid selector": {
+			path:         "/apis/metrics.k8s.io/v1beta1/namespaces/default/pods?labelSelector=%3D%3D",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	// batch-1 is on a node with a utilization factor of 2
+	expectedCPU := map[string]int64{"web-1": 1500, "web-2": 1500, "batch-1": 1000}
+
+	srv := startTestServer(t)
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var list PodMetricsList
+			assert.Equal(t, tc.expectedCode, get(t, srv, tc.path, &list))
+			if tc.expectedNames != nil {
+				names := []string{}
+				for _, item := range list.Items {
+					names = append(names, item.Name)
+					assert.Equal(t, expectedCPU[item.Name], item.Containers[0].Usage.Cpu().MilliValue())
+				}
+				assert.ElementsMatch(t, tc.expectedNames, names)
+			}
+		})
+	}
+
+	var pod PodMetrics
+	assert.Equal(t, http.StatusOK, get(t, srv, "/apis/metrics.k8s.io/v1beta1/namespaces/default/pods/web-1", &pod))
+	assert.Equal(t, podMetricsKind, pod.Kind)
+
+	var status metav1.Status
+	assert.Equal(t, http.StatusNotFound, get(t, srv, "/apis/metrics.k8s.io/v1beta1/namespaces/default/pods/real", &status))
+}
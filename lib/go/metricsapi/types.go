@@ -0,0 +1,56 @@
+package metricsapi
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// These are the parts of the resource metrics API (metrics.k8s.io/v1beta1) that the HPA and
+// kubectl top use; we don't import k8s.io/metrics for a handful of types
+const (
+	nodeMetricsKind     = "NodeMetrics"
+	nodeMetricsListKind = "NodeMetricsList"
+	podMetricsKind      = "PodMetrics"
+	podMetricsListKind  = "PodMetricsList"
+)
+
+//nolint:gochecknoglobals
+var GroupVersion = schema.GroupVersion{Group: "metrics.k8s.io", Version: "v1beta1"}
+
+type NodeMetrics struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Timestamp metav1.Time         `json:"timestamp"`
+	Window    metav1.Duration     `json:"window"`
+	Usage     corev1.ResourceList `json:"usage"`
+}
+
+type NodeMetricsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []NodeMetrics `json:"items"`
+}
+
+type PodMetrics struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Timestamp  metav1.Time        `json:"timestamp"`
+	Window     metav1.Duration    `json:"window"`
+	Containers []ContainerMetrics `json:"containers"`
+}
+
+type PodMetricsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []PodMetrics `json:"items"`
+}
+
+type ContainerMetrics struct {
+	Name  string              `json:"name"`
+	Usage corev1.ResourceList `json:"usage"`
+}
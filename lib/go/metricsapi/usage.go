@@ -0,0 +1,161 @@
+package metricsapi
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"simkube/lib/go/k8s"
+)
+
+// CPULoadAnnotation sets the total CPU usage (e.g., "4" or "2500m") of all of the pods with the
+// same owner; the load is shared evenly between the owner's running pods, so that scaling up
+// brings the utilization of each pod down, the way it would with real traffic
+const CPULoadAnnotation = "simkube.io/cpu-load"
+
+// UtilizationFactorLabel scales the usage of every pod on a node (e.g., "1.5" makes them all use
+// 50% more than they otherwise would); it's a label, rather than an annotation, so that it can be
+// set in the node skeleton or changed on a schedule, and used to make some nodes run hotter than
+// others (which is the kind of imbalance that a descheduler acts on)
+const UtilizationFactorLabel = "simkube.io/utilization-factor"
+
+// The UsageModel synthesizes resource usage for the simulated pods, which don't use anything:
+// containers use a fixed fraction of what they request, unless the pod has a CPU load annotation.
+// Nodes use whatever their pods use.
+type UsageModel struct {
+	CPUUtilization    float64
+	MemoryUtilization float64
+}
+
+// podUsage returns the usage of each container of every running pod, by the pod's namespaced
+// name; all of the pods are needed to share out the CPU loads, even if only some of them are
+// being asked about
+func (self UsageModel) podUsage(pods []*corev1.Pod) map[string][]ContainerMetrics {
+	running := make([]*corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodRunning {
+			running = append(running, pod)
+		}
+	}
+
+	loads := cpuLoadShares(running)
+	usage := make(map[string][]ContainerMetrics, len(running))
+	for _, pod := range running {
+		podName := k8s.NamespacedNameFromObjectMeta(pod.ObjectMeta)
+		load, ok := loads[podName]
+		usage[podName] = self.containerUsage(pod, load, ok)
+	}
+	return usage
+}
+
+// containerUsage splits the pod's CPU load (in millicores) between its containers in proportion
+// to their CPU requests (or evenly, if they don't have any)
+func (self UsageModel) containerUsage(pod *corev1.Pod, cpuLoad int64, hasLoad bool) []ContainerMetrics {
+	var totalCPURequest int64
+	for _, c := range pod.Spec.Containers {
+		totalCPURequest += c.Resources.Requests.Cpu().MilliValue()
+	}
+
+	containers := make([]ContainerMetrics, 0, len(pod.Spec.Containers))
+	for _, c := range pod.Spec.Containers {
+		cpuRequest := c.Resources.Requests.Cpu().MilliValue()
+		cpu := int64(float64(cpuRequest) * self.CPUUtilization)
+		if hasLoad && totalCPURequest > 0 {
+			cpu = cpuLoad * cpuRequest / totalCPURequest
+		} else if hasLoad {
+			cpu = cpuLoad / int64(len(pod.Spec.Containers))
+		}
+		memory := int64(float64(c.Resources.Requests.Memory().Value()) * self.MemoryUtilization)
+
+		containers = append(containers, ContainerMetrics{
+			Name: c.Name,
+			Usage: corev1.ResourceList{
+				corev1.ResourceCPU:    *resource.NewMilliQuantity(cpu, resource.DecimalSI),
+				corev1.ResourceMemory: *resource.NewQuantity(memory, resource.BinarySI),
+			},
+		})
+	}
+	return containers
+}
+
+// cpuLoadShares returns each pod's share of its owner's CPU load (in millicores), for the pods
+// that have a (valid) CPU load annotation; pods without an owner have the whole load to
+// themselves
+func cpuLoadShares(pods []*corev1.Pod) map[string]int64 {
+	type owner struct {
+		load int64
+		pods []string
+	}
+
+	owners := map[string]*owner{}
+	for _, pod := range pods {
+		loadStr, ok := pod.ObjectMeta.Annotations[CPULoadAnnotation]
+		if !ok {
+			continue
+		}
+		load, err := resource.ParseQuantity(loadStr)
+		if err != nil {
+			continue
+		}
+
+		podName := k8s.NamespacedNameFromObjectMeta(pod.ObjectMeta)
+		ownerKey := podName
+		if ref := metav1.GetControllerOf(pod); ref != nil {
+			ownerKey = k8s.NamespacedName(pod.Namespace, string(ref.UID))
+		}
+		if _, ok := owners[ownerKey]; !ok {
+			owners[ownerKey] = &owner{load: load.MilliValue()}
+		}
+		owners[ownerKey].pods = append(owners[ownerKey].pods, podName)
+	}
+
+	shares := map[string]int64{}
+	for _, o := range owners {
+		for _, podName := range o.pods {
+			shares[podName] = o.load / int64(len(o.pods))
+		}
+	}
+	return shares
+}
+
+// utilizationFactor returns the node's utilization factor, or 1 if the node doesn't have a valid
+// (i.e., non-negative) one
+func utilizationFactor(node *corev1.Node) float64 {
+	factorStr, ok := node.ObjectMeta.Labels[UtilizationFactorLabel]
+	if !ok {
+		return 1
+	}
+	factor, err := strconv.ParseFloat(factorStr, 64)
+	if err != nil || factor < 0 {
+		return 1
+	}
+	return factor
+}
+
+func scaleUsage(containers []ContainerMetrics, factor float64) []ContainerMetrics {
+	if factor == 1 {
+		return containers
+	}
+
+	scaled := make([]ContainerMetrics, 0, len(containers))
+	for _, c := range containers {
+		cpu := c.Usage[corev1.ResourceCPU]
+		memory := c.Usage[corev1.ResourceMemory]
+		scaled = append(scaled, ContainerMetrics{
+			Name: c.Name,
+			Usage: corev1.ResourceList{
+				corev1.ResourceCPU: *resource.NewMilliQuantity(
+					int64(float64(cpu.MilliValue())*factor),
+					resource.DecimalSI,
+				),
+				corev1.ResourceMemory: *resource.NewQuantity(
+					int64(float64(memory.Value())*factor),
+					resource.BinarySI,
+				),
+			},
+		})
+	}
+	return scaled
+}
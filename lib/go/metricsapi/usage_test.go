@@ -0,0 +1,133 @@
+package metricsapi
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	testNamespace = "default"
+	testNode      = "sk-vnode-1"
+)
+
+func testPod(name, ownerUID, cpuLoad string, phase corev1.PodPhase, cpuRequests ...string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name, Labels: map[string]string{"app": "web"}},
+		Spec:       corev1.PodSpec{NodeName: testNode},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+	if ownerUID != "" {
+		pod.ObjectMeta.OwnerReferences = []metav1.OwnerReference{
+			{Kind: "ReplicaSet", Name: "web", UID: types.UID(ownerUID), Controller: lo.ToPtr(true)},
+		}
+	}
+	if cpuLoad != "" {
+		pod.ObjectMeta.Annotations = map[string]string{CPULoadAnnotation: cpuLoad}
+	}
+	for i, cpu := range cpuRequests {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{
+			Name: []string{"app", "sidecar"}[i],
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse("1Gi"),
+			}},
+		})
+	}
+	return pod
+}
+
+func cpuUsage(containers []ContainerMetrics) []int64 {
+	usage := make([]int64, 0, len(containers))
+	for _, c := range containers {
+		usage = append(usage, c.Usage.Cpu().MilliValue())
+	}
+	return usage
+}
+
+func TestPodUsage(t *testing.T) {
+	model := UsageModel{CPUUtilization: 0.5, MemoryUtilization: 0.25}
+	cases := map[string]struct {
+		pods     []*corev1.Pod
+		expected map[string][]int64
+	}{
+		"utilization": {
+			pods:     []*corev1.Pod{testPod("web-1", "", "", corev1.PodRunning, "1", "200m")},
+			expected: map[string][]int64{"default/web-1": {500, 100}},
+		},
+		"shared load": {
+			pods: []*corev1.Pod{
+				testPod("web-1", "abcd", "3", corev1.PodRunning, "1"),
+				testPod("web-2", "abcd", "3", corev1.PodRunning, "1"),
+				testPod("web-3", "abcd", "3", corev1.PodPending, "1"),
+			},
+			expected: map[string][]int64{"default/web-1": {1500}, "default/web-2": {1500}},
+		},
+		"load split by request": {
+			pods:     []*corev1.Pod{testPod("web-1", "", "2", corev1.PodRunning, "300m", "100m")},
+			expected: map[string][]int64{"default/web-1": {1500, 500}},
+		},
+		"invalid load": {
+			pods:     []*corev1.Pod{testPod("web-1", "abcd", "lots", corev1.PodRunning, "1")},
+			expected: map[string][]int64{"default/web-1": {500}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			usage := model.podUsage(tc.pods)
+			assert.Len(t, usage, len(tc.expected))
+			for podName, expected := range tc.expected {
+				assert.Equal(t, expected, cpuUsage(usage[podName]))
+				for _, c := range usage[podName] {
+					assert.Equal(t, int64(256*1024*1024), c.Usage.Memory().Value())
+				}
+			}
+		})
+	}
+}
+
+func TestUtilizationFactor(t *testing.T) {
+	cases := map[string]struct {
+		labels   map[string]string
+		expected float64
+	}{
+		"no label": {
+			expected: 1,
+		},
+		"factor": {
+			labels:   map[string]string{UtilizationFactorLabel: "1.5"},
+			expected: 1.5,
+		},
+		"invalid": {
+			labels:   map[string]string{UtilizationFactorLabel: "asdf"},
+			expected: 1,
+		},
+		"negative": {
+			labels:   map[string]string{UtilizationFactorLabel: "-2"},
+			expected: 1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: testNode, Labels: tc.labels}}
+			assert.Equal(t, tc.expected, utilizationFactor(node))
+		})
+	}
+}
+
+func TestScaleUsage(t *testing.T) {
+	model := UsageModel{CPUUtilization: 0.5, MemoryUtilization: 0.25}
+	containers := model.containerUsage(testPod("web-1", "", "", corev1.PodRunning, "1", "200m"), 0, false)
+
+	scaled := scaleUsage(containers, 1.5)
+	assert.Equal(t, []int64{750, 150}, cpuUsage(scaled))
+	assert.Equal(t, int64(384*1024*1024), scaled[0].Usage.Memory().Value())
+	assert.Equal(t, []int64{500, 100}, cpuUsage(containers))
+}
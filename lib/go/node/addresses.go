@@ -0,0 +1,131 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultKubeletPort = 10250
+
+	// We don't need to be able to address every host in a huge (e.g., IPv6) range, so
+	// we cap the number of host bits that are used when allocating addresses
+	maxHostBits = 31
+)
+
+// ParseNodeCIDR parses the range that node InternalIPs are allocated from; an empty string
+// returns the zero prefix, which disables address allocation.
+func ParseNodeCIDR(cidr string) (netip.Prefix, error) {
+	if cidr == "" {
+		return netip.Prefix{}, nil
+	}
+
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid node CIDR %s: %w", cidr, err)
+	}
+	if prefix.Addr().BitLen()-prefix.Bits() < 2 {
+		return netip.Prefix{}, fmt.Errorf("node CIDR %s is too small", cidr)
+	}
+	return prefix.Masked(), nil
+}
+
+// Some controllers (and metrics scrapers) expect every node to have an InternalIP and a
+// kubelet endpoint; addresses from the skeleton are left alone, otherwise we pick an
+// address in the node CIDR that isn't used by any other node.
+func (self *LifecycleManager) setNodeAddresses(ctx context.Context, node *corev1.Node) {
+	if node.Status.DaemonEndpoints.KubeletEndpoint.Port == 0 {
+		port := self.opts.KubeletPort
+		if port == 0 {
+			port = defaultKubeletPort
+		}
+		node.Status.DaemonEndpoints.KubeletEndpoint.Port = port
+	}
+
+	if !hasAddressType(node, corev1.NodeHostName) {
+		node.Status.Addresses = append(node.Status.Addresses, corev1.NodeAddress{
+			Type:    corev1.NodeHostName,
+			Address: self.nodeName,
+		})
+	}
+
+	if !self.opts.NodeCIDR.IsValid() || hasAddressType(node, corev1.NodeInternalIP) {
+		return
+	}
+
+	used, err := self.listUsedAddresses(ctx)
+	if err != nil {
+		self.logger.WithError(err).Warn("could not list node addresses, the chosen InternalIP may not be unique")
+	}
+	addr := pickNodeAddress(self.opts.NodeCIDR, used, self.nodeName)
+	self.logger.Infof("using InternalIP %s", addr)
+	node.Status.Addresses = append(node.Status.Addresses, corev1.NodeAddress{
+		Type:    corev1.NodeInternalIP,
+		Address: addr.String(),
+	})
+}
+
+func (self *LifecycleManager) listUsedAddresses(ctx context.Context) (map[netip.Addr]bool, error) {
+	nodes, err := self.k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not list nodes: %w", err)
+	}
+
+	used := map[netip.Addr]bool{}
+	for _, n := range nodes.Items {
+		if n.ObjectMeta.Name == self.nodeName {
+			continue
+		}
+		for _, address := range n.Status.Addresses {
+			if addr, err := netip.ParseAddr(address.Address); err == nil {
+				used[addr] = true
+			}
+		}
+	}
+	return used, nil
+}
+
+// We start from an offset based on the node name (so that nodes starting at the same time
+// are unlikely to collide) and probe forward until we find an unused address; the network
+// and broadcast addresses are never chosen.  If the range is full, we just use the first
+// address we tried.
+func pickNodeAddress(prefix netip.Prefix, used map[netip.Addr]bool, nodeName string) netip.Addr {
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits > maxHostBits {
+		hostBits = maxHostBits
+	}
+	usable := uint64(1)<<hostBits - 2
+
+	start := uint64(hashNodeName(nodeName)) % usable
+	for i := uint64(0); i < usable && i <= uint64(len(used)); i++ {
+		addr := addToAddr(prefix.Addr(), (start+i)%usable+1)
+		if !used[addr] {
+			return addr
+		}
+	}
+	return addToAddr(prefix.Addr(), start+1)
+}
+
+func addToAddr(addr netip.Addr, n uint64) netip.Addr {
+	b := addr.AsSlice()
+	for i := len(b) - 1; i >= 0 && n > 0; i-- {
+		sum := uint64(b[i]) + n&0xff
+		b[i] = byte(sum)
+		n = n>>8 + sum>>8
+	}
+	res, _ := netip.AddrFromSlice(b)
+	return res
+}
+
+func hasAddressType(node *corev1.Node, addrType corev1.NodeAddressType) bool {
+	for _, address := range node.Status.Addresses {
+		if address.Type == addrType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package node
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"simkube/lib/go/testutils"
+)
+
+func TestParseNodeCIDR(t *testing.T) {
+	cases := map[string]struct {
+		cidr      string
+		expected  netip.Prefix
+		expectErr bool
+	}{
+		"empty":     {cidr: ""},
+		"ipv4":      {cidr: "10.0.0.0/16", expected: netip.MustParsePrefix("10.0.0.0/16")},
+		"unmasked":  {cidr: "10.0.1.2/16", expected: netip.MustParsePrefix("10.0.0.0/16")},
+		"ipv6":      {cidr: "fd00::/64", expected: netip.MustParsePrefix("fd00::/64")},
+		"too small": {cidr: "10.0.0.1/31", expectErr: true},
+		"invalid":   {cidr: "asdf", expectErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			prefix, err := ParseNodeCIDR(tc.cidr)
+			if tc.expectErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expected, prefix)
+			}
+		})
+	}
+}
+
+func TestPickNodeAddress(t *testing.T) {
+	prefix := netip.MustParsePrefix("10.0.0.0/30")
+
+	// There are only two usable addresses in a /30, so whichever one we pick first, we
+	// should get the other one if the first is in use
+	first := pickNodeAddress(prefix, nil, expectedName)
+	assert.Contains(t, []string{"10.0.0.1", "10.0.0.2"}, first.String())
+
+	second := pickNodeAddress(prefix, map[netip.Addr]bool{first: true}, expectedName)
+	assert.NotEqual(t, first, second)
+	assert.Contains(t, []string{"10.0.0.1", "10.0.0.2"}, second.String())
+
+	full := map[netip.Addr]bool{first: true, second: true}
+	assert.Equal(t, first, pickNodeAddress(prefix, full, expectedName))
+}
+
+func TestAddToAddr(t *testing.T) {
+	assert.Equal(t, "10.0.1.4", addToAddr(netip.MustParseAddr("10.0.0.255"), 5).String())
+	assert.Equal(t, "fd00::1:0", addToAddr(netip.MustParseAddr("fd00::ffff"), 1).String())
+}
+
+func TestSetNodeAddresses(t *testing.T) {
+	cases := map[string]struct {
+		nodeCIDR          string
+		skeletonAddresses []corev1.NodeAddress
+		expectedIP        string
+	}{
+		"no cidr": {},
+		"allocated": {
+			nodeCIDR:   "10.0.0.0/30",
+			expectedIP: "10.0.0.2",
+		},
+		"from skeleton": {
+			nodeCIDR:          "10.0.0.0/30",
+			skeletonAddresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "192.168.0.1"}},
+			expectedIP:        "192.168.0.1",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			k8sClient := fake.NewSimpleClientset(&corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "other-node"},
+				Status: corev1.NodeStatus{
+					Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.1"}},
+				},
+			})
+			nodeCIDR, _ := ParseNodeCIDR(tc.nodeCIDR)
+			nlm := &LifecycleManager{
+				nodeName:  expectedName,
+				k8sClient: k8sClient,
+				opts:      Options{NodeCIDR: nodeCIDR},
+				logger:    testutils.GetFakeLogger(),
+			}
+			n := &corev1.Node{Status: corev1.NodeStatus{Addresses: tc.skeletonAddresses}}
+
+			nlm.setNodeAddresses(context.TODO(), n)
+
+			assert.Equal(t, int32(defaultKubeletPort), n.Status.DaemonEndpoints.KubeletEndpoint.Port)
+			addresses := map[corev1.NodeAddressType]string{}
+			for _, address := range n.Status.Addresses {
+				addresses[address.Type] = address.Address
+			}
+			assert.Equal(t, expectedName, addresses[corev1.NodeHostName])
+			assert.Equal(t, tc.expectedIP, addresses[corev1.NodeInternalIP])
+		})
+	}
+}
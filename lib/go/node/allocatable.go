@@ -0,0 +1,111 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// AllocatableChange describes a change to the node's allocatable resources that happens
+// some time after the node starts.  Each allocatable value is either an absolute quantity
+// (e.g., "2" or "4Gi") or a percentage of the node's capacity (e.g., "50%").
+type AllocatableChange struct {
+	After       metav1.Duration                `json:"after"`
+	Allocatable map[corev1.ResourceName]string `json:"allocatable"`
+}
+
+func LoadAllocatableSchedule(scheduleFile string) ([]AllocatableChange, error) {
+	scheduleBytes, err := os.ReadFile(scheduleFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", scheduleFile, err)
+	}
+
+	var schedule []AllocatableChange
+	if err = yaml.UnmarshalStrict(scheduleBytes, &schedule); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", scheduleFile, err)
+	}
+
+	sort.SliceStable(schedule, func(i, j int) bool { return schedule[i].After.Duration < schedule[j].After.Duration })
+	return schedule, nil
+}
+
+// SetAllocatable updates the node's allocatable resources; resources that aren't
+// specified are left unchanged
+func (self *LifecycleManager) SetAllocatable(ctx context.Context, allocatable map[corev1.ResourceName]string) error {
+	self.logger.Infof("setting node allocatable resources: %v", allocatable)
+
+	var computeErr error
+	err := self.updateNodeStatus(ctx, func(n *corev1.Node) {
+		var newAllocatable corev1.ResourceList
+		newAllocatable, computeErr = computeAllocatable(n.Status.Capacity, n.Status.Allocatable, allocatable)
+		if computeErr == nil {
+			n.Status.Allocatable = newAllocatable
+		}
+	})
+	if computeErr != nil {
+		return computeErr
+	}
+	return err
+}
+
+func (self *LifecycleManager) runAllocatableSchedule(ctx context.Context) {
+	start := time.Now()
+	for _, change := range self.opts.AllocatableSchedule {
+		timer := time.NewTimer(time.Until(start.Add(change.After.Duration)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			if err := self.SetAllocatable(ctx, change.Allocatable); err != nil {
+				self.logger.WithError(err).Error("could not apply scheduled allocatable change")
+			}
+		}
+	}
+}
+
+func computeAllocatable(
+	capacity, current corev1.ResourceList,
+	changes map[corev1.ResourceName]string,
+) (corev1.ResourceList, error) {
+	res := current.DeepCopy()
+	if res == nil {
+		res = corev1.ResourceList{}
+	}
+
+	for name, value := range changes {
+		if pctStr, ok := strings.CutSuffix(value, "%"); ok {
+			pct, err := strconv.ParseFloat(pctStr, 64)
+			// More than 100% would make allocatable larger than capacity, which the scheduler
+			// doesn't catch
+			if err != nil || pct < 0 || pct > 100 {
+				return nil, fmt.Errorf("%w: invalid percentage for %s: %s", ErrInvalidAllocatable, name, value)
+			}
+			capQuantity, ok := capacity[name]
+			if !ok {
+				return nil, fmt.Errorf(
+					"%w: cannot set %s to a percentage of capacity: no capacity for %s",
+					ErrInvalidAllocatable, value, name,
+				)
+			}
+			milliValue := int64(float64(capQuantity.MilliValue()) * pct / 100)
+			res[name] = *resource.NewMilliQuantity(milliValue, capQuantity.Format)
+		} else {
+			q, err := resource.ParseQuantity(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid quantity for %s: %w", ErrInvalidAllocatable, name, err)
+			}
+			res[name] = q
+		}
+	}
+	return res, nil
+}
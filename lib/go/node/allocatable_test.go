@@ -0,0 +1,83 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"simkube/lib/go/util"
+)
+
+const testScheduleFile = "../testutils/manifests/allocatable-schedule.yml"
+
+func TestLoadAllocatableSchedule(t *testing.T) {
+	schedule, err := LoadAllocatableSchedule(testScheduleFile)
+
+	assert.Nil(t, err)
+	assert.Len(t, schedule, 2)
+	assert.Equal(t, 10*time.Minute, schedule[0].After.Duration)
+	assert.Equal(t, "50%", schedule[0].Allocatable[corev1.ResourceCPU])
+	assert.Equal(t, 20*time.Minute, schedule[1].After.Duration)
+}
+
+func TestComputeAllocatable(t *testing.T) {
+	capacity := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("4"),
+		corev1.ResourceMemory: resource.MustParse("8Gi"),
+	}
+
+	cases := map[string]struct {
+		changes       map[corev1.ResourceName]string
+		expected      corev1.ResourceList
+		expectedError bool
+	}{
+		"absolute": {
+			changes: map[corev1.ResourceName]string{corev1.ResourceMemory: "2Gi"},
+			expected: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("4"),
+				corev1.ResourceMemory: resource.MustParse("2Gi"),
+			},
+		},
+		"percentage": {
+			changes: map[corev1.ResourceName]string{corev1.ResourceCPU: "50%", corev1.ResourceMemory: "25%"},
+			expected: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2"),
+				corev1.ResourceMemory: resource.MustParse("2Gi"),
+			},
+		},
+		"bad quantity": {
+			changes:       map[corev1.ResourceName]string{corev1.ResourceCPU: "asdf"},
+			expectedError: true,
+		},
+		"bad percentage": {
+			changes:       map[corev1.ResourceName]string{corev1.ResourceCPU: "asdf%"},
+			expectedError: true,
+		},
+		"percentage over 100": {
+			changes:       map[corev1.ResourceName]string{corev1.ResourceCPU: "150%"},
+			expectedError: true,
+		},
+		"percentage without capacity": {
+			changes:       map[corev1.ResourceName]string{corev1.ResourcePods: "50%"},
+			expectedError: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := computeAllocatable(capacity, capacity, tc.changes)
+			if tc.expectedError {
+				assert.ErrorIs(t, err, ErrInvalidAllocatable)
+				assert.True(t, util.IsValidation(err))
+			} else {
+				assert.Nil(t, err)
+				for rname, q := range tc.expected {
+					assert.Zero(t, q.Cmp(res[rname]), "%s: expected %v, got %v", rname, q, res[rname])
+				}
+			}
+		})
+	}
+}
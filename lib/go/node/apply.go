@@ -0,0 +1,88 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+
+	"simkube/lib/go/k8s"
+)
+
+const fieldManager = "simkube-vnode"
+
+// We use server-side apply to manage the simkube-owned parts of the node object (labels,
+// annotations, the provider ID, and taints), so that changes from other controllers (e.g.,
+// labels synced by a cloud controller) aren't clobbered.  The node status is managed by
+// virtual-kubelet.
+//
+// Taints are an atomic list, so whoever applies them owns the entire list; to avoid removing
+// taints that were added by someone else (like the node lifecycle controller), we include
+// them in the applied list along with our own.
+func (self *LifecycleManager) applyNode(ctx context.Context, current *corev1.Node) error {
+	self.mutex.Lock()
+	desired := self.desired.DeepCopy()
+	self.mutex.Unlock()
+	if desired == nil {
+		return errNodeNotRunning
+	}
+
+	taints := desired.Spec.Taints
+	if current != nil {
+		for _, taint := range current.Spec.Taints {
+			if !lo.ContainsBy(taints, func(t corev1.Taint) bool { return t.MatchTaint(&taint) }) {
+				taints = append(taints, taint)
+			}
+		}
+	}
+
+	nodeApply := corev1ac.Node(self.nodeName).
+		WithLabels(desired.ObjectMeta.Labels).
+		WithAnnotations(desired.ObjectMeta.Annotations).
+		WithSpec(corev1ac.NodeSpec().
+			WithProviderID(desired.Spec.ProviderID).
+			WithTaints(lo.Map(taints, taintApplyConfig)...),
+		)
+
+	if err := k8s.Retry(func() error {
+		_, err := self.k8sClient.CoreV1().Nodes().Apply(
+			ctx,
+			nodeApply,
+			metav1.ApplyOptions{FieldManager: fieldManager, Force: true},
+		)
+		//nolint:wrapcheck // wrapped below
+		return err
+	}); err != nil {
+		return fmt.Errorf("could not apply node: %w", err)
+	}
+	return nil
+}
+
+// registerNode applies the node object before the node controller starts, so that the
+// node is created (or an existing node is updated) with our field manager
+func (self *LifecycleManager) registerNode(ctx context.Context) error {
+	var current *corev1.Node
+	err := k8s.Retry(func() (err error) {
+		current, err = self.k8sClient.CoreV1().Nodes().Get(ctx, self.nodeName, metav1.GetOptions{})
+		//nolint:wrapcheck // wrapped below
+		return err
+	})
+	if apierrors.IsNotFound(err) {
+		current = nil
+	} else if err != nil {
+		return fmt.Errorf("could not get node: %w", err)
+	}
+	return self.applyNode(ctx, current)
+}
+
+func taintApplyConfig(t corev1.Taint, _ int) *corev1ac.TaintApplyConfiguration {
+	taintApply := corev1ac.Taint().WithKey(t.Key).WithValue(t.Value).WithEffect(t.Effect)
+	if t.TimeAdded != nil {
+		taintApply = taintApply.WithTimeAdded(*t.TimeAdded)
+	}
+	return taintApply
+}
@@ -0,0 +1,107 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"simkube/lib/go/testutils"
+)
+
+// The fake clientset implements apply as a patch, which fails if the object doesn't exist
+// yet; this reactor creates the node from the applied configuration in that case
+func newApplyClientset(objs ...runtime.Object) *fake.Clientset {
+	k8sClient := fake.NewSimpleClientset(objs...)
+	k8sClient.PrependReactor("patch", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch, ok := action.(k8stesting.PatchAction)
+		if !ok || patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+
+		gvr := action.GetResource()
+		if _, err := k8sClient.Tracker().Get(gvr, "", patch.GetName()); !apierrors.IsNotFound(err) {
+			return false, nil, nil
+		}
+
+		var n corev1.Node
+		if err := json.Unmarshal(patch.GetPatch(), &n); err != nil {
+			return true, nil, err
+		}
+		return true, &n, k8sClient.Tracker().Create(gvr, &n, "")
+	})
+	return k8sClient
+}
+
+func TestApplyNode(t *testing.T) {
+	timeAdded := metav1.Unix(1688169600, 0)
+	otherTaint := corev1.Taint{
+		Key:       "node.kubernetes.io/unreachable",
+		Effect:    corev1.TaintEffectNoExecute,
+		TimeAdded: &timeAdded,
+	}
+	cases := map[string]struct {
+		current        *corev1.Node
+		expectedTaints []corev1.Taint
+	}{
+		"node missing": {
+			expectedTaints: []corev1.Taint{testTaint},
+		},
+		"other taints": {
+			current: &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: expectedName},
+				Spec:       corev1.NodeSpec{Taints: []corev1.Taint{otherTaint}},
+			},
+			expectedTaints: []corev1.Taint{testTaint, otherTaint},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var objs []runtime.Object
+			if tc.current != nil {
+				objs = append(objs, tc.current)
+			}
+			k8sClient := newApplyClientset(objs...)
+			nlm := &LifecycleManager{
+				nodeName:  expectedName,
+				k8sClient: k8sClient,
+				logger:    testutils.GetFakeLogger(),
+				desired:   makeDesiredNode(),
+			}
+
+			err := nlm.applyNode(context.TODO(), tc.current)
+			assert.Nil(t, err)
+
+			n, err := k8sClient.CoreV1().Nodes().Get(context.TODO(), expectedName, metav1.GetOptions{})
+			assert.Nil(t, err)
+			assert.Equal(t, makeDesiredNode().Labels, n.Labels)
+			assert.Equal(t, tc.expectedTaints, n.Spec.Taints)
+
+			for _, action := range k8sClient.Actions() {
+				if patch, ok := action.(k8stesting.PatchAction); ok {
+					assert.Equal(t, types.ApplyPatchType, patch.GetPatchType())
+				}
+			}
+		})
+	}
+}
+
+func TestApplyNodeNotRunning(t *testing.T) {
+	nlm := &LifecycleManager{
+		nodeName:  expectedName,
+		k8sClient: fake.NewSimpleClientset(),
+		logger:    testutils.GetFakeLogger(),
+	}
+
+	err := nlm.applyNode(context.TODO(), nil)
+	assert.ErrorIs(t, err, errNodeNotRunning)
+}
@@ -0,0 +1,78 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"simkube/lib/go/k8s"
+)
+
+const (
+	// These match the events that the kubelet emits when it observes a change to
+	// spec.unschedulable
+	nodeSchedulableReason    = "NodeSchedulable"
+	nodeNotSchedulableReason = "NodeNotSchedulable"
+)
+
+// SetUnschedulable cordons (or uncordons) the node, and records the corresponding event
+// on the node object.
+func (self *LifecycleManager) SetUnschedulable(ctx context.Context, unschedulable bool) error {
+	self.logger.Infof("setting node unschedulable=%t", unschedulable)
+
+	patch := fmt.Sprintf(`{"spec":{"unschedulable":%t}}`, unschedulable)
+	if err := k8s.Retry(func() error {
+		_, err := self.k8sClient.CoreV1().Nodes().Patch(
+			ctx,
+			self.nodeName,
+			types.MergePatchType,
+			[]byte(patch),
+			metav1.PatchOptions{FieldManager: fieldManager},
+		)
+		//nolint:wrapcheck // wrapped below
+		return err
+	}); err != nil {
+		return fmt.Errorf("could not set unschedulable=%t: %w", unschedulable, err)
+	}
+
+	reason := nodeSchedulableReason
+	if unschedulable {
+		reason = nodeNotSchedulableReason
+	}
+	message := fmt.Sprintf("Node %s status is now: %s", self.nodeName, reason)
+	self.recordNodeEvent(corev1.EventTypeNormal, reason, message)
+	return nil
+}
+
+func (self *LifecycleManager) recordNodeEvent(eventType, reason, message string) {
+	self.mutex.Lock()
+	recorder := self.recorder
+	self.mutex.Unlock()
+
+	if recorder == nil {
+		return
+	}
+
+	// The kubelet uses the node name as the UID in node event references
+	ref := &corev1.ObjectReference{Kind: "Node", Name: self.nodeName, UID: types.UID(self.nodeName)}
+	recorder.Event(ref, eventType, reason, message)
+}
+
+func (self *LifecycleManager) makeEventRecorder() record.EventRecorder {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(self.logger.Infof)
+	eventBroadcaster.StartRecordingToSink(
+		&corev1client.EventSinkImpl{Interface: self.k8sClient.CoreV1().Events(corev1.NamespaceAll)},
+	)
+	return eventBroadcaster.NewRecorder(
+		scheme.Scheme,
+		corev1.EventSource{Component: path.Join(self.nodeName, "node-controller"), Host: self.nodeName},
+	)
+}
@@ -0,0 +1,63 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"simkube/lib/go/testutils"
+)
+
+func TestSetUnschedulable(t *testing.T) {
+	cases := map[string]struct {
+		unschedulable  bool
+		expectedReason string
+	}{
+		"cordon":   {unschedulable: true, expectedReason: nodeNotSchedulableReason},
+		"uncordon": {unschedulable: false, expectedReason: nodeSchedulableReason},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			k8sClient := fake.NewSimpleClientset(&corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: expectedName},
+				Spec:       corev1.NodeSpec{Unschedulable: !tc.unschedulable},
+			})
+			recorder := record.NewFakeRecorder(1)
+			nlm := &LifecycleManager{
+				nodeName:  expectedName,
+				k8sClient: k8sClient,
+				logger:    testutils.GetFakeLogger(),
+				recorder:  recorder,
+			}
+
+			err := nlm.SetUnschedulable(context.TODO(), tc.unschedulable)
+			assert.Nil(t, err)
+
+			n, _ := k8sClient.CoreV1().Nodes().Get(context.TODO(), expectedName, metav1.GetOptions{})
+			assert.Equal(t, tc.unschedulable, n.Spec.Unschedulable)
+			assert.Equal(
+				t,
+				fmt.Sprintf("Normal %s Node %s status is now: %s", tc.expectedReason, expectedName, tc.expectedReason),
+				<-recorder.Events,
+			)
+		})
+	}
+}
+
+func TestSetUnschedulableNodeNotFound(t *testing.T) {
+	nlm := &LifecycleManager{
+		nodeName:  expectedName,
+		k8sClient: fake.NewSimpleClientset(),
+		logger:    testutils.GetFakeLogger(),
+	}
+
+	err := nlm.SetUnschedulable(context.TODO(), true)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,46 @@
+package node
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"simkube/lib/go/dra"
+)
+
+const resourceSliceRetryInterval = 5 * time.Second
+
+// The ResourceSlice is owned by the node object (so that it's garbage-collected along with the
+// node), which means it can't be published until the node is registered and has a UID; we keep
+// trying until the node shows up.
+func (self *LifecycleManager) publishResourceSlice(ctx context.Context) {
+	ticker := time.NewTicker(resourceSliceRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := self.tryPublishResourceSlice(ctx); err != nil {
+			self.logger.WithError(err).Warn("could not publish ResourceSlice, retrying")
+		} else {
+			devices := self.opts.DRADevices
+			self.logger.Infof("published ResourceSlice with %d %s devices", len(devices.DeviceNames()), devices.Driver)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (self *LifecycleManager) tryPublishResourceSlice(ctx context.Context) error {
+	n, err := self.k8sClient.CoreV1().Nodes().Get(ctx, self.nodeName, metav1.GetOptions{})
+	if err != nil {
+		//nolint:wrapcheck // the node not existing yet is expected, no need for extra context
+		return err
+	}
+	//nolint:wrapcheck // already wrapped
+	return dra.PublishResourceSlice(ctx, self.dynamicClient, self.opts.DRADevices, n)
+}
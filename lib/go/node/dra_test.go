@@ -0,0 +1,51 @@
+package node
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"simkube/lib/go/dra"
+	"simkube/lib/go/testutils"
+)
+
+func TestTryPublishResourceSlice(t *testing.T) {
+	k8sClient := fake.NewSimpleClientset()
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{dra.ResourceSliceResource: "ResourceSliceList"},
+	)
+	nlm := &LifecycleManager{
+		nodeName:      expectedName,
+		k8sClient:     k8sClient,
+		dynamicClient: dynamicClient,
+		logger:        testutils.GetFakeLogger(),
+		opts: Options{DRADevices: &dra.DeviceConfig{
+			Driver:  "gpu.example.com",
+			Devices: []dra.DeviceSpec{{Name: "gpu", Count: 4}},
+		}},
+	}
+
+	// The node hasn't been registered yet
+	assert.NotNil(t, nlm.tryPublishResourceSlice(context.TODO()))
+
+	_, err := k8sClient.CoreV1().Nodes().Create(
+		context.TODO(),
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: expectedName, UID: "1234"}},
+		metav1.CreateOptions{},
+	)
+	assert.Nil(t, err)
+	assert.Nil(t, nlm.tryPublishResourceSlice(context.TODO()))
+
+	slice, err := dynamicClient.Resource(dra.ResourceSliceResource).
+		Get(context.TODO(), expectedName+"-gpu.example.com", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "1234", string(slice.GetOwnerReferences()[0].UID))
+}
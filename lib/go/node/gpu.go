@@ -0,0 +1,43 @@
+package node
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/util"
+)
+
+const defaultGPUType = "nvidia-gpu"
+
+// Cluster Autoscaler uses the GPU label to tell which nodes have GPUs (and what kind), so
+// nodes with a non-zero number of GPUs are labelled with their GPU type.  The type comes
+// from (in order of precedence) the skeleton, the node group's simkube.io/gpu-type
+// annotation, or the default type.
+func (self *LifecycleManager) setGPULabel(ctx context.Context, node *corev1.Node) {
+	if gpus, ok := node.Status.Capacity[k8s.NvidiaGPUResource]; !ok || gpus.IsZero() {
+		return
+	}
+
+	label := self.gpuLabel()
+	if _, ok := node.ObjectMeta.Labels[label]; ok {
+		return
+	}
+
+	gpuType := self.lookupNodeGroupAnnotation(ctx, util.GPUTypeAnnotation)
+	if gpuType == "" {
+		gpuType = defaultGPUType
+	}
+	if node.ObjectMeta.Labels == nil {
+		node.ObjectMeta.Labels = map[string]string{}
+	}
+	node.ObjectMeta.Labels[label] = gpuType
+}
+
+func (self *LifecycleManager) gpuLabel() string {
+	if self.opts.GPULabel != "" {
+		return self.opts.GPULabel
+	}
+	return util.GPULabel
+}
@@ -0,0 +1,86 @@
+package node
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/testutils"
+	"simkube/lib/go/util"
+)
+
+func TestSetGPULabel(t *testing.T) {
+	cases := map[string]struct {
+		gpus          string
+		skelLabels    map[string]string
+		groupGPUType  string
+		gpuLabel      string
+		expectedLabel string
+		expectedType  string
+	}{
+		"no gpus": {
+			gpus:          "0",
+			expectedLabel: util.GPULabel,
+		},
+		"default": {
+			gpus:          "4",
+			expectedLabel: util.GPULabel,
+			expectedType:  defaultGPUType,
+		},
+		"node group": {
+			gpus:          "4",
+			groupGPUType:  "nvidia-tesla-t4",
+			expectedLabel: util.GPULabel,
+			expectedType:  "nvidia-tesla-t4",
+		},
+		"skeleton": {
+			gpus:          "4",
+			skelLabels:    map[string]string{util.GPULabel: "nvidia-a100"},
+			groupGPUType:  "nvidia-tesla-t4",
+			expectedLabel: util.GPULabel,
+			expectedType:  "nvidia-a100",
+		},
+		"custom label": {
+			gpus:          "4",
+			gpuLabel:      "k8s.amazonaws.com/accelerator",
+			groupGPUType:  "nvidia-tesla-t4",
+			expectedLabel: "k8s.amazonaws.com/accelerator",
+			expectedType:  "nvidia-tesla-t4",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(namespaceEnvKey, "test")
+			t.Setenv(nodeGroupEnvKey, "node-group")
+
+			deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "node-group"}}
+			if tc.groupGPUType != "" {
+				deployment.ObjectMeta.Annotations = map[string]string{util.GPUTypeAnnotation: tc.groupGPUType}
+			}
+			nlm := &LifecycleManager{
+				nodeName:      expectedName,
+				dynamicClient: dynamicfake.NewSimpleDynamicClient(scheme.Scheme, deployment),
+				opts:          Options{GPULabel: tc.gpuLabel},
+				logger:        testutils.GetFakeLogger(),
+			}
+			n := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Labels: tc.skelLabels},
+				Status: corev1.NodeStatus{
+					Capacity: corev1.ResourceList{k8s.NvidiaGPUResource: resource.MustParse(tc.gpus)},
+				},
+			}
+
+			nlm.setGPULabel(context.TODO(), n)
+			assert.Equal(t, tc.expectedType, n.ObjectMeta.Labels[tc.expectedLabel])
+		})
+	}
+}
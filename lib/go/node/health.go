@@ -0,0 +1,42 @@
+package node
+
+import (
+	"fmt"
+	"time"
+
+	"simkube/lib/go/util"
+)
+
+// The node controller pings the provider every 10s (before it renews the lease), so if it
+// hasn't done so in a couple of minutes, it's probably wedged
+const maxPingAge = 2 * time.Minute
+
+// Ready returns nil once the node has been registered with the API server and the node
+// controller is running
+func (self *LifecycleManager) Ready() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.nodeCtrl == nil {
+		return errNodeNotRunning
+	}
+	return util.ControllerReady("node", self.nodeCtrl)
+}
+
+// Healthy returns an error if the node controller has stopped, or if it has stopped pinging
+// the provider; a node that is still starting up is considered healthy
+func (self *LifecycleManager) Healthy() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.nodeCtrl == nil {
+		return nil
+	}
+	if err := util.ControllerRunning("node", self.nodeCtrl); err != nil {
+		return err
+	}
+	if age := self.provider.sinceLastPing(); age > maxPingAge {
+		return fmt.Errorf("node controller has not pinged the provider in %v", age.Round(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"simkube/lib/go/testutils"
+)
+
+type fakeNodeController struct {
+	ready chan struct{}
+	done  chan struct{}
+}
+
+func (self *fakeNodeController) Ready() <-chan struct{} { return self.ready }
+func (self *fakeNodeController) Done() <-chan struct{}  { return self.done }
+func (self *fakeNodeController) Err() error             { return nil }
+
+func TestNodeHealth(t *testing.T) {
+	nlm := &LifecycleManager{logger: testutils.GetFakeLogger()}
+	assert.ErrorIs(t, nlm.Ready(), errNodeNotRunning)
+	assert.Nil(t, nlm.Healthy())
+
+	ctrl := &fakeNodeController{ready: make(chan struct{}), done: make(chan struct{})}
+	nlm.provider = newNodeProvider()
+	nlm.nodeCtrl = ctrl
+	assert.NotNil(t, nlm.Ready())
+	assert.Nil(t, nlm.Healthy())
+
+	close(ctrl.ready)
+	assert.Nil(t, nlm.Ready())
+	assert.Nil(t, nlm.Healthy())
+
+	// The node controller is wedged if it stops pinging the provider
+	nlm.provider.lastPing = time.Now().Add(-maxPingAge - time.Second)
+	assert.Nil(t, nlm.Ready())
+	assert.NotNil(t, nlm.Healthy())
+
+	nlm.provider.lastPing = time.Now()
+	close(ctrl.done)
+	assert.NotNil(t, nlm.Ready())
+	assert.NotNil(t, nlm.Healthy())
+}
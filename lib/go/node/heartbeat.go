@@ -0,0 +1,68 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/node"
+)
+
+var errHeartbeatsPaused = errors.New("node heartbeats are paused")
+
+// The virtual-kubelet node controller pings the provider before it renews the node lease
+// or posts the node status, and skips both if the ping fails; we use this to simulate a
+// kubelet that has lost contact with the control plane without touching the node object.
+type nodeProvider struct {
+	*node.NaiveNodeProviderV2
+
+	mutex       sync.Mutex
+	pausedUntil time.Time
+	lastPing    time.Time
+}
+
+func newNodeProvider() *nodeProvider {
+	return &nodeProvider{NaiveNodeProviderV2: node.NewNaiveNodeProvider(), lastPing: time.Now()}
+}
+
+func (self *nodeProvider) Ping(ctx context.Context) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	// This is recorded even when the heartbeats are paused, since the node controller is still
+	// doing its job (see Healthy)
+	self.lastPing = time.Now()
+	if time.Now().Before(self.pausedUntil) {
+		return errHeartbeatsPaused
+	}
+	return self.NaiveNodeProviderV2.Ping(ctx) //nolint:wrapcheck // this is just a passthrough
+}
+
+func (self *nodeProvider) sinceLastPing() time.Duration {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return time.Since(self.lastPing)
+}
+
+func (self *nodeProvider) pauseHeartbeats(duration time.Duration) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.pausedUntil = time.Now().Add(duration)
+}
+
+// PauseHeartbeats stops the node from renewing its lease or posting status updates for the
+// given duration; heartbeats resume automatically afterwards.  Calling this while the
+// heartbeats are already paused replaces the previous pause.
+func (self *LifecycleManager) PauseHeartbeats(duration time.Duration) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.provider == nil {
+		return errNodeNotRunning
+	}
+
+	self.logger.Infof("pausing node heartbeats for %v", duration)
+	self.provider.pauseHeartbeats(duration)
+	return nil
+}
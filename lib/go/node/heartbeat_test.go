@@ -0,0 +1,26 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"simkube/lib/go/testutils"
+)
+
+func TestPauseHeartbeats(t *testing.T) {
+	nlm := &LifecycleManager{logger: testutils.GetFakeLogger()}
+	assert.ErrorIs(t, nlm.PauseHeartbeats(time.Minute), errNodeNotRunning)
+
+	nlm.provider = newNodeProvider()
+	assert.Nil(t, nlm.provider.Ping(context.TODO()))
+
+	assert.Nil(t, nlm.PauseHeartbeats(time.Minute))
+	assert.ErrorIs(t, nlm.provider.Ping(context.TODO()), errHeartbeatsPaused)
+
+	// A later pause replaces the earlier one, so this resumes the heartbeats immediately
+	assert.Nil(t, nlm.PauseHeartbeats(-time.Second))
+	assert.Nil(t, nlm.provider.Ping(context.TODO()))
+}
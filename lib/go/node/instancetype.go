@@ -0,0 +1,65 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"simkube/lib/go/util"
+)
+
+// InstanceShape parses the CPU and memory of an instance type, as given in the node group's
+// simkube.io/instance-cpu and simkube.io/instance-memory annotations; either one can be empty.
+func InstanceShape(cpu, memory string) (corev1.ResourceList, error) {
+	shape := corev1.ResourceList{}
+	for name, value := range map[corev1.ResourceName]string{corev1.ResourceCPU: cpu, corev1.ResourceMemory: memory} {
+		if value == "" {
+			continue
+		}
+
+		q, err := resource.ParseQuantity(value)
+		if err != nil || q.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid instance %s: %q", name, value)
+		}
+		shape[name] = q
+	}
+	return shape, nil
+}
+
+// The node group can give its nodes an instance type, along with the CPU and memory that
+// instances of that type have, so that node groups with different shapes can be simulated
+// without a separate skeleton for each one.  Anything set in the skeleton takes precedence.
+func (self *LifecycleManager) applyNodeGroupInstanceType(ctx context.Context, node *corev1.Node) {
+	annotations := self.lookupNodeGroupAnnotations(
+		ctx,
+		util.InstanceTypeAnnotation,
+		util.InstanceCPUAnnotation,
+		util.InstanceMemoryAnnotation,
+	)
+
+	shape, err := InstanceShape(annotations[util.InstanceCPUAnnotation], annotations[util.InstanceMemoryAnnotation])
+	if err != nil {
+		self.logger.WithError(err).Warn("invalid node group instance shape, ignoring")
+	}
+	applyInstanceType(node, annotations[util.InstanceTypeAnnotation], shape)
+}
+
+func applyInstanceType(node *corev1.Node, instanceType string, shape corev1.ResourceList) {
+	if _, ok := node.ObjectMeta.Labels[nodeInstanceTypeLabel]; !ok && instanceType != "" {
+		if node.ObjectMeta.Labels == nil {
+			node.ObjectMeta.Labels = map[string]string{}
+		}
+		node.ObjectMeta.Labels[nodeInstanceTypeLabel] = instanceType
+	}
+
+	for name, q := range shape {
+		if _, ok := node.Status.Capacity[name]; !ok {
+			if node.Status.Capacity == nil {
+				node.Status.Capacity = corev1.ResourceList{}
+			}
+			node.Status.Capacity[name] = q.DeepCopy()
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package node
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"simkube/lib/go/testutils"
+	"simkube/lib/go/util"
+)
+
+func TestInstanceShape(t *testing.T) {
+	shape, err := InstanceShape("4", "16Gi")
+	assert.Nil(t, err)
+	assert.Equal(t, corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("4"),
+		corev1.ResourceMemory: resource.MustParse("16Gi"),
+	}, shape)
+
+	shape, err = InstanceShape("", "")
+	assert.Nil(t, err)
+	assert.Empty(t, shape)
+
+	for _, cpu := range []string{"lots", "0", "-1"} {
+		_, err = InstanceShape(cpu, "")
+		assert.NotNil(t, err)
+	}
+}
+
+func TestApplyNodeGroupInstanceType(t *testing.T) {
+	cases := map[string]struct {
+		skelLabels       map[string]string
+		skelCapacity     corev1.ResourceList
+		groupAnnotations map[string]string
+		expectedType     string
+		expectedCapacity corev1.ResourceList
+	}{
+		"default": {},
+		"node group": {
+			groupAnnotations: map[string]string{
+				util.InstanceTypeAnnotation:   "m6i.xlarge",
+				util.InstanceCPUAnnotation:    "4",
+				util.InstanceMemoryAnnotation: "16Gi",
+			},
+			expectedType: "m6i.xlarge",
+			expectedCapacity: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("4"),
+				corev1.ResourceMemory: resource.MustParse("16Gi"),
+			},
+		},
+		"skeleton": {
+			skelLabels:   map[string]string{nodeInstanceTypeLabel: "c6i.large"},
+			skelCapacity: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			groupAnnotations: map[string]string{
+				util.InstanceTypeAnnotation: "m6i.xlarge",
+				util.InstanceCPUAnnotation:  "4",
+			},
+			expectedType:     "c6i.large",
+			expectedCapacity: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+		},
+		"invalid shape": {
+			groupAnnotations: map[string]string{
+				util.InstanceTypeAnnotation: "m6i.xlarge",
+				util.InstanceCPUAnnotation:  "lots",
+			},
+			expectedType: "m6i.xlarge",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(namespaceEnvKey, "test")
+			t.Setenv(nodeGroupEnvKey, "node-group")
+
+			deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "test",
+				Name:        "node-group",
+				Annotations: tc.groupAnnotations,
+			}}
+			nlm := &LifecycleManager{
+				nodeName:      expectedName,
+				dynamicClient: dynamicfake.NewSimpleDynamicClient(scheme.Scheme, deployment),
+				logger:        testutils.GetFakeLogger(),
+			}
+			n := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Labels: tc.skelLabels},
+				Status:     corev1.NodeStatus{Capacity: tc.skelCapacity},
+			}
+
+			nlm.applyNodeGroupInstanceType(context.TODO(), n)
+			assert.Equal(t, tc.expectedType, n.ObjectMeta.Labels[nodeInstanceTypeLabel])
+			assert.Equal(t, tc.expectedCapacity, n.Status.Capacity)
+		})
+	}
+}
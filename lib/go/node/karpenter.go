@@ -0,0 +1,63 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+
+	"simkube/lib/go/karpenter"
+)
+
+// lookupNodeClaim returns the Karpenter NodeClaim that this virtual node was launched for (given
+// by the KARPENTER_NODECLAIM environment variable), or nil if it wasn't launched by Karpenter.
+// Unlike the node group annotations, the NodeClaim isn't optional: if the node doesn't match it,
+// Karpenter never considers it registered, so it's an error if it can't be found.
+func (self *LifecycleManager) lookupNodeClaim(ctx context.Context) (*karpenter.NodeClaim, error) {
+	name := os.Getenv(karpenter.NodeClaimEnvKey)
+	if name == "" {
+		return nil, nil
+	} else if self.dynamicClient == nil {
+		return nil, fmt.Errorf("could not look up NodeClaim %s: no dynamic client", name)
+	}
+
+	claim, err := karpenter.GetNodeClaim(ctx, self.dynamicClient, name)
+	if err != nil {
+		//nolint:wrapcheck // already wrapped
+		return nil, err
+	}
+	self.logger.Infof("registering node for Karpenter NodeClaim %s", name)
+	return claim, nil
+}
+
+// applyNodeClaim gives the node the labels, taints, and resources from its NodeClaim; these take
+// precedence over the skeleton and the node group settings, since they're what Karpenter (and
+// the pods it launched the node for) expect.  The startup taints are left off, because nothing
+// in the simulation would ever remove them.
+func applyNodeClaim(node *corev1.Node, claim *karpenter.NodeClaim) {
+	node.ObjectMeta.Labels = lo.Assign(node.ObjectMeta.Labels, claim.NodeLabels())
+
+	for _, taint := range claim.Spec.Taints {
+		if !lo.ContainsBy(node.Spec.Taints, func(t corev1.Taint) bool { return t.MatchTaint(&taint) }) {
+			node.Spec.Taints = append(node.Spec.Taints, taint)
+		}
+	}
+
+	if len(claim.Status.Capacity) > 0 {
+		node.Status.Capacity = lo.Assign(node.Status.Capacity, claim.Status.Capacity)
+	}
+	if len(claim.Status.Allocatable) > 0 {
+		node.Status.Allocatable = lo.Assign(node.Status.Allocatable, claim.Status.Allocatable)
+	}
+}
+
+// Karpenter matches nodes to NodeClaims by provider ID, so if the NodeClaim already has one
+// (i.e., the cloud provider or sk-cloudprov assigned it), the node has to use it, whatever the
+// provider ID template says
+func applyNodeClaimProviderID(node *corev1.Node, claim *karpenter.NodeClaim) {
+	if claim != nil && claim.Status.ProviderID != "" {
+		node.Spec.ProviderID = claim.Status.ProviderID
+	}
+}
@@ -0,0 +1,95 @@
+package node
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"simkube/lib/go/karpenter"
+	"simkube/lib/go/testutils"
+)
+
+func TestLookupNodeClaim(t *testing.T) {
+	claim := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "karpenter.sh/v1",
+		"kind":       "NodeClaim",
+		"metadata":   map[string]interface{}{"name": "default-abcde"},
+		"status":     map[string]interface{}{"providerID": "simkube://default-abcde"},
+	}}
+	nlm := &LifecycleManager{
+		nodeName: expectedName,
+		dynamicClient: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+			runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{karpenter.NodeClaimResource: "NodeClaimList"},
+			claim,
+		),
+		logger: testutils.GetFakeLogger(),
+	}
+
+	found, err := nlm.lookupNodeClaim(context.TODO())
+	assert.Nil(t, err)
+	assert.Nil(t, found)
+
+	t.Setenv(karpenter.NodeClaimEnvKey, "default-abcde")
+	found, err = nlm.lookupNodeClaim(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, "simkube://default-abcde", found.Status.ProviderID)
+
+	t.Setenv(karpenter.NodeClaimEnvKey, "missing")
+	_, err = nlm.lookupNodeClaim(context.TODO())
+	assert.NotNil(t, err)
+}
+
+func TestApplyNodeClaim(t *testing.T) {
+	claim := &karpenter.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{karpenter.NodePoolLabel: "default"}},
+		Spec: karpenter.NodeClaimSpec{
+			Requirements: []karpenter.NodeSelectorRequirement{
+				{Key: nodeInstanceTypeLabel, Operator: corev1.NodeSelectorOpIn, Values: []string{"c6i.large"}},
+			},
+			Taints:        []corev1.Taint{{Key: "dedicated", Value: "batch", Effect: corev1.TaintEffectNoSchedule}},
+			StartupTaints: []corev1.Taint{{Key: "startup", Effect: corev1.TaintEffectNoSchedule}},
+		},
+		Status: karpenter.NodeClaimStatus{
+			ProviderID: "simkube://default-abcde",
+			Capacity:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+		},
+	}
+	n := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{nodeInstanceTypeLabel: "m6i.large", "foo": "bar"}},
+		Spec: corev1.NodeSpec{
+			ProviderID: "simkube://" + expectedName,
+			Taints:     []corev1.Taint{*defaultVirtualNodeTaint()},
+		},
+		Status: corev1.NodeStatus{Capacity: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("1"),
+			corev1.ResourceMemory: resource.MustParse("4Gi"),
+		}},
+	}
+
+	applyNodeClaim(n, claim)
+	applyNodeClaimProviderID(n, claim)
+	assert.Equal(t, map[string]string{
+		nodeInstanceTypeLabel:       "c6i.large",
+		karpenter.NodePoolLabel:     "default",
+		karpenter.CapacityTypeLabel: "on-demand",
+		"foo":                       "bar",
+	}, n.ObjectMeta.Labels)
+	assert.Equal(t, []corev1.Taint{
+		*defaultVirtualNodeTaint(),
+		{Key: "dedicated", Value: "batch", Effect: corev1.TaintEffectNoSchedule},
+	}, n.Spec.Taints)
+	assert.Equal(t, corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("2"),
+		corev1.ResourceMemory: resource.MustParse("4Gi"),
+	}, n.Status.Capacity)
+	assert.Equal(t, "simkube://default-abcde", n.Spec.ProviderID)
+}
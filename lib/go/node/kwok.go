@@ -0,0 +1,31 @@
+package node
+
+import (
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// kwok marks the nodes that it manages with an annotation, and taints them with the same key,
+// so that only pods that are meant for fake nodes land on them
+const (
+	kwokNodeKey   = "kwok.x-k8s.io/node"
+	kwokNodeValue = "fake"
+)
+
+// applyKwokCompat makes the node look like a kwok node to tools that only know about kwok.  The
+// type label is left alone, since the simulated pods select virtual nodes by it; as with the
+// virtual node taint, a taint with the same key in the skeleton wins.
+func applyKwokCompat(node *corev1.Node) {
+	if node.ObjectMeta.Annotations == nil {
+		node.ObjectMeta.Annotations = map[string]string{}
+	}
+	node.ObjectMeta.Annotations[kwokNodeKey] = kwokNodeValue
+
+	if !lo.ContainsBy(node.Spec.Taints, func(t corev1.Taint) bool { return t.Key == kwokNodeKey }) {
+		node.Spec.Taints = append(node.Spec.Taints, corev1.Taint{
+			Key:    kwokNodeKey,
+			Value:  kwokNodeValue,
+			Effect: corev1.TaintEffectNoSchedule,
+		})
+	}
+}
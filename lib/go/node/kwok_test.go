@@ -0,0 +1,35 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestApplyKwokCompat(t *testing.T) {
+	kwokTaint := corev1.Taint{Key: kwokNodeKey, Value: kwokNodeValue, Effect: corev1.TaintEffectNoSchedule}
+	cases := map[string]struct {
+		taints         []corev1.Taint
+		expectedTaints []corev1.Taint
+	}{
+		"no taints": {expectedTaints: []corev1.Taint{kwokTaint}},
+		"other taints": {
+			taints:         []corev1.Taint{{Key: "foo", Effect: corev1.TaintEffectNoExecute}},
+			expectedTaints: []corev1.Taint{{Key: "foo", Effect: corev1.TaintEffectNoExecute}, kwokTaint},
+		},
+		"skeleton wins": {
+			taints:         []corev1.Taint{{Key: kwokNodeKey, Effect: corev1.TaintEffectPreferNoSchedule}},
+			expectedTaints: []corev1.Taint{{Key: kwokNodeKey, Effect: corev1.TaintEffectPreferNoSchedule}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			node := &corev1.Node{Spec: corev1.NodeSpec{Taints: tc.taints}}
+			applyKwokCompat(node)
+			assert.Equal(t, map[string]string{kwokNodeKey: kwokNodeValue}, node.ObjectMeta.Annotations)
+			assert.Equal(t, tc.expectedTaints, node.Spec.Taints)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+
+	"simkube/lib/go/util"
+)
+
+// LabelChange describes a change to the node's labels that happens some time after the node
+// starts; a label with a null value is removed.  Changing labels out from under running pods
+// is how node affinity violations (and other imbalances that a descheduler looks for) come
+// about in a real cluster.
+type LabelChange struct {
+	After  metav1.Duration    `json:"after"`
+	Labels map[string]*string `json:"labels"`
+}
+
+func LoadLabelSchedule(scheduleFile string) ([]LabelChange, error) {
+	scheduleBytes, err := os.ReadFile(scheduleFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", scheduleFile, err)
+	}
+
+	var schedule []LabelChange
+	if err = yaml.UnmarshalStrict(scheduleBytes, &schedule); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", scheduleFile, err)
+	}
+
+	sort.SliceStable(schedule, func(i, j int) bool { return schedule[i].After.Duration < schedule[j].After.Duration })
+	return schedule, nil
+}
+
+// SetLabels adds, changes, or (for null values) removes labels on the node; labels that aren't
+// specified are left unchanged.  The new labels become part of the desired node, so they aren't
+// reverted by node reconciliation (but they are replaced if the skeleton is reloaded).
+func (self *LifecycleManager) SetLabels(ctx context.Context, labels map[string]*string) error {
+	self.logger.Infof("setting node labels: %v", formatLabelChanges(labels))
+
+	if err := self.updateNodeStatus(ctx, func(n *corev1.Node) {
+		n.ObjectMeta.Labels = computeLabels(n.ObjectMeta.Labels, labels)
+	}); err != nil {
+		return err
+	}
+
+	self.mutex.Lock()
+	if self.desired != nil {
+		self.desired.ObjectMeta.Labels = computeLabels(self.desired.ObjectMeta.Labels, labels)
+	}
+	self.mutex.Unlock()
+	return nil
+}
+
+func (self *LifecycleManager) runLabelSchedule(ctx context.Context) {
+	start := time.Now()
+	for _, change := range self.opts.LabelSchedule {
+		timer := time.NewTimer(time.Until(start.Add(change.After.Duration)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			if err := self.SetLabels(ctx, change.Labels); err != nil {
+				self.logger.WithError(err).Error("could not apply scheduled label change")
+			}
+		}
+	}
+}
+
+// ParseNodeLabels parses the labels in a node group's simkube.io/node-labels annotation, which
+// is a comma-separated list of key=value pairs (like kubelet's --node-labels flag)
+func ParseNodeLabels(spec string) (map[string]string, error) {
+	nodeLabels, err := labels.ConvertSelectorToLabelsMap(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node labels %q: %w", spec, err)
+	}
+	return nodeLabels, nil
+}
+
+// Node groups can have their own labels (e.g., a capacity type or a team), so that pods can
+// target them with node selectors and Cluster Autoscaler has something to tell them apart by;
+// labels from the skeleton take precedence.
+func (self *LifecycleManager) applyNodeGroupLabels(ctx context.Context, node *corev1.Node) {
+	spec := self.lookupNodeGroupAnnotation(ctx, util.NodeLabelsAnnotation)
+	if spec == "" {
+		return
+	}
+
+	nodeLabels, err := ParseNodeLabels(spec)
+	if err != nil {
+		self.logger.WithError(err).Warnf("invalid %s annotation on node group, ignoring", util.NodeLabelsAnnotation)
+		return
+	}
+	applyNodeLabels(node, nodeLabels)
+}
+
+func applyNodeLabels(node *corev1.Node, nodeLabels map[string]string) {
+	for key, value := range nodeLabels {
+		if _, ok := node.ObjectMeta.Labels[key]; !ok {
+			if node.ObjectMeta.Labels == nil {
+				node.ObjectMeta.Labels = map[string]string{}
+			}
+			node.ObjectMeta.Labels[key] = value
+		}
+	}
+}
+
+func computeLabels(current map[string]string, changes map[string]*string) map[string]string {
+	res := make(map[string]string, len(current)+len(changes))
+	for key, value := range current {
+		res[key] = value
+	}
+
+	for key, value := range changes {
+		if value == nil {
+			delete(res, key)
+		} else {
+			res[key] = *value
+		}
+	}
+	return res
+}
+
+func formatLabelChanges(changes map[string]*string) map[string]string {
+	res := make(map[string]string, len(changes))
+	for key, value := range changes {
+		if value == nil {
+			res[key] = "<removed>"
+		} else {
+			res[key] = *value
+		}
+	}
+	return res
+}
@@ -0,0 +1,108 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"simkube/lib/go/testutils"
+	"simkube/lib/go/util"
+)
+
+const testLabelScheduleFile = "../testutils/manifests/label-schedule.yml"
+
+func TestLoadLabelSchedule(t *testing.T) {
+	schedule, err := LoadLabelSchedule(testLabelScheduleFile)
+
+	assert.Nil(t, err)
+	assert.Len(t, schedule, 2)
+	assert.Equal(t, 10*time.Minute, schedule[0].After.Duration)
+	assert.Equal(t, lo.ToPtr("2"), schedule[0].Labels["simkube.io/utilization-factor"])
+	assert.Equal(t, 20*time.Minute, schedule[1].After.Duration)
+	assert.Contains(t, schedule[1].Labels, "simkube.io/utilization-factor")
+	assert.Nil(t, schedule[1].Labels["simkube.io/utilization-factor"])
+}
+
+func TestComputeLabels(t *testing.T) {
+	current := map[string]string{"foo": "bar", "baz": "qux"}
+
+	cases := map[string]struct {
+		changes  map[string]*string
+		expected map[string]string
+	}{
+		"add": {
+			changes:  map[string]*string{"new": lo.ToPtr("label")},
+			expected: map[string]string{"foo": "bar", "baz": "qux", "new": "label"},
+		},
+		"change": {
+			changes:  map[string]*string{"foo": lo.ToPtr("asdf")},
+			expected: map[string]string{"foo": "asdf", "baz": "qux"},
+		},
+		"remove": {
+			changes:  map[string]*string{"baz": nil, "missing": nil},
+			expected: map[string]string{"foo": "bar"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, computeLabels(current, tc.changes))
+			assert.Equal(t, map[string]string{"foo": "bar", "baz": "qux"}, current)
+		})
+	}
+}
+
+func TestParseNodeLabels(t *testing.T) {
+	nodeLabels, err := ParseNodeLabels("karpenter.sh/capacity-type=spot,team=ml")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"karpenter.sh/capacity-type": "spot", "team": "ml"}, nodeLabels)
+
+	for _, spec := range []string{"team", "team!=ml", "bad key=foo"} {
+		_, err = ParseNodeLabels(spec)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestApplyNodeGroupLabels(t *testing.T) {
+	cases := map[string]struct {
+		annotation string
+		expected   map[string]string
+	}{
+		"none":    {expected: map[string]string{"team": "infra"}},
+		"invalid": {annotation: "spot", expected: map[string]string{"team": "infra"}},
+		"node group": {
+			annotation: "capacity-type=spot,team=ml",
+			expected:   map[string]string{"capacity-type": "spot", "team": "infra"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(namespaceEnvKey, "test")
+			t.Setenv(nodeGroupEnvKey, "node-group")
+
+			deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "test",
+				Name:        "node-group",
+				Annotations: map[string]string{util.NodeLabelsAnnotation: tc.annotation},
+			}}
+			nlm := &LifecycleManager{
+				nodeName:      expectedName,
+				dynamicClient: dynamicfake.NewSimpleDynamicClient(scheme.Scheme, deployment),
+				logger:        testutils.GetFakeLogger(),
+			}
+			n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "infra"}}}
+
+			nlm.applyNodeGroupLabels(context.TODO(), n)
+			assert.Equal(t, tc.expected, n.ObjectMeta.Labels)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"simkube/lib/go/util"
+)
+
+// NodeLifetime returns how long the node should run before it is terminated, from its
+// simkube.io/node-lifetime-seconds annotation; if the annotation isn't set, it returns 0.
+func NodeLifetime(node *corev1.Node) (time.Duration, error) {
+	value, ok := node.ObjectMeta.Annotations[util.NodeLifetimeAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	return parseLifetimeSeconds(value)
+}
+
+func parseLifetimeSeconds(value string) (time.Duration, error) {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return 0, fmt.Errorf("%s must be a positive number of seconds, got %q", util.NodeLifetimeAnnotation, value)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// The lifetime annotation in the skeleton takes precedence over the one on the node
+// group; we copy the node group's value onto the node so that the lifetime of each
+// node is visible from the node object.
+func (self *LifecycleManager) applyNodeGroupLifetime(ctx context.Context, node *corev1.Node) {
+	if _, ok := node.ObjectMeta.Annotations[util.NodeLifetimeAnnotation]; ok {
+		return
+	}
+
+	value := self.lookupNodeGroupAnnotation(ctx, util.NodeLifetimeAnnotation)
+	if value == "" {
+		return
+	}
+	if _, err := parseLifetimeSeconds(value); err != nil {
+		self.logger.WithError(err).Warn("ignoring invalid node group lifetime")
+		return
+	}
+
+	if node.ObjectMeta.Annotations == nil {
+		node.ObjectMeta.Annotations = map[string]string{}
+	}
+	node.ObjectMeta.Annotations[util.NodeLifetimeAnnotation] = value
+}
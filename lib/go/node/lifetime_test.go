@@ -0,0 +1,95 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"simkube/lib/go/testutils"
+	"simkube/lib/go/util"
+)
+
+func TestNodeLifetime(t *testing.T) {
+	cases := map[string]struct {
+		annotations      map[string]string
+		expectedLifetime time.Duration
+		expectErr        bool
+	}{
+		"unset": {},
+		"valid": {
+			annotations:      map[string]string{util.NodeLifetimeAnnotation: "3600"},
+			expectedLifetime: time.Hour,
+		},
+		"not a number": {
+			annotations: map[string]string{util.NodeLifetimeAnnotation: "1h"},
+			expectErr:   true,
+		},
+		"zero": {
+			annotations: map[string]string{util.NodeLifetimeAnnotation: "0"},
+			expectErr:   true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}}
+			lifetime, err := NodeLifetime(n)
+			if tc.expectErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expectedLifetime, lifetime)
+			}
+		})
+	}
+}
+
+func TestApplyNodeGroupLifetime(t *testing.T) {
+	cases := map[string]struct {
+		nodeAnnotations    map[string]string
+		groupLifetime      string
+		expectedAnnotation string
+	}{
+		"from node group": {
+			groupLifetime:      "600",
+			expectedAnnotation: "600",
+		},
+		"skeleton wins": {
+			nodeAnnotations:    map[string]string{util.NodeLifetimeAnnotation: "60"},
+			groupLifetime:      "600",
+			expectedAnnotation: "60",
+		},
+		"invalid node group lifetime": {
+			groupLifetime: "-1",
+		},
+		"unset": {},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(namespaceEnvKey, "test")
+			t.Setenv(nodeGroupEnvKey, "node-group")
+
+			deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "node-group"}}
+			if tc.groupLifetime != "" {
+				deployment.ObjectMeta.Annotations = map[string]string{util.NodeLifetimeAnnotation: tc.groupLifetime}
+			}
+			nlm := &LifecycleManager{
+				nodeName:      expectedName,
+				dynamicClient: dynamicfake.NewSimpleDynamicClient(scheme.Scheme, deployment),
+				logger:        testutils.GetFakeLogger(),
+			}
+			n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: tc.nodeAnnotations}}
+
+			nlm.applyNodeGroupLifetime(context.TODO(), n)
+			assert.Equal(t, tc.expectedAnnotation, n.ObjectMeta.Annotations[util.NodeLifetimeAnnotation])
+		})
+	}
+}
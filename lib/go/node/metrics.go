@@ -0,0 +1,53 @@
+package node
+
+import (
+	"context"
+
+	coordv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coordv1client "k8s.io/client-go/kubernetes/typed/coordination/v1"
+
+	"simkube/lib/go/metrics"
+)
+
+const registeredMetric = "simkube_vnode_node_registered"
+
+//nolint:gochecknoglobals
+var leaseRenewals = metrics.Default.NewCounter(
+	"simkube_vnode_lease_renewals_total",
+	"Number of times the node lease was renewed, by result",
+	"result",
+)
+
+// The virtual-kubelet lease controller renews the lease by updating it (it only creates it if
+// it doesn't exist), so we count the updates
+type countingLeaseClient struct {
+	coordv1client.LeaseInterface
+}
+
+func (self *countingLeaseClient) Update(
+	ctx context.Context,
+	lease *coordv1.Lease,
+	opts metav1.UpdateOptions,
+) (*coordv1.Lease, error) {
+	updated, err := self.LeaseInterface.Update(ctx, lease, opts)
+	if err != nil {
+		leaseRenewals.Inc("failure")
+	} else {
+		leaseRenewals.Inc("success")
+	}
+	return updated, err //nolint:wrapcheck // this is just a passthrough
+}
+
+func (self *LifecycleManager) registerMetrics() {
+	metrics.Default.GaugeFunc(
+		registeredMetric,
+		"Whether the node has been registered with the API server and the node controller is running",
+		func() float64 {
+			if self.Ready() != nil {
+				return 0
+			}
+			return 1
+		},
+	)
+}
@@ -0,0 +1,29 @@
+package node
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	coordv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCountingLeaseClient(t *testing.T) {
+	lease := &coordv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: "test-node", Namespace: corev1.NamespaceNodeLease}}
+	client := &countingLeaseClient{fake.NewSimpleClientset().CoordinationV1().Leases(corev1.NamespaceNodeLease)}
+	successes, failures := leaseRenewals.Value("success"), leaseRenewals.Value("failure")
+
+	// The lease doesn't exist yet, so the first update fails
+	_, err := client.Update(context.TODO(), lease, metav1.UpdateOptions{})
+	assert.NotNil(t, err)
+	_, err = client.Create(context.TODO(), lease, metav1.CreateOptions{})
+	assert.Nil(t, err)
+	_, err = client.Update(context.TODO(), lease, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+
+	assert.Equal(t, successes+1, leaseRenewals.Value("success"))
+	assert.Equal(t, failures+1, leaseRenewals.Value("failure"))
+}
@@ -0,0 +1,99 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+
+	"simkube/lib/go/util"
+)
+
+const maxNodeOrdinal = 100000
+
+// NodeNameFields are the values that can be referenced in a node name template
+type NodeNameFields struct {
+	PodName   string
+	Namespace string
+	Group     string
+
+	// Suffix is the random suffix at the end of the pod name
+	Suffix string
+
+	// Ordinal is the lowest index that isn't already used by another node in the
+	// same node group; it is only computed if the template references it
+	Ordinal int
+}
+
+// RenderNodeName computes the node name from a Go template (e.g., "sim-{{.Group}}-{{.Ordinal}}"),
+// so that node names can mimic the naming scheme of the cluster being simulated.
+func RenderNodeName(ctx context.Context, k8sClient kubernetes.Interface, nameTemplate, podName string) (string, error) {
+	tmpl, err := template.New("node-name").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("could not parse node name template: %w", err)
+	}
+
+	fields := NodeNameFields{
+		PodName:   podName,
+		Namespace: os.Getenv(namespaceEnvKey),
+		Group:     os.Getenv(nodeGroupEnvKey),
+		Suffix:    podName[strings.LastIndex(podName, "-")+1:],
+	}
+	if !strings.Contains(nameTemplate, ".Ordinal") {
+		return renderNodeName(tmpl, fields)
+	}
+
+	existing, err := listNodeGroupNodeNames(ctx, k8sClient)
+	if err != nil {
+		return "", err
+	}
+
+	// Nodes that start at the same time can race for the same ordinal, so templates that
+	// must be unique during a large scale-up should use the pod name suffix instead
+	for fields.Ordinal = 0; fields.Ordinal < maxNodeOrdinal; fields.Ordinal++ {
+		name, err := renderNodeName(tmpl, fields)
+		if err != nil || !existing[name] {
+			return name, err
+		}
+	}
+	return "", fmt.Errorf("could not find an unused node ordinal")
+}
+
+func renderNodeName(tmpl *template.Template, fields NodeNameFields) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, fields); err != nil {
+		return "", fmt.Errorf("could not render node name template: %w", err)
+	}
+
+	name := buf.String()
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", fmt.Errorf("invalid node name %q: %s", name, strings.Join(errs, "; "))
+	}
+	return name, nil
+}
+
+func listNodeGroupNodeNames(ctx context.Context, k8sClient kubernetes.Interface) (map[string]bool, error) {
+	selector := fmt.Sprintf(
+		"%s=%s,%s=%s",
+		util.NodeGroupNamespaceLabel,
+		os.Getenv(namespaceEnvKey),
+		util.NodeGroupNameLabel,
+		os.Getenv(nodeGroupEnvKey),
+	)
+	nodes, err := k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("could not list nodes: %w", err)
+	}
+
+	names := map[string]bool{}
+	for _, n := range nodes.Items {
+		names[n.ObjectMeta.Name] = true
+	}
+	return names, nil
+}
@@ -0,0 +1,71 @@
+package node
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"simkube/lib/go/util"
+)
+
+func TestRenderNodeName(t *testing.T) {
+	cases := map[string]struct {
+		template     string
+		expectedName string
+		expectErr    bool
+	}{
+		"pod name": {
+			template:     "{{.PodName}}",
+			expectedName: "the-group-abc12",
+		},
+		"group and suffix": {
+			template:     "sim-{{.Group}}-{{.Suffix}}",
+			expectedName: "sim-the-group-abc12",
+		},
+		"ordinal": {
+			template:     "sim-{{.Group}}-{{.Ordinal}}",
+			expectedName: "sim-the-group-2",
+		},
+		"invalid template": {
+			template:  "sim-{{.Group",
+			expectErr: true,
+		},
+		"unknown field": {
+			template:  "sim-{{.Zone}}",
+			expectErr: true,
+		},
+		"invalid node name": {
+			template:  "Sim_{{.Group}}",
+			expectErr: true,
+		},
+	}
+
+	t.Setenv(namespaceEnvKey, "the-namespace")
+	t.Setenv(nodeGroupEnvKey, "the-group")
+	nodeGroupLabels := map[string]string{
+		util.NodeGroupNamespaceLabel: "the-namespace",
+		util.NodeGroupNameLabel:      "the-group",
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			k8sClient := fake.NewSimpleClientset(
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "sim-the-group-0", Labels: nodeGroupLabels}},
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "sim-the-group-1", Labels: nodeGroupLabels}},
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "sim-the-group-3", Labels: nodeGroupLabels}},
+			)
+
+			name, err := RenderNodeName(context.TODO(), k8sClient, tc.template, "the-group-abc12")
+			if tc.expectErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expectedName, name)
+			}
+		})
+	}
+}
@@ -2,18 +2,32 @@ package node
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/netip"
 	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/samber/lo"
 	log "github.com/sirupsen/logrus"
 	"github.com/virtual-kubelet/virtual-kubelet/node"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	coordv1client "k8s.io/client-go/kubernetes/typed/coordination/v1"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/yaml"
 
+	"simkube/lib/go/audit"
+	"simkube/lib/go/dra"
 	"simkube/lib/go/k8s"
 	"simkube/lib/go/util"
 )
@@ -45,99 +59,508 @@ const (
 	defaultTopologyRegion = "us-east-1"
 	defaultTopologyZone   = "us-east-1a"
 	defaultKubeVersion    = "v1.27.1"
+	defaultMaxPods        = 110
+
+	faultInjectionReason = "SimkubeFaultInjection"
+)
+
+var (
+	ErrInvalidConditionStatus = util.WithKind(util.ErrValidation, errors.New("invalid condition status"))
+	ErrInvalidAllocatable     = util.WithKind(util.ErrValidation, errors.New("invalid allocatable resources"))
+	ErrInvalidNodeTemplate    = util.WithKind(util.ErrValidation, errors.New("invalid node template name"))
+
+	errNodeNotRunning = util.WithKind(util.ErrRetriable, errors.New("node controller is not running"))
 )
 
 type LifecycleManagerI interface {
 	CreateNodeObject(string) (*corev1.Node, error)
 	Run(context.Context, context.CancelCauseFunc, *corev1.Node)
 	DeleteNode(context.CancelFunc) error
+	SetCondition(context.Context, corev1.NodeConditionType, corev1.ConditionStatus, string, string) error
+	SetAllocatable(context.Context, map[corev1.ResourceName]string) error
+	SetLabels(context.Context, map[string]*string) error
+	SetUnschedulable(context.Context, bool) error
+	PauseHeartbeats(time.Duration) error
+	Ready() error
+	Healthy() error
+}
+
+// Options controls the behaviour of the node lifecycle manager; the zero value
+// uses the virtual-kubelet defaults for everything
+type Options struct {
+	// LeaseDurationSeconds is the duration of the node lease; if 0, the
+	// virtual-kubelet default (40s) is used
+	LeaseDurationSeconds int32
+
+	// LeaseRenewInterval is how often the node lease is renewed; if 0, the lease
+	// is renewed at a fixed fraction of the lease duration
+	LeaseRenewInterval time.Duration
+
+	// SkipDrain disables cordoning the node and deleting its pods before the node
+	// object is removed on shutdown
+	SkipDrain bool
+
+	// SkeletonReloadInterval is how often the skeleton file is checked for changes;
+	// if 0, the skeleton is only read at startup
+	SkeletonReloadInterval time.Duration
+
+	// NodeTemplate selects which skeleton to use when the skeleton path is a directory;
+	// if empty, the template is read from the node group's annotations instead
+	NodeTemplate string
+
+	// AllocatableSchedule is a list of changes to the node's allocatable resources that
+	// are applied at fixed offsets from when the node starts
+	AllocatableSchedule []AllocatableChange
+
+	// LabelSchedule is a list of changes to the node's labels that are applied at fixed
+	// offsets from when the node starts
+	LabelSchedule []LabelChange
+
+	// Zones is the set of zones that virtual nodes are distributed across, using
+	// the specified ZonePolicy; if empty, all nodes are placed in the default zone
+	Zones      []Zone
+	ZonePolicy string
+
+	// KubeletVersion overrides the kubelet version reported by the node (which otherwise
+	// comes from the skeleton or the API server version)
+	KubeletVersion string
+
+	// RetainNode leaves the node object (and its pods) in place on shutdown, so that a
+	// restarted virtual node can pick up where it left off
+	RetainNode bool
+
+	// VirtualNodeTaint replaces the default simkube.io/virtual-node=true:NoExecute taint
+	// that is applied to the node; if DisableVirtualNodeTaint is set, no taint is applied
+	VirtualNodeTaint        *corev1.Taint
+	DisableVirtualNodeTaint bool
+
+	// KwokCompat also gives the node kwok's kwok.x-k8s.io/node annotation and taint, for
+	// tools that expect kwok nodes
+	KwokCompat bool
+
+	// StartupDelay is how long the node stays NotReady after it is registered; if
+	// StartupDelayMax is larger, the delay is chosen at random between the two
+	StartupDelay    time.Duration
+	StartupDelayMax time.Duration
+
+	// PodName is the name of the pod running the virtual node, which can be different
+	// from the node name; it is recorded on the node object for the cloud provider
+	PodName string
+
+	// NodeCIDR is the range that node InternalIPs are allocated from; if it is the zero
+	// value, no InternalIP is assigned (unless one is set in the skeleton)
+	NodeCIDR netip.Prefix
+
+	// MaxPods is the pod capacity of the node if it isn't specified in the skeleton; if 0,
+	// the kubelet default (110) is used
+	MaxPods int64
+
+	// DisableNodeReconcile stops the node manager from recreating the node object if it is
+	// deleted externally, or reverting external changes to simkube-owned labels and taints
+	DisableNodeReconcile bool
+
+	// KubeletPort is the kubelet endpoint reported in the node status; if 0, the
+	// standard kubelet port (10250) is used
+	KubeletPort int32
+
+	// GPULabel is the label that records the GPU type of nodes with GPUs; it should match
+	// the GPU label used by the cloud provider.  If empty, simkube.io/gpu-type is used
+	GPULabel string
+
+	// ProviderIDTemplate renders the node's provider ID (see ProviderIDFields); if nil,
+	// the provider ID is simkube://<node name>
+	ProviderIDTemplate *template.Template
+
+	// NodeGroupResource is the kind of object that owns this virtual node (whose name is
+	// given by the POD_OWNER environment variable); node group annotations are read from
+	// it.  If empty, the node group is a Deployment.
+	NodeGroupResource schema.GroupVersionResource
+
+	// DRADevices are the devices that the node advertises in a ResourceSlice for Dynamic
+	// Resource Allocation; if nil, no ResourceSlice is published
+	DRADevices *dra.DeviceConfig
 }
 
 type LifecycleManager struct {
-	nodeName  string
-	k8sClient kubernetes.Interface
-	logger    *log.Entry
+	nodeName      string
+	k8sClient     kubernetes.Interface
+	dynamicClient dynamic.Interface
+	opts          Options
+	logger        *log.Entry
+
+	skeletonFile  string
+	skeletonBytes []byte
+	zone          *Zone
+	readyDelay    time.Duration
+
+	// The provider and current node object are set once the node controller
+	// starts running; they are used to push runtime changes to the node status.
+	// The desired node object is what we (re-)create if the node is deleted.
+	mutex    sync.Mutex
+	desired  *corev1.Node
+	provider *nodeProvider
+	nodeCtrl util.ControllerStatus
+	node     *corev1.Node
+	recorder record.EventRecorder
 }
 
-func NewLifecycleManager(nodeName string, k8sClient kubernetes.Interface) *LifecycleManager {
+func NewLifecycleManager(
+	nodeName string,
+	k8sClient kubernetes.Interface,
+	dynamicClient dynamic.Interface,
+	opts Options,
+) *LifecycleManager {
 	return &LifecycleManager{
-		nodeName:  nodeName,
-		k8sClient: k8sClient,
-		logger:    util.GetLogger(nodeName),
+		nodeName:      nodeName,
+		k8sClient:     k8sClient,
+		dynamicClient: dynamicClient,
+		opts:          opts,
+		logger:        util.GetLogger(nodeName),
 	}
 }
 
-func (self *LifecycleManager) CreateNodeObject(nodeSkeletonFile string) (*corev1.Node, error) {
-	node, err := parseSkeletonNode(nodeSkeletonFile)
+func (self *LifecycleManager) CreateNodeObject(nodeSkeletonPath string) (*corev1.Node, error) {
+	nodeSkeletonFile, err := self.resolveSkeletonFile(context.Background(), nodeSkeletonPath)
 	if err != nil {
 		return nil, err
 	}
 
+	nodeBytes, err := readSkeletonFile(nodeSkeletonFile)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := parseSkeletonNode(nodeSkeletonFile, nodeBytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSkeleton(nodeSkeletonFile, node, self.maxPods()); err != nil {
+		return nil, err
+	}
+	self.skeletonFile = nodeSkeletonFile
+	self.skeletonBytes = nodeBytes
+
+	claim, err := self.lookupNodeClaim(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	if zone, ok := self.selectZone(context.Background()); ok {
+		self.logger.Infof("placing node in zone %s/%s", zone.Region, zone.Name)
+		self.zone = &zone
+		applyZoneLabels(node, zone)
+	}
+
 	setNodeNameAndID(self.nodeName, node)
+	if self.opts.PodName != "" {
+		if node.ObjectMeta.Annotations == nil {
+			node.ObjectMeta.Annotations = map[string]string{}
+		}
+		node.ObjectMeta.Annotations[util.PodNameAnnotation] = self.opts.PodName
+	}
+	self.applyNodeGroupLifetime(context.Background(), node)
 	setNodeStatus(node)
-	applyStandardNodeLabelsAndTaints(node)
-	configureNodeResources(node)
+	self.setNodeAddresses(context.Background(), node)
+	if self.readyDelay = self.startupDelay(); self.readyDelay > 0 {
+		markNodeNotReady(node)
+	}
+	if claim != nil {
+		applyNodeClaim(node, claim)
+	}
+	self.applyNodeGroupInstanceType(context.Background(), node)
+	self.applyNodeGroupLabels(context.Background(), node)
+	applyStandardNodeLabelsAndTaints(node, self.virtualNodeTaint())
+	if self.opts.KwokCompat {
+		applyKwokCompat(node)
+	}
+	configureNodeResources(node, self.maxPods())
+	self.setGPULabel(context.Background(), node)
+	if err := self.setProviderID(node); err != nil {
+		return nil, err
+	}
+	applyNodeClaimProviderID(node, claim)
 
-	if kubeVersion, err := getKubeVersion(self.k8sClient); err != nil {
-		self.logger.WithError(err).Warn("could not determine Kubernetes version, using default")
-		node.Status.NodeInfo.KubeletVersion = defaultKubeVersion
-	} else {
-		node.Status.NodeInfo.KubeletVersion = kubeVersion
+	// The kubelet version can be set explicitly (either by the user or in the skeleton), so
+	// that mixed-version node pools can be simulated; otherwise we match the API server
+	if self.opts.KubeletVersion != "" {
+		node.Status.NodeInfo.KubeletVersion = self.opts.KubeletVersion
+	} else if node.Status.NodeInfo.KubeletVersion == "" {
+		if kubeVersion, err := getKubeVersion(self.k8sClient); err != nil {
+			self.logger.WithError(err).Warn("could not determine Kubernetes version, using default")
+			node.Status.NodeInfo.KubeletVersion = defaultKubeVersion
+		} else {
+			node.Status.NodeInfo.KubeletVersion = kubeVersion
+		}
 	}
+	setNodeSystemInfo(node)
 
+	self.mutex.Lock()
+	self.desired = node.DeepCopy()
+	self.mutex.Unlock()
 	return node, nil
 }
 
+func (self *LifecycleManager) maxPods() int64 {
+	if self.opts.MaxPods > 0 {
+		return self.opts.MaxPods
+	}
+	return defaultMaxPods
+}
+
+func (self *LifecycleManager) virtualNodeTaint() *corev1.Taint {
+	if self.opts.DisableVirtualNodeTaint {
+		return nil
+	} else if self.opts.VirtualNodeTaint != nil {
+		return self.opts.VirtualNodeTaint
+	}
+	return defaultVirtualNodeTaint()
+}
+
+func defaultVirtualNodeTaint() *corev1.Taint {
+	return &corev1.Taint{
+		Key:    virtualNodeTaintKey,
+		Value:  virtualNodeTaintValue,
+		Effect: corev1.TaintEffectNoExecute,
+	}
+}
+
 func (self *LifecycleManager) Run(ctx context.Context, cancel context.CancelCauseFunc, n *corev1.Node) {
 	self.logger.Info("Starting node manager...")
 
-	leaseClient := self.k8sClient.CoordinationV1().Leases(corev1.NamespaceNodeLease)
+	self.mutex.Lock()
+	self.provider = newNodeProvider()
+	self.node = n.DeepCopy()
+	self.recorder = self.makeEventRecorder()
+	self.mutex.Unlock()
+
+	if err := self.registerNode(ctx); err != nil {
+		self.logger.WithError(err).Warn("could not apply node object, node controller will register it instead")
+	}
+	audit.Record(audit.Event{Action: audit.NodeCreated, Object: self.nodeName, Details: map[string]string{
+		"instanceType": n.Labels[nodeInstanceTypeLabel],
+		"zone":         n.Labels[topologyZoneLabel],
+	}})
+
+	leaseClient := &countingLeaseClient{self.k8sClient.CoordinationV1().Leases(corev1.NamespaceNodeLease)}
+	nodeCtrlOpts := []node.NodeControllerOpt{self.leaseOpt(leaseClient)}
+	if !self.opts.DisableNodeReconcile {
+		nodeCtrlOpts = append(nodeCtrlOpts, node.WithNodeStatusUpdateErrorHandler(self.handleNodeStatusUpdateError))
+	}
 	nodeCtrl, err := node.NewNodeController(
-		node.NaiveNodeProvider{},
+		self.provider,
 		n,
 		self.k8sClient.CoreV1().Nodes(),
-		node.WithNodeEnableLeaseV1(leaseClient, 0),
+		nodeCtrlOpts...,
 	)
 	if err != nil {
 		cancel(fmt.Errorf("could not create node controller: %w", err))
 		return
 	}
+	self.mutex.Lock()
+	self.nodeCtrl = nodeCtrl
+	self.mutex.Unlock()
+	self.registerMetrics()
 
 	go func() {
 		if err := nodeCtrl.Run(ctx); err != nil {
 			cancel(fmt.Errorf("could not run node controller: %w", err))
 		}
 	}()
+
+	if !self.opts.DisableNodeReconcile {
+		self.watchNode(ctx)
+	}
+
+	if self.opts.SkeletonReloadInterval > 0 && self.skeletonFile != "" {
+		go self.watchSkeleton(ctx)
+	}
+
+	if len(self.opts.AllocatableSchedule) > 0 {
+		go self.runAllocatableSchedule(ctx)
+	}
+
+	if len(self.opts.LabelSchedule) > 0 {
+		go self.runLabelSchedule(ctx)
+	}
+
+	if self.opts.DRADevices != nil {
+		go self.publishResourceSlice(ctx)
+	}
+
+	if self.readyDelay > 0 {
+		go self.markReadyAfter(ctx, self.readyDelay)
+	}
 	self.logger.Info("Node manager running!")
 }
 
+// SetCondition overrides the status of the given node condition (adding the condition if
+// it is not present) and pushes the updated status to the API server.  This is used to
+// inject faults like MemoryPressure or NotReady into a running virtual node.
+func (self *LifecycleManager) SetCondition(
+	ctx context.Context,
+	condType corev1.NodeConditionType,
+	status corev1.ConditionStatus,
+	reason, message string,
+) error {
+	switch status {
+	case corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionUnknown:
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidConditionStatus, status)
+	}
+
+	if reason == "" {
+		reason = faultInjectionReason
+	}
+
+	self.logger.Infof("setting node condition %s=%s (reason: %s)", condType, status, reason)
+	if err := self.updateNodeStatus(ctx, func(n *corev1.Node) {
+		setNodeCondition(n, condType, status, reason, message)
+	}); err != nil {
+		return err
+	}
+
+	audit.Record(audit.Event{
+		Action:  audit.NodeConditionChanged,
+		Object:  self.nodeName,
+		Reason:  reason,
+		Details: map[string]string{"type": string(condType), "status": string(status), "message": message},
+	})
+	return nil
+}
+
+func (self *LifecycleManager) updateNodeStatus(ctx context.Context, mutate func(*corev1.Node)) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.provider == nil {
+		return errNodeNotRunning
+	}
+
+	n := self.node.DeepCopy()
+	mutate(n)
+	if err := self.provider.UpdateStatus(ctx, n); err != nil {
+		return fmt.Errorf("could not update node status: %w", err)
+	}
+	self.node = n
+	return nil
+}
+
+func (self *LifecycleManager) leaseOpt(leaseClient coordv1client.LeaseInterface) node.NodeControllerOpt {
+	if self.opts.LeaseRenewInterval == 0 {
+		return node.WithNodeEnableLeaseV1(leaseClient, self.opts.LeaseDurationSeconds)
+	}
+	return node.WithNodeEnableLeaseV1WithRenewInterval(
+		leaseClient,
+		self.opts.LeaseDurationSeconds,
+		self.opts.LeaseRenewInterval,
+	)
+}
+
 func (self *LifecycleManager) DeleteNode(stop context.CancelFunc) error {
 	stop()
-	if err := self.k8sClient.CoreV1().Nodes().Delete(
-		context.Background(),
-		self.nodeName,
-		metav1.DeleteOptions{},
-	); err != nil {
+	ctx := context.Background()
+
+	if self.opts.RetainNode {
+		self.logger.Info("retaining node object on exit")
+		return nil
+	}
+
+	if !self.opts.SkipDrain {
+		if err := self.drainNode(ctx); err != nil {
+			return fmt.Errorf("drain node failed: %w", err)
+		}
+	}
+
+	if err := k8s.Retry(func() error {
+		//nolint:wrapcheck // wrapped below
+		return self.k8sClient.CoreV1().Nodes().Delete(ctx, self.nodeName, metav1.DeleteOptions{})
+	}); err != nil && !apierrors.IsNotFound(err) {
 		return fmt.Errorf("delete node failed: %w", err)
 	}
 
+	audit.Record(audit.Event{Action: audit.NodeDeleted, Object: self.nodeName})
 	return nil
 }
 
-func parseSkeletonNode(nodeSkeletonFile string) (*corev1.Node, error) {
-	var skel corev1.Node
+// By the time we drain the node, the pod controller has already shut down, so there's
+// nothing left to acknowledge a graceful deletion; thus we force-delete all the pods
+// that are bound to this node so they don't hang around waiting for the garbage collector
+func (self *LifecycleManager) drainNode(ctx context.Context) error {
+	self.logger.Info("draining node")
+	if err := self.SetUnschedulable(ctx, true); err != nil {
+		return err
+	}
+
+	var pods *corev1.PodList
+	if err := k8s.Retry(func() (err error) {
+		pods, err = self.k8sClient.CoreV1().Pods(corev1.NamespaceAll).List(ctx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("spec.nodeName", self.nodeName).String(),
+		})
+		//nolint:wrapcheck // wrapped below
+		return err
+	}); err != nil {
+		return fmt.Errorf("could not list pods: %w", err)
+	}
+
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != self.nodeName {
+			continue
+		}
+
+		podName := k8s.NamespacedNameFromObjectMeta(pod.ObjectMeta)
+		self.logger.Infof("deleting pod %s", podName)
+		if err := k8s.Retry(func() error {
+			//nolint:wrapcheck // wrapped below
+			return self.k8sClient.CoreV1().Pods(pod.Namespace).Delete(
+				ctx,
+				pod.Name,
+				metav1.DeleteOptions{GracePeriodSeconds: lo.ToPtr(int64(0))},
+			)
+		}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("could not delete pod %s: %w", podName, err)
+		}
+	}
+
+	return nil
+}
+
+func readSkeletonFile(nodeSkeletonFile string) ([]byte, error) {
 	nodeBytes, err := os.ReadFile(nodeSkeletonFile)
 	if err != nil {
 		return nil, fmt.Errorf("could not open %s: %w", nodeSkeletonFile, err)
 	}
+	return nodeBytes, nil
+}
 
-	if err = yaml.UnmarshalStrict(nodeBytes, &skel); err != nil {
+func parseSkeletonNode(nodeSkeletonFile string, nodeBytes []byte) (*corev1.Node, error) {
+	var skel corev1.Node
+	if err := yaml.UnmarshalStrict(nodeBytes, &skel); err != nil {
 		return nil, fmt.Errorf("could not parse %s: %w", nodeSkeletonFile, err)
 	}
 
 	return &skel, nil
 }
 
+// ParseTaint parses a taint of the form <key>[=<value>]:<effect>
+func ParseTaint(taintSpec string) (*corev1.Taint, error) {
+	keyValue, effect, ok := strings.Cut(taintSpec, ":")
+	if !ok {
+		return nil, fmt.Errorf("taint %s has no effect", taintSpec)
+	}
+
+	switch corev1.TaintEffect(effect) {
+	case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+	default:
+		return nil, fmt.Errorf("invalid taint effect %s", effect)
+	}
+
+	key, value, _ := strings.Cut(keyValue, "=")
+	if key == "" {
+		return nil, fmt.Errorf("taint %s has no key", taintSpec)
+	}
+	return &corev1.Taint{Key: key, Value: value, Effect: corev1.TaintEffect(effect)}, nil
+}
+
 func setNodeNameAndID(nodeName string, node *corev1.Node) {
 	node.ObjectMeta.Name = nodeName
 	node.Spec.ProviderID = k8s.ProviderID(nodeName)
@@ -150,8 +573,8 @@ func setNodeStatus(node *corev1.Node) {
 			Status:             corev1.ConditionTrue,
 			LastHeartbeatTime:  metav1.Now(),
 			LastTransitionTime: metav1.Now(),
-			Reason:             "KubeletReady",
-			Message:            "kubelet is ready.",
+			Reason:             readyReason,
+			Message:            readyMessage,
 		},
 		{
 			Type:               "OutOfDisk",
@@ -181,7 +604,38 @@ func setNodeStatus(node *corev1.Node) {
 	node.Status.Phase = corev1.NodeRunning
 }
 
-func applyStandardNodeLabelsAndTaints(node *corev1.Node) {
+func setNodeCondition(
+	node *corev1.Node,
+	condType corev1.NodeConditionType,
+	status corev1.ConditionStatus,
+	reason, message string,
+) {
+	now := metav1.Now()
+	for i := range node.Status.Conditions {
+		cond := &node.Status.Conditions[i]
+		if cond.Type == condType {
+			if cond.Status != status {
+				cond.LastTransitionTime = now
+			}
+			cond.Status = status
+			cond.Reason = reason
+			cond.Message = message
+			cond.LastHeartbeatTime = now
+			return
+		}
+	}
+
+	node.Status.Conditions = append(node.Status.Conditions, corev1.NodeCondition{
+		Type:               condType,
+		Status:             status,
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func applyStandardNodeLabelsAndTaints(node *corev1.Node, virtualNodeTaint *corev1.Taint) {
 	defaultLabels := map[string]string{
 		nodeTypeLabel:                nodeType,
 		kubernetesArchLabel:          defaultArch,
@@ -197,30 +651,45 @@ func applyStandardNodeLabelsAndTaints(node *corev1.Node) {
 	}
 	node.ObjectMeta.Labels = lo.Assign(defaultLabels, node.ObjectMeta.Labels)
 
-	defaultTaints := []corev1.Taint{
-		{
-			Key:    virtualNodeTaintKey,
-			Value:  virtualNodeTaintValue,
-			Effect: corev1.TaintEffectNoExecute,
-		},
-	}
-	if node.Spec.Taints != nil {
-		node.Spec.Taints = append(node.Spec.Taints, defaultTaints...)
-	} else {
-		node.Spec.Taints = defaultTaints
+	// If the skeleton already has a taint with the same key, the skeleton wins
+	if virtualNodeTaint != nil && !lo.ContainsBy(node.Spec.Taints, func(t corev1.Taint) bool {
+		return t.Key == virtualNodeTaint.Key
+	}) {
+		node.Spec.Taints = append(node.Spec.Taints, *virtualNodeTaint)
 	}
 }
 
-func configureNodeResources(node *corev1.Node) {
+func configureNodeResources(node *corev1.Node, maxPods int64) {
 	defaultCapacity := map[corev1.ResourceName]resource.Quantity{
 		corev1.ResourceCPU:              resource.MustParse("1"),
 		corev1.ResourceMemory:           resource.MustParse("1Gi"),
 		corev1.ResourceEphemeralStorage: resource.MustParse("1024Gi"),
-		corev1.ResourcePods:             resource.MustParse("110"),
+		corev1.ResourcePods:             *resource.NewQuantity(maxPods, resource.DecimalSI),
 	}
 
 	node.Status.Capacity = lo.Assign(defaultCapacity, node.Status.Capacity)
-	node.Status.Allocatable = lo.Assign(node.Status.Capacity, node.Status.Allocatable)
+
+	// Anything that's only listed as allocatable (e.g., an extended resource) has to be
+	// present in the node capacity as well, otherwise the node is invalid
+	for name, q := range node.Status.Allocatable {
+		if _, ok := node.Status.Capacity[name]; !ok {
+			node.Status.Capacity[name] = q.DeepCopy()
+		}
+	}
+
+	// Like the real kubelet, memory that is reserved for hugepages is not allocatable as
+	// regular memory (unless the user explicitly told us what allocatable memory is)
+	allocatable := lo.Assign(node.Status.Capacity)
+	if _, ok := node.Status.Allocatable[corev1.ResourceMemory]; !ok {
+		mem := node.Status.Capacity[corev1.ResourceMemory].DeepCopy()
+		for name, q := range node.Status.Capacity {
+			if k8s.IsHugePageResourceName(name) {
+				mem.Sub(q)
+			}
+		}
+		allocatable[corev1.ResourceMemory] = mem
+	}
+	node.Status.Allocatable = lo.Assign(allocatable, node.Status.Allocatable)
 }
 
 func getKubeVersion(k8sClient kubernetes.Interface) (string, error) {
@@ -1,13 +1,22 @@
 package node
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 
+	"simkube/lib/go/k8s"
 	"simkube/lib/go/testutils"
 )
 
@@ -30,7 +39,11 @@ var (
 )
 
 func TestCreateNodeObject(t *testing.T) {
-	nlm := &LifecycleManager{expectedName, fake.NewSimpleClientset(), testutils.GetFakeLogger()}
+	nlm := &LifecycleManager{
+		nodeName:  expectedName,
+		k8sClient: fake.NewSimpleClientset(),
+		logger:    testutils.GetFakeLogger(),
+	}
 	n, err := nlm.CreateNodeObject(testSkelFile)
 
 	assert.Nil(t, err)
@@ -60,3 +73,202 @@ func TestCreateNodeObject(t *testing.T) {
 
 	assert.Len(t, n.Status.Conditions, expectedConditionCount)
 }
+
+func TestSetNodeCondition(t *testing.T) {
+	n := &corev1.Node{}
+	setNodeStatus(n)
+
+	setNodeCondition(n, corev1.NodeMemoryPressure, corev1.ConditionTrue, faultInjectionReason, "")
+	setNodeCondition(n, corev1.NodeNetworkUnavailable, corev1.ConditionTrue, faultInjectionReason, "")
+
+	assert.Len(t, n.Status.Conditions, expectedConditionCount+1)
+	for _, cond := range n.Status.Conditions {
+		switch cond.Type {
+		case corev1.NodeMemoryPressure, corev1.NodeNetworkUnavailable:
+			assert.Equal(t, corev1.ConditionTrue, cond.Status)
+			assert.Equal(t, faultInjectionReason, cond.Reason)
+		}
+	}
+}
+
+func TestSetConditionNotRunning(t *testing.T) {
+	nlm := &LifecycleManager{nodeName: expectedName, logger: testutils.GetFakeLogger()}
+
+	err := nlm.SetCondition(context.TODO(), corev1.NodeReady, corev1.ConditionFalse, "", "")
+	assert.ErrorIs(t, err, errNodeNotRunning)
+
+	err = nlm.SetCondition(context.TODO(), corev1.NodeReady, "asdf", "", "")
+	assert.ErrorIs(t, err, ErrInvalidConditionStatus)
+}
+
+func TestDeleteNode(t *testing.T) {
+	cases := map[string]struct {
+		skipDrain     bool
+		retainNode    bool
+		expectedNodes int
+		expectedPods  int
+	}{
+		"drain":       {expectedPods: 1},
+		"skip drain":  {skipDrain: true, expectedPods: 2},
+		"retain node": {retainNode: true, expectedNodes: 1, expectedPods: 2},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			k8sClient := fake.NewSimpleClientset(
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: expectedName}},
+				&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod1"},
+					Spec:       corev1.PodSpec{NodeName: expectedName},
+				},
+				&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod2"},
+					Spec:       corev1.PodSpec{NodeName: "some-other-node"},
+				},
+			)
+			nlm := &LifecycleManager{
+				nodeName:  expectedName,
+				k8sClient: k8sClient,
+				opts:      Options{SkipDrain: tc.skipDrain, RetainNode: tc.retainNode},
+				logger:    testutils.GetFakeLogger(),
+			}
+
+			err := nlm.DeleteNode(func() {})
+			assert.Nil(t, err)
+
+			nodes, _ := k8sClient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+			assert.Len(t, nodes.Items, tc.expectedNodes)
+
+			pods, _ := k8sClient.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{})
+			assert.Len(t, pods.Items, tc.expectedPods)
+		})
+	}
+}
+
+func TestDeleteNodeRetries(t *testing.T) {
+	k8sClient := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: expectedName}})
+	failures := 2
+	k8sClient.PrependReactor("delete", "nodes", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if failures > 0 {
+			failures -= 1
+			return true, nil, apierrors.NewTooManyRequests("slow down", 1)
+		}
+		return false, nil, nil
+	})
+	nlm := &LifecycleManager{
+		nodeName:  expectedName,
+		k8sClient: k8sClient,
+		opts:      Options{SkipDrain: true},
+		logger:    testutils.GetFakeLogger(),
+	}
+
+	err := nlm.DeleteNode(func() {})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, failures)
+
+	nodes, _ := k8sClient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	assert.Len(t, nodes.Items, 0)
+}
+
+func TestConfigureNodeResourcesExtended(t *testing.T) {
+	n := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{}},
+		Status: corev1.NodeStatus{
+			Capacity: corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse("8Gi"),
+				"hugepages-2Mi":       resource.MustParse("2Gi"),
+			},
+			Allocatable: corev1.ResourceList{
+				k8s.NvidiaGPUResource: resource.MustParse("4"),
+			},
+		},
+	}
+
+	configureNodeResources(n, defaultMaxPods)
+
+	assert.True(t, resource.MustParse("4").Equal(n.Status.Capacity[k8s.NvidiaGPUResource]))
+	assert.True(t, resource.MustParse("4").Equal(n.Status.Allocatable[k8s.NvidiaGPUResource]))
+	assert.True(t, resource.MustParse("2Gi").Equal(n.Status.Allocatable["hugepages-2Mi"]))
+	assert.True(t, resource.MustParse("6Gi").Equal(n.Status.Allocatable[corev1.ResourceMemory]))
+}
+
+func TestCreateNodeObjectKubeletVersion(t *testing.T) {
+	cases := map[string]struct {
+		skelVersion string
+		optVersion  string
+		expected    string
+	}{
+		"skeleton": {skelVersion: "v1.25.0", expected: "v1.25.0"},
+		"override": {skelVersion: "v1.25.0", optVersion: "v1.26.3", expected: "v1.26.3"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			skelFile := filepath.Join(t.TempDir(), "node.yml")
+			skel := fmt.Sprintf("status:\n  nodeInfo:\n    kubeletVersion: %s\n", tc.skelVersion)
+			if err := os.WriteFile(skelFile, []byte(skel), 0600); err != nil {
+				panic(err)
+			}
+
+			nlm := &LifecycleManager{
+				nodeName:  expectedName,
+				k8sClient: fake.NewSimpleClientset(),
+				opts:      Options{KubeletVersion: tc.optVersion},
+				logger:    testutils.GetFakeLogger(),
+			}
+			n, err := nlm.CreateNodeObject(skelFile)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, n.Status.NodeInfo.KubeletVersion)
+		})
+	}
+}
+
+func TestParseTaint(t *testing.T) {
+	taint, err := ParseTaint("foo=bar:NoSchedule")
+	assert.Nil(t, err)
+	assert.Equal(t, &corev1.Taint{Key: "foo", Value: "bar", Effect: corev1.TaintEffectNoSchedule}, taint)
+
+	taint, err = ParseTaint("foo:NoExecute")
+	assert.Nil(t, err)
+	assert.Equal(t, &corev1.Taint{Key: "foo", Effect: corev1.TaintEffectNoExecute}, taint)
+
+	for _, spec := range []string{"foo=bar", "foo=bar:Sometimes", "=bar:NoSchedule"} {
+		_, err = ParseTaint(spec)
+		assert.NotNil(t, err, spec)
+	}
+}
+
+func TestVirtualNodeTaint(t *testing.T) {
+	customTaint := &corev1.Taint{Key: "foo", Effect: corev1.TaintEffectNoSchedule}
+	cases := map[string]struct {
+		opts       Options
+		skelTaints []corev1.Taint
+		expected   []corev1.Taint
+	}{
+		"default": {
+			expected: []corev1.Taint{{
+				Key:    virtualNodeTaintKey,
+				Value:  virtualNodeTaintValue,
+				Effect: corev1.TaintEffectNoExecute,
+			}},
+		},
+		"custom":   {opts: Options{VirtualNodeTaint: customTaint}, expected: []corev1.Taint{*customTaint}},
+		"disabled": {opts: Options{DisableVirtualNodeTaint: true}},
+		"skeleton override": {
+			opts:       Options{VirtualNodeTaint: customTaint},
+			skelTaints: []corev1.Taint{{Key: "foo", Effect: corev1.TaintEffectPreferNoSchedule}},
+			expected:   []corev1.Taint{{Key: "foo", Effect: corev1.TaintEffectPreferNoSchedule}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			nlm := &LifecycleManager{opts: tc.opts}
+			n := &corev1.Node{Spec: corev1.NodeSpec{Taints: tc.skelTaints}}
+
+			applyStandardNodeLabelsAndTaints(n, nlm.virtualNodeTaint())
+			assert.Equal(t, tc.expected, n.Spec.Taints)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package node
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const instanceIDHexLen = 17
+
+// ProviderIDFields are the values that can be referenced in a provider ID template
+type ProviderIDFields struct {
+	NodeName     string
+	PodName      string
+	Region       string
+	Zone         string
+	InstanceType string
+
+	// InstanceID is a stable, EC2-style instance ID (e.g., i-0123456789abcdef0) derived
+	// from the node name
+	InstanceID string
+}
+
+// ParseProviderIDTemplate parses a Go template for the node's provider ID, e.g.
+// "aws:///{{.Zone}}/{{.InstanceID}}"; an empty string returns nil, which uses the
+// default simkube://<node name> provider ID.
+func ParseProviderIDTemplate(providerIDTemplate string) (*template.Template, error) {
+	if providerIDTemplate == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("provider-id").Option("missingkey=error").Parse(providerIDTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse provider ID template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Some tools (e.g., cloud controllers and cost exporters) parse the provider ID to figure out
+// where the node is running, so the template is rendered after the topology and instance
+// type labels have been filled in.
+func (self *LifecycleManager) setProviderID(node *corev1.Node) error {
+	if self.opts.ProviderIDTemplate == nil {
+		return nil
+	}
+
+	fields := ProviderIDFields{
+		NodeName:     node.ObjectMeta.Name,
+		PodName:      self.opts.PodName,
+		Region:       node.ObjectMeta.Labels[topologyRegionLabel],
+		Zone:         node.ObjectMeta.Labels[topologyZoneLabel],
+		InstanceType: node.ObjectMeta.Labels[nodeInstanceTypeLabel],
+		InstanceID:   instanceID(node.ObjectMeta.Name),
+	}
+
+	var buf bytes.Buffer
+	if err := self.opts.ProviderIDTemplate.Execute(&buf, fields); err != nil {
+		return fmt.Errorf("could not render provider ID template: %w", err)
+	}
+
+	providerID := buf.String()
+	if providerID == "" || strings.ContainsAny(providerID, " \t\n") {
+		return fmt.Errorf("invalid provider ID %q", providerID)
+	}
+	node.Spec.ProviderID = providerID
+	return nil
+}
+
+func instanceID(nodeName string) string {
+	hash := sha256.Sum256([]byte("instance-id/" + nodeName))
+	return "i-" + hex.EncodeToString(hash[:])[:instanceIDHexLen]
+}
@@ -0,0 +1,81 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"simkube/lib/go/k8s"
+)
+
+func TestSetProviderID(t *testing.T) {
+	cases := map[string]struct {
+		template           string
+		expectedProviderID string
+		expectErr          bool
+	}{
+		"default": {
+			expectedProviderID: k8s.ProviderID(expectedName),
+		},
+		"aws": {
+			template:           "aws:///{{.Zone}}/{{.InstanceID}}",
+			expectedProviderID: "aws:///us-west-2b/" + instanceID(expectedName),
+		},
+		"all fields": {
+			template:           "sim://{{.Region}}/{{.InstanceType}}/{{.PodName}}/{{.NodeName}}",
+			expectedProviderID: "sim://us-west-2/c5.xlarge/the-pod/" + expectedName,
+		},
+		"unknown field": {
+			template:  "sim://{{.Foo}}",
+			expectErr: true,
+		},
+		"empty": {
+			template:  "{{if .PodName}}{{end}}",
+			expectErr: true,
+		},
+		"whitespace": {
+			template:  "sim:// {{.NodeName}}",
+			expectErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tmpl, err := ParseProviderIDTemplate(tc.template)
+			if err != nil {
+				assert.True(t, tc.expectErr)
+				return
+			}
+
+			n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+				topologyRegionLabel:   "us-west-2",
+				topologyZoneLabel:     "us-west-2b",
+				nodeInstanceTypeLabel: "c5.xlarge",
+			}}}
+			setNodeNameAndID(expectedName, n)
+			nlm := &LifecycleManager{opts: Options{PodName: "the-pod", ProviderIDTemplate: tmpl}}
+
+			err = nlm.setProviderID(n)
+			if tc.expectErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expectedProviderID, n.Spec.ProviderID)
+			}
+		})
+	}
+}
+
+func TestParseProviderIDTemplateInvalid(t *testing.T) {
+	_, err := ParseProviderIDTemplate("aws:///{{.Zone")
+	assert.NotNil(t, err)
+}
+
+func TestInstanceID(t *testing.T) {
+	id := instanceID(expectedName)
+	assert.Regexp(t, "^i-[0-9a-f]{17}$", id)
+	assert.Equal(t, id, instanceID(expectedName))
+	assert.NotEqual(t, id, instanceID("otherNode"))
+}
@@ -0,0 +1,115 @@
+package node
+
+import (
+	"context"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	nodeRecreatedReason  = "NodeRecreated"
+	nodeReconciledReason = "NodeReconciled"
+
+	nodeInformerResyncPeriod = 0
+)
+
+// If someone else deletes or edits the node object while the virtual node is running, we
+// put it back the way we want it: deleted nodes are recreated, and any simkube-owned labels
+// or taints that were removed or changed are restored.  Other changes (e.g., taints added
+// by the node lifecycle controller, or cordoning the node) are left alone.
+func (self *LifecycleManager) watchNode(ctx context.Context) {
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		self.k8sClient,
+		nodeInformerResyncPeriod,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", self.nodeName).String()
+		}),
+	)
+
+	informer := factory.Core().V1().Nodes().Informer()
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, obj interface{}) {
+			if n, ok := obj.(*corev1.Node); ok && n.ObjectMeta.Name == self.nodeName {
+				self.reconcileNode(ctx, n)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := self.recreateNode(ctx); err != nil {
+				self.logger.WithError(err).Error("could not recreate node")
+			}
+		},
+	}); err != nil {
+		self.logger.WithError(err).Error("could not watch node object")
+		return
+	}
+	factory.Start(ctx.Done())
+}
+
+// handleNodeStatusUpdateError is called by the node controller if it can't update the
+// node's status; if the node was deleted, we recreate it so that the update can be retried
+func (self *LifecycleManager) handleNodeStatusUpdateError(ctx context.Context, err error) error {
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+	return self.recreateNode(ctx)
+}
+
+func (self *LifecycleManager) recreateNode(ctx context.Context) error {
+	// The node controller will fill in the status once the node exists again
+	if err := self.applyNode(ctx, nil); err != nil {
+		return err
+	}
+
+	self.logger.Warn("node object was deleted externally, recreated it")
+	self.recordNodeEvent(
+		corev1.EventTypeWarning,
+		nodeRecreatedReason,
+		"Node object was deleted while the virtual node was running and has been recreated",
+	)
+	return nil
+}
+
+func (self *LifecycleManager) reconcileNode(ctx context.Context, n *corev1.Node) {
+	self.mutex.Lock()
+	desired := self.desired.DeepCopy()
+	self.mutex.Unlock()
+	if desired == nil {
+		return
+	}
+
+	changed := false
+	for key, value := range desired.ObjectMeta.Labels {
+		if current, ok := n.ObjectMeta.Labels[key]; !ok || current != value {
+			changed = true
+		}
+	}
+	for _, taint := range desired.Spec.Taints {
+		if !lo.ContainsBy(n.Spec.Taints, func(t corev1.Taint) bool { return t.MatchTaint(&taint) }) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return
+	}
+
+	self.logger.Warn("node object was modified externally, reverting simkube-owned labels and taints")
+	if err := self.applyNode(ctx, n); err != nil {
+		self.logger.WithError(err).Error("could not reconcile node")
+		return
+	}
+	self.recordNodeEvent(
+		corev1.EventTypeWarning,
+		nodeReconciledReason,
+		"Node labels or taints were modified externally and have been reverted",
+	)
+}
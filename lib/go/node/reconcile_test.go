@@ -0,0 +1,124 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"simkube/lib/go/testutils"
+)
+
+//nolint:gochecknoglobals
+var testTaint = corev1.Taint{
+	Key:    virtualNodeTaintKey,
+	Value:  virtualNodeTaintValue,
+	Effect: corev1.TaintEffectNoExecute,
+}
+
+func makeDesiredNode() *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   expectedName,
+			Labels: map[string]string{kubernetesArchLabel: expectedArch, kubernetesOSLabel: expectedOS},
+		},
+		Spec: corev1.NodeSpec{Taints: []corev1.Taint{testTaint}},
+	}
+}
+
+func TestReconcileNode(t *testing.T) {
+	otherTaint := corev1.Taint{Key: "node.kubernetes.io/unreachable", Effect: corev1.TaintEffectNoExecute}
+	cases := map[string]struct {
+		current        *corev1.Node
+		expectedLabels map[string]string
+		expectedTaints []corev1.Taint
+	}{
+		"no changes": {
+			current:        makeDesiredNode(),
+			expectedLabels: map[string]string{kubernetesArchLabel: expectedArch, kubernetesOSLabel: expectedOS},
+			expectedTaints: []corev1.Taint{testTaint},
+		},
+		"external changes": {
+			current: &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   expectedName,
+					Labels: map[string]string{kubernetesArchLabel: "amd64", "foo": "bar"},
+				},
+				Spec: corev1.NodeSpec{Taints: []corev1.Taint{otherTaint}, Unschedulable: true},
+			},
+			expectedLabels: map[string]string{
+				kubernetesArchLabel: expectedArch,
+				kubernetesOSLabel:   expectedOS,
+				"foo":               "bar",
+			},
+			expectedTaints: []corev1.Taint{testTaint, otherTaint},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			k8sClient := fake.NewSimpleClientset(tc.current)
+			nlm := &LifecycleManager{
+				nodeName:  expectedName,
+				k8sClient: k8sClient,
+				logger:    testutils.GetFakeLogger(),
+				desired:   makeDesiredNode(),
+			}
+
+			nlm.reconcileNode(context.TODO(), tc.current)
+
+			n, _ := k8sClient.CoreV1().Nodes().Get(context.TODO(), expectedName, metav1.GetOptions{})
+			assert.Equal(t, tc.expectedLabels, n.ObjectMeta.Labels)
+			assert.Equal(t, tc.expectedTaints, n.Spec.Taints)
+			assert.Equal(t, tc.current.Spec.Unschedulable, n.Spec.Unschedulable)
+		})
+	}
+}
+
+func TestHandleNodeStatusUpdateError(t *testing.T) {
+	cases := map[string]struct {
+		err           error
+		expectErr     bool
+		expectedNodes int
+	}{
+		"node deleted": {
+			err:           apierrors.NewNotFound(schema.GroupResource{Resource: "nodes"}, expectedName),
+			expectedNodes: 1,
+		},
+		"other error": {
+			err:       errors.New("oh no"),
+			expectErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			k8sClient := newApplyClientset()
+			nlm := &LifecycleManager{
+				nodeName:  expectedName,
+				k8sClient: k8sClient,
+				logger:    testutils.GetFakeLogger(),
+				desired:   makeDesiredNode(),
+			}
+
+			err := nlm.handleNodeStatusUpdateError(context.TODO(), tc.err)
+			if tc.expectErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+
+			nodes, _ := k8sClient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+			assert.Len(t, nodes.Items, tc.expectedNodes)
+			if tc.expectedNodes > 0 {
+				assert.Equal(t, []corev1.Taint{testTaint}, nodes.Items[0].Spec.Taints)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"simkube/lib/go/util"
+)
+
+const defaultNodeTemplate = "default"
+
+//nolint:gochecknoglobals
+var skeletonExtensions = []string{".yml", ".yaml"}
+
+// If the skeleton path is a directory, it contains one skeleton per node template, named
+// <template>.yml (or .yaml).  The template is selected from (in order of precedence) the
+// NodeTemplate option, the simkube.io/node-template annotation on the node group
+// Deployment, or "default".
+func (self *LifecycleManager) resolveSkeletonFile(ctx context.Context, nodeSkeletonPath string) (string, error) {
+	info, err := os.Stat(nodeSkeletonPath)
+	if err != nil {
+		return "", fmt.Errorf("could not open %s: %w", nodeSkeletonPath, err)
+	}
+	if !info.IsDir() {
+		return nodeSkeletonPath, nil
+	}
+
+	template := self.opts.NodeTemplate
+	if template == "" {
+		template = self.lookupNodeGroupAnnotation(ctx, util.NodeTemplateAnnotation)
+	}
+	if template == "" {
+		template = defaultNodeTemplate
+	}
+
+	nodeSkeletonFile, err := findTemplateSkeleton(nodeSkeletonPath, template)
+	if err != nil {
+		return "", err
+	}
+	self.logger.Infof("using node template %s (%s)", template, nodeSkeletonFile)
+	return nodeSkeletonFile, nil
+}
+
+// The template name comes from a flag or a node group annotation, so it has to be a plain file
+// name (without the extension); otherwise it could pick a file outside of the skeleton directory
+func findTemplateSkeleton(nodeSkeletonDir, template string) (string, error) {
+	if filepath.Base(template) != template || template == "." || template == ".." {
+		return "", fmt.Errorf("%w: %q", ErrInvalidNodeTemplate, template)
+	}
+
+	for _, ext := range skeletonExtensions {
+		nodeSkeletonFile := filepath.Join(nodeSkeletonDir, template+ext)
+		if _, err := os.Stat(nodeSkeletonFile); err == nil {
+			return nodeSkeletonFile, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("could not open %s: %w", nodeSkeletonFile, err)
+		}
+	}
+	return "", fmt.Errorf("no skeleton found for node template %s in %s", template, nodeSkeletonDir)
+}
+
+// Some settings can be configured for the whole node group with annotations on the node
+// group object; if the node group can't be found, the setting is treated as unset
+func (self *LifecycleManager) lookupNodeGroupAnnotation(ctx context.Context, key string) string {
+	return self.lookupNodeGroupAnnotations(ctx, key)[key]
+}
+
+// lookupNodeGroupAnnotations returns all of the node group's annotations with one request; the
+// keys are only used to report which settings are being ignored if the lookup fails
+func (self *LifecycleManager) lookupNodeGroupAnnotations(ctx context.Context, keys ...string) map[string]string {
+	namespace, name := os.Getenv(namespaceEnvKey), os.Getenv(nodeGroupEnvKey)
+	if namespace == "" || name == "" || self.dynamicClient == nil {
+		return nil
+	}
+
+	resource := self.opts.NodeGroupResource
+	if resource.Empty() {
+		resource = appsv1.SchemeGroupVersion.WithResource("deployments")
+	}
+
+	nodeGroup, err := self.dynamicClient.Resource(resource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		self.logger.WithError(err).Warnf("could not look up node group, ignoring %s", strings.Join(keys, ", "))
+		return nil
+	}
+	return nodeGroup.GetAnnotations()
+}
+
+// The skeleton file is usually mounted from a ConfigMap, which kubelet updates by
+// atomically swapping out a symlink; rather than trying to track that with inotify,
+// we just poll the file contents on a fixed interval and compare them to what we saw last.
+func (self *LifecycleManager) watchSkeleton(ctx context.Context) {
+	self.logger.Infof("watching %s for changes every %v", self.skeletonFile, self.opts.SkeletonReloadInterval)
+	ticker := time.NewTicker(self.opts.SkeletonReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := self.reloadSkeleton(ctx); err != nil {
+				self.logger.WithError(err).Warn("could not reload node skeleton")
+			}
+		}
+	}
+}
+
+// Only the labels, the node capacity/allocatable, and the system info are updated on
+// reload; virtual-kubelet doesn't propagate spec changes (e.g., taints) from the provider,
+// and everything else is owned by simkube anyways.
+func (self *LifecycleManager) reloadSkeleton(ctx context.Context) error {
+	nodeBytes, err := readSkeletonFile(self.skeletonFile)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(nodeBytes, self.skeletonBytes) {
+		return nil
+	}
+
+	skel, err := parseSkeletonNode(self.skeletonFile, nodeBytes)
+	if err != nil {
+		return err
+	}
+	if err := validateSkeleton(self.skeletonFile, skel, self.maxPods()); err != nil {
+		return err
+	}
+	if self.zone != nil {
+		applyZoneLabels(skel, *self.zone)
+	}
+	setNodeNameAndID(self.nodeName, skel)
+	self.applyNodeGroupInstanceType(ctx, skel)
+	self.applyNodeGroupLabels(ctx, skel)
+	applyStandardNodeLabelsAndTaints(skel, self.virtualNodeTaint())
+	configureNodeResources(skel, self.maxPods())
+	self.setGPULabel(ctx, skel)
+
+	self.logger.Infof("node skeleton %s changed, updating node", self.skeletonFile)
+	if err := self.updateNodeStatus(ctx, func(n *corev1.Node) {
+		n.ObjectMeta.Labels = skel.ObjectMeta.Labels
+		n.Status.Capacity = skel.Status.Capacity
+		n.Status.Allocatable = skel.Status.Allocatable
+		mergeNodeSystemInfo(&n.Status.NodeInfo, skel.Status.NodeInfo)
+		if self.opts.KubeletVersion != "" {
+			n.Status.NodeInfo.KubeletVersion = self.opts.KubeletVersion
+		}
+	}); err != nil {
+		return err
+	}
+
+	self.mutex.Lock()
+	if self.desired != nil {
+		self.desired.ObjectMeta.Labels = skel.ObjectMeta.Labels
+	}
+	self.mutex.Unlock()
+	self.skeletonBytes = nodeBytes
+	return nil
+}
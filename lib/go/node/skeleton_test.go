@@ -0,0 +1,185 @@
+package node
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"simkube/lib/go/testutils"
+	"simkube/lib/go/util"
+)
+
+const updatedSkeleton = `---
+apiVersion: v1
+kind: Node
+metadata:
+  labels:
+    foo: bar
+status:
+  capacity:
+    cpu: "8"
+`
+
+func TestReloadSkeleton(t *testing.T) {
+	skelFile := filepath.Join(t.TempDir(), "node.yml")
+	origBytes, err := os.ReadFile(testSkelFile)
+	if err != nil {
+		panic(err)
+	}
+	if err = os.WriteFile(skelFile, origBytes, 0600); err != nil {
+		panic(err)
+	}
+
+	nlm := &LifecycleManager{
+		nodeName:  expectedName,
+		k8sClient: fake.NewSimpleClientset(),
+		logger:    testutils.GetFakeLogger(),
+	}
+	n, err := nlm.CreateNodeObject(skelFile)
+	if err != nil {
+		panic(err)
+	}
+
+	var notified *corev1.Node
+	nlm.provider = newNodeProvider()
+	nlm.provider.NotifyNodeStatus(context.TODO(), func(n *corev1.Node) { notified = n })
+	nlm.node = n
+
+	// Nothing changed, so we shouldn't get notified
+	assert.Nil(t, nlm.reloadSkeleton(context.TODO()))
+	assert.Nil(t, notified)
+
+	if err = os.WriteFile(skelFile, []byte(updatedSkeleton), 0600); err != nil {
+		panic(err)
+	}
+	assert.Nil(t, nlm.reloadSkeleton(context.TODO()))
+
+	assert.NotNil(t, notified)
+	assert.Equal(t, "bar", notified.ObjectMeta.Labels["foo"])
+	assert.Equal(t, expectedOS, notified.ObjectMeta.Labels[kubernetesOSLabel])
+	assert.Equal(t, resource.MustParse("8"), notified.Status.Capacity[corev1.ResourceCPU])
+	assert.Equal(t, resource.MustParse("8"), notified.Status.Allocatable[corev1.ResourceCPU])
+	assert.Equal(t, []byte(updatedSkeleton), nlm.skeletonBytes)
+}
+
+func TestResolveSkeletonFile(t *testing.T) {
+	skelDir := t.TempDir()
+	for _, name := range []string{"default.yml", "gpu.yaml"} {
+		if err := os.WriteFile(filepath.Join(skelDir, name), []byte{}, 0600); err != nil {
+			panic(err)
+		}
+	}
+
+	cases := map[string]struct {
+		path          string
+		template      string
+		expected      string
+		expectedError bool
+	}{
+		"file":             {path: testSkelFile, expected: testSkelFile},
+		"default template": {path: skelDir, expected: filepath.Join(skelDir, "default.yml")},
+		"named template":   {path: skelDir, template: "gpu", expected: filepath.Join(skelDir, "gpu.yaml")},
+		"missing template": {path: skelDir, template: "asdf", expectedError: true},
+		"missing path":     {path: "/does/not/exist", expectedError: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			nlm := &LifecycleManager{
+				nodeName:  expectedName,
+				k8sClient: fake.NewSimpleClientset(),
+				opts:      Options{NodeTemplate: tc.template},
+				logger:    testutils.GetFakeLogger(),
+			}
+
+			res, err := nlm.resolveSkeletonFile(context.TODO(), tc.path)
+			if tc.expectedError {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expected, res)
+			}
+		})
+	}
+}
+
+func TestFindTemplateSkeletonInvalidName(t *testing.T) {
+	parentDir := t.TempDir()
+	skelDir := filepath.Join(parentDir, "skeletons")
+	if err := os.Mkdir(skelDir, 0700); err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(filepath.Join(parentDir, "outside.yml"), []byte{}, 0600); err != nil {
+		panic(err)
+	}
+
+	for name, template := range map[string]string{
+		"parent directory": "../outside",
+		"absolute path":    filepath.Join(parentDir, "outside"),
+		"subdirectory":     "sub/gpu",
+		"dot dot":          "..",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := findTemplateSkeleton(skelDir, template)
+			assert.ErrorIs(t, err, ErrInvalidNodeTemplate)
+			assert.True(t, util.IsValidation(err))
+		})
+	}
+}
+
+func TestLookupNodeGroupAnnotation(t *testing.T) {
+	t.Setenv(namespaceEnvKey, "test")
+	t.Setenv(nodeGroupEnvKey, "node-group")
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(
+		scheme.Scheme,
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "test",
+				Name:        "node-group",
+				Annotations: map[string]string{util.NodeTemplateAnnotation: "gpu"},
+			},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "test",
+				Name:        "node-group",
+				Annotations: map[string]string{util.NodeTemplateAnnotation: "zonal"},
+			},
+		},
+	)
+
+	cases := map[string]struct {
+		resource schema.GroupVersionResource
+		expected string
+	}{
+		"deployment":  {expected: "gpu"},
+		"statefulset": {resource: appsv1.SchemeGroupVersion.WithResource("statefulsets"), expected: "zonal"},
+		"missing":     {resource: appsv1.SchemeGroupVersion.WithResource("daemonsets")},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			nlm := &LifecycleManager{
+				nodeName:      expectedName,
+				dynamicClient: dynamicClient,
+				opts:          Options{NodeGroupResource: tc.resource},
+				logger:        testutils.GetFakeLogger(),
+			}
+
+			assert.Equal(t, tc.expected, nlm.lookupNodeGroupAnnotation(context.TODO(), util.NodeTemplateAnnotation))
+			assert.Equal(t, "", nlm.lookupNodeGroupAnnotation(context.TODO(), util.NodeLifetimeAnnotation))
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package node
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"simkube/lib/go/audit"
+)
+
+const (
+	notReadyReason  = "KubeletNotReady"
+	notReadyMessage = "simulated node provisioning in progress"
+	readyReason     = "KubeletReady"
+	readyMessage    = "kubelet is ready."
+)
+
+// Real nodes take a while to boot and join the cluster after the cloud provider scales
+// up; to model this, the node is registered in the Pending phase and NotReady, and only
+// becomes Ready once the startup delay has elapsed.  If StartupDelayMax is larger than
+// StartupDelay, the delay is chosen uniformly at random from that range.
+func (self *LifecycleManager) startupDelay() time.Duration {
+	delay := self.opts.StartupDelay
+	if self.opts.StartupDelayMax > delay {
+		//nolint:gosec // this doesn't need to be cryptographically secure
+		delay += time.Duration(rand.Int63n(int64(self.opts.StartupDelayMax - delay)))
+	}
+	return delay
+}
+
+func markNodeNotReady(node *corev1.Node) {
+	setNodeCondition(node, corev1.NodeReady, corev1.ConditionFalse, notReadyReason, notReadyMessage)
+	node.Status.Phase = corev1.NodePending
+}
+
+func (self *LifecycleManager) markReadyAfter(ctx context.Context, delay time.Duration) {
+	self.logger.Infof("simulating node startup, node will become ready in %v", delay)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-timer.C:
+	}
+
+	if err := self.updateNodeStatus(ctx, func(n *corev1.Node) {
+		setNodeCondition(n, corev1.NodeReady, corev1.ConditionTrue, readyReason, readyMessage)
+		n.Status.Phase = corev1.NodeRunning
+	}); err != nil {
+		self.logger.WithError(err).Error("could not mark node ready")
+		return
+	}
+	self.logger.Info("node is ready")
+	audit.Record(audit.Event{
+		Action:  audit.NodeConditionChanged,
+		Object:  self.nodeName,
+		Reason:  readyReason,
+		Details: map[string]string{"type": string(corev1.NodeReady), "status": string(corev1.ConditionTrue)},
+	})
+}
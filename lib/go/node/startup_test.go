@@ -0,0 +1,48 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	"simkube/lib/go/testutils"
+)
+
+func TestStartupDelay(t *testing.T) {
+	nlm := &LifecycleManager{opts: Options{StartupDelay: time.Minute}}
+	assert.Equal(t, time.Minute, nlm.startupDelay())
+
+	nlm.opts.StartupDelayMax = 2 * time.Minute
+	for i := 0; i < 100; i++ {
+		delay := nlm.startupDelay()
+		assert.GreaterOrEqual(t, delay, time.Minute)
+		assert.Less(t, delay, 2*time.Minute)
+	}
+}
+
+func TestMarkReadyAfter(t *testing.T) {
+	n := &corev1.Node{}
+	setNodeStatus(n)
+	markNodeNotReady(n)
+	assert.Equal(t, corev1.NodePending, n.Status.Phase)
+
+	var notified *corev1.Node
+	nlm := &LifecycleManager{
+		provider: newNodeProvider(),
+		node:     n,
+		logger:   testutils.GetFakeLogger(),
+	}
+	nlm.provider.NotifyNodeStatus(context.TODO(), func(n *corev1.Node) { notified = n })
+
+	nlm.markReadyAfter(context.TODO(), time.Millisecond)
+
+	assert.Equal(t, corev1.NodeRunning, notified.Status.Phase)
+	for _, cond := range notified.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		}
+	}
+}
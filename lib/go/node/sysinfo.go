@@ -0,0 +1,64 @@
+package node
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+const (
+	defaultContainerRuntimeVersion = "containerd://1.7.2"
+	defaultKernelVersion           = "5.15.0-1041-aws"
+	defaultOSImage                 = "Ubuntu 22.04.3 LTS"
+)
+
+// Node inventory and compliance tools parse the node's system info, so we fill in realistic
+// values for anything that isn't set in the skeleton.  The machine ID and system UUID are
+// derived from the node name so that they're stable across restarts of the virtual node,
+// whereas the boot ID changes every time (like it would on a real machine).
+func setNodeSystemInfo(node *corev1.Node) {
+	hash := sha256.Sum256([]byte(node.ObjectMeta.Name))
+	info := corev1.NodeSystemInfo{
+		MachineID:               hex.EncodeToString(hash[:16]),
+		SystemUUID:              formatUUID(hash[:16]),
+		BootID:                  string(uuid.NewUUID()),
+		KernelVersion:           defaultKernelVersion,
+		OSImage:                 defaultOSImage,
+		ContainerRuntimeVersion: defaultContainerRuntimeVersion,
+		KubeletVersion:          node.Status.NodeInfo.KubeletVersion,
+		KubeProxyVersion:        node.Status.NodeInfo.KubeletVersion,
+		OperatingSystem:         node.ObjectMeta.Labels[kubernetesOSLabel],
+		Architecture:            node.ObjectMeta.Labels[kubernetesArchLabel],
+	}
+	mergeNodeSystemInfo(&info, node.Status.NodeInfo)
+	node.Status.NodeInfo = info
+}
+
+// mergeNodeSystemInfo overwrites each field in dst with the corresponding field from src,
+// unless the src field is empty
+func mergeNodeSystemInfo(dst *corev1.NodeSystemInfo, src corev1.NodeSystemInfo) {
+	fields := []struct{ dst, src *string }{
+		{&dst.MachineID, &src.MachineID},
+		{&dst.SystemUUID, &src.SystemUUID},
+		{&dst.BootID, &src.BootID},
+		{&dst.KernelVersion, &src.KernelVersion},
+		{&dst.OSImage, &src.OSImage},
+		{&dst.ContainerRuntimeVersion, &src.ContainerRuntimeVersion},
+		{&dst.KubeletVersion, &src.KubeletVersion},
+		{&dst.KubeProxyVersion, &src.KubeProxyVersion},
+		{&dst.OperatingSystem, &src.OperatingSystem},
+		{&dst.Architecture, &src.Architecture},
+	}
+	for _, f := range fields {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+}
+
+func formatUUID(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
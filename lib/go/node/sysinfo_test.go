@@ -0,0 +1,55 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetNodeSystemInfo(t *testing.T) {
+	makeNode := func() *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   expectedName,
+				Labels: map[string]string{kubernetesArchLabel: expectedArch, kubernetesOSLabel: expectedOS},
+			},
+			Status: corev1.NodeStatus{
+				NodeInfo: corev1.NodeSystemInfo{
+					KubeletVersion: "v1.28.0",
+					OSImage:        "Bottlerocket OS 1.15.0 (aws-k8s-1.28)",
+				},
+			},
+		}
+	}
+
+	n1 := makeNode()
+	setNodeSystemInfo(n1)
+	assert.Equal(t, "Bottlerocket OS 1.15.0 (aws-k8s-1.28)", n1.Status.NodeInfo.OSImage)
+	assert.Equal(t, defaultKernelVersion, n1.Status.NodeInfo.KernelVersion)
+	assert.Equal(t, defaultContainerRuntimeVersion, n1.Status.NodeInfo.ContainerRuntimeVersion)
+	assert.Equal(t, "v1.28.0", n1.Status.NodeInfo.KubeProxyVersion)
+	assert.Equal(t, expectedArch, n1.Status.NodeInfo.Architecture)
+	assert.Equal(t, expectedOS, n1.Status.NodeInfo.OperatingSystem)
+	assert.Len(t, n1.Status.NodeInfo.MachineID, 32)
+
+	// The machine ID and system UUID are stable, but the boot ID is not
+	n2 := makeNode()
+	setNodeSystemInfo(n2)
+	assert.Equal(t, n1.Status.NodeInfo.MachineID, n2.Status.NodeInfo.MachineID)
+	assert.Equal(t, n1.Status.NodeInfo.SystemUUID, n2.Status.NodeInfo.SystemUUID)
+	assert.NotEqual(t, n1.Status.NodeInfo.BootID, n2.Status.NodeInfo.BootID)
+}
+
+func TestMergeNodeSystemInfo(t *testing.T) {
+	dst := corev1.NodeSystemInfo{KernelVersion: "5.15", OSImage: "Ubuntu", BootID: "1234"}
+	mergeNodeSystemInfo(&dst, corev1.NodeSystemInfo{KernelVersion: "6.1", ContainerRuntimeVersion: "cri-o://1.28"})
+
+	assert.Equal(t, corev1.NodeSystemInfo{
+		KernelVersion:           "6.1",
+		OSImage:                 "Ubuntu",
+		BootID:                  "1234",
+		ContainerRuntimeVersion: "cri-o://1.28",
+	}, dst)
+}
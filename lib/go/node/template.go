@@ -0,0 +1,103 @@
+package node
+
+import (
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/util"
+)
+
+// TemplateNodeOptions describes the node group that a template node is built for; the
+// fields have the same meaning as the corresponding node group annotations and flags.
+type TemplateNodeOptions struct {
+	NodeGroupNamespace string
+	NodeGroupName      string
+
+	// NodeTemplate selects the skeleton when the skeleton path is a directory; if empty,
+	// the default template is used
+	NodeTemplate string
+
+	// GPULabel and GPUType are used to label template nodes that have GPUs; if empty,
+	// simkube.io/gpu-type and the default GPU type are used
+	GPULabel string
+	GPUType  string
+
+	// InstanceType and Zone (<region>/<zone>) are used unless the skeleton sets the instance
+	// type and topology labels, and the resources in InstanceShape are used unless the
+	// skeleton sets them; if empty, the defaults are used
+	InstanceType  string
+	InstanceShape corev1.ResourceList
+	Zone          string
+
+	// Labels are added to the template node, unless the skeleton sets them
+	Labels map[string]string
+}
+
+// TemplateNode builds the node that a virtual node in the node group would create from
+// its skeleton, using the default settings for everything that isn't in the skeleton.
+// Cluster Autoscaler needs this to scale up node groups that don't have any nodes.
+func TemplateNode(nodeSkeletonPath string, opts TemplateNodeOptions) (*corev1.Node, error) {
+	info, err := os.Stat(nodeSkeletonPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", nodeSkeletonPath, err)
+	}
+
+	nodeSkeletonFile := nodeSkeletonPath
+	if info.IsDir() {
+		template := opts.NodeTemplate
+		if template == "" {
+			template = defaultNodeTemplate
+		}
+		if nodeSkeletonFile, err = findTemplateSkeleton(nodeSkeletonPath, template); err != nil {
+			return nil, err
+		}
+	}
+
+	nodeBytes, err := readSkeletonFile(nodeSkeletonFile)
+	if err != nil {
+		return nil, err
+	}
+	node, err := parseSkeletonNode(nodeSkeletonFile, nodeBytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSkeleton(nodeSkeletonFile, node, defaultMaxPods); err != nil {
+		return nil, err
+	}
+
+	if opts.Zone != "" {
+		zones, err := ParseZones([]string{opts.Zone})
+		if err != nil {
+			return nil, err
+		}
+		applyZoneLabels(node, zones[0])
+	}
+	applyInstanceType(node, opts.InstanceType, opts.InstanceShape)
+	applyNodeLabels(node, opts.Labels)
+
+	setNodeNameAndID(fmt.Sprintf("template-%s-%s", opts.NodeGroupNamespace, opts.NodeGroupName), node)
+	setNodeStatus(node)
+	applyStandardNodeLabelsAndTaints(node, defaultVirtualNodeTaint())
+	node.ObjectMeta.Labels[util.NodeGroupNamespaceLabel] = opts.NodeGroupNamespace
+	node.ObjectMeta.Labels[util.NodeGroupNameLabel] = opts.NodeGroupName
+	configureNodeResources(node, defaultMaxPods)
+	setNodeSystemInfo(node)
+
+	gpuLabel := opts.GPULabel
+	if gpuLabel == "" {
+		gpuLabel = util.GPULabel
+	}
+	if gpus, ok := node.Status.Capacity[k8s.NvidiaGPUResource]; ok && !gpus.IsZero() {
+		if _, ok := node.ObjectMeta.Labels[gpuLabel]; !ok {
+			gpuType := opts.GPUType
+			if gpuType == "" {
+				gpuType = defaultGPUType
+			}
+			node.ObjectMeta.Labels[gpuLabel] = gpuType
+		}
+	}
+	return node, nil
+}
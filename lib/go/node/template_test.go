@@ -0,0 +1,80 @@
+package node
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/util"
+)
+
+const gpuSkeleton = `---
+apiVersion: v1
+kind: Node
+status:
+  capacity:
+    cpu: "8"
+    nvidia.com/gpu: "1"
+`
+
+func TestTemplateNode(t *testing.T) {
+	node, err := TemplateNode(testSkelFile, TemplateNodeOptions{NodeGroupNamespace: "test", NodeGroupName: "ng"})
+	assert.Nil(t, err)
+
+	assert.Equal(t, "arm64", node.ObjectMeta.Labels[kubernetesArchLabel])
+	assert.Equal(t, "test", node.ObjectMeta.Labels[util.NodeGroupNamespaceLabel])
+	assert.Equal(t, "ng", node.ObjectMeta.Labels[util.NodeGroupNameLabel])
+	assert.Contains(t, node.Spec.Taints, *defaultVirtualNodeTaint())
+	assert.Equal(t, resource.MustParse("1"), node.Status.Allocatable[corev1.ResourceCPU])
+	assert.Equal(t, int64(defaultMaxPods), node.Status.Capacity.Pods().Value())
+}
+
+func TestTemplateNodeDirectory(t *testing.T) {
+	skelDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(skelDir, "gpu.yml"), []byte(gpuSkeleton), 0600); err != nil {
+		panic(err)
+	}
+
+	node, err := TemplateNode(skelDir, TemplateNodeOptions{NodeTemplate: "gpu", GPUType: "nvidia-a100"})
+	assert.Nil(t, err)
+	assert.Equal(t, resource.MustParse("1"), node.Status.Capacity[k8s.NvidiaGPUResource])
+	assert.Equal(t, "nvidia-a100", node.ObjectMeta.Labels[util.GPULabel])
+
+	_, err = TemplateNode(skelDir, TemplateNodeOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestTemplateNodeInstanceTypeAndZone(t *testing.T) {
+	skelFile := filepath.Join(t.TempDir(), "skel.yml")
+	if err := os.WriteFile(skelFile, []byte(gpuSkeleton), 0600); err != nil {
+		panic(err)
+	}
+
+	node, err := TemplateNode(skelFile, TemplateNodeOptions{
+		InstanceType: "m6i.xlarge",
+		InstanceShape: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("4"),
+			corev1.ResourceMemory: resource.MustParse("16Gi"),
+		},
+		Zone:   "us-west-2/us-west-2b",
+		Labels: map[string]string{"karpenter.sh/capacity-type": "spot"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "spot", node.ObjectMeta.Labels["karpenter.sh/capacity-type"])
+	assert.Equal(t, "m6i.xlarge", node.ObjectMeta.Labels[nodeInstanceTypeLabel])
+	assert.Equal(t, "us-west-2b", node.ObjectMeta.Labels[topologyZoneLabel])
+	assert.Equal(t, "us-west-2", node.ObjectMeta.Labels[topologyRegionLabel])
+
+	// The skeleton's CPU capacity takes precedence
+	assert.Equal(t, resource.MustParse("8"), node.Status.Capacity[corev1.ResourceCPU])
+	assert.Equal(t, resource.MustParse("16Gi"), node.Status.Capacity[corev1.ResourceMemory])
+	assert.Equal(t, resource.MustParse("16Gi"), node.Status.Allocatable[corev1.ResourceMemory])
+
+	_, err = TemplateNode(skelFile, TemplateNodeOptions{Zone: "us-west-2/"})
+	assert.NotNil(t, err)
+}
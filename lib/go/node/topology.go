@@ -0,0 +1,166 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"simkube/lib/go/util"
+)
+
+const (
+	ZonePolicyRoundRobin = "round-robin"
+	ZonePolicyWeighted   = "weighted"
+)
+
+type Zone struct {
+	Region string
+	Name   string
+	Weight int
+}
+
+// ParseZones parses a list of zone specifications of the form <region>/<zone>[=<weight>];
+// if the region is omitted, the default region is used.  Weights default to 1.
+func ParseZones(zoneSpecs []string) ([]Zone, error) {
+	zones := make([]Zone, 0, len(zoneSpecs))
+	for _, spec := range zoneSpecs {
+		zone := Zone{Region: defaultTopologyRegion, Weight: 1}
+
+		name, weightStr, hasWeight := strings.Cut(spec, "=")
+		if hasWeight {
+			weight, err := strconv.Atoi(weightStr)
+			if err != nil || weight <= 0 {
+				return nil, fmt.Errorf("invalid weight for zone %s: %s", name, weightStr)
+			}
+			zone.Weight = weight
+		}
+
+		if region, zoneName, hasRegion := strings.Cut(name, "/"); hasRegion {
+			zone.Region = region
+			name = zoneName
+		}
+		if name == "" || zone.Region == "" {
+			return nil, fmt.Errorf("invalid zone specification: %s", spec)
+		}
+		zone.Name = name
+		zones = append(zones, zone)
+	}
+	return zones, nil
+}
+
+// The round-robin policy places the node in whichever zone currently has the fewest nodes
+// from the same node group (like a cloud provider ASG would); the weighted policy picks a
+// zone at random (seeded by the node name, so it is stable across restarts) in proportion
+// to the zone weights.  If no zones are configured, the node group's simkube.io/zone
+// annotation is used (if it has one).
+func (self *LifecycleManager) selectZone(ctx context.Context) (Zone, bool) {
+	if len(self.opts.Zones) == 0 {
+		return self.nodeGroupZone(ctx)
+	}
+
+	switch self.opts.ZonePolicy {
+	case ZonePolicyWeighted:
+		return pickWeightedZone(self.opts.Zones, self.nodeName), true
+	default:
+		counts, err := self.countNodeGroupZones(ctx)
+		if err != nil {
+			self.logger.WithError(err).Warn("could not count nodes per zone, using weighted zone selection")
+			return pickWeightedZone(self.opts.Zones, self.nodeName), true
+		}
+		return pickLeastPopulatedZone(self.opts.Zones, counts, self.nodeName), true
+	}
+}
+
+func (self *LifecycleManager) nodeGroupZone(ctx context.Context) (Zone, bool) {
+	spec := self.lookupNodeGroupAnnotation(ctx, util.ZoneAnnotation)
+	if spec == "" {
+		return Zone{}, false
+	}
+
+	zones, err := ParseZones([]string{spec})
+	if err != nil {
+		self.logger.WithError(err).Warnf("invalid %s annotation on node group, ignoring", util.ZoneAnnotation)
+		return Zone{}, false
+	}
+	return zones[0], true
+}
+
+func (self *LifecycleManager) countNodeGroupZones(ctx context.Context) (map[string]int, error) {
+	selector := fmt.Sprintf(
+		"%s=%s,%s=%s",
+		util.NodeGroupNamespaceLabel,
+		os.Getenv(namespaceEnvKey),
+		util.NodeGroupNameLabel,
+		os.Getenv(nodeGroupEnvKey),
+	)
+	nodes, err := self.k8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("could not list nodes: %w", err)
+	}
+
+	counts := map[string]int{}
+	for _, n := range nodes.Items {
+		if n.ObjectMeta.Name != self.nodeName {
+			counts[n.ObjectMeta.Labels[topologyZoneLabel]] += 1
+		}
+	}
+	return counts, nil
+}
+
+func pickLeastPopulatedZone(zones []Zone, counts map[string]int, nodeName string) Zone {
+	// Multiple nodes that start at the same time will all see the same counts, so we
+	// break ties using the node name to spread them out
+	minCount := -1
+	var candidates []Zone
+	for _, zone := range zones {
+		count := counts[zone.Name]
+		if minCount == -1 || count < minCount {
+			minCount = count
+			candidates = []Zone{zone}
+		} else if count == minCount {
+			candidates = append(candidates, zone)
+		}
+	}
+	return candidates[hashNodeName(nodeName)%uint32(len(candidates))]
+}
+
+func pickWeightedZone(zones []Zone, nodeName string) Zone {
+	total := 0
+	for _, zone := range zones {
+		total += zone.Weight
+	}
+
+	target := int(hashNodeName(nodeName) % uint32(total))
+	for _, zone := range zones {
+		if target < zone.Weight {
+			return zone
+		}
+		target -= zone.Weight
+	}
+	return zones[len(zones)-1]
+}
+
+func hashNodeName(nodeName string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(nodeName))
+	return h.Sum32()
+}
+
+// Topology labels from the skeleton take precedence over the selected zone
+func applyZoneLabels(node *corev1.Node, zone Zone) {
+	if node.ObjectMeta.Labels == nil {
+		node.ObjectMeta.Labels = map[string]string{}
+	}
+	if _, ok := node.ObjectMeta.Labels[topologyZoneLabel]; !ok {
+		node.ObjectMeta.Labels[topologyZoneLabel] = zone.Name
+	}
+	if _, ok := node.ObjectMeta.Labels[topologyRegionLabel]; !ok {
+		node.ObjectMeta.Labels[topologyRegionLabel] = zone.Region
+	}
+}
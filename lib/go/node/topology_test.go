@@ -0,0 +1,110 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"simkube/lib/go/testutils"
+	"simkube/lib/go/util"
+)
+
+func TestParseZones(t *testing.T) {
+	zones, err := ParseZones([]string{"us-west-2/us-west-2a=3", "us-east-1b"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Zone{
+		{Region: "us-west-2", Name: "us-west-2a", Weight: 3},
+		{Region: defaultTopologyRegion, Name: "us-east-1b", Weight: 1},
+	}, zones)
+}
+
+func TestParseZonesError(t *testing.T) {
+	for _, spec := range []string{"us-east-1a=0", "us-east-1a=asdf", "us-east-1/", "/us-east-1a"} {
+		_, err := ParseZones([]string{spec})
+		assert.NotNil(t, err, spec)
+	}
+}
+
+func TestPickWeightedZone(t *testing.T) {
+	zones := []Zone{{Name: "a", Weight: 1}, {Name: "b", Weight: 0}, {Name: "c", Weight: 3}}
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		counts[pickWeightedZone(zones, fmt.Sprintf("node-%d", i)).Name] += 1
+	}
+
+	assert.Zero(t, counts["b"])
+	assert.Greater(t, counts["c"], counts["a"])
+}
+
+func TestSelectZoneRoundRobin(t *testing.T) {
+	t.Setenv(namespaceEnvKey, "test")
+	t.Setenv(nodeGroupEnvKey, "node-group")
+
+	nodeGroupLabels := func(zone string) map[string]string {
+		return map[string]string{
+			util.NodeGroupNamespaceLabel: "test",
+			util.NodeGroupNameLabel:      "node-group",
+			topologyZoneLabel:            zone,
+		}
+	}
+	nlm := &LifecycleManager{
+		nodeName: expectedName,
+		k8sClient: fake.NewSimpleClientset(
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: nodeGroupLabels("a")}},
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node2", Labels: nodeGroupLabels("c")}},
+		),
+		opts: Options{
+			Zones:      []Zone{{Name: "a", Weight: 1}, {Name: "b", Weight: 1}, {Name: "c", Weight: 1}},
+			ZonePolicy: ZonePolicyRoundRobin,
+		},
+		logger: testutils.GetFakeLogger(),
+	}
+
+	zone, ok := nlm.selectZone(context.TODO())
+	assert.True(t, ok)
+	assert.Equal(t, "b", zone.Name)
+}
+
+func TestApplyZoneLabels(t *testing.T) {
+	n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{topologyZoneLabel: "override"}}}
+	applyZoneLabels(n, Zone{Region: "region", Name: "zone"})
+
+	assert.Equal(t, "override", n.ObjectMeta.Labels[topologyZoneLabel])
+	assert.Equal(t, "region", n.ObjectMeta.Labels[topologyRegionLabel])
+}
+
+func TestSelectZoneNodeGroupAnnotation(t *testing.T) {
+	t.Setenv(namespaceEnvKey, "test")
+	t.Setenv(nodeGroupEnvKey, "node-group")
+
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "test",
+		Name:        "node-group",
+		Annotations: map[string]string{util.ZoneAnnotation: "us-west-2/us-west-2b"},
+	}}
+	nlm := &LifecycleManager{
+		nodeName:      expectedName,
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(scheme.Scheme, deployment),
+		logger:        testutils.GetFakeLogger(),
+	}
+
+	zone, ok := nlm.selectZone(context.TODO())
+	assert.True(t, ok)
+	assert.Equal(t, Zone{Region: "us-west-2", Name: "us-west-2b", Weight: 1}, zone)
+
+	// The --zones flag takes precedence over the annotation
+	nlm.opts.Zones = []Zone{{Region: "us-east-1", Name: "us-east-1a", Weight: 1}}
+	nlm.opts.ZonePolicy = ZonePolicyWeighted
+	zone, ok = nlm.selectZone(context.TODO())
+	assert.True(t, ok)
+	assert.Equal(t, "us-east-1a", zone.Name)
+}
@@ -0,0 +1,169 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/util"
+)
+
+var ErrInvalidSkeleton = util.WithKind(util.ErrValidation, errors.New("invalid node skeleton"))
+
+//nolint:gochecknoglobals
+var standardNodeResources = []corev1.ResourceName{
+	corev1.ResourceCPU,
+	corev1.ResourceMemory,
+	corev1.ResourceEphemeralStorage,
+	corev1.ResourcePods,
+}
+
+// ValidateSkeletonFile checks that the skeleton file (or, if the path is a directory, every
+// node template in the directory) parses and describes a valid node.  maxPods is the pod
+// capacity of the node if the skeleton doesn't specify one (see Options.MaxPods).
+func ValidateSkeletonFile(nodeSkeletonPath string, maxPods int64) error {
+	info, err := os.Stat(nodeSkeletonPath)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", nodeSkeletonPath, err)
+	}
+
+	files := []string{nodeSkeletonPath}
+	if info.IsDir() {
+		files = nil
+		for _, ext := range skeletonExtensions {
+			matches, err := filepath.Glob(filepath.Join(nodeSkeletonPath, "*"+ext))
+			if err != nil {
+				return fmt.Errorf("could not list node templates in %s: %w", nodeSkeletonPath, err)
+			}
+			files = append(files, matches...)
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("%w: no node templates found in %s", ErrInvalidSkeleton, nodeSkeletonPath)
+		}
+		sort.Strings(files)
+	}
+
+	var errs []error
+	for _, file := range files {
+		nodeBytes, err := readSkeletonFile(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		skel, err := parseSkeletonNode(file, nodeBytes)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := validateSkeleton(file, skel, maxPods); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// The API server will reject some invalid nodes, but others (e.g., allocatable larger than
+// capacity) are accepted and then fail in confusing ways once pods get scheduled, so we
+// check for as many problems as we can up front and report them all at once.
+func validateSkeleton(nodeSkeletonFile string, skel *corev1.Node, maxPods int64) error {
+	var problems []string
+
+	for key, value := range skel.ObjectMeta.Labels {
+		for _, msg := range validation.IsQualifiedName(key) {
+			problems = append(problems, fmt.Sprintf("label key %q: %s", key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(value) {
+			problems = append(problems, fmt.Sprintf("label %q value %q: %s", key, value, msg))
+		}
+	}
+
+	if _, err := NodeLifetime(skel); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	for _, taint := range skel.Spec.Taints {
+		problems = append(problems, validateTaint(&taint)...)
+	}
+
+	problems = append(problems, validateResources("capacity", skel.Status.Capacity)...)
+	problems = append(problems, validateResources("allocatable", skel.Status.Allocatable)...)
+
+	// Compare against the capacity after defaults have been filled in, since the node
+	// will fail in the same way if the allocatable exceeds a default capacity
+	if maxPods <= 0 {
+		maxPods = defaultMaxPods
+	}
+	withDefaults := skel.DeepCopy()
+	configureNodeResources(withDefaults, maxPods)
+	for name, allocatable := range withDefaults.Status.Allocatable {
+		if capacity, ok := withDefaults.Status.Capacity[name]; ok && allocatable.Cmp(capacity) > 0 {
+			problems = append(problems, fmt.Sprintf(
+				"allocatable %s (%s) is larger than capacity (%s)",
+				name, allocatable.String(), capacity.String(),
+			))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("%w %s:\n  - %s", ErrInvalidSkeleton, nodeSkeletonFile, strings.Join(problems, "\n  - "))
+}
+
+func validateTaint(taint *corev1.Taint) []string {
+	var problems []string
+	for _, msg := range validation.IsQualifiedName(taint.Key) {
+		problems = append(problems, fmt.Sprintf("taint key %q: %s", taint.Key, msg))
+	}
+	for _, msg := range validation.IsValidLabelValue(taint.Value) {
+		problems = append(problems, fmt.Sprintf("taint %q value %q: %s", taint.Key, taint.Value, msg))
+	}
+	switch taint.Effect {
+	case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+	default:
+		problems = append(problems, fmt.Sprintf("taint %q: invalid effect %q", taint.Key, taint.Effect))
+	}
+	return problems
+}
+
+func validateResources(field string, resources corev1.ResourceList) []string {
+	var problems []string
+	for name, q := range resources {
+		if q.Sign() < 0 {
+			problems = append(problems, fmt.Sprintf("%s %s: quantity must not be negative", field, name))
+		}
+
+		switch {
+		case lo.Contains(standardNodeResources, name):
+		case strings.HasPrefix(string(name), corev1.ResourceAttachableVolumesPrefix):
+		case k8s.IsHugePageResourceName(name):
+			pageSize := strings.TrimPrefix(string(name), corev1.ResourceHugePagesPrefix)
+			if _, err := resource.ParseQuantity(pageSize); err != nil {
+				problems = append(problems, fmt.Sprintf("%s %s: invalid hugepage size %q", field, name, pageSize))
+			}
+		case k8s.IsExtendedResourceName(name):
+			for _, msg := range validation.IsQualifiedName(string(name)) {
+				problems = append(problems, fmt.Sprintf("%s %s: %s", field, name, msg))
+			}
+			if q.MilliValue()%1000 != 0 {
+				problems = append(problems, fmt.Sprintf("%s %s: extended resources must be whole numbers", field, name))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf(
+				"%s %s: unknown resource name (extended resources must be fully-qualified, e.g., example.com/%s)",
+				field, name, name,
+			))
+		}
+	}
+	return problems
+}
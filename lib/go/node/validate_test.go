@@ -0,0 +1,128 @@
+package node
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"simkube/lib/go/util"
+)
+
+func TestValidateSkeleton(t *testing.T) {
+	cases := map[string]struct {
+		skel             *corev1.Node
+		maxPods          int64
+		expectedProblems []string
+	}{
+		"valid": {
+			skel: &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{kubernetesArchLabel: expectedArch}},
+				Spec:       corev1.NodeSpec{Taints: []corev1.Taint{testTaint}},
+				Status: corev1.NodeStatus{
+					Capacity: corev1.ResourceList{
+						corev1.ResourceCPU:                     resource.MustParse("2"),
+						"hugepages-2Mi":                        resource.MustParse("1Gi"),
+						"attachable-volumes-aws-ebs":           resource.MustParse("25"),
+						corev1.ResourceName("example.com/foo"): resource.MustParse("4"),
+					},
+					Allocatable: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+				},
+			},
+		},
+		"bad labels and taints": {
+			skel: &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"foo/bar/baz": "ok", "ok": "not ok!"}},
+				Spec:       corev1.NodeSpec{Taints: []corev1.Taint{{Key: "foo", Effect: "Sometimes"}}},
+			},
+			expectedProblems: []string{
+				`label key "foo/bar/baz"`,
+				`label "ok" value "not ok!"`,
+				`invalid effect "Sometimes"`,
+			},
+		},
+		"bad resources": {
+			skel: &corev1.Node{
+				Status: corev1.NodeStatus{
+					Capacity: corev1.ResourceList{
+						"gpu":                 resource.MustParse("1"),
+						"hugepages-asdf":      resource.MustParse("1Gi"),
+						"example.com/foo":     resource.MustParse("500m"),
+						corev1.ResourceMemory: resource.MustParse("-1Gi"),
+					},
+				},
+			},
+			expectedProblems: []string{
+				"capacity gpu: unknown resource name",
+				`invalid hugepage size "asdf"`,
+				"extended resources must be whole numbers",
+				"capacity memory: quantity must not be negative",
+			},
+		},
+		"bad lifetime": {
+			skel: &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{util.NodeLifetimeAnnotation: "soon"}},
+			},
+			expectedProblems: []string{`node-lifetime-seconds must be a positive number of seconds, got "soon"`},
+		},
+		"allocatable larger than default capacity": {
+			skel: &corev1.Node{
+				Status: corev1.NodeStatus{
+					Allocatable: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+				},
+			},
+			expectedProblems: []string{"allocatable cpu (4) is larger than capacity (1)"},
+		},
+		"allocatable pods within max pods": {
+			skel: &corev1.Node{
+				Status: corev1.NodeStatus{
+					Allocatable: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("200")},
+				},
+			},
+			maxPods: 250,
+		},
+		"allocatable pods larger than max pods": {
+			skel: &corev1.Node{
+				Status: corev1.NodeStatus{
+					Allocatable: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("200")},
+				},
+			},
+			expectedProblems: []string{"allocatable pods (200) is larger than capacity (110)"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateSkeleton("skel.yml", tc.skel, tc.maxPods)
+			if len(tc.expectedProblems) == 0 {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidSkeleton)
+				for _, problem := range tc.expectedProblems {
+					assert.ErrorContains(t, err, problem)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateSkeletonFile(t *testing.T) {
+	dir := t.TempDir()
+	assert.ErrorIs(t, ValidateSkeletonFile(dir, 0), ErrInvalidSkeleton)
+
+	skelBytes, err := os.ReadFile(testSkelFile)
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "default.yml"), skelBytes, 0o600))
+	assert.Nil(t, ValidateSkeletonFile(dir, 0))
+	assert.Nil(t, ValidateSkeletonFile(filepath.Join(dir, "default.yml"), 0))
+
+	badSkel := "status:\n  capacity:\n    gpu: 1\n"
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "gpu.yaml"), []byte(badSkel), 0o600))
+	err = ValidateSkeletonFile(dir, 0)
+	assert.ErrorIs(t, err, ErrInvalidSkeleton)
+	assert.ErrorContains(t, err, "gpu.yaml")
+}
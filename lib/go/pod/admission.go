@@ -0,0 +1,115 @@
+package pod
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+
+	"simkube/lib/go/k8s"
+)
+
+const schedulingGatedReason = "SchedulingGated"
+
+// The scheduler should never place a pod on a node that doesn't have room for it, but pods
+// can bypass the scheduler (by setting spec.nodeName directly), and the scheduler's view of
+// the node can be stale.  The real kubelet re-checks the pod's requests at admission time,
+// and rejects the pod if it doesn't fit; we do the same thing here.
+//
+// CPU and memory are not checked, because a simulated node is happy to over-commit them;
+// however, extended resources (like GPUs) and hugepages are used to model hardware that
+// the pods actually need, so we enforce those.  We also enforce the node's pod capacity.
+func isAdmissionTrackedResource(name corev1.ResourceName) bool {
+	return k8s.IsExtendedResourceName(name) || k8s.IsHugePageResourceName(name)
+}
+
+func (self *podLifecycleHandler) admitPod(pod *corev1.Pod) (string, string, bool) {
+	// A pod that still has scheduling gates (e.g., Kueue's admission gate, which is only
+	// removed once its Workload is admitted) isn't supposed to run anywhere yet
+	if len(pod.Spec.SchedulingGates) > 0 {
+		gates := lo.Map(pod.Spec.SchedulingGates, func(g corev1.PodSchedulingGate, _ int) string { return g.Name })
+		message := fmt.Sprintf("Pod was rejected: Pod has scheduling gates: %s", strings.Join(gates, ", "))
+		return schedulingGatedReason, message, false
+	}
+
+	if maxPods, ok := self.allocatable[corev1.ResourcePods]; ok {
+		podName := k8s.NamespacedNameFromObjectMeta(pod.ObjectMeta)
+		if active := self.countActivePods(podName); active >= maxPods.Value() {
+			reason := fmt.Sprintf("OutOf%s", corev1.ResourcePods)
+			message := fmt.Sprintf(
+				"Pod was rejected: Node didn't have enough resource: %s, requested: 1, used: %d, capacity: %d",
+				corev1.ResourcePods,
+				active,
+				maxPods.Value(),
+			)
+			return reason, message, false
+		}
+	}
+
+	reqs := k8s.PodRequests(pod)
+	for name, req := range reqs {
+		if !isAdmissionTrackedResource(name) || req.IsZero() {
+			continue
+		}
+
+		available := self.allocatable[name]
+		used := self.allocated[name]
+		remaining := available.DeepCopy()
+		remaining.Sub(used)
+		if remaining.Cmp(req) < 0 {
+			reason := fmt.Sprintf("OutOf%s", name)
+			message := fmt.Sprintf(
+				"Pod was rejected: Node didn't have enough resource: %s, requested: %s, used: %s, capacity: %s",
+				name,
+				req.String(),
+				used.String(),
+				available.String(),
+			)
+			return reason, message, false
+		}
+	}
+
+	for name, req := range reqs {
+		if isAdmissionTrackedResource(name) {
+			total := self.allocated[name]
+			total.Add(req)
+			self.allocated[name] = total
+		}
+	}
+	return "", "", true
+}
+
+// Like the kubelet, only pods that haven't terminated count against the node's pod capacity
+func (self *podLifecycleHandler) countActivePods(excludePodName string) int64 {
+	self.lifetimeMutex.Lock()
+	defer self.lifetimeMutex.Unlock()
+
+	now := self.clock.Now()
+	active := int64(0)
+	for podName, pod := range self.pods {
+		if podName == excludePodName || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if endTime, ok := self.podEndTimes[podName]; ok && now.After(endTime) {
+			continue
+		}
+		active += 1
+	}
+	return active
+}
+
+func (self *podLifecycleHandler) releaseResources(pod *corev1.Pod) {
+	for name, req := range k8s.PodRequests(pod) {
+		if total, ok := self.allocated[name]; ok {
+			total.Sub(req)
+			self.allocated[name] = total
+		}
+	}
+}
+
+func (self *podLifecycleHandler) setRejectedStatus(pod *corev1.Pod, reason, message string) {
+	pod.Status.Phase = corev1.PodFailed
+	pod.Status.Reason = reason
+	pod.Status.Message = message
+}
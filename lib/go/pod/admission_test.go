@@ -0,0 +1,93 @@
+package pod
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/kueue"
+)
+
+func makeGPUPod(name string, gpus string) *corev1.Pod {
+	pod := makePod(nil, []corev1.Container{testContainer}, nil)
+	pod.ObjectMeta.Name = name
+	pod.Spec.Containers[0].Resources.Limits = corev1.ResourceList{
+		k8s.NvidiaGPUResource: resource.MustParse(gpus),
+	}
+	return pod
+}
+
+func TestCreatePodExtendedResources(t *testing.T) {
+	podHandler := makePodLifecycleHandler()
+	podHandler.SetAllocatable(corev1.ResourceList{k8s.NvidiaGPUResource: resource.MustParse("2")})
+
+	pod1 := makeGPUPod("pod1", "2")
+	pod2 := makeGPUPod("pod2", "1")
+
+	assert.Nil(t, podHandler.CreatePod(context.TODO(), pod1))
+	assert.Equal(t, corev1.PodRunning, pod1.Status.Phase)
+
+	assert.Nil(t, podHandler.CreatePod(context.TODO(), pod2))
+	assert.Equal(t, corev1.PodFailed, pod2.Status.Phase)
+	assert.Equal(t, "OutOfnvidia.com/gpu", pod2.Status.Reason)
+
+	// Deleting the rejected pod shouldn't free anything up, but deleting the running pod should
+	assert.Nil(t, podHandler.DeletePod(context.TODO(), pod2))
+	assert.True(t, resource.MustParse("2").Equal(podHandler.allocated[k8s.NvidiaGPUResource]))
+	assert.Nil(t, podHandler.DeletePod(context.TODO(), pod1))
+	assert.True(t, resource.MustParse("0").Equal(podHandler.allocated[k8s.NvidiaGPUResource]))
+
+	pod3 := makeGPUPod("pod3", "1")
+	assert.Nil(t, podHandler.CreatePod(context.TODO(), pod3))
+	assert.Equal(t, corev1.PodRunning, pod3.Status.Phase)
+}
+
+func TestCreatePodMaxPods(t *testing.T) {
+	c := clockwork.NewFakeClockAt(time.Time{})
+	podHandler := makePodLifecycleHandler(func(h *podLifecycleHandler) { h.clock = c })
+	podHandler.SetAllocatable(corev1.ResourceList{corev1.ResourcePods: resource.MustParse("2")})
+
+	lifetime := 10 * time.Second
+	pods := make([]*corev1.Pod, 4)
+	for i := range pods {
+		pods[i] = makePod(nil, []corev1.Container{testContainer}, &lifetime)
+		pods[i].ObjectMeta.Name = fmt.Sprintf("pod%d", i)
+	}
+
+	assert.Nil(t, podHandler.CreatePod(context.TODO(), pods[0]))
+	assert.Nil(t, podHandler.CreatePod(context.TODO(), pods[1]))
+	assert.Nil(t, podHandler.CreatePod(context.TODO(), pods[2]))
+	assert.Equal(t, corev1.PodRunning, pods[1].Status.Phase)
+	assert.Equal(t, corev1.PodFailed, pods[2].Status.Phase)
+	assert.Equal(t, "OutOfpods", pods[2].Status.Reason)
+
+	// Pods that have finished don't count against the pod capacity, even if they haven't
+	// been deleted yet
+	c.Advance(2 * lifetime)
+	assert.Nil(t, podHandler.CreatePod(context.TODO(), pods[3]))
+	assert.Equal(t, corev1.PodRunning, pods[3].Status.Phase)
+}
+
+func TestCreatePodSchedulingGated(t *testing.T) {
+	podHandler := makePodLifecycleHandler()
+	podHandler.SetAllocatable(corev1.ResourceList{k8s.NvidiaGPUResource: resource.MustParse("1")})
+
+	gated := makeGPUPod("gated", "1")
+	gated.Spec.SchedulingGates = []corev1.PodSchedulingGate{{Name: kueue.AdmissionGate}}
+	assert.Nil(t, podHandler.CreatePod(context.TODO(), gated))
+	assert.Equal(t, corev1.PodFailed, gated.Status.Phase)
+	assert.Equal(t, schedulingGatedReason, gated.Status.Reason)
+	assert.Contains(t, gated.Status.Message, kueue.AdmissionGate)
+
+	// The gated pod doesn't use up any of the node's resources
+	admitted := makeGPUPod("admitted", "1")
+	assert.Nil(t, podHandler.CreatePod(context.TODO(), admitted))
+	assert.Equal(t, corev1.PodRunning, admitted.Status.Phase)
+}
@@ -0,0 +1,56 @@
+package pod
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+
+	"simkube/lib/go/util"
+)
+
+// Pods that use Dynamic Resource Allocation can't start until their ResourceClaims have been
+// allocated (by the scheduler, or by us on the scheduler's behalf) and reserved for them, so they
+// stay in Pending until then; their lifetime doesn't start until they're running.
+type claimAllocator interface {
+	AllocateClaims(context.Context, *corev1.Pod) (bool, error)
+}
+
+func (self *podLifecycleHandler) needsClaims(pod *corev1.Pod) bool {
+	return self.claims != nil && len(pod.Spec.ResourceClaims) > 0
+}
+
+// checkClaims tries to allocate the claims for a pod that's waiting on them; once they're
+// allocated, the pod starts running (unless it's still being held for a scheduling delay).
+func (self *podLifecycleHandler) checkClaims(ctx context.Context, podName string) {
+	self.podsMutex.Lock()
+	pod, ok := self.pods[podName]
+	awaiting := self.podsAwaitingClaims[podName]
+	self.podsMutex.Unlock()
+	if !ok || !awaiting {
+		return
+	}
+
+	ctx = util.WithLogFields(ctx, log.Fields{"podName": podName})
+	logger := util.LoggerFromContext(ctx)
+	if allocated, err := self.claims.AllocateClaims(ctx, pod); err != nil {
+		logger.WithError(err).Warn("could not allocate resource claims")
+		return
+	} else if !allocated {
+		return
+	}
+
+	self.podsMutex.Lock()
+	defer self.podsMutex.Unlock()
+	if !self.podsAwaitingClaims[podName] {
+		return
+	}
+
+	logger.Info("Resource claims allocated")
+	delete(self.podsAwaitingClaims, podName)
+	now := self.clock.Now()
+	if startTime, ok := self.podStartTimes[podName]; !ok || startTime.Before(now) {
+		self.podStartTimes[podName] = now
+	}
+	self.startLifetime(ctx, podName, pod)
+}
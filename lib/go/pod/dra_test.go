@@ -0,0 +1,93 @@
+package pod
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type fakeClaimAllocator struct {
+	allocated bool
+	err       error
+	calls     int
+}
+
+func (self *fakeClaimAllocator) AllocateClaims(context.Context, *corev1.Pod) (bool, error) {
+	self.calls++
+	return self.allocated, self.err
+}
+
+func TestCreatePodAwaitingClaims(t *testing.T) {
+	cases := map[string]struct {
+		claims        []corev1.PodResourceClaim
+		expectPending bool
+	}{
+		"no claims": {},
+		"with claims": {
+			claims:        []corev1.PodResourceClaim{{Name: "gpus"}},
+			expectPending: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := clockwork.NewFakeClockAt(time.Time{})
+			alloc := &fakeClaimAllocator{}
+			podHandler := makePodLifecycleHandler(func(h *podLifecycleHandler) {
+				h.clock = c
+				h.claims = alloc
+			})
+			pod := makePod(nil, []corev1.Container{testContainer}, lo.ToPtr(5*time.Second))
+			pod.Spec.ResourceClaims = tc.claims
+
+			assert.Nil(t, podHandler.CreatePod(context.TODO(), pod))
+
+			status := func() *corev1.PodStatus {
+				status, err := podHandler.GetPodStatus(context.TODO(), testNamespace, testPodName)
+				assert.Nil(t, err)
+				return status
+			}
+
+			if tc.expectPending {
+				// The pod doesn't start (or start aging) until its claims are allocated
+				assert.Equal(t, corev1.PodPending, status().Phase)
+				alloc.err = errors.New("whoops")
+				c.Advance(10 * time.Second)
+				assert.Equal(t, corev1.PodPending, status().Phase)
+				alloc.err = nil
+				alloc.allocated = true
+			}
+
+			running := status()
+			assert.Equal(t, corev1.PodRunning, running.Phase)
+			assert.Equal(t, c.Now(), running.StartTime.Time)
+			c.Advance(4 * time.Second)
+			assert.Equal(t, corev1.PodRunning, status().Phase)
+			c.Advance(2 * time.Second)
+			assert.Equal(t, corev1.PodSucceeded, status().Phase)
+
+			if tc.expectPending {
+				assert.Equal(t, 3, alloc.calls)
+			} else {
+				assert.Zero(t, alloc.calls)
+			}
+		})
+	}
+}
+
+func TestDeletePodAwaitingClaims(t *testing.T) {
+	podHandler := makePodLifecycleHandler(func(h *podLifecycleHandler) { h.claims = &fakeClaimAllocator{} })
+	pod := makePod(nil, []corev1.Container{testContainer}, nil)
+	pod.Spec.ResourceClaims = []corev1.PodResourceClaim{{Name: "gpus"}}
+
+	assert.Nil(t, podHandler.CreatePod(context.TODO(), pod))
+	assert.True(t, podHandler.podsAwaitingClaims[testPodFullName])
+	assert.Nil(t, podHandler.DeletePod(context.TODO(), pod))
+	assert.Empty(t, podHandler.podsAwaitingClaims)
+}
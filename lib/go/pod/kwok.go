@@ -0,0 +1,46 @@
+package pod
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"simkube/lib/go/util"
+)
+
+// kwok's stages read how long a pod runs for from these annotations (as Go durations); if the
+// jitter delay is larger than the delay, the pod runs for a random time between the two
+const (
+	kwokCompleteDelayAnnotation       = "pod-complete.stage.kwok.x-k8s.io/delay"
+	kwokCompleteJitterDelayAnnotation = "pod-complete.stage.kwok.x-k8s.io/jitter-delay"
+)
+
+// setKwokLifetime gives pods that use kwok's stage annotations (instead of the simkube lifetime
+// annotation) the lifetime that kwok would; unparseable annotations are treated the same way as
+// an unparseable lifetime annotation, and the pod never terminates
+func (self *podLifecycleHandler) setKwokLifetime(ctx context.Context, podName string, pod *corev1.Pod) {
+	logger := util.LoggerFromContext(ctx)
+
+	delayStr, ok := pod.ObjectMeta.Annotations[kwokCompleteDelayAnnotation]
+	if !ok {
+		return
+	}
+	delay, err := time.ParseDuration(delayStr)
+	if err != nil {
+		logger.Warn("Could not parse kwok delay annotation, pod will not terminate")
+		return
+	}
+
+	if jitterStr, ok := pod.ObjectMeta.Annotations[kwokCompleteJitterDelayAnnotation]; ok {
+		jitter, err := time.ParseDuration(jitterStr)
+		if err != nil {
+			logger.Warn("Could not parse kwok jitter delay annotation, ignoring it")
+		} else if jitter > delay {
+			//nolint:gosec // this doesn't need to be cryptographically secure
+			delay += time.Duration(rand.Int63n(int64(jitter - delay)))
+		}
+	}
+	self.setLifetime(ctx, podName, pod, delay)
+}
@@ -0,0 +1,73 @@
+package pod
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestCreatePodKwokLifetime(t *testing.T) {
+	cases := map[string]struct {
+		kwokCompat  bool
+		annotations map[string]string
+		expectedMin time.Duration
+		expectedMax time.Duration
+		expectedSet bool
+	}{
+		"delay": {
+			kwokCompat:  true,
+			annotations: map[string]string{kwokCompleteDelayAnnotation: "5s"},
+			expectedMin: 5 * time.Second,
+			expectedMax: 5 * time.Second,
+			expectedSet: true,
+		},
+		"jitter": {
+			kwokCompat: true,
+			annotations: map[string]string{
+				kwokCompleteDelayAnnotation:       "5s",
+				kwokCompleteJitterDelayAnnotation: "10s",
+			},
+			expectedMin: 5 * time.Second,
+			expectedMax: 10 * time.Second,
+			expectedSet: true,
+		},
+		"simkube lifetime wins": {
+			kwokCompat:  true,
+			annotations: map[string]string{lifetimeAnnotationKey: "3", kwokCompleteDelayAnnotation: "5s"},
+			expectedMin: 3 * time.Second,
+			expectedMax: 3 * time.Second,
+			expectedSet: true,
+		},
+		"unparseable": {
+			kwokCompat:  true,
+			annotations: map[string]string{kwokCompleteDelayAnnotation: "asdf"},
+		},
+		"not compatible": {
+			annotations: map[string]string{kwokCompleteDelayAnnotation: "5s"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := clockwork.NewFakeClockAt(time.Time{})
+			pod := makePod(nil, []corev1.Container{testContainer}, nil)
+			pod.ObjectMeta.Annotations = tc.annotations
+			podHandler := makePodLifecycleHandler(func(h *podLifecycleHandler) {
+				h.clock = c
+				h.kwokCompat = tc.kwokCompat
+			})
+
+			assert.Nil(t, podHandler.CreatePod(context.TODO(), pod))
+			endTime, ok := podHandler.podEndTimes[testPodFullName]
+			assert.Equal(t, tc.expectedSet, ok)
+			if tc.expectedSet {
+				assert.GreaterOrEqual(t, endTime.Sub(c.Now()), tc.expectedMin)
+				assert.LessOrEqual(t, endTime.Sub(c.Now()), tc.expectedMax)
+			}
+		})
+	}
+}
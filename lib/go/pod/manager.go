@@ -2,62 +2,131 @@ package pod
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/virtual-kubelet/virtual-kubelet/node"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/dra"
 	"simkube/lib/go/util"
 )
 
 const (
 	podSyncWorkers       = 1
 	informerResyncPeriod = 30 * time.Second
+
+	// Pods that take a while to terminate hold up the sync worker that's deleting them, so
+	// more workers are needed to keep up with the rest of the pods (e.g., during an eviction)
+	terminatingPodSyncWorkers = 10
 )
 
+var simulationGVR = simkubev1.GroupVersion.WithResource("simulations") //nolint:gochecknoglobals
+
+var errPodControllerNotRunning = util.WithKind(util.ErrRetriable, errors.New("pod controller is not running"))
+
 type LifecycleManagerI interface {
-	Run(context.Context, context.CancelCauseFunc)
+	Run(context.Context, context.CancelCauseFunc, *corev1.Node)
+	SetNodeDown(bool)
+	TerminatePods()
+	Ready() error
+	Healthy() error
+}
+
+type Options struct {
+	// KwokCompat lets pods use kwok's pod-complete stage annotations instead of the simkube
+	// lifetime annotation
+	KwokCompat bool
+
+	// TerminationDelay is how long running pods take to shut down when they're deleted or
+	// evicted (capped at their grace period); if TerminationDelayMax is larger, the delay is
+	// chosen at random between the two
+	TerminationDelay    time.Duration
+	TerminationDelayMax time.Duration
+
+	// DRADevices are the devices that ResourceClaims are allocated from; if nil, pods'
+	// ResourceClaims are ignored
+	DRADevices *dra.DeviceConfig
 }
 
 type LifecycleManager struct {
-	nodeName   string
-	k8sClient  kubernetes.Interface
-	podHandler node.PodLifecycleHandler
-	logger     *log.Entry
+	nodeName      string
+	k8sClient     kubernetes.Interface
+	dynamicClient dynamic.Interface
+	podHandler    podLifecycleHandlerI
+	syncWorkers   int
+	logger        *log.Entry
+
+	mutex   sync.Mutex
+	podCtrl util.ControllerStatus
 }
 
-func NewLifecycleManager(nodeName string, k8sClient kubernetes.Interface) *LifecycleManager {
-	podHandler := newPodHandler(nodeName)
+func NewLifecycleManager(
+	nodeName string,
+	k8sClient kubernetes.Interface,
+	dynamicClient dynamic.Interface,
+	logSampler *util.LogSampler,
+	opts Options,
+) *LifecycleManager {
+	podHandler := newPodHandler(logSampler, opts)
+	if opts.DRADevices != nil {
+		podHandler.claims = dra.NewAllocator(dynamicClient, nodeName, opts.DRADevices)
+	}
+	syncWorkers := podSyncWorkers
+	if opts.TerminationDelay > 0 || opts.TerminationDelayMax > 0 {
+		syncWorkers = terminatingPodSyncWorkers
+	}
 	return &LifecycleManager{
-		nodeName:   nodeName,
-		k8sClient:  k8sClient,
-		podHandler: podHandler,
-		logger:     util.GetLogger(nodeName),
+		nodeName:      nodeName,
+		k8sClient:     k8sClient,
+		dynamicClient: dynamicClient,
+		podHandler:    podHandler,
+		syncWorkers:   syncWorkers,
+		logger:        util.GetLogger(nodeName),
 	}
 }
 
-func (self *LifecycleManager) Run(ctx context.Context, cancel context.CancelCauseFunc) {
+func (self *LifecycleManager) Run(ctx context.Context, cancel context.CancelCauseFunc, n *corev1.Node) {
 	self.logger.Info("Starting pod manager...")
 
+	self.podHandler.SetAllocatable(n.Status.Allocatable)
+	delays, err := parseSchedulerDelays(n.ObjectMeta.Annotations)
+	if err != nil {
+		self.logger.WithError(err).Warn("could not read scheduler delays, pods won't be delayed")
+	}
+	self.podHandler.SetSchedulerDelays(delays)
+	self.watchNode(ctx)
+	self.watchSimulations(ctx)
+
 	podCtrlConfig := self.makePodControllerConfig(ctx)
 	podCtrl, err := node.NewPodController(podCtrlConfig)
 	if err != nil {
 		cancel(fmt.Errorf("could not create pod controller: %w", err))
 		return
 	}
+	self.mutex.Lock()
+	self.podCtrl = podCtrl
+	self.mutex.Unlock()
 
 	go func() {
-		if err := podCtrl.Run(ctx, podSyncWorkers); err != nil {
+		if err := podCtrl.Run(ctx, self.syncWorkers); err != nil {
 			cancel(fmt.Errorf("could not run pod controller: %w", err))
 		}
 	}()
@@ -71,6 +140,117 @@ func (self *LifecycleManager) Run(ctx context.Context, cancel context.CancelCaus
 	self.logger.Info("Pod manager running!")
 }
 
+// SetNodeDown simulates a crashed node; while the node is down, all running pods report
+// their status as Unknown
+func (self *LifecycleManager) SetNodeDown(down bool) {
+	self.podHandler.SetNodeDown(down)
+}
+
+// TerminatePods marks all running pods as Failed, as though the node was shut down
+func (self *LifecycleManager) TerminatePods() {
+	self.podHandler.TerminatePods()
+}
+
+// Ready returns nil once the pod controller has started watching pods and is still running
+func (self *LifecycleManager) Ready() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.podCtrl == nil {
+		return errPodControllerNotRunning
+	}
+	return util.ControllerReady("pod", self.podCtrl)
+}
+
+// Healthy returns an error if the pod controller has stopped; a pod controller that is still
+// starting up is considered healthy
+func (self *LifecycleManager) Healthy() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.podCtrl == nil {
+		return nil
+	}
+	return util.ControllerRunning("pod", self.podCtrl)
+}
+
+// watchNode keeps the pods' admission checks up to date with the node's allocatable resources,
+// which can change while the node is running (from the admin API, the allocatable schedule,
+// skeleton reloads, or someone editing the node object)
+func (self *LifecycleManager) watchNode(ctx context.Context) {
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		self.k8sClient,
+		informerResyncPeriod,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", self.nodeName).String()
+		}),
+	)
+	if _, err := factory.Core().V1().Nodes().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    self.nodeChanged,
+		UpdateFunc: func(_, obj interface{}) { self.nodeChanged(obj) },
+	}); err != nil {
+		self.logger.WithError(err).Warn("could not watch node object, allocatable changes won't be enforced")
+		return
+	}
+	factory.Start(ctx.Done())
+}
+
+func (self *LifecycleManager) nodeChanged(obj interface{}) {
+	if n, ok := obj.(*corev1.Node); ok && n.ObjectMeta.Name == self.nodeName {
+		self.podHandler.SetAllocatable(n.Status.Allocatable)
+	}
+}
+
+// watchSimulations keeps track of which simulations are paused, so that the lifetimes of their pods
+// can be frozen; if the Simulation CRD isn't installed (e.g., if the virtual nodes are being used
+// without the rest of simkube), there's nothing to watch.
+func (self *LifecycleManager) watchSimulations(ctx context.Context) {
+	_, err := self.dynamicClient.Resource(simulationGVR).List(ctx, metav1.ListOptions{Limit: 1})
+	if apierrors.IsNotFound(err) {
+		self.logger.Info("Simulation CRD is not installed, pod lifetimes can't be paused")
+		return
+	} else if err != nil {
+		self.logger.WithError(err).Warn("could not list simulations, pod lifetimes can't be paused")
+		return
+	}
+
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(self.dynamicClient, informerResyncPeriod)
+	if _, err := factory.ForResource(simulationGVR).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    self.simulationChanged,
+		UpdateFunc: func(_, obj interface{}) { self.simulationChanged(obj) },
+		DeleteFunc: self.simulationDeleted,
+	}); err != nil {
+		self.logger.WithError(err).Warn("could not watch simulations, pod lifetimes can't be paused")
+		return
+	}
+	factory.Start(ctx.Done())
+}
+
+func (self *LifecycleManager) simulationChanged(obj interface{}) {
+	sim, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		self.logger.Warnf("unexpected simulation object: %T", obj)
+		return
+	}
+
+	paused, _, err := unstructured.NestedBool(sim.Object, "spec", "paused")
+	if err != nil {
+		self.logger.WithError(err).Warnf("invalid paused field on simulation %s", sim.GetName())
+		return
+	}
+	self.podHandler.SetSimulationPaused(sim.GetName(), paused)
+}
+
+// Once the simulation is gone, there's no reason to keep its pods frozen
+func (self *LifecycleManager) simulationDeleted(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if sim, ok := obj.(metav1.Object); ok {
+		self.podHandler.SetSimulationPaused(sim.GetName(), false)
+	}
+}
+
 func (self *LifecycleManager) makePodControllerConfig(ctx context.Context) node.PodControllerConfig {
 	podInformerFactory := informers.NewSharedInformerFactoryWithOptions(
 		self.k8sClient,
@@ -110,13 +290,31 @@ func (self *LifecycleManager) makePodControllerConfig(ctx context.Context) node.
 		corev1.EventSource{Component: path.Join(self.nodeName, "pod-controller")},
 	)
 
+	syncPodsQueue := newQueueDepthRateLimiter()
+	syncStatusQueue := newQueueDepthRateLimiter()
+	registerMetrics(
+		podInformer,
+		map[string]cache.SharedIndexInformer{
+			"pods":       podInformer.Informer(),
+			"secrets":    secretInformer.Informer(),
+			"configmaps": cmInformer.Informer(),
+			"services":   svcInformer.Informer(),
+		},
+		map[string]*queueDepthRateLimiter{
+			"syncPodsFromKubernetes":    syncPodsQueue,
+			"syncPodStatusFromProvider": syncStatusQueue,
+		},
+	)
+
 	return node.PodControllerConfig{
-		PodClient:         self.k8sClient.CoreV1(),
-		EventRecorder:     recorder,
-		Provider:          self.podHandler,
-		PodInformer:       podInformer,
-		SecretInformer:    secretInformer,
-		ConfigMapInformer: cmInformer,
-		ServiceInformer:   svcInformer,
+		PodClient:                            self.k8sClient.CoreV1(),
+		EventRecorder:                        recorder,
+		Provider:                             self.podHandler,
+		PodInformer:                          podInformer,
+		SecretInformer:                       secretInformer,
+		ConfigMapInformer:                    cmInformer,
+		ServiceInformer:                      svcInformer,
+		SyncPodsFromKubernetesRateLimiter:    syncPodsQueue,
+		SyncPodStatusFromProviderRateLimiter: syncStatusQueue,
 	}
 }
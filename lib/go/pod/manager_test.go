@@ -3,23 +3,149 @@ package pod
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
 
+	"simkube/lib/go/k8s"
 	"simkube/lib/go/testutils"
 )
 
 func TestPodManagerRun(t *testing.T) {
 	plm := &LifecycleManager{
-		nodeName:   "test-node",
-		k8sClient:  fake.NewSimpleClientset(),
-		podHandler: testutils.NewPodHandler(),
-		logger:     testutils.GetFakeLogger(),
+		nodeName:      "test-node",
+		k8sClient:     fake.NewSimpleClientset(),
+		dynamicClient: newSimulationDynamicClient(),
+		podHandler:    testutils.NewPodHandler(),
+		syncWorkers:   podSyncWorkers,
+		logger:        testutils.GetFakeLogger(),
 	}
 
 	ctx, cancel := context.WithCancelCause(context.TODO())
-	plm.Run(ctx, cancel)
+	plm.Run(ctx, cancel, &corev1.Node{})
 
 	assert.Nil(t, context.Cause(ctx))
 }
+
+func TestPodManagerWatchNode(t *testing.T) {
+	n := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: testNodeName},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{k8s.NvidiaGPUResource: resource.MustParse("2")},
+		},
+	}
+	k8sClient := fake.NewSimpleClientset(n)
+	podHandler := makePodLifecycleHandler()
+	plm := &LifecycleManager{
+		nodeName:   testNodeName,
+		k8sClient:  k8sClient,
+		podHandler: podHandler,
+		logger:     testutils.GetFakeLogger(),
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	plm.watchNode(ctx)
+
+	gpus := func() int64 {
+		podHandler.podsMutex.Lock()
+		defer podHandler.podsMutex.Unlock()
+		q := podHandler.allocatable[k8s.NvidiaGPUResource]
+		return q.Value()
+	}
+	assert.Eventually(t, func() bool { return gpus() == 2 }, time.Second, 10*time.Millisecond)
+
+	// After the allocatable resources shrink, pods are admitted against the new values
+	n.Status.Allocatable = corev1.ResourceList{k8s.NvidiaGPUResource: resource.MustParse("1")}
+	_, err := k8sClient.CoreV1().Nodes().UpdateStatus(ctx, n, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool { return gpus() == 1 }, time.Second, 10*time.Millisecond)
+
+	pod := makeGPUPod("pod1", "2")
+	assert.Nil(t, podHandler.CreatePod(context.TODO(), pod))
+	assert.Equal(t, corev1.PodFailed, pod.Status.Phase)
+	assert.Equal(t, "OutOfnvidia.com/gpu", pod.Status.Reason)
+}
+
+func newSimulationDynamicClient(objs ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		scheme.Scheme,
+		map[schema.GroupVersionResource]string{simulationGVR: "SimulationList"},
+		objs...,
+	)
+}
+
+func TestPodManagerWatchSimulations(t *testing.T) {
+	sim := &unstructured.Unstructured{}
+	sim.SetAPIVersion("simkube.io/v1")
+	sim.SetKind("Simulation")
+	sim.SetName("the-sim")
+	unstructured.SetNestedField(sim.Object, true, "spec", "paused") //nolint:errcheck // can't fail
+
+	podHandler := makePodLifecycleHandler()
+	dynamicClient := newSimulationDynamicClient(sim)
+	plm := &LifecycleManager{
+		nodeName:      testNodeName,
+		dynamicClient: dynamicClient,
+		podHandler:    podHandler,
+		logger:        testutils.GetFakeLogger(),
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	plm.watchSimulations(ctx)
+
+	paused := func() bool {
+		podHandler.lifetimeMutex.Lock()
+		defer podHandler.lifetimeMutex.Unlock()
+		return podHandler.pausedSims["the-sim"]
+	}
+	assert.Eventually(t, paused, time.Second, 10*time.Millisecond)
+
+	err := dynamicClient.Resource(simulationGVR).Delete(ctx, "the-sim", metav1.DeleteOptions{})
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool { return !paused() }, time.Second, 10*time.Millisecond)
+}
+
+func TestPodManagerWatchSimulationsNoCRD(t *testing.T) {
+	dynamicClient := newSimulationDynamicClient()
+	dynamicClient.PrependReactor("list", "simulations", func(_ k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewNotFound(simulationGVR.GroupResource(), "")
+	})
+	plm := &LifecycleManager{
+		nodeName:      testNodeName,
+		dynamicClient: dynamicClient,
+		podHandler:    testutils.NewPodHandler(),
+		syncWorkers:   podSyncWorkers,
+		logger:        testutils.GetFakeLogger(),
+	}
+
+	// Nothing to do, but that's not an error
+	plm.watchSimulations(context.TODO())
+	assert.Len(t, dynamicClient.Actions(), 1)
+}
+
+func TestQueueDepthRateLimiter(t *testing.T) {
+	rl := newQueueDepthRateLimiter()
+	rl.When("default/foo")
+	rl.When("default/bar")
+	// Retries go through When again without forgetting the key first
+	rl.When("default/foo")
+	assert.Equal(t, float64(2), rl.depth())
+
+	rl.Forget("default/foo")
+	assert.Equal(t, float64(1), rl.depth())
+	rl.Forget("default/baz")
+	assert.Equal(t, float64(1), rl.depth())
+}
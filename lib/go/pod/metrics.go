@@ -0,0 +1,137 @@
+package pod
+
+import (
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"simkube/lib/go/metrics"
+)
+
+const (
+	podsByPhaseMetric   = "simkube_vnode_pods"
+	queueDepthMetric    = "simkube_vnode_pod_controller_queue_depth"
+	informerCacheMetric = "simkube_vnode_informer_cache_objects"
+)
+
+// The scheduling latency is a running total, like the _sum of a histogram, so that the average
+// latency for each scheduler is the rate of the total divided by the rate of the count
+//
+//nolint:gochecknoglobals
+var (
+	podsScheduled = metrics.Default.NewCounter(
+		"simkube_vnode_pods_scheduled_total",
+		"Number of pods placed on the virtual node, by scheduler",
+		"scheduler",
+	)
+	schedulingLatencySeconds = metrics.Default.NewCounter(
+		"simkube_vnode_pod_scheduling_latency_seconds_total",
+		"Total time the pods placed on the virtual node took to be scheduled, including any simulated delay, by scheduler",
+		"scheduler",
+	)
+)
+
+// virtual-kubelet doesn't expose the lengths of the pod controller's work queues, but it asks the
+// rate limiter when every key is added to the sync queues, and tells it to forget the key once it
+// has been handled (unless it's being retried), so the rate limiter knows how many keys are
+// waiting to be (or are being) synced.
+type queueDepthRateLimiter struct {
+	workqueue.RateLimiter
+
+	mutex   sync.Mutex
+	pending map[interface{}]bool
+}
+
+func newQueueDepthRateLimiter() *queueDepthRateLimiter {
+	return &queueDepthRateLimiter{
+		RateLimiter: workqueue.DefaultControllerRateLimiter(),
+		pending:     map[interface{}]bool{},
+	}
+}
+
+func (self *queueDepthRateLimiter) When(item interface{}) time.Duration {
+	self.mutex.Lock()
+	self.pending[item] = true
+	self.mutex.Unlock()
+	return self.RateLimiter.When(item)
+}
+
+func (self *queueDepthRateLimiter) Forget(item interface{}) {
+	self.mutex.Lock()
+	delete(self.pending, item)
+	self.mutex.Unlock()
+	self.RateLimiter.Forget(item)
+}
+
+func (self *queueDepthRateLimiter) depth() float64 {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return float64(len(self.pending))
+}
+
+// registerMetrics exports the pod controller's state; the pod phases come from the informer
+// cache (i.e., what the API server knows), so they lag slightly behind the pod handler
+func registerMetrics(
+	podInformer corev1informers.PodInformer,
+	informers map[string]cache.SharedIndexInformer,
+	queues map[string]*queueDepthRateLimiter,
+) {
+	metrics.Default.GaugeVecFunc(
+		podsByPhaseMetric,
+		"Number of pods on the virtual node, by phase",
+		"phase",
+		func() map[string]float64 {
+			counts := map[string]float64{}
+			for _, phase := range []corev1.PodPhase{
+				corev1.PodPending, corev1.PodRunning, corev1.PodSucceeded, corev1.PodFailed, corev1.PodUnknown,
+			} {
+				counts[string(phase)] = 0
+			}
+
+			pods, err := podInformer.Lister().List(labels.Everything())
+			if err != nil {
+				return counts
+			}
+			for _, pod := range pods {
+				// Pods that haven't been synced yet don't have a phase
+				phase := pod.Status.Phase
+				if phase == "" {
+					phase = corev1.PodPending
+				}
+				counts[string(phase)]++
+			}
+			return counts
+		},
+	)
+
+	metrics.Default.GaugeVecFunc(
+		queueDepthMetric,
+		"Number of pods waiting to be synced by the pod controller, by queue",
+		"queue",
+		func() map[string]float64 {
+			depths := map[string]float64{}
+			for name, q := range queues {
+				depths[name] = q.depth()
+			}
+			return depths
+		},
+	)
+
+	metrics.Default.GaugeVecFunc(
+		informerCacheMetric,
+		"Number of objects in the pod controller's informer caches, by resource",
+		"resource",
+		func() map[string]float64 {
+			sizes := map[string]float64{}
+			for resource, informer := range informers {
+				sizes[resource] = float64(len(informer.GetStore().ListKeys()))
+			}
+			return sizes
+		},
+	)
+}
@@ -3,65 +3,233 @@ package pod
 import (
 	"context"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/jonboulle/clockwork"
 	"github.com/samber/lo"
+	log "github.com/sirupsen/logrus"
 	vkerr "github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/node"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"simkube/lib/go/audit"
 	"simkube/lib/go/k8s"
+	"simkube/lib/go/kueue"
 	"simkube/lib/go/util"
 )
 
-const lifetimeAnnotationKey = "simkube.io/lifetime-seconds"
+const (
+	lifetimeAnnotationKey = "simkube.io/lifetime-seconds"
+	simulationLabelKey    = "simkube.io/simulation"
+
+	podCompletedReason       = "PodCompleted"
+	containerCompletedReason = "Completed"
+	nodeLostReason           = "NodeLost"
+	nodeLostMessage          = "Node which was running the pod is unresponsive"
+	nodeShutdownReason       = "Terminated"
+	nodeShutdownMessage      = "Pod was terminated in response to imminent node shutdown."
+	nodeShutdownExitCode     = 137
+)
 
 var ErrorPodNotFound = vkerr.NotFound("pod not found")
 
+type podLifecycleHandlerI interface {
+	node.PodLifecycleHandler
+	SetAllocatable(corev1.ResourceList)
+	SetSchedulerDelays(map[string]time.Duration)
+	SetNodeDown(bool)
+	TerminatePods()
+	SetSimulationPaused(string, bool)
+}
+
 type podLifecycleHandler struct {
-	nodeName    string
-	pods        map[string]*corev1.Pod
-	podEndTimes map[string]time.Time
-	clock       clockwork.Clock
+	// The pods (and the resources they've claimed, below) are guarded by podsMutex, since pods
+	// can be deleted concurrently while they're terminating
+	podsMutex sync.Mutex
+	pods      map[string]*corev1.Pod
+	clock     clockwork.Clock
+
+	// Pods that are held in Pending to simulate a slower scheduler start running at these times
+	podStartTimes   map[string]time.Time
+	schedulerDelays map[string]time.Duration
+
+	// In kwok compatibility mode, pods without a lifetime annotation can use kwok's instead
+	kwokCompat bool
+
+	// Running pods take between terminationDelayMin and terminationDelayMax to shut down
+	// when they're deleted
+	terminationDelayMin time.Duration
+	terminationDelayMax time.Duration
+
+	// Pods with ResourceClaims wait in Pending until the claims are allocated; if claims is
+	// nil, DRA isn't simulated and the claims are ignored
+	claims             claimAllocator
+	podsAwaitingClaims map[string]bool
+
+	// virtual-kubelet polls every pod's status every few seconds, so the messages for the
+	// read paths get sampled
+	logSampler *util.LogSampler
+
+	// The lifetimes of pods in a paused simulation are frozen: when the simulation is paused,
+	// we record how much time each of its pods has left, and when it's resumed, the pods get
+	// that much time again
+	lifetimeMutex sync.Mutex
+	podEndTimes   map[string]time.Time
+	podsEnded     map[string]bool
+	podRemaining  map[string]time.Duration
+	podSims       map[string]string
+	pausedSims    map[string]bool
+
+	// Resources that the node has available, and that have been claimed by admitted pods;
+	// only resources that the kubelet checks at admission time are tracked here
+	allocatable corev1.ResourceList
+	allocated   corev1.ResourceList
+
+	// Simulated node failures: while the node is down all running pods report as Unknown,
+	// and once the node has been terminated all running pods report as Failed
+	stateMutex     sync.RWMutex
+	nodeDown       bool
+	nodeTerminated bool
 }
 
-func newPodHandler(nodeName string) *podLifecycleHandler {
+func newPodHandler(logSampler *util.LogSampler, opts Options) *podLifecycleHandler {
 	return &podLifecycleHandler{
-		nodeName,
-		map[string]*corev1.Pod{},
-		map[string]time.Time{},
-		clockwork.NewRealClock(),
+		pods:                map[string]*corev1.Pod{},
+		clock:               clockwork.NewRealClock(),
+		podStartTimes:       map[string]time.Time{},
+		podsAwaitingClaims:  map[string]bool{},
+		kwokCompat:          opts.KwokCompat,
+		terminationDelayMin: opts.TerminationDelay,
+		terminationDelayMax: opts.TerminationDelayMax,
+		logSampler:          logSampler,
+		podEndTimes:         map[string]time.Time{},
+		podsEnded:           map[string]bool{},
+		podRemaining:        map[string]time.Duration{},
+		podSims:             map[string]string{},
+		pausedSims:          map[string]bool{},
+		allocatable:         corev1.ResourceList{},
+		allocated:           corev1.ResourceList{},
+	}
+}
+
+func (self *podLifecycleHandler) SetAllocatable(allocatable corev1.ResourceList) {
+	self.podsMutex.Lock()
+	defer self.podsMutex.Unlock()
+	self.allocatable = allocatable.DeepCopy()
+}
+
+func (self *podLifecycleHandler) SetSchedulerDelays(delays map[string]time.Duration) {
+	self.schedulerDelays = delays
+}
+
+func (self *podLifecycleHandler) SetNodeDown(down bool) {
+	self.stateMutex.Lock()
+	defer self.stateMutex.Unlock()
+	self.nodeDown = down
+}
+
+func (self *podLifecycleHandler) TerminatePods() {
+	self.stateMutex.Lock()
+	defer self.stateMutex.Unlock()
+	self.nodeTerminated = true
+}
+
+// SetSimulationPaused freezes (or unfreezes) the lifetimes of all the pods in the simulation
+func (self *podLifecycleHandler) SetSimulationPaused(simName string, paused bool) {
+	self.lifetimeMutex.Lock()
+	defer self.lifetimeMutex.Unlock()
+
+	if self.pausedSims[simName] == paused {
+		return
+	} else if paused {
+		self.pausedSims[simName] = true
+	} else {
+		delete(self.pausedSims, simName)
+	}
+
+	now := self.clock.Now()
+	for podName, podSim := range self.podSims {
+		if podSim != simName {
+			continue
+		}
+
+		if paused {
+			// Pods that have already finished stay finished
+			if endTime, ok := self.podEndTimes[podName]; ok && endTime.After(now) {
+				self.podRemaining[podName] = endTime.Sub(now)
+				delete(self.podEndTimes, podName)
+			}
+		} else if remaining, ok := self.podRemaining[podName]; ok {
+			self.podEndTimes[podName] = now.Add(remaining)
+			delete(self.podRemaining, podName)
+		}
 	}
 }
 
 func (self *podLifecycleHandler) CreatePod(ctx context.Context, pod *corev1.Pod) error {
 	podName := k8s.NamespacedNameFromObjectMeta(pod.ObjectMeta)
-	logger := util.GetLogger(self.nodeName, "podName", podName)
+	ctx = util.WithLogFields(ctx, log.Fields{"podName": podName})
+	queueDetails := kueue.QueueDetails(pod.ObjectMeta.Labels)
+	if queueDetails != nil {
+		ctx = util.WithLogFields(ctx, log.Fields{"queue": queueDetails["queue"]})
+	}
+	logger := util.LoggerFromContext(ctx)
 	logger.Info("Creating pod")
 
-	self.setRunningStatus(pod)
-
-	if pod.ObjectMeta.Annotations != nil {
-		if lifetime_str, ok := pod.ObjectMeta.Annotations[lifetimeAnnotationKey]; ok {
-			lifetime_seconds, err := strconv.Atoi(lifetime_str)
-			if err != nil {
-				logger.Warn("Could not parse lifetime annotation, pod will not terminate")
-			} else {
-				endTime := self.clock.Now().Add(time.Duration(lifetime_seconds) * time.Second)
-				self.podEndTimes[podName] = endTime
-				logger.Infof("pod end time recorded at %v", endTime)
-			}
-		}
+	self.podsMutex.Lock()
+	defer self.podsMutex.Unlock()
+
+	now := self.clock.Now()
+	scheduler := schedulerName(pod)
+	delay := self.schedulingDelay(ctx, pod)
+	podsScheduled.Inc(scheduler)
+	schedulingLatencySeconds.Add((schedulingLatency(pod, now) + delay).Seconds(), scheduler)
+	details := lo.Assign(queueDetails, map[string]string{"scheduler": scheduler})
+
+	if reason, message, ok := self.admitPod(pod); !ok {
+		logger.Warnf("Pod rejected: %s", message)
+		self.setRejectedStatus(pod, reason, message)
+		self.pods[podName] = pod
+		audit.Record(audit.Event{
+			Action:  audit.PodRejected,
+			Object:  podName,
+			Reason:  reason,
+			Details: lo.Assign(details, map[string]string{"message": message}),
+		})
+		return nil
+	}
+
+	awaitingClaims := self.needsClaims(pod)
+	if delay > 0 || awaitingClaims {
+		setPendingStatus(pod)
+	} else {
+		self.setRunningStatus(pod, now)
+	}
+
+	if delay > 0 {
+		logger.Infof("Holding pod in Pending for %v to simulate %s", delay, scheduler)
+		self.podStartTimes[podName] = now.Add(delay)
+		details["schedulingDelay"] = delay.String()
+	}
+
+	if awaitingClaims {
+		logger.Info("Holding pod in Pending until its resource claims are allocated")
+		self.podsAwaitingClaims[podName] = true
+		details["awaitingClaims"] = "true"
+	} else {
+		self.startLifetime(ctx, podName, pod)
 	}
 
 	self.pods[podName] = pod
+	audit.Record(audit.Event{Action: audit.PodCreated, Object: podName, Details: details})
 	return nil
 }
 
 func (self *podLifecycleHandler) UpdatePod(ctx context.Context, pod *corev1.Pod) error {
 	podName := k8s.NamespacedNameFromObjectMeta(pod.ObjectMeta)
-	logger := util.GetLogger(self.nodeName, "podName", podName)
+	logger := self.logSampler.Sample("UpdatePod", util.LoggerFromContext(ctx).WithField("podName", podName))
 	logger.Info("Updating pod")
 
 	return nil
@@ -69,18 +237,47 @@ func (self *podLifecycleHandler) UpdatePod(ctx context.Context, pod *corev1.Pod)
 
 func (self *podLifecycleHandler) DeletePod(ctx context.Context, pod *corev1.Pod) error {
 	podName := k8s.NamespacedNameFromObjectMeta(pod.ObjectMeta)
-	logger := util.GetLogger(self.nodeName, "podName", podName)
+	logger := util.LoggerFromContext(ctx).WithField("podName", podName)
 	logger.Info("Deleting pod")
 
+	delay, err := self.waitForTermination(ctx, podName, pod)
+	if err != nil {
+		return err
+	} else if delay > 0 {
+		logger.Infof("Pod terminated after %v", delay)
+	}
+
+	self.podsMutex.Lock()
+	if pod, ok := self.pods[podName]; ok && pod.Status.Phase != corev1.PodFailed {
+		self.releaseResources(pod)
+	}
 	delete(self.pods, podName)
+	delete(self.podStartTimes, podName)
+	delete(self.podsAwaitingClaims, podName)
+	self.podsMutex.Unlock()
+
+	self.lifetimeMutex.Lock()
+	defer self.lifetimeMutex.Unlock()
+	delete(self.podEndTimes, podName)
+	delete(self.podRemaining, podName)
+	delete(self.podSims, podName)
+	delete(self.podsEnded, podName)
+
+	event := audit.Event{Action: audit.PodDeleted, Object: podName}
+	if delay > 0 {
+		event.Details = map[string]string{"terminationDelay": delay.String()}
+	}
+	audit.Record(event)
 	return nil
 }
 
 func (self *podLifecycleHandler) GetPod(ctx context.Context, namespace, name string) (*corev1.Pod, error) {
 	podName := k8s.NamespacedName(namespace, name)
-	logger := util.GetLogger(self.nodeName, "podName", podName)
+	logger := self.logSampler.Sample("GetPod", util.LoggerFromContext(ctx).WithField("podName", podName))
 	logger.Info("Getting pod")
 
+	self.podsMutex.Lock()
+	defer self.podsMutex.Unlock()
 	if pod, ok := self.pods[podName]; !ok {
 		//nolint:wrapcheck // this is my error, doesn't need to be wrapped
 		return nil, ErrorPodNotFound
@@ -91,27 +288,64 @@ func (self *podLifecycleHandler) GetPod(ctx context.Context, namespace, name str
 
 func (self *podLifecycleHandler) GetPodStatus(ctx context.Context, namespace, name string) (*corev1.PodStatus, error) {
 	podName := k8s.NamespacedName(namespace, name)
-	logger := util.GetLogger(self.nodeName, "podName", podName)
+	logger := self.logSampler.Sample("GetPodStatus", util.LoggerFromContext(ctx).WithField("podName", podName))
 	logger.Debug("Getting pod status")
 
+	self.checkClaims(ctx, podName)
+
+	self.podsMutex.Lock()
+	defer self.podsMutex.Unlock()
 	if pod, ok := self.pods[podName]; !ok {
 		//nolint:wrapcheck // this is my error, doesn't need to be wrapped
 		return nil, ErrorPodNotFound
 	} else {
+		if self.podsAwaitingClaims[podName] {
+			return self.applyNodeState(pod.Status.DeepCopy()), nil
+		} else if startTime, ok := self.podStartTimes[podName]; ok {
+			if self.clock.Now().Before(startTime) {
+				return self.applyNodeState(pod.Status.DeepCopy()), nil
+			}
+			self.setRunningStatus(pod, startTime)
+			delete(self.podStartTimes, podName)
+		}
+
+		self.lifetimeMutex.Lock()
+		endTime, ok := self.podEndTimes[podName]
+		ended := ok && self.clock.Now().After(endTime)
+		firstEnded := ended && !self.podsEnded[podName]
+		if ended {
+			self.podsEnded[podName] = true
+		}
+		self.lifetimeMutex.Unlock()
+
 		var status *corev1.PodStatus
-		if endTime, ok := self.podEndTimes[podName]; ok && self.clock.Now().After(endTime) {
+		if ended {
 			status = self.makeTerminatedStatus(pod, endTime)
+			if firstEnded {
+				audit.Record(audit.Event{
+					Time:   endTime,
+					Action: audit.PodTerminated,
+					Object: podName,
+					Reason: "LifetimeExpired",
+					Details: lo.Assign(
+						kueue.QueueDetails(pod.ObjectMeta.Labels),
+						map[string]string{"phase": string(status.Phase)},
+					),
+				})
+			}
 		} else {
 			status = pod.Status.DeepCopy()
 		}
-		return status, nil
+		return self.applyNodeState(status), nil
 	}
 }
 
-func (self *podLifecycleHandler) GetPods(context.Context) ([]*corev1.Pod, error) {
-	logger := util.GetLogger(self.nodeName)
+func (self *podLifecycleHandler) GetPods(ctx context.Context) ([]*corev1.Pod, error) {
+	logger := self.logSampler.Sample("GetPods", util.LoggerFromContext(ctx))
 	logger.Info("Getting all pods")
 
+	self.podsMutex.Lock()
+	defer self.podsMutex.Unlock()
 	pods := make([]*corev1.Pod, 0, len(self.pods))
 	for _, pod := range self.pods {
 		pods = append(pods, pod.DeepCopy())
@@ -119,17 +353,72 @@ func (self *podLifecycleHandler) GetPods(context.Context) ([]*corev1.Pod, error)
 	return pods, nil
 }
 
-func (self *podLifecycleHandler) setRunningStatus(pod *corev1.Pod) {
+// startLifetime starts the clock on the pod's lifetime annotation (or kwok's, in compatibility
+// mode); pods without one run until they're deleted
+func (self *podLifecycleHandler) startLifetime(ctx context.Context, podName string, pod *corev1.Pod) {
+	if pod.ObjectMeta.Annotations == nil {
+		return
+	}
+
+	if lifetime_str, ok := pod.ObjectMeta.Annotations[lifetimeAnnotationKey]; ok {
+		lifetime_seconds, err := strconv.Atoi(lifetime_str)
+		if err != nil {
+			util.LoggerFromContext(ctx).Warn("Could not parse lifetime annotation, pod will not terminate")
+		} else {
+			self.setLifetime(ctx, podName, pod, time.Duration(lifetime_seconds)*time.Second)
+		}
+	} else if self.kwokCompat {
+		self.setKwokLifetime(ctx, podName, pod)
+	}
+}
+
+func (self *podLifecycleHandler) setLifetime(
+	ctx context.Context,
+	podName string,
+	pod *corev1.Pod,
+	lifetime time.Duration,
+) {
+	logger := util.LoggerFromContext(ctx)
+
+	// Pods that are held in Pending don't start aging until they're running
+	if startTime, ok := self.podStartTimes[podName]; ok {
+		lifetime += startTime.Sub(self.clock.Now())
+	}
+
+	self.lifetimeMutex.Lock()
+	defer self.lifetimeMutex.Unlock()
+
+	simName := pod.ObjectMeta.Labels[simulationLabelKey]
+	self.podSims[podName] = simName
+	if self.pausedSims[simName] {
+		self.podRemaining[podName] = lifetime
+		logger.Infof("simulation %s is paused, pod lifetime starts when it is resumed", simName)
+	} else {
+		endTime := self.clock.Now().Add(lifetime)
+		self.podEndTimes[podName] = endTime
+		logger.Infof("pod end time recorded at %v", endTime)
+	}
+}
+
+// setRunningStatus reports the pod as running the same way the kubelet does, since some
+// controllers (e.g., Volcano, which counts the running members of each gang) look at more than
+// the phase
+func (self *podLifecycleHandler) setRunningStatus(pod *corev1.Pod, startTime time.Time) {
 	pod.Status.Phase = corev1.PodRunning
 
-	now := metav1.Time{Time: self.clock.Now()}
+	now := metav1.Time{Time: startTime}
+	pod.Status.StartTime = &now
 	pod.Status.InitContainerStatuses = make([]corev1.ContainerStatus, len(pod.Spec.InitContainers))
 	for i, c := range pod.Spec.InitContainers {
 		pod.Status.InitContainerStatuses[i] = corev1.ContainerStatus{
 			Name: c.Name,
 			State: corev1.ContainerState{
 				// TODO eventually we could read these timestamps from annotations
-				Terminated: &corev1.ContainerStateTerminated{StartedAt: now, FinishedAt: now},
+				Terminated: &corev1.ContainerStateTerminated{
+					StartedAt:  now,
+					FinishedAt: now,
+					Reason:     containerCompletedReason,
+				},
 			},
 			Ready: true,
 		}
@@ -142,40 +431,98 @@ func (self *podLifecycleHandler) setRunningStatus(pod *corev1.Pod) {
 			State: corev1.ContainerState{
 				Running: &corev1.ContainerStateRunning{StartedAt: now},
 			},
-			Ready: true,
+			Ready:   true,
+			Started: lo.ToPtr(true),
 		}
 	}
 
-	pod.Status.Conditions = append(pod.Status.Conditions, []corev1.PodCondition{
-		{
-			Type:               corev1.PodInitialized,
-			Status:             corev1.ConditionTrue,
-			LastTransitionTime: now,
-		},
-		{
-			Type:               corev1.ContainersReady,
+	// The pod can already have conditions that were set by someone else (e.g., PodScheduled, or
+	// the conditions that Kueue uses to track preemption), which are left alone
+	for _, condType := range []corev1.PodConditionType{corev1.PodInitialized, corev1.ContainersReady, corev1.PodReady} {
+		setPodCondition(&pod.Status, corev1.PodCondition{
+			Type:               condType,
 			Status:             corev1.ConditionTrue,
 			LastTransitionTime: now,
-		},
-		{
-			Type:               corev1.PodReady,
-			Status:             corev1.ConditionTrue,
-			LastTransitionTime: now,
-		},
-	}...)
+		})
+	}
+}
+
+func setPodCondition(status *corev1.PodStatus, condition corev1.PodCondition) {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == condition.Type {
+			status.Conditions[i] = condition
+			return
+		}
+	}
+	status.Conditions = append(status.Conditions, condition)
+}
+
+func (self *podLifecycleHandler) applyNodeState(status *corev1.PodStatus) *corev1.PodStatus {
+	self.stateMutex.RLock()
+	defer self.stateMutex.RUnlock()
+
+	if status.Phase != corev1.PodRunning && status.Phase != corev1.PodUnknown {
+		return status
+	}
+
+	now := metav1.Time{Time: self.clock.Now()}
+	if self.nodeTerminated {
+		status.Phase = corev1.PodFailed
+		status.Reason = nodeShutdownReason
+		status.Message = nodeShutdownMessage
+		setPodNotReady(status, now)
+		for i := range status.ContainerStatuses {
+			cs := &status.ContainerStatuses[i]
+			if cs.State.Running != nil {
+				cs.State = corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					StartedAt:  cs.State.Running.StartedAt,
+					FinishedAt: now,
+					ExitCode:   nodeShutdownExitCode,
+					Reason:     nodeShutdownReason,
+				}}
+			}
+			cs.Ready = false
+			cs.Started = lo.ToPtr(false)
+		}
+	} else if self.nodeDown {
+		status.Phase = corev1.PodUnknown
+		status.Reason = nodeLostReason
+		status.Message = nodeLostMessage
+		setPodNotReady(status, now)
+		for i := range status.ContainerStatuses {
+			status.ContainerStatuses[i].Ready = false
+		}
+	}
+	return status
+}
+
+func setPodNotReady(status *corev1.PodStatus, now metav1.Time) {
+	for i := range status.Conditions {
+		cond := &status.Conditions[i]
+		switch cond.Type {
+		case corev1.PodReady, corev1.ContainersReady:
+			if cond.Status != corev1.ConditionFalse {
+				cond.LastTransitionTime = now
+			}
+			cond.Status = corev1.ConditionFalse
+		}
+	}
 }
 
 func (self *podLifecycleHandler) makeTerminatedStatus(pod *corev1.Pod, endTime time.Time) *corev1.PodStatus {
 	status := pod.Status.DeepCopy()
 
 	status.Phase = corev1.PodSucceeded
-	for _, cond := range status.Conditions {
+	for i := range status.Conditions {
+		cond := &status.Conditions[i]
 		switch cond.Type {
 		case corev1.PodReady, corev1.ContainersReady:
 			cond.Status = corev1.ConditionFalse
 			cond.LastTransitionTime = metav1.Time{Time: endTime}
+			cond.Reason = podCompletedReason
+		case corev1.PodInitialized:
+			cond.Reason = podCompletedReason
 		}
-		cond.Reason = "PodCompleted"
 	}
 	for i, c := range pod.Spec.Containers {
 		status.ContainerStatuses[i] = corev1.ContainerStatus{
@@ -185,6 +532,7 @@ func (self *podLifecycleHandler) makeTerminatedStatus(pod *corev1.Pod, endTime t
 					StartedAt:  pod.Status.ContainerStatuses[i].State.Running.StartedAt,
 					FinishedAt: metav1.Time{Time: endTime},
 					ExitCode:   0,
+					Reason:     containerCompletedReason,
 				},
 			},
 			Ready:   false,
@@ -29,10 +29,17 @@ var (
 
 func makePodLifecycleHandler(opts ...func(*podLifecycleHandler)) *podLifecycleHandler {
 	handler := &podLifecycleHandler{
-		testNodeName,
-		map[string]*corev1.Pod{},
-		map[string]time.Time{},
-		clockwork.NewFakeClock(),
+		pods:               map[string]*corev1.Pod{},
+		podStartTimes:      map[string]time.Time{},
+		podsAwaitingClaims: map[string]bool{},
+		clock:              clockwork.NewFakeClock(),
+		podEndTimes:        map[string]time.Time{},
+		podsEnded:          map[string]bool{},
+		podRemaining:       map[string]time.Duration{},
+		podSims:            map[string]string{},
+		pausedSims:         map[string]bool{},
+		allocatable:        corev1.ResourceList{},
+		allocated:          corev1.ResourceList{},
 	}
 	for _, opt := range opts {
 		opt(handler)
@@ -104,6 +111,13 @@ func TestCreatePod(t *testing.T) {
 			assert.Equal(t, pod.Status.Phase, corev1.PodRunning)
 			assert.Len(t, pod.Status.InitContainerStatuses, len(pod.Spec.InitContainers))
 			assert.Len(t, pod.Status.ContainerStatuses, len(pod.Spec.Containers))
+			assert.Equal(t, &metav1.Time{Time: c.Now()}, pod.Status.StartTime)
+			for _, cs := range pod.Status.InitContainerStatuses {
+				assert.Equal(t, containerCompletedReason, cs.State.Terminated.Reason)
+			}
+			for _, cs := range pod.Status.ContainerStatuses {
+				assert.Equal(t, lo.ToPtr(true), cs.Started)
+			}
 
 			if tc.lifetime != nil {
 				assert.Equal(t, testEndTime, podHandler.podEndTimes[testPodFullName])
@@ -125,6 +139,39 @@ func TestCreatePodUnparseableLifetime(t *testing.T) {
 	assert.NotContains(t, podHandler.podEndTimes, testPodFullName)
 }
 
+func conditionType(cond corev1.PodCondition) corev1.PodConditionType {
+	return cond.Type
+}
+
+func TestCreatePodKeepsConditions(t *testing.T) {
+	c := clockwork.NewFakeClockAt(time.Time{})
+	pod := makePod(nil, []corev1.Container{testContainer}, lo.ToPtr(5*time.Second))
+	pod.ObjectMeta.Labels = map[string]string{"kueue.x-k8s.io/queue-name": "batch"}
+	pod.Status.Conditions = []corev1.PodCondition{
+		{Type: corev1.PodScheduled, Status: corev1.ConditionTrue},
+		{Type: corev1.PodReady, Status: corev1.ConditionFalse},
+		{Type: "TerminationTarget", Status: corev1.ConditionFalse, Reason: "kueue"},
+	}
+	podHandler := makePodLifecycleHandler(func(h *podLifecycleHandler) { h.clock = c })
+
+	assert.Nil(t, podHandler.CreatePod(context.TODO(), pod))
+	conditions := lo.KeyBy(pod.Status.Conditions, conditionType)
+	assert.Len(t, pod.Status.Conditions, 5)
+	assert.Equal(t, corev1.ConditionTrue, conditions[corev1.PodScheduled].Status)
+	assert.Equal(t, corev1.ConditionTrue, conditions[corev1.PodReady].Status)
+	assert.Equal(t, "kueue", conditions["TerminationTarget"].Reason)
+
+	// Once the pod finishes, only the kubelet's conditions change
+	c.Advance(10 * time.Second)
+	status, err := podHandler.GetPodStatus(context.TODO(), testNamespace, testPodName)
+	assert.Nil(t, err)
+	conditions = lo.KeyBy(status.Conditions, conditionType)
+	assert.Equal(t, corev1.ConditionFalse, conditions[corev1.PodReady].Status)
+	assert.Equal(t, podCompletedReason, conditions[corev1.PodReady].Reason)
+	assert.Equal(t, "", conditions[corev1.PodScheduled].Reason)
+	assert.Equal(t, "kueue", conditions["TerminationTarget"].Reason)
+}
+
 func TestUpdatePod(t *testing.T) {
 	pod := makePod(nil, []corev1.Container{testContainer}, nil)
 	podHandler := makePodLifecycleHandler()
@@ -192,6 +239,7 @@ func TestGetPodStatusWithExpiration(t *testing.T) {
 			expectedState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
 				StartedAt:  metav1.Time{},
 				FinishedAt: metav1.Time{Time: testEndTime},
+				Reason:     containerCompletedReason,
 			}},
 			expectedReady: false,
 		},
@@ -227,6 +275,116 @@ func TestGetPodStatusWithExpiration(t *testing.T) {
 				assert.Equal(t, cs.Ready, tc.expectedReady)
 				assert.Equal(t, cs.State, tc.expectedState)
 			}
+			// The termination is only recorded in the audit log the first time it's seen
+			assert.Equal(t, !tc.expectedReady, podHandler.podsEnded[testPodFullName])
+		})
+	}
+}
+
+func TestSetSimulationPaused(t *testing.T) {
+	cases := map[string]struct {
+		pausedAtCreation  bool
+		expectedRemaining time.Duration
+	}{
+		"paused while running": {expectedRemaining: 3 * time.Second},
+		"created while paused": {pausedAtCreation: true, expectedRemaining: 5 * time.Second},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := clockwork.NewFakeClockAt(time.Time{})
+			podHandler := makePodLifecycleHandler(func(h *podLifecycleHandler) { h.clock = c })
+			pod := makePod(nil, []corev1.Container{testContainer}, lo.ToPtr(5*time.Second))
+			pod.ObjectMeta.Labels = map[string]string{simulationLabelKey: "the-sim"}
+
+			if tc.pausedAtCreation {
+				podHandler.SetSimulationPaused("the-sim", true)
+				assert.Nil(t, podHandler.CreatePod(context.TODO(), pod))
+			} else {
+				assert.Nil(t, podHandler.CreatePod(context.TODO(), pod))
+				c.Advance(2 * time.Second)
+				podHandler.SetSimulationPaused("the-sim", true)
+			}
+
+			phase := func() corev1.PodPhase {
+				status, err := podHandler.GetPodStatus(context.TODO(), testNamespace, testPodName)
+				assert.Nil(t, err)
+				return status.Phase
+			}
+
+			// Other simulations don't affect the pod, and it doesn't age while its simulation is paused
+			podHandler.SetSimulationPaused("other-sim", false)
+			c.Advance(time.Minute)
+			assert.Equal(t, corev1.PodRunning, phase())
+
+			podHandler.SetSimulationPaused("the-sim", false)
+			c.Advance(tc.expectedRemaining - time.Second)
+			assert.Equal(t, corev1.PodRunning, phase())
+			c.Advance(2 * time.Second)
+			assert.Equal(t, corev1.PodSucceeded, phase())
+		})
+	}
+}
+
+func TestGetPodStatusNodeFailure(t *testing.T) {
+	cases := map[string]struct {
+		nodeDown       bool
+		nodeTerminated bool
+		expectedPhase  corev1.PodPhase
+		expectedReason string
+	}{
+		"node up": {
+			expectedPhase: corev1.PodRunning,
+		},
+		"node down": {
+			nodeDown:       true,
+			expectedPhase:  corev1.PodUnknown,
+			expectedReason: nodeLostReason,
+		},
+		"node terminated": {
+			nodeTerminated: true,
+			expectedPhase:  corev1.PodFailed,
+			expectedReason: nodeShutdownReason,
+		},
+		"node down then terminated": {
+			nodeDown:       true,
+			nodeTerminated: true,
+			expectedPhase:  corev1.PodFailed,
+			expectedReason: nodeShutdownReason,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			podHandler := makePodLifecycleHandler(withPod, func(h *podLifecycleHandler) {
+				h.pods[testPodFullName].Status.Conditions = []corev1.PodCondition{
+					{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+				}
+				h.pods[testPodFullName].Status.ContainerStatuses = []corev1.ContainerStatus{
+					{
+						Name:  testContainerName,
+						State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+						Ready: true,
+					},
+				}
+			})
+			podHandler.SetNodeDown(tc.nodeDown)
+			if tc.nodeTerminated {
+				podHandler.TerminatePods()
+			}
+
+			status, err := podHandler.GetPodStatus(context.TODO(), testNamespace, testPodName)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedPhase, status.Phase)
+			assert.Equal(t, tc.expectedReason, status.Reason)
+			healthy := tc.expectedPhase == corev1.PodRunning
+			assert.Equal(t, healthy, status.ContainerStatuses[0].Ready)
+			assert.Equal(t, healthy, status.Conditions[0].Status == corev1.ConditionTrue)
+			assert.Equal(t, tc.nodeTerminated, status.ContainerStatuses[0].State.Terminated != nil)
+
+			// The stored pod status should not be modified
+			assert.Equal(t, corev1.PodRunning, podHandler.pods[testPodFullName].Status.Phase)
 		})
 	}
 }
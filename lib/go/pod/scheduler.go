@@ -0,0 +1,106 @@
+package pod
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"simkube/lib/go/util"
+)
+
+// To compare schedulers on the same trace, a slower (or faster) scheduler can be simulated by
+// holding pods in Pending for a while after they're placed on the node, as though the scheduler
+// had taken that much longer to get to them.  The delay can be set for every pod placed by a
+// scheduler, in a node annotation (a JSON object of scheduler names to Go durations), or for an
+// individual pod, which takes precedence.
+const (
+	schedulerDelaysAnnotation = "simkube.io/scheduler-delays"
+	schedulingDelayAnnotation = "simkube.io/scheduling-delay"
+
+	containerCreatingReason = "ContainerCreating"
+)
+
+// parseSchedulerDelays reads the scheduler delays from the node's annotations; it returns nil if
+// the node doesn't have any
+func parseSchedulerDelays(annotations map[string]string) (map[string]time.Duration, error) {
+	delaysStr, ok := annotations[schedulerDelaysAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	var parsed map[string]metav1.Duration
+	if err := json.Unmarshal([]byte(delaysStr), &parsed); err != nil {
+		return nil, fmt.Errorf("could not parse %s annotation: %w", schedulerDelaysAnnotation, err)
+	}
+
+	delays := make(map[string]time.Duration, len(parsed))
+	for name, delay := range parsed {
+		if delay.Duration < 0 {
+			return nil, fmt.Errorf("invalid delay for scheduler %s: %v", name, delay.Duration)
+		}
+		delays[name] = delay.Duration
+	}
+	return delays, nil
+}
+
+// schedulerName is the scheduler that placed the pod on the node; pods that don't name one are
+// placed by the default scheduler
+func schedulerName(pod *corev1.Pod) string {
+	if pod.Spec.SchedulerName == "" {
+		return corev1.DefaultSchedulerName
+	}
+	return pod.Spec.SchedulerName
+}
+
+// schedulingLatency is how long the scheduler took to place the pod, i.e., from when the pod was
+// created until it was scheduled (or until now, if the PodScheduled condition isn't set)
+func schedulingLatency(pod *corev1.Pod, now time.Time) time.Duration {
+	if pod.ObjectMeta.CreationTimestamp.IsZero() {
+		return 0
+	}
+
+	scheduledAt := now
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionTrue && !cond.LastTransitionTime.IsZero() {
+			scheduledAt = cond.LastTransitionTime.Time
+		}
+	}
+
+	latency := scheduledAt.Sub(pod.ObjectMeta.CreationTimestamp.Time)
+	if latency < 0 {
+		return 0
+	}
+	return latency
+}
+
+// schedulingDelay is how long the pod is held in Pending, from its own annotation or from the
+// delay for its scheduler; an unparseable pod annotation is ignored
+func (self *podLifecycleHandler) schedulingDelay(ctx context.Context, pod *corev1.Pod) time.Duration {
+	if delayStr, ok := pod.ObjectMeta.Annotations[schedulingDelayAnnotation]; ok {
+		delay, err := time.ParseDuration(delayStr)
+		if err == nil && delay >= 0 {
+			return delay
+		}
+		util.LoggerFromContext(ctx).Warn("Could not parse scheduling delay annotation, ignoring it")
+	}
+	return self.schedulerDelays[schedulerName(pod)]
+}
+
+// setPendingStatus reports the pod as placed on the node, but with its containers still being
+// created, the way the kubelet does before the pod starts
+func setPendingStatus(pod *corev1.Pod) {
+	pod.Status.Phase = corev1.PodPending
+	pod.Status.ContainerStatuses = make([]corev1.ContainerStatus, len(pod.Spec.Containers))
+	for i, c := range pod.Spec.Containers {
+		pod.Status.ContainerStatuses[i] = corev1.ContainerStatus{
+			Name:    c.Name,
+			State:   corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: containerCreatingReason}},
+			Started: lo.ToPtr(false),
+		}
+	}
+}
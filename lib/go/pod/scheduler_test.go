@@ -0,0 +1,144 @@
+package pod
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseSchedulerDelays(t *testing.T) {
+	cases := map[string]struct {
+		annotations   map[string]string
+		expected      map[string]time.Duration
+		expectedError bool
+	}{
+		"no annotation": {},
+		"delays": {
+			annotations: map[string]string{schedulerDelaysAnnotation: `{"my-scheduler": "5s", "default-scheduler": "0s"}`},
+			expected:    map[string]time.Duration{"my-scheduler": 5 * time.Second, "default-scheduler": 0},
+		},
+		"invalid": {
+			annotations:   map[string]string{schedulerDelaysAnnotation: `{"my-scheduler": "asdf"}`},
+			expectedError: true,
+		},
+		"negative": {
+			annotations:   map[string]string{schedulerDelaysAnnotation: `{"my-scheduler": "-5s"}`},
+			expectedError: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			delays, err := parseSchedulerDelays(tc.annotations)
+			if tc.expectedError {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expected, delays)
+			}
+		})
+	}
+}
+
+func TestSchedulingLatency(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := created.Add(time.Minute)
+
+	cases := map[string]struct {
+		creationTime time.Time
+		conditions   []corev1.PodCondition
+		expected     time.Duration
+	}{
+		"not created": {},
+		"scheduled": {
+			creationTime: created,
+			conditions: []corev1.PodCondition{{
+				Type:               corev1.PodScheduled,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.Time{Time: created.Add(3 * time.Second)},
+			}},
+			expected: 3 * time.Second,
+		},
+		"no condition": {
+			creationTime: created,
+			expected:     time.Minute,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := makePod(nil, []corev1.Container{testContainer}, nil)
+			pod.ObjectMeta.CreationTimestamp = metav1.Time{Time: tc.creationTime}
+			pod.Status.Conditions = tc.conditions
+			assert.Equal(t, tc.expected, schedulingLatency(pod, now))
+		})
+	}
+}
+
+func TestCreatePodSchedulingDelay(t *testing.T) {
+	cases := map[string]struct {
+		schedulerName string
+		annotations   map[string]string
+		expectedDelay time.Duration
+	}{
+		"default scheduler": {},
+		"custom scheduler": {
+			schedulerName: "my-scheduler",
+			expectedDelay: 10 * time.Second,
+		},
+		"pod annotation": {
+			schedulerName: "my-scheduler",
+			annotations:   map[string]string{schedulingDelayAnnotation: "20s"},
+			expectedDelay: 20 * time.Second,
+		},
+		"invalid pod annotation": {
+			schedulerName: "my-scheduler",
+			annotations:   map[string]string{schedulingDelayAnnotation: "asdf"},
+			expectedDelay: 10 * time.Second,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := clockwork.NewFakeClockAt(time.Time{})
+			podHandler := makePodLifecycleHandler(func(h *podLifecycleHandler) { h.clock = c })
+			podHandler.SetSchedulerDelays(map[string]time.Duration{"my-scheduler": 10 * time.Second})
+			pod := makePod(nil, []corev1.Container{testContainer}, lo.ToPtr(5*time.Second))
+			pod.ObjectMeta.Annotations = lo.Assign(pod.ObjectMeta.Annotations, tc.annotations)
+			pod.Spec.SchedulerName = tc.schedulerName
+			scheduled := podsScheduled.Value(schedulerName(pod))
+
+			assert.Nil(t, podHandler.CreatePod(context.TODO(), pod))
+			assert.Equal(t, scheduled+1, podsScheduled.Value(schedulerName(pod)))
+
+			status := func() *corev1.PodStatus {
+				status, err := podHandler.GetPodStatus(context.TODO(), testNamespace, testPodName)
+				assert.Nil(t, err)
+				return status
+			}
+
+			if tc.expectedDelay > 0 {
+				assert.Equal(t, corev1.PodPending, status().Phase)
+				assert.Equal(t, containerCreatingReason, status().ContainerStatuses[0].State.Waiting.Reason)
+				c.Advance(tc.expectedDelay - time.Second)
+				assert.Equal(t, corev1.PodPending, status().Phase)
+				c.Advance(2 * time.Second)
+			}
+
+			// The pod starts when the delay is up, and its lifetime starts then too
+			running := status()
+			assert.Equal(t, corev1.PodRunning, running.Phase)
+			assert.Equal(t, tc.expectedDelay, running.StartTime.Time.Sub(time.Time{}))
+			c.Advance(3 * time.Second)
+			assert.Equal(t, corev1.PodRunning, status().Phase)
+			c.Advance(3 * time.Second)
+			assert.Equal(t, corev1.PodSucceeded, status().Phase)
+		})
+	}
+}
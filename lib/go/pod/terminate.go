@@ -0,0 +1,65 @@
+package pod
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// terminationDelay is how long a running pod takes to shut down once it's deleted (or evicted),
+// which is a random value between the configured minimum and maximum.  Like the kubelet, we
+// don't let the pod run past its grace period.
+func (self *podLifecycleHandler) terminationDelay(pod *corev1.Pod) time.Duration {
+	delay := self.terminationDelayMin
+	if self.terminationDelayMax > delay {
+		//nolint:gosec // this doesn't need to be cryptographically secure
+		delay += time.Duration(rand.Int63n(int64(self.terminationDelayMax - delay)))
+	}
+
+	if pod.ObjectMeta.DeletionGracePeriodSeconds != nil {
+		grace := time.Duration(*pod.ObjectMeta.DeletionGracePeriodSeconds) * time.Second
+		if delay > grace {
+			delay = grace
+		}
+	}
+	return delay
+}
+
+// waitForTermination holds up the deletion of a running pod for its termination delay, so that
+// evictions (e.g., from a descheduler) take a realistic amount of time; pods that never started,
+// or that have already finished, are deleted straight away
+func (self *podLifecycleHandler) waitForTermination(
+	ctx context.Context,
+	podName string,
+	pod *corev1.Pod,
+) (time.Duration, error) {
+	self.podsMutex.Lock()
+	current, ok := self.pods[podName]
+	self.podsMutex.Unlock()
+	if !ok || current.Status.Phase != corev1.PodRunning || self.podEnded(podName) {
+		return 0, nil
+	}
+
+	delay := self.terminationDelay(pod)
+	if delay <= 0 {
+		return 0, nil
+	}
+
+	select {
+	case <-self.clock.After(delay):
+		return delay, nil
+	case <-ctx.Done():
+		return 0, fmt.Errorf("pod %s did not terminate: %w", podName, ctx.Err())
+	}
+}
+
+func (self *podLifecycleHandler) podEnded(podName string) bool {
+	self.lifetimeMutex.Lock()
+	defer self.lifetimeMutex.Unlock()
+
+	endTime, ok := self.podEndTimes[podName]
+	return ok && self.clock.Now().After(endTime)
+}
@@ -0,0 +1,106 @@
+package pod
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestTerminationDelay(t *testing.T) {
+	cases := map[string]struct {
+		delayMin    time.Duration
+		delayMax    time.Duration
+		gracePeriod *int64
+		expectedMin time.Duration
+		expectedMax time.Duration
+	}{
+		"no delay": {},
+		"fixed": {
+			delayMin:    5 * time.Second,
+			expectedMin: 5 * time.Second,
+			expectedMax: 5 * time.Second,
+		},
+		"random": {
+			delayMin:    5 * time.Second,
+			delayMax:    10 * time.Second,
+			expectedMin: 5 * time.Second,
+			expectedMax: 10 * time.Second,
+		},
+		"grace period": {
+			delayMin:    time.Minute,
+			gracePeriod: lo.ToPtr(int64(30)),
+			expectedMin: 30 * time.Second,
+			expectedMax: 30 * time.Second,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			podHandler := makePodLifecycleHandler(func(h *podLifecycleHandler) {
+				h.terminationDelayMin = tc.delayMin
+				h.terminationDelayMax = tc.delayMax
+			})
+			pod := makePod(nil, []corev1.Container{testContainer}, nil)
+			pod.ObjectMeta.DeletionGracePeriodSeconds = tc.gracePeriod
+
+			delay := podHandler.terminationDelay(pod)
+			assert.GreaterOrEqual(t, delay, tc.expectedMin)
+			assert.LessOrEqual(t, delay, tc.expectedMax)
+		})
+	}
+}
+
+func TestDeletePodTerminationDelay(t *testing.T) {
+	cases := map[string]struct {
+		phase         corev1.PodPhase
+		expectedDelay bool
+	}{
+		"running":  {phase: corev1.PodRunning, expectedDelay: true},
+		"rejected": {phase: corev1.PodFailed},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := clockwork.NewFakeClock()
+			podHandler := makePodLifecycleHandler(withPod, func(h *podLifecycleHandler) {
+				h.clock = c
+				h.terminationDelayMin = 10 * time.Second
+				h.pods[testPodFullName].Status.Phase = tc.phase
+			})
+			pod := makePod(nil, []corev1.Container{testContainer}, nil)
+
+			done := make(chan error)
+			go func() { done <- podHandler.DeletePod(context.TODO(), pod) }()
+
+			if tc.expectedDelay {
+				c.BlockUntil(1)
+				_, err := podHandler.GetPod(context.TODO(), testNamespace, testPodName)
+				assert.Nil(t, err)
+				c.Advance(10 * time.Second)
+			}
+
+			assert.Nil(t, <-done)
+			_, err := podHandler.GetPod(context.TODO(), testNamespace, testPodName)
+			assert.ErrorIs(t, err, ErrorPodNotFound)
+		})
+	}
+}
+
+func TestDeletePodTerminationCancelled(t *testing.T) {
+	podHandler := makePodLifecycleHandler(withPod, func(h *podLifecycleHandler) {
+		h.terminationDelayMin = 10 * time.Second
+	})
+	pod := makePod(nil, []corev1.Container{testContainer}, nil)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	assert.NotNil(t, podHandler.DeletePod(ctx, pod))
+	_, err := podHandler.GetPod(context.TODO(), testNamespace, testPodName)
+	assert.Nil(t, err)
+}
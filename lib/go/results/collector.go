@@ -0,0 +1,263 @@
+package results
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	log "github.com/sirupsen/logrus"
+
+	simkubev1 "simkube/lib/go/api/v1"
+)
+
+const queryTimeout = 10 * time.Second
+
+// DefaultQueries are what the collector runs if the simulation doesn't configure any; these have
+// to match the ones in driver/metrics.rs
+func DefaultQueries() []simkubev1.MetricsQuery {
+	return []simkubev1.MetricsQuery{
+		{Name: "pending_pods", Query: `sum(kube_pod_status_phase{phase="Pending"})`},
+		{Name: "nodes", Query: `count(kube_node_info)`},
+		{
+			Name:  "scheduling_latency_p99",
+			Query: `histogram_quantile(0.99, sum(rate(scheduler_pod_scheduling_duration_seconds_bucket[1m])) by (le))`,
+		},
+		{Name: "pods_by_scheduler", Query: `sum(simkube_vnode_pods_scheduled_total) by (scheduler)`},
+		{
+			Name: "scheduling_latency_by_scheduler",
+			Query: `sum(rate(simkube_vnode_pod_scheduling_latency_seconds_total[1m])) by (scheduler)` +
+				` / sum(rate(simkube_vnode_pods_scheduled_total[1m])) by (scheduler)`,
+		},
+	}
+}
+
+// ParseQuery parses a query from the command line, in the form name=query
+func ParseQuery(s string) (simkubev1.MetricsQuery, error) {
+	name, query, ok := strings.Cut(s, "=")
+	if !ok || name == "" || query == "" {
+		return simkubev1.MetricsQuery{}, fmt.Errorf("invalid metrics query %q: must be name=query", s)
+	}
+	return simkubev1.MetricsQuery{Name: name, Query: query}, nil
+}
+
+// A Collector runs Prometheus queries at a regular interval for as long as the simulation is
+// running, and keeps all of the results in memory (they're small, compared to the trace).
+// Queries that fail are logged and skipped, since missing a few samples shouldn't stop the
+// simulation.
+type Collector struct {
+	simName  string
+	promURL  *url.URL
+	interval time.Duration
+	queries  []simkubev1.MetricsQuery
+
+	series map[string]*Series
+
+	httpClient *http.Client
+	clock      clockwork.Clock
+	logger     *log.Entry
+}
+
+func NewCollector(simName string, config simkubev1.SimulationMetricsConfig) (*Collector, error) {
+	promURL, err := url.Parse(config.PrometheusURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Prometheus URL %s: %w", config.PrometheusURL, err)
+	} else if promURL.Scheme != "http" && promURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid Prometheus URL %s: must be an http or https URL", config.PrometheusURL)
+	}
+
+	intervalSeconds := config.IntervalSeconds
+	if intervalSeconds <= 0 {
+		intervalSeconds = simkubev1.DefaultMetricsIntervalSeconds
+	}
+	queries := config.Queries
+	if len(queries) == 0 {
+		queries = DefaultQueries()
+	}
+
+	return &Collector{
+		simName:    simName,
+		promURL:    promURL,
+		interval:   time.Duration(intervalSeconds) * time.Second,
+		queries:    queries,
+		series:     map[string]*Series{},
+		httpClient: &http.Client{Timeout: queryTimeout},
+		clock:      clockwork.NewRealClock(),
+		logger:     log.WithFields(log.Fields{"component": "metrics", "simulation": simName}),
+	}, nil
+}
+
+// Run runs the queries right away, and then once per interval until ctx is cancelled
+func (self *Collector) Run(ctx context.Context) {
+	for {
+		self.collect(ctx, self.clock.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-self.clock.After(self.interval):
+		}
+	}
+}
+
+// Metrics returns everything that's been collected so far; the series are sorted by name and then
+// by labels.  It's not safe to call Metrics while Run is running.
+func (self *Collector) Metrics() *Metrics {
+	keys := make([]string, 0, len(self.series))
+	for key := range self.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	series := make([]Series, 0, len(keys))
+	for _, key := range keys {
+		series = append(series, *self.series[key])
+	}
+	return &Metrics{Simulation: self.simName, Queries: self.queries, Series: series}
+}
+
+func (self *Collector) collect(ctx context.Context, now time.Time) {
+	for _, q := range self.queries {
+		samples, err := self.query(ctx, q.Query, now)
+		if err != nil {
+			self.logger.WithError(err).Warnf("could not run query %s", q.Name)
+			continue
+		}
+
+		for _, s := range samples {
+			// JSON can't represent these, and they don't tell us anything anyways
+			if math.IsNaN(s.value) || math.IsInf(s.value, 0) {
+				continue
+			}
+
+			key := seriesKey(q.Name, s.labels)
+			series, ok := self.series[key]
+			if !ok {
+				series = &Series{Name: q.Name, Labels: s.labels}
+				self.series[key] = series
+			}
+			series.Samples = append(series.Samples, Sample{Ts: now.Unix(), Value: s.value})
+		}
+	}
+}
+
+type promSample struct {
+	labels map[string]string
+	value  float64
+}
+
+type promResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+type promVectorElem struct {
+	Metric map[string]string `json:"metric"`
+	Value  []json.RawMessage `json:"value"`
+}
+
+// query runs an instant query (see https://prometheus.io/docs/prometheus/latest/querying/api/);
+// only vector and scalar results are supported, since those are the only ones that make sense to
+// sample over time
+func (self *Collector) query(ctx context.Context, query string, now time.Time) ([]promSample, error) {
+	endpoint := self.promURL.JoinPath("api", "v1", "query")
+	endpoint.RawQuery = url.Values{
+		"query": {query},
+		"time":  {strconv.FormatInt(now.Unix(), 10)},
+	}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	resp, err := self.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not query Prometheus: %w", err)
+	}
+	defer func() {
+		//nolint:errcheck // there's nothing to do if closing the body fails
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response from Prometheus: %w", err)
+	}
+
+	// Prometheus sends back errors in the same format, so we try to parse the body either way
+	var promResp promResponse
+	if err := json.Unmarshal(body, &promResp); err != nil {
+		return nil, fmt.Errorf("could not parse response from Prometheus (status %d): %w", resp.StatusCode, err)
+	} else if promResp.Status != "success" {
+		return nil, fmt.Errorf("query failed (status %d): %s", resp.StatusCode, promResp.Error)
+	}
+
+	switch promResp.Data.ResultType {
+	case "vector":
+		var elems []promVectorElem
+		if err := json.Unmarshal(promResp.Data.Result, &elems); err != nil {
+			return nil, fmt.Errorf("could not parse vector result: %w", err)
+		}
+
+		samples := make([]promSample, 0, len(elems))
+		for _, elem := range elems {
+			value, err := parseValue(elem.Value)
+			if err != nil {
+				return nil, err
+			}
+			samples = append(samples, promSample{labels: elem.Metric, value: value})
+		}
+		return samples, nil
+	case "scalar":
+		var scalar []json.RawMessage
+		if err := json.Unmarshal(promResp.Data.Result, &scalar); err != nil {
+			return nil, fmt.Errorf("could not parse scalar result: %w", err)
+		}
+		value, err := parseValue(scalar)
+		if err != nil {
+			return nil, err
+		}
+		return []promSample{{value: value}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported result type: %s", promResp.Data.ResultType)
+	}
+}
+
+// Prometheus values are [<timestamp>, "<value>"]; we use our own timestamps, so that all of the
+// samples from the same round of queries line up
+func parseValue(value []json.RawMessage) (float64, error) {
+	if len(value) != 2 {
+		return 0, errors.New("invalid sample value")
+	}
+
+	var valueStr string
+	if err := json.Unmarshal(value[1], &valueStr); err != nil {
+		return 0, fmt.Errorf("invalid sample value: %w", err)
+	}
+	v, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sample value: %w", err)
+	}
+	return v, nil
+}
+
+func seriesKey(name string, labels map[string]string) string {
+	labelStrs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		labelStrs = append(labelStrs, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(labelStrs)
+	return fmt.Sprintf("%s{%s}", name, strings.Join(labelStrs, ","))
+}
@@ -0,0 +1,184 @@
+package results
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/testutils"
+)
+
+const (
+	testSimName  = "test-sim"
+	nodesQuery   = "count(kube_node_info)"
+	pendingQuery = `sum(kube_pod_status_phase{phase="Pending"}) by (namespace)`
+	scalarQuery  = "scalar(up)"
+	nanQuery     = "0/0"
+	badQuery     = "sum("
+	matrixQuery  = "up[5m]"
+)
+
+// fakePrometheus answers instant queries with canned responses; the number of nodes goes up by
+// one every time it's asked
+func fakePrometheus(t *testing.T) *httptest.Server {
+	nodes := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/query", r.URL.Path)
+
+		var resp string
+		switch r.URL.Query().Get("query") {
+		case nodesQuery:
+			nodes++
+			resp = `{"status":"success","data":{"resultType":"vector","result":[` +
+				`{"metric":{},"value":[1700000000.123,"` + strconv.Itoa(nodes) + `"]}]}}`
+		case pendingQuery:
+			resp = `{"status":"success","data":{"resultType":"vector","result":[` +
+				`{"metric":{"namespace":"default"},"value":[1700000000,"2"]},` +
+				`{"metric":{"namespace":"kube-system"},"value":[1700000000,"0"]}]}}`
+		case scalarQuery:
+			resp = `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"1.5"]}}`
+		case nanQuery:
+			resp = `{"status":"success","data":{"resultType":"vector","result":[` +
+				`{"metric":{},"value":[1700000000,"NaN"]}]}}`
+		case matrixQuery:
+			resp = `{"status":"success","data":{"resultType":"matrix","result":[]}}`
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			resp = `{"status":"error","errorType":"bad_data","error":"parse error"}`
+		}
+		_, err := w.Write([]byte(resp))
+		assert.Nil(t, err)
+	}))
+}
+
+func newTestCollector(t *testing.T, url string, queries ...simkubev1.MetricsQuery) *Collector {
+	collector, err := NewCollector(testSimName, simkubev1.SimulationMetricsConfig{
+		PrometheusURL:   url,
+		IntervalSeconds: 10,
+		Queries:         queries,
+	})
+	require.Nil(t, err)
+	collector.logger = testutils.GetFakeLogger()
+	return collector
+}
+
+func TestCollectorRun(t *testing.T) {
+	srv := fakePrometheus(t)
+	defer srv.Close()
+
+	collector := newTestCollector(t, srv.URL,
+		simkubev1.MetricsQuery{Name: "nodes", Query: nodesQuery},
+		simkubev1.MetricsQuery{Name: "pending_pods", Query: pendingQuery},
+	)
+	clock := clockwork.NewFakeClockAt(time.Unix(1000, 0))
+	collector.clock = clock
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	done := make(chan struct{})
+	go func() {
+		collector.Run(ctx)
+		close(done)
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(10 * time.Second)
+	clock.BlockUntil(1)
+	cancel()
+	<-done
+
+	assert.Equal(t, &Metrics{
+		Simulation: testSimName,
+		Queries: []simkubev1.MetricsQuery{
+			{Name: "nodes", Query: nodesQuery},
+			{Name: "pending_pods", Query: pendingQuery},
+		},
+		Series: []Series{
+			{Name: "nodes", Labels: map[string]string{}, Samples: []Sample{{1000, 1}, {1010, 2}}},
+			{
+				Name:    "pending_pods",
+				Labels:  map[string]string{"namespace": "default"},
+				Samples: []Sample{{1000, 2}, {1010, 2}},
+			},
+			{
+				Name:    "pending_pods",
+				Labels:  map[string]string{"namespace": "kube-system"},
+				Samples: []Sample{{1000, 0}, {1010, 0}},
+			},
+		},
+	}, collector.Metrics())
+}
+
+func TestCollectorCollect(t *testing.T) {
+	srv := fakePrometheus(t)
+	defer srv.Close()
+
+	cases := map[string]struct {
+		query          string
+		expectedSeries []Series
+	}{
+		"scalar": {
+			query:          scalarQuery,
+			expectedSeries: []Series{{Name: "q", Samples: []Sample{{1000, 1.5}}}},
+		},
+		"NaN is skipped":      {query: nanQuery, expectedSeries: []Series{}},
+		"query error":         {query: badQuery, expectedSeries: []Series{}},
+		"unknown result type": {query: matrixQuery, expectedSeries: []Series{}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			collector := newTestCollector(t, srv.URL, simkubev1.MetricsQuery{Name: "q", Query: tc.query})
+			collector.collect(context.TODO(), time.Unix(1000, 0))
+			assert.Equal(t, tc.expectedSeries, collector.Metrics().Series)
+		})
+	}
+}
+
+func TestNewCollector(t *testing.T) {
+	collector, err := NewCollector(testSimName, simkubev1.SimulationMetricsConfig{PrometheusURL: "http://prom:9090"})
+	assert.Nil(t, err)
+	assert.Equal(t, 15*time.Second, collector.interval)
+	assert.Equal(t, DefaultQueries(), collector.queries)
+
+	_, err = NewCollector(testSimName, simkubev1.SimulationMetricsConfig{PrometheusURL: "prom:9090"})
+	assert.ErrorContains(t, err, "must be an http or https URL")
+}
+
+func TestParseQuery(t *testing.T) {
+	cases := map[string]struct {
+		input         string
+		expected      simkubev1.MetricsQuery
+		expectedError bool
+	}{
+		"simple": {input: "nodes=count(kube_node_info)", expected: simkubev1.MetricsQuery{
+			Name:  "nodes",
+			Query: "count(kube_node_info)",
+		}},
+		"query with equals signs": {
+			input:    `pending=sum(kube_pod_status_phase{phase="Pending"})`,
+			expected: simkubev1.MetricsQuery{Name: "pending", Query: `sum(kube_pod_status_phase{phase="Pending"})`},
+		},
+		"no name":  {input: "=up", expectedError: true},
+		"no query": {input: "up", expectedError: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			query, err := ParseQuery(tc.input)
+			if tc.expectedError {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expected, query)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package results
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	simkubev1 "simkube/lib/go/api/v1"
+)
+
+// The results of a simulation are stored in a ConfigMap in the driver namespace, which is owned
+// by the Simulation (not the SimulationRoot), so that they stick around after the simulation is
+// over.  These have to match the ones that sk-driver uses (see driver/metrics.rs).
+const (
+	MetricsKey = "metrics.json"
+
+	simulationLabel = "simkube.io/simulation"
+)
+
+func ConfigMapName(simName string) string {
+	return fmt.Sprintf("sk-%s-results", simName)
+}
+
+// A Sample is the value of a series at a point in time (in seconds since the epoch)
+type Sample struct {
+	Ts    int64   `json:"ts"`
+	Value float64 `json:"value"`
+}
+
+// A Series is all of the samples for one query that have the same labels; queries that return a
+// vector (e.g., `kube_node_info`) have a series for every element of the vector.
+type Series struct {
+	Name    string            `json:"name"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Samples []Sample          `json:"samples"`
+}
+
+// Metrics are the time series that the driver collected while the simulation was running
+type Metrics struct {
+	Simulation string                   `json:"simulation"`
+	Queries    []simkubev1.MetricsQuery `json:"queries"`
+	Series     []Series                 `json:"series"`
+}
+
+// BuildConfigMap stores the metrics in the simulation's results ConfigMap; if owner is nil (e.g.,
+// because the driver is being run by hand), the ConfigMap isn't cleaned up with the Simulation.
+func BuildConfigMap(namespace string, owner *metav1.OwnerReference, metrics *Metrics) (*corev1.ConfigMap, error) {
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal metrics: %w", err)
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      ConfigMapName(metrics.Simulation),
+			Labels:    map[string]string{simulationLabel: metrics.Simulation},
+		},
+		Data: map[string]string{MetricsKey: string(data)},
+	}
+	if owner != nil {
+		cm.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+	return cm, nil
+}
+
+// ReadMetrics returns the metrics from a results ConfigMap
+func ReadMetrics(cm *corev1.ConfigMap) (*Metrics, error) {
+	data, ok := cm.Data[MetricsKey]
+	if !ok {
+		return nil, fmt.Errorf("no metrics in %s/%s", cm.Namespace, cm.Name)
+	}
+
+	var metrics Metrics
+	if err := json.Unmarshal([]byte(data), &metrics); err != nil {
+		return nil, fmt.Errorf("could not parse metrics in %s/%s: %w", cm.Namespace, cm.Name, err)
+	}
+	return &metrics, nil
+}
+
+// Save creates the results ConfigMap, or replaces it if it's already there (e.g., from a previous
+// attempt of the driver job)
+func Save(ctx context.Context, client kubernetes.Interface, cm *corev1.ConfigMap) error {
+	cms := client.CoreV1().ConfigMaps(cm.Namespace)
+	_, err := cms.Create(ctx, cm, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		_, err = cms.Update(ctx, cm, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("could not save results %s/%s: %w", cm.Namespace, cm.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package results
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testMetrics() *Metrics {
+	return &Metrics{
+		Simulation: testSimName,
+		Series:     []Series{{Name: "nodes", Samples: []Sample{{1000, 3}, {1015, 4}}}},
+	}
+}
+
+func TestConfigMapRoundTrip(t *testing.T) {
+	owner := &metav1.OwnerReference{APIVersion: "simkube.io/v1", Kind: "Simulation", Name: testSimName, UID: "abcd"}
+	cm, err := BuildConfigMap("simkube", owner, testMetrics())
+	assert.Nil(t, err)
+	assert.Equal(t, "simkube", cm.Namespace)
+	assert.Equal(t, "sk-test-sim-results", cm.Name)
+	assert.Equal(t, []metav1.OwnerReference{*owner}, cm.OwnerReferences)
+	assert.Equal(t, testSimName, cm.Labels[simulationLabel])
+
+	metrics, err := ReadMetrics(cm)
+	assert.Nil(t, err)
+	assert.Equal(t, testMetrics(), metrics)
+}
+
+func TestReadMetricsErrors(t *testing.T) {
+	cm, err := BuildConfigMap("simkube", nil, testMetrics())
+	assert.Nil(t, err)
+	assert.Empty(t, cm.OwnerReferences)
+
+	cm.Data[MetricsKey] = "{"
+	_, err = ReadMetrics(cm)
+	assert.ErrorContains(t, err, "could not parse metrics in simkube/sk-test-sim-results")
+
+	delete(cm.Data, MetricsKey)
+	_, err = ReadMetrics(cm)
+	assert.ErrorContains(t, err, "no metrics in simkube/sk-test-sim-results")
+}
+
+func TestSave(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ctx := context.TODO()
+
+	cm, err := BuildConfigMap("simkube", nil, testMetrics())
+	assert.Nil(t, err)
+	assert.Nil(t, Save(ctx, client, cm))
+
+	// Saving again replaces the old results
+	metrics := testMetrics()
+	metrics.Series[0].Samples = metrics.Series[0].Samples[:1]
+	cm, err = BuildConfigMap("simkube", nil, metrics)
+	assert.Nil(t, err)
+	assert.Nil(t, Save(ctx, client, cm))
+
+	saved, err := client.CoreV1().ConfigMaps("simkube").Get(ctx, cm.Name, metav1.GetOptions{})
+	assert.Nil(t, err)
+	savedMetrics, err := ReadMetrics(saved)
+	assert.Nil(t, err)
+	assert.Equal(t, metrics, savedMetrics)
+}
@@ -0,0 +1,118 @@
+package results
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	simkubev1 "simkube/lib/go/api/v1"
+)
+
+// Besides the metrics ConfigMap, the driver writes the results of a simulation to a directory next
+// to the trace: a summary of how the simulation went, and the metrics bundle (if it collected any
+// metrics).  The Simulation status says where they are (see SimulationResults).  These have to
+// match the ones that sk-driver uses (see driver/results.rs).
+const (
+	SummaryFile = "summary.json"
+	MetricsFile = "metrics.json"
+
+	// SummaryVersion is bumped whenever the summary format changes in a way that older readers
+	// can't handle
+	SummaryVersion = 1
+)
+
+// A Summary says how the simulation went; the trace and the progress are copied from the
+// Simulation, so that the results make sense on their own
+type Summary struct {
+	Version        int                       `json:"version"`
+	Simulation     string                    `json:"simulation"`
+	Trace          string                    `json:"trace,omitempty"`
+	Phase          simkubev1.SimulationPhase `json:"phase"`
+	Error          string                    `json:"error,omitempty"`
+	StartTime      time.Time                 `json:"startTime"`
+	CompletionTime time.Time                 `json:"completionTime"`
+	EventsReplayed int64                     `json:"eventsReplayed"`
+	TotalEvents    int64                     `json:"totalEvents"`
+
+	// Metrics is the name of the metrics bundle, if there is one
+	Metrics string `json:"metrics,omitempty"`
+}
+
+// WriteLocal writes the summary (and the metrics, if there are any) to dir, and returns the
+// reference to them for the Simulation status; location is where dir can be found from outside of
+// the driver (e.g., the host path that it's mounted from).
+func WriteLocal(dir, location string, summary *Summary, metrics *Metrics) (*simkubev1.SimulationResults, error) {
+	if err := os.MkdirAll(dir, fs.ModeDir|0755); err != nil {
+		return nil, fmt.Errorf("could not create results directory %s: %w", dir, err)
+	}
+
+	ref := &simkubev1.SimulationResults{Location: location, Summary: SummaryFile}
+	if metrics != nil {
+		if err := writeJSON(filepath.Join(dir, MetricsFile), metrics); err != nil {
+			return nil, err
+		}
+		ref.Metrics = MetricsFile
+	}
+
+	summary.Metrics = ref.Metrics
+	if err := writeJSON(filepath.Join(dir, SummaryFile), summary); err != nil {
+		return nil, err
+	}
+	return ref, nil
+}
+
+// ReadLocal reads the results that ref points to; only local (file://) locations are supported.
+// The metrics are nil if the simulation didn't collect any.
+func ReadLocal(ref *simkubev1.SimulationResults) (*Summary, *Metrics, error) {
+	u, err := url.Parse(ref.Location)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid results location %s: %w", ref.Location, err)
+	} else if u.Scheme != "file" {
+		return nil, nil, fmt.Errorf("only local results locations are supported: %s", ref.Location)
+	}
+
+	var summary Summary
+	if err := readJSON(filepath.Join(u.Path, ref.Summary), &summary); err != nil {
+		return nil, nil, err
+	} else if summary.Version > SummaryVersion {
+		return nil, nil, fmt.Errorf("unsupported results summary version: %d", summary.Version)
+	}
+
+	if ref.Metrics == "" {
+		return &summary, nil, nil
+	}
+	var metrics Metrics
+	if err := readJSON(filepath.Join(u.Path, ref.Metrics), &metrics); err != nil {
+		return nil, nil, err
+	}
+	return &summary, &metrics, nil
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal %s: %w", filepath.Base(path), err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	return nil
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no results at %s: %w", path, err)
+	} else if err != nil {
+		return fmt.Errorf("could not read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package results
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	simkubev1 "simkube/lib/go/api/v1"
+)
+
+func testSummary() *Summary {
+	return &Summary{
+		Version:        SummaryVersion,
+		Simulation:     testSimName,
+		Trace:          "file:///data/trace",
+		Phase:          simkubev1.SimulationSucceeded,
+		StartTime:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		CompletionTime: time.Date(2024, 1, 1, 0, 5, 0, 0, time.UTC),
+		EventsReplayed: 10,
+		TotalEvents:    10,
+	}
+}
+
+func TestResultsRoundTrip(t *testing.T) {
+	for name, tc := range map[string]struct {
+		metrics         *Metrics
+		expectedMetrics string
+	}{
+		"with metrics":    {metrics: testMetrics(), expectedMetrics: MetricsFile},
+		"without metrics": {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "sk-test-sim-results")
+			ref, err := WriteLocal(dir, "file://"+dir, testSummary(), tc.metrics)
+			require.Nil(t, err)
+			assert.Equal(t, &simkubev1.SimulationResults{
+				Location: "file://" + dir,
+				Summary:  SummaryFile,
+				Metrics:  tc.expectedMetrics,
+			}, ref)
+
+			summary, metrics, err := ReadLocal(ref)
+			require.Nil(t, err)
+			expectedSummary := testSummary()
+			expectedSummary.Metrics = tc.expectedMetrics
+			assert.Equal(t, expectedSummary, summary)
+			assert.Equal(t, tc.metrics, metrics)
+		})
+	}
+}
+
+func TestReadLocalErrors(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(dir, SummaryFile), []byte(`{"version":2}`), 0600))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "bad.json"), []byte("{"), 0600))
+
+	for name, tc := range map[string]struct {
+		ref           simkubev1.SimulationResults
+		expectedError string
+	}{
+		"remote location": {
+			ref:           simkubev1.SimulationResults{Location: "s3://bucket/results", Summary: SummaryFile},
+			expectedError: "only local results locations are supported",
+		},
+		"missing summary": {
+			ref:           simkubev1.SimulationResults{Location: "file://" + dir, Summary: "missing.json"},
+			expectedError: "no results at",
+		},
+		"invalid summary": {
+			ref:           simkubev1.SimulationResults{Location: "file://" + dir, Summary: "bad.json"},
+			expectedError: "could not parse",
+		},
+		"newer summary": {
+			ref:           simkubev1.SimulationResults{Location: "file://" + dir, Summary: SummaryFile},
+			expectedError: "unsupported results summary version: 2",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := ReadLocal(&tc.ref)
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package faketracer
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/trace"
+)
+
+// An ExportFunc builds the trace that the fake tracer sends back for an export request; if it
+// returns an error, the tracer responds with a 500
+type ExportFunc func(req *simkubev1.ExportRequest) (*trace.Trace, error)
+
+// A Tracer serves sk-tracer's HTTP API (the /health, /config, and /export endpoints) with canned
+// responses, and records every export request that it gets, so that code that talks to the
+// tracer can be tested without a cluster.  Start it with httptest.NewServer (or NewTLSServer).
+// The fields must be set before the server starts.
+type Tracer struct {
+	// Config is what /config returns, and what the default exports are recorded with;
+	// by default, trace.DefaultTracerConfig()
+	Config *trace.TracerConfig
+
+	// Export builds the export responses; by default, SingleEventExport
+	Export ExportFunc
+
+	// If Token is set, requests without it (as a bearer token) get a 401 back
+	Token string
+
+	// The first Failures requests (to any endpoint) get a 503 back, and exports take Delay to
+	// respond
+	Failures int
+	Delay    time.Duration
+
+	mu          sync.Mutex
+	requests    []*simkubev1.ExportRequest
+	calls       int
+	inFlight    int
+	maxInFlight int
+}
+
+func New() *Tracer {
+	return &Tracer{Config: trace.DefaultTracerConfig()}
+}
+
+// SingleEventExport returns a trace with one event at the start of the requested time window,
+// which creates the default/nginx Deployment
+func SingleEventExport(config *trace.TracerConfig) ExportFunc {
+	return func(req *simkubev1.ExportRequest) (*trace.Trace, error) {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("apps/v1")
+		obj.SetKind("Deployment")
+		obj.SetNamespace("default")
+		obj.SetName("nginx")
+		return &trace.Trace{
+			Config: config,
+			Events: []trace.Event{{Ts: req.StartTs, AppliedObjs: []*unstructured.Unstructured{obj}}},
+			Index:  map[string]uint64{"default/nginx": 1},
+		}, nil
+	}
+}
+
+// StaticExport returns the same trace for every export request
+func StaticExport(tr *trace.Trace) ExportFunc {
+	return func(*simkubev1.ExportRequest) (*trace.Trace, error) {
+		return tr, nil
+	}
+}
+
+func (self *Tracer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	self.mu.Lock()
+	self.calls++
+	failed := self.calls <= self.Failures
+	self.mu.Unlock()
+
+	if failed {
+		http.Error(w, "try again later", http.StatusServiceUnavailable)
+		return
+	} else if self.Token != "" && r.Header.Get("Authorization") != "Bearer "+self.Token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/health":
+		_, _ = w.Write([]byte("ok"))
+	case "/config":
+		data, err := json.Marshal(self.config())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(data)
+	case "/export":
+		self.serveExport(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Requests returns the export requests that the tracer has gotten, in the order they arrived
+func (self *Tracer) Requests() []*simkubev1.ExportRequest {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return append([]*simkubev1.ExportRequest(nil), self.requests...)
+}
+
+// Calls returns how many requests (to any endpoint) the tracer has gotten
+func (self *Tracer) Calls() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.calls
+}
+
+// MaxInFlight returns the most exports that were being served at the same time
+func (self *Tracer) MaxInFlight() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.maxInFlight
+}
+
+func (self *Tracer) serveExport(w http.ResponseWriter, r *http.Request) {
+	var req simkubev1.ExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.StartTs >= req.EndTs {
+		http.Error(w, "invalid export request", http.StatusBadRequest)
+		return
+	}
+	self.startExport(&req)
+	defer self.finishExport()
+
+	export := self.Export
+	if export == nil {
+		export = SingleEventExport(self.config())
+	}
+	tr, err := export(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := trace.WriteTrace(&buf, tr); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write(buf.Bytes())
+}
+
+func (self *Tracer) startExport(req *simkubev1.ExportRequest) {
+	self.mu.Lock()
+	self.requests = append(self.requests, req)
+	self.inFlight++
+	if self.inFlight > self.maxInFlight {
+		self.maxInFlight = self.inFlight
+	}
+	self.mu.Unlock()
+	time.Sleep(self.Delay)
+}
+
+func (self *Tracer) finishExport() {
+	self.mu.Lock()
+	self.inFlight--
+	self.mu.Unlock()
+}
+
+func (self *Tracer) config() *trace.TracerConfig {
+	if self.Config == nil {
+		return trace.DefaultTracerConfig()
+	}
+	return self.Config
+}
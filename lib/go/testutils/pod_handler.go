@@ -2,6 +2,7 @@ package testutils
 
 import (
 	"context"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	corev1 "k8s.io/api/core/v1"
@@ -41,6 +42,26 @@ func (self *PodHandler) GetPods(ctx context.Context) ([]*corev1.Pod, error) {
 	return retvals.Get(0).([]*corev1.Pod), retvals.Error(1)
 }
 
+func (self *PodHandler) SetAllocatable(allocatable corev1.ResourceList) {
+	self.Called(allocatable)
+}
+
+func (self *PodHandler) SetSchedulerDelays(delays map[string]time.Duration) {
+	self.Called(delays)
+}
+
+func (self *PodHandler) SetNodeDown(down bool) {
+	self.Called(down)
+}
+
+func (self *PodHandler) TerminatePods() {
+	self.Called()
+}
+
+func (self *PodHandler) SetSimulationPaused(simName string, paused bool) {
+	self.Called(simName, paused)
+}
+
 func NewPodHandler() *PodHandler {
 	ph := &PodHandler{}
 
@@ -50,5 +71,10 @@ func NewPodHandler() *PodHandler {
 	ph.On("GetPod", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
 	ph.On("GetPodStatus", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
 	ph.On("GetPods", mock.Anything).Return([]*corev1.Pod{}, nil)
+	ph.On("SetAllocatable", mock.Anything).Return()
+	ph.On("SetSchedulerDelays", mock.Anything).Return()
+	ph.On("SetNodeDown", mock.Anything).Return()
+	ph.On("TerminatePods").Return()
+	ph.On("SetSimulationPaused", mock.Anything, mock.Anything).Return()
 	return ph
 }
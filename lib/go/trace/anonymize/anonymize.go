@@ -0,0 +1,205 @@
+package anonymize
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Hashed names are the first hashLen hex digits of the salted SHA-256 hash, which is short
+// enough to fit in a label value or a namespace name, even with a prefix in front of it
+const hashLen = 16
+
+// Generated salts are 256 bits, the same size as the hashes they go into
+const saltLen = 32
+
+// A Policy says what to anonymize; everything that's hashed is hashed consistently (the same
+// value always hashes to the same thing, as long as the salt is the same), so that owner
+// references, selectors, and the keys in the trace all still line up afterwards.
+type Policy struct {
+	// Salt is mixed into every hash, so that common names (like default or nginx) can't be
+	// recovered by hashing them; it has to be kept secret, and an empty salt offers no
+	// protection at all, so use NewSalt to generate one
+	Salt string `json:"salt,omitempty"`
+
+	// HashNames hashes the names, generateNames, and namespaces of objects, and the names in
+	// their owner references; HashLabels hashes the label values (but not the keys) in the
+	// objects' metadata, their pod templates, and their selectors
+	HashNames  bool `json:"hashNames,omitempty"`
+	HashLabels bool `json:"hashLabels,omitempty"`
+
+	// StripEnv removes all of the env vars from the objects' containers, and StripSecretRefs
+	// removes the references to secrets: env vars that come from secrets, image pull secrets,
+	// and secret volumes (which are replaced by empty dirs, so that the volume mounts are still
+	// valid)
+	StripEnv        bool `json:"stripEnv,omitempty"`
+	StripSecretRefs bool `json:"stripSecretRefs,omitempty"`
+
+	// StripImageRegistries removes the registry from the containers' images, e.g.,
+	// "registry.example.com:5000/team/app:v1" becomes "team/app:v1"
+	StripImageRegistries bool `json:"stripImageRegistries,omitempty"`
+
+	// StripAnnotations removes all of the annotations from the objects and their pod
+	// templates, since they can contain anything (including the last-applied-configuration)
+	StripAnnotations bool `json:"stripAnnotations,omitempty"`
+}
+
+// DefaultPolicy anonymizes everything that we know how to anonymize
+func DefaultPolicy() Policy {
+	return Policy{
+		HashNames:            true,
+		HashLabels:           true,
+		StripEnv:             true,
+		StripSecretRefs:      true,
+		StripImageRegistries: true,
+		StripAnnotations:     true,
+	}
+}
+
+// An Anonymizer applies a policy to objects or whole traces
+type Anonymizer struct {
+	policy Policy
+}
+
+// NewSalt returns a random salt; it needs to be saved to get the same hashes in other traces
+func NewSalt() (string, error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("could not generate salt: %w", err)
+	}
+	return hex.EncodeToString(salt), nil
+}
+
+func New(policy Policy) *Anonymizer {
+	return &Anonymizer{policy: policy}
+}
+
+// Hash returns the consistent hash of the value; empty values stay empty
+func (self *Anonymizer) Hash(value string) string {
+	if value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(self.policy.Salt + "\x00" + value))
+	return hex.EncodeToString(sum[:])[:hashLen]
+}
+
+// HashName hashes an object name or a namespace if the policy says to, and returns it
+// unchanged otherwise
+func (self *Anonymizer) HashName(name string) string {
+	if !self.policy.HashNames {
+		return name
+	}
+	return self.Hash(name)
+}
+
+// Object anonymizes the object in place.  Pod specs are found by looking for their containers,
+// instead of with the tracer config's pod spec template paths, so that this works for any kind
+// of object (including pods).
+func (self *Anonymizer) Object(obj *unstructured.Unstructured) {
+	self.anonymize(obj.Object)
+}
+
+func (self *Anonymizer) anonymize(v interface{}) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if _, ok := val["containers"].([]interface{}); ok {
+			self.podSpec(val)
+		}
+		for key, child := range val {
+			switch key {
+			case "metadata":
+				if meta, ok := child.(map[string]interface{}); ok {
+					self.metadata(meta)
+				}
+			case "matchLabels", "selector":
+				// Label selectors have matchLabels and matchExpressions (which we get to when we
+				// recurse), but some objects, like services, have a plain map of labels instead
+				if labels, ok := child.(map[string]interface{}); ok && isLabelMap(labels) {
+					self.labels(labels)
+				}
+			case "matchExpressions":
+				self.matchExpressions(child)
+			}
+			self.anonymize(child)
+		}
+	case []interface{}:
+		for _, child := range val {
+			self.anonymize(child)
+		}
+	}
+}
+
+func (self *Anonymizer) metadata(meta map[string]interface{}) {
+	if self.policy.HashNames {
+		for _, field := range []string{"name", "namespace"} {
+			if name, ok := meta[field].(string); ok {
+				meta[field] = self.Hash(name)
+			}
+		}
+		if generateName, ok := meta["generateName"].(string); ok && generateName != "" {
+			meta["generateName"] = self.Hash(strings.TrimSuffix(generateName, "-")) + "-"
+		}
+		if ownerRefs, ok := meta["ownerReferences"].([]interface{}); ok {
+			for _, rf := range ownerRefs {
+				if ownerRef, ok := rf.(map[string]interface{}); ok {
+					if name, ok := ownerRef["name"].(string); ok {
+						ownerRef["name"] = self.Hash(name)
+					}
+				}
+			}
+		}
+	}
+
+	if labels, ok := meta["labels"].(map[string]interface{}); ok {
+		self.labels(labels)
+	}
+	if self.policy.StripAnnotations {
+		delete(meta, "annotations")
+	}
+}
+
+func (self *Anonymizer) labels(labels map[string]interface{}) {
+	if !self.policy.HashLabels {
+		return
+	}
+	for key, value := range labels {
+		if s, ok := value.(string); ok {
+			labels[key] = self.Hash(s)
+		}
+	}
+}
+
+func (self *Anonymizer) matchExpressions(v interface{}) {
+	exprs, ok := v.([]interface{})
+	if !ok || !self.policy.HashLabels {
+		return
+	}
+	for _, e := range exprs {
+		expr, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if values, ok := expr["values"].([]interface{}); ok {
+			for i, value := range values {
+				if s, ok := value.(string); ok {
+					values[i] = self.Hash(s)
+				}
+			}
+		}
+	}
+}
+
+// isLabelMap returns true if the map is a set of labels, instead of a label selector (or some
+// other kind of object)
+func isLabelMap(m map[string]interface{}) bool {
+	for _, value := range m {
+		if _, ok := value.(string); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+package anonymize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	testNamespace      = "payments"
+	testDeploymentName = "ledger"
+)
+
+func testDeploymentObj() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"namespace":   testNamespace,
+			"name":        testDeploymentName,
+			"labels":      map[string]interface{}{"app": "ledger"},
+			"annotations": map[string]interface{}{"secret-project": "yes"},
+			"ownerReferences": []interface{}{
+				map[string]interface{}{"apiVersion": "example.com/v1", "kind": "Ledger", "name": "ledger-owner"},
+			},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{"app": "ledger"},
+				"matchExpressions": []interface{}{
+					map[string]interface{}{"key": "tier", "operator": "In", "values": []interface{}{"backend"}},
+				},
+			},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"labels":      map[string]interface{}{"app": "ledger", "tier": "backend"},
+					"annotations": map[string]interface{}{"secret-project": "yes"},
+				},
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{
+							"name":  "ledger",
+							"image": "registry.example.com/payments/ledger:v1",
+							"env": []interface{}{
+								map[string]interface{}{"name": "MODE", "value": "prod"},
+							},
+						},
+					},
+					"nodeSelector": map[string]interface{}{"type": "virtual"},
+				},
+			},
+		},
+	}}
+}
+
+func TestAnonymizerHash(t *testing.T) {
+	anonymizer := New(Policy{})
+	assert.Equal(t, anonymizer.Hash("ledger"), anonymizer.Hash("ledger"))
+	assert.NotEqual(t, anonymizer.Hash("ledger"), anonymizer.Hash("payments"))
+	assert.Len(t, anonymizer.Hash("ledger"), hashLen)
+	assert.Equal(t, "", anonymizer.Hash(""))
+
+	// Different salts give different hashes
+	assert.NotEqual(t, anonymizer.Hash("ledger"), New(Policy{Salt: "foo"}).Hash("ledger"))
+
+	// Names are only hashed if the policy says to
+	assert.Equal(t, "ledger", anonymizer.HashName("ledger"))
+	assert.Equal(t, anonymizer.Hash("ledger"), New(Policy{HashNames: true}).HashName("ledger"))
+}
+
+func TestNewSalt(t *testing.T) {
+	salt1, err := NewSalt()
+	assert.Nil(t, err)
+	salt2, err := NewSalt()
+	assert.Nil(t, err)
+
+	assert.Len(t, salt1, 2*saltLen)
+	assert.NotEqual(t, salt1, salt2)
+	assert.NotEqual(t, New(Policy{Salt: salt1}).Hash("default"), New(Policy{Salt: salt2}).Hash("default"))
+}
+
+func TestAnonymizerObject(t *testing.T) {
+	anonymizer := New(DefaultPolicy())
+	obj := testDeploymentObj()
+	anonymizer.Object(obj)
+
+	h := anonymizer.Hash
+	assert.Equal(t, h(testNamespace), obj.GetNamespace())
+	assert.Equal(t, h(testDeploymentName), obj.GetName())
+	assert.Equal(t, map[string]string{"app": h("ledger")}, obj.GetLabels())
+	assert.Nil(t, obj.GetAnnotations())
+	assert.Equal(t, h("ledger-owner"), obj.GetOwnerReferences()[0].Name)
+
+	// The selector still matches the pod template
+	matchLabels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "selector", "matchLabels")
+	assert.Equal(t, map[string]string{"app": h("ledger")}, matchLabels)
+	exprs, _, _ := unstructured.NestedSlice(obj.Object, "spec", "selector", "matchExpressions")
+	assert.Equal(t, []interface{}{h("backend")}, exprs[0].(map[string]interface{})["values"])
+
+	templateLabels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "labels")
+	assert.Equal(t, map[string]string{"app": h("ledger"), "tier": h("backend")}, templateLabels)
+	_, found, _ := unstructured.NestedMap(obj.Object, "spec", "template", "metadata", "annotations")
+	assert.False(t, found)
+
+	containers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "containers")
+	assert.Equal(t, map[string]interface{}{"name": "ledger", "image": "payments/ledger:v1"}, containers[0])
+
+	// The node selector is used for scheduling in the simulation, so it's left alone
+	nodeSelector, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "template", "spec", "nodeSelector")
+	assert.Equal(t, map[string]string{"type": "virtual"}, nodeSelector)
+}
+
+func TestAnonymizerObjectEmptyPolicy(t *testing.T) {
+	obj := testDeploymentObj()
+	New(Policy{}).Object(obj)
+	assert.Equal(t, testDeploymentObj(), obj)
+}
+
+func TestAnonymizerServiceSelector(t *testing.T) {
+	anonymizer := New(Policy{HashLabels: true})
+	svc := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"namespace": testNamespace, "name": "ledger-svc"},
+		"spec":       map[string]interface{}{"selector": map[string]interface{}{"app": "ledger"}},
+	}}
+	anonymizer.Object(svc)
+
+	selector, _, _ := unstructured.NestedStringMap(svc.Object, "spec", "selector")
+	assert.Equal(t, map[string]string{"app": anonymizer.Hash("ledger")}, selector)
+	assert.Equal(t, "ledger-svc", svc.GetName())
+}
+
+func TestAnonymizerGenerateName(t *testing.T) {
+	anonymizer := New(Policy{HashNames: true})
+	pod := &unstructured.Unstructured{}
+	pod.SetGenerateName("ledger-")
+	anonymizer.Object(pod)
+	assert.Equal(t, anonymizer.Hash("ledger")+"-", pod.GetGenerateName())
+}
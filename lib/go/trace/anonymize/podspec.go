@@ -0,0 +1,100 @@
+package anonymize
+
+import (
+	"strings"
+)
+
+// podSpec strips the env vars, secret references, and image registries from the pod spec
+func (self *Anonymizer) podSpec(spec map[string]interface{}) {
+	if self.policy.StripSecretRefs {
+		delete(spec, "imagePullSecrets")
+		stripSecretVolumes(spec)
+	}
+
+	for _, field := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		containers, ok := spec[field].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			if self.policy.StripEnv {
+				delete(container, "env")
+				delete(container, "envFrom")
+			} else if self.policy.StripSecretRefs {
+				stripSecretEnv(container)
+			}
+			if image, ok := container["image"].(string); ok && self.policy.StripImageRegistries {
+				container["image"] = StripImageRegistry(image)
+			}
+		}
+	}
+}
+
+// StripImageRegistry removes the registry from an image reference; like docker, the first
+// part of the image is only a registry if it looks like a hostname (i.e., it has a "." or
+// a ":" in it, or it's "localhost"), so "team/app" doesn't have a registry
+func StripImageRegistry(image string) string {
+	registry, rest, ok := strings.Cut(image, "/")
+	if ok && (strings.ContainsAny(registry, ".:") || registry == "localhost") {
+		return rest
+	}
+	return image
+}
+
+func stripSecretEnv(container map[string]interface{}) {
+	filterField(container, "env", func(envVar map[string]interface{}) bool {
+		valueFrom, ok := envVar["valueFrom"].(map[string]interface{})
+		return ok && valueFrom["secretKeyRef"] != nil
+	})
+	filterField(container, "envFrom", func(envFrom map[string]interface{}) bool {
+		return envFrom["secretRef"] != nil
+	})
+}
+
+// stripSecretVolumes replaces secret volumes with empty dirs, and removes the secrets from
+// projected volumes
+func stripSecretVolumes(spec map[string]interface{}) {
+	volumes, ok := spec["volumes"].([]interface{})
+	if !ok {
+		return
+	}
+
+	for _, v := range volumes {
+		volume, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if _, ok := volume["secret"]; ok {
+			delete(volume, "secret")
+			volume["emptyDir"] = map[string]interface{}{}
+		}
+		if projected, ok := volume["projected"].(map[string]interface{}); ok {
+			filterField(projected, "sources", func(source map[string]interface{}) bool {
+				return source["secret"] != nil
+			})
+		}
+	}
+}
+
+// filterField removes the items that match from the list in m[key], if there is one
+func filterField(m map[string]interface{}, key string, remove func(map[string]interface{}) bool) {
+	items, ok := m[key].([]interface{})
+	if !ok {
+		return
+	}
+
+	filtered := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		if obj, ok := item.(map[string]interface{}); ok && remove(obj) {
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+	m[key] = filtered
+}
@@ -0,0 +1,98 @@
+package anonymize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPodSpec() map[string]interface{} {
+	return map[string]interface{}{
+		"imagePullSecrets": []interface{}{map[string]interface{}{"name": "regcred"}},
+		"containers": []interface{}{
+			map[string]interface{}{
+				"name":  "ledger",
+				"image": "ledger:v1",
+				"env": []interface{}{
+					map[string]interface{}{"name": "MODE", "value": "prod"},
+					map[string]interface{}{
+						"name": "PASSWORD",
+						"valueFrom": map[string]interface{}{
+							"secretKeyRef": map[string]interface{}{"name": "db", "key": "password"},
+						},
+					},
+				},
+				"envFrom": []interface{}{
+					map[string]interface{}{"configMapRef": map[string]interface{}{"name": "config"}},
+					map[string]interface{}{"secretRef": map[string]interface{}{"name": "creds"}},
+				},
+			},
+		},
+		"volumes": []interface{}{
+			map[string]interface{}{"name": "certs", "secret": map[string]interface{}{"secretName": "certs"}},
+			map[string]interface{}{
+				"name": "all",
+				"projected": map[string]interface{}{
+					"sources": []interface{}{
+						map[string]interface{}{"secret": map[string]interface{}{"name": "certs"}},
+						map[string]interface{}{"configMap": map[string]interface{}{"name": "config"}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestPodSpecStripSecretRefs(t *testing.T) {
+	spec := testPodSpec()
+	New(Policy{StripSecretRefs: true}).podSpec(spec)
+
+	assert.Equal(t, map[string]interface{}{
+		"containers": []interface{}{
+			map[string]interface{}{
+				"name":  "ledger",
+				"image": "ledger:v1",
+				"env":   []interface{}{map[string]interface{}{"name": "MODE", "value": "prod"}},
+				"envFrom": []interface{}{
+					map[string]interface{}{"configMapRef": map[string]interface{}{"name": "config"}},
+				},
+			},
+		},
+		"volumes": []interface{}{
+			map[string]interface{}{"name": "certs", "emptyDir": map[string]interface{}{}},
+			map[string]interface{}{
+				"name": "all",
+				"projected": map[string]interface{}{
+					"sources": []interface{}{
+						map[string]interface{}{"configMap": map[string]interface{}{"name": "config"}},
+					},
+				},
+			},
+		},
+	}, spec)
+}
+
+func TestPodSpecStripEnv(t *testing.T) {
+	spec := testPodSpec()
+	New(Policy{StripEnv: true}).podSpec(spec)
+
+	container := spec["containers"].([]interface{})[0]
+	assert.Equal(t, map[string]interface{}{"name": "ledger", "image": "ledger:v1"}, container)
+	assert.Equal(t, testPodSpec()["volumes"], spec["volumes"])
+}
+
+func TestStripImageRegistry(t *testing.T) {
+	for image, expected := range map[string]string{
+		"nginx":                                         "nginx",
+		"nginx:1.25":                                    "nginx:1.25",
+		"library/nginx:1.25":                            "library/nginx:1.25",
+		"docker.io/library/nginx:1.25":                  "library/nginx:1.25",
+		"registry.example.com:5000/team/app:v1":         "team/app:v1",
+		"localhost/app@sha256:abcd":                     "app@sha256:abcd",
+		"123456789.dkr.ecr.us-west-2.amazonaws.com/app": "app",
+	} {
+		t.Run(image, func(t *testing.T) {
+			assert.Equal(t, expected, StripImageRegistry(image))
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package anonymize
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"simkube/lib/go/trace"
+)
+
+type indexKey struct {
+	nsName string
+	hash   uint64
+}
+
+// Trace returns an anonymized copy of the trace; the keys in the index and the pod lifecycle
+// data are hashed the same way as the objects' names, and the index is updated with the new
+// spec hashes.  The pod lifecycle data is keyed by the hash of each pod's spec, which we can't
+// recompute (the pods aren't in the trace), so if the policy changes the pod templates, the
+// pods in the simulation won't match any of the lifecycle data.
+func (self *Anonymizer) Trace(tr *trace.Trace) *trace.Trace {
+	anonymized := &trace.Trace{
+		Version:       tr.Version,
+		Config:        tr.Config,
+		Index:         make(map[string]uint64, len(tr.Index)),
+		PodLifecycles: make(map[string]map[uint64][]trace.PodLifecycleData, len(tr.PodLifecycles)),
+	}
+
+	newHashes := map[indexKey]uint64{}
+	for _, evt := range tr.Events {
+		anonymized.Events = append(anonymized.Events, trace.Event{
+			Ts:          evt.Ts,
+			AppliedObjs: self.objs(evt.AppliedObjs, newHashes),
+			DeletedObjs: self.objs(evt.DeletedObjs, newHashes),
+		})
+	}
+
+	for nsName, hash := range tr.Index {
+		if newHash, ok := newHashes[indexKey{nsName, hash}]; ok {
+			hash = newHash
+		}
+		anonymized.Index[self.nsName(nsName)] = hash
+	}
+	for owner, lifecycles := range tr.PodLifecycles {
+		anonymized.PodLifecycles[self.nsName(owner)] = lifecycles
+	}
+	return anonymized
+}
+
+// objs anonymizes copies of the objects, and records how their spec hashes changed
+func (self *Anonymizer) objs(
+	objs []*unstructured.Unstructured,
+	newHashes map[indexKey]uint64,
+) []*unstructured.Unstructured {
+	var anonymized []*unstructured.Unstructured
+	for _, obj := range objs {
+		newObj := obj.DeepCopy()
+		self.Object(newObj)
+		newHashes[indexKey{nsName(obj), trace.SpecHash(obj)}] = trace.SpecHash(newObj)
+		anonymized = append(anonymized, newObj)
+	}
+	return anonymized
+}
+
+// nsName hashes a key from the trace, which is either <namespace>/<name> or, for cluster-scoped
+// objects, just the name
+func (self *Anonymizer) nsName(key string) string {
+	if namespace, name, ok := strings.Cut(key, "/"); ok {
+		return fmt.Sprintf("%s/%s", self.HashName(namespace), self.HashName(name))
+	}
+	return self.HashName(key)
+}
+
+func nsName(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return fmt.Sprintf("%s/%s", obj.GetNamespace(), obj.GetName())
+}
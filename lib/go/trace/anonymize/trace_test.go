@@ -0,0 +1,47 @@
+package anonymize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"simkube/lib/go/trace"
+)
+
+func testTrace() *trace.Trace {
+	return &trace.Trace{
+		Version: trace.CurrentTraceFormat,
+		Config:  trace.DefaultTracerConfig(),
+		Events: []trace.Event{
+			{Ts: 0, AppliedObjs: []*unstructured.Unstructured{testDeploymentObj()}},
+			{Ts: 5, DeletedObjs: []*unstructured.Unstructured{testDeploymentObj()}},
+		},
+		Index: map[string]uint64{"payments/ledger": trace.SpecHash(testDeploymentObj()), "node1": 1234},
+		PodLifecycles: map[string]map[uint64][]trace.PodLifecycleData{
+			"payments/ledger": {1234: {{StartTs: 1000, EndTs: 2000}}},
+		},
+	}
+}
+
+func TestAnonymizerTrace(t *testing.T) {
+	anonymizer := New(DefaultPolicy())
+	tr := testTrace()
+	anonymized := anonymizer.Trace(tr)
+
+	expectedObj := testDeploymentObj()
+	anonymizer.Object(expectedObj)
+	assert.Equal(t, []trace.Event{
+		{Ts: 0, AppliedObjs: []*unstructured.Unstructured{expectedObj}},
+		{Ts: 5, DeletedObjs: []*unstructured.Unstructured{expectedObj}},
+	}, anonymized.Events)
+
+	h := anonymizer.Hash
+	nsName := h(testNamespace) + "/" + h(testDeploymentName)
+	assert.Equal(t, map[string]uint64{nsName: trace.SpecHash(expectedObj), h("node1"): 1234}, anonymized.Index)
+	assert.Equal(t, testTrace().PodLifecycles["payments/ledger"], anonymized.PodLifecycles[nsName])
+	assert.True(t, anonymized.HasObj(nsName))
+
+	// The original trace isn't modified
+	assert.Equal(t, testTrace(), tr)
+}
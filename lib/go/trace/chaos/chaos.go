@@ -0,0 +1,306 @@
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/trace"
+)
+
+type matchFunc func(obj *unstructured.Unstructured) bool
+
+// Inject returns a copy of the trace with the scenario's events spliced in.  A trace can only
+// say which objects were applied and deleted, so the chaos is expressed the same way: bursts and
+// scale-downs re-apply the matching objects with more (or fewer) replicas, and scale the
+// trace's own updates to those objects in the same way until the event is over, and evictions
+// delete the objects and apply them again later.  Fractions of objects and replicas are rounded
+// up.  The index is updated with the new spec hashes; the pod templates don't change, so the pod
+// lifecycle data still applies.
+func Inject(tr *trace.Trace, scenario *Scenario) (*trace.Trace, error) {
+	if err := scenario.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario: %w", err)
+	} else if len(tr.Events) == 0 {
+		return nil, errors.New("trace has no events")
+	}
+
+	injected := &trace.Trace{
+		Version:       tr.Version,
+		Config:        tr.Config,
+		Index:         make(map[string]uint64, len(tr.Index)),
+		PodLifecycles: tr.PodLifecycles,
+	}
+	for key, hash := range tr.Index {
+		injected.Index[key] = hash
+	}
+	for _, evt := range tr.Events {
+		injected.Events = append(injected.Events, trace.Event{
+			Ts:          evt.Ts,
+			AppliedObjs: append([]*unstructured.Unstructured(nil), evt.AppliedObjs...),
+			DeletedObjs: append([]*unstructured.Unstructured(nil), evt.DeletedObjs...),
+		})
+	}
+
+	//nolint:gosec // this doesn't need to be cryptographically secure
+	rng := rand.New(rand.NewSource(scenario.Seed))
+	startTs, endTs := tr.Events[0].Ts, tr.Events[len(tr.Events)-1].Ts
+	for i := range scenario.Events {
+		evt := &scenario.Events[i]
+		at := startTs + evt.AtSeconds
+		if at > endTs {
+			return nil, fmt.Errorf("event %d (%s) starts after the end of the trace", i, evt.Type)
+		}
+		sel, err := evt.selector()
+		if err != nil {
+			return nil, err
+		}
+		matches := matcher(evt.Namespaces, sel)
+
+		// Events that would last past the end of the trace last until the end instead
+		until := at + evt.DurationSeconds
+		if evt.DurationSeconds == 0 || until > endTs {
+			until = 0
+		}
+
+		switch evt.Type {
+		case Burst:
+			scaleReplicas(injected, at, until, matches, func(r int64) int64 {
+				return int64(math.Ceil(float64(r) * evt.Factor))
+			})
+		case ScaleDown:
+			scaleReplicas(injected, at, until, matches, func(r int64) int64 {
+				return r - int64(math.Ceil(float64(r)*evt.Fraction))
+			})
+		case Eviction:
+			downtime := evt.DurationSeconds
+			if downtime == 0 {
+				downtime = defaultEvictionSeconds
+			}
+			evict(injected, at, at+downtime, endTs, matches, evt.Fraction, rng)
+		}
+	}
+
+	for _, evt := range injected.Events {
+		for _, obj := range evt.AppliedObjs {
+			injected.Index[nsName(obj)] = trace.SpecHash(obj)
+		}
+	}
+	return injected, nil
+}
+
+// scaleReplicas scales the replicas of the matching objects from at until until (or the end of
+// the trace, if until is 0), and then puts them back the way they are in the trace
+func scaleReplicas(tr *trace.Trace, at, until int64, matches matchFunc, scale func(int64) int64) {
+	var originals map[string]*unstructured.Unstructured
+	if until != 0 {
+		originals = liveObjs(tr.Events, until)
+	}
+
+	scaled := map[string]bool{}
+	live := liveObjs(tr.Events, at)
+	for _, key := range sortedKeys(live) {
+		if obj, ok := scaledCopy(live[key], matches, scale); ok {
+			tr.Events = insertObj(tr.Events, at, obj, false)
+			scaled[key] = true
+		}
+	}
+
+	// The trace's own updates during the event get scaled too, so that they don't undo it
+	for i := range tr.Events {
+		evt := &tr.Events[i]
+		if evt.Ts <= at || (until != 0 && evt.Ts >= until) {
+			continue
+		}
+		for j, obj := range evt.AppliedObjs {
+			if scaledObj, ok := scaledCopy(obj, matches, scale); ok {
+				evt.AppliedObjs[j] = scaledObj
+				scaled[nsName(obj)] = true
+			}
+		}
+	}
+
+	if until == 0 {
+		return
+	}
+	updatedAtEnd := map[string]bool{}
+	if i := findEvent(tr.Events, until); i < len(tr.Events) && tr.Events[i].Ts == until {
+		for _, obj := range tr.Events[i].AppliedObjs {
+			updatedAtEnd[nsName(obj)] = true
+		}
+	}
+	for _, key := range sortedKeys(originals) {
+		if scaled[key] && !updatedAtEnd[key] {
+			tr.Events = insertObj(tr.Events, until, originals[key], false)
+		}
+	}
+}
+
+// evict deletes fraction of the matching objects that have pods at at, and applies them again
+// at recreateTs (as they are in the trace by then); the trace's own updates to the evicted
+// objects in the meantime are dropped.  If recreateTs is after the end of the trace, the
+// objects stay evicted.
+func evict(
+	tr *trace.Trace,
+	at, recreateTs, endTs int64,
+	matches matchFunc,
+	fraction float64,
+	rng *rand.Rand,
+) {
+	live := liveObjs(tr.Events, at)
+	var candidates []string
+	for _, key := range sortedKeys(live) {
+		if matches(live[key]) && hasPods(tr.Config, live[key]) {
+			candidates = append(candidates, key)
+		}
+	}
+
+	n := int(math.Ceil(float64(len(candidates)) * fraction))
+	evicted := map[string]*unstructured.Unstructured{}
+	for _, i := range rng.Perm(len(candidates))[:n] {
+		evicted[candidates[i]] = live[candidates[i]]
+	}
+	for _, key := range sortedKeys(evicted) {
+		tr.Events = insertObj(tr.Events, at, evicted[key], true)
+	}
+
+	for i := range tr.Events {
+		evt := &tr.Events[i]
+		if evt.Ts <= at || evt.Ts > recreateTs {
+			continue
+		}
+		evt.AppliedObjs = dropEvicted(evt.AppliedObjs, evicted, false)
+		evt.DeletedObjs = dropEvicted(evt.DeletedObjs, evicted, true)
+	}
+
+	if recreateTs > endTs {
+		return
+	}
+	for _, key := range sortedKeys(evicted) {
+		if obj := evicted[key]; obj != nil {
+			tr.Events = insertObj(tr.Events, recreateTs, obj, false)
+		}
+	}
+}
+
+// dropEvicted removes the evicted objects from the list, and keeps track of their latest state
+// (nil if the trace deleted them)
+func dropEvicted(
+	objs []*unstructured.Unstructured,
+	evicted map[string]*unstructured.Unstructured,
+	deleted bool,
+) []*unstructured.Unstructured {
+	var kept []*unstructured.Unstructured
+	for _, obj := range objs {
+		key := nsName(obj)
+		if _, ok := evicted[key]; !ok {
+			kept = append(kept, obj)
+		} else if deleted {
+			evicted[key] = nil
+		} else {
+			evicted[key] = obj
+		}
+	}
+	return kept
+}
+
+func scaledCopy(
+	obj *unstructured.Unstructured,
+	matches matchFunc,
+	scale func(int64) int64,
+) (*unstructured.Unstructured, bool) {
+	if !matches(obj) {
+		return nil, false
+	}
+	replicas, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if err != nil || !found {
+		return nil, false
+	}
+
+	scaled := obj.DeepCopy()
+	if err := unstructured.SetNestedField(scaled.Object, scale(replicas), "spec", "replicas"); err != nil {
+		return nil, false
+	}
+	return scaled, true
+}
+
+func matcher(namespaces []string, sel labels.Selector) matchFunc {
+	nsSet := map[string]bool{}
+	for _, ns := range namespaces {
+		nsSet[ns] = true
+	}
+	return func(obj *unstructured.Unstructured) bool {
+		if len(nsSet) > 0 && !nsSet[obj.GetNamespace()] {
+			return false
+		}
+		return sel.Matches(labels.Set(obj.GetLabels()))
+	}
+}
+
+func hasPods(config *trace.TracerConfig, obj *unstructured.Unstructured) bool {
+	if config == nil {
+		return false
+	}
+	return config.TrackedObjects[simkubev1.KindString(obj.GroupVersionKind())].PodSpecTemplatePath != ""
+}
+
+// liveObjs returns the objects that are in the cluster after the events at ts
+func liveObjs(events []trace.Event, ts int64) map[string]*unstructured.Unstructured {
+	live := map[string]*unstructured.Unstructured{}
+	for _, evt := range events {
+		if evt.Ts > ts {
+			break
+		}
+		for _, obj := range evt.AppliedObjs {
+			live[nsName(obj)] = obj
+		}
+		for _, obj := range evt.DeletedObjs {
+			delete(live, nsName(obj))
+		}
+	}
+	return live
+}
+
+// insertObj adds the object to the end of the event at ts, creating the event if there isn't
+// one
+func insertObj(events []trace.Event, ts int64, obj *unstructured.Unstructured, deleted bool) []trace.Event {
+	i := findEvent(events, ts)
+	if i == len(events) || events[i].Ts != ts {
+		events = append(events, trace.Event{})
+		copy(events[i+1:], events[i:])
+		events[i] = trace.Event{Ts: ts}
+	}
+
+	if deleted {
+		events[i].DeletedObjs = append(events[i].DeletedObjs, obj)
+	} else {
+		events[i].AppliedObjs = append(events[i].AppliedObjs, obj)
+	}
+	return events
+}
+
+func findEvent(events []trace.Event, ts int64) int {
+	return sort.Search(len(events), func(i int) bool { return events[i].Ts >= ts })
+}
+
+func sortedKeys(objs map[string]*unstructured.Unstructured) []string {
+	keys := make([]string, 0, len(objs))
+	for key := range objs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Cluster-scoped objects are just identified by their name in the trace
+func nsName(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return fmt.Sprintf("%s/%s", obj.GetNamespace(), obj.GetName())
+}
@@ -0,0 +1,199 @@
+package chaos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"simkube/lib/go/trace"
+)
+
+const testStartTs = 1000
+
+func testDeployment(namespace, name string, replicas int64) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"namespace": namespace,
+			"name":      name,
+			"labels":    map[string]interface{}{"app": name},
+		},
+		"spec": map[string]interface{}{"replicas": replicas},
+	}}
+}
+
+// testTrace has two deployments in the default namespace and one in another namespace; web gets
+// scaled up half-way through the trace
+func testTrace() *trace.Trace {
+	return &trace.Trace{
+		Version: 2,
+		Config:  trace.DefaultTracerConfig(),
+		Events: []trace.Event{
+			{
+				Ts: testStartTs,
+				AppliedObjs: []*unstructured.Unstructured{
+					testDeployment("default", "web", 2),
+					testDeployment("default", "db", 1),
+					testDeployment("other", "batch", 4),
+				},
+			},
+			{Ts: testStartTs + 50, AppliedObjs: []*unstructured.Unstructured{testDeployment("default", "web", 4)}},
+			{Ts: testStartTs + 100},
+		},
+		Index: map[string]uint64{},
+	}
+}
+
+type expectedEvent struct {
+	ts      int64
+	applied map[string]int64
+	deleted []string
+}
+
+func assertEvents(t *testing.T, expected []expectedEvent, tr *trace.Trace) {
+	require.Len(t, tr.Events, len(expected))
+	for i, e := range expected {
+		assert.Equal(t, e.ts, tr.Events[i].Ts)
+
+		var applied map[string]int64
+		for _, obj := range tr.Events[i].AppliedObjs {
+			if applied == nil {
+				applied = map[string]int64{}
+			}
+			applied[nsName(obj)], _, _ = unstructured.NestedInt64(obj.Object, "spec", "replicas")
+		}
+		var deleted []string
+		for _, obj := range tr.Events[i].DeletedObjs {
+			deleted = append(deleted, nsName(obj))
+		}
+		assert.Equal(t, e.applied, applied, "event %d", i)
+		assert.Equal(t, e.deleted, deleted, "event %d", i)
+	}
+}
+
+func TestInjectBurst(t *testing.T) {
+	tr := testTrace()
+	injected, err := Inject(tr, &Scenario{Events: []Event{{
+		Type:            Burst,
+		AtSeconds:       20,
+		DurationSeconds: 60,
+		Factor:          2.5,
+		Namespaces:      []string{"default"},
+	}}})
+	require.Nil(t, err)
+
+	assertEvents(t, []expectedEvent{
+		{ts: testStartTs, applied: map[string]int64{"default/web": 2, "default/db": 1, "other/batch": 4}},
+		{ts: testStartTs + 20, applied: map[string]int64{"default/db": 3, "default/web": 5}},
+		{ts: testStartTs + 50, applied: map[string]int64{"default/web": 10}},
+		{ts: testStartTs + 80, applied: map[string]int64{"default/db": 1, "default/web": 4}},
+		{ts: testStartTs + 100},
+	}, injected)
+	assert.Equal(t, trace.SpecHash(injected.Events[3].AppliedObjs[1]), injected.Index["default/web"])
+
+	// The original trace is left alone
+	replicas, _, _ := unstructured.NestedInt64(tr.Events[1].AppliedObjs[0].Object, "spec", "replicas")
+	assert.Equal(t, int64(4), replicas)
+	assert.Len(t, tr.Events, 3)
+}
+
+func TestInjectScaleDownUntilEnd(t *testing.T) {
+	injected, err := Inject(testTrace(), &Scenario{Events: []Event{{
+		Type:      ScaleDown,
+		AtSeconds: 30,
+		Fraction:  0.5,
+		Selector:  &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+	}}})
+	require.Nil(t, err)
+
+	assertEvents(t, []expectedEvent{
+		{ts: testStartTs, applied: map[string]int64{"default/web": 2, "default/db": 1, "other/batch": 4}},
+		{ts: testStartTs + 30, applied: map[string]int64{"default/web": 1}},
+		{ts: testStartTs + 50, applied: map[string]int64{"default/web": 2}},
+		{ts: testStartTs + 100},
+	}, injected)
+}
+
+func TestInjectEviction(t *testing.T) {
+	injected, err := Inject(testTrace(), &Scenario{Events: []Event{{
+		Type:            Eviction,
+		AtSeconds:       40,
+		DurationSeconds: 20,
+		Fraction:        1,
+		Namespaces:      []string{"default"},
+	}}})
+	require.Nil(t, err)
+
+	// The trace's update to web while it's evicted shows up when it's recreated
+	assertEvents(t, []expectedEvent{
+		{ts: testStartTs, applied: map[string]int64{"default/web": 2, "default/db": 1, "other/batch": 4}},
+		{ts: testStartTs + 40, deleted: []string{"default/db", "default/web"}},
+		{ts: testStartTs + 50},
+		{ts: testStartTs + 60, applied: map[string]int64{"default/db": 1, "default/web": 4}},
+		{ts: testStartTs + 100},
+	}, injected)
+}
+
+func TestInjectEvictionDeterministic(t *testing.T) {
+	scenario := &Scenario{Seed: 3, Events: []Event{{Type: Eviction, AtSeconds: 10, Fraction: 0.5}}}
+	tr1, err := Inject(testTrace(), scenario)
+	require.Nil(t, err)
+	tr2, err := Inject(testTrace(), scenario)
+	require.Nil(t, err)
+	assert.Equal(t, tr1, tr2)
+
+	// Half of the three deployments, rounded up
+	assert.Len(t, tr1.Events[1].DeletedObjs, 2)
+	assert.Equal(t, int64(testStartTs+10+defaultEvictionSeconds), tr1.Events[2].Ts)
+	assert.Len(t, tr1.Events[2].AppliedObjs, 2)
+}
+
+func TestInjectSequential(t *testing.T) {
+	injected, err := Inject(testTrace(), &Scenario{Events: []Event{
+		{Type: Burst, AtSeconds: 10, Factor: 2, Namespaces: []string{"other"}},
+		{Type: ScaleDown, AtSeconds: 20, DurationSeconds: 10, Fraction: 0.25, Namespaces: []string{"other"}},
+	}})
+	require.Nil(t, err)
+
+	assertEvents(t, []expectedEvent{
+		{ts: testStartTs, applied: map[string]int64{"default/web": 2, "default/db": 1, "other/batch": 4}},
+		{ts: testStartTs + 10, applied: map[string]int64{"other/batch": 8}},
+		{ts: testStartTs + 20, applied: map[string]int64{"other/batch": 6}},
+		{ts: testStartTs + 30, applied: map[string]int64{"other/batch": 8}},
+		{ts: testStartTs + 50, applied: map[string]int64{"default/web": 4}},
+		{ts: testStartTs + 100},
+	}, injected)
+}
+
+func TestInjectErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		tr            *trace.Trace
+		scenario      *Scenario
+		expectedError string
+	}{
+		"invalid scenario": {
+			tr:            testTrace(),
+			scenario:      &Scenario{},
+			expectedError: "invalid scenario",
+		},
+		"empty trace": {
+			tr:            &trace.Trace{},
+			scenario:      &Scenario{Events: []Event{{Type: Burst, Factor: 2}}},
+			expectedError: "trace has no events",
+		},
+		"after the end": {
+			tr:            testTrace(),
+			scenario:      &Scenario{Events: []Event{{Type: Burst, AtSeconds: 101, Factor: 2}}},
+			expectedError: "event 0 (burst) starts after the end of the trace",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Inject(tc.tr, tc.scenario)
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
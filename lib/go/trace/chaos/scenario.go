@@ -0,0 +1,128 @@
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+type EventType string
+
+const (
+	// A Burst multiplies the replicas of the matching objects by Factor, e.g., for a sudden
+	// spike in traffic
+	Burst EventType = "burst"
+
+	// A ScaleDown takes away Fraction of the replicas of the matching objects, and gives them
+	// back when the event is over.  This isn't a node failure (the controllers would recreate
+	// those pods elsewhere, and the capacity would be what goes away); the sk-vnode admin API
+	// simulates those.
+	ScaleDown EventType = "scaleDown"
+
+	// An Eviction deletes Fraction of the matching objects (and so all of their pods), and
+	// recreates them DurationSeconds later
+	Eviction EventType = "eviction"
+)
+
+// The pods from evicted objects are gone for at least a second, so that they're actually
+// deleted before they're recreated
+const defaultEvictionSeconds = 1
+
+// A Scenario is a list of chaos events to splice into a trace; the events are applied in
+// order, so later events act on the objects as the earlier events left them.  The Seed picks
+// which objects get evicted.
+type Scenario struct {
+	Seed   int64   `json:"seed,omitempty"`
+	Events []Event `json:"events"`
+}
+
+// An Event starts AtSeconds after the start of the trace, and lasts for DurationSeconds (0
+// means until the end of the trace, except for evictions); it only affects the objects in
+// Namespaces (or in every namespace, if it's empty) that match Selector.
+type Event struct {
+	Type            EventType             `json:"type"`
+	AtSeconds       int64                 `json:"atSeconds"`
+	DurationSeconds int64                 `json:"durationSeconds,omitempty"`
+	Factor          float64               `json:"factor,omitempty"`
+	Fraction        float64               `json:"fraction,omitempty"`
+	Namespaces      []string              `json:"namespaces,omitempty"`
+	Selector        *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+func LoadScenario(scenarioFile string) (*Scenario, error) {
+	scenarioBytes, err := os.ReadFile(scenarioFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", scenarioFile, err)
+	}
+
+	var scenario Scenario
+	if err = yaml.UnmarshalStrict(scenarioBytes, &scenario); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", scenarioFile, err)
+	}
+	if err = scenario.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", scenarioFile, err)
+	}
+	return &scenario, nil
+}
+
+// Validate returns every problem with the scenario, joined together
+func (self *Scenario) Validate() error {
+	var errs []error
+	if len(self.Events) == 0 {
+		errs = append(errs, errors.New("no events"))
+	}
+	for i := range self.Events {
+		if err := self.Events[i].validate(); err != nil {
+			errs = append(errs, fmt.Errorf("event %d (%s): %w", i, self.Events[i].Type, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (self *Event) validate() error {
+	var errs []error
+	if self.AtSeconds < 0 {
+		errs = append(errs, fmt.Errorf("events can't start before the trace: %d", self.AtSeconds))
+	}
+	if self.DurationSeconds < 0 {
+		errs = append(errs, fmt.Errorf("duration can't be negative: %d", self.DurationSeconds))
+	}
+	if _, err := self.selector(); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch self.Type {
+	case Burst:
+		if self.Factor <= 0 {
+			errs = append(errs, fmt.Errorf("factor must be positive: %v", self.Factor))
+		}
+	case ScaleDown, Eviction:
+		if self.Fraction <= 0 || self.Fraction > 1 {
+			errs = append(errs, fmt.Errorf("fraction must be between 0 and 1: %v", self.Fraction))
+		}
+	default:
+		errs = append(errs, fmt.Errorf(
+			"unknown event type %q (must be one of %s, %s, %s)",
+			self.Type,
+			Burst,
+			ScaleDown,
+			Eviction,
+		))
+	}
+	return errors.Join(errs...)
+}
+
+func (self *Event) selector() (labels.Selector, error) {
+	if self.Selector == nil {
+		return labels.Everything(), nil
+	}
+	sel, err := metav1.LabelSelectorAsSelector(self.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector: %w", err)
+	}
+	return sel, nil
+}
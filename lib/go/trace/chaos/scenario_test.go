@@ -0,0 +1,115 @@
+package chaos
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLoadScenario(t *testing.T) {
+	scenarioFile := filepath.Join(t.TempDir(), "scenario.yml")
+	require.Nil(t, os.WriteFile(scenarioFile, []byte(`
+seed: 5
+events:
+  - type: burst
+    atSeconds: 60
+    durationSeconds: 120
+    factor: 3
+    selector:
+      matchLabels:
+        app: web
+  - type: eviction
+    atSeconds: 300
+    fraction: 0.1
+    namespaces: [jobs]
+`), 0600))
+
+	scenario, err := LoadScenario(scenarioFile)
+	require.Nil(t, err)
+	assert.Equal(t, &Scenario{
+		Seed: 5,
+		Events: []Event{
+			{
+				Type:            Burst,
+				AtSeconds:       60,
+				DurationSeconds: 120,
+				Factor:          3,
+				Selector:        &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			},
+			{Type: Eviction, AtSeconds: 300, Fraction: 0.1, Namespaces: []string{"jobs"}},
+		},
+	}, scenario)
+}
+
+func TestLoadScenarioErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		contents      string
+		expectedError string
+	}{
+		"unknown field": {
+			contents:      "event: []\n",
+			expectedError: "could not parse",
+		},
+		"invalid scenario": {
+			contents:      "events: []\n",
+			expectedError: "no events",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			scenarioFile := filepath.Join(t.TempDir(), "scenario.yml")
+			require.Nil(t, os.WriteFile(scenarioFile, []byte(tc.contents), 0600))
+			_, err := LoadScenario(scenarioFile)
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestScenarioValidate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		evt           Event
+		expectedError string
+	}{
+		"negative start": {
+			evt:           Event{Type: Burst, AtSeconds: -1, Factor: 2},
+			expectedError: "event 0 (burst): events can't start before the trace: -1",
+		},
+		"negative duration": {
+			evt:           Event{Type: Burst, DurationSeconds: -1, Factor: 2},
+			expectedError: "duration can't be negative: -1",
+		},
+		"no factor": {
+			evt:           Event{Type: Burst},
+			expectedError: "factor must be positive: 0",
+		},
+		"fraction too big": {
+			evt:           Event{Type: ScaleDown, Fraction: 1.5},
+			expectedError: "fraction must be between 0 and 1: 1.5",
+		},
+		"invalid selector": {
+			evt: Event{
+				Type:     Eviction,
+				Fraction: 1,
+				Selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "app", Operator: "Bogus"},
+				}},
+			},
+			expectedError: "invalid selector",
+		},
+		"unknown type": {
+			evt:           Event{Type: "meteor"},
+			expectedError: `unknown event type "meteor"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			scenario := Scenario{Events: []Event{tc.evt}}
+			assert.ErrorContains(t, scenario.Validate(), tc.expectedError)
+		})
+	}
+
+	scenario := Scenario{Events: []Event{{Type: ScaleDown, Fraction: 1}}}
+	assert.Nil(t, scenario.Validate())
+}
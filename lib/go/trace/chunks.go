@@ -0,0 +1,64 @@
+package trace
+
+import (
+	"errors"
+	"fmt"
+)
+
+// JoinChunks reassembles a trace that was exported in consecutive time slices (see `skctl
+// export --chunk-size`) into the trace that a single export of the whole time window would
+// have returned.  sk-tracer starts every export with an event at the start of the slice
+// that has all of the objects that existed at that time, so the chunks are joined like this:
+//
+//   - the first chunk's starting event is kept, and the other chunks' starting events are
+//     dropped, since the objects in them are already in the earlier chunks
+//   - the index of every chunk includes all of the objects up to the end of the chunk, so the
+//     last chunk's index is used
+//   - pods are included in every slice that they overlap, so each pod's lifecycle data is
+//     only taken from the first chunk that it overlaps
+func JoinChunks(chunks []*Trace) (*Trace, error) {
+	if len(chunks) == 0 {
+		return nil, errors.New("no chunks to join")
+	}
+
+	joined := &Trace{
+		Version:       CurrentTraceFormat,
+		Config:        chunks[0].Config,
+		Index:         chunks[len(chunks)-1].Index,
+		PodLifecycles: map[string]map[uint64][]PodLifecycleData{},
+	}
+
+	var startTs, lastTs int64
+	for i, chunk := range chunks {
+		if len(chunk.Events) == 0 {
+			return nil, fmt.Errorf("chunk %d has no starting event", i)
+		}
+
+		chunkStartTs := chunk.Events[0].Ts
+		events := chunk.Events
+		if i == 0 {
+			startTs = chunkStartTs
+		} else if chunkStartTs < lastTs {
+			return nil, fmt.Errorf("chunk %d starts at %d, before the end of the previous chunk", i, chunkStartTs)
+		} else {
+			events = events[1:]
+		}
+		joined.Events = append(joined.Events, events...)
+		lastTs = joined.Events[len(joined.Events)-1].Ts
+
+		for owner, lifecycles := range chunk.PodLifecycles {
+			for hash, pods := range lifecycles {
+				for _, data := range pods {
+					if i > 0 && data.overlaps(startTs, chunkStartTs) {
+						continue
+					}
+					if joined.PodLifecycles[owner] == nil {
+						joined.PodLifecycles[owner] = map[uint64][]PodLifecycleData{}
+					}
+					joined.PodLifecycles[owner][hash] = append(joined.PodLifecycles[owner][hash], data)
+				}
+			}
+		}
+	}
+	return joined, nil
+}
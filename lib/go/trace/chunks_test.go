@@ -0,0 +1,75 @@
+package trace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// testChunks are what sk-tracer would return for the time slices [0, 10) and [10, 20)
+func testChunks() []*Trace {
+	finished := PodLifecycleData{StartTs: 2, EndTs: 12}
+	running := PodLifecycleData{StartTs: 8}
+	other := testDeploymentObj(1)
+	other.SetName("other")
+
+	return []*Trace{
+		{
+			Version: CurrentTraceFormat,
+			Config:  DefaultTracerConfig(),
+			Events: []Event{
+				{Ts: 0, AppliedObjs: []*unstructured.Unstructured{testDeploymentObj(1)}},
+				{Ts: 5, AppliedObjs: []*unstructured.Unstructured{other}},
+			},
+			Index:         map[string]uint64{"default/nginx": 1, "default/other": 2},
+			PodLifecycles: map[string]map[uint64][]PodLifecycleData{"default/nginx": {1234: {finished, running}}},
+		},
+		{
+			Version: CurrentTraceFormat,
+			Config:  DefaultTracerConfig(),
+			Events: []Event{
+				{Ts: 10, AppliedObjs: []*unstructured.Unstructured{testDeploymentObj(1), other}},
+				{Ts: 15, DeletedObjs: []*unstructured.Unstructured{other}},
+			},
+			Index: map[string]uint64{"default/nginx": 1, "default/other": 2},
+			PodLifecycles: map[string]map[uint64][]PodLifecycleData{
+				"default/nginx": {1234: {finished, running, {StartTs: 11, EndTs: 13}}},
+			},
+		},
+	}
+}
+
+func TestJoinChunks(t *testing.T) {
+	chunks := testChunks()
+	joined, err := JoinChunks(chunks)
+	require.Nil(t, err)
+
+	assert.Equal(t, []Event{chunks[0].Events[0], chunks[0].Events[1], chunks[1].Events[1]}, joined.Events)
+	assert.Equal(t, chunks[1].Index, joined.Index)
+	assert.Equal(t, map[string]map[uint64][]PodLifecycleData{
+		"default/nginx": {1234: {{StartTs: 2, EndTs: 12}, {StartTs: 8}, {StartTs: 11, EndTs: 13}}},
+	}, joined.PodLifecycles)
+}
+
+func TestJoinChunksOneChunk(t *testing.T) {
+	chunks := testChunks()
+	joined, err := JoinChunks(chunks[:1])
+	require.Nil(t, err)
+	assert.Equal(t, chunks[0], joined)
+}
+
+func TestJoinChunksErrors(t *testing.T) {
+	_, err := JoinChunks(nil)
+	assert.Error(t, err)
+
+	chunks := testChunks()
+	chunks[1].Events[0].Ts = 3
+	_, err = JoinChunks(chunks)
+	assert.ErrorContains(t, err, "before the end of the previous chunk")
+
+	chunks[1].Events = nil
+	_, err = JoinChunks(chunks)
+	assert.ErrorContains(t, err, "no starting event")
+}
@@ -0,0 +1,151 @@
+package compact
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"simkube/lib/go/trace"
+)
+
+// Options control how much of the trace's fidelity is given up for a smaller trace.  The zero
+// value only drops the updates that the tracer wouldn't have recorded either, i.e., the ones
+// that don't change an object's spec (or its recorded status; see trace.ChangeHash).
+type Options struct {
+	// Updates to an object that are followed by another change to the same object less than
+	// CoalesceWindow later are dropped, so only the last of a burst of updates gets replayed
+	// (0 turns this off).  Objects are still created and deleted when they were in the trace.
+	CoalesceWindow time.Duration
+
+	// By default, updates that only change an object's labels or annotations are dropped
+	// along with the status-only updates; KeepMetadataUpdates keeps them.
+	KeepMetadataUpdates bool
+
+	// StripStatus removes the status from every object; the driver doesn't use the status
+	// (the simulated controllers fill it in), so it's just taking up space.  The status fields
+	// whose history is recorded (see trace.RecordedStatusField) are kept.
+	StripStatus bool
+}
+
+// Stats counts what was taken out of the trace
+type Stats struct {
+	NoopUpdates      int
+	CoalescedUpdates int
+	EmptyEvents      int
+}
+
+func (self Stats) String() string {
+	return fmt.Sprintf(
+		"dropped %d no-op updates, coalesced %d updates, removed %d empty events",
+		self.NoopUpdates,
+		self.CoalescedUpdates,
+		self.EmptyEvents,
+	)
+}
+
+type objRef struct {
+	evt, obj int
+}
+
+// Trace returns a compacted copy of the trace.  None of the updates that are dropped change
+// the last state of any object, so the index and the pod lifecycle data are the same as
+// before; the first and last events are always kept (even if they're empty), so that the
+// compacted trace covers the same time range.
+func Trace(tr *trace.Trace, opts Options) (*trace.Trace, Stats) {
+	compacted := &trace.Trace{
+		Version:       tr.Version,
+		Config:        tr.Config,
+		Index:         tr.Index,
+		PodLifecycles: tr.PodLifecycles,
+	}
+
+	nextChange := nextChangeTs(tr.Events)
+	live := map[string]*unstructured.Unstructured{}
+	var stats Stats
+	for i, evt := range tr.Events {
+		newEvt := trace.Event{Ts: evt.Ts}
+		for j, obj := range evt.AppliedObjs {
+			key := nsName(obj)
+			if prev, ok := live[key]; ok {
+				next, changesAgain := nextChange[objRef{i, j}]
+				if changesAgain && time.Duration(next-evt.Ts)*time.Second < opts.CoalesceWindow {
+					stats.CoalescedUpdates++
+					continue
+				}
+				if sameObj(prev, obj, opts) {
+					stats.NoopUpdates++
+					continue
+				}
+			}
+			live[key] = obj
+			newEvt.AppliedObjs = append(newEvt.AppliedObjs, stripped(obj, opts))
+		}
+		for _, obj := range evt.DeletedObjs {
+			delete(live, nsName(obj))
+			newEvt.DeletedObjs = append(newEvt.DeletedObjs, stripped(obj, opts))
+		}
+
+		empty := len(newEvt.AppliedObjs) == 0 && len(newEvt.DeletedObjs) == 0
+		if empty && i != 0 && i != len(tr.Events)-1 {
+			stats.EmptyEvents++
+			continue
+		}
+		compacted.Events = append(compacted.Events, newEvt)
+	}
+	return compacted, stats
+}
+
+// nextChangeTs returns the timestamp of the next time each applied object gets applied or
+// deleted again (if it does)
+func nextChangeTs(events []trace.Event) map[objRef]int64 {
+	next := map[objRef]int64{}
+	lastSeen := map[string]int64{}
+	for i := len(events) - 1; i >= 0; i-- {
+		evt := events[i]
+		for _, obj := range evt.DeletedObjs {
+			lastSeen[nsName(obj)] = evt.Ts
+		}
+		for j := len(evt.AppliedObjs) - 1; j >= 0; j-- {
+			key := nsName(evt.AppliedObjs[j])
+			if ts, ok := lastSeen[key]; ok {
+				next[objRef{i, j}] = ts
+			}
+			lastSeen[key] = evt.Ts
+		}
+	}
+	return next
+}
+
+func sameObj(prev, obj *unstructured.Unstructured, opts Options) bool {
+	if trace.ChangeHash(prev) != trace.ChangeHash(obj) {
+		return false
+	}
+	return !opts.KeepMetadataUpdates ||
+		(reflect.DeepEqual(prev.GetLabels(), obj.GetLabels()) &&
+			reflect.DeepEqual(prev.GetAnnotations(), obj.GetAnnotations()))
+}
+
+func stripped(obj *unstructured.Unstructured, opts Options) *unstructured.Unstructured {
+	if _, ok := obj.Object["status"]; !opts.StripStatus || !ok {
+		return obj
+	}
+
+	obj = obj.DeepCopy()
+	statusField := trace.RecordedStatusField(obj)
+	recorded, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "status", statusField)
+	unstructured.RemoveNestedField(obj.Object, "status")
+	if statusField != "" && found {
+		obj.Object["status"] = map[string]interface{}{statusField: recorded}
+	}
+	return obj
+}
+
+// Cluster-scoped objects are just identified by their name in the trace
+func nsName(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return fmt.Sprintf("%s/%s", obj.GetNamespace(), obj.GetName())
+}
@@ -0,0 +1,190 @@
+package compact
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"simkube/lib/go/trace"
+)
+
+const testStartTs = 1000
+
+func testDeployment(name string, replicas int64, ready int64) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"namespace": "default",
+			"name":      name,
+			"labels":    map[string]interface{}{"app": name},
+		},
+		"spec":   map[string]interface{}{"replicas": replicas},
+		"status": map[string]interface{}{"readyReplicas": ready},
+	}}
+}
+
+func withLabel(obj *unstructured.Unstructured, key, value string) *unstructured.Unstructured {
+	labels := obj.GetLabels()
+	labels[key] = value
+	obj.SetLabels(labels)
+	return obj
+}
+
+// testTrace creates web and db, and then updates web a bunch of times: a status-only update,
+// a label change, and two quick scale-ups; db gets deleted right after a status-only update
+func testTrace() *trace.Trace {
+	return &trace.Trace{
+		Version: 2,
+		Config:  trace.DefaultTracerConfig(),
+		Events: []trace.Event{
+			{
+				Ts: testStartTs,
+				AppliedObjs: []*unstructured.Unstructured{
+					testDeployment("web", 2, 0),
+					testDeployment("db", 1, 0),
+				},
+			},
+			{Ts: testStartTs + 10, AppliedObjs: []*unstructured.Unstructured{testDeployment("web", 2, 2)}},
+			{
+				Ts:          testStartTs + 20,
+				AppliedObjs: []*unstructured.Unstructured{withLabel(testDeployment("web", 2, 2), "tier", "frontend")},
+			},
+			{Ts: testStartTs + 30, AppliedObjs: []*unstructured.Unstructured{testDeployment("web", 3, 2)}},
+			{Ts: testStartTs + 32, AppliedObjs: []*unstructured.Unstructured{testDeployment("web", 4, 3)}},
+			{Ts: testStartTs + 40, AppliedObjs: []*unstructured.Unstructured{testDeployment("db", 1, 1)}},
+			{Ts: testStartTs + 41, DeletedObjs: []*unstructured.Unstructured{testDeployment("db", 1, 1)}},
+			{Ts: testStartTs + 100},
+		},
+		Index: map[string]uint64{"default/web": trace.SpecHash(testDeployment("web", 4, 3))},
+	}
+}
+
+type expectedEvent struct {
+	ts      int64
+	applied map[string]int64
+	deleted []string
+}
+
+func assertEvents(t *testing.T, expected []expectedEvent, tr *trace.Trace) {
+	require.Len(t, tr.Events, len(expected))
+	for i, e := range expected {
+		assert.Equal(t, e.ts, tr.Events[i].Ts)
+
+		var applied map[string]int64
+		for _, obj := range tr.Events[i].AppliedObjs {
+			if applied == nil {
+				applied = map[string]int64{}
+			}
+			applied[nsName(obj)], _, _ = unstructured.NestedInt64(obj.Object, "spec", "replicas")
+		}
+		var deleted []string
+		for _, obj := range tr.Events[i].DeletedObjs {
+			deleted = append(deleted, nsName(obj))
+		}
+		assert.Equal(t, e.applied, applied, "event %d", i)
+		assert.Equal(t, e.deleted, deleted, "event %d", i)
+	}
+}
+
+func TestTraceDefaults(t *testing.T) {
+	tr := testTrace()
+	compacted, stats := Trace(tr, Options{})
+
+	assertEvents(t, []expectedEvent{
+		{ts: testStartTs, applied: map[string]int64{"default/web": 2, "default/db": 1}},
+		{ts: testStartTs + 30, applied: map[string]int64{"default/web": 3}},
+		{ts: testStartTs + 32, applied: map[string]int64{"default/web": 4}},
+		{ts: testStartTs + 41, deleted: []string{"default/db"}},
+		{ts: testStartTs + 100},
+	}, compacted)
+	assert.Equal(t, Stats{NoopUpdates: 3, EmptyEvents: 3}, stats)
+	assert.Equal(t, tr.Index, compacted.Index)
+
+	// The status is left alone by default
+	_, found, _ := unstructured.NestedInt64(compacted.Events[2].AppliedObjs[0].Object, "status", "readyReplicas")
+	assert.True(t, found)
+	assert.Len(t, tr.Events, 8)
+}
+
+func TestTraceKeepMetadataUpdates(t *testing.T) {
+	compacted, stats := Trace(testTrace(), Options{KeepMetadataUpdates: true})
+
+	assertEvents(t, []expectedEvent{
+		{ts: testStartTs, applied: map[string]int64{"default/web": 2, "default/db": 1}},
+		{ts: testStartTs + 20, applied: map[string]int64{"default/web": 2}},
+		{ts: testStartTs + 30, applied: map[string]int64{"default/web": 3}},
+		{ts: testStartTs + 32, applied: map[string]int64{"default/web": 4}},
+		{ts: testStartTs + 41, deleted: []string{"default/db"}},
+		{ts: testStartTs + 100},
+	}, compacted)
+	assert.Equal(t, Stats{NoopUpdates: 2, EmptyEvents: 2}, stats)
+}
+
+func TestTraceCoalesce(t *testing.T) {
+	tr := testTrace()
+	compacted, stats := Trace(tr, Options{CoalesceWindow: 5 * time.Second, StripStatus: true})
+
+	// Web's first scale-up is replaced by the second one, and db's status-only update gets
+	// coalesced into its deletion; the creations are left where they are
+	assertEvents(t, []expectedEvent{
+		{ts: testStartTs, applied: map[string]int64{"default/web": 2, "default/db": 1}},
+		{ts: testStartTs + 32, applied: map[string]int64{"default/web": 4}},
+		{ts: testStartTs + 41, deleted: []string{"default/db"}},
+		{ts: testStartTs + 100},
+	}, compacted)
+	assert.Equal(t, Stats{NoopUpdates: 2, CoalescedUpdates: 2, EmptyEvents: 4}, stats)
+
+	for _, evt := range compacted.Events {
+		for _, obj := range append(evt.AppliedObjs, evt.DeletedObjs...) {
+			assert.NotContains(t, obj.Object, "status")
+		}
+	}
+	assert.Contains(t, tr.Events[4].AppliedObjs[0].Object, "status")
+}
+
+func testHPA(cpu int64) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "autoscaling/v2",
+		"kind":       "HorizontalPodAutoscaler",
+		"metadata":   map[string]interface{}{"namespace": "default", "name": "web"},
+		"spec":       map[string]interface{}{"maxReplicas": int64(10)},
+		"status": map[string]interface{}{
+			"currentReplicas": int64(2),
+			"currentMetrics": []interface{}{map[string]interface{}{
+				"type": "Resource",
+				"resource": map[string]interface{}{
+					"name":    "cpu",
+					"current": map[string]interface{}{"averageUtilization": cpu},
+				},
+			}},
+		},
+	}}
+}
+
+func TestTraceRecordedStatus(t *testing.T) {
+	tr := &trace.Trace{
+		Version: 2,
+		Config:  trace.DefaultTracerConfig(),
+		Events: []trace.Event{
+			{Ts: testStartTs, AppliedObjs: []*unstructured.Unstructured{testHPA(50)}},
+			{Ts: testStartTs + 10, AppliedObjs: []*unstructured.Unstructured{testHPA(50)}},
+			{Ts: testStartTs + 20, AppliedObjs: []*unstructured.Unstructured{testHPA(90)}},
+		},
+	}
+	compacted, stats := Trace(tr, Options{StripStatus: true})
+
+	// The metrics changes are the HPA's history, so they're kept (along with the metrics
+	// themselves), but the rest of the status isn't
+	require.Len(t, compacted.Events, 2)
+	assert.Equal(t, Stats{NoopUpdates: 1, EmptyEvents: 1}, stats)
+	for i, j := range []int{0, 2} {
+		obj := compacted.Events[i].AppliedObjs[0]
+		assert.Equal(t, trace.ChangeHash(tr.Events[j].AppliedObjs[0]), trace.ChangeHash(obj))
+		_, found, _ := unstructured.NestedInt64(obj.Object, "status", "currentReplicas")
+		assert.False(t, found)
+	}
+}
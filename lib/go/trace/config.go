@@ -0,0 +1,113 @@
+package trace
+
+import (
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	simkubev1 "simkube/lib/go/api/v1"
+)
+
+// A TracerConfig is the same config that sk-tracer uses: the objects to record, keyed by
+// their GVK in <group>/<version>.<kind> form (e.g., "apps/v1.Deployment", or "/v1.Node" for
+// objects in the core group).  It's also stored at the start of the trace.
+type TracerConfig struct {
+	TrackedObjects map[string]TrackedObjectConfig `json:"trackedObjects"`
+}
+
+type TrackedObjectConfig struct {
+	// PodSpecTemplatePath is the JSON patch path to the object's pod template (sk-driver uses
+	// it to modify the pods during the simulation), and TrackLifecycle records how long the
+	// object's pods ran for
+	PodSpecTemplatePath string `json:"podSpecTemplatePath"`
+	TrackLifecycle      bool   `json:"trackLifecycle,omitempty"`
+}
+
+func DefaultTracerConfig() *TracerConfig {
+	return &TracerConfig{
+		TrackedObjects: map[string]TrackedObjectConfig{
+			"apps/v1.Deployment": {PodSpecTemplatePath: "/spec/template", TrackLifecycle: true},
+		},
+	}
+}
+
+func LoadTracerConfig(configFile string) (*TracerConfig, error) {
+	configBytes, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", configFile, err)
+	}
+
+	var cfg TracerConfig
+	if err = yaml.UnmarshalStrict(configBytes, &cfg); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", configFile, err)
+	}
+	for key := range cfg.TrackedObjects {
+		if _, err = ParseGVK(key); err != nil {
+			return nil, fmt.Errorf("could not load %s: %w", configFile, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// ParseGVK parses a GVK in <group>/<version>.<kind> form; the kind can't have dots in it,
+// but the group can
+func ParseGVK(key string) (schema.GroupVersionKind, error) {
+	//nolint:wrapcheck // this is just a passthrough
+	return simkubev1.ParseKind(key)
+}
+
+func gvkKey(gvk schema.GroupVersionKind) string {
+	return simkubev1.KindString(gvk)
+}
+
+func (self *TracerConfig) trackLifecycleFor(gvk schema.GroupVersionKind) bool {
+	return self.TrackedObjects[gvkKey(gvk)].TrackLifecycle
+}
+
+func (self *TracerConfig) toMsgpack() map[string]interface{} {
+	trackedObjects := map[string]interface{}{}
+	for key, obj := range self.TrackedObjects {
+		cfg := map[string]interface{}{"podSpecTemplatePath": obj.PodSpecTemplatePath}
+		if obj.TrackLifecycle {
+			cfg["trackLifecycle"] = true
+		}
+		trackedObjects[key] = cfg
+	}
+	return map[string]interface{}{"trackedObjects": trackedObjects}
+}
+
+// tracerConfigFromMsgpack reads the config back out of a trace; sk-tracer may or may not write
+// trackLifecycle when it's false, so it's optional
+func tracerConfigFromMsgpack(v interface{}) (*TracerConfig, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid tracer config: %v", v)
+	}
+	trackedObjects, ok := m["trackedObjects"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid tracked objects: %v", m["trackedObjects"])
+	}
+
+	cfg := &TracerConfig{TrackedObjects: map[string]TrackedObjectConfig{}}
+	for key, obj := range trackedObjects {
+		if _, err := ParseGVK(key); err != nil {
+			return nil, err
+		}
+		objCfg, ok := obj.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid config for %s: %v", key, obj)
+		}
+		path, ok := objCfg["podSpecTemplatePath"].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid podSpecTemplatePath for %s: %v", key, objCfg["podSpecTemplatePath"])
+		}
+		trackLifecycle, ok := objCfg["trackLifecycle"].(bool)
+		if !ok && objCfg["trackLifecycle"] != nil {
+			return nil, fmt.Errorf("invalid trackLifecycle for %s: %v", key, objCfg["trackLifecycle"])
+		}
+		cfg.TrackedObjects[key] = TrackedObjectConfig{PodSpecTemplatePath: path, TrackLifecycle: trackLifecycle}
+	}
+	return cfg, nil
+}
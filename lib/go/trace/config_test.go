@@ -0,0 +1,68 @@
+package trace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+//nolint:gochecknoglobals
+var (
+	deploymentGVK = schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	volcanoJobGVK = schema.GroupVersionKind{Group: "batch.volcano.sh", Version: "v1alpha1", Kind: "Job"}
+)
+
+const testTracerConfig = `---
+trackedObjects:
+  apps/v1.Deployment:
+    podSpecTemplatePath: /spec/template
+    trackLifecycle: true
+  batch.volcano.sh/v1alpha1.Job:
+    podSpecTemplatePath: /spec/tasks/*/template
+`
+
+func TestParseGVK(t *testing.T) {
+	cases := map[string]struct {
+		expected  schema.GroupVersionKind
+		expectErr bool
+	}{
+		"apps/v1.Deployment":            {expected: deploymentGVK},
+		"/v1.Node":                      {expected: schema.GroupVersionKind{Version: "v1", Kind: "Node"}},
+		"batch.volcano.sh/v1alpha1.Job": {expected: volcanoJobGVK},
+		"Deployment":                    {expectErr: true},
+		"apps/v1":                       {expectErr: true},
+		"apps/v1.foo.Deployment":        {expectErr: true},
+		"apps/v1/Deployment":            {expectErr: true},
+	}
+
+	for key, tc := range cases {
+		t.Run(key, func(t *testing.T) {
+			gvk, err := ParseGVK(key)
+			if tc.expectErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expected, gvk)
+				assert.Equal(t, key, gvkKey(gvk))
+			}
+		})
+	}
+}
+
+func TestLoadTracerConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "tracer-config.yml")
+	assert.Nil(t, os.WriteFile(configFile, []byte(testTracerConfig), 0o600))
+
+	cfg, err := LoadTracerConfig(configFile)
+	assert.Nil(t, err)
+	assert.Len(t, cfg.TrackedObjects, 2)
+	assert.True(t, cfg.trackLifecycleFor(deploymentGVK))
+	assert.False(t, cfg.trackLifecycleFor(volcanoJobGVK))
+
+	assert.Nil(t, os.WriteFile(configFile, []byte("trackedObjects:\n  Deployment: {}\n"), 0o600))
+	_, err = LoadTracerConfig(configFile)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,152 @@
+package generate
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"simkube/lib/go/trace"
+)
+
+type objEvent struct {
+	ts      int64
+	obj     *unstructured.Unstructured
+	deleted bool
+}
+
+// Trace generates a trace from the model, starting at startTs (in unix seconds).  Like an
+// exported trace, the first event is at startTs, and the index has every object that was in the
+// trace; there's also an empty event at the end, so that the simulation runs for the full
+// duration.  The trace doesn't have any pod lifecycle data, since the pods' spec hashes depend on
+// the defaults that the API server fills in, so the pods run until their Deployment is deleted.
+func Trace(model *Model, startTs int64) (*trace.Trace, error) {
+	if err := model.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid model: %w", err)
+	}
+
+	//nolint:gosec // this doesn't need to be cryptographically secure
+	rng := rand.New(rand.NewSource(model.Seed))
+	tr := &trace.Trace{
+		Version:       trace.CurrentTraceFormat,
+		Config:        trace.DefaultTracerConfig(),
+		Index:         map[string]uint64{},
+		PodLifecycles: map[string]map[uint64][]trace.PodLifecycleData{},
+	}
+
+	var objEvents []objEvent
+	for i := range model.Workloads {
+		w := &model.Workloads[i]
+		for j, arrival := range arrivals(&w.Arrivals, w.Count, model.DurationSeconds, rng) {
+			obj := deployment(w, j)
+			tr.Index[fmt.Sprintf("%s/%s", obj.GetNamespace(), obj.GetName())] = trace.SpecHash(obj)
+			objEvents = append(objEvents, objEvent{ts: arrival, obj: obj})
+
+			if w.Lifetime != nil {
+				if end := arrival + w.Lifetime.sample(rng); end < model.DurationSeconds {
+					objEvents = append(objEvents, objEvent{ts: end, obj: obj, deleted: true})
+				}
+			}
+		}
+	}
+
+	// The workloads stay in the order they're in in the model, for objects that arrive at the
+	// same time
+	sort.SliceStable(objEvents, func(i, j int) bool { return objEvents[i].ts < objEvents[j].ts })
+	tr.Events = []trace.Event{{Ts: startTs}}
+	for _, oe := range objEvents {
+		evt := &tr.Events[len(tr.Events)-1]
+		if evt.Ts != startTs+oe.ts {
+			tr.Events = append(tr.Events, trace.Event{Ts: startTs + oe.ts})
+			evt = &tr.Events[len(tr.Events)-1]
+		}
+		if oe.deleted {
+			evt.DeletedObjs = append(evt.DeletedObjs, oe.obj)
+		} else {
+			evt.AppliedObjs = append(evt.AppliedObjs, oe.obj)
+		}
+	}
+	if endTs := startTs + model.DurationSeconds; tr.Events[len(tr.Events)-1].Ts < endTs {
+		tr.Events = append(tr.Events, trace.Event{Ts: endTs})
+	}
+	return tr, nil
+}
+
+// arrivals returns when each of the workload's Deployments arrives (in seconds since the start of
+// the trace), up to count of them; the ones that would arrive after the end of the trace are left
+// out
+func arrivals(a *Arrivals, count int, durationSeconds int64, rng *rand.Rand) []int64 {
+	var times []int64
+	next := float64(a.StartSeconds)
+	for len(times) < count && int64(next) < durationSeconds {
+		for i := 0; i < a.burstSize() && len(times) < count; i++ {
+			times = append(times, int64(next))
+		}
+
+		switch {
+		case a.RatePerMinute > 0:
+			next += rng.ExpFloat64() * 60 / a.RatePerMinute
+		case a.IntervalSeconds > 0:
+			next += float64(a.IntervalSeconds)
+		default:
+			// Everything arrives at once
+			for len(times) < count {
+				times = append(times, int64(next))
+			}
+		}
+	}
+	return times
+}
+
+func (self *Distribution) sample(rng *rand.Rand) int64 {
+	var seconds int64
+	switch self.Type {
+	case Constant:
+		seconds = self.Seconds
+	case Uniform:
+		seconds = self.MinSeconds + rng.Int63n(self.MaxSeconds-self.MinSeconds+1)
+	case Exponential:
+		seconds = int64(math.Round(rng.ExpFloat64() * float64(self.MeanSeconds)))
+	}
+
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+func deployment(w *Workload, index int) *unstructured.Unstructured {
+	name := fmt.Sprintf("%s-%d", w.Name, index)
+	labels := func() map[string]interface{} { return map[string]interface{}{"app": name} }
+
+	container := map[string]interface{}{"name": w.Name, "image": w.image()}
+	if len(w.Resources) > 0 {
+		requests := map[string]interface{}{}
+		for res, q := range w.Resources {
+			requests[string(res)] = q.String()
+		}
+		container["resources"] = map[string]interface{}{"requests": requests}
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"namespace": w.namespace(),
+			"name":      name,
+			"labels":    labels(),
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(w.Replicas),
+			"selector": map[string]interface{}{"matchLabels": labels()},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": labels()},
+				"spec": map[string]interface{}{
+					"containers": []interface{}{container},
+				},
+			},
+		},
+	}}
+}
@@ -0,0 +1,192 @@
+package generate
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"simkube/lib/go/trace"
+)
+
+const testStartTs = 1000
+
+func testModel() *Model {
+	return &Model{
+		DurationSeconds: 100,
+		Seed:            42,
+		Workloads: []Workload{
+			{
+				Name:      "web",
+				Count:     3,
+				Replicas:  2,
+				Resources: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+				Arrivals:  Arrivals{StartSeconds: 10, IntervalSeconds: 20},
+				Lifetime:  &Distribution{Type: Constant, Seconds: 50},
+			},
+			{Name: "batch", Namespace: "jobs", Count: 2, Replicas: 1},
+		},
+	}
+}
+
+func eventNames(evt trace.Event) ([]string, []string) {
+	names := func(objs []*unstructured.Unstructured) []string {
+		var res []string
+		for _, obj := range objs {
+			res = append(res, obj.GetNamespace()+"/"+obj.GetName())
+		}
+		return res
+	}
+	return names(evt.AppliedObjs), names(evt.DeletedObjs)
+}
+
+func TestTrace(t *testing.T) {
+	tr, err := Trace(testModel(), testStartTs)
+	require.Nil(t, err)
+
+	expected := []struct {
+		ts      int64
+		applied []string
+		deleted []string
+	}{
+		{ts: testStartTs, applied: []string{"jobs/batch-0", "jobs/batch-1"}},
+		{ts: testStartTs + 10, applied: []string{"default/web-0"}},
+		{ts: testStartTs + 30, applied: []string{"default/web-1"}},
+		{ts: testStartTs + 50, applied: []string{"default/web-2"}},
+		{ts: testStartTs + 60, deleted: []string{"default/web-0"}},
+		{ts: testStartTs + 80, deleted: []string{"default/web-1"}},
+		// web-2 would be deleted at 100, which is after the end of the trace
+		{ts: testStartTs + 100},
+	}
+	require.Len(t, tr.Events, len(expected))
+	for i, e := range expected {
+		applied, deleted := eventNames(tr.Events[i])
+		assert.Equal(t, e.ts, tr.Events[i].Ts)
+		assert.Equal(t, e.applied, applied)
+		assert.Equal(t, e.deleted, deleted)
+	}
+
+	assert.Len(t, tr.Index, 5)
+	web := tr.Events[1].AppliedObjs[0]
+	assert.Equal(t, trace.SpecHash(web), tr.Index["default/web-0"])
+	replicas, _, _ := unstructured.NestedInt64(web.Object, "spec", "replicas")
+	assert.Equal(t, int64(2), replicas)
+	containers, _, _ := unstructured.NestedSlice(web.Object, "spec", "template", "spec", "containers")
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"name":      "web",
+		"image":     "nginx",
+		"resources": map[string]interface{}{"requests": map[string]interface{}{"cpu": "500m"}},
+	}}, containers)
+
+	// The generated trace can be written out and read back in
+	var buf bytes.Buffer
+	require.Nil(t, trace.WriteTrace(&buf, tr))
+	readTr, err := trace.ReadTrace(&buf)
+	require.Nil(t, err)
+	assert.Len(t, readTr.Events, len(expected))
+}
+
+func TestTraceDeterministic(t *testing.T) {
+	model := &Model{
+		DurationSeconds: 3600,
+		Seed:            7,
+		Workloads: []Workload{{
+			Name:     "web",
+			Count:    50,
+			Replicas: 1,
+			Arrivals: Arrivals{RatePerMinute: 2, BurstSize: 3},
+			Lifetime: &Distribution{Type: Exponential, MeanSeconds: 300},
+		}},
+	}
+
+	tr1, err := Trace(model, testStartTs)
+	require.Nil(t, err)
+	tr2, err := Trace(model, testStartTs)
+	require.Nil(t, err)
+	assert.Equal(t, tr1, tr2)
+
+	model.Seed = 8
+	tr3, err := Trace(model, testStartTs)
+	require.Nil(t, err)
+	assert.NotEqual(t, tr1, tr3)
+}
+
+func TestTraceInvalidModel(t *testing.T) {
+	_, err := Trace(&Model{}, testStartTs)
+	assert.ErrorContains(t, err, "invalid model")
+}
+
+func TestArrivals(t *testing.T) {
+	for name, tc := range map[string]struct {
+		arrivals Arrivals
+		count    int
+		expected []int64
+	}{
+		"all at once": {
+			arrivals: Arrivals{StartSeconds: 5},
+			count:    3,
+			expected: []int64{5, 5, 5},
+		},
+		"fixed interval": {
+			arrivals: Arrivals{IntervalSeconds: 10},
+			count:    3,
+			expected: []int64{0, 10, 20},
+		},
+		"bursts": {
+			arrivals: Arrivals{IntervalSeconds: 10, BurstSize: 2},
+			count:    5,
+			expected: []int64{0, 0, 10, 10, 20},
+		},
+		"past the end": {
+			arrivals: Arrivals{StartSeconds: 50, IntervalSeconds: 30},
+			count:    5,
+			expected: []int64{50, 80},
+		},
+		"starts after the end": {
+			arrivals: Arrivals{StartSeconds: 100},
+			count:    5,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			//nolint:gosec // this doesn't need to be cryptographically secure
+			rng := rand.New(rand.NewSource(0))
+			assert.Equal(t, tc.expected, arrivals(&tc.arrivals, tc.count, 100, rng))
+		})
+	}
+}
+
+func TestArrivalsRate(t *testing.T) {
+	//nolint:gosec // this doesn't need to be cryptographically secure
+	rng := rand.New(rand.NewSource(0))
+	times := arrivals(&Arrivals{RatePerMinute: 60}, 1000, 1000000, rng)
+	require.Len(t, times, 1000)
+
+	// About one a second, on average
+	assert.InDelta(t, 1000, times[len(times)-1], 100)
+	for i := 1; i < len(times); i++ {
+		assert.LessOrEqual(t, times[i-1], times[i])
+	}
+}
+
+func TestDistributionSample(t *testing.T) {
+	//nolint:gosec // this doesn't need to be cryptographically secure
+	rng := rand.New(rand.NewSource(0))
+	assert.Equal(t, int64(30), (&Distribution{Type: Constant, Seconds: 30}).sample(rng))
+
+	uniform := &Distribution{Type: Uniform, MinSeconds: 10, MaxSeconds: 20}
+	for i := 0; i < 100; i++ {
+		s := uniform.sample(rng)
+		assert.GreaterOrEqual(t, s, int64(10))
+		assert.LessOrEqual(t, s, int64(20))
+	}
+
+	exponential := &Distribution{Type: Exponential, MeanSeconds: 1}
+	for i := 0; i < 100; i++ {
+		assert.GreaterOrEqual(t, exponential.sample(rng), int64(1))
+	}
+}
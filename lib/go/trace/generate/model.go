@@ -0,0 +1,196 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultNamespace = "default"
+	defaultImage     = "nginx"
+)
+
+// A Model describes the workloads in a synthetic trace: each Workload is a group of identical
+// Deployments, which arrive over the course of the trace and are deleted when their lifetime is
+// up.  The same model and seed always generate the same trace.
+type Model struct {
+	// DurationSeconds is how long the trace is; workloads that would arrive after the end of the
+	// trace are left out, and workloads that are still running at the end are never deleted
+	DurationSeconds int64 `json:"durationSeconds"`
+
+	// Seed seeds the random arrival times and lifetimes
+	Seed int64 `json:"seed,omitempty"`
+
+	Workloads []Workload `json:"workloads"`
+}
+
+// A Workload is Count Deployments named <name>-0, <name>-1, and so on, each with Replicas pods
+// running Image and requesting Resources
+type Workload struct {
+	Name      string              `json:"name"`
+	Namespace string              `json:"namespace,omitempty"`
+	Count     int                 `json:"count"`
+	Replicas  int32               `json:"replicas"`
+	Image     string              `json:"image,omitempty"`
+	Resources corev1.ResourceList `json:"resources,omitempty"`
+
+	Arrivals Arrivals `json:"arrivals,omitempty"`
+
+	// Lifetime is how long each Deployment exists for; if it's not set, the Deployments last
+	// until the end of the trace
+	Lifetime *Distribution `json:"lifetime,omitempty"`
+}
+
+// Arrivals says when the Deployments in a workload are created: they arrive in bursts of
+// BurstSize (1 by default), starting at StartSeconds into the trace.  With RatePerMinute, the
+// time between bursts is random (i.e., a Poisson process), and with IntervalSeconds it's fixed;
+// with neither, all of the Deployments arrive at StartSeconds.
+type Arrivals struct {
+	StartSeconds    int64   `json:"startSeconds,omitempty"`
+	RatePerMinute   float64 `json:"ratePerMinute,omitempty"`
+	IntervalSeconds int64   `json:"intervalSeconds,omitempty"`
+	BurstSize       int     `json:"burstSize,omitempty"`
+}
+
+type DistributionType string
+
+const (
+	Constant    DistributionType = "constant"
+	Uniform     DistributionType = "uniform"
+	Exponential DistributionType = "exponential"
+)
+
+// A Distribution is a random duration, in seconds: a constant one uses Seconds, a uniform one is
+// between MinSeconds and MaxSeconds, and an exponential one has a mean of MeanSeconds.  Samples
+// are always at least one second.
+type Distribution struct {
+	Type        DistributionType `json:"type"`
+	Seconds     int64            `json:"seconds,omitempty"`
+	MinSeconds  int64            `json:"minSeconds,omitempty"`
+	MaxSeconds  int64            `json:"maxSeconds,omitempty"`
+	MeanSeconds int64            `json:"meanSeconds,omitempty"`
+}
+
+func LoadModel(modelFile string) (*Model, error) {
+	modelBytes, err := os.ReadFile(modelFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", modelFile, err)
+	}
+
+	var model Model
+	if err = yaml.UnmarshalStrict(modelBytes, &model); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", modelFile, err)
+	}
+	if err = model.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid model %s: %w", modelFile, err)
+	}
+	return &model, nil
+}
+
+// Validate returns every problem with the model, joined together
+func (self *Model) Validate() error {
+	var errs []error
+	if self.DurationSeconds <= 0 {
+		errs = append(errs, fmt.Errorf("duration must be at least 1 second: %d", self.DurationSeconds))
+	}
+	if len(self.Workloads) == 0 {
+		errs = append(errs, errors.New("no workloads"))
+	}
+
+	names := map[string]bool{}
+	for i := range self.Workloads {
+		w := &self.Workloads[i]
+		key := w.namespace() + "/" + w.Name
+		if names[key] {
+			errs = append(errs, fmt.Errorf("duplicate workload %s", key))
+		}
+		names[key] = true
+
+		if err := w.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("workload %s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (self *Workload) validate() error {
+	var errs []error
+	// The Deployments get a -<index> suffix, so the name has to leave room for it
+	for _, msg := range validation.IsDNS1123Label(fmt.Sprintf("%s-%d", self.Name, self.Count)) {
+		errs = append(errs, fmt.Errorf("invalid name %q: %s", self.Name, msg))
+	}
+	for _, msg := range validation.IsDNS1123Label(self.namespace()) {
+		errs = append(errs, fmt.Errorf("invalid namespace %q: %s", self.namespace(), msg))
+	}
+	if self.Count <= 0 {
+		errs = append(errs, fmt.Errorf("count must be at least 1: %d", self.Count))
+	}
+	if self.Replicas < 0 {
+		errs = append(errs, fmt.Errorf("replicas can't be negative: %d", self.Replicas))
+	}
+
+	a := &self.Arrivals
+	if a.StartSeconds < 0 {
+		errs = append(errs, fmt.Errorf("arrivals can't start before the trace: %d", a.StartSeconds))
+	}
+	if a.RatePerMinute < 0 || a.IntervalSeconds < 0 || a.BurstSize < 0 {
+		errs = append(errs, errors.New("arrival rate, interval, and burst size can't be negative"))
+	} else if a.RatePerMinute > 0 && a.IntervalSeconds > 0 {
+		errs = append(errs, errors.New("only one of arrival rate and interval can be set"))
+	}
+
+	if self.Lifetime != nil {
+		if err := self.Lifetime.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid lifetime: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (self *Distribution) validate() error {
+	switch self.Type {
+	case Constant:
+		if self.Seconds <= 0 {
+			return fmt.Errorf("seconds must be at least 1: %d", self.Seconds)
+		}
+	case Uniform:
+		if self.MinSeconds <= 0 || self.MaxSeconds < self.MinSeconds {
+			return fmt.Errorf("invalid range: %d-%d", self.MinSeconds, self.MaxSeconds)
+		}
+	case Exponential:
+		if self.MeanSeconds <= 0 {
+			return fmt.Errorf("mean must be at least 1 second: %d", self.MeanSeconds)
+		}
+	default:
+		valid := []string{string(Constant), string(Uniform), string(Exponential)}
+		return fmt.Errorf("unknown distribution %q (must be one of %s)", self.Type, strings.Join(valid, ", "))
+	}
+	return nil
+}
+
+func (self *Workload) namespace() string {
+	if self.Namespace == "" {
+		return defaultNamespace
+	}
+	return self.Namespace
+}
+
+func (self *Workload) image() string {
+	if self.Image == "" {
+		return defaultImage
+	}
+	return self.Image
+}
+
+func (self *Arrivals) burstSize() int {
+	if self.BurstSize == 0 {
+		return 1
+	}
+	return self.BurstSize
+}
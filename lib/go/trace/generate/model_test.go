@@ -0,0 +1,127 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestLoadModel(t *testing.T) {
+	modelFile := filepath.Join(t.TempDir(), "model.yml")
+	require.Nil(t, os.WriteFile(modelFile, []byte(`
+durationSeconds: 3600
+seed: 1
+workloads:
+  - name: web
+    count: 10
+    replicas: 3
+    resources:
+      cpu: "1"
+    arrivals:
+      ratePerMinute: 0.5
+      burstSize: 2
+    lifetime:
+      type: uniform
+      minSeconds: 60
+      maxSeconds: 600
+`), 0600))
+
+	model, err := LoadModel(modelFile)
+	require.Nil(t, err)
+	assert.Equal(t, &Model{
+		DurationSeconds: 3600,
+		Seed:            1,
+		Workloads: []Workload{{
+			Name:      "web",
+			Count:     10,
+			Replicas:  3,
+			Resources: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			Arrivals:  Arrivals{RatePerMinute: 0.5, BurstSize: 2},
+			Lifetime:  &Distribution{Type: Uniform, MinSeconds: 60, MaxSeconds: 600},
+		}},
+	}, model)
+}
+
+func TestLoadModelErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		contents      string
+		expectedError string
+	}{
+		"unknown field": {
+			contents:      "durationSeconds: 10\nworkload: []\n",
+			expectedError: "could not parse",
+		},
+		"invalid model": {
+			contents:      "durationSeconds: 10\nworkloads: []\n",
+			expectedError: "no workloads",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			modelFile := filepath.Join(t.TempDir(), "model.yml")
+			require.Nil(t, os.WriteFile(modelFile, []byte(tc.contents), 0600))
+			_, err := LoadModel(modelFile)
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestModelValidate(t *testing.T) {
+	valid := Workload{Name: "web", Count: 1, Replicas: 1}
+	for name, tc := range map[string]struct {
+		model         Model
+		expectedError string
+	}{
+		"no duration": {
+			model:         Model{Workloads: []Workload{valid}},
+			expectedError: "duration must be at least 1 second",
+		},
+		"duplicate workloads": {
+			model:         Model{DurationSeconds: 10, Workloads: []Workload{valid, valid}},
+			expectedError: "duplicate workload default/web",
+		},
+		"invalid name": {
+			model:         Model{DurationSeconds: 10, Workloads: []Workload{{Name: "Web", Count: 1}}},
+			expectedError: `invalid name "Web"`,
+		},
+		"no count": {
+			model:         Model{DurationSeconds: 10, Workloads: []Workload{{Name: "web"}}},
+			expectedError: "count must be at least 1",
+		},
+		"rate and interval": {
+			model: Model{DurationSeconds: 10, Workloads: []Workload{{
+				Name:     "web",
+				Count:    1,
+				Arrivals: Arrivals{RatePerMinute: 1, IntervalSeconds: 10},
+			}}},
+			expectedError: "only one of arrival rate and interval can be set",
+		},
+		"invalid lifetime": {
+			model: Model{DurationSeconds: 10, Workloads: []Workload{{
+				Name:     "web",
+				Count:    1,
+				Lifetime: &Distribution{Type: Uniform, MinSeconds: 10, MaxSeconds: 5},
+			}}},
+			expectedError: "invalid lifetime: invalid range: 10-5",
+		},
+		"unknown distribution": {
+			model: Model{DurationSeconds: 10, Workloads: []Workload{{
+				Name:     "web",
+				Count:    1,
+				Lifetime: &Distribution{Type: "normal"},
+			}}},
+			expectedError: `unknown distribution "normal"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorContains(t, tc.model.Validate(), tc.expectedError)
+		})
+	}
+
+	model := Model{DurationSeconds: 10, Workloads: []Workload{valid}}
+	assert.Nil(t, model.Validate())
+}
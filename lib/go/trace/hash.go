@@ -0,0 +1,164 @@
+package trace
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The pod lifecycle data in a trace is keyed by a hash of the pod spec, which sk-driver
+// recomputes for the pods in the simulation, so we have to hash things exactly the same way
+// that the Rust code does (see lib/rust/jsonutils/hash.rs): that's Rust's DefaultHasher,
+// i.e., SipHash-1-3 with zero keys, over the JSON value with object keys in sorted order.
+
+const kubeAPIAccessVolumePrefix = "kube-api-access-"
+
+func hashJSON(v interface{}) uint64 {
+	return sipHash13(appendJSONHash(nil, v))
+}
+
+// appendJSONHash writes the bytes that Rust's Hash implementations feed into the hasher for
+// each type of JSON value; arrays and objects don't hash their lengths, only their contents.
+func appendJSONHash(buf []byte, v interface{}) []byte {
+	switch val := v.(type) {
+	case nil:
+		// This is the discriminant of Option::None, which is hashed as an isize
+		return binary.LittleEndian.AppendUint64(buf, 0)
+	case bool:
+		if val {
+			return append(buf, 1)
+		}
+		return append(buf, 0)
+	case int64:
+		return binary.LittleEndian.AppendUint64(buf, uint64(val))
+	case int:
+		return binary.LittleEndian.AppendUint64(buf, uint64(val))
+	case uint64:
+		return binary.LittleEndian.AppendUint64(buf, val)
+	case float64:
+		// +0 and -0 hash the same way
+		if val == 0 {
+			val = 0
+		}
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(val))
+	case string:
+		return append(append(buf, val...), 0xff)
+	case []interface{}:
+		for _, item := range val {
+			buf = appendJSONHash(buf, item)
+		}
+		return buf
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			buf = appendJSONHash(append(append(buf, k...), 0xff), val[k])
+		}
+		return buf
+	default:
+		// The unstructured converter only produces the types above
+		return buf
+	}
+}
+
+// StablePodSpecHash hashes the parts of the pod spec that stay the same when the pod is
+// recreated in the simulation; the service account token volume and the node and service
+// account names are removed (see lib/rust/k8s/pod_ext.rs).
+func StablePodSpecHash(pod *corev1.Pod) (uint64, error) {
+	spec, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&pod.Spec)
+	if err != nil {
+		return 0, fmt.Errorf("could not convert pod spec: %w", err)
+	}
+
+	delete(spec, "nodeName")
+	delete(spec, "serviceAccount")
+	delete(spec, "serviceAccountName")
+	spec["volumes"] = withoutKubeAPIAccess(spec["volumes"])
+	for _, field := range []string{"initContainers", "containers"} {
+		containers, ok := spec[field].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, c := range containers {
+			if container, ok := c.(map[string]interface{}); ok {
+				container["volumeMounts"] = withoutKubeAPIAccess(container["volumeMounts"])
+			}
+		}
+	}
+	return hashJSON(spec), nil
+}
+
+// The volumes and volume mounts are always present in the stable spec, even if they're empty
+func withoutKubeAPIAccess(v interface{}) []interface{} {
+	filtered := []interface{}{}
+	items, ok := v.([]interface{})
+	if !ok {
+		return filtered
+	}
+
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			if name, ok := m["name"].(string); ok && strings.HasPrefix(name, kubeAPIAccessVolumePrefix) {
+				continue
+			}
+		}
+		filtered = append(filtered, item)
+	}
+	return filtered
+}
+
+func sipHash13(msg []byte) uint64 {
+	// The keys are zero, so the initial state is just the constants
+	v0 := uint64(0x736f6d6570736575)
+	v1 := uint64(0x646f72616e646f6d)
+	v2 := uint64(0x6c7967656e657261)
+	v3 := uint64(0x7465646279746573)
+
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+
+	n := len(msg)
+	for ; len(msg) >= 8; msg = msg[8:] {
+		m := binary.LittleEndian.Uint64(msg)
+		v3 ^= m
+		round()
+		v0 ^= m
+	}
+
+	b := uint64(n) << 56
+	for i, c := range msg {
+		b |= uint64(c) << (8 * i)
+	}
+	v3 ^= b
+	round()
+	v0 ^= b
+
+	v2 ^= 0xff
+	round()
+	round()
+	round()
+	return v0 ^ v1 ^ v2 ^ v3
+}
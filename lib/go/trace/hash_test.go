@@ -0,0 +1,85 @@
+package trace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+const testDeploymentSpec = `{
+	"replicas": 3,
+	"selector": {"matchLabels": {"app": "nginx"}},
+	"template": {
+		"metadata": {"labels": {"app": "nginx"}},
+		"spec": {
+			"containers": [{
+				"image": "nginx:1.14.2",
+				"name": "nginx",
+				"ports": [{"containerPort": 80}],
+				"resources": {}
+			}],
+			"volumes": []
+		}
+	}
+}`
+
+func TestHashJSON(t *testing.T) {
+	// These are the hashes computed by lib/rust/jsonutils for the same values
+	cases := map[string]uint64{
+		`null`:                              13646096770106105413,
+		`true`:                              4952851536318644461,
+		`false`:                             7541581120933061747,
+		`0`:                                 13646096770106105413,
+		`-1`:                                3395815149532668813,
+		`42`:                                8880661182590738257,
+		`1.5`:                               14071338911868686178,
+		`"foo"`:                             4506850079084802999,
+		`""`:                                3476900567878811119,
+		`[]`:                                15130871412783076140,
+		`{}`:                                15130871412783076140,
+		`[1, "a", null]`:                    6769803031325424219,
+		`{"b": 1, "a": [true, {"z": "y"}]}`: 7757380870566558586,
+		testDeploymentSpec:                  12691637882283442614,
+	}
+
+	for input, expected := range cases {
+		t.Run(input, func(t *testing.T) {
+			var v interface{}
+			assert.Nil(t, json.Unmarshal([]byte(input), &v))
+			assert.Equal(t, expected, hashJSON(v))
+		})
+	}
+}
+
+func TestStablePodSpecHash(t *testing.T) {
+	spec := corev1.PodSpec{
+		Containers: []corev1.Container{{
+			Name:  "nginx",
+			Image: "nginx:1.14.2",
+			Ports: []corev1.ContainerPort{{ContainerPort: 80}},
+		}},
+	}
+	pod := &corev1.Pod{Spec: spec}
+	expected, err := StablePodSpecHash(pod)
+	assert.Nil(t, err)
+
+	// Scheduling the pod and mounting the service account token don't change the hash
+	scheduled := &corev1.Pod{Spec: *spec.DeepCopy()}
+	scheduled.Spec.NodeName = "node-1"
+	scheduled.Spec.ServiceAccountName = "default"
+	scheduled.Spec.Volumes = []corev1.Volume{{Name: "kube-api-access-abcde"}}
+	scheduled.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{
+		{Name: "kube-api-access-abcde", MountPath: "/var/run/secrets/kubernetes.io/serviceaccount"},
+	}
+	hash, err := StablePodSpecHash(scheduled)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, hash)
+
+	changed := &corev1.Pod{Spec: *spec.DeepCopy()}
+	changed.Spec.Containers[0].Image = "nginx:1.15"
+	hash, err = StablePodSpecHash(changed)
+	assert.Nil(t, err)
+	assert.NotEqual(t, expected, hash)
+}
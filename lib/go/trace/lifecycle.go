@@ -0,0 +1,150 @@
+package trace
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PodLifecycleData is how long a pod ran for, as unix timestamps: a pod that hasn't started
+// yet is empty, a running pod only has a start time, and a finished pod has both.  Like
+// sk-tracer, we use the earliest container start time and the latest container end time,
+// instead of the pod's creation time, so that time spent pending isn't counted.
+type PodLifecycleData struct {
+	StartTs int64
+	EndTs   int64
+}
+
+func (self PodLifecycleData) Empty() bool {
+	return self.StartTs == 0
+}
+
+func (self PodLifecycleData) Running() bool {
+	return self.StartTs != 0 && self.EndTs == 0
+}
+
+func (self PodLifecycleData) Finished() bool {
+	return self.StartTs != 0 && self.EndTs != 0
+}
+
+// A pod is only finished if all of its containers have terminated; init containers always
+// terminate before the main containers start, so they only count towards the start time.
+func newPodLifecycleData(pod *corev1.Pod) PodLifecycleData {
+	var data PodLifecycleData
+	terminated := 0
+	for i, statuses := range [][]corev1.ContainerStatus{
+		pod.Status.InitContainerStatuses,
+		pod.Status.ContainerStatuses,
+	} {
+		for _, status := range statuses {
+			start, end := containerStartEndTs(status.State)
+			if start != 0 && (data.StartTs == 0 || start < data.StartTs) {
+				data.StartTs = start
+			}
+			if end > data.EndTs {
+				data.EndTs = end
+			}
+			if i == 1 && end != 0 {
+				terminated++
+			}
+		}
+	}
+
+	if data.StartTs == 0 || terminated != len(pod.Spec.Containers) {
+		data.EndTs = 0
+	}
+	return data
+}
+
+func containerStartEndTs(state corev1.ContainerState) (int64, int64) {
+	if state.Running != nil && !state.Running.StartedAt.IsZero() {
+		return state.Running.StartedAt.Unix(), 0
+	} else if state.Terminated != nil && !state.Terminated.StartedAt.IsZero() {
+		if state.Terminated.FinishedAt.IsZero() {
+			return state.Terminated.StartedAt.Unix(), 0
+		}
+		return state.Terminated.StartedAt.Unix(), state.Terminated.FinishedAt.Unix()
+	}
+	return 0, 0
+}
+
+// supersedes is true if the lifecycle data can replace the old data: empty data can be
+// replaced by anything, and running data can only be replaced by finished data with the
+// same start time.  This way, pods that restart (e.g., in CrashLoopBackoff) keep the
+// earliest start time that we saw.
+func (self PodLifecycleData) supersedes(old PodLifecycleData) bool {
+	switch {
+	case self.Empty():
+		return false
+	case old.Empty():
+		return true
+	default:
+		return self.Finished() && old.Running() && self.StartTs == old.StartTs
+	}
+}
+
+// If a pod is deleted before all of its containers terminate, we don't know when it stopped
+// running, so we use the current time
+func guessFinishedLifecycle(pod *corev1.Pod, current PodLifecycleData, now int64) PodLifecycleData {
+	data := newPodLifecycleData(pod)
+	switch {
+	case data.Finished():
+		return data
+	case data.Running():
+		return PodLifecycleData{StartTs: data.StartTs, EndTs: now}
+	case !current.Empty():
+		return PodLifecycleData{StartTs: current.StartTs, EndTs: now}
+	case !pod.ObjectMeta.CreationTimestamp.IsZero():
+		return PodLifecycleData{StartTs: pod.ObjectMeta.CreationTimestamp.Unix(), EndTs: now}
+	default:
+		return PodLifecycleData{}
+	}
+}
+
+// overlaps is true if the pod started or finished in the time window, or if it's still
+// running at the end of the window
+func (self PodLifecycleData) overlaps(startTs, endTs int64) bool {
+	switch {
+	case self.Running():
+		return self.StartTs < endTs
+	case self.Finished():
+		return (startTs <= self.StartTs && self.StartTs < endTs) || (startTs <= self.EndTs && self.EndTs < endTs)
+	default:
+		return false
+	}
+}
+
+// The lifecycle data is a Rust enum in the trace, which rmp_serde writes as the name of the
+// variant, or as a map from the name of the variant to its fields
+func (self PodLifecycleData) toMsgpack() interface{} {
+	switch {
+	case self.Running():
+		return map[string]interface{}{"Running": self.StartTs}
+	case self.Finished():
+		return map[string]interface{}{"Finished": []interface{}{self.StartTs, self.EndTs}}
+	default:
+		return "Empty"
+	}
+}
+
+func podLifecycleFromMsgpack(v interface{}) (PodLifecycleData, error) {
+	if v == "Empty" {
+		return PodLifecycleData{}, nil
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return PodLifecycleData{}, fmt.Errorf("invalid pod lifecycle data: %v", v)
+	}
+	if start, ok := m["Running"].(int64); ok && start != 0 {
+		return PodLifecycleData{StartTs: start}, nil
+	}
+	if ts, ok := m["Finished"].([]interface{}); ok && len(ts) == 2 {
+		start, startOk := ts[0].(int64)
+		end, endOk := ts[1].(int64)
+		if startOk && endOk && start != 0 && end != 0 {
+			return PodLifecycleData{StartTs: start, EndTs: end}, nil
+		}
+	}
+	return PodLifecycleData{}, fmt.Errorf("invalid pod lifecycle data: %v", v)
+}
@@ -0,0 +1,176 @@
+package trace
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	testStartTs int64 = 1000
+	testEndTs   int64 = 2000
+)
+
+func running(ts int64) corev1.ContainerState {
+	return corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: metav1.Unix(ts, 0)}}
+}
+
+func terminated(start, end int64) corev1.ContainerState {
+	return corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+		StartedAt:  metav1.Unix(start, 0),
+		FinishedAt: metav1.Unix(end, 0),
+	}}
+}
+
+func testPod(initStates []corev1.ContainerState, states ...corev1.ContainerState) *corev1.Pod {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-pod"}}
+	for _, state := range initStates {
+		pod.Status.InitContainerStatuses = append(
+			pod.Status.InitContainerStatuses,
+			corev1.ContainerStatus{State: state},
+		)
+	}
+	for _, state := range states {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "nginx", Image: "nginx"})
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{State: state})
+	}
+	return pod
+}
+
+func TestNewPodLifecycleData(t *testing.T) {
+	waiting := corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{}}
+	cases := map[string]struct {
+		pod      *corev1.Pod
+		expected PodLifecycleData
+	}{
+		"pending": {
+			pod: testPod(nil, waiting),
+		},
+		"running": {
+			pod:      testPod(nil, running(testStartTs+5), running(testStartTs)),
+			expected: PodLifecycleData{StartTs: testStartTs},
+		},
+		"some containers terminated": {
+			pod:      testPod(nil, running(testStartTs), terminated(testStartTs, testEndTs)),
+			expected: PodLifecycleData{StartTs: testStartTs},
+		},
+		"finished": {
+			pod:      testPod(nil, terminated(testStartTs+5, testEndTs-5), terminated(testStartTs, testEndTs)),
+			expected: PodLifecycleData{StartTs: testStartTs, EndTs: testEndTs},
+		},
+		"init containers": {
+			pod: testPod(
+				[]corev1.ContainerState{terminated(testStartTs, testStartTs+5)},
+				running(testStartTs+10),
+			),
+			expected: PodLifecycleData{StartTs: testStartTs},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, newPodLifecycleData(tc.pod))
+		})
+	}
+}
+
+func TestPodLifecycleDataSupersedes(t *testing.T) {
+	empty := PodLifecycleData{}
+	runningData := PodLifecycleData{StartTs: testStartTs}
+	finishedData := PodLifecycleData{StartTs: testStartTs, EndTs: testEndTs}
+
+	cases := map[string]struct {
+		data     PodLifecycleData
+		old      PodLifecycleData
+		expected bool
+	}{
+		"empty":                 {data: empty, old: empty},
+		"running over empty":    {data: runningData, old: empty, expected: true},
+		"finished over empty":   {data: finishedData, old: empty, expected: true},
+		"finished over running": {data: finishedData, old: runningData, expected: true},
+		"running over running":  {data: runningData, old: runningData},
+		"restarted":             {data: PodLifecycleData{StartTs: testStartTs + 5}, old: runningData},
+		"different start time": {
+			data: PodLifecycleData{StartTs: testStartTs + 5, EndTs: testEndTs},
+			old:  runningData,
+		},
+		"running over finished":  {data: runningData, old: finishedData},
+		"finished over finished": {data: finishedData, old: finishedData},
+		"different finished values": {
+			data: PodLifecycleData{StartTs: testStartTs, EndTs: testEndTs + 5},
+			old:  finishedData,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.data.supersedes(tc.old))
+		})
+	}
+}
+
+func TestGuessFinishedLifecycle(t *testing.T) {
+	now := testEndTs + 100
+	created := testPod(nil, corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{}})
+	created.ObjectMeta.CreationTimestamp = metav1.NewTime(time.Unix(testStartTs-10, 0))
+
+	cases := map[string]struct {
+		pod      *corev1.Pod
+		current  PodLifecycleData
+		expected PodLifecycleData
+	}{
+		"finished": {
+			pod:      testPod(nil, terminated(testStartTs, testEndTs)),
+			expected: PodLifecycleData{StartTs: testStartTs, EndTs: testEndTs},
+		},
+		"running": {
+			pod:      testPod(nil, running(testStartTs)),
+			expected: PodLifecycleData{StartTs: testStartTs, EndTs: now},
+		},
+		"no status": {
+			pod:      testPod(nil),
+			current:  PodLifecycleData{StartTs: testStartTs},
+			expected: PodLifecycleData{StartTs: testStartTs, EndTs: now},
+		},
+		"never started": {
+			pod:      created,
+			expected: PodLifecycleData{StartTs: testStartTs - 10, EndTs: now},
+		},
+		"unknown": {
+			pod: testPod(nil),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, guessFinishedLifecycle(tc.pod, tc.current, now))
+		})
+	}
+}
+
+func TestPodLifecycleDataOverlaps(t *testing.T) {
+	cases := map[string]struct {
+		data     PodLifecycleData
+		expected bool
+	}{
+		"empty":          {data: PodLifecycleData{}},
+		"running before": {data: PodLifecycleData{StartTs: testStartTs - 10}, expected: true},
+		"running after":  {data: PodLifecycleData{StartTs: testEndTs}},
+		"started during": {data: PodLifecycleData{StartTs: testStartTs, EndTs: testEndTs + 10}, expected: true},
+		"finished during": {
+			data:     PodLifecycleData{StartTs: testStartTs - 10, EndTs: testEndTs - 10},
+			expected: true,
+		},
+		"finished before":        {data: PodLifecycleData{StartTs: testStartTs - 10, EndTs: testStartTs - 5}},
+		"running the whole time": {data: PodLifecycleData{StartTs: testStartTs - 10, EndTs: testEndTs + 10}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.data.overlaps(testStartTs, testEndTs))
+		})
+	}
+}
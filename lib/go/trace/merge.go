@@ -0,0 +1,253 @@
+package trace
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// MergeOptions control how the traces are lined up in time: by default, every trace is
+// re-based so that it starts at the origin (the earliest start time of all the traces), which
+// makes sense for traces that were captured from different clusters at the same time.  If
+// Sequential is set, each trace starts when the previous one ends instead, which makes sense
+// for traces that were captured from different time windows.
+type MergeOptions struct {
+	Sequential bool
+}
+
+// A NamespaceRename records that a namespace in one of the traces (the index of the trace in
+// the list that was merged) was renamed to avoid colliding with a namespace in an earlier trace
+type NamespaceRename struct {
+	Trace int
+	From  string
+	To    string
+}
+
+// Merge combines the traces into a single trace, in the current trace format.  If more than
+// one trace has objects in the same namespace, the first trace keeps the namespace and the
+// other traces' objects are moved into a new namespace with the index of the trace appended
+// to it (e.g., "default-1").  The traces' tracer configs are combined, and it's an error for
+// two traces to have different pod spec template paths for the same kind.
+func Merge(traces []*Trace, opts MergeOptions) (*Trace, []NamespaceRename, error) {
+	if len(traces) == 0 {
+		return nil, nil, errors.New("no traces to merge")
+	}
+
+	config, err := mergeConfigs(traces)
+	if err != nil {
+		return nil, nil, err
+	}
+	merged := &Trace{
+		Version:       CurrentTraceFormat,
+		Config:        config,
+		Index:         map[string]uint64{},
+		PodLifecycles: map[string]map[uint64][]PodLifecycleData{},
+	}
+
+	renames := resolveNamespaces(traces)
+	var allRenames []NamespaceRename
+	for i, offset := range rebaseOffsets(traces, opts) {
+		nsNames := renames[i]
+		froms := lo.Keys(nsNames)
+		sort.Strings(froms)
+		for _, from := range froms {
+			allRenames = append(allRenames, NamespaceRename{Trace: i, From: from, To: nsNames[from]})
+		}
+
+		for _, evt := range traces[i].Events {
+			merged.Events = append(merged.Events, Event{
+				Ts:          evt.Ts + offset,
+				AppliedObjs: renameObjs(evt.AppliedObjs, nsNames),
+				DeletedObjs: renameObjs(evt.DeletedObjs, nsNames),
+			})
+		}
+		for nsName, hash := range traces[i].Index {
+			merged.Index[renameNsName(nsName, nsNames)] = hash
+		}
+		for owner, lifecycles := range traces[i].PodLifecycles {
+			merged.PodLifecycles[renameNsName(owner, nsNames)] = rebaseLifecycles(lifecycles, offset)
+		}
+	}
+
+	merged.Events = coalesceEvents(merged.Events)
+	return merged, allRenames, nil
+}
+
+func mergeConfigs(traces []*Trace) (*TracerConfig, error) {
+	config := &TracerConfig{TrackedObjects: map[string]TrackedObjectConfig{}}
+	for i, trace := range traces {
+		if trace.Config == nil {
+			return nil, fmt.Errorf("trace %d has no tracer config", i)
+		}
+
+		for kind, objCfg := range trace.Config.TrackedObjects {
+			existing, ok := config.TrackedObjects[kind]
+			if !ok {
+				config.TrackedObjects[kind] = objCfg
+				continue
+			} else if existing.PodSpecTemplatePath != objCfg.PodSpecTemplatePath {
+				return nil, fmt.Errorf(
+					"trace %d has a different pod spec template path for %s: %s != %s",
+					i, kind, objCfg.PodSpecTemplatePath, existing.PodSpecTemplatePath,
+				)
+			}
+			existing.TrackLifecycle = existing.TrackLifecycle || objCfg.TrackLifecycle
+			config.TrackedObjects[kind] = existing
+		}
+	}
+	return config, nil
+}
+
+// resolveNamespaces returns the namespaces that need to be renamed in each trace; the new
+// names have to avoid every namespace that's in any of the traces, not just the earlier ones
+func resolveNamespaces(traces []*Trace) []map[string]string {
+	traceNamespaces := make([]map[string]bool, 0, len(traces))
+	taken := map[string]bool{}
+	for _, trace := range traces {
+		namespaces := trace.namespaces()
+		traceNamespaces = append(traceNamespaces, namespaces)
+		for ns := range namespaces {
+			taken[ns] = true
+		}
+	}
+
+	renames := make([]map[string]string, 0, len(traces))
+	used := map[string]bool{}
+	for i, namespaces := range traceNamespaces {
+		nsNames := map[string]string{}
+		sortedNamespaces := lo.Keys(namespaces)
+		sort.Strings(sortedNamespaces)
+		for _, ns := range sortedNamespaces {
+			if !used[ns] {
+				used[ns] = true
+				continue
+			}
+
+			newNs := fmt.Sprintf("%s-%d", ns, i)
+			for j := 1; taken[newNs]; j++ {
+				newNs = fmt.Sprintf("%s-%d-%d", ns, i, j)
+			}
+			taken[newNs] = true
+			used[newNs] = true
+			nsNames[ns] = newNs
+		}
+		renames = append(renames, nsNames)
+	}
+	return renames
+}
+
+// rebaseOffsets returns how far to shift the timestamps in each trace
+func rebaseOffsets(traces []*Trace, opts MergeOptions) []int64 {
+	var origin int64
+	haveOrigin := false
+	for _, trace := range traces {
+		if len(trace.Events) > 0 && (!haveOrigin || trace.Events[0].Ts < origin) {
+			origin = trace.Events[0].Ts
+			haveOrigin = true
+		}
+	}
+
+	offsets := make([]int64, 0, len(traces))
+	start := origin
+	for _, trace := range traces {
+		if len(trace.Events) == 0 {
+			offsets = append(offsets, 0)
+			continue
+		}
+
+		offset := start - trace.Events[0].Ts
+		offsets = append(offsets, offset)
+		if opts.Sequential {
+			start = trace.Events[len(trace.Events)-1].Ts + offset
+		}
+	}
+	return offsets
+}
+
+// namespaces returns every namespace that's referenced in the trace; cluster-scoped objects
+// don't have a namespace (and are just identified by their name in the index), so they're
+// never renamed
+func (self *Trace) namespaces() map[string]bool {
+	namespaces := map[string]bool{}
+	for _, evt := range self.Events {
+		for _, objs := range [][]*unstructured.Unstructured{evt.AppliedObjs, evt.DeletedObjs} {
+			for _, obj := range objs {
+				if ns := obj.GetNamespace(); ns != "" {
+					namespaces[ns] = true
+				}
+			}
+		}
+	}
+	for nsName := range self.Index {
+		if ns, _ := splitNamespacedName(nsName); ns != "" {
+			namespaces[ns] = true
+		}
+	}
+	for owner := range self.PodLifecycles {
+		if ns, _ := splitNamespacedName(owner); ns != "" {
+			namespaces[ns] = true
+		}
+	}
+	return namespaces
+}
+
+// renameObjs copies the objects, so that merging doesn't modify the original traces
+func renameObjs(objs []*unstructured.Unstructured, nsNames map[string]string) []*unstructured.Unstructured {
+	renamed := make([]*unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		newObj := obj.DeepCopy()
+		if newNs, ok := nsNames[obj.GetNamespace()]; ok {
+			newObj.SetNamespace(newNs)
+		}
+		renamed = append(renamed, newObj)
+	}
+	return renamed
+}
+
+func renameNsName(nsName string, nsNames map[string]string) string {
+	ns, name := splitNamespacedName(nsName)
+	if newNs, ok := nsNames[ns]; ok {
+		return namespacedName(newNs, name)
+	}
+	return nsName
+}
+
+// rebaseLifecycles shifts the pod lifecycle timestamps along with the events; pods that
+// haven't started or finished yet keep their zero timestamps
+func rebaseLifecycles(lifecycles map[uint64][]PodLifecycleData, offset int64) map[uint64][]PodLifecycleData {
+	rebased := make(map[uint64][]PodLifecycleData, len(lifecycles))
+	for hash, pods := range lifecycles {
+		rebasedPods := make([]PodLifecycleData, 0, len(pods))
+		for _, data := range pods {
+			if data.StartTs != 0 {
+				data.StartTs += offset
+			}
+			if data.EndTs != 0 {
+				data.EndTs += offset
+			}
+			rebasedPods = append(rebasedPods, data)
+		}
+		rebased[hash] = rebasedPods
+	}
+	return rebased
+}
+
+// coalesceEvents sorts the events by timestamp, and combines events that happen at the same
+// time; events from earlier traces come first, so their objects are applied first
+func coalesceEvents(events []Event) []Event {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Ts < events[j].Ts })
+
+	var coalesced []Event
+	for _, evt := range events {
+		if n := len(coalesced); n > 0 && coalesced[n-1].Ts == evt.Ts {
+			coalesced[n-1].AppliedObjs = append(coalesced[n-1].AppliedObjs, evt.AppliedObjs...)
+			coalesced[n-1].DeletedObjs = append(coalesced[n-1].DeletedObjs, evt.DeletedObjs...)
+			continue
+		}
+		coalesced = append(coalesced, evt)
+	}
+	return coalesced
+}
@@ -0,0 +1,139 @@
+package trace
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// otherTestTrace is the same as testTrace, except that it was recorded later, and it has an
+// object in a second namespace
+func otherTestTrace() *Trace {
+	trace := testTrace()
+	for i := range trace.Events {
+		trace.Events[i].Ts += 100
+	}
+	other := testDeploymentObj(3)
+	other.SetNamespace("other")
+	trace.Events[0].AppliedObjs = append(trace.Events[0].AppliedObjs, other)
+	trace.Index["other/nginx"] = SpecHash(other)
+	trace.PodLifecycles["default/nginx"][1234][0] = PodLifecycleData{StartTs: testStartTs + 100, EndTs: testEndTs + 100}
+	return trace
+}
+
+func eventSummary(events []Event) map[int64][]string {
+	summary := map[int64][]string{}
+	for _, evt := range events {
+		for _, obj := range evt.AppliedObjs {
+			summary[evt.Ts] = append(summary[evt.Ts], "+"+obj.GetNamespace())
+		}
+		for _, obj := range evt.DeletedObjs {
+			summary[evt.Ts] = append(summary[evt.Ts], "-"+obj.GetNamespace())
+		}
+	}
+	return summary
+}
+
+func TestMerge(t *testing.T) {
+	for name, tc := range map[string]struct {
+		opts          MergeOptions
+		expectedTimes map[int64][]string
+		expectedStart int64
+	}{
+		"concurrent": {
+			expectedTimes: map[int64][]string{
+				0:  {"+default", "+default-1", "+other"},
+				5:  {"+default", "+default-1"},
+				10: {"-default", "-default-1"},
+			},
+			expectedStart: testStartTs,
+		},
+		"sequential": {
+			opts: MergeOptions{Sequential: true},
+			expectedTimes: map[int64][]string{
+				0:  {"+default"},
+				5:  {"+default"},
+				10: {"+default-1", "+other", "-default"},
+				15: {"+default-1"},
+				20: {"-default-1"},
+			},
+			expectedStart: testStartTs + 10,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			first, second := testTrace(), otherTestTrace()
+			merged, renames, err := Merge([]*Trace{first, second}, tc.opts)
+			require.Nil(t, err)
+
+			assert.Equal(t, []NamespaceRename{{Trace: 1, From: "default", To: "default-1"}}, renames)
+			assert.Equal(t, tc.expectedTimes, eventSummary(merged.Events))
+			assert.Equal(t, DefaultTracerConfig(), merged.Config)
+			assert.ElementsMatch(t, []string{"default/nginx", "default-1/nginx", "other/nginx"}, lo.Keys(merged.Index))
+
+			lifecycle := merged.LookupPodLifecycle("default-1/nginx", 1234, 0)
+			assert.Equal(t, PodLifecycleData{StartTs: tc.expectedStart, EndTs: tc.expectedStart + 1000}, lifecycle)
+			assert.Equal(t, testTrace().PodLifecycles["default/nginx"], merged.PodLifecycles["default/nginx"])
+
+			// The original traces aren't modified
+			assert.Equal(t, "default", second.Events[0].AppliedObjs[0].GetNamespace())
+			assert.Equal(t, otherTestTrace(), second)
+		})
+	}
+}
+
+func TestMergeRenameAvoidsExistingNamespace(t *testing.T) {
+	first, second := testTrace(), testTrace()
+	taken := testDeploymentObj(1)
+	taken.SetNamespace("default-1")
+	first.Events[0].AppliedObjs = append(first.Events[0].AppliedObjs, taken)
+
+	merged, renames, err := Merge([]*Trace{first, second}, MergeOptions{})
+	require.Nil(t, err)
+	assert.Equal(t, []NamespaceRename{{Trace: 1, From: "default", To: "default-1-1"}}, renames)
+	assert.Contains(t, merged.Index, "default-1-1/nginx")
+}
+
+func TestMergeClusterScopedObjects(t *testing.T) {
+	first, second := testTrace(), testTrace()
+	for _, trace := range []*Trace{first, second} {
+		node := &unstructured.Unstructured{}
+		node.SetAPIVersion("v1")
+		node.SetKind("Node")
+		node.SetName("node1")
+		trace.Events[0].AppliedObjs = append(trace.Events[0].AppliedObjs, node)
+		trace.Index["node1"] = SpecHash(node)
+	}
+
+	merged, renames, err := Merge([]*Trace{first, second}, MergeOptions{})
+	require.Nil(t, err)
+	assert.Equal(t, []NamespaceRename{{Trace: 1, From: "default", To: "default-1"}}, renames)
+	assert.Equal(t, []string{"+default", "+", "+default-1", "+"}, eventSummary(merged.Events)[0])
+	assert.ElementsMatch(t, []string{"default/nginx", "default-1/nginx", "node1"}, lo.Keys(merged.Index))
+}
+
+func TestMergeConfigConflict(t *testing.T) {
+	first, second := testTrace(), testTrace()
+	second.Config = &TracerConfig{TrackedObjects: map[string]TrackedObjectConfig{
+		"apps/v1.Deployment": {PodSpecTemplatePath: "/spec/foo"},
+		"batch/v1.Job":       {PodSpecTemplatePath: "/spec/template"},
+	}}
+
+	_, _, err := Merge([]*Trace{first, second}, MergeOptions{})
+	assert.ErrorContains(t, err, "different pod spec template path")
+
+	second.Config.TrackedObjects["apps/v1.Deployment"] = TrackedObjectConfig{PodSpecTemplatePath: "/spec/template"}
+	merged, _, err := Merge([]*Trace{first, second}, MergeOptions{})
+	require.Nil(t, err)
+	assert.Equal(t, map[string]TrackedObjectConfig{
+		"apps/v1.Deployment": {PodSpecTemplatePath: "/spec/template", TrackLifecycle: true},
+		"batch/v1.Job":       {PodSpecTemplatePath: "/spec/template"},
+	}, merged.Config.TrackedObjects)
+}
+
+func TestMergeNoTraces(t *testing.T) {
+	_, _, err := Merge(nil, MergeOptions{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,352 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// Traces are stored in msgpack format, the way rmp_serde::to_vec_named writes them (i.e.,
+// structs are maps keyed by the field names); this is just enough of an encoder to write the
+// values in a trace, which are all plain JSON-like data.
+type msgpackEncoder struct {
+	buf []byte
+}
+
+func (self *msgpackEncoder) encode(v interface{}) error {
+	switch val := v.(type) {
+	case nil:
+		self.buf = append(self.buf, 0xc0)
+	case bool:
+		if val {
+			self.buf = append(self.buf, 0xc3)
+		} else {
+			self.buf = append(self.buf, 0xc2)
+		}
+	case int:
+		self.encodeInt(int64(val))
+	case int32:
+		self.encodeInt(int64(val))
+	case int64:
+		self.encodeInt(val)
+	case uint64:
+		self.encodeUint(val)
+	case float64:
+		self.buf = binary.BigEndian.AppendUint64(append(self.buf, 0xcb), math.Float64bits(val))
+	case string:
+		self.encodeString(val)
+	case []interface{}:
+		self.encodeArrayLen(len(val))
+		for _, item := range val {
+			if err := self.encode(item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		// The keys are sorted so that the same trace is always encoded the same way
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		self.encodeMapLen(len(val))
+		for _, k := range keys {
+			self.encodeString(k)
+			if err := self.encode(val[k]); err != nil {
+				return err
+			}
+		}
+	case map[uint64]interface{}:
+		keys := make([]uint64, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+		self.encodeMapLen(len(val))
+		for _, k := range keys {
+			self.encodeUint(k)
+			if err := self.encode(val[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot encode value of type %T", v)
+	}
+	return nil
+}
+
+// Like rmp, non-negative integers are always written as unsigned, in the smallest format
+// that fits the value
+func (self *msgpackEncoder) encodeInt(i int64) {
+	switch {
+	case i >= 0:
+		self.encodeUint(uint64(i))
+	case i >= -32:
+		self.buf = append(self.buf, byte(i))
+	case i >= math.MinInt8:
+		self.buf = append(self.buf, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		self.buf = binary.BigEndian.AppendUint16(append(self.buf, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		self.buf = binary.BigEndian.AppendUint32(append(self.buf, 0xd2), uint32(i))
+	default:
+		self.buf = binary.BigEndian.AppendUint64(append(self.buf, 0xd3), uint64(i))
+	}
+}
+
+func (self *msgpackEncoder) encodeUint(u uint64) {
+	switch {
+	case u < 128:
+		self.buf = append(self.buf, byte(u))
+	case u <= math.MaxUint8:
+		self.buf = append(self.buf, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		self.buf = binary.BigEndian.AppendUint16(append(self.buf, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		self.buf = binary.BigEndian.AppendUint32(append(self.buf, 0xce), uint32(u))
+	default:
+		self.buf = binary.BigEndian.AppendUint64(append(self.buf, 0xcf), u)
+	}
+}
+
+func (self *msgpackEncoder) encodeString(s string) {
+	self.encodeLen(len(s), 0xa0, 32, 0xd9, 0xda, 0xdb)
+	self.buf = append(self.buf, s...)
+}
+
+func (self *msgpackEncoder) encodeArrayLen(n int) {
+	self.encodeLen(n, 0x90, 16, 0, 0xdc, 0xdd)
+}
+
+func (self *msgpackEncoder) encodeMapLen(n int) {
+	self.encodeLen(n, 0x80, 16, 0, 0xde, 0xdf)
+}
+
+// encodeLen writes the header for a string, array, or map; arrays and maps don't have an
+// 8-bit length format, so their len8 marker is 0
+func (self *msgpackEncoder) encodeLen(n int, fixMarker byte, fixMax int, len8, len16, len32 byte) {
+	switch {
+	case n < fixMax:
+		self.buf = append(self.buf, fixMarker|byte(n))
+	case len8 != 0 && n <= math.MaxUint8:
+		self.buf = append(self.buf, len8, byte(n))
+	case n <= math.MaxUint16:
+		self.buf = binary.BigEndian.AppendUint16(append(self.buf, len16), uint16(n))
+	default:
+		self.buf = binary.BigEndian.AppendUint32(append(self.buf, len32), uint32(n))
+	}
+}
+
+// The decoder reads the values that the encoder writes (and that rmp_serde writes for a
+// trace), one at a time, so that traces can be read without loading the whole file first;
+// integers are decoded as int64 if they fit, and uint64 otherwise, like encoding/json does
+// for unstructured objects.
+type msgpackDecoder struct {
+	r *bufio.Reader
+}
+
+func newMsgpackDecoder(r io.Reader) *msgpackDecoder {
+	return &msgpackDecoder{r: bufio.NewReader(r)}
+}
+
+func (self *msgpackDecoder) decode() (interface{}, error) {
+	marker, err := self.r.ReadByte()
+	if err != nil {
+		return nil, self.wrap(err)
+	}
+
+	switch {
+	case marker <= 0x7f || marker >= 0xe0 || (marker >= 0xcc && marker <= 0xd3):
+		return self.decodeIntWithMarker(marker)
+	case marker&0xf0 == 0x80 || marker == 0xde || marker == 0xdf:
+		return self.decodeMapWithMarker(marker)
+	case marker&0xf0 == 0x90 || marker == 0xdc || marker == 0xdd:
+		return self.decodeArrayWithMarker(marker)
+	case marker&0xe0 == 0xa0 || (marker >= 0xd9 && marker <= 0xdb):
+		return self.decodeStringWithMarker(marker)
+	case marker == 0xc0:
+		return nil, nil
+	case marker == 0xc2:
+		return false, nil
+	case marker == 0xc3:
+		return true, nil
+	case marker == 0xca:
+		bits, err := self.readUint(4)
+		return float64(math.Float32frombits(uint32(bits))), err
+	case marker == 0xcb:
+		bits, err := self.readUint(8)
+		return math.Float64frombits(bits), err
+	default:
+		return nil, fmt.Errorf("cannot decode msgpack value with marker 0x%02x", marker)
+	}
+}
+
+func (self *msgpackDecoder) decodeArrayLen() (int, error) {
+	marker, err := self.r.ReadByte()
+	if err != nil {
+		return 0, self.wrap(err)
+	}
+	return self.decodeLen(marker, 0x90, 0xf0, 0, 0xdc, 0xdd)
+}
+
+func (self *msgpackDecoder) decodeMapLen() (int, error) {
+	marker, err := self.r.ReadByte()
+	if err != nil {
+		return 0, self.wrap(err)
+	}
+	return self.decodeLen(marker, 0x80, 0xf0, 0, 0xde, 0xdf)
+}
+
+func (self *msgpackDecoder) decodeString() (string, error) {
+	marker, err := self.r.ReadByte()
+	if err != nil {
+		return "", self.wrap(err)
+	}
+	return self.decodeStringWithMarker(marker)
+}
+
+func (self *msgpackDecoder) decodeInt() (int64, error) {
+	v, err := self.decode()
+	if err != nil {
+		return 0, err
+	}
+	i, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("expected int64, got %T", v)
+	}
+	return i, nil
+}
+
+func (self *msgpackDecoder) decodeUint() (uint64, error) {
+	v, err := self.decode()
+	if err != nil {
+		return 0, err
+	}
+	switch val := v.(type) {
+	case uint64:
+		return val, nil
+	case int64:
+		if val >= 0 {
+			return uint64(val), nil
+		}
+	}
+	return 0, fmt.Errorf("expected uint64, got %v", v)
+}
+
+func (self *msgpackDecoder) decodeIntWithMarker(marker byte) (interface{}, error) {
+	switch {
+	case marker <= 0x7f:
+		return int64(marker), nil
+	case marker >= 0xe0:
+		return int64(int8(marker)), nil
+	}
+
+	size := 1 << ((marker - 0xcc) % 4)
+	u, err := self.readUint(size)
+	if err != nil {
+		return nil, err
+	}
+
+	// 0xcc-0xcf are unsigned, and 0xd0-0xd3 are signed
+	if marker <= 0xcf {
+		if u > math.MaxInt64 {
+			return u, nil
+		}
+		return int64(u), nil
+	}
+	shift := 64 - 8*size
+	return int64(u<<shift) >> shift, nil
+}
+
+func (self *msgpackDecoder) decodeStringWithMarker(marker byte) (string, error) {
+	n, err := self.decodeLen(marker, 0xa0, 0xe0, 0xd9, 0xda, 0xdb)
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(self.r, buf); err != nil {
+		return "", self.wrap(err)
+	}
+	return string(buf), nil
+}
+
+func (self *msgpackDecoder) decodeArrayWithMarker(marker byte) ([]interface{}, error) {
+	n, err := self.decodeLen(marker, 0x90, 0xf0, 0, 0xdc, 0xdd)
+	if err != nil {
+		return nil, err
+	}
+
+	arr := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		item, err := self.decode()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, item)
+	}
+	return arr, nil
+}
+
+// Generic maps can only have string keys, since they're JSON objects; the maps that have
+// integer keys in a trace are decoded with decodeMapLen instead
+func (self *msgpackDecoder) decodeMapWithMarker(marker byte) (map[string]interface{}, error) {
+	n, err := self.decodeLen(marker, 0x80, 0xf0, 0, 0xde, 0xdf)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := self.decodeString()
+		if err != nil {
+			return nil, fmt.Errorf("invalid map key: %w", err)
+		}
+		if m[k], err = self.decode(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// decodeLen reads the length of a string, array, or map with the given marker; the mask is
+// the bits of the marker that aren't part of a fix-format length
+func (self *msgpackDecoder) decodeLen(marker, fixMarker, fixMask, len8, len16, len32 byte) (int, error) {
+	var n uint64
+	var err error
+	switch {
+	case marker&fixMask == fixMarker:
+		return int(marker &^ fixMask), nil
+	case len8 != 0 && marker == len8:
+		n, err = self.readUint(1)
+	case marker == len16:
+		n, err = self.readUint(2)
+	case marker == len32:
+		n, err = self.readUint(4)
+	default:
+		return 0, fmt.Errorf("unexpected msgpack marker 0x%02x", marker)
+	}
+	return int(n), err
+}
+
+func (self *msgpackDecoder) readUint(size int) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(self.r, buf[8-size:]); err != nil {
+		return 0, self.wrap(err)
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+// Running out of data in the middle of a value is always an error
+func (self *msgpackDecoder) wrap(err error) error {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("could not read msgpack data: %w", err)
+}
@@ -0,0 +1,124 @@
+package trace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMsgpackEncode(t *testing.T) {
+	cases := map[string]struct {
+		value    interface{}
+		expected []byte
+	}{
+		"nil":      {value: nil, expected: []byte{0xc0}},
+		"true":     {value: true, expected: []byte{0xc3}},
+		"fixint":   {value: int64(5), expected: []byte{0x05}},
+		"negative": {value: int64(-5), expected: []byte{0xfb}},
+		"int8":     {value: int64(-100), expected: []byte{0xd0, 0x9c}},
+		"uint8":    {value: 200, expected: []byte{0xcc, 0xc8}},
+		"uint16":   {value: int64(1000), expected: []byte{0xcd, 0x03, 0xe8}},
+		"uint32":   {value: int64(1700000000), expected: []byte{0xce, 0x65, 0x53, 0xf1, 0x00}},
+		"uint64":   {value: uint64(1 << 63), expected: []byte{0xcf, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		"float":    {value: 1.5, expected: []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		"fixstr":   {value: "foo", expected: []byte{0xa3, 'f', 'o', 'o'}},
+		"array":    {value: []interface{}{int64(1), "a"}, expected: []byte{0x92, 0x01, 0xa1, 'a'}},
+		"sorted map": {
+			value:    map[string]interface{}{"b": int64(2), "a": int64(1)},
+			expected: []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02},
+		},
+		"uint64 map": {value: map[uint64]interface{}{2: nil, 1: nil}, expected: []byte{0x82, 0x01, 0xc0, 0x02, 0xc0}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var enc msgpackEncoder
+			assert.Nil(t, enc.encode(tc.value))
+			assert.Equal(t, tc.expected, enc.buf)
+		})
+	}
+}
+
+func TestMsgpackEncodeLongString(t *testing.T) {
+	var enc msgpackEncoder
+	assert.Nil(t, enc.encode(strings.Repeat("a", 40)))
+	assert.Equal(t, []byte{0xd9, 40}, enc.buf[:2])
+	assert.Len(t, enc.buf, 42)
+
+	enc = msgpackEncoder{}
+	assert.Nil(t, enc.encode(strings.Repeat("a", 300)))
+	assert.Equal(t, []byte{0xda, 0x01, 0x2c}, enc.buf[:3])
+}
+
+func TestMsgpackEncodeInvalid(t *testing.T) {
+	var enc msgpackEncoder
+	assert.NotNil(t, enc.encode([]interface{}{struct{}{}}))
+}
+
+func TestMsgpackDecode(t *testing.T) {
+	cases := map[string]struct {
+		data     []byte
+		expected interface{}
+	}{
+		"nil":      {data: []byte{0xc0}, expected: nil},
+		"false":    {data: []byte{0xc2}, expected: false},
+		"fixint":   {data: []byte{0x05}, expected: int64(5)},
+		"negative": {data: []byte{0xfb}, expected: int64(-5)},
+		"int8":     {data: []byte{0xd0, 0x9c}, expected: int64(-100)},
+		"int16":    {data: []byte{0xd1, 0xfc, 0x18}, expected: int64(-1000)},
+		"uint32":   {data: []byte{0xce, 0x65, 0x53, 0xf1, 0x00}, expected: int64(1700000000)},
+		"uint64":   {data: []byte{0xcf, 0x80, 0, 0, 0, 0, 0, 0, 0}, expected: uint64(1 << 63)},
+		"float32":  {data: []byte{0xca, 0x3f, 0xc0, 0, 0}, expected: 1.5},
+		"float64":  {data: []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}, expected: 1.5},
+		"str8":     {data: []byte{0xd9, 0x03, 'f', 'o', 'o'}, expected: "foo"},
+		"array16":  {data: []byte{0xdc, 0x00, 0x02, 0x01, 0xa1, 'a'}, expected: []interface{}{int64(1), "a"}},
+		"map": {
+			data:     []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x90},
+			expected: map[string]interface{}{"a": int64(1), "b": []interface{}{}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v, err := newMsgpackDecoder(bytes.NewReader(tc.data)).decode()
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, v)
+		})
+	}
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	value := map[string]interface{}{
+		"long": strings.Repeat("a", 300),
+		"ints": []interface{}{int64(-1 << 40), int64(-200), int64(0), int64(70000), int64(1 << 40)},
+		"nested": map[string]interface{}{
+			"float": -2.25,
+			"bools": []interface{}{true, false, nil},
+		},
+	}
+
+	var enc msgpackEncoder
+	assert.Nil(t, enc.encode(value))
+	decoded, err := newMsgpackDecoder(bytes.NewReader(enc.buf)).decode()
+	assert.Nil(t, err)
+	assert.Equal(t, value, decoded)
+}
+
+func TestMsgpackDecodeInvalid(t *testing.T) {
+	cases := map[string][]byte{
+		"empty":         {},
+		"truncated":     {0x92, 0x01},
+		"int map key":   {0x81, 0x01, 0x01},
+		"unsupported":   {0xc4, 0x01, 0x00},
+		"truncated str": {0xa3, 'f'},
+	}
+
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := newMsgpackDecoder(bytes.NewReader(data)).decode()
+			assert.NotNil(t, err)
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package trace
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A Reader reads a trace one event at a time, so that tools can look at large traces without
+// loading all of the objects in them at once.  The tracer config is read by NewReader, the
+// events by Next, and the index and pod lifecycle data (which come after the events) by
+// Finish.
+type Reader struct {
+	dec     *msgpackDecoder
+	version int
+	config  *TracerConfig
+
+	numEvents int
+	read      int
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	dec := newMsgpackDecoder(r)
+	version, err := detectVersion(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	msgConfig, err := dec.decode()
+	if err != nil {
+		return nil, fmt.Errorf("could not read tracer config: %w", err)
+	}
+	config, err := tracerConfigFromMsgpack(msgConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not read tracer config: %w", err)
+	}
+
+	numEvents, err := dec.decodeArrayLen()
+	if err != nil {
+		return nil, fmt.Errorf("could not read events: %w", err)
+	}
+	return &Reader{dec: dec, version: version, config: config, numEvents: numEvents}, nil
+}
+
+func (self *Reader) Version() int {
+	return self.version
+}
+
+func (self *Reader) Config() *TracerConfig {
+	return self.config
+}
+
+// Next returns the next event in the trace, or io.EOF if there aren't any more
+func (self *Reader) Next() (Event, error) {
+	if self.read == self.numEvents {
+		return Event{}, io.EOF
+	}
+
+	msgEvent, err := self.dec.decode()
+	if err != nil {
+		return Event{}, fmt.Errorf("could not read event %d: %w", self.read, err)
+	}
+	evt, err := eventFromMsgpack(msgEvent)
+	if err != nil {
+		return Event{}, fmt.Errorf("could not read event %d: %w", self.read, err)
+	}
+	self.read++
+	return evt, nil
+}
+
+// Finish skips any events that haven't been read yet, and returns the index and the pod
+// lifecycle data
+func (self *Reader) Finish() (map[string]uint64, map[string]map[uint64][]PodLifecycleData, error) {
+	for self.read < self.numEvents {
+		if _, err := self.Next(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	index, err := self.readIndex()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read index: %w", err)
+	}
+	podLifecycles, err := self.readPodLifecycles()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read pod lifecycle data: %w", err)
+	}
+	return index, podLifecycles, nil
+}
+
+func (self *Reader) readIndex() (map[string]uint64, error) {
+	n, err := self.dec.decodeMapLen()
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]uint64, n)
+	for i := 0; i < n; i++ {
+		nsName, err := self.dec.decodeString()
+		if err != nil {
+			return nil, err
+		}
+		if index[nsName], err = self.dec.decodeUint(); err != nil {
+			return nil, fmt.Errorf("invalid hash for %s: %w", nsName, err)
+		}
+	}
+	return index, nil
+}
+
+func (self *Reader) readPodLifecycles() (map[string]map[uint64][]PodLifecycleData, error) {
+	numOwners, err := self.dec.decodeMapLen()
+	if err != nil {
+		return nil, err
+	}
+
+	podLifecycles := make(map[string]map[uint64][]PodLifecycleData, numOwners)
+	for i := 0; i < numOwners; i++ {
+		owner, err := self.dec.decodeString()
+		if err != nil {
+			return nil, err
+		}
+		numHashes, err := self.dec.decodeMapLen()
+		if err != nil {
+			return nil, fmt.Errorf("invalid lifecycle data for %s: %w", owner, err)
+		}
+
+		podLifecycles[owner] = make(map[uint64][]PodLifecycleData, numHashes)
+		for j := 0; j < numHashes; j++ {
+			hash, err := self.dec.decodeUint()
+			if err != nil {
+				return nil, fmt.Errorf("invalid pod hash for %s: %w", owner, err)
+			}
+			msgPods, err := self.dec.decode()
+			if err != nil {
+				return nil, err
+			}
+			pods, ok := msgPods.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid lifecycle data for %s: %v", owner, msgPods)
+			}
+
+			for _, msgData := range pods {
+				data, err := podLifecycleFromMsgpack(msgData)
+				if err != nil {
+					return nil, fmt.Errorf("invalid lifecycle data for %s: %w", owner, err)
+				}
+				podLifecycles[owner][hash] = append(podLifecycles[owner][hash], data)
+			}
+		}
+	}
+	return podLifecycles, nil
+}
+
+// ReadTrace reads a whole trace into memory
+func ReadTrace(r io.Reader) (*Trace, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	trace := &Trace{Version: reader.Version(), Config: reader.Config()}
+	for {
+		evt, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		trace.Events = append(trace.Events, evt)
+	}
+
+	if trace.Index, trace.PodLifecycles, err = reader.Finish(); err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
+
+// detectVersion figures out which format the trace is in from the start of the trace; the
+// only format so far is a 4-tuple of the config, the events, the index, and the lifecycles
+func detectVersion(dec *msgpackDecoder) (int, error) {
+	n, err := dec.decodeArrayLen()
+	if err != nil {
+		return 0, fmt.Errorf("could not detect trace format: %w", err)
+	}
+
+	switch n {
+	case traceFormatV1Len:
+		return TraceFormatV1, nil
+	default:
+		return 0, fmt.Errorf("unknown trace format: expected a %d-tuple, got %d items", traceFormatV1Len, n)
+	}
+}
@@ -0,0 +1,79 @@
+package trace
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadTraceRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, WriteTrace(&buf, testTrace()))
+
+	trace, err := ReadTrace(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, testTrace(), trace)
+}
+
+func TestReadTraceExported(t *testing.T) {
+	s := newStore(DefaultTracerConfig())
+	s.createOrUpdateObj(testDeploymentObj(1), 1)
+	s.createOrUpdateObj(testDeploymentObj(2), 5)
+	data, err := s.export(0, 10)
+	assert.Nil(t, err)
+
+	trace, err := ReadTrace(bytes.NewReader(data))
+	assert.Nil(t, err)
+	events, index := s.collectEvents(0, 10)
+	assert.Equal(t, TraceFormatV1, trace.Version)
+	assert.Equal(t, s.config, trace.Config)
+	assert.Equal(t, events, trace.Events)
+	assert.Equal(t, index, trace.Index)
+	assert.Empty(t, trace.PodLifecycles)
+}
+
+func TestReaderStreaming(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, WriteTrace(&buf, testTrace()))
+
+	reader, err := NewReader(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, TraceFormatV1, reader.Version())
+	assert.Equal(t, DefaultTracerConfig(), reader.Config())
+
+	evt, err := reader.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, testTrace().Events[0], evt)
+
+	// The rest of the events are skipped
+	index, podLifecycles, err := reader.Finish()
+	assert.Nil(t, err)
+	assert.Equal(t, testTrace().Index, index)
+	assert.Equal(t, testTrace().PodLifecycles, podLifecycles)
+
+	_, err = reader.Next()
+	assert.True(t, errors.Is(err, io.EOF))
+}
+
+func TestReadTraceInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, WriteTrace(&buf, testTrace()))
+	data := buf.Bytes()
+
+	cases := map[string][]byte{
+		"empty":          {},
+		"unknown format": {0x93, 0x80, 0x90, 0x80},
+		"no config":      {0x94, 0xc0, 0x90, 0x80, 0x80},
+		"truncated":      data[:len(data)-4],
+	}
+
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ReadTrace(bytes.NewReader(data))
+			assert.NotNil(t, err)
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jonboulle/clockwork"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/cache"
+
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/volcano"
+)
+
+const (
+	informerResyncPeriod = 0
+
+	lastAppliedConfigAnnotation  = "kubectl.kubernetes.io/last-applied-configuration"
+	deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+)
+
+// A Recorder is a native Go version of sk-tracer: it watches the tracked objects, and all of
+// the pods in the cluster, and records their changes in an in-memory trace, which can be
+// exported at any time in the same format that sk-tracer uses.
+type Recorder struct {
+	// The mutex protects the trace; the informers call the pod event handlers one at a time,
+	// so the rest of the pod tracking state doesn't need it
+	mutex sync.Mutex
+	store *store
+
+	k8sClient     kubernetes.Interface
+	dynamicClient dynamic.Interface
+	mapper        meta.RESTMapper
+
+	// ownedPods is the latest lifecycle data for each pod, and owners caches the ownership
+	// chain for each pod (and its owners), since it doesn't change
+	ownedPods map[string]PodLifecycleData
+	owners    *k8s.OwnersCache
+
+	clock  clockwork.Clock
+	logger *log.Entry
+}
+
+func NewRecorder(config *TracerConfig, opts k8s.ClientOptions) (*Recorder, error) {
+	k8sClient, err := k8s.NewClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize Kubernetes client: %w", err)
+	}
+
+	dynamicClient, err := k8s.NewDynamicClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize Kubernetes client: %w", err)
+	}
+
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(k8sClient.Discovery()))
+	return newRecorder(config, k8sClient, dynamicClient, mapper), nil
+}
+
+func newRecorder(
+	config *TracerConfig,
+	k8sClient kubernetes.Interface,
+	dynamicClient dynamic.Interface,
+	mapper meta.RESTMapper,
+) *Recorder {
+	return &Recorder{
+		store:         newStore(config),
+		k8sClient:     k8sClient,
+		dynamicClient: dynamicClient,
+		mapper:        mapper,
+		ownedPods:     map[string]PodLifecycleData{},
+		owners:        k8s.NewOwnersCache(dynamicClient, mapper),
+		clock:         clockwork.NewRealClock(),
+		logger:        log.WithFields(log.Fields{"component": "trace-recorder"}),
+	}
+}
+
+// Start runs the informers for the tracked objects and the pods; the tracked objects are
+// synced first, so that the pods that already exist can be matched up with their owners.
+func (self *Recorder) Start(ctx context.Context) error {
+	objFactory := dynamicinformer.NewDynamicSharedInformerFactory(self.dynamicClient, informerResyncPeriod)
+	for key := range self.store.config.TrackedObjects {
+		gvk, err := ParseGVK(key)
+		if err != nil {
+			return err
+		}
+		mapping, err := self.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return fmt.Errorf("could not find resource for %s: %w", key, err)
+		}
+
+		informer := objFactory.ForResource(mapping.Resource).Informer()
+		if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { self.objApplied(gvk, obj) },
+			UpdateFunc: func(_, obj interface{}) { self.objApplied(gvk, obj) },
+			DeleteFunc: func(obj interface{}) { self.objDeleted(gvk, obj) },
+		}); err != nil {
+			return fmt.Errorf("could not watch %s: %w", key, err)
+		}
+	}
+
+	podFactory := informers.NewSharedInformerFactory(self.k8sClient, informerResyncPeriod)
+	if _, err := podFactory.Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { self.podApplied(ctx, obj) },
+		UpdateFunc: func(_, obj interface{}) { self.podApplied(ctx, obj) },
+		DeleteFunc: func(obj interface{}) { self.podDeleted(ctx, obj) },
+	}); err != nil {
+		return fmt.Errorf("could not watch pods: %w", err)
+	}
+
+	objFactory.Start(ctx.Done())
+	for resource, synced := range objFactory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("could not sync %s informer", resource)
+		}
+	}
+	podFactory.Start(ctx.Done())
+	for _, synced := range podFactory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return errors.New("could not sync pod informer")
+		}
+	}
+	return nil
+}
+
+// Export returns the trace of everything that happened in [startTs, endTs), in unix seconds
+func (self *Recorder) Export(startTs, endTs int64) ([]byte, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.store.export(startTs, endTs)
+}
+
+func (self *Recorder) objApplied(gvk schema.GroupVersionKind, obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	ts := self.clock.Now().Unix()
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.store.createOrUpdateObj(SanitizeObj(gvk, u), ts)
+}
+
+func (self *Recorder) objDeleted(gvk schema.GroupVersionKind, obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	ts := self.clock.Now().Unix()
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.store.deleteObj(SanitizeObj(gvk, u), ts)
+}
+
+// We only store lifecycle data when it changes in a valid way (see supersedes); if a pod's
+// containers restart, for example, we keep the original start time.
+func (self *Recorder) podApplied(ctx context.Context, obj interface{}) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+
+	nsName := namespacedName(pod.ObjectMeta.Namespace, pod.ObjectMeta.Name)
+	data := newPodLifecycleData(pod)
+	current := self.ownedPods[nsName]
+	if data.supersedes(current) {
+		self.ownedPods[nsName] = data
+		self.storePodLifecycle(ctx, pod, data)
+	} else if !data.Empty() && data != current {
+		self.logger.Warnf(
+			"new lifecycle data for %s does not match stored data, not updating: %v, %v",
+			nsName,
+			data,
+			current,
+		)
+	}
+}
+
+func (self *Recorder) podDeleted(ctx context.Context, obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+
+	// If the lifecycle data is already finished, it's already been stored
+	nsName := namespacedName(pod.ObjectMeta.Namespace, pod.ObjectMeta.Name)
+	current := self.ownedPods[nsName]
+	delete(self.ownedPods, nsName)
+	defer self.owners.Forget(pod.ObjectMeta.Namespace, pod.ObjectMeta.Name)
+	if current.Finished() {
+		return
+	}
+
+	if data := guessFinishedLifecycle(pod, current, self.clock.Now().Unix()); !data.Empty() {
+		self.storePodLifecycle(ctx, pod, data)
+	}
+}
+
+func (self *Recorder) storePodLifecycle(ctx context.Context, pod *corev1.Pod, data PodLifecycleData) {
+	// The lifecycle data is stored under whichever of the pod's owners (or their owners, and
+	// so on) is tracked; e.g., a pod's direct owner is usually a ReplicaSet, but we're tracking
+	// the Deployment that owns the ReplicaSet.
+	owners, err := self.owners.OwnerChain(ctx, pod)
+	if err != nil {
+		self.logger.WithError(err).Errorf("could not store lifecycle data for %s/%s", pod.Namespace, pod.Name)
+		return
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if err := self.store.recordPodLifecycle(pod, owners, data); err != nil {
+		self.logger.WithError(err).Errorf("could not store lifecycle data for %s/%s", pod.Namespace, pod.Name)
+	}
+}
+
+// SanitizeObj strips out the metadata that won't be the same in the simulation (or that the
+// apiserver won't let us set) before the object is stored in the trace, like sk-tracer does
+func SanitizeObj(gvk schema.GroupVersionKind, obj *unstructured.Unstructured) *unstructured.Unstructured {
+	obj = obj.DeepCopy()
+	obj.SetGroupVersionKind(gvk)
+	volcano.RecordOwner(obj)
+	for _, field := range []string{
+		"creationTimestamp",
+		"deletionTimestamp",
+		"deletionGracePeriodSeconds",
+		"generation",
+		"managedFields",
+		"ownerReferences",
+		"resourceVersion",
+		"uid",
+	} {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+
+	if annotations := obj.GetAnnotations(); annotations != nil {
+		delete(annotations, lastAppliedConfigAnnotation)
+		delete(annotations, deploymentRevisionAnnotation)
+		obj.SetAnnotations(annotations)
+	}
+	return obj
+}
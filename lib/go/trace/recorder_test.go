@@ -0,0 +1,162 @@
+package trace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"simkube/lib/go/testutils"
+)
+
+func fakeRecorder(pods ...*corev1.Pod) (*Recorder, clockwork.FakeClock) {
+	deployment := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       testNamespace,
+			Name:            testDeploymentName,
+			UID:             "1234",
+			ResourceVersion: "1",
+			Annotations:     map[string]string{deploymentRevisionAnnotation: "1"},
+		},
+	}
+	replicaSet := &appsv1.ReplicaSet{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "ReplicaSet"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       testNamespace,
+			Name:            testReplicaSetName,
+			OwnerReferences: []metav1.OwnerReference{deploymentOwnerRef()},
+		},
+	}
+
+	k8sClient := fake.NewSimpleClientset()
+	for _, pod := range pods {
+		if _, err := k8sClient.CoreV1().Pods(testNamespace).Create(
+			context.TODO(),
+			pod,
+			metav1.CreateOptions{},
+		); err != nil {
+			panic(err)
+		}
+	}
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("ReplicaSet"), meta.RESTScopeNamespace)
+
+	clock := clockwork.NewFakeClockAt(time.Unix(testStartTs, 0))
+	recorder := newRecorder(
+		DefaultTracerConfig(),
+		k8sClient,
+		dynamicfake.NewSimpleDynamicClient(scheme.Scheme, deployment, replicaSet),
+		mapper,
+	)
+	recorder.clock = clock
+	recorder.logger = testutils.GetFakeLogger()
+	return recorder, clock
+}
+
+func ownedPod(name string, states ...corev1.ContainerState) *corev1.Pod {
+	pod := testPod(nil, states...)
+	pod.ObjectMeta.Name = name
+	pod.ObjectMeta.OwnerReferences = []metav1.OwnerReference{
+		{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: testReplicaSetName},
+	}
+	return pod
+}
+
+func startRecorder(t *testing.T, recorder *Recorder) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := recorder.Start(ctx); err != nil {
+		panic(err)
+	}
+}
+
+func recordedLifecycles(recorder *Recorder) []PodLifecycleData {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	var lifecycles []PodLifecycleData
+	for _, pods := range recorder.store.podLifecycles["default/nginx"] {
+		lifecycles = append(lifecycles, pods...)
+	}
+	return lifecycles
+}
+
+func TestRecorderObjects(t *testing.T) {
+	recorder, _ := fakeRecorder()
+	startRecorder(t, recorder)
+
+	assert.Eventually(t, func() bool {
+		recorder.mutex.Lock()
+		defer recorder.mutex.Unlock()
+		return len(recorder.store.events) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	obj := recorder.store.events[0].AppliedObjs[0]
+	assert.Equal(t, testStartTs, recorder.store.events[0].Ts)
+	assert.Equal(t, deploymentGVK, obj.GroupVersionKind())
+	assert.Empty(t, obj.GetUID())
+	assert.Empty(t, obj.GetResourceVersion())
+	assert.Empty(t, obj.GetAnnotations())
+	assert.Contains(t, recorder.store.index, "default/nginx")
+}
+
+func TestRecorderPodLifecycle(t *testing.T) {
+	recorder, clock := fakeRecorder(ownedPod("nginx-1", running(testStartTs)))
+	startRecorder(t, recorder)
+
+	assert.Eventually(t, func() bool {
+		return len(recordedLifecycles(recorder)) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []PodLifecycleData{{StartTs: testStartTs}}, recordedLifecycles(recorder))
+	owners, ok := recorder.owners.Lookup(testNamespace, "nginx-1")
+	assert.True(t, ok)
+	assert.Equal(t, []metav1.OwnerReference{
+		{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: testReplicaSetName},
+		deploymentOwnerRef(),
+	}, owners)
+
+	// The pod is deleted without its container terminating, so we use the current time
+	clock.Advance(time.Minute)
+	err := recorder.k8sClient.CoreV1().Pods(testNamespace).Delete(context.TODO(), "nginx-1", metav1.DeleteOptions{})
+	assert.Nil(t, err)
+	expected := []PodLifecycleData{{StartTs: testStartTs, EndTs: testStartTs + 60}}
+	assert.Eventually(t, func() bool {
+		lifecycles := recordedLifecycles(recorder)
+		return len(lifecycles) == 1 && lifecycles[0] == expected[0]
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRecorderPodApplied(t *testing.T) {
+	recorder, _ := fakeRecorder()
+	recorder.store.createOrUpdateObj(testDeploymentObj(1), testStartTs)
+
+	recorder.podApplied(context.TODO(), ownedPod("nginx-1", running(testStartTs)))
+	recorder.podApplied(context.TODO(), ownedPod("nginx-1", running(testStartTs+30)))
+	assert.Equal(t, []PodLifecycleData{{StartTs: testStartTs}}, recordedLifecycles(recorder))
+
+	recorder.podApplied(context.TODO(), ownedPod("nginx-1", terminated(testStartTs, testEndTs)))
+	assert.Equal(t, []PodLifecycleData{{StartTs: testStartTs, EndTs: testEndTs}}, recordedLifecycles(recorder))
+
+	// Finished pods have already been stored when they're deleted
+	recorder.podDeleted(context.TODO(), ownedPod("nginx-1", terminated(testStartTs, testEndTs)))
+	assert.Equal(t, []PodLifecycleData{{StartTs: testStartTs, EndTs: testEndTs}}, recordedLifecycles(recorder))
+	assert.Empty(t, recorder.ownedPods)
+	_, ok := recorder.owners.Lookup(testNamespace, "nginx-1")
+	assert.False(t, ok)
+}
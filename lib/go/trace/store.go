@@ -0,0 +1,245 @@
+package trace
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"simkube/lib/go/kueue"
+	"simkube/lib/go/volcano"
+)
+
+const hpaKind = "HorizontalPodAutoscaler"
+
+type podLifecycleIndex struct {
+	owner string
+	hash  uint64
+	seq   int
+}
+
+// The store is the in-memory trace, which works the same way as sk-tracer's trace store
+// (lib/rust/store): objects are only recorded when their spec changes, and the lifecycle
+// data for each pod is stored under its tracked owner, and the hash of its spec.
+type store struct {
+	config *TracerConfig
+	events []Event
+
+	// The index is the hash of the spec of every object that we're tracking, and the pod
+	// lifecycle data is the owner's namespaced name -> pod spec hash -> lifecycle of each pod
+	// (podIndex is where each pod is in the lifecycle data, so we can update it later).  The
+	// hashes in the index are only used to spot changes; the exported index has the spec hashes.
+	index         map[string]uint64
+	podLifecycles map[string]map[uint64][]PodLifecycleData
+	podIndex      map[string]podLifecycleIndex
+}
+
+func newStore(config *TracerConfig) *store {
+	return &store{
+		config:        config,
+		index:         map[string]uint64{},
+		podLifecycles: map[string]map[uint64][]PodLifecycleData{},
+		podIndex:      map[string]podLifecycleIndex{},
+	}
+}
+
+func (self *store) createOrUpdateObj(obj *unstructured.Unstructured, ts int64) {
+	nsName := namespacedName(obj.GetNamespace(), obj.GetName())
+	hash := ChangeHash(obj)
+	if oldHash, ok := self.index[nsName]; !ok || oldHash != hash {
+		self.appendEvent(ts, obj, false)
+	}
+	self.index[nsName] = hash
+}
+
+func (self *store) deleteObj(obj *unstructured.Unstructured, ts int64) {
+	self.appendEvent(ts, obj, true)
+	delete(self.index, namespacedName(obj.GetNamespace(), obj.GetName()))
+}
+
+func (self *store) appendEvent(ts int64, obj *unstructured.Unstructured, deleted bool) {
+	if len(self.events) == 0 || self.events[len(self.events)-1].Ts != ts {
+		self.events = append(self.events, Event{Ts: ts})
+	}
+
+	evt := &self.events[len(self.events)-1]
+	if deleted {
+		evt.DeletedObjs = append(evt.DeletedObjs, obj)
+	} else {
+		evt.AppliedObjs = append(evt.AppliedObjs, obj)
+	}
+}
+
+// recordPodLifecycle stores the lifecycle data for a pod under the first of its owners that
+// we're tracking the lifecycle for; once it's stored, later updates go to the same place.
+func (self *store) recordPodLifecycle(pod *corev1.Pod, owners []metav1.OwnerReference, data PodLifecycleData) error {
+	nsName := namespacedName(pod.ObjectMeta.Namespace, pod.ObjectMeta.Name)
+	if idx, ok := self.podIndex[nsName]; ok {
+		self.podLifecycles[idx.owner][idx.hash][idx.seq] = data
+		return nil
+	}
+
+	for _, rf := range owners {
+		ownerNsName := namespacedName(pod.ObjectMeta.Namespace, rf.Name)
+		if _, ok := self.index[ownerNsName]; !ok {
+			continue
+		}
+
+		gv, err := schema.ParseGroupVersion(rf.APIVersion)
+		if err != nil {
+			return fmt.Errorf("invalid owner reference for %s: %w", nsName, err)
+		} else if !self.config.trackLifecycleFor(gv.WithKind(rf.Kind)) {
+			continue
+		}
+
+		hash, err := StablePodSpecHash(pod)
+		if err != nil {
+			return fmt.Errorf("could not hash pod %s: %w", nsName, err)
+		}
+		if _, ok := self.podLifecycles[ownerNsName]; !ok {
+			self.podLifecycles[ownerNsName] = map[uint64][]PodLifecycleData{}
+		}
+		pods := append(self.podLifecycles[ownerNsName][hash], data)
+		self.podLifecycles[ownerNsName][hash] = pods
+		self.podIndex[nsName] = podLifecycleIndex{owner: ownerNsName, hash: hash, seq: len(pods) - 1}
+		break
+	}
+	return nil
+}
+
+// collectEvents returns the events in [startTs, endTs), and the index of every object that
+// was in the cluster during that window; the first event is the state of the cluster at
+// startTs, i.e., all of the objects that were applied (and not deleted) before then.
+func (self *store) collectEvents(startTs, endTs int64) ([]Event, map[string]uint64) {
+	events := []Event{{Ts: startTs}}
+	initialObjs := map[string]*unstructured.Unstructured{}
+	index := map[string]uint64{}
+	for _, evt := range self.events {
+		if evt.Ts >= endTs {
+			break
+		}
+
+		for _, obj := range evt.AppliedObjs {
+			nsName := namespacedName(obj.GetNamespace(), obj.GetName())
+			if evt.Ts < startTs {
+				initialObjs[nsName] = obj
+			}
+			index[nsName] = SpecHash(obj)
+		}
+		for _, obj := range evt.DeletedObjs {
+			if evt.Ts < startTs {
+				delete(initialObjs, namespacedName(obj.GetNamespace(), obj.GetName()))
+			}
+		}
+
+		if evt.Ts >= startTs {
+			events = append(events, evt)
+		}
+	}
+
+	names := make([]string, 0, len(initialObjs))
+	for nsName := range initialObjs {
+		names = append(names, nsName)
+	}
+	sort.Strings(names)
+	for _, nsName := range names {
+		events[0].AppliedObjs = append(events[0].AppliedObjs, initialObjs[nsName])
+	}
+	return events, index
+}
+
+// export writes the trace for [startTs, endTs) as a msgpack-encoded 4-tuple of the tracer
+// config, the events, the index, and the pod lifecycle data (see docs/sk-tracer.md)
+func (self *store) export(startTs, endTs int64) ([]byte, error) {
+	events, index := self.collectEvents(startTs, endTs)
+
+	// Only the lifecycle data for pods that ran during the trace is exported
+	podLifecycles := map[string]map[uint64][]PodLifecycleData{}
+	for owner, lifecycles := range self.podLifecycles {
+		if _, ok := index[owner]; !ok {
+			continue
+		}
+
+		ownerLifecycles := map[uint64][]PodLifecycleData{}
+		for hash, pods := range lifecycles {
+			for _, data := range pods {
+				if data.overlaps(startTs, endTs) {
+					ownerLifecycles[hash] = append(ownerLifecycles[hash], data)
+				}
+			}
+		}
+		if len(ownerLifecycles) > 0 {
+			podLifecycles[owner] = ownerLifecycles
+		}
+	}
+
+	var buf bytes.Buffer
+	trace := &Trace{
+		Version:       CurrentTraceFormat,
+		Config:        self.config,
+		Events:        events,
+		Index:         index,
+		PodLifecycles: podLifecycles,
+	}
+	if err := WriteTrace(&buf, trace); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SpecHash is the hash that's stored in the index; the index only tracks changes to the
+// object's spec, not its status or metadata
+func SpecHash(obj *unstructured.Unstructured) uint64 {
+	return hashJSON(obj.Object["spec"])
+}
+
+// ChangeHash is what we compare to decide whether the object has changed (and so whether it
+// gets recorded again); that's normally just the spec, plus the RecordedStatusField if the
+// object has one
+func ChangeHash(obj *unstructured.Unstructured) uint64 {
+	statusField := RecordedStatusField(obj)
+	if statusField == "" {
+		return SpecHash(obj)
+	}
+	status, _, _ := unstructured.NestedFieldNoCopy(obj.Object, "status", statusField)
+	return hashJSON([]interface{}{obj.Object["spec"], status})
+}
+
+// RecordedStatusField returns the field of the object's status whose changes are recorded in
+// the trace, or "" if only changes to the spec are.  The interesting part of the history of Kueue
+// Workloads (whether, and where, they were admitted), Volcano PodGroups (whether the gang was
+// scheduled), and HPAs (the values of the metrics they scale on, which sk-cloudprov can serve in
+// the simulation) is in their status.
+func RecordedStatusField(obj *unstructured.Unstructured) string {
+	switch gvk := obj.GroupVersionKind(); {
+	case kueue.IsWorkload(gvk):
+		return "admission"
+	case volcano.IsPodGroup(gvk):
+		return "phase"
+	case gvk.Group == autoscalingv2.GroupName && gvk.Kind == hpaKind:
+		return "currentMetrics"
+	default:
+		return ""
+	}
+}
+
+// Cluster-scoped objects are just identified by their name in the trace
+func namespacedName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return fmt.Sprintf("%s/%s", namespace, name)
+}
+
+func splitNamespacedName(nsName string) (string, string) {
+	if namespace, name, ok := strings.Cut(nsName, "/"); ok {
+		return namespace, name
+	}
+	return "", nsName
+}
@@ -0,0 +1,189 @@
+package trace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	testNamespace      = "default"
+	testDeploymentName = "nginx"
+	testReplicaSetName = "nginx-abcde"
+)
+
+func testDeploymentObj(replicas int64) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"namespace": testNamespace, "name": testDeploymentName},
+		"spec":       map[string]interface{}{"replicas": replicas},
+	}}
+}
+
+func deploymentOwnerRef() metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: appsv1.SchemeGroupVersion.String(),
+		Kind:       "Deployment",
+		Name:       testDeploymentName,
+	}
+}
+
+func TestStoreCreateOrUpdateObj(t *testing.T) {
+	s := newStore(DefaultTracerConfig())
+	s.createOrUpdateObj(testDeploymentObj(1), 1)
+
+	// Status or metadata changes don't create a new event
+	updated := testDeploymentObj(1)
+	updated.SetLabels(map[string]string{"foo": "bar"})
+	s.createOrUpdateObj(updated, 2)
+	assert.Len(t, s.events, 1)
+
+	s.createOrUpdateObj(testDeploymentObj(2), 3)
+	s.deleteObj(testDeploymentObj(2), 3)
+	assert.Len(t, s.events, 2)
+	assert.Len(t, s.events[1].AppliedObjs, 1)
+	assert.Len(t, s.events[1].DeletedObjs, 1)
+	assert.Empty(t, s.index)
+}
+
+func TestStoreCreateOrUpdateStatusChanges(t *testing.T) {
+	cases := map[string]struct {
+		apiVersion  string
+		kind        string
+		statusField string
+		statuses    []interface{}
+	}{
+		"workload admission": {
+			apiVersion:  "kueue.x-k8s.io/v1beta1",
+			kind:        "Workload",
+			statusField: "admission",
+			statuses: []interface{}{
+				nil,
+				map[string]interface{}{"clusterQueue": "cluster-queue"},
+				map[string]interface{}{"clusterQueue": "cluster-queue"},
+				nil,
+			},
+		},
+		"podgroup phase": {
+			apiVersion:  "scheduling.volcano.sh/v1beta1",
+			kind:        "PodGroup",
+			statusField: "phase",
+			statuses:    []interface{}{"Pending", "Running", "Running", "Completed"},
+		},
+		"hpa metrics": {
+			apiVersion:  "autoscaling/v2",
+			kind:        "HorizontalPodAutoscaler",
+			statusField: "currentMetrics",
+			statuses: []interface{}{
+				[]interface{}{map[string]interface{}{"type": "External"}},
+				[]interface{}{map[string]interface{}{"type": "External", "value": "4"}},
+				[]interface{}{map[string]interface{}{"type": "External", "value": "4"}},
+				[]interface{}{map[string]interface{}{"type": "External", "value": "5"}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			makeObj := func(status interface{}) *unstructured.Unstructured {
+				obj := &unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": tc.apiVersion,
+					"kind":       tc.kind,
+					"metadata":   map[string]interface{}{"namespace": testNamespace, "name": "foo"},
+					"spec":       map[string]interface{}{"queueName": "batch"},
+				}}
+				if status != nil {
+					obj.Object["status"] = map[string]interface{}{tc.statusField: status}
+				}
+				return obj
+			}
+
+			s := newStore(DefaultTracerConfig())
+			for i, status := range tc.statuses {
+				s.createOrUpdateObj(makeObj(status), int64(i+1))
+			}
+			assert.Len(t, s.events, 3)
+
+			// The index still only has the spec hash
+			_, index := s.collectEvents(0, 5)
+			assert.Equal(t, SpecHash(makeObj(nil)), index[testNamespace+"/foo"])
+		})
+	}
+}
+
+func TestStoreCollectEvents(t *testing.T) {
+	other := testDeploymentObj(1)
+	other.SetName("other")
+
+	s := newStore(DefaultTracerConfig())
+	s.createOrUpdateObj(testDeploymentObj(1), 1)
+	s.createOrUpdateObj(other, 2)
+	s.createOrUpdateObj(testDeploymentObj(2), 3)
+	s.deleteObj(other, 4)
+	s.createOrUpdateObj(testDeploymentObj(3), 10)
+	s.deleteObj(testDeploymentObj(3), 15)
+
+	events, index := s.collectEvents(3, 10)
+	assert.Len(t, events, 3)
+
+	// The first event is everything that existed at the start of the trace
+	assert.Equal(t, int64(3), events[0].Ts)
+	assert.Equal(t, []*unstructured.Unstructured{testDeploymentObj(1), other}, events[0].AppliedObjs)
+	assert.Equal(t, int64(3), events[1].Ts)
+	assert.Equal(t, int64(4), events[2].Ts)
+
+	// Deleted objects stay in the index, since they were in the cluster during the trace
+	assert.Equal(t, map[string]uint64{
+		"default/nginx": SpecHash(testDeploymentObj(2)),
+		"default/other": SpecHash(other),
+	}, index)
+}
+
+func TestStoreRecordPodLifecycle(t *testing.T) {
+	rsRef := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: testReplicaSetName}
+	owners := []metav1.OwnerReference{rsRef, deploymentOwnerRef()}
+	pod := testPod(nil, running(testStartTs))
+	podHash, err := StablePodSpecHash(pod)
+	assert.Nil(t, err)
+
+	s := newStore(DefaultTracerConfig())
+
+	// The pod's owner isn't in the trace
+	assert.Nil(t, s.recordPodLifecycle(pod, owners, PodLifecycleData{StartTs: testStartTs}))
+	assert.Empty(t, s.podLifecycles)
+
+	s.createOrUpdateObj(testDeploymentObj(1), 1)
+	assert.Nil(t, s.recordPodLifecycle(pod, owners, PodLifecycleData{StartTs: testStartTs}))
+	assert.Nil(t, s.recordPodLifecycle(pod, nil, PodLifecycleData{StartTs: testStartTs, EndTs: testEndTs}))
+
+	other := testPod(nil, running(testStartTs))
+	other.ObjectMeta.Name = "other-pod"
+	assert.Nil(t, s.recordPodLifecycle(other, owners, PodLifecycleData{StartTs: testStartTs + 5}))
+
+	assert.Equal(t, map[string]map[uint64][]PodLifecycleData{
+		"default/nginx": {podHash: {
+			{StartTs: testStartTs, EndTs: testEndTs},
+			{StartTs: testStartTs + 5},
+		}},
+	}, s.podLifecycles)
+}
+
+func TestStoreExport(t *testing.T) {
+	s := newStore(&TracerConfig{TrackedObjects: map[string]TrackedObjectConfig{}})
+	data, err := s.export(0, 10)
+	assert.Nil(t, err)
+
+	// [{"trackedObjects": {}}, [{"applied_objs": [], "deleted_objs": [], "ts": 0}], {}, {}]
+	expected := []byte{0x94, 0x81, 0xae}
+	expected = append(expected, "trackedObjects"...)
+	expected = append(expected, 0x80, 0x91, 0x83, 0xac)
+	expected = append(expected, "applied_objs"...)
+	expected = append(expected, 0x90, 0xac)
+	expected = append(expected, "deleted_objs"...)
+	expected = append(expected, 0x90, 0xa2, 't', 's', 0x00, 0x80, 0x80)
+	assert.Equal(t, expected, data)
+}
@@ -0,0 +1,105 @@
+package trace
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Trace files don't have a version number in them; the only format so far is the 4-tuple
+// that sk-tracer exports (see docs/sk-tracer.md), so we tell formats apart by their shape,
+// and any format that changes the shape will get a new version here.
+const (
+	TraceFormatV1      = 1
+	CurrentTraceFormat = TraceFormatV1
+
+	traceFormatV1Len = 4
+)
+
+// A Trace is the full contents of a trace file: the config that the trace was recorded with,
+// the events, the index (the namespaced name -> spec hash of every object in the trace), and
+// the pod lifecycle data (the owner's namespaced name -> pod spec hash -> lifecycle of each
+// pod that the owner created).
+type Trace struct {
+	Version       int
+	Config        *TracerConfig
+	Events        []Event
+	Index         map[string]uint64
+	PodLifecycles map[string]map[uint64][]PodLifecycleData
+}
+
+func (self *Trace) HasObj(nsName string) bool {
+	_, ok := self.Index[nsName]
+	return ok
+}
+
+// LookupPodLifecycle returns the lifecycle of the seq'th pod with the given hash that the owner
+// created; like sk-driver, if the owner creates more pods than were in the trace, we wrap around.
+func (self *Trace) LookupPodLifecycle(ownerNsName string, hash uint64, seq int) PodLifecycleData {
+	pods := self.PodLifecycles[ownerNsName][hash]
+	if len(pods) == 0 {
+		return PodLifecycleData{}
+	}
+	return pods[seq%len(pods)]
+}
+
+// An Event is everything that changed in the cluster at one time (in unix seconds)
+type Event struct {
+	Ts          int64
+	AppliedObjs []*unstructured.Unstructured
+	DeletedObjs []*unstructured.Unstructured
+}
+
+func (self *Event) toMsgpack() map[string]interface{} {
+	return map[string]interface{}{
+		"ts":           self.Ts,
+		"applied_objs": objsToMsgpack(self.AppliedObjs),
+		"deleted_objs": objsToMsgpack(self.DeletedObjs),
+	}
+}
+
+func eventFromMsgpack(v interface{}) (Event, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return Event{}, fmt.Errorf("invalid event: %v", v)
+	}
+	ts, ok := m["ts"].(int64)
+	if !ok {
+		return Event{}, fmt.Errorf("invalid event timestamp: %v", m["ts"])
+	}
+
+	applied, err := objsFromMsgpack(m["applied_objs"])
+	if err != nil {
+		return Event{}, fmt.Errorf("invalid applied objects at %d: %w", ts, err)
+	}
+	deleted, err := objsFromMsgpack(m["deleted_objs"])
+	if err != nil {
+		return Event{}, fmt.Errorf("invalid deleted objects at %d: %w", ts, err)
+	}
+	return Event{Ts: ts, AppliedObjs: applied, DeletedObjs: deleted}, nil
+}
+
+func objsToMsgpack(objs []*unstructured.Unstructured) []interface{} {
+	msgObjs := make([]interface{}, 0, len(objs))
+	for _, obj := range objs {
+		msgObjs = append(msgObjs, obj.Object)
+	}
+	return msgObjs
+}
+
+func objsFromMsgpack(v interface{}) ([]*unstructured.Unstructured, error) {
+	msgObjs, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected array, got %v", v)
+	}
+
+	var objs []*unstructured.Unstructured
+	for _, msgObj := range msgObjs {
+		obj, ok := msgObj.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected object, got %v", msgObj)
+		}
+		objs = append(objs, &unstructured.Unstructured{Object: obj})
+	}
+	return objs, nil
+}
@@ -0,0 +1,42 @@
+package trace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testTrace() *Trace {
+	return &Trace{
+		Version: CurrentTraceFormat,
+		Config:  DefaultTracerConfig(),
+		Events: []Event{
+			{Ts: 0, AppliedObjs: []*unstructured.Unstructured{testDeploymentObj(1)}},
+			{Ts: 5, AppliedObjs: []*unstructured.Unstructured{testDeploymentObj(2)}},
+			{Ts: 10, DeletedObjs: []*unstructured.Unstructured{testDeploymentObj(2)}},
+		},
+		Index: map[string]uint64{"default/nginx": SpecHash(testDeploymentObj(2))},
+		PodLifecycles: map[string]map[uint64][]PodLifecycleData{
+			"default/nginx": {
+				1234:    {{StartTs: testStartTs, EndTs: testEndTs}, {StartTs: testStartTs + 5}},
+				1 << 63: {{StartTs: testEndTs}},
+			},
+		},
+	}
+}
+
+func TestTraceLookupPodLifecycle(t *testing.T) {
+	trace := testTrace()
+	assert.True(t, trace.HasObj("default/nginx"))
+	assert.False(t, trace.HasObj("default/other"))
+
+	assert.Equal(t, PodLifecycleData{StartTs: testStartTs + 5}, trace.LookupPodLifecycle("default/nginx", 1234, 1))
+
+	// There are only two pods with this hash, so the third pod gets the first pod's lifecycle
+	expected := PodLifecycleData{StartTs: testStartTs, EndTs: testEndTs}
+	assert.Equal(t, expected, trace.LookupPodLifecycle("default/nginx", 1234, 2))
+
+	assert.Equal(t, PodLifecycleData{}, trace.LookupPodLifecycle("default/nginx", 5678, 0))
+	assert.Equal(t, PodLifecycleData{}, trace.LookupPodLifecycle("default/other", 1234, 0))
+}
@@ -0,0 +1,101 @@
+package trace
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A Writer writes a trace one event at a time, in the current trace format; since msgpack
+// arrays are prefixed by their length, the number of events has to be known up front.  The
+// index and the pod lifecycle data come after the events, and are written by Finish.
+type Writer struct {
+	w   io.Writer
+	enc msgpackEncoder
+
+	numEvents int
+	written   int
+}
+
+func NewWriter(w io.Writer, config *TracerConfig, numEvents int) (*Writer, error) {
+	writer := &Writer{w: w, numEvents: numEvents}
+	writer.enc.encodeArrayLen(traceFormatV1Len)
+	if err := writer.enc.encode(config.toMsgpack()); err != nil {
+		return nil, fmt.Errorf("could not encode tracer config: %w", err)
+	}
+	writer.enc.encodeArrayLen(numEvents)
+	if err := writer.flush(); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (self *Writer) WriteEvent(evt *Event) error {
+	if self.written == self.numEvents {
+		return fmt.Errorf("could not write event at %d: trace only has %d events", evt.Ts, self.numEvents)
+	}
+
+	if err := self.enc.encode(evt.toMsgpack()); err != nil {
+		return fmt.Errorf("could not encode event at %d: %w", evt.Ts, err)
+	}
+	self.written++
+	return self.flush()
+}
+
+func (self *Writer) Finish(index map[string]uint64, podLifecycles map[string]map[uint64][]PodLifecycleData) error {
+	if self.written != self.numEvents {
+		return fmt.Errorf("could not finish trace: wrote %d of %d events", self.written, self.numEvents)
+	}
+
+	msgIndex := map[string]interface{}{}
+	for nsName, hash := range index {
+		msgIndex[nsName] = hash
+	}
+
+	msgLifecycles := map[string]interface{}{}
+	for owner, lifecycles := range podLifecycles {
+		ownerLifecycles := map[uint64]interface{}{}
+		for hash, pods := range lifecycles {
+			podData := make([]interface{}, 0, len(pods))
+			for _, data := range pods {
+				podData = append(podData, data.toMsgpack())
+			}
+			ownerLifecycles[hash] = podData
+		}
+		msgLifecycles[owner] = ownerLifecycles
+	}
+
+	if err := self.enc.encode(msgIndex); err != nil {
+		return fmt.Errorf("could not encode index: %w", err)
+	}
+	if err := self.enc.encode(msgLifecycles); err != nil {
+		return fmt.Errorf("could not encode pod lifecycle data: %w", err)
+	}
+	return self.flush()
+}
+
+func (self *Writer) flush() error {
+	if _, err := self.w.Write(self.enc.buf); err != nil {
+		return fmt.Errorf("could not write trace: %w", err)
+	}
+	self.enc.buf = self.enc.buf[:0]
+	return nil
+}
+
+// WriteTrace writes the whole trace in the current format, regardless of its version
+func WriteTrace(w io.Writer, trace *Trace) error {
+	if trace.Config == nil {
+		return errors.New("could not write trace: no tracer config")
+	}
+
+	writer, err := NewWriter(w, trace.Config, len(trace.Events))
+	if err != nil {
+		return err
+	}
+	for i := range trace.Events {
+		if err := writer.WriteEvent(&trace.Events[i]); err != nil {
+			return err
+		}
+	}
+	return writer.Finish(trace.Index, trace.PodLifecycles)
+}
@@ -0,0 +1,31 @@
+package trace
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriterEventCount(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, DefaultTracerConfig(), 1)
+	assert.Nil(t, err)
+
+	// Finishing early would write a corrupt trace
+	assert.NotNil(t, writer.Finish(nil, nil))
+	assert.Nil(t, writer.WriteEvent(&Event{Ts: 1}))
+	assert.NotNil(t, writer.WriteEvent(&Event{Ts: 2}))
+	assert.Nil(t, writer.Finish(nil, nil))
+
+	trace, err := ReadTrace(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, []Event{{Ts: 1}}, trace.Events)
+	assert.Empty(t, trace.Index)
+	assert.Empty(t, trace.PodLifecycles)
+}
+
+func TestWriteTraceNoConfig(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NotNil(t, WriteTrace(&buf, &Trace{}))
+}
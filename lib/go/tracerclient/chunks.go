@@ -0,0 +1,108 @@
+package tracerclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/trace"
+)
+
+type timeSlice struct {
+	startTs int64
+	endTs   int64
+}
+
+// ExportChunked exports the trace one time slice at a time, for time windows that are too big
+// to export in a single request, and joins the slices back together into a single trace (see
+// trace.JoinChunks); up to parallelism slices are fetched at the same time.
+func (self *Client) ExportChunked(
+	ctx context.Context,
+	req *simkubev1.ExportRequest,
+	chunkSize time.Duration,
+	parallelism int,
+) (*trace.Trace, error) {
+	chunkSecs := int64(chunkSize.Seconds())
+	if chunkSecs <= 0 {
+		return nil, errors.New("chunk size must be at least one second")
+	}
+
+	var slices []timeSlice
+	for sliceStart := req.StartTs; sliceStart < req.EndTs; sliceStart += chunkSecs {
+		sliceEnd := sliceStart + chunkSecs
+		if sliceEnd > req.EndTs {
+			sliceEnd = req.EndTs
+		}
+		slices = append(slices, timeSlice{sliceStart, sliceEnd})
+	}
+	return self.exportSlices(ctx, req.Filters, slices, parallelism)
+}
+
+// ExportParallel splits the time window into parallelism slices of (about) the same size, and
+// fetches them all at the same time; each slice is at least one second long, so short time
+// windows may be split into fewer slices
+func (self *Client) ExportParallel(
+	ctx context.Context,
+	req *simkubev1.ExportRequest,
+	parallelism int,
+) (*trace.Trace, error) {
+	span := req.EndTs - req.StartTs
+	if span <= 0 {
+		return nil, fmt.Errorf("invalid time window: %d-%d", req.StartTs, req.EndTs)
+	} else if parallelism < 1 {
+		return nil, fmt.Errorf("parallelism must be at least 1: %d", parallelism)
+	}
+
+	n := int64(parallelism)
+	if n > span {
+		n = span
+	}
+	slices := make([]timeSlice, 0, n)
+	for i := int64(0); i < n; i++ {
+		slices = append(slices, timeSlice{req.StartTs + span*i/n, req.StartTs + span*(i+1)/n})
+	}
+	return self.exportSlices(ctx, req.Filters, slices, parallelism)
+}
+
+// exportSlices fetches the slices, up to parallelism at a time, and joins them in order; if
+// any of the slices fail, the other requests are cancelled
+func (self *Client) exportSlices(
+	ctx context.Context,
+	filters simkubev1.ExportFilters,
+	slices []timeSlice,
+	parallelism int,
+) (*trace.Trace, error) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	chunks := make([]*trace.Trace, len(slices))
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(parallelism)
+	for i, slice := range slices {
+		i, slice := i, slice
+		group.Go(func() error {
+			data, err := self.Export(groupCtx, simkubev1.NewExportRequest(slice.startTs, slice.endTs, filters))
+			if err != nil {
+				return fmt.Errorf("could not export %d-%d: %w", slice.startTs, slice.endTs, err)
+			}
+			if chunks[i], err = trace.ReadTrace(bytes.NewReader(data)); err != nil {
+				return fmt.Errorf("could not parse trace data for %d-%d: %w", slice.startTs, slice.endTs, err)
+			}
+			return nil
+		})
+	}
+
+	//nolint:wrapcheck // the errors are wrapped in the goroutines
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	//nolint:wrapcheck // this is just a passthrough
+	return trace.JoinChunks(chunks)
+}
@@ -0,0 +1,213 @@
+package tracerclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/trace"
+)
+
+const (
+	defaultRetries    = 3
+	defaultRetryDelay = 500 * time.Millisecond
+)
+
+// Options configures the client: Address is the tracer's base URL (e.g.,
+// http://localhost:7777), and Timeout is how long each request is allowed to take (0 means
+// no timeout, since exports of long time windows can take a while).  Failed requests are
+// retried Retries times (0 means the default of 3, and a negative number turns retries off),
+// starting RetryDelay apart and doubling after every attempt.
+type Options struct {
+	Address    string
+	Timeout    time.Duration
+	Retries    int
+	RetryDelay time.Duration
+
+	TLS TLSOptions
+
+	// If BearerTokenFile is set, every request is sent with the token in the file, e.g., for
+	// tracers that are behind an authenticating proxy
+	BearerTokenFile string
+}
+
+// TLSOptions configures TLS for https tracer addresses: CAFile is used to verify the tracer's
+// certificate (instead of the system CAs), and if CertFile and KeyFile are set, the client
+// presents that certificate to the tracer (i.e., mutual TLS)
+type TLSOptions struct {
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// A Client talks to sk-tracer's HTTP API
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	token      string
+
+	retries    int
+	retryDelay time.Duration
+}
+
+func New(opts Options) (*Client, error) {
+	baseURL, err := url.Parse(opts.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tracer address %s: %w", opts.Address, err)
+	} else if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid tracer address %s: must be an http or https URL", opts.Address)
+	}
+
+	tlsConfig, err := opts.TLS.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	//nolint:errcheck // the default transport is always an *http.Transport
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	client := &Client{
+		baseURL:    baseURL,
+		httpClient: &http.Client{Transport: transport, Timeout: opts.Timeout},
+		retries:    opts.Retries,
+		retryDelay: opts.RetryDelay,
+	}
+	if client.retries == 0 {
+		client.retries = defaultRetries
+	} else if client.retries < 0 {
+		client.retries = 0
+	}
+	if client.retryDelay == 0 {
+		client.retryDelay = defaultRetryDelay
+	}
+
+	if opts.BearerTokenFile != "" {
+		token, err := os.ReadFile(opts.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read bearer token: %w", err)
+		}
+		client.token = strings.TrimSpace(string(token))
+	}
+	return client, nil
+}
+
+// Export returns the trace data for the request, exactly as the tracer sent it
+func (self *Client) Export(ctx context.Context, req *simkubev1.ExportRequest) ([]byte, error) {
+	body, err := req.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal export request: %w", err)
+	}
+	return self.do(ctx, http.MethodPost, "export", body)
+}
+
+// Health returns nil if the tracer is up and serving requests
+func (self *Client) Health(ctx context.Context) error {
+	_, err := self.do(ctx, http.MethodGet, "health", nil)
+	return err
+}
+
+// Config returns the tracer config that the tracer is running with
+func (self *Client) Config(ctx context.Context) (*trace.TracerConfig, error) {
+	body, err := self.do(ctx, http.MethodGet, "config", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var config trace.TracerConfig
+	if err := json.Unmarshal(body, &config); err != nil {
+		return nil, fmt.Errorf("could not parse tracer config: %w", err)
+	}
+	return &config, nil
+}
+
+func (self *Client) do(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	delay := self.retryDelay
+	for attempt := 0; ; attempt++ {
+		respBody, err := self.doOnce(ctx, method, path, body)
+		if err == nil || attempt >= self.retries || !IsRetriable(err) || ctx.Err() != nil {
+			return respBody, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%s %s: %w", method, path, ctx.Err())
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (self *Client) doOnce(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+
+	endpoint := self.baseURL.JoinPath(path).String()
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if self.token != "" {
+		req.Header.Set("Authorization", "Bearer "+self.token)
+	}
+
+	resp, err := self.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, endpoint, err)
+	}
+	defer func() {
+		//nolint:errcheck // there's nothing to do if closing the body fails
+		_ = resp.Body.Close()
+	}()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response from %s: %w", endpoint, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(respBody))}
+	}
+	return respBody, nil
+}
+
+func (self *TLSOptions) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if self.CAFile != "" {
+		caBytes, err := os.ReadFile(self.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not open %s: %w", self.CAFile, err)
+		}
+
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certificates found in %s", self.CAFile)
+		}
+		config.RootCAs = rootCAs
+	}
+
+	if (self.CertFile == "") != (self.KeyFile == "") {
+		return nil, errors.New("both a client certificate and key are required for mutual TLS")
+	} else if self.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(self.CertFile, self.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
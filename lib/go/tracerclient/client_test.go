@@ -0,0 +1,268 @@
+package tracerclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	simkubev1 "simkube/lib/go/api/v1"
+	"simkube/lib/go/testutils/faketracer"
+	"simkube/lib/go/trace"
+	"simkube/lib/go/util"
+)
+
+const testToken = "s3cr3t"
+
+func newFakeTracer(failures int, delay time.Duration) *faketracer.Tracer {
+	return &faketracer.Tracer{Token: testToken, Failures: failures, Delay: delay}
+}
+
+func newTestClient(t *testing.T, addr string, opts Options) *Client {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.Nil(t, os.WriteFile(tokenFile, []byte(testToken+"\n"), 0600))
+
+	opts.Address = addr
+	opts.BearerTokenFile = tokenFile
+	opts.RetryDelay = time.Millisecond
+	client, err := New(opts)
+	require.Nil(t, err)
+	return client
+}
+
+func testExportRequest(startTs, endTs int64) *simkubev1.ExportRequest {
+	return simkubev1.NewExportRequest(startTs, endTs, *simkubev1.NewExportFilters(nil, nil, true))
+}
+
+func TestClientExport(t *testing.T) {
+	tracer := newFakeTracer(0, 0)
+	srv := httptest.NewServer(tracer)
+	defer srv.Close()
+	client := newTestClient(t, srv.URL, Options{})
+
+	data, err := client.Export(context.Background(), testExportRequest(10, 20))
+	require.Nil(t, err)
+	tr, err := trace.ReadTrace(bytes.NewReader(data))
+	require.Nil(t, err)
+	assert.Equal(t, int64(10), tr.Events[0].Ts)
+	assert.Equal(t, []*simkubev1.ExportRequest{testExportRequest(10, 20)}, tracer.Requests())
+}
+
+func TestClientExportBadRequest(t *testing.T) {
+	tracer := newFakeTracer(0, 0)
+	srv := httptest.NewServer(tracer)
+	defer srv.Close()
+	client := newTestClient(t, srv.URL, Options{})
+
+	_, err := client.Export(context.Background(), testExportRequest(20, 10))
+	assert.True(t, IsBadRequest(err))
+	assert.True(t, util.IsValidation(err))
+	assert.ErrorContains(t, err, "invalid export request")
+
+	// Bad requests aren't retried
+	assert.Equal(t, 1, tracer.Calls())
+}
+
+func TestClientRetries(t *testing.T) {
+	for name, tc := range map[string]struct {
+		failures      int
+		retries       int
+		expectedCalls int
+		expectedErr   bool
+	}{
+		"succeeds after retrying": {failures: 2, expectedCalls: 3},
+		"out of retries":          {failures: 5, expectedCalls: 4, expectedErr: true},
+		"retries disabled":        {failures: 1, retries: -1, expectedCalls: 1, expectedErr: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tracer := newFakeTracer(tc.failures, 0)
+			srv := httptest.NewServer(tracer)
+			defer srv.Close()
+			client := newTestClient(t, srv.URL, Options{Retries: tc.retries})
+
+			err := client.Health(context.Background())
+			assert.Equal(t, tc.expectedCalls, tracer.Calls())
+			if tc.expectedErr {
+				assert.True(t, IsRetriable(err))
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestClientRetriesCancelled(t *testing.T) {
+	tracer := newFakeTracer(5, 0)
+	srv := httptest.NewServer(tracer)
+	defer srv.Close()
+	client := newTestClient(t, srv.URL, Options{})
+	client.retryDelay = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := client.Health(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, tracer.Calls())
+}
+
+func TestClientConfig(t *testing.T) {
+	tracer := newFakeTracer(0, 0)
+	tracer.Config = &trace.TracerConfig{TrackedObjects: map[string]trace.TrackedObjectConfig{
+		"apps/v1.Deployment": {PodSpecTemplatePath: "/spec/template"},
+	}}
+	srv := httptest.NewServer(tracer)
+	defer srv.Close()
+	client := newTestClient(t, srv.URL, Options{})
+
+	config, err := client.Config(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, &trace.TracerConfig{TrackedObjects: map[string]trace.TrackedObjectConfig{
+		"apps/v1.Deployment": {PodSpecTemplatePath: "/spec/template"},
+	}}, config)
+}
+
+func TestClientTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(newFakeTracer(0, 0))
+	defer srv.Close()
+
+	// Without the tracer's CA, the certificate can't be verified
+	client := newTestClient(t, srv.URL, Options{Retries: -1})
+	assert.Error(t, client.Health(context.Background()))
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.Nil(t, os.WriteFile(caFile, caPEM, 0600))
+	client = newTestClient(t, srv.URL, Options{TLS: TLSOptions{CAFile: caFile}})
+	assert.Nil(t, client.Health(context.Background()))
+}
+
+func TestNewErrors(t *testing.T) {
+	for name, opts := range map[string]Options{
+		"invalid address":    {Address: "localhost:7777"},
+		"missing key":        {Address: "https://localhost:7777", TLS: TLSOptions{CertFile: "tls.crt"}},
+		"missing CA file":    {Address: "https://localhost:7777", TLS: TLSOptions{CAFile: "/does/not/exist"}},
+		"missing token file": {Address: "http://localhost:7777", BearerTokenFile: "/does/not/exist"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(opts)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestClientExportChunked(t *testing.T) {
+	tracer := newFakeTracer(0, 0)
+	srv := httptest.NewServer(tracer)
+	defer srv.Close()
+	client := newTestClient(t, srv.URL, Options{})
+
+	tr, err := client.ExportChunked(context.Background(), testExportRequest(100, 225), 50*time.Second, 1)
+	require.Nil(t, err)
+	assert.Equal(t, []*simkubev1.ExportRequest{
+		testExportRequest(100, 150),
+		testExportRequest(150, 200),
+		testExportRequest(200, 225),
+	}, tracer.Requests())
+
+	// The later chunks' starting events are dropped
+	assert.Len(t, tr.Events, 1)
+	assert.Equal(t, int64(100), tr.Events[0].Ts)
+
+	_, err = client.ExportChunked(context.Background(), testExportRequest(100, 225), time.Millisecond, 1)
+	assert.Error(t, err)
+}
+
+func TestClientExportChunkedParallel(t *testing.T) {
+	tracer := newFakeTracer(0, 20*time.Millisecond)
+	srv := httptest.NewServer(tracer)
+	defer srv.Close()
+	client := newTestClient(t, srv.URL, Options{})
+
+	tr, err := client.ExportChunked(context.Background(), testExportRequest(0, 100), 10*time.Second, 3)
+	require.Nil(t, err)
+	assert.Len(t, tracer.Requests(), 10)
+	assert.Equal(t, 3, tracer.MaxInFlight())
+
+	// The chunks are joined in time order, no matter what order they finished in
+	assert.Len(t, tr.Events, 1)
+	assert.Equal(t, int64(0), tr.Events[0].Ts)
+}
+
+func TestClientExportParallel(t *testing.T) {
+	for name, tc := range map[string]struct {
+		startTs, endTs   int64
+		parallelism      int
+		expectedRequests []*simkubev1.ExportRequest
+	}{
+		"even split": {
+			startTs:     0,
+			endTs:       300,
+			parallelism: 3,
+			expectedRequests: []*simkubev1.ExportRequest{
+				testExportRequest(0, 100),
+				testExportRequest(100, 200),
+				testExportRequest(200, 300),
+			},
+		},
+		"uneven split": {
+			startTs:     10,
+			endTs:       20,
+			parallelism: 3,
+			expectedRequests: []*simkubev1.ExportRequest{
+				testExportRequest(10, 13),
+				testExportRequest(13, 16),
+				testExportRequest(16, 20),
+			},
+		},
+		"more slices than seconds": {
+			startTs:     10,
+			endTs:       12,
+			parallelism: 4,
+			expectedRequests: []*simkubev1.ExportRequest{
+				testExportRequest(10, 11),
+				testExportRequest(11, 12),
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tracer := newFakeTracer(0, 20*time.Millisecond)
+			srv := httptest.NewServer(tracer)
+			defer srv.Close()
+			client := newTestClient(t, srv.URL, Options{})
+
+			req := testExportRequest(tc.startTs, tc.endTs)
+			tr, err := client.ExportParallel(context.Background(), req, tc.parallelism)
+			require.Nil(t, err)
+			assert.ElementsMatch(t, tc.expectedRequests, tracer.Requests())
+			assert.Equal(t, len(tc.expectedRequests), tracer.MaxInFlight())
+			assert.Equal(t, tc.startTs, tr.Events[0].Ts)
+		})
+	}
+}
+
+func TestClientExportParallelErrors(t *testing.T) {
+	tracer := newFakeTracer(0, 0)
+	srv := httptest.NewServer(tracer)
+	defer srv.Close()
+	client := newTestClient(t, srv.URL, Options{})
+
+	_, err := client.ExportParallel(context.Background(), testExportRequest(20, 10), 2)
+	assert.ErrorContains(t, err, "invalid time window")
+
+	_, err = client.ExportParallel(context.Background(), testExportRequest(10, 20), 0)
+	assert.ErrorContains(t, err, "parallelism must be at least 1")
+
+	// A slice that fails cancels the whole export
+	failingSrv := httptest.NewServer(newFakeTracer(100, 0))
+	defer failingSrv.Close()
+	client = newTestClient(t, failingSrv.URL, Options{Retries: -1})
+	_, err = client.ExportParallel(context.Background(), testExportRequest(10, 20), 2)
+	assert.True(t, IsRetriable(err))
+}
@@ -0,0 +1,62 @@
+package tracerclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+
+	"simkube/lib/go/util"
+)
+
+// An APIError is returned when the tracer responds with anything other than 200 OK; the
+// message is the body of the response, which is the reason that the tracer gave (e.g., that
+// an included kind isn't being tracked)
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (self *APIError) Error() string {
+	return fmt.Sprintf("tracer returned %d %s: %s", self.StatusCode, http.StatusText(self.StatusCode), self.Message)
+}
+
+// Is lets callers check an APIError against the error kinds in util (e.g., with util.IsNotFound)
+func (self *APIError) Is(target error) bool {
+	switch target {
+	case util.ErrValidation:
+		return self.StatusCode == http.StatusBadRequest
+	case util.ErrNotFound:
+		return self.StatusCode == http.StatusNotFound
+	case util.ErrConflict:
+		return self.StatusCode == http.StatusConflict
+	case util.ErrRetriable:
+		return self.StatusCode >= http.StatusInternalServerError || self.StatusCode == http.StatusTooManyRequests
+	default:
+		return false
+	}
+}
+
+// IsBadRequest returns true if the tracer rejected the request, e.g., because of invalid filters
+func IsBadRequest(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest
+}
+
+// IsRetriable returns true for errors that are likely to go away if the request is made again:
+// server errors, throttling, timeouts, and dropped or refused connections (e.g., while the
+// tracer is restarting)
+func IsRetriable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= http.StatusInternalServerError || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	var netErr net.Error
+	return (errors.As(err, &netErr) && netErr.Timeout()) ||
+		utilnet.IsConnectionReset(err) ||
+		utilnet.IsConnectionRefused(err) ||
+		utilnet.IsProbableEOF(err)
+}
@@ -3,4 +3,46 @@ package util
 const (
 	NodeGroupNameLabel      = "simkube.io/node-group"
 	NodeGroupNamespaceLabel = "simkube.io/node-group-namespace"
+
+	NodeTemplateAnnotation = "simkube.io/node-template"
+
+	// NodeGroupMinSizeAnnotation and NodeGroupMaxSizeAnnotation set the scaling bounds of
+	// a node group Deployment
+	NodeGroupMinSizeAnnotation = "simkube.io/min-size"
+	NodeGroupMaxSizeAnnotation = "simkube.io/max-size"
+
+	// InstanceTypeAnnotation and ZoneAnnotation set the instance type and the zone
+	// (<region>/<zone>) of the virtual nodes in a node group, unless the skeleton does
+	InstanceTypeAnnotation = "simkube.io/instance-type"
+	ZoneAnnotation         = "simkube.io/zone"
+
+	// InstanceCPUAnnotation and InstanceMemoryAnnotation set the capacity of the node group's
+	// instance type, unless the skeleton does
+	InstanceCPUAnnotation    = "simkube.io/instance-cpu"
+	InstanceMemoryAnnotation = "simkube.io/instance-memory"
+
+	// NodeLabelsAnnotation gives the virtual nodes in a node group extra labels (a comma-separated
+	// list of key=value pairs), unless the skeleton sets them
+	NodeLabelsAnnotation = "simkube.io/node-labels"
+
+	// NodeGroupPriorityAnnotation is the node group's priority for Cluster Autoscaler's priority
+	// expander (higher is preferred)
+	NodeGroupPriorityAnnotation = "simkube.io/priority"
+
+	// NodeLifetimeAnnotation can be set on the node skeleton or the node group Deployment to
+	// terminate virtual nodes after a fixed number of seconds
+	NodeLifetimeAnnotation = "simkube.io/node-lifetime-seconds"
+
+	// PodNameAnnotation is set on virtual nodes to record the name of the pod that is running
+	// the node, since the node name can be templated
+	PodNameAnnotation = "simkube.io/pod-name"
+
+	// GPULabel is set on virtual nodes that have GPUs; its value is the GPU type.  The
+	// GPU type of a node group can be set with an annotation on its Deployment.
+	GPULabel          = "simkube.io/gpu-type"
+	GPUTypeAnnotation = "simkube.io/gpu-type"
+
+	// ScalingBackendAnnotation picks the backend that the cloud provider uses to scale a
+	// node group
+	ScalingBackendAnnotation = "simkube.io/scaling-backend"
 )
@@ -0,0 +1,43 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errControllerNotReady = WithKind(ErrRetriable, errors.New("controller is not ready"))
+
+// ControllerStatus is implemented by the virtual-kubelet node and pod controllers
+type ControllerStatus interface {
+	Ready() <-chan struct{}
+	Done() <-chan struct{}
+	Err() error
+}
+
+// ControllerRunning returns an error if the controller has stopped
+func ControllerRunning(name string, ctrl ControllerStatus) error {
+	select {
+	case <-ctrl.Done():
+		if err := ctrl.Err(); err != nil {
+			return fmt.Errorf("%s controller stopped: %w", name, err)
+		}
+		return fmt.Errorf("%s controller stopped", name)
+	default:
+		return nil
+	}
+}
+
+// ControllerReady returns an error unless the controller has finished starting up and is still
+// running
+func ControllerReady(name string, ctrl ControllerStatus) error {
+	if err := ControllerRunning(name, ctrl); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctrl.Ready():
+		return nil
+	default:
+		return fmt.Errorf("%s %w", name, errControllerNotReady)
+	}
+}
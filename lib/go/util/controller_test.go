@@ -0,0 +1,68 @@
+package util
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeController struct {
+	ready chan struct{}
+	done  chan struct{}
+	err   error
+}
+
+func (self *fakeController) Ready() <-chan struct{} { return self.ready }
+func (self *fakeController) Done() <-chan struct{}  { return self.done }
+func (self *fakeController) Err() error             { return self.err }
+
+func TestControllerStatus(t *testing.T) {
+	cases := map[string]struct {
+		ready           bool
+		done            bool
+		err             error
+		expectedRunning bool
+		expectedReady   bool
+	}{
+		"starting": {
+			expectedRunning: true,
+		},
+		"ready": {
+			ready:           true,
+			expectedRunning: true,
+			expectedReady:   true,
+		},
+		"stopped": {
+			ready: true,
+			done:  true,
+		},
+		"failed": {
+			done: true,
+			err:  errors.New("could not register node"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctrl := &fakeController{ready: make(chan struct{}), done: make(chan struct{}), err: tc.err}
+			if tc.ready {
+				close(ctrl.ready)
+			}
+			if tc.done {
+				close(ctrl.done)
+			}
+
+			running := ControllerRunning("node", ctrl)
+			ready := ControllerReady("node", ctrl)
+			assert.Equal(t, tc.expectedRunning, running == nil)
+			assert.Equal(t, tc.expectedReady, ready == nil)
+			if tc.err != nil {
+				assert.ErrorIs(t, running, tc.err)
+			}
+			if !tc.done && !tc.ready {
+				assert.True(t, IsRetriable(ready))
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package util
+
+import (
+	"errors"
+
+	vkerr "github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+// These are the kinds of errors that callers handle differently: whether to retry, which gRPC
+// code or HTTP status to return, and which exit code skctl uses.  Errors are marked with a kind
+// by WithKind (or by wrapping the kind with fmt.Errorf and %w), and checked with the Is*
+// functions below, which also understand the equivalent Kubernetes API errors.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrRetriable  = errors.New("temporary failure")
+	ErrValidation = errors.New("validation failed")
+)
+
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (self *kindError) Error() string {
+	return self.err.Error()
+}
+
+func (self *kindError) Unwrap() []error {
+	return []error{self.err, self.kind}
+}
+
+// WithKind marks err as one of the kinds above, without changing its message; errors.Is still
+// matches err itself, too
+func WithKind(kind error, err error) error {
+	return &kindError{kind: kind, err: err}
+}
+
+func IsNotFound(err error) bool {
+	// The virtual-kubelet errdefs helpers only follow Cause(), not %w, so we unwrap them ourselves
+	var vkNotFound vkerr.ErrNotFound
+	return errors.Is(err, ErrNotFound) ||
+		apierrors.IsNotFound(err) ||
+		(errors.As(err, &vkNotFound) && vkNotFound.NotFound())
+}
+
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict) || apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+}
+
+func IsValidation(err error) bool {
+	var vkInvalid vkerr.ErrInvalidInput
+	return errors.Is(err, ErrValidation) ||
+		apierrors.IsInvalid(err) ||
+		apierrors.IsBadRequest(err) ||
+		(errors.As(err, &vkInvalid) && vkInvalid.InvalidInput())
+}
+
+// IsRetriable returns true for errors that are likely to go away if the request is made
+// again, e.g., throttling, timeouts, or dropped connections; conflicts are also retriable,
+// so callers that update objects need to re-read them on every attempt.
+func IsRetriable(err error) bool {
+	return errors.Is(err, ErrRetriable) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsInternalError(err) ||
+		IsConflict(err) ||
+		utilnet.IsConnectionReset(err) ||
+		utilnet.IsConnectionRefused(err) ||
+		utilnet.IsProbableEOF(err)
+}
@@ -0,0 +1,56 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	vkerr "github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestWithKind(t *testing.T) {
+	errBase := errors.New("invalid fleet")
+	err := fmt.Errorf("%w: fleets must have a name", WithKind(ErrValidation, errBase))
+
+	assert.Equal(t, "invalid fleet: fleets must have a name", err.Error())
+	assert.ErrorIs(t, err, errBase)
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.True(t, IsValidation(err))
+	assert.False(t, IsNotFound(err))
+	assert.False(t, IsRetriable(err))
+}
+
+func TestErrorKinds(t *testing.T) {
+	gr := schema.GroupResource{Resource: "nodes"}
+	cases := map[string]struct {
+		err                error
+		expectedNotFound   bool
+		expectedConflict   bool
+		expectedValidation bool
+		expectedRetriable  bool
+	}{
+		"not found":        {err: fmt.Errorf("%w: foo", ErrNotFound), expectedNotFound: true},
+		"k8s not found":    {err: apierrors.NewNotFound(gr, "foo"), expectedNotFound: true},
+		"vk not found":     {err: vkerr.NotFound("pod not found"), expectedNotFound: true},
+		"k8s conflict":     {err: apierrors.NewConflict(gr, "foo", nil), expectedConflict: true, expectedRetriable: true},
+		"k8s exists":       {err: apierrors.NewAlreadyExists(gr, "foo"), expectedConflict: true, expectedRetriable: true},
+		"k8s invalid":      {err: apierrors.NewBadRequest("bad"), expectedValidation: true},
+		"vk invalid input": {err: vkerr.InvalidInput("bad"), expectedValidation: true},
+		"retriable":        {err: WithKind(ErrRetriable, errors.New("not running")), expectedRetriable: true},
+		"k8s throttled":    {err: apierrors.NewTooManyRequests("slow down", 1), expectedRetriable: true},
+		"other":            {err: errors.New("something went wrong")},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := fmt.Errorf("wrapped: %w", tc.err)
+			assert.Equal(t, tc.expectedNotFound, IsNotFound(err))
+			assert.Equal(t, tc.expectedConflict, IsConflict(err))
+			assert.Equal(t, tc.expectedValidation, IsValidation(err))
+			assert.Equal(t, tc.expectedRetriable, IsRetriable(err))
+		})
+	}
+}
@@ -1,13 +1,18 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
+	vklog "github.com/virtual-kubelet/virtual-kubelet/log"
+	vklogrus "github.com/virtual-kubelet/virtual-kubelet/log/logrus"
 )
 
+type loggerKey struct{}
+
 //nolint:gochecknoglobals
 var logLevels = []log.Level{
 	log.ErrorLevel,
@@ -15,19 +20,40 @@ var logLevels = []log.Level{
 	log.InfoLevel,
 }
 
-func GetLogger(nodeName string, extraFields ...string) *log.Entry {
-	fields := log.Fields{
+func GetLogger(nodeName string) *log.Entry {
+	return log.WithFields(log.Fields{
 		"provider": "simkube",
 		"nodeName": nodeName,
-	}
+	})
+}
+
+// WithLogger returns a copy of ctx that carries logger, so that everything down the call chain
+// logs with the same fields.  The logger is also stored for virtual-kubelet (see vklog.G), so
+// that its log messages have our fields too.
+func WithLogger(ctx context.Context, logger *log.Entry) context.Context {
+	ctx = vklog.WithLogger(ctx, vklogrus.FromLogrus(logger))
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// WithLogFields returns a copy of ctx whose logger has the extra fields (e.g., the name of the
+// pod that a request is about)
+func WithLogFields(ctx context.Context, fields log.Fields) context.Context {
+	return WithLogger(ctx, LoggerFromContext(ctx).WithFields(fields))
+}
 
-	for i := 0; i < len(extraFields); i += 2 {
-		fields[extraFields[i]] = extraFields[i+1]
+// LoggerFromContext returns the logger that was stored in ctx by WithLogger, or the standard
+// logger (without any fields) if there isn't one
+func LoggerFromContext(ctx context.Context) *log.Entry {
+	if logger, ok := ctx.Value(loggerKey{}).(*log.Entry); ok {
+		return logger
 	}
-	return log.WithFields(fields)
+	return log.NewEntry(log.StandardLogger())
 }
 
-func SetupLogging(level int, jsonLogs bool) {
+// SetupLogging configures the standard logger; if otlp has an endpoint (or one is set in the
+// environment), the logs are also exported to an OpenTelemetry collector.  The returned function
+// sends any logs that haven't been exported yet, and should be called before exiting.
+func SetupLogging(level int, jsonLogs bool, otlp OTLPLogOptions) func() {
 	prettyfier := func(f *runtime.Frame) (string, string) {
 		// Build with -trimpath to hide info about the devel environment
 		// Strip off the leading package name for "pretty" output
@@ -44,10 +70,15 @@ func SetupLogging(level int, jsonLogs bool) {
 		})
 	}
 
+	configuredLevel = verbosityLevel(level)
+	log.SetLevel(configuredLevel)
+	log.SetReportCaller(true)
+	return setupOTLPExport(otlp)
+}
+
+func verbosityLevel(level int) log.Level {
 	if level >= len(logLevels) {
-		log.SetLevel(log.DebugLevel)
-	} else {
-		log.SetLevel(logLevels[level])
+		return log.DebugLevel
 	}
-	log.SetReportCaller(true)
+	return logLevels[level]
 }
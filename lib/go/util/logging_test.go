@@ -0,0 +1,36 @@
+package util
+
+import (
+	"context"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	vklog "github.com/virtual-kubelet/virtual-kubelet/log"
+)
+
+func TestLoggerFromContext(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	ctx := WithLogger(context.Background(), GetLogger("test-node"))
+	ctx = WithLogFields(ctx, log.Fields{"podName": "default/foo"})
+
+	LoggerFromContext(ctx).Info("from simkube")
+	vklog.G(ctx).Info("from virtual-kubelet")
+
+	entries := hook.AllEntries()
+	if assert.Len(t, entries, 2) {
+		for _, entry := range entries {
+			assert.Equal(t, "test-node", entry.Data["nodeName"], entry.Message)
+			assert.Equal(t, "default/foo", entry.Data["podName"], entry.Message)
+		}
+	}
+}
+
+func TestLoggerFromContextDefault(t *testing.T) {
+	logger := LoggerFromContext(context.Background())
+	assert.Equal(t, log.StandardLogger(), logger.Logger)
+	assert.Empty(t, logger.Data)
+}
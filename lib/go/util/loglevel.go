@@ -0,0 +1,87 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// configuredLevel is the level from --verbosity, which SIGHUP switches back to after debug
+// logging has been turned on; it's only set by SetupLogging, before any signals are handled
+//
+//nolint:gochecknoglobals
+var configuredLevel = log.InfoLevel
+
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// ParseLogLevel accepts a level name (e.g., "debug" or "warn"), or a number, which is
+// interpreted the same way as --verbosity
+func ParseLogLevel(levelStr string) (log.Level, error) {
+	if verbosity, err := strconv.Atoi(levelStr); err == nil {
+		if verbosity < 0 {
+			return 0, fmt.Errorf("invalid verbosity %d", verbosity)
+		}
+		return verbosityLevel(verbosity), nil
+	}
+
+	//nolint:wrapcheck // the logrus error is already descriptive
+	return log.ParseLevel(levelStr)
+}
+
+// HandleLogLevelSignal turns on debug logging when the process gets a SIGHUP, and a second
+// SIGHUP switches back to the configured level; this lets us debug a live simulation without
+// restarting anything.
+func HandleLogLevelSignal() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	go func() {
+		for range sigs {
+			toggleDebugLogging()
+		}
+	}()
+}
+
+func toggleDebugLogging() {
+	level := log.DebugLevel
+	if log.GetLevel() == log.DebugLevel {
+		level = configuredLevel
+	}
+	log.SetLevel(level)
+	log.Infof("log level is now %s", level)
+}
+
+// HandleLogLevel is an HTTP handler that returns the current log level on GET, and changes
+// it on POST (the body is {"level": "<level>"}; see ParseLogLevel)
+func HandleLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(logLevelRequest{Level: log.GetLevel().String()}); err != nil {
+			log.WithError(err).Warn("could not write log level response")
+		}
+	case http.MethodPost:
+		var req logLevelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("could not parse request: %v", err), http.StatusBadRequest)
+			return
+		}
+		level, err := ParseLogLevel(req.Level)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.SetLevel(level)
+		log.Infof("log level is now %s", level)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,99 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	cases := map[string]struct {
+		str      string
+		expected log.Level
+	}{
+		"name":           {str: "debug", expected: log.DebugLevel},
+		"name uppercase": {str: "WARN", expected: log.WarnLevel},
+		"verbosity":      {str: "1", expected: log.WarnLevel},
+		"max verbosity":  {str: "5", expected: log.DebugLevel},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			level, err := ParseLogLevel(tc.str)
+			require.Nil(t, err)
+			assert.Equal(t, tc.expected, level)
+		})
+	}
+
+	for _, str := range []string{"asdf", "-1", ""} {
+		_, err := ParseLogLevel(str)
+		assert.NotNil(t, err, str)
+	}
+}
+
+func TestToggleDebugLogging(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+	defer func(level log.Level) { configuredLevel = level }(configuredLevel)
+
+	configuredLevel = log.WarnLevel
+	log.SetLevel(log.WarnLevel)
+	toggleDebugLogging()
+	assert.Equal(t, log.DebugLevel, log.GetLevel())
+	toggleDebugLogging()
+	assert.Equal(t, log.WarnLevel, log.GetLevel())
+}
+
+func TestHandleLogLevel(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel(log.InfoLevel)
+
+	cases := map[string]struct {
+		method        string
+		body          string
+		expectedCode  int
+		expectedLevel log.Level
+	}{
+		"get": {
+			method:        http.MethodGet,
+			expectedCode:  http.StatusOK,
+			expectedLevel: log.InfoLevel,
+		},
+		"set": {
+			method:        http.MethodPost,
+			body:          `{"level": "debug"}`,
+			expectedCode:  http.StatusNoContent,
+			expectedLevel: log.DebugLevel,
+		},
+		"invalid level": {
+			method:        http.MethodPost,
+			body:          `{"level": "loud"}`,
+			expectedCode:  http.StatusBadRequest,
+			expectedLevel: log.InfoLevel,
+		},
+		"wrong method": {
+			method:        http.MethodDelete,
+			expectedCode:  http.StatusMethodNotAllowed,
+			expectedLevel: log.InfoLevel,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			log.SetLevel(log.InfoLevel)
+			req := httptest.NewRequest(tc.method, "/loglevel", strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			HandleLogLevel(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, tc.expectedLevel, log.GetLevel())
+			if tc.method == http.MethodGet {
+				assert.JSONEq(t, `{"level": "info"}`, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package util
+
+import (
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LogSampler thins out the routine messages on high-volume code paths (e.g., the status of
+// every pod on a node, every few seconds): only 1 in every N messages for each key gets logged.
+// Warnings and errors are always logged.  A nil LogSampler (or a rate of 1) logs everything.
+type LogSampler struct {
+	rate   uint64
+	counts sync.Map // key -> *atomic.Uint64
+}
+
+func NewLogSampler(rate int) *LogSampler {
+	if rate < 1 {
+		rate = 1
+	}
+	return &LogSampler{rate: uint64(rate)}
+}
+
+// Sample returns logger if the next message for key should be logged, and otherwise a copy of
+// logger that drops everything below the warning level; the first message for each key is
+// always logged
+func (self *LogSampler) Sample(key string, logger *log.Entry) *log.Entry {
+	if self == nil || self.rate <= 1 {
+		return logger
+	}
+
+	value, _ := self.counts.LoadOrStore(key, new(atomic.Uint64))
+	if count, ok := value.(*atomic.Uint64); !ok || count.Add(1)%self.rate == 1 {
+		return logger
+	}
+	return &log.Entry{Logger: quietLogger(logger.Logger), Data: logger.Data, Context: logger.Context}
+}
+
+// quietLogger writes to the same place as logger, but only at the warning level or above
+func quietLogger(logger *log.Logger) *log.Logger {
+	level := logger.GetLevel()
+	if level > log.WarnLevel {
+		level = log.WarnLevel
+	}
+	return &log.Logger{
+		Out:          logger.Out,
+		Hooks:        logger.Hooks,
+		Formatter:    logger.Formatter,
+		ReportCaller: logger.ReportCaller,
+		Level:        level,
+		ExitFunc:     logger.ExitFunc,
+	}
+}
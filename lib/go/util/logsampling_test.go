@@ -0,0 +1,63 @@
+package util
+
+import (
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogSampler(t *testing.T) {
+	cases := map[string]struct {
+		sampler       *LogSampler
+		expectedInfos int
+	}{
+		"nil":      {expectedInfos: 10},
+		"rate 1":   {sampler: NewLogSampler(1), expectedInfos: 10},
+		"rate 0":   {sampler: NewLogSampler(0), expectedInfos: 10},
+		"rate 3":   {sampler: NewLogSampler(3), expectedInfos: 4},
+		"rate 100": {sampler: NewLogSampler(100), expectedInfos: 1},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			logger, hook := test.NewNullLogger()
+			entry := logger.WithField("podName", "default/foo")
+			for i := 0; i < 10; i++ {
+				tc.sampler.Sample("GetPodStatus", entry).Info("getting pod status")
+			}
+			tc.sampler.Sample("GetPodStatus", entry).Warn("something is off")
+
+			entries := hook.AllEntries()
+			assert.Len(t, entries, tc.expectedInfos+1)
+			for _, e := range entries {
+				assert.Equal(t, "default/foo", e.Data["podName"])
+			}
+			assert.Equal(t, log.WarnLevel, hook.LastEntry().Level)
+		})
+	}
+}
+
+func TestLogSamplerKeys(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	sampler := NewLogSampler(10)
+	sampler.Sample("GetPod", logger.WithFields(nil)).Info("getting pod")
+	sampler.Sample("GetPod", logger.WithFields(nil)).Info("getting pod")
+	sampler.Sample("GetPodStatus", logger.WithFields(nil)).Info("getting pod status")
+
+	assert.Len(t, hook.AllEntries(), 2)
+}
+
+func TestLogSamplerRespectsLevel(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(log.ErrorLevel)
+	sampler := NewLogSampler(2)
+	sampler.Sample("GetPod", logger.WithFields(nil)).Info("getting pod")
+	sampler.Sample("GetPod", logger.WithFields(nil)).Warn("something is off")
+	sampler.Sample("GetPod", logger.WithFields(nil)).Error("something is wrong")
+
+	if assert.Len(t, hook.AllEntries(), 1) {
+		assert.Equal(t, log.ErrorLevel, hook.LastEntry().Level)
+	}
+}
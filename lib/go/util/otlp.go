@@ -0,0 +1,433 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	otlpLogsEndpointEnv   = "OTEL_EXPORTER_OTLP_LOGS_ENDPOINT"
+	otlpLogsHeadersEnv    = "OTEL_EXPORTER_OTLP_LOGS_HEADERS"
+	otlpResourceAttrsEnv  = "OTEL_RESOURCE_ATTRIBUTES"
+	otlpScopeName         = "simkube"
+	otlpServiceNameKey    = "service.name"
+	otlpPodNameKey        = "k8s.pod.name"
+	otlpNamespaceKey      = "k8s.namespace.name"
+	otlpNodeNameKey       = "k8s.node.name"
+	otlpSimulationKey     = "simkube.simulation"
+	otlpDefaultBatchSize  = 512
+	otlpDefaultFlushEvery = 5 * time.Second
+	otlpMaxQueuedRecords  = 8192
+	otlpExportTimeout     = 10 * time.Second
+)
+
+// OTLPLogOptions configures SetupLogging to also send the logs to an OpenTelemetry collector,
+// using OTLP over HTTP with the JSON encoding.  The resource attributes identify where the logs
+// came from; the log fields become attributes of each log record.
+type OTLPLogOptions struct {
+	// The OTLP/HTTP logs endpoint, e.g., http://otel-collector:4318/v1/logs; if it's empty, the
+	// OTEL_EXPORTER_OTLP_LOGS_ENDPOINT environment variable is used, and if that's empty too,
+	// logs aren't exported
+	Endpoint string
+
+	// Extra headers to send with each request (e.g., for authentication); the ones in the
+	// OTEL_EXPORTER_OTLP_LOGS_HEADERS environment variable are added too
+	Headers map[string]string
+
+	// The component that's logging (e.g., sk-vnode), the node it's simulating, and the simulation
+	// it's part of, if known; the pod name and namespace come from the POD_NAME and POD_NAMESPACE
+	// environment variables, and any OTEL_RESOURCE_ATTRIBUTES are added as well
+	Component  string
+	NodeName   string
+	Simulation string
+
+	// Records are sent in batches of up to BatchSize, at least every FlushInterval
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string          `json:"timeUnixNano"`
+	ObservedTimeUnixNano string          `json:"observedTimeUnixNano"`
+	SeverityNumber       int             `json:"severityNumber"`
+	SeverityText         string          `json:"severityText"`
+	Body                 otlpValue       `json:"body"`
+	Attributes           []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope      map[string]string `json:"scope"`
+	LogRecords []otlpLogRecord   `json:"logRecords"`
+}
+
+type otlpResourceLogs struct {
+	Resource  map[string][]otlpAttribute `json:"resource"`
+	ScopeLogs []otlpScopeLogs            `json:"scopeLogs"`
+}
+
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+// otlpHook is a logrus hook that queues up every log entry, and exports them in batches from a
+// background goroutine, so that logging never waits on the collector.  If the collector can't
+// keep up, the oldest records are dropped.  Errors are written to stderr, since logging them
+// would just queue up more records.
+type otlpHook struct {
+	endpoint      string
+	headers       map[string]string
+	batchSize     int
+	flushInterval time.Duration
+	client        *http.Client
+
+	mutex    sync.Mutex
+	resource map[string]string
+	records  []otlpLogRecord
+	pending  []otlpBatch
+	dropped  int
+
+	flushNow chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+// otlpBatch is a set of records that were logged with the same resource attributes
+type otlpBatch struct {
+	resource []otlpAttribute
+	records  []otlpLogRecord
+}
+
+//nolint:gochecknoglobals
+var (
+	otlpHookMutex  sync.Mutex
+	activeOTLPHook *otlpHook
+)
+
+func newOTLPHook(opts OTLPLogOptions) *otlpHook {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv(otlpLogsEndpointEnv)
+	}
+	if endpoint == "" {
+		return nil
+	}
+
+	headers := parseKeyValues(os.Getenv(otlpLogsHeadersEnv))
+	for k, v := range opts.Headers {
+		headers[k] = v
+	}
+
+	resource := parseKeyValues(os.Getenv(otlpResourceAttrsEnv))
+	for k, v := range map[string]string{
+		otlpServiceNameKey: opts.Component,
+		otlpPodNameKey:     os.Getenv("POD_NAME"),
+		otlpNamespaceKey:   os.Getenv("POD_NAMESPACE"),
+		otlpNodeNameKey:    opts.NodeName,
+		otlpSimulationKey:  opts.Simulation,
+	} {
+		if v != "" {
+			resource[k] = v
+		}
+	}
+
+	hook := &otlpHook{
+		endpoint:      endpoint,
+		headers:       headers,
+		batchSize:     opts.BatchSize,
+		flushInterval: opts.FlushInterval,
+		client:        &http.Client{Timeout: otlpExportTimeout},
+		resource:      resource,
+		flushNow:      make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	if hook.batchSize <= 0 {
+		hook.batchSize = otlpDefaultBatchSize
+	}
+	if hook.flushInterval <= 0 {
+		hook.flushInterval = otlpDefaultFlushEvery
+	}
+	return hook
+}
+
+// setupOTLPExport replaces the OTLP hook on the standard logger (if any) with a new one, and
+// returns a function that sends everything that's still queued and stops exporting
+func setupOTLPExport(opts OTLPLogOptions) func() {
+	hook := newOTLPHook(opts)
+
+	otlpHookMutex.Lock()
+	defer otlpHookMutex.Unlock()
+	if activeOTLPHook != nil {
+		activeOTLPHook.shutdown()
+		hooks := log.LevelHooks{}
+		for level, levelHooks := range log.StandardLogger().Hooks {
+			for _, h := range levelHooks {
+				if h != activeOTLPHook {
+					hooks[level] = append(hooks[level], h)
+				}
+			}
+		}
+		log.StandardLogger().ReplaceHooks(hooks)
+	}
+
+	activeOTLPHook = hook
+	if hook == nil {
+		return func() {}
+	}
+	log.AddHook(hook)
+	go hook.run()
+	return hook.shutdown
+}
+
+// SetLogNodeName sets the node name on the exported logs, for components that don't know it
+// when logging is set up (i.e., sk-vnode, which might have to render it from a template); it does
+// nothing if logs aren't being exported
+func SetLogNodeName(nodeName string) {
+	otlpHookMutex.Lock()
+	hook := activeOTLPHook
+	otlpHookMutex.Unlock()
+	if hook == nil {
+		return
+	}
+
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+	if len(hook.records) > 0 {
+		// The queued records were logged with the old resource, so they're set aside with it
+		hook.pending = append(hook.pending, otlpBatch{resource: hook.resourceAttributes(), records: hook.records})
+		hook.records = nil
+	}
+	hook.resource[otlpNodeNameKey] = nodeName
+}
+
+func (self *otlpHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (self *otlpHook) Fire(entry *log.Entry) error {
+	record := otlpLogRecord{
+		TimeUnixNano:         strconv.FormatInt(entry.Time.UnixNano(), 10),
+		ObservedTimeUnixNano: strconv.FormatInt(time.Now().UnixNano(), 10),
+		SeverityNumber:       otlpSeverity(entry.Level),
+		SeverityText:         strings.ToUpper(entry.Level.String()),
+		Body:                 otlpStringValue(entry.Message),
+		Attributes:           otlpAttributes(entry),
+	}
+
+	self.mutex.Lock()
+	if len(self.records) >= otlpMaxQueuedRecords {
+		self.records = self.records[1:]
+		self.dropped++
+	}
+	self.records = append(self.records, record)
+	if len(self.records) >= self.batchSize {
+		select {
+		case self.flushNow <- struct{}{}:
+		default:
+		}
+	}
+	self.mutex.Unlock()
+
+	// log.Fatal calls os.Exit as soon as the hooks have fired, which skips the deferred flush in
+	// the commands, so everything that's queued (including this record) is sent before returning;
+	// a panic might be recovered, so exporting carries on after it's been sent
+	switch entry.Level {
+	case log.FatalLevel:
+		self.shutdown()
+	case log.PanicLevel:
+		self.flush()
+	}
+	return nil
+}
+
+func (self *otlpHook) run() {
+	defer close(self.stopped)
+	ticker := time.NewTicker(self.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-self.stop:
+			self.flush()
+			return
+		case <-ticker.C:
+			self.flush()
+		case <-self.flushNow:
+			self.flush()
+		}
+	}
+}
+
+func (self *otlpHook) shutdown() {
+	self.stopOnce.Do(func() { close(self.stop) })
+	<-self.stopped
+}
+
+// flush sends everything that's queued; the records are taken off the queue first, so that
+// logging doesn't wait for the collector
+func (self *otlpHook) flush() {
+	self.mutex.Lock()
+	batches := self.pending
+	if len(self.records) > 0 {
+		batches = append(batches, otlpBatch{resource: self.resourceAttributes(), records: self.records})
+	}
+	self.pending, self.records = nil, nil
+	dropped := self.dropped
+	self.dropped = 0
+	self.mutex.Unlock()
+
+	if dropped > 0 {
+		fmt.Fprintf(os.Stderr, "dropped %d log records that couldn't be exported in time\n", dropped)
+	}
+	for _, batch := range batches {
+		for start := 0; start < len(batch.records); start += self.batchSize {
+			end := start + self.batchSize
+			if end > len(batch.records) {
+				end = len(batch.records)
+			}
+			if err := self.export(batch.resource, batch.records[start:end]); err != nil {
+				fmt.Fprintf(os.Stderr, "could not export %d log records: %s\n", end-start, err)
+			}
+		}
+	}
+}
+
+func (self *otlpHook) export(resource []otlpAttribute, records []otlpLogRecord) error {
+	body, err := json.Marshal(otlpLogsRequest{ResourceLogs: []otlpResourceLogs{{
+		Resource:  map[string][]otlpAttribute{"attributes": resource},
+		ScopeLogs: []otlpScopeLogs{{Scope: map[string]string{"name": otlpScopeName}, LogRecords: records}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("could not encode log records: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), otlpExportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, self.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range self.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := self.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not send log records: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (self *otlpHook) resourceAttributes() []otlpAttribute {
+	attrs := make([]otlpAttribute, 0, len(self.resource))
+	for k, v := range self.resource {
+		attrs = append(attrs, otlpAttribute{Key: k, Value: otlpStringValue(v)})
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
+	return attrs
+}
+
+// otlpSeverity maps the logrus levels onto the OpenTelemetry severity numbers
+func otlpSeverity(level log.Level) int {
+	switch level {
+	case log.TraceLevel:
+		return 1
+	case log.DebugLevel:
+		return 5
+	case log.InfoLevel:
+		return 9
+	case log.WarnLevel:
+		return 13
+	case log.ErrorLevel:
+		return 17
+	case log.FatalLevel:
+		return 21
+	case log.PanicLevel:
+		return 24
+	default:
+		return 0
+	}
+}
+
+func otlpAttributes(entry *log.Entry) []otlpAttribute {
+	attrs := make([]otlpAttribute, 0, len(entry.Data)+3)
+	for k, v := range entry.Data {
+		attrs = append(attrs, otlpAttribute{Key: k, Value: otlpAnyValue(v)})
+	}
+	if entry.HasCaller() {
+		attrs = append(attrs,
+			otlpAttribute{Key: "code.function", Value: otlpStringValue(entry.Caller.Function)},
+			otlpAttribute{Key: "code.filepath", Value: otlpStringValue(entry.Caller.File)},
+			otlpAttribute{Key: "code.lineno", Value: otlpAnyValue(entry.Caller.Line)},
+		)
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
+	return attrs
+}
+
+func otlpAnyValue(v interface{}) otlpValue {
+	switch val := v.(type) {
+	case bool:
+		return otlpValue{BoolValue: &val}
+	case int:
+		s := strconv.FormatInt(int64(val), 10)
+		return otlpValue{IntValue: &s}
+	case int32:
+		s := strconv.FormatInt(int64(val), 10)
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(val, 10)
+		return otlpValue{IntValue: &s}
+	case float64:
+		return otlpValue{DoubleValue: &val}
+	case error:
+		return otlpStringValue(val.Error())
+	default:
+		return otlpStringValue(fmt.Sprint(val))
+	}
+}
+
+func otlpStringValue(s string) otlpValue {
+	return otlpValue{StringValue: &s}
+}
+
+// parseKeyValues parses the key1=value1,key2=value2 format of the OpenTelemetry environment
+// variables; entries without an = are ignored
+func parseKeyValues(s string) map[string]string {
+	kvs := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if k, v, ok := strings.Cut(pair, "="); ok && strings.TrimSpace(k) != "" {
+			kvs[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return kvs
+}
@@ -0,0 +1,163 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCollector struct {
+	mutex    sync.Mutex
+	requests []otlpLogsRequest
+	headers  []http.Header
+}
+
+func (self *fakeCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req otlpLogsRequest
+	if body, err := io.ReadAll(r.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.requests = append(self.requests, req)
+	self.headers = append(self.headers, r.Header)
+}
+
+func attributeMap(attrs []otlpAttribute) map[string]otlpValue {
+	m := map[string]otlpValue{}
+	for _, a := range attrs {
+		m[a.Key] = a.Value
+	}
+	return m
+}
+
+func TestOTLPExport(t *testing.T) {
+	collector := &fakeCollector{}
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+
+	t.Setenv(otlpResourceAttrsEnv, "deployment.environment=test, team = sim")
+	t.Setenv(otlpLogsHeadersEnv, "Authorization=Bearer abc")
+	t.Setenv("POD_NAME", "sk-vnode-abc")
+	oldHooks := log.StandardLogger().ReplaceHooks(log.LevelHooks{})
+	defer log.StandardLogger().ReplaceHooks(oldHooks)
+
+	shutdown := setupOTLPExport(OTLPLogOptions{
+		Endpoint:   srv.URL + "/v1/logs",
+		Component:  "sk-vnode",
+		Simulation: "test-sim",
+	})
+	log.WithFields(log.Fields{"podName": "default/foo", "delta": 2}).Info("created pod")
+	SetLogNodeName("test-node")
+	log.WithError(errors.New("boom")).Warn("something is off")
+	shutdown()
+	defer setupOTLPExport(OTLPLogOptions{})
+
+	require.Len(t, collector.requests, 2)
+	assert.Equal(t, "Bearer abc", collector.headers[0].Get("Authorization"))
+	assert.Equal(t, "application/json", collector.headers[0].Get("Content-Type"))
+
+	first := collector.requests[0].ResourceLogs[0]
+	resource := attributeMap(first.Resource["attributes"])
+	assert.Equal(t, "sk-vnode", *resource[otlpServiceNameKey].StringValue)
+	assert.Equal(t, "sk-vnode-abc", *resource[otlpPodNameKey].StringValue)
+	assert.Equal(t, "test-sim", *resource[otlpSimulationKey].StringValue)
+	assert.Equal(t, "test", *resource["deployment.environment"].StringValue)
+	assert.Equal(t, "sim", *resource["team"].StringValue)
+	assert.NotContains(t, resource, otlpNodeNameKey)
+
+	require.Len(t, first.ScopeLogs[0].LogRecords, 1)
+	record := first.ScopeLogs[0].LogRecords[0]
+	assert.Equal(t, "created pod", *record.Body.StringValue)
+	assert.Equal(t, 9, record.SeverityNumber)
+	assert.Equal(t, "INFO", record.SeverityText)
+	attrs := attributeMap(record.Attributes)
+	assert.Equal(t, "default/foo", *attrs["podName"].StringValue)
+	assert.Equal(t, "2", *attrs["delta"].IntValue)
+
+	second := collector.requests[1].ResourceLogs[0]
+	resource = attributeMap(second.Resource["attributes"])
+	assert.Equal(t, "test-node", *resource[otlpNodeNameKey].StringValue)
+	record = second.ScopeLogs[0].LogRecords[0]
+	assert.Equal(t, 13, record.SeverityNumber)
+	assert.Equal(t, "boom", *attributeMap(record.Attributes)[log.ErrorKey].StringValue)
+}
+
+func TestOTLPExportBatches(t *testing.T) {
+	collector := &fakeCollector{}
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+	oldHooks := log.StandardLogger().ReplaceHooks(log.LevelHooks{})
+	defer log.StandardLogger().ReplaceHooks(oldHooks)
+
+	shutdown := setupOTLPExport(OTLPLogOptions{Endpoint: srv.URL, BatchSize: 2})
+	for i := 0; i < 5; i++ {
+		log.Info("hello")
+	}
+	shutdown()
+	defer setupOTLPExport(OTLPLogOptions{})
+
+	total := 0
+	for _, req := range collector.requests {
+		n := len(req.ResourceLogs[0].ScopeLogs[0].LogRecords)
+		assert.LessOrEqual(t, n, 2)
+		total += n
+	}
+	assert.Equal(t, 5, total)
+}
+
+func TestOTLPExportFatal(t *testing.T) {
+	collector := &fakeCollector{}
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+	oldHooks := log.StandardLogger().ReplaceHooks(log.LevelHooks{})
+	defer log.StandardLogger().ReplaceHooks(oldHooks)
+
+	// The exit function runs right after the hooks, so nothing else gets a chance to flush
+	numExported := -1
+	log.StandardLogger().ExitFunc = func(int) {
+		collector.mutex.Lock()
+		defer collector.mutex.Unlock()
+		numExported = 0
+		for _, req := range collector.requests {
+			numExported += len(req.ResourceLogs[0].ScopeLogs[0].LogRecords)
+		}
+	}
+	defer func() { log.StandardLogger().ExitFunc = nil }()
+
+	setupOTLPExport(OTLPLogOptions{Endpoint: srv.URL, FlushInterval: time.Hour})
+	defer setupOTLPExport(OTLPLogOptions{})
+	log.Info("hello")
+
+	// Panics might be recovered, so the records are sent but exporting carries on
+	assert.Panics(t, func() { log.Panic("oh no") })
+	assert.Len(t, collector.requests, 1)
+
+	log.Info("still here")
+	log.Fatal("lost the leader election lease, exiting")
+	assert.Equal(t, 4, numExported)
+}
+
+func TestOTLPExportDisabled(t *testing.T) {
+	t.Setenv(otlpLogsEndpointEnv, "")
+	oldHooks := log.StandardLogger().ReplaceHooks(log.LevelHooks{})
+	defer log.StandardLogger().ReplaceHooks(oldHooks)
+
+	setupOTLPExport(OTLPLogOptions{})()
+	assert.Empty(t, log.StandardLogger().Hooks)
+	SetLogNodeName("test-node")
+}
@@ -0,0 +1,187 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const redactedValue = "[REDACTED]"
+
+// These are masked wherever they show up as (part of) a log field name, or as the key of a
+// key=value (or key: value) pair, an env var, or an annotation in a log message or field value
+//
+//nolint:gochecknoglobals
+var defaultRedactedKeys = []string{
+	"password",
+	"passwd",
+	"secret",
+	"token",
+	"apikey",
+	"api_key",
+	"api-key",
+	"authorization",
+	"credential",
+	"private_key",
+	"private-key",
+	"privatekey",
+}
+
+// RedactionOptions adds to the keys that are masked by default, and adds regular expressions
+// whose matches are masked, wherever they are
+type RedactionOptions struct {
+	Keys     []string
+	Patterns []string
+}
+
+// A Redactor is a logrus hook that masks sensitive values (e.g., the env vars of a pod) in every
+// log entry before it's written out or exported.  Keys are matched case-insensitively, anywhere
+// in the name (so "token" also masks "bearerToken" and "GITHUB_TOKEN").
+type Redactor struct {
+	keys     []string
+	keyed    []*regexp.Regexp
+	patterns []*regexp.Regexp
+}
+
+func NewRedactor(opts RedactionOptions) (*Redactor, error) {
+	keys := make([]string, 0, len(defaultRedactedKeys)+len(opts.Keys))
+	keys = append(keys, defaultRedactedKeys...)
+	for _, k := range opts.Keys {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			keys = append(keys, k)
+		}
+	}
+
+	quoted := make([]string, len(keys))
+	for i, k := range keys {
+		quoted[i] = regexp.QuoteMeta(k)
+	}
+	// A key can have an annotation-style prefix (e.g., vault.hashicorp.com/secret), but it can't be
+	// part of a file path, so that "open /run/secrets/token: permission denied" isn't mangled
+	keyRegex := fmt.Sprintf(`(?:[\w.-]+/)?[\w.-]*(?:%s)[\w.-]*`, strings.Join(quoted, "|"))
+
+	// Everything up to the end of the first group is kept, and the rest of the match (the value)
+	// is masked; quotes around the value are kept too
+	keyed := []*regexp.Regexp{
+		// Env vars, as Go structs ({Name:DB_PASSWORD Value:hunter2 ...}) and as JSON
+		regexp.MustCompile(`(?i)(?:^|\W)(Name:\s*` + keyRegex + `\s+Value:)\S*`),
+		regexp.MustCompile(`(?i)(?:^|\W)("name"\s*:\s*"` + keyRegex + `"\s*,\s*"value"\s*:\s*)"(?:[^"\\]|\\.)*"`),
+		// key=value, key: value, "key": "value", map[key:value], and Authorization: Bearer <token>
+		regexp.MustCompile(
+			`(?i)(?:^|[^\w./-])(` + keyRegex + `["']?\s*[=:]\s*)` +
+				`(?:"(?:[^"\\]|\\.)*"|'[^']*'|(?:bearer|basic)\s+[^\s"',;]+|[^\s"',;&\]}]+)`,
+		),
+		regexp.MustCompile(`(?i)(?:^|\W)(bearer\s+)[^\s"',;]+`),
+	}
+
+	var patterns []*regexp.Regexp
+	for _, p := range opts.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid redaction pattern %q: %w", ErrValidation, p, err)
+		}
+		patterns = append(patterns, re)
+	}
+
+	return &Redactor{keys: keys, keyed: keyed, patterns: patterns}, nil
+}
+
+// SetupRedaction masks sensitive values in everything that's logged with the standard logger;
+// the hook runs before any other hooks, so that nothing is exported before it's redacted
+func SetupRedaction(opts RedactionOptions) error {
+	redactor, err := NewRedactor(opts)
+	if err != nil {
+		return err
+	}
+
+	hooks := log.LevelHooks{}
+	hooks.Add(redactor)
+	for level, levelHooks := range log.StandardLogger().Hooks {
+		for _, h := range levelHooks {
+			if _, ok := h.(*Redactor); !ok {
+				hooks[level] = append(hooks[level], h)
+			}
+		}
+	}
+	log.StandardLogger().ReplaceHooks(hooks)
+	return nil
+}
+
+// RedactString masks the sensitive values in s; for the keys, only the value is masked (e.g.,
+// "password=[REDACTED]"), and for the custom patterns, the whole match is
+func (self *Redactor) RedactString(s string) string {
+	for _, re := range self.keyed {
+		s = redactValues(re, s)
+	}
+	for _, re := range self.patterns {
+		s = re.ReplaceAllString(s, redactedValue)
+	}
+	return s
+}
+
+func redactValues(re *regexp.Regexp, s string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(s, -1) {
+		valueStart, end := m[3], m[1]
+		b.WriteString(s[last:valueStart])
+		if value := s[valueStart:end]; len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+			b.WriteString(value[:1] + redactedValue + value[:1])
+		} else {
+			b.WriteString(redactedValue)
+		}
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+func (self *Redactor) isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range self.keys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *Redactor) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire redacts the entry in place; logrus copies the entry's fields before it runs the hooks, so
+// this doesn't affect the logger that the entry came from
+func (self *Redactor) Fire(entry *log.Entry) error {
+	entry.Message = self.RedactString(entry.Message)
+	for k, v := range entry.Data {
+		if self.isSensitiveKey(k) {
+			entry.Data[k] = redactedValue
+			continue
+		}
+
+		switch val := v.(type) {
+		case string:
+			entry.Data[k] = self.RedactString(val)
+		case error:
+			if s := self.RedactString(val.Error()); s != val.Error() {
+				entry.Data[k] = redactedError(s)
+			}
+		case bool, int, int32, int64, uint, uint32, uint64, float32, float64:
+		default:
+			// Anything else is written out with fmt, so it's only replaced if it needs redacting
+			if str := fmt.Sprintf("%+v", val); self.RedactString(str) != str {
+				entry.Data[k] = self.RedactString(str)
+			}
+		}
+	}
+	return nil
+}
+
+type redactedError string
+
+func (self redactedError) Error() string {
+	return string(self)
+}
@@ -2,37 +2,114 @@ package util
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jonboulle/clockwork"
 )
 
-const ISO8601DateTimeExtended = "2006-01-02T03:04:05"
+const ISO8601DateTimeExtended = "2006-01-02T15:04:05"
 
+// These are relative to the current time, in the timezone that the time is parsed in
+const (
+	TimeKeywordNow         = "now"
+	TimeKeywordToday       = "today"
+	TimeKeywordYesterday   = "yesterday"
+	TimeKeywordStartOfHour = "start-of-hour"
+)
+
+// ParseTimeStr parses an absolute or relative time: "now", "today" or "yesterday" (midnight at
+// the start of the day), "start-of-hour", an RFC3339 timestamp with an offset (e.g.,
+// 2023-10-19T01:04:32Z or 2023-10-19T01:04:32-07:00), a unix timestamp in seconds, a local
+// ISO-8601 extended timestamp (2023-10-19T01:04:32), or a duration (e.g., -15m or +2h) relative
+// to relTime (or to the current time, if relTime is zero).
 func ParseTimeStr(timeStr string, relTime time.Time) (time.Time, error) {
-	return parseTimeStrWithClock(timeStr, relTime, clockwork.NewRealClock())
+	return ParseTimeStrInLocation(timeStr, relTime, time.Local)
 }
 
-func parseTimeStrWithClock(timeStr string, relTime time.Time, clock clockwork.Clock) (time.Time, error) {
-	if timeStr == "now" {
-		return clock.Now(), nil
-	} else {
-		if res, parseErr1 := time.ParseInLocation(ISO8601DateTimeExtended, timeStr, time.Local); parseErr1 != nil {
-			delta, parseErr2 := time.ParseDuration(timeStr)
-			if parseErr2 != nil {
-				return time.Time{}, fmt.Errorf(
-					"could not parse time %s as absolute or relative time: %w, %w",
-					timeStr,
-					parseErr1,
-					parseErr2,
-				)
-			}
-			if relTime.IsZero() {
-				relTime = clock.Now()
-			}
-			return relTime.Add(delta), nil
-		} else {
-			return res, nil
+// ParseTimeStrInLocation is like ParseTimeStr, except that ISO-8601 timestamps without an
+// offset (and the days and hours that the keywords refer to) are in loc instead of in local time
+func ParseTimeStrInLocation(timeStr string, relTime time.Time, loc *time.Location) (time.Time, error) {
+	return parseTimeStrWithClock(timeStr, relTime, loc, clockwork.NewRealClock())
+}
+
+// ParseTimeRange parses the start and end of a time window: a duration in the start time is
+// relative to the end time (e.g., -30m is the 30 minutes before the end), unless the end time
+// is a forward duration (e.g., +2h), which is relative to the start time instead, so that
+// "yesterday" to "+24h" is all of yesterday.
+func ParseTimeRange(startStr, endStr string, loc *time.Location) (time.Time, time.Time, error) {
+	return parseTimeRangeWithClock(startStr, endStr, loc, clockwork.NewRealClock())
+}
+
+func parseTimeRangeWithClock(
+	startStr, endStr string,
+	loc *time.Location,
+	clock clockwork.Clock,
+) (time.Time, time.Time, error) {
+	if strings.HasPrefix(endStr, "+") {
+		startTime, err := parseTimeStrWithClock(startStr, time.Time{}, loc, clock)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("could not parse start time: %w", err)
+		}
+		endTime, err := parseTimeStrWithClock(endStr, startTime, loc, clock)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("could not parse end time: %w", err)
 		}
+		return startTime, endTime, nil
+	}
+
+	endTime, err := parseTimeStrWithClock(endStr, time.Time{}, loc, clock)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("could not parse end time: %w", err)
+	}
+	startTime, err := parseTimeStrWithClock(startStr, endTime, loc, clock)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("could not parse start time: %w", err)
+	}
+	return startTime, endTime, nil
+}
+
+func parseTimeStrWithClock(
+	timeStr string,
+	relTime time.Time,
+	loc *time.Location,
+	clock clockwork.Clock,
+) (time.Time, error) {
+	now := clock.Now().In(loc)
+	switch timeStr {
+	case TimeKeywordNow:
+		return clock.Now(), nil
+	case TimeKeywordToday:
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc), nil
+	case TimeKeywordYesterday:
+		return time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, loc), nil
+	case TimeKeywordStartOfHour:
+		return time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, loc), nil
+	}
+
+	if res, err := time.Parse(time.RFC3339, timeStr); err == nil {
+		return res, nil
+	}
+	if epoch, err := strconv.ParseUint(timeStr, 10, 63); err == nil {
+		return time.Unix(int64(epoch), 0).In(loc), nil
+	}
+
+	res, parseErr1 := time.ParseInLocation(ISO8601DateTimeExtended, timeStr, loc)
+	if parseErr1 == nil {
+		return res, nil
+	}
+	delta, parseErr2 := time.ParseDuration(timeStr)
+	if parseErr2 != nil {
+		return time.Time{}, fmt.Errorf(
+			"could not parse time %s as absolute or relative time: %w, %w",
+			timeStr,
+			parseErr1,
+			parseErr2,
+		)
+	}
+	if relTime.IsZero() {
+		relTime = clock.Now()
 	}
+	return relTime.Add(delta), nil
 }
@@ -6,12 +6,15 @@ import (
 
 	"github.com/jonboulle/clockwork"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseTimeStr(t *testing.T) {
+	pdt := time.FixedZone("PDT", -7*60*60)
 	cases := map[string]struct {
 		str      string
 		start    time.Time
+		loc      *time.Location
 		expected time.Time
 	}{
 		"now": {str: "now"},
@@ -28,19 +31,134 @@ func TestParseTimeStr(t *testing.T) {
 			str:      "2023-10-19T01:04:32",
 			expected: time.Date(2023, 10, 19, 01, 04, 32, 0, time.Local),
 		},
+		"abs time afternoon": {
+			str:      "2023-10-19T13:04:32",
+			expected: time.Date(2023, 10, 19, 13, 04, 32, 0, time.Local),
+		},
+		"abs time in location": {
+			str:      "2023-10-19T01:04:32",
+			loc:      pdt,
+			expected: time.Date(2023, 10, 19, 8, 04, 32, 0, time.UTC),
+		},
+		"rfc3339 utc": {
+			str:      "2023-10-19T01:04:32Z",
+			expected: time.Date(2023, 10, 19, 01, 04, 32, 0, time.UTC),
+		},
+		"rfc3339 offset": {
+			str:      "2023-10-19T01:04:32-07:00",
+			expected: time.Date(2023, 10, 19, 8, 04, 32, 0, time.UTC),
+		},
+		"rfc3339 ignores location": {
+			str:      "2023-10-19T01:04:32+02:00",
+			loc:      pdt,
+			expected: time.Date(2023, 10, 18, 23, 04, 32, 0, time.UTC),
+		},
+		"rfc3339 fractional seconds": {
+			str:      "2023-10-19T01:04:32.5Z",
+			expected: time.Date(2023, 10, 19, 01, 04, 32, 500_000_000, time.UTC),
+		},
+		"epoch": {
+			str:      "1697677472",
+			expected: time.Unix(1697677472, 0),
+		},
 	}
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
+			loc := tc.loc
+			if loc == nil {
+				loc = time.Local
+			}
 			c := clockwork.NewFakeClockAt(time.Time{})
-			res, err := parseTimeStrWithClock(tc.str, tc.start, c)
-			assert.Nil(t, err)
-			assert.Equal(t, res, tc.expected)
+			res, err := parseTimeStrWithClock(tc.str, tc.start, loc, c)
+			require.Nil(t, err)
+			assert.True(t, tc.expected.Equal(res), "expected %v, got %v", tc.expected, res)
 		})
 	}
 }
 
+func TestParseTimeStrKeywords(t *testing.T) {
+	pdt := time.FixedZone("PDT", -7*60*60)
+	c := clockwork.NewFakeClockAt(time.Date(2023, 10, 19, 2, 45, 10, 0, time.UTC))
+
+	cases := map[string]struct {
+		str      string
+		loc      *time.Location
+		expected time.Time
+	}{
+		"today":                 {str: "today", loc: time.UTC, expected: time.Date(2023, 10, 19, 0, 0, 0, 0, time.UTC)},
+		"yesterday":             {str: "yesterday", loc: time.UTC, expected: time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC)},
+		"today in location":     {str: "today", loc: pdt, expected: time.Date(2023, 10, 18, 0, 0, 0, 0, pdt)},
+		"yesterday in location": {str: "yesterday", loc: pdt, expected: time.Date(2023, 10, 17, 0, 0, 0, 0, pdt)},
+		"forward duration":      {str: "+2h", loc: time.UTC, expected: time.Date(2023, 10, 19, 4, 45, 10, 0, time.UTC)},
+		"start of hour": {
+			str:      "start-of-hour",
+			loc:      time.UTC,
+			expected: time.Date(2023, 10, 19, 2, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res, err := parseTimeStrWithClock(tc.str, time.Time{}, tc.loc, c)
+			require.Nil(t, err)
+			assert.True(t, tc.expected.Equal(res), "expected %v, got %v", tc.expected, res)
+		})
+	}
+}
+
+func TestParseTimeRange(t *testing.T) {
+	now := time.Date(2023, 10, 19, 2, 45, 10, 0, time.UTC)
+	c := clockwork.NewFakeClockAt(now)
+
+	cases := map[string]struct {
+		start, end                 string
+		expectedStart, expectedEnd time.Time
+	}{
+		"relative to end": {
+			start:         "-30m",
+			end:           "now",
+			expectedStart: now.Add(-30 * time.Minute),
+			expectedEnd:   now,
+		},
+		"all of yesterday": {
+			start:         "yesterday",
+			end:           "today",
+			expectedStart: time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2023, 10, 19, 0, 0, 0, 0, time.UTC),
+		},
+		"forward from start": {
+			start:         "yesterday",
+			end:           "+24h",
+			expectedStart: time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2023, 10, 19, 0, 0, 0, 0, time.UTC),
+		},
+		"forward from absolute start": {
+			start:         "2023-10-01T06:00:00Z",
+			end:           "+2h",
+			expectedStart: time.Date(2023, 10, 1, 6, 0, 0, 0, time.UTC),
+			expectedEnd:   time.Date(2023, 10, 1, 8, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			start, end, err := parseTimeRangeWithClock(tc.start, tc.end, time.UTC, c)
+			require.Nil(t, err)
+			assert.True(t, tc.expectedStart.Equal(start), "expected start %v, got %v", tc.expectedStart, start)
+			assert.True(t, tc.expectedEnd.Equal(end), "expected end %v, got %v", tc.expectedEnd, end)
+		})
+	}
+
+	_, _, err := ParseTimeRange("asdf", "+2h", time.UTC)
+	assert.ErrorContains(t, err, "could not parse start time")
+	_, _, err = ParseTimeRange("-2h", "asdf", time.UTC)
+	assert.ErrorContains(t, err, "could not parse end time")
+}
+
 func TestParseTimeStrError(t *testing.T) {
-	_, err := ParseTimeStr("asdf", time.Time{})
-	assert.NotNil(t, err)
+	for _, str := range []string{"asdf", "2023-10-19", "2023-10-19T01:04:32+25:00", "-1697677472"} {
+		_, err := ParseTimeStr(str, time.Time{})
+		assert.NotNil(t, err, str)
+	}
 }
@@ -0,0 +1,50 @@
+package volcano
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// These are the names that the Volcano scheduler uses for gang scheduling; like Kueue, we don't
+// import the Volcano API for a handful of strings
+const (
+	Group = "scheduling.volcano.sh"
+
+	// GroupNameAnnotation is set on a pod to put it in a PodGroup (in the same namespace)
+	GroupNameAnnotation = "scheduling.k8s.io/group-name"
+
+	// OwnerKindAnnotation is added by the tracers to PodGroups that were owned by another object
+	// (e.g., a VolcanoJob), since the owner references are stripped from the trace; it's the kind
+	// of the (controlling) owner
+	OwnerKindAnnotation = "simkube.io/owner-kind"
+
+	podGroupKind = "PodGroup"
+)
+
+// IsPodGroup returns true if the GVK is a Volcano PodGroup (of any version)
+func IsPodGroup(gvk schema.GroupVersionKind) bool {
+	return gvk.Group == Group && gvk.Kind == podGroupKind
+}
+
+// RecordOwner annotates a PodGroup with the kind of its owner (if it has one), so that we can
+// still tell that Volcano created it once the owner references are gone
+func RecordOwner(obj *unstructured.Unstructured) {
+	owners := obj.GetOwnerReferences()
+	if !IsPodGroup(obj.GroupVersionKind()) || len(owners) == 0 {
+		return
+	}
+
+	owner := owners[0]
+	for _, ref := range owners {
+		if ref.Controller != nil && *ref.Controller {
+			owner = ref
+		}
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[OwnerKindAnnotation] = owner.Kind
+	obj.SetAnnotations(annotations)
+}
@@ -0,0 +1,62 @@
+package volcano
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestIsPodGroup(t *testing.T) {
+	cases := map[string]struct {
+		gvk      schema.GroupVersionKind
+		expected bool
+	}{
+		"v1beta1":  {gvk: schema.GroupVersionKind{Group: Group, Version: "v1beta1", Kind: "PodGroup"}, expected: true},
+		"vcjob":    {gvk: schema.GroupVersionKind{Group: "batch.volcano.sh", Version: "v1alpha1", Kind: "Job"}},
+		"other pg": {gvk: schema.GroupVersionKind{Group: "scheduling.x-k8s.io", Version: "v1alpha1", Kind: "PodGroup"}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsPodGroup(tc.gvk))
+		})
+	}
+}
+
+func TestRecordOwner(t *testing.T) {
+	podGroup := func(owners ...metav1.OwnerReference) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(schema.GroupVersionKind{Group: Group, Version: "v1beta1", Kind: "PodGroup"})
+		obj.SetOwnerReferences(owners)
+		return obj
+	}
+	vcjob := metav1.OwnerReference{APIVersion: "batch.volcano.sh/v1alpha1", Kind: "Job", Controller: lo.ToPtr(true)}
+	other := metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap"}
+
+	cases := map[string]struct {
+		obj      *unstructured.Unstructured
+		expected map[string]string
+	}{
+		"owned":                {obj: podGroup(other, vcjob), expected: map[string]string{OwnerKindAnnotation: "Job"}},
+		"no owners":            {obj: podGroup()},
+		"non-controller owner": {obj: podGroup(other), expected: map[string]string{OwnerKindAnnotation: "ConfigMap"}},
+		"not a podgroup": {
+			obj: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "batch.volcano.sh/v1alpha1",
+				"kind":       "Job",
+				"metadata":   map[string]interface{}{"ownerReferences": []interface{}{map[string]interface{}{"kind": "Foo"}}},
+			}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			RecordOwner(tc.obj)
+			assert.Equal(t, tc.expected, tc.obj.GetAnnotations())
+		})
+	}
+}
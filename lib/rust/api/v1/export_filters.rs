@@ -18,6 +18,14 @@ pub struct ExportFilters {
     pub excluded_labels: Vec<metav1::LabelSelector>,
     #[serde(rename = "exclude_daemonsets")]
     pub exclude_daemonsets: bool,
+    #[serde(rename = "included_kinds", skip_serializing_if = "Option::is_none")]
+    pub included_kinds: Option<Vec<String>>,
+    #[serde(rename = "excluded_kinds", skip_serializing_if = "Option::is_none")]
+    pub excluded_kinds: Option<Vec<String>>,
+    #[serde(rename = "exclude_owned_objects", skip_serializing_if = "Option::is_none")]
+    pub exclude_owned_objects: Option<bool>,
+    #[serde(rename = "top_level_only", skip_serializing_if = "Option::is_none")]
+    pub top_level_only: Option<bool>,
 }
 
 impl ExportFilters {
@@ -30,6 +38,10 @@ impl ExportFilters {
             excluded_namespaces,
             excluded_labels,
             exclude_daemonsets,
+            included_kinds: None,
+            excluded_kinds: None,
+            exclude_owned_objects: None,
+            top_level_only: None,
         }
     }
 }
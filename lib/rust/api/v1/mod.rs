@@ -16,6 +16,12 @@ pub use simulation_roots::{
 };
 pub use simulations::{
     Simulation,
+    SimulationMetrics,
+    SimulationMetricsQueries,
     SimulationSpec,
     SimulationStatus,
+    SimulationStatusConditions,
+    SimulationStatusConditionsStatus,
+    SimulationStatusPhase,
+    SimulationStatusResults,
 };
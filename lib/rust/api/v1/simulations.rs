@@ -2,6 +2,9 @@
 // kopium command: kopium -f k8s/raw/simkube.io_simulations.yaml
 // kopium version: 0.15.0
 
+use std::collections::BTreeMap;
+
+use k8s_openapi::apimachinery::pkg::util::intstr::IntOrString;
 use kube::CustomResource;
 use serde::{
     Deserialize,
@@ -13,10 +16,122 @@ use serde::{
 #[kube(status = "SimulationStatus")]
 #[kube(schema = "disabled")]
 pub struct SimulationSpec {
+    #[serde(default, skip_serializing_if = "Option::is_none", rename = "customMetrics")]
+    pub custom_metrics: Option<Vec<SimulationCustomMetrics>>,
     #[serde(rename = "driverNamespace")]
     pub driver_namespace: String,
+    #[serde(default, skip_serializing_if = "Option::is_none", rename = "maxDurationSeconds")]
+    pub max_duration_seconds: Option<i64>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub metrics: Option<SimulationMetrics>,
+    #[serde(default, skip_serializing_if = "Option::is_none", rename = "namespaceMap")]
+    pub namespace_map: Option<BTreeMap<String, String>>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub paused: Option<bool>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub repetitions: Option<i32>,
+    #[serde(default, skip_serializing_if = "Option::is_none", rename = "scaleFactor")]
+    pub scale_factor: Option<f64>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub seed: Option<i64>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub speed: Option<f64>,
     pub trace: String,
 }
 
 #[derive(Serialize, Deserialize, Clone, Debug)]
-pub struct SimulationStatus {}
+pub struct SimulationCustomMetrics {
+    #[serde(default, skip_serializing_if = "Option::is_none", rename = "fromTrace")]
+    pub from_trace: Option<bool>,
+    pub name: String,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub namespace: Option<String>,
+    #[serde(rename = "type")]
+    pub r#type: SimulationCustomMetricsType,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub values: Option<Vec<SimulationCustomMetricsValues>>,
+}
+
+#[derive(Serialize, Deserialize, Clone, Debug)]
+pub enum SimulationCustomMetricsType {
+    Object,
+    Pods,
+    External,
+}
+
+#[derive(Serialize, Deserialize, Clone, Debug)]
+pub struct SimulationCustomMetricsValues {
+    #[serde(rename = "offsetSeconds")]
+    pub offset_seconds: i64,
+    pub value: IntOrString,
+}
+
+#[derive(Serialize, Deserialize, Clone, Debug)]
+pub struct SimulationMetrics {
+    #[serde(default, skip_serializing_if = "Option::is_none", rename = "intervalSeconds")]
+    pub interval_seconds: Option<i32>,
+    #[serde(rename = "prometheusURL")]
+    pub prometheus_url: String,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub queries: Option<Vec<SimulationMetricsQueries>>,
+}
+
+#[derive(Serialize, Deserialize, Clone, Debug)]
+pub struct SimulationMetricsQueries {
+    pub name: String,
+    pub query: String,
+}
+
+#[derive(Serialize, Deserialize, Clone, Debug)]
+pub struct SimulationStatus {
+    #[serde(default, skip_serializing_if = "Option::is_none", rename = "completionTime")]
+    pub completion_time: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub conditions: Option<Vec<SimulationStatusConditions>>,
+    #[serde(default, skip_serializing_if = "Option::is_none", rename = "eventsReplayed")]
+    pub events_replayed: Option<i64>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub phase: Option<SimulationStatusPhase>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub results: Option<SimulationStatusResults>,
+    #[serde(default, skip_serializing_if = "Option::is_none", rename = "startTime")]
+    pub start_time: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none", rename = "totalEvents")]
+    pub total_events: Option<i64>,
+}
+
+#[derive(Serialize, Deserialize, Clone, Debug)]
+pub struct SimulationStatusConditions {
+    #[serde(rename = "lastTransitionTime")]
+    pub last_transition_time: String,
+    pub message: String,
+    #[serde(default, skip_serializing_if = "Option::is_none", rename = "observedGeneration")]
+    pub observed_generation: Option<i64>,
+    pub reason: String,
+    pub status: SimulationStatusConditionsStatus,
+    #[serde(rename = "type")]
+    pub r#type: String,
+}
+
+#[derive(Serialize, Deserialize, Clone, Debug)]
+pub enum SimulationStatusConditionsStatus {
+    True,
+    False,
+    Unknown,
+}
+
+#[derive(Serialize, Deserialize, Clone, Debug)]
+pub enum SimulationStatusPhase {
+    Pending,
+    Running,
+    Succeeded,
+    Failed,
+}
+
+#[derive(Serialize, Deserialize, Clone, Debug)]
+pub struct SimulationStatusResults {
+    pub location: String,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub metrics: Option<String>,
+    pub summary: String,
+}
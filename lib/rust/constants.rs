@@ -1,5 +1,6 @@
 pub const DRIVER_ADMISSION_WEBHOOK_PORT: &str = "8888";
 pub const LIFETIME_ANNOTATION_KEY: &str = "simkube.io/lifetime-seconds";
+pub const OWNER_KIND_ANNOTATION_KEY: &str = "simkube.io/owner-kind";
 pub const ORIG_NAMESPACE_ANNOTATION_KEY: &str = "simkube.io/original-namespace";
 pub const SIMULATION_LABEL_KEY: &str = "simkube.io/simulation";
 pub const VIRTUAL_LABEL_KEY: &str = "simkube.io/virtual";
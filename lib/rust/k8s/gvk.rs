@@ -57,13 +57,18 @@ impl Deref for GVK {
     }
 }
 
+impl fmt::Display for GVK {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        write!(f, "{}/{}.{}", self.0.group, self.0.version, self.0.kind)
+    }
+}
+
 impl Serialize for GVK {
     fn serialize<S>(&self, serializer: S) -> Result<S::Ok, S::Error>
     where
         S: Serializer,
     {
-        let skey = format!("{}/{}.{}", self.0.group, self.0.version, self.0.kind);
-        serializer.serialize_str(&skey)
+        serializer.serialize_str(&self.to_string())
     }
 }
 
@@ -147,12 +147,14 @@ impl PodLifecycleData {
         matches!(self, PodLifecycleData::Finished(..))
     }
 
-    pub fn to_annotation_patch(&self) -> Option<PatchOperation> {
+    // When the simulation is sped up, the pods have to run for less time as well; we round up, so
+    // that pods that ran for a short time in the trace don't terminate immediately in the simulation
+    pub fn to_annotation_patch(&self, speed: f64) -> Option<PatchOperation> {
         match self {
             PodLifecycleData::Empty | PodLifecycleData::Running(_) => None,
             PodLifecycleData::Finished(start_ts, end_ts) => Some(PatchOperation::Add(AddOperation {
                 path: format!("/metadata/annotations/{}", jsonutils::escape(LIFETIME_ANNOTATION_KEY)),
-                value: Value::String(format!("{}", end_ts - start_ts)),
+                value: Value::String(format!("{}", ((end_ts - start_ts) as f64 / speed).ceil() as i64)),
             })),
         }
     }
@@ -1,5 +1,10 @@
 use std::cmp::Ordering;
 
+use json_patch::{
+    AddOperation,
+    PatchOperation,
+};
+
 use super::*;
 use crate::testutils::{
     pods,
@@ -55,6 +60,18 @@ fn test_pod_lifecycle_data_for_with_end_times(mut test_pod: corev1::Pod) {
     assert_eq!(res, PodLifecycleData::Finished(START_TS, end_ts));
 }
 
+#[rstest]
+#[case::normal_speed(1.0, "60")]
+#[case::sped_up(12.0, "5")]
+#[case::rounds_up(7.0, "9")]
+fn test_to_annotation_patch(#[case] speed: f64, #[case] expected: &str) {
+    let patch = PodLifecycleData::Finished(START_TS, START_TS + 60).to_annotation_patch(speed);
+    assert!(matches!(patch, Some(PatchOperation::Add(AddOperation { value, .. })) if value == expected));
+
+    assert!(PodLifecycleData::Empty.to_annotation_patch(speed).is_none());
+    assert!(PodLifecycleData::Running(START_TS).to_annotation_patch(speed).is_none());
+}
+
 #[test]
 fn test_partial_eq() {
     assert_eq!(PodLifecycleData::Empty, None);
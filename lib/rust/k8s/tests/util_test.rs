@@ -40,7 +40,7 @@ fn test_sanitize_obj() {
     assert_eq!(obj.metadata.deletion_grace_period_seconds, None);
     assert_eq!(obj.metadata.generation, None);
     assert_eq!(obj.metadata.managed_fields, None);
-    assert_eq!(obj.metadata.owner_references, None);
+    assert_eq!(obj.metadata.owner_references, Some(vec![Default::default()]));
     assert_eq!(obj.metadata.resource_version, None);
     assert_eq!(obj.metadata.uid, None);
 
@@ -76,13 +76,14 @@ pub fn list_params_for(namespace: &str, name: &str) -> ListParams {
     }
 }
 
+// The owner references are left alone so that the export filters can tell which objects are owned
+// by other objects; they're stripped when the trace is exported (see store/trace_filter.rs)
 pub fn sanitize_obj(obj: &mut DynamicObject, api_version: &str, kind: &str) {
     obj.metadata.creation_timestamp = None;
     obj.metadata.deletion_timestamp = None;
     obj.metadata.deletion_grace_period_seconds = None;
     obj.metadata.generation = None;
     obj.metadata.managed_fields = None;
-    obj.metadata.owner_references = None;
     obj.metadata.resource_version = None;
     obj.metadata.uid = None;
 
@@ -18,7 +18,10 @@ use self::pod_owners_map::{
     PodLifecyclesMap,
     PodOwnersMap,
 };
-use self::trace_filter::filter_event;
+use self::trace_filter::{
+    filter_event,
+    traced_objs,
+};
 use crate::errors::*;
 use crate::prelude::*;
 
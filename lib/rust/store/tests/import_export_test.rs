@@ -146,6 +146,7 @@ async fn itest_export() {
             ..Default::default()
         }],
         exclude_daemonsets: true,
+        ..Default::default()
     };
 
     let store = s.lock().unwrap();
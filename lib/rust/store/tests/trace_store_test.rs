@@ -1,5 +1,9 @@
 use assertables::*;
-use kube::api::DynamicObject;
+use kube::api::{
+    DynamicObject,
+    TypeMeta,
+};
+use kube::ResourceExt;
 use serde_json::json;
 
 use super::*;
@@ -133,6 +137,113 @@ fn test_collect_events(mut tracer: TraceStore) {
     assert_bag_eq!(keys, ["test/obj1", "test/obj2", "test/obj3"].map(|s| s.to_string()));
 }
 
+#[rstest]
+fn test_collect_events_kind_filters(mut tracer: TraceStore) {
+    let mut deployment = test_obj("obj1");
+    deployment.types = Some(TypeMeta {
+        api_version: "apps/v1".into(),
+        kind: "Deployment".into(),
+    });
+    let mut workflow = test_obj("obj2");
+    workflow.types = Some(TypeMeta {
+        api_version: "argoproj.io/v1alpha1".into(),
+        kind: "Workflow".into(),
+    });
+    tracer.events = vec![TraceEvent {
+        ts: 0,
+        applied_objs: vec![deployment.clone(), workflow, test_obj("obj3")],
+        deleted_objs: vec![],
+    }]
+    .into();
+
+    // Objects without type data are dropped if there are included kinds, but not otherwise
+    let filter = ExportFilters {
+        included_kinds: Some(vec!["apps/v1.Deployment".into()]),
+        ..Default::default()
+    };
+    let (events, _) = tracer.collect_events(1, 10, &filter, true);
+    assert_eq!(
+        events,
+        vec![TraceEvent {
+            ts: 1,
+            applied_objs: vec![deployment],
+            ..Default::default()
+        }]
+    );
+
+    let filter = ExportFilters {
+        excluded_kinds: Some(vec!["apps/v1.Deployment".into()]),
+        ..Default::default()
+    };
+    let (_, index) = tracer.collect_events(1, 10, &filter, true);
+    let keys: Vec<_> = index.into_keys().collect();
+    assert_bag_eq!(keys, ["test/obj2", "test/obj3"].map(|s| s.to_string()));
+}
+
+#[rstest]
+fn test_collect_events_owner_filters(mut tracer: TraceStore, owner_ref: metav1::OwnerReference) {
+    let mut deployment = test_obj(TEST_DEPLOYMENT);
+    deployment.types = Some(TypeMeta { api_version: "apps/v1".into(), kind: "Deployment".into() });
+    let mut pods = vec![];
+    for (name, owner) in [
+        ("pod1", Some(owner_ref.clone())),
+        ("pod2", Some(metav1::OwnerReference { name: "other-deployment".into(), ..owner_ref })),
+        ("pod3", None),
+    ] {
+        let mut pod = test_obj(name);
+        pod.types = Some(TypeMeta { api_version: "v1".into(), kind: "Pod".into() });
+        pod.metadata.owner_references = owner.map(|o| vec![o]);
+        pods.push(pod);
+    }
+    tracer.events = vec![TraceEvent {
+        ts: 0,
+        applied_objs: [vec![deployment], pods].concat(),
+        deleted_objs: vec![],
+    }]
+    .into();
+
+    // pod2's owner isn't in the trace, so nothing would create it if we dropped it
+    let filter = ExportFilters { exclude_owned_objects: Some(true), ..Default::default() };
+    let (events, index) = tracer.collect_events(1, 10, &filter, true);
+    let keys: Vec<_> = index.into_keys().collect();
+    assert_bag_eq!(keys, ["test/the-deployment", "test/pod2", "test/pod3"].map(|s| s.to_string()));
+
+    // The owner references are only used for filtering, and aren't exported
+    assert!(events[0].applied_objs.iter().all(|obj| obj.metadata.owner_references.is_none()));
+
+    let filter = ExportFilters { top_level_only: Some(true), ..Default::default() };
+    let (_, index) = tracer.collect_events(1, 10, &filter, true);
+    let keys: Vec<_> = index.into_keys().collect();
+    assert_bag_eq!(keys, ["test/the-deployment", "test/pod3"].map(|s| s.to_string()));
+}
+
+#[rstest]
+fn test_collect_events_owned_pod_group(mut tracer: TraceStore, owner_ref: metav1::OwnerReference) {
+    let mut pod_group = test_obj("the-pod-group");
+    pod_group.types = Some(TypeMeta {
+        api_version: "scheduling.volcano.sh/v1beta1".into(),
+        kind: "PodGroup".into(),
+    });
+    pod_group.metadata.owner_references = Some(vec![metav1::OwnerReference { kind: "Job".into(), ..owner_ref }]);
+    tracer.events = vec![TraceEvent { ts: 0, applied_objs: vec![pod_group], deleted_objs: vec![] }].into();
+
+    // The owner references are stripped, but the driver still needs to know that Volcano created
+    // the PodGroup
+    let (events, _) = tracer.collect_events(1, 10, &ExportFilters::default(), true);
+    let obj = &events[0].applied_objs[0];
+    assert!(obj.metadata.owner_references.is_none());
+    assert_eq!(obj.annotations().get(OWNER_KIND_ANNOTATION_KEY), Some(&"Job".to_string()));
+}
+
+#[rstest]
+fn test_export_untracked_kind(tracer: TraceStore) {
+    let filter = ExportFilters {
+        included_kinds: Some(vec!["apps/v1.Deployment".into(), "argoproj.io/v1alpha1.Workflow".into()]),
+        ..Default::default()
+    };
+    assert!(tracer.export(0, 10, &filter).is_err());
+}
+
 #[rstest]
 fn test_create_or_update_obj(mut tracer: TraceStore, test_obj: DynamicObject) {
     let ns_name = test_obj.namespaced_name();
@@ -150,6 +261,31 @@ fn test_create_or_update_obj(mut tracer: TraceStore, test_obj: DynamicObject) {
     assert_eq!(tracer.events[0].ts, ts);
 }
 
+#[rstest]
+fn test_create_or_update_obj_hpa_metrics(mut tracer: TraceStore) {
+    let hpa = |metrics: serde_json::Value, ts: i64, tracer: &mut TraceStore| {
+        let mut obj = test_obj("the-hpa");
+        obj.types = Some(TypeMeta {
+            api_version: "autoscaling/v2".into(),
+            kind: "HorizontalPodAutoscaler".into(),
+        });
+        obj.data = json!({"spec": {}, "status": {"currentReplicas": ts, "currentMetrics": metrics}});
+        tracer.create_or_update_obj(&obj, ts, None);
+    };
+
+    // Changes to the current metric values are recorded, but other status changes aren't
+    hpa(json!([{"type": "External", "external": {"current": {"value": "4"}}}]), 1, &mut tracer);
+    hpa(json!([{"type": "External", "external": {"current": {"value": "4"}}}]), 2, &mut tracer);
+    hpa(json!([{"type": "External", "external": {"current": {"value": "5"}}}]), 3, &mut tracer);
+
+    assert_eq!(tracer.events.len(), 2);
+    assert_eq!(tracer.events[1].ts, 3);
+
+    // The exported index still only has the spec hash
+    let (_, index) = tracer.collect_events(0, 10, &ExportFilters::default(), true);
+    assert_eq!(index["test/the-hpa"], EMPTY_OBJ_HASH);
+}
+
 #[rstest]
 fn test_create_or_update_objs(mut tracer: TraceStore) {
     let obj_names = vec!["obj1", "obj2"];
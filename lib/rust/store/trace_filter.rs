@@ -1,23 +1,33 @@
+use std::collections::HashSet;
+
 use kube::api::DynamicObject;
+use kube::ResourceExt;
 
 use super::TraceEvent;
 use crate::api::v1::ExportFilters;
+use crate::k8s::GVK;
 use crate::prelude::*;
 
-pub fn filter_event(evt: &TraceEvent, f: &ExportFilters) -> Option<TraceEvent> {
+const VOLCANO_SCHEDULING_GROUP: &str = "scheduling.volcano.sh";
+
+// The traced objects are the objects that make it through the filters (not counting the owner
+// filters), so that we can tell whether an object's owner is also in the trace.  The owner
+// references are only used for filtering, and are stripped from the exported objects, since they
+// point at objects that won't exist in the simulation.
+pub fn filter_event(evt: &TraceEvent, f: &ExportFilters, traced: &HashSet<String>) -> Option<TraceEvent> {
     let new_evt = TraceEvent {
         ts: evt.ts,
         applied_objs: evt
             .applied_objs
             .iter()
-            .filter(|obj| !obj_matches_filter(obj, f))
-            .cloned()
+            .filter(|obj| !obj_matches_filter(obj, f) && !owner_matches_filter(obj, f, traced))
+            .map(strip_owner_references)
             .collect(),
         deleted_objs: evt
             .deleted_objs
             .iter()
-            .filter(|obj| !obj_matches_filter(obj, f))
-            .cloned()
+            .filter(|obj| !obj_matches_filter(obj, f) && !owner_matches_filter(obj, f, traced))
+            .map(strip_owner_references)
             .collect(),
     };
 
@@ -28,18 +38,92 @@ pub fn filter_event(evt: &TraceEvent, f: &ExportFilters) -> Option<TraceEvent> {
     Some(new_evt)
 }
 
+// We only need to know which objects are in the trace if we're excluding the objects that are
+// owned by other objects in the trace
+pub fn traced_objs<'a>(events: impl Iterator<Item = &'a TraceEvent>, f: &ExportFilters) -> HashSet<String> {
+    if f.exclude_owned_objects != Some(true) {
+        return HashSet::new();
+    }
+
+    events
+        .flat_map(|evt| evt.applied_objs.iter())
+        .filter(|obj| !obj_matches_filter(obj, f))
+        .filter_map(|obj| {
+            let gvk = GVK::from_dynamic_obj(obj).ok()?;
+            Some(traced_key(&gvk, obj.metadata.namespace.as_deref(), &obj.name_any()))
+        })
+        .collect()
+}
+
 fn obj_matches_filter(obj: &DynamicObject, f: &ExportFilters) -> bool {
     obj.metadata
         .namespace
         .as_ref()
         .is_some_and(|ns| f.excluded_namespaces.contains(ns))
-        || obj
-            .metadata
-            .owner_references
-            .as_ref()
-            .is_some_and(|owners| owners.iter().any(|owner| &owner.kind == "DaemonSet"))
+        || (f.exclude_daemonsets
+            && obj
+                .metadata
+                .owner_references
+                .as_ref()
+                .is_some_and(|owners| owners.iter().any(|owner| &owner.kind == "DaemonSet")))
         // TODO: maybe don't call unwrap here?  Right now we panic if the user specifies
         // an invalid label selector.  Or, maybe it doesn't matter once we write the CLI
         // tool.
         || f.excluded_labels.iter().any(|sel| obj.matches(sel).unwrap())
+        || !kind_matches_filter(obj, f)
+}
+
+// With top_level_only, anything that's owned by something else is dropped; with
+// exclude_owned_objects, objects are only dropped if one of their owners is in the trace, since
+// otherwise nothing would create them in the simulation.
+fn owner_matches_filter(obj: &DynamicObject, f: &ExportFilters, traced: &HashSet<String>) -> bool {
+    let owners = match &obj.metadata.owner_references {
+        Some(owners) if !owners.is_empty() => owners,
+        _ => return false,
+    };
+
+    f.top_level_only == Some(true)
+        || (f.exclude_owned_objects == Some(true)
+            && owners.iter().any(|owner| {
+                GVK::from_owner_ref(owner).is_ok_and(|gvk| {
+                    traced.contains(&traced_key(&gvk, obj.metadata.namespace.as_deref(), &owner.name))
+                })
+            }))
+}
+
+// Kinds are in the same "group/version.kind" format as the tracer config; if there's a list of
+// included kinds, objects without any type data are dropped, since we can't tell what they are.
+fn kind_matches_filter(obj: &DynamicObject, f: &ExportFilters) -> bool {
+    let kind = match GVK::from_dynamic_obj(obj) {
+        Ok(gvk) => gvk.to_string(),
+        Err(_) => return f.included_kinds.is_none(),
+    };
+
+    f.included_kinds.as_ref().map_or(true, |kinds| kinds.contains(&kind))
+        && !f.excluded_kinds.as_ref().is_some_and(|kinds| kinds.contains(&kind))
+}
+
+// Owners are always in the same namespace as the objects they own (or cluster-scoped, in which
+// case we won't find them in the trace anyways, since all the tracked objects are namespaced)
+fn traced_key(gvk: &GVK, namespace: Option<&str>, name: &str) -> String {
+    format!("{gvk}:{}/{name}", namespace.unwrap_or_default())
+}
+
+// Volcano creates PodGroups for the objects that use gang scheduling (e.g., VolcanoJobs), and
+// creates them again when those objects are replayed; the driver needs to know which PodGroups
+// were owned so that it doesn't replay them too, so we record the owner's kind in an annotation
+// before the owner references go away
+fn strip_owner_references(obj: &DynamicObject) -> DynamicObject {
+    let mut obj = obj.clone();
+    let owners = obj.metadata.owner_references.take().unwrap_or_default();
+    if is_volcano_pod_group(&obj) {
+        if let Some(owner) = owners.iter().find(|owner| owner.controller == Some(true)).or(owners.first()) {
+            obj.annotations_mut().insert(OWNER_KIND_ANNOTATION_KEY.into(), owner.kind.clone());
+        }
+    }
+    obj
+}
+
+fn is_volcano_pod_group(obj: &DynamicObject) -> bool {
+    GVK::from_dynamic_obj(obj).is_ok_and(|gvk| gvk.group == VOLCANO_SCHEDULING_GROUP && gvk.kind == "PodGroup")
 }
@@ -6,6 +6,7 @@ use std::mem::take;
 
 use kube::api::DynamicObject;
 use kube::ResourceExt;
+use serde_json::json;
 use tracing::*;
 
 use super::*;
@@ -17,6 +18,8 @@ use crate::k8s::{
 };
 use crate::prelude::*;
 
+const HPA_KIND: &str = "HorizontalPodAutoscaler";
+
 // The TraceStore object is an in-memory store of a cluster trace.  It keeps track of all the
 // configured Kubernetes objects, as well as lifecycle data for any pods that are owned by the
 // tracked objects.  It also provides functionality for importing and exporting traces.
@@ -32,6 +35,15 @@ impl TraceStore {
     pub fn export(&self, start_ts: i64, end_ts: i64, filter: &ExportFilters) -> anyhow::Result<Vec<u8>> {
         info!("Exporting objs with filters: {filter:?}");
 
+        // The consumer can only ask for kinds that we're tracking; otherwise they would silently
+        // get back a trace that's missing the objects they wanted
+        if let Some(kinds) = &filter.included_kinds {
+            let tracked: Vec<_> = self.config.tracked_objects.keys().map(|gvk| gvk.to_string()).collect();
+            if let Some(kind) = kinds.iter().find(|kind| !tracked.contains(kind)) {
+                bail!("{kind} is not a tracked kind (tracked kinds: {tracked:?})");
+            }
+        }
+
         // First, we collect all the events in our trace that match our configured filters.  This
         // will return an index of objects that we collected, and we set the keep_deleted flag =
         // true so that in the second step, we keep pod data around even if the owning object was
@@ -86,6 +98,7 @@ impl TraceStore {
         let mut events = vec![TraceEvent { ts: start_ts, ..Default::default() }];
         let mut flattened_objects = HashMap::new();
         let mut index = HashMap::new();
+        let traced = traced_objs(self.events.iter().take_while(|evt| evt.ts < end_ts), filter);
         for (evt, _) in self.iter() {
             // trace should be end-exclusive, so we use >= here: anything that is at the
             // end_ts or greater gets discarded.  The event list is stored in
@@ -94,7 +107,7 @@ impl TraceStore {
                 break;
             }
 
-            if let Some(new_evt) = filter_event(evt, filter) {
+            if let Some(new_evt) = filter_event(evt, filter, &traced) {
                 for obj in &new_evt.applied_objs {
                     let ns_name = obj.namespaced_name();
                     if new_evt.ts < start_ts {
@@ -153,7 +166,7 @@ impl TraceStorable for TraceStore {
     // index either, we'll do a second lookup in the new index, but that should be pretty fast)..
     fn create_or_update_obj(&mut self, obj: &DynamicObject, ts: i64, maybe_old_hash: Option<u64>) {
         let ns_name = obj.namespaced_name();
-        let new_hash = jsonutils::hash_option(obj.data.get("spec"));
+        let new_hash = change_hash(obj);
         let old_hash = maybe_old_hash.or_else(|| self.index.get(&ns_name).cloned());
 
         if Some(new_hash) != old_hash {
@@ -261,6 +274,17 @@ impl TraceStorable for TraceStore {
     }
 }
 
+// The in-memory index normally just tracks the spec of each object, but HPAs are also recorded
+// when the values of the metrics they scale on change, so that sk-cloudprov can serve the same
+// values in the simulation; the exported index still only has the spec hashes.
+fn change_hash(obj: &DynamicObject) -> u64 {
+    let is_hpa = GVK::from_dynamic_obj(obj).is_ok_and(|gvk| gvk.group == "autoscaling" && gvk.kind == HPA_KIND);
+    if !is_hpa {
+        return jsonutils::hash_option(obj.data.get("spec"));
+    }
+    jsonutils::hash(&json!([obj.data.get("spec"), obj.data.pointer("/status/currentMetrics")]))
+}
+
 // Our iterator implementation iterates over all the events in timeseries order.  It returns the
 // current event, and the timestamp of the _next_ event.
 impl<'a> Iterator for TraceIterator<'a> {
@@ -5,6 +5,8 @@ use std::sync::{
 
 use clap::Parser;
 use kube::Client;
+use rocket::http::Status;
+use rocket::response::status;
 use rocket::serde::json::Json;
 use simkube::api::v1::ExportRequest;
 use simkube::k8s::ApiSet;
@@ -29,13 +31,26 @@ struct Options {
 }
 
 #[rocket::post("/export", data = "<req>")]
-async fn export(req: Json<ExportRequest>, store: &rocket::State<Arc<Mutex<TraceStore>>>) -> Result<Vec<u8>, String> {
+async fn export(
+    req: Json<ExportRequest>,
+    store: &rocket::State<Arc<Mutex<TraceStore>>>,
+) -> Result<Vec<u8>, status::Custom<String>> {
     debug!("export called with {:?}", req);
     store
         .lock()
         .unwrap()
         .export(req.start_ts, req.end_ts, &req.filters)
-        .map_err(|e| format!("{e:?}"))
+        .map_err(|e| status::Custom(Status::BadRequest, format!("{e:?}")))
+}
+
+#[rocket::get("/health")]
+async fn health() -> &'static str {
+    "ok"
+}
+
+#[rocket::get("/config")]
+async fn tracer_config(config: &rocket::State<TracerConfig>) -> Json<TracerConfig> {
+    Json(config.inner().clone())
 }
 
 #[instrument(ret, err)]
@@ -51,8 +66,9 @@ async fn run(args: Options) -> EmptyResult {
 
     let rkt_config = rocket::Config { port: args.server_port, ..Default::default() };
     let server = rocket::custom(&rkt_config)
-        .mount("/", rocket::routes![export])
-        .manage(store.clone());
+        .mount("/", rocket::routes![export, health, tracer_config])
+        .manage(store.clone())
+        .manage(config.clone());
 
     tokio::select! {
         res = tokio::spawn(dyn_obj_watcher.start()) => res.map_err(|e| e.into()),
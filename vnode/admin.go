@@ -0,0 +1,302 @@
+package vnode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"simkube/lib/go/metrics"
+	"simkube/lib/go/node"
+	"simkube/lib/go/pod"
+	"simkube/lib/go/util"
+)
+
+const (
+	adminReadHeaderTimeout = 5 * time.Second
+	adminShutdownTimeout   = 5 * time.Second
+
+	conditionsPath  = "/node/conditions"
+	allocatablePath = "/node/allocatable"
+	labelsPath      = "/node/labels"
+	cordonPath      = "/node/cordon"
+	uncordonPath    = "/node/uncordon"
+	crashPath       = "/node/crash"
+	terminatePath   = "/node/terminate"
+	heartbeatsPath  = "/node/heartbeats/pause"
+	logLevelPath    = "/loglevel"
+	healthzPath     = "/healthz"
+	readyzPath      = "/readyz"
+	metricsPath     = "/metrics"
+)
+
+type allocatableRequest struct {
+	Allocatable map[corev1.ResourceName]string `json:"allocatable"`
+}
+
+type labelsRequest struct {
+	Labels map[string]*string `json:"labels"`
+}
+
+type durationRequest struct {
+	Duration metav1.Duration `json:"duration"`
+}
+
+type conditionRequest struct {
+	Type    corev1.NodeConditionType `json:"type"`
+	Status  corev1.ConditionStatus   `json:"status"`
+	Reason  string                   `json:"reason,omitempty"`
+	Message string                   `json:"message,omitempty"`
+}
+
+// The admin server exposes a small HTTP API that lets users (or test harnesses) modify
+// the behaviour of the virtual node while a simulation is running.
+type adminServer struct {
+	nlm    node.LifecycleManagerI
+	plm    pod.LifecycleManagerI
+	faults *faultInjector
+	logger *log.Entry
+}
+
+func (self *adminServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(conditionsPath, self.handleConditions)
+	mux.HandleFunc(allocatablePath, self.handleAllocatable)
+	mux.HandleFunc(labelsPath, self.handleLabels)
+	mux.HandleFunc(cordonPath, self.handleUnschedulable(true))
+	mux.HandleFunc(uncordonPath, self.handleUnschedulable(false))
+	mux.HandleFunc(crashPath, self.handleCrash)
+	mux.HandleFunc(terminatePath, self.handleTerminate)
+	mux.HandleFunc(heartbeatsPath, self.handlePauseHeartbeats)
+	mux.HandleFunc(logLevelPath, util.HandleLogLevel)
+	mux.HandleFunc(healthzPath, self.handleHealthz)
+	mux.HandleFunc(readyzPath, self.handleReadyz)
+	mux.Handle(metricsPath, metrics.Default.Handler())
+	return mux
+}
+
+func (self *adminServer) run(ctx context.Context, addr string) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           self.handler(),
+		ReadHeaderTimeout: adminReadHeaderTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), adminShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			self.logger.WithError(err).Warn("could not shut down admin server")
+		}
+	}()
+
+	go func() {
+		self.logger.Infof("admin server listening on %s", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			self.logger.WithError(err).Error("admin server failed")
+		}
+	}()
+}
+
+func (self *adminServer) handleConditions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req conditionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("could not parse request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Type == "" {
+		http.Error(w, "condition type is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := self.nlm.SetCondition(r.Context(), req.Type, req.Status, req.Reason, req.Message); err != nil {
+		self.logger.WithError(err).Error("could not set node condition")
+		http.Error(w, err.Error(), errorCode(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (self *adminServer) handleAllocatable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req allocatableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("could not parse request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(req.Allocatable) == 0 {
+		http.Error(w, "no allocatable resources specified", http.StatusBadRequest)
+		return
+	}
+
+	if err := self.nlm.SetAllocatable(r.Context(), req.Allocatable); err != nil {
+		self.logger.WithError(err).Error("could not set node allocatable")
+		http.Error(w, err.Error(), errorCode(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (self *adminServer) handleLabels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req labelsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("could not parse request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(req.Labels) == 0 {
+		http.Error(w, "no labels specified", http.StatusBadRequest)
+		return
+	}
+
+	if err := self.nlm.SetLabels(r.Context(), req.Labels); err != nil {
+		self.logger.WithError(err).Error("could not set node labels")
+		http.Error(w, err.Error(), errorCode(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (self *adminServer) handleUnschedulable(unschedulable bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := self.nlm.SetUnschedulable(r.Context(), unschedulable); err != nil {
+			self.logger.WithError(err).Error("could not set node unschedulable")
+			http.Error(w, err.Error(), errorCode(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (self *adminServer) handleCrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	duration, ok := parseDuration(w, r, "crash")
+	if !ok {
+		return
+	}
+
+	if err := self.faults.crash(duration); err != nil {
+		self.logger.WithError(err).Error("could not crash node")
+		http.Error(w, err.Error(), errorCode(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (self *adminServer) handleTerminate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	self.faults.terminate(terminateRequestedReason)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (self *adminServer) handlePauseHeartbeats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	duration, ok := parseDuration(w, r, "pause")
+	if !ok {
+		return
+	}
+
+	if err := self.nlm.PauseHeartbeats(duration); err != nil {
+		self.logger.WithError(err).Error("could not pause node heartbeats")
+		http.Error(w, err.Error(), errorCode(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleHealthz is for liveness probes: it fails if the node or pod controller has stopped, or
+// if the node controller is wedged, but not while they are starting up
+func (self *adminServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	self.handleProbe(w, r, self.nlm.Healthy, self.plm.Healthy)
+}
+
+// handleReadyz is for readiness probes: it fails until the node is registered and the pod
+// controller is watching pods, and after either controller stops
+func (self *adminServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	self.handleProbe(w, r, self.nlm.Ready, self.plm.Ready)
+}
+
+func (self *adminServer) handleProbe(w http.ResponseWriter, r *http.Request, checks ...func() error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	for _, check := range checks {
+		if err := check(); err != nil {
+			self.logger.WithError(err).Debugf("%s check failed", r.URL.Path)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	if _, err := fmt.Fprintln(w, "ok"); err != nil {
+		self.logger.WithError(err).Warnf("could not write %s response", r.URL.Path)
+	}
+}
+
+// errorCode picks the HTTP status for an error from the node lifecycle manager, based on its
+// kind (see util.ErrNotFound, etc.)
+func errorCode(err error) int {
+	switch {
+	case util.IsValidation(err):
+		return http.StatusBadRequest
+	case util.IsNotFound(err):
+		return http.StatusNotFound
+	case util.IsConflict(err):
+		return http.StatusConflict
+	case util.IsRetriable(err):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func parseDuration(w http.ResponseWriter, r *http.Request, what string) (time.Duration, bool) {
+	var req durationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("could not parse request: %v", err), http.StatusBadRequest)
+		return 0, false
+	}
+	if req.Duration.Duration <= 0 {
+		http.Error(w, what+" duration must be positive", http.StatusBadRequest)
+		return 0, false
+	}
+	return req.Duration.Duration, true
+}
@@ -0,0 +1,361 @@
+package vnode
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	corev1 "k8s.io/api/core/v1"
+
+	"simkube/lib/go/node"
+	"simkube/lib/go/testutils"
+	"simkube/lib/go/util"
+)
+
+func TestAdminSetCondition(t *testing.T) {
+	cases := map[string]struct {
+		method       string
+		body         string
+		setErr       error
+		expectedCode int
+	}{
+		"ok": {
+			method:       http.MethodPost,
+			body:         `{"type": "MemoryPressure", "status": "True"}`,
+			expectedCode: http.StatusNoContent,
+		},
+		"wrong method": {
+			method:       http.MethodGet,
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+		"bad json": {
+			method:       http.MethodPost,
+			body:         `asdf`,
+			expectedCode: http.StatusBadRequest,
+		},
+		"missing type": {
+			method:       http.MethodPost,
+			body:         `{"status": "True"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		"invalid status": {
+			method:       http.MethodPost,
+			body:         `{"type": "Ready", "status": "Maybe"}`,
+			setErr:       node.ErrInvalidConditionStatus,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			nlm := &mockNodeLifecycleManager{}
+			nlm.On("SetCondition", mock.Anything, mock.Anything, mock.Anything, "", "").Return(tc.setErr)
+			admin := &adminServer{nlm: nlm, logger: testutils.GetFakeLogger()}
+
+			req := httptest.NewRequest(tc.method, conditionsPath, strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			admin.handler().ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			if tc.expectedCode == http.StatusNoContent {
+				nlm.AssertCalled(
+					t, "SetCondition", mock.Anything, corev1.NodeMemoryPressure, corev1.ConditionTrue, "", "",
+				)
+			}
+		})
+	}
+}
+
+func TestAdminSetAllocatable(t *testing.T) {
+	cases := map[string]struct {
+		body         string
+		expectedCode int
+	}{
+		"ok": {
+			body:         `{"allocatable": {"cpu": "50%"}}`,
+			expectedCode: http.StatusNoContent,
+		},
+		"empty": {
+			body:         `{"allocatable": {}}`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			nlm := &mockNodeLifecycleManager{}
+			nlm.On("SetAllocatable", mock.Anything, mock.Anything).Return(nil)
+			admin := &adminServer{nlm: nlm, logger: testutils.GetFakeLogger()}
+
+			req := httptest.NewRequest(http.MethodPost, allocatablePath, strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			admin.handler().ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestAdminSetLabels(t *testing.T) {
+	cases := map[string]struct {
+		body           string
+		expectedCode   int
+		expectedLabels map[string]*string
+	}{
+		"ok": {
+			body:           `{"labels": {"foo": "bar", "baz": null}}`,
+			expectedCode:   http.StatusNoContent,
+			expectedLabels: map[string]*string{"foo": lo.ToPtr("bar"), "baz": nil},
+		},
+		"empty": {
+			body:         `{"labels": {}}`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			nlm := &mockNodeLifecycleManager{}
+			nlm.On("SetLabels", mock.Anything, mock.Anything).Return(nil)
+			admin := &adminServer{nlm: nlm, logger: testutils.GetFakeLogger()}
+
+			req := httptest.NewRequest(http.MethodPost, labelsPath, strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			admin.handler().ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			if tc.expectedLabels != nil {
+				nlm.AssertCalled(t, "SetLabels", mock.Anything, tc.expectedLabels)
+			}
+		})
+	}
+}
+
+func TestAdminCrash(t *testing.T) {
+	cases := map[string]struct {
+		body         string
+		expectedCode int
+	}{
+		"ok": {
+			body:         `{"duration": "5m"}`,
+			expectedCode: http.StatusNoContent,
+		},
+		"missing duration": {
+			body:         `{}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		"bad duration": {
+			body:         `{"duration": "asdf"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			faults, nlm, plm := makeFaultInjector(context.Background(), nil)
+			nlm.On("SetCondition", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+				Return(nil)
+			plm.On("SetNodeDown", true).Return()
+			admin := &adminServer{nlm: nlm, faults: faults, logger: testutils.GetFakeLogger()}
+
+			req := httptest.NewRequest(http.MethodPost, crashPath, strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			admin.handler().ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestAdminTerminate(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	faults, nlm, plm := makeFaultInjector(ctx, cancel)
+	plm.On("TerminatePods").Return()
+	nlm.On("SetCondition", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	admin := &adminServer{nlm: nlm, faults: faults, logger: testutils.GetFakeLogger()}
+
+	req := httptest.NewRequest(http.MethodPost, terminatePath, nil)
+	rec := httptest.NewRecorder()
+	admin.handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	<-ctx.Done()
+	plm.AssertExpectations(t)
+}
+
+func TestAdminCordon(t *testing.T) {
+	cases := map[string]struct {
+		path                  string
+		method                string
+		setErr                error
+		expectedUnschedulable bool
+		expectedCode          int
+	}{
+		"cordon": {
+			path:                  cordonPath,
+			method:                http.MethodPost,
+			expectedUnschedulable: true,
+			expectedCode:          http.StatusNoContent,
+		},
+		"uncordon": {
+			path:                  uncordonPath,
+			method:                http.MethodPost,
+			expectedUnschedulable: false,
+			expectedCode:          http.StatusNoContent,
+		},
+		"wrong method": {
+			path:         cordonPath,
+			method:       http.MethodGet,
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+		"patch failed": {
+			path:                  cordonPath,
+			method:                http.MethodPost,
+			setErr:                errors.New("patch failed"),
+			expectedUnschedulable: true,
+			expectedCode:          http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			nlm := &mockNodeLifecycleManager{}
+			nlm.On("SetUnschedulable", mock.Anything, mock.Anything).Return(tc.setErr)
+			admin := &adminServer{nlm: nlm, logger: testutils.GetFakeLogger()}
+
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			rec := httptest.NewRecorder()
+			admin.handler().ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			if tc.method == http.MethodPost {
+				nlm.AssertCalled(t, "SetUnschedulable", mock.Anything, tc.expectedUnschedulable)
+			}
+		})
+	}
+}
+
+func TestAdminPauseHeartbeats(t *testing.T) {
+	cases := map[string]struct {
+		body         string
+		pauseErr     error
+		expectedCode int
+	}{
+		"ok": {
+			body:         `{"duration": "2m"}`,
+			expectedCode: http.StatusNoContent,
+		},
+		"missing duration": {
+			body:         `{}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		"node not running": {
+			body:         `{"duration": "2m"}`,
+			pauseErr:     util.WithKind(util.ErrRetriable, errors.New("node controller is not running")),
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		"failed": {
+			body:         `{"duration": "2m"}`,
+			pauseErr:     errors.New("something went wrong"),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			nlm := &mockNodeLifecycleManager{}
+			nlm.On("PauseHeartbeats", mock.Anything).Return(tc.pauseErr)
+			admin := &adminServer{nlm: nlm, logger: testutils.GetFakeLogger()}
+
+			req := httptest.NewRequest(http.MethodPost, heartbeatsPath, strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			admin.handler().ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			if tc.expectedCode != http.StatusBadRequest {
+				nlm.AssertCalled(t, "PauseHeartbeats", 2*time.Minute)
+			}
+		})
+	}
+}
+
+func TestAdminLogLevel(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel(log.InfoLevel)
+	admin := &adminServer{nlm: &mockNodeLifecycleManager{}, logger: testutils.GetFakeLogger()}
+
+	req := httptest.NewRequest(http.MethodPost, logLevelPath, strings.NewReader(`{"level": "debug"}`))
+	rec := httptest.NewRecorder()
+	admin.handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, log.DebugLevel, log.GetLevel())
+}
+
+func TestAdminProbes(t *testing.T) {
+	cases := map[string]struct {
+		path         string
+		method       string
+		nodeErr      error
+		podErr       error
+		expectedCode int
+	}{
+		"healthy": {
+			path:         healthzPath,
+			method:       http.MethodGet,
+			expectedCode: http.StatusOK,
+		},
+		"node unhealthy": {
+			path:         healthzPath,
+			method:       http.MethodGet,
+			nodeErr:      errors.New("node controller stopped"),
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		"ready": {
+			path:         readyzPath,
+			method:       http.MethodGet,
+			expectedCode: http.StatusOK,
+		},
+		"pod controller not ready": {
+			path:         readyzPath,
+			method:       http.MethodGet,
+			podErr:       errors.New("pod controller is not ready"),
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		"wrong method": {
+			path:         readyzPath,
+			method:       http.MethodPost,
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			nlm := &mockNodeLifecycleManager{}
+			nlm.On("Healthy").Return(tc.nodeErr)
+			nlm.On("Ready").Return(tc.nodeErr)
+			plm := &mockPodLifecycleManager{}
+			plm.On("Healthy").Return(tc.podErr)
+			plm.On("Ready").Return(tc.podErr)
+			admin := &adminServer{nlm: nlm, plm: plm, logger: testutils.GetFakeLogger()}
+
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			rec := httptest.NewRecorder()
+			admin.handler().ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			if tc.expectedCode == http.StatusOK {
+				assert.Equal(t, "ok\n", rec.Body.String())
+			}
+		})
+	}
+}
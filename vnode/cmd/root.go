@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
 
+	"simkube/lib/go/audit"
+	"simkube/lib/go/debugserver"
+	"simkube/lib/go/dra"
+	"simkube/lib/go/k8s"
+	"simkube/lib/go/node"
 	"simkube/lib/go/util"
 	"simkube/vnode"
 )
@@ -12,9 +20,49 @@ import (
 const (
 	progname = "sk-vnode"
 
-	verbosityFlag    = "verbosity"
-	jsonLogsFlag     = "jsonlogs"
-	nodeSkeletonFlag = "node-skeleton"
+	verbosityFlag        = "verbosity"
+	jsonLogsFlag         = "jsonlogs"
+	otlpLogsEndpointFlag = "otlp-logs-endpoint"
+	redactKeysFlag       = "redact-keys"
+	redactPatternsFlag   = "redact-patterns"
+	debugAddrFlag        = "debug-addr"
+	auditSinkFlag        = "audit-sink"
+	logSampleRateFlag    = "log-sample-rate"
+	nodeSkeletonFlag     = "node-skeleton"
+	adminAddrFlag        = "admin-addr"
+
+	leaseDurationFlag      = "lease-duration-seconds"
+	leaseRenewIntervalFlag = "lease-renew-interval"
+	skipDrainFlag          = "skip-drain"
+	skeletonReloadFlag     = "skeleton-reload-interval"
+	nodeTemplateFlag       = "node-template"
+	allocatableSchedFlag   = "allocatable-schedule"
+	labelSchedFlag         = "label-schedule"
+	draDevicesFlag         = "dra-devices"
+	zonesFlag              = "zones"
+	zonePolicyFlag         = "zone-policy"
+	kubeletVersionFlag     = "kubelet-version"
+	retainNodeFlag         = "retain-node-on-exit"
+	virtualNodeTaintFlag   = "virtual-node-taint"
+	noVirtualNodeTaintFlag = "no-virtual-node-taint"
+	startupDelayFlag       = "startup-delay"
+	startupDelayMaxFlag    = "startup-delay-max"
+	nodeLifetimeFlag       = "node-lifetime"
+	nodeCIDRFlag           = "node-cidr"
+	kubeletPortFlag        = "kubelet-port"
+	nodeNameTemplateFlag   = "node-name-template"
+	noReconcileNodeFlag    = "no-reconcile-node"
+	validateOnlyFlag       = "validate-only"
+	maxPodsFlag            = "max-pods"
+	providerIDTemplateFlag = "provider-id-template"
+	gpuLabelFlag           = "gpu-label"
+	nodeGroupResourceFlag  = "node-group-resource"
+	clientQPSFlag          = "kube-api-qps"
+	clientBurstFlag        = "kube-api-burst"
+	clientTimeoutFlag      = "kube-api-timeout"
+	kwokCompatFlag         = "kwok-compat"
+	podTermDelayFlag       = "pod-termination-delay"
+	podTermDelayMaxFlag    = "pod-termination-delay-max"
 )
 
 func rootCmd() *cobra.Command {
@@ -26,7 +74,167 @@ func rootCmd() *cobra.Command {
 
 	root.PersistentFlags().IntP(verbosityFlag, "v", 2, "log level output (higher is more verbose")
 	root.PersistentFlags().Bool(jsonLogsFlag, false, "structured JSON logging output")
-	root.PersistentFlags().StringP(nodeSkeletonFlag, "n", "node.yml", "location of config file")
+	root.PersistentFlags().String(
+		otlpLogsEndpointFlag,
+		"",
+		"OTLP/HTTP endpoint to export logs to, e.g., http://otel-collector:4318/v1/logs\n"+
+			"    (defaults to $OTEL_EXPORTER_OTLP_LOGS_ENDPOINT; if neither is set, logs aren't exported)",
+	)
+	root.PersistentFlags().StringSlice(
+		redactKeysFlag,
+		nil,
+		"additional log field names and keys whose values are masked in the logs (passwords, tokens, etc. always are)",
+	)
+	root.PersistentFlags().StringArray(
+		redactPatternsFlag,
+		nil,
+		"regular expression whose matches are masked in the logs (can be repeated)",
+	)
+	root.PersistentFlags().String(
+		debugAddrFlag,
+		"",
+		"listen address for the debug HTTP server, with pprof profiles and expvar stats (empty to disable)",
+	)
+	root.PersistentFlags().String(
+		auditSinkFlag,
+		"",
+		"file path (- for stdout) or http(s) URL to record lifecycle decisions to, as JSON (empty to disable)",
+	)
+	root.PersistentFlags().Int(
+		logSampleRateFlag,
+		1,
+		"only log 1 in N of the routine messages about each pod's status (warnings and errors are always logged)",
+	)
+	root.PersistentFlags().StringP(
+		nodeSkeletonFlag,
+		"n",
+		"node.yml",
+		"location of node skeleton file, or directory of node templates",
+	)
+	root.PersistentFlags().String(
+		nodeTemplateFlag,
+		"",
+		"node template to use when --node-skeleton is a directory\n"+
+			"    (defaults to the node group's simkube.io/node-template annotation, or \"default\")",
+	)
+	root.PersistentFlags().String(adminAddrFlag, ":8080", "listen address for the admin HTTP server (empty to disable)")
+	root.PersistentFlags().Int32(leaseDurationFlag, 0, "node lease duration in seconds (0 uses the default)")
+	root.PersistentFlags().Duration(
+		leaseRenewIntervalFlag,
+		0,
+		"node lease renewal interval (0 renews at a fixed fraction of the lease duration)",
+	)
+	root.PersistentFlags().Bool(skipDrainFlag, false, "do not cordon the node and delete its pods on shutdown")
+	root.PersistentFlags().Duration(
+		skeletonReloadFlag,
+		0,
+		"how often to check the node skeleton for changes (0 disables reloading)",
+	)
+	root.PersistentFlags().String(
+		allocatableSchedFlag,
+		"",
+		"location of a file describing scheduled changes to the node's allocatable resources",
+	)
+	root.PersistentFlags().String(
+		labelSchedFlag,
+		"",
+		"location of a file describing scheduled changes to the node's labels",
+	)
+	root.PersistentFlags().String(
+		draDevicesFlag,
+		"",
+		"location of a file describing the devices to advertise for Dynamic Resource Allocation (empty to disable)",
+	)
+	root.PersistentFlags().StringSlice(
+		zonesFlag,
+		[]string{},
+		"zones to distribute virtual nodes across, as <region>/<zone>[=<weight>]",
+	)
+	root.PersistentFlags().String(
+		zonePolicyFlag,
+		node.ZonePolicyRoundRobin,
+		"how to distribute virtual nodes across zones (round-robin or weighted)",
+	)
+	root.PersistentFlags().String(
+		kubeletVersionFlag,
+		"",
+		"kubelet version reported by the node (defaults to the skeleton value or the API server version)",
+	)
+	root.PersistentFlags().Bool(retainNodeFlag, false, "do not delete the node object on shutdown")
+	root.PersistentFlags().String(
+		virtualNodeTaintFlag,
+		"",
+		"taint applied to the node, as <key>[=<value>]:<effect>\n"+
+			"    (default \"simkube.io/virtual-node=true:NoExecute\")",
+	)
+	root.PersistentFlags().Bool(noVirtualNodeTaintFlag, false, "do not apply the virtual node taint")
+	root.PersistentFlags().Duration(startupDelayFlag, 0, "how long the node stays NotReady after registering")
+	root.PersistentFlags().Duration(
+		startupDelayMaxFlag,
+		0,
+		"if larger than --startup-delay, the startup delay is chosen uniformly at random up to this value",
+	)
+	root.PersistentFlags().Duration(
+		nodeLifetimeFlag,
+		0,
+		"terminate the node and fail all of its pods after this long (0 runs forever)",
+	)
+	root.PersistentFlags().String(
+		nodeCIDRFlag,
+		"10.128.0.0/16",
+		"range to allocate node InternalIPs from (empty to disable)",
+	)
+	root.PersistentFlags().Int32(kubeletPortFlag, 10250, "kubelet port reported in the node's daemon endpoints")
+	root.PersistentFlags().String(
+		nodeNameTemplateFlag,
+		"",
+		"Go template for the node name, e.g. \"sim-{{.Group}}-{{.Ordinal}}\" (defaults to the pod name)",
+	)
+	root.PersistentFlags().Bool(
+		noReconcileNodeFlag,
+		false,
+		"do not recreate the node if it is deleted, or revert external changes to its labels and taints",
+	)
+	root.PersistentFlags().Int64(maxPodsFlag, 110, "pod capacity of the node, if not set in the skeleton")
+	root.PersistentFlags().String(
+		providerIDTemplateFlag,
+		"",
+		"Go template for the node's provider ID, e.g. \"aws:///{{.Zone}}/{{.InstanceID}}\"\n"+
+			"    (default \"simkube://<node name>\")",
+	)
+	root.PersistentFlags().String(
+		gpuLabelFlag,
+		"simkube.io/gpu-type",
+		"label that records the GPU type of nodes with GPUs (must match the cloud provider's GPU label)",
+	)
+	root.PersistentFlags().String(
+		nodeGroupResourceFlag,
+		k8s.DefaultNodeGroupResource,
+		"kind of object that owns the virtual node, as <resource>.<version>.<group>",
+	)
+	root.PersistentFlags().Float32(clientQPSFlag, 5, "maximum rate of requests to the Kubernetes API server")
+	root.PersistentFlags().Int(clientBurstFlag, 10, "maximum burst of requests to the Kubernetes API server")
+	root.PersistentFlags().Duration(
+		clientTimeoutFlag,
+		0,
+		"how long each request to the Kubernetes API server can take (0 means no timeout)",
+	)
+	root.PersistentFlags().Bool(
+		kwokCompatFlag,
+		false,
+		"apply kwok's node annotation and taint, and accept kwok's pod-complete stage annotations",
+	)
+	root.PersistentFlags().Duration(
+		podTermDelayFlag,
+		0,
+		"how long running pods take to shut down when they are deleted or evicted (capped at their grace period)",
+	)
+	root.PersistentFlags().Duration(
+		podTermDelayMaxFlag,
+		0,
+		"if larger than --pod-termination-delay, the termination delay is chosen uniformly at random up to this value",
+	)
+	root.PersistentFlags().Bool(validateOnlyFlag, false, "validate the node skeleton and exit")
 	return root
 }
 
@@ -41,14 +249,314 @@ func start(cmd *cobra.Command, _ []string) {
 		panic(err)
 	}
 
+	otlpLogsEndpoint, err := cmd.PersistentFlags().GetString(otlpLogsEndpointFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	redactKeys, err := cmd.PersistentFlags().GetStringSlice(redactKeysFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	redactPatterns, err := cmd.PersistentFlags().GetStringArray(redactPatternsFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	debugAddr, err := cmd.PersistentFlags().GetString(debugAddrFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	auditSink, err := cmd.PersistentFlags().GetString(auditSinkFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	logSampleRate, err := cmd.PersistentFlags().GetInt(logSampleRateFlag)
+	if err != nil {
+		panic(err)
+	}
+
 	nodeSkeletonFile, err := cmd.PersistentFlags().GetString(nodeSkeletonFlag)
 	if err != nil {
 		panic(err)
 	}
 
-	util.SetupLogging(level, jsonLogs)
+	adminAddr, err := cmd.PersistentFlags().GetString(adminAddrFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	leaseDuration, err := cmd.PersistentFlags().GetInt32(leaseDurationFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	leaseRenewInterval, err := cmd.PersistentFlags().GetDuration(leaseRenewIntervalFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	skipDrain, err := cmd.PersistentFlags().GetBool(skipDrainFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	skeletonReloadInterval, err := cmd.PersistentFlags().GetDuration(skeletonReloadFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	nodeTemplate, err := cmd.PersistentFlags().GetString(nodeTemplateFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	allocatableSchedFile, err := cmd.PersistentFlags().GetString(allocatableSchedFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	labelSchedFile, err := cmd.PersistentFlags().GetString(labelSchedFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	draDevicesFile, err := cmd.PersistentFlags().GetString(draDevicesFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	zoneSpecs, err := cmd.PersistentFlags().GetStringSlice(zonesFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	zonePolicy, err := cmd.PersistentFlags().GetString(zonePolicyFlag)
+	if err != nil {
+		panic(err)
+	}
 
-	runner, err := vnode.NewRunner()
+	kubeletVersion, err := cmd.PersistentFlags().GetString(kubeletVersionFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	retainNode, err := cmd.PersistentFlags().GetBool(retainNodeFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	virtualNodeTaintSpec, err := cmd.PersistentFlags().GetString(virtualNodeTaintFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	noVirtualNodeTaint, err := cmd.PersistentFlags().GetBool(noVirtualNodeTaintFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	startupDelay, err := cmd.PersistentFlags().GetDuration(startupDelayFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	startupDelayMax, err := cmd.PersistentFlags().GetDuration(startupDelayMaxFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	nodeLifetime, err := cmd.PersistentFlags().GetDuration(nodeLifetimeFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	nodeCIDRSpec, err := cmd.PersistentFlags().GetString(nodeCIDRFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	kubeletPort, err := cmd.PersistentFlags().GetInt32(kubeletPortFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	nodeNameTemplate, err := cmd.PersistentFlags().GetString(nodeNameTemplateFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	noReconcileNode, err := cmd.PersistentFlags().GetBool(noReconcileNodeFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	maxPods, err := cmd.PersistentFlags().GetInt64(maxPodsFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	providerIDTemplateSpec, err := cmd.PersistentFlags().GetString(providerIDTemplateFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	gpuLabel, err := cmd.PersistentFlags().GetString(gpuLabelFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	nodeGroupResourceSpec, err := cmd.PersistentFlags().GetString(nodeGroupResourceFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	kwokCompat, err := cmd.PersistentFlags().GetBool(kwokCompatFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	podTermDelay, err := cmd.PersistentFlags().GetDuration(podTermDelayFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	podTermDelayMax, err := cmd.PersistentFlags().GetDuration(podTermDelayMaxFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	validateOnly, err := cmd.PersistentFlags().GetBool(validateOnlyFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	if validateOnly {
+		if err := node.ValidateSkeletonFile(nodeSkeletonFile, maxPods); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s is valid\n", nodeSkeletonFile)
+		return
+	}
+
+	flushLogs := util.SetupLogging(level, jsonLogs, util.OTLPLogOptions{Endpoint: otlpLogsEndpoint, Component: progname})
+	defer flushLogs()
+	if err := util.SetupRedaction(util.RedactionOptions{Keys: redactKeys, Patterns: redactPatterns}); err != nil {
+		panic(err)
+	}
+	if debugAddr != "" {
+		debugserver.Run(context.Background(), debugAddr)
+	}
+	flushAudit, err := audit.Setup(audit.Options{Sink: auditSink, Component: progname})
+	if err != nil {
+		panic(err)
+	}
+	defer flushAudit()
+	util.HandleLogLevelSignal()
+
+	var allocatableSchedule []node.AllocatableChange
+	if allocatableSchedFile != "" {
+		if allocatableSchedule, err = node.LoadAllocatableSchedule(allocatableSchedFile); err != nil {
+			panic(err)
+		}
+	}
+
+	var labelSchedule []node.LabelChange
+	if labelSchedFile != "" {
+		if labelSchedule, err = node.LoadLabelSchedule(labelSchedFile); err != nil {
+			panic(err)
+		}
+	}
+
+	var draDevices *dra.DeviceConfig
+	if draDevicesFile != "" {
+		if draDevices, err = dra.LoadDeviceConfig(draDevicesFile); err != nil {
+			panic(err)
+		}
+	}
+
+	zones, err := node.ParseZones(zoneSpecs)
+	if err != nil {
+		panic(err)
+	}
+	if zonePolicy != node.ZonePolicyRoundRobin && zonePolicy != node.ZonePolicyWeighted {
+		panic(fmt.Sprintf("unknown zone policy: %s", zonePolicy))
+	}
+
+	nodeCIDR, err := node.ParseNodeCIDR(nodeCIDRSpec)
+	if err != nil {
+		panic(err)
+	}
+
+	providerIDTemplate, err := node.ParseProviderIDTemplate(providerIDTemplateSpec)
+	if err != nil {
+		panic(err)
+	}
+
+	nodeGroupResource, err := k8s.ParseGroupVersionResource(nodeGroupResourceSpec)
+	if err != nil {
+		panic(err)
+	}
+
+	var clientOpts k8s.ClientOptions
+	if clientOpts.QPS, err = cmd.PersistentFlags().GetFloat32(clientQPSFlag); err != nil {
+		panic(err)
+	}
+	if clientOpts.Burst, err = cmd.PersistentFlags().GetInt(clientBurstFlag); err != nil {
+		panic(err)
+	}
+	if clientOpts.Timeout, err = cmd.PersistentFlags().GetDuration(clientTimeoutFlag); err != nil {
+		panic(err)
+	}
+
+	var virtualNodeTaint *corev1.Taint
+	if virtualNodeTaintSpec != "" {
+		if virtualNodeTaint, err = node.ParseTaint(virtualNodeTaintSpec); err != nil {
+			panic(err)
+		}
+	}
+
+	nodeOpts := node.Options{
+		LeaseDurationSeconds:    leaseDuration,
+		LeaseRenewInterval:      leaseRenewInterval,
+		SkipDrain:               skipDrain,
+		SkeletonReloadInterval:  skeletonReloadInterval,
+		NodeTemplate:            nodeTemplate,
+		AllocatableSchedule:     allocatableSchedule,
+		LabelSchedule:           labelSchedule,
+		Zones:                   zones,
+		ZonePolicy:              zonePolicy,
+		KubeletVersion:          kubeletVersion,
+		RetainNode:              retainNode,
+		VirtualNodeTaint:        virtualNodeTaint,
+		DisableVirtualNodeTaint: noVirtualNodeTaint,
+		StartupDelay:            startupDelay,
+		StartupDelayMax:         startupDelayMax,
+		NodeCIDR:                nodeCIDR,
+		KubeletPort:             kubeletPort,
+		DisableNodeReconcile:    noReconcileNode,
+		MaxPods:                 maxPods,
+		ProviderIDTemplate:      providerIDTemplate,
+		GPULabel:                gpuLabel,
+		NodeGroupResource:       nodeGroupResource,
+		KwokCompat:              kwokCompat,
+		DRADevices:              draDevices,
+	}
+	runnerOpts := vnode.Options{
+		AdminAddr:              adminAddr,
+		NodeNameTemplate:       nodeNameTemplate,
+		NodeLifetime:           nodeLifetime,
+		Client:                 clientOpts,
+		LogSampleRate:          logSampleRate,
+		KwokCompat:             kwokCompat,
+		PodTerminationDelay:    podTermDelay,
+		PodTerminationDelayMax: podTermDelayMax,
+	}
+	runner, err := vnode.NewRunner(runnerOpts, nodeOpts)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,133 @@
+package vnode
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+
+	"simkube/lib/go/audit"
+	"simkube/lib/go/node"
+	"simkube/lib/go/pod"
+)
+
+const (
+	crashReason     = "NodeStatusUnknown"
+	crashMessage    = "Kubelet stopped posting node status."
+	recoverReason   = "KubeletReady"
+	recoverMessage  = "kubelet is posting ready status"
+	shutdownReason  = "KubeletNotReady"
+	shutdownMessage = "node is shutting down"
+
+	// Why the node was terminated, for the audit log
+	terminateRequestedReason = "TerminateRequested"
+	lifetimeExpiredReason    = "LifetimeExpired"
+
+	// The pod controller polls for pod status updates every 5 seconds, so we wait a bit
+	// longer than that before shutting down to make sure the terminated pod statuses are
+	// propagated to the API server
+	podStatusSyncWait = 10 * time.Second
+)
+
+// The fault injector simulates node failures: a crash takes the node (and all of its pods)
+// offline for a period of time before it recovers, and a termination marks all of the pods
+// as Failed and the node as NotReady before shutting the node down, similar to a spot
+// instance interruption.
+type faultInjector struct {
+	// Faults are triggered by (short-lived) admin API requests but play out over the
+	// lifetime of the node, so the injector holds on to the node's context
+	ctx      context.Context //nolint:containedctx // see above
+	nodeName string
+	nlm      node.LifecycleManagerI
+	plm      pod.LifecycleManagerI
+	cancel   context.CancelCauseFunc
+	logger   *log.Entry
+
+	// Each crash increments the generation, so that a recovery timer from an earlier
+	// crash doesn't bring the node back early
+	mutex      sync.Mutex
+	generation int
+	terminated bool
+
+	syncWait time.Duration
+}
+
+func (self *faultInjector) crash(duration time.Duration) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.logger.Infof("simulating node crash for %v", duration)
+	err := self.nlm.SetCondition(self.ctx, corev1.NodeReady, corev1.ConditionUnknown, crashReason, crashMessage)
+	if err != nil {
+		return err //nolint:wrapcheck // this is just a passthrough
+	}
+	self.plm.SetNodeDown(true)
+	audit.Record(audit.Event{
+		Action:  audit.NodeCrashed,
+		Object:  self.nodeName,
+		Details: map[string]string{"duration": duration.String()},
+	})
+
+	self.generation += 1
+	generation := self.generation
+	time.AfterFunc(duration, func() { self.recover(generation) })
+	return nil
+}
+
+func (self *faultInjector) recover(generation int) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if generation != self.generation || self.terminated || self.ctx.Err() != nil {
+		return
+	}
+
+	self.logger.Info("recovering node after simulated crash")
+	err := self.nlm.SetCondition(self.ctx, corev1.NodeReady, corev1.ConditionTrue, recoverReason, recoverMessage)
+	if err != nil {
+		self.logger.WithError(err).Error("could not recover node")
+		return
+	}
+	self.plm.SetNodeDown(false)
+}
+
+func (self *faultInjector) terminate(reason string) {
+	self.mutex.Lock()
+	if self.terminated {
+		self.mutex.Unlock()
+		return
+	}
+	self.terminated = true
+	self.mutex.Unlock()
+
+	self.logger.Info("terminating node")
+	audit.Record(audit.Event{Action: audit.NodeTerminated, Object: self.nodeName, Reason: reason})
+	self.plm.TerminatePods()
+	err := self.nlm.SetCondition(self.ctx, corev1.NodeReady, corev1.ConditionFalse, shutdownReason, shutdownMessage)
+	if err != nil {
+		self.logger.WithError(err).Warn("could not mark node as not ready")
+	}
+
+	go func() {
+		timer := time.NewTimer(self.syncWait)
+		defer timer.Stop()
+		select {
+		case <-self.ctx.Done():
+		case <-timer.C:
+			self.cancel(nil)
+		}
+	}()
+}
+
+func (self *faultInjector) terminateAfter(lifetime time.Duration) {
+	timer := time.NewTimer(lifetime)
+	defer timer.Stop()
+	select {
+	case <-self.ctx.Done():
+	case <-timer.C:
+		self.logger.Infof("node lifetime of %v has expired", lifetime)
+		self.terminate(lifetimeExpiredReason)
+	}
+}
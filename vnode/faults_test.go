@@ -0,0 +1,87 @@
+package vnode
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	corev1 "k8s.io/api/core/v1"
+
+	"simkube/lib/go/testutils"
+)
+
+func makeFaultInjector(ctx context.Context, cancel context.CancelCauseFunc) (
+	*faultInjector,
+	*mockNodeLifecycleManager,
+	*mockPodLifecycleManager,
+) {
+	nlm := &mockNodeLifecycleManager{}
+	plm := &mockPodLifecycleManager{}
+	return &faultInjector{
+		ctx:    ctx,
+		nlm:    nlm,
+		plm:    plm,
+		cancel: cancel,
+		logger: testutils.GetFakeLogger(),
+	}, nlm, plm
+}
+
+func TestFaultInjectorCrashAndRecover(t *testing.T) {
+	faults, nlm, plm := makeFaultInjector(context.Background(), nil)
+	nlm.On("SetCondition", mock.Anything, corev1.NodeReady, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	plm.On("SetNodeDown", mock.Anything).Return()
+
+	err := faults.crash(time.Hour)
+	assert.Nil(t, err)
+	nlm.AssertCalled(
+		t, "SetCondition", mock.Anything, corev1.NodeReady, corev1.ConditionUnknown, crashReason, crashMessage,
+	)
+	plm.AssertCalled(t, "SetNodeDown", true)
+
+	// A recovery from a previous crash should be ignored
+	faults.recover(faults.generation - 1)
+	plm.AssertNotCalled(t, "SetNodeDown", false)
+
+	faults.recover(faults.generation)
+	nlm.AssertCalled(
+		t, "SetCondition", mock.Anything, corev1.NodeReady, corev1.ConditionTrue, recoverReason, recoverMessage,
+	)
+	plm.AssertCalled(t, "SetNodeDown", false)
+}
+
+func TestFaultInjectorCrashError(t *testing.T) {
+	faults, nlm, plm := makeFaultInjector(context.Background(), nil)
+	nlm.On("SetCondition", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(errors.New("node not running"))
+
+	err := faults.crash(time.Hour)
+	assert.NotNil(t, err)
+	plm.AssertNotCalled(t, "SetNodeDown", mock.Anything)
+}
+
+func TestFaultInjectorTerminate(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	faults, nlm, plm := makeFaultInjector(ctx, cancel)
+	plm.On("TerminatePods").Once().Return()
+	nlm.On("SetCondition", mock.Anything, corev1.NodeReady, corev1.ConditionFalse, shutdownReason, shutdownMessage).
+		Once().
+		Return(nil)
+
+	faults.terminate(terminateRequestedReason)
+	faults.terminate(terminateRequestedReason)
+	<-ctx.Done()
+
+	plm.AssertExpectations(t)
+	nlm.AssertExpectations(t)
+	assert.ErrorIs(t, context.Cause(ctx), context.Canceled)
+
+	// Once the node is terminated, it should not recover
+	faults.recover(faults.generation)
+	nlm.AssertNotCalled(
+		t, "SetCondition", mock.Anything, corev1.NodeReady, corev1.ConditionTrue, mock.Anything, mock.Anything,
+	)
+}
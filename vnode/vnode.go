@@ -7,13 +7,15 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
-	vklog "github.com/virtual-kubelet/virtual-kubelet/log"
-	vklogrus "github.com/virtual-kubelet/virtual-kubelet/log/logrus"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 
+	"simkube/lib/go/audit"
 	"simkube/lib/go/k8s"
+	"simkube/lib/go/metrics"
 	"simkube/lib/go/node"
 	"simkube/lib/go/pod"
 	"simkube/lib/go/util"
@@ -21,38 +23,107 @@ import (
 
 const podNameEnv = "POD_NAME"
 
+type Options struct {
+	AdminAddr string
+
+	// If set, the node name is computed from this template instead of using the pod name
+	NodeNameTemplate string
+
+	// If set, the node is terminated (and all of its pods are marked as Failed) once it
+	// has been running for this long; this overrides the node lifetime annotation
+	NodeLifetime time.Duration
+
+	// Rate limits, timeout, and user agent for the node's Kubernetes clients
+	Client k8s.ClientOptions
+
+	// Only 1 in LogSampleRate of the routine per-pod messages are logged
+	LogSampleRate int
+
+	// If set, pods can use kwok's stage annotations for their lifetimes (the node's kwok
+	// annotation and taint are set in the node options)
+	KwokCompat bool
+
+	// Running pods take between PodTerminationDelay and PodTerminationDelayMax to shut down
+	// when they're deleted or evicted
+	PodTerminationDelay    time.Duration
+	PodTerminationDelayMax time.Duration
+}
+
 type Runner struct {
 	nodeName  string
+	opts      Options
 	k8sClient kubernetes.Interface
 	nlm       node.LifecycleManagerI
 	plm       pod.LifecycleManagerI
 	logger    *log.Entry
 }
 
-func NewRunner() (*Runner, error) {
-	nodeName := os.Getenv(podNameEnv)
-	if nodeName == "" {
+func NewRunner(opts Options, nodeOpts node.Options) (*Runner, error) {
+	podName := os.Getenv(podNameEnv)
+	if podName == "" {
 		return nil, errors.New("could not determine pod name")
 	}
 
-	k8sClient, err := k8s.NewClient()
+	metrics.CountClientErrors(metrics.Default.NewCounter(
+		"simkube_vnode_api_errors_total",
+		"Number of failed requests to the Kubernetes API server, by method and status code",
+		"method",
+		"code",
+	))
+
+	k8sClient, err := k8s.NewClient(opts.Client)
 	if err != nil {
 		return nil, fmt.Errorf("could not initialize Kubernetes client: %w", err)
 	}
 
+	dynamicClient, err := k8s.NewDynamicClient(opts.Client)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize Kubernetes client: %w", err)
+	}
+
+	nodeName := podName
+	if opts.NodeNameTemplate != "" {
+		nodeName, err = node.RenderNodeName(context.Background(), k8sClient, opts.NodeNameTemplate, podName)
+		if err != nil {
+			return nil, fmt.Errorf("could not compute node name: %w", err)
+		}
+	}
+	nodeOpts.PodName = podName
+
+	util.SetLogNodeName(nodeName)
+	audit.SetNode(nodeName)
 	logger := util.GetLogger(nodeName)
-	nlm := node.NewLifecycleManager(nodeName, k8sClient)
-	plm := pod.NewLifecycleManager(nodeName, k8sClient)
+	nlm := node.NewLifecycleManager(nodeName, k8sClient, dynamicClient, nodeOpts)
+	plm := pod.NewLifecycleManager(
+		nodeName,
+		k8sClient,
+		dynamicClient,
+		util.NewLogSampler(opts.LogSampleRate),
+		pod.Options{
+			KwokCompat:          opts.KwokCompat,
+			TerminationDelay:    opts.PodTerminationDelay,
+			TerminationDelayMax: opts.PodTerminationDelayMax,
+			DRADevices:          nodeOpts.DRADevices,
+		},
+	)
 
-	return &Runner{nodeName, k8sClient, nlm, plm, logger}, nil
+	return &Runner{
+		nodeName:  nodeName,
+		opts:      opts,
+		k8sClient: k8sClient,
+		nlm:       nlm,
+		plm:       plm,
+		logger:    logger,
+	}, nil
 }
 
 func (self *Runner) Run(nodeSkeletonFile string) {
 	self.logger.Info("Initializing simkube controllers...")
 
-	ctx := vklog.WithLogger(context.Background(), vklogrus.FromLogrus(self.logger))
+	ctx := util.WithLogger(context.Background(), self.logger)
 	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM)
 	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
 	defer func() {
 		// If the context was canceled by k8s, the cause is just "context.Canceled",
 		// so don't report an error in this case
@@ -66,14 +137,50 @@ func (self *Runner) Run(nodeSkeletonFile string) {
 		}
 	}()
 
+	faults := &faultInjector{
+		ctx:      ctx,
+		nodeName: self.nodeName,
+		nlm:      self.nlm,
+		plm:      self.plm,
+		cancel:   cancel,
+		logger:   self.logger,
+		syncWait: podStatusSyncWait,
+	}
+
+	// The admin server is started before the node, so that the health checks respond while
+	// the node is being registered
+	if self.opts.AdminAddr != "" {
+		admin := &adminServer{nlm: self.nlm, plm: self.plm, faults: faults, logger: self.logger}
+		admin.run(ctx, self.opts.AdminAddr)
+	}
+
 	n, err := self.nlm.CreateNodeObject(nodeSkeletonFile)
 	if err != nil {
 		self.logger.WithError(err).Error("could not create node object")
 		return
 	}
 
-	self.plm.Run(ctx, cancel)
+	self.plm.Run(ctx, cancel, n)
 	self.nlm.Run(ctx, cancel, n)
 
+	if lifetime := self.nodeLifetime(n); lifetime > 0 {
+		go faults.terminateAfter(lifetime)
+	}
+
 	<-ctx.Done()
 }
+
+// The --node-lifetime flag takes precedence over the lifetime annotation on the node (which
+// comes from either the skeleton or the node group)
+func (self *Runner) nodeLifetime(n *corev1.Node) time.Duration {
+	if self.opts.NodeLifetime > 0 {
+		return self.opts.NodeLifetime
+	}
+
+	lifetime, err := node.NodeLifetime(n)
+	if err != nil {
+		self.logger.WithError(err).Warn("could not parse node lifetime, node will not terminate")
+		return 0
+	}
+	return lifetime
+}
@@ -5,12 +5,15 @@ import (
 	"sync"
 	"syscall"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes/fake"
 
 	"simkube/lib/go/testutils"
+	"simkube/lib/go/util"
 )
 
 type mockNodeLifecycleManager struct {
@@ -33,12 +36,73 @@ func (self *mockNodeLifecycleManager) DeleteNode(stop context.CancelFunc) error
 	return retvals.Error(0)
 }
 
+func (self *mockNodeLifecycleManager) SetCondition(
+	ctx context.Context,
+	condType corev1.NodeConditionType,
+	status corev1.ConditionStatus,
+	reason, message string,
+) error {
+	retvals := self.Called(ctx, condType, status, reason, message)
+	return retvals.Error(0)
+}
+
+func (self *mockNodeLifecycleManager) SetAllocatable(
+	ctx context.Context,
+	allocatable map[corev1.ResourceName]string,
+) error {
+	retvals := self.Called(ctx, allocatable)
+	return retvals.Error(0)
+}
+
+func (self *mockNodeLifecycleManager) SetLabels(ctx context.Context, labels map[string]*string) error {
+	retvals := self.Called(ctx, labels)
+	return retvals.Error(0)
+}
+
+func (self *mockNodeLifecycleManager) SetUnschedulable(ctx context.Context, unschedulable bool) error {
+	retvals := self.Called(ctx, unschedulable)
+	return retvals.Error(0)
+}
+
+func (self *mockNodeLifecycleManager) PauseHeartbeats(duration time.Duration) error {
+	retvals := self.Called(duration)
+	return retvals.Error(0)
+}
+
+func (self *mockNodeLifecycleManager) Ready() error {
+	retvals := self.Called()
+	return retvals.Error(0)
+}
+
+func (self *mockNodeLifecycleManager) Healthy() error {
+	retvals := self.Called()
+	return retvals.Error(0)
+}
+
 type mockPodLifecycleManager struct {
 	mock.Mock
 }
 
-func (self *mockPodLifecycleManager) Run(ctx context.Context, cancel context.CancelCauseFunc) {
-	self.Called(ctx, cancel)
+func (self *mockPodLifecycleManager) Run(ctx context.Context, cancel context.CancelCauseFunc, n *corev1.Node) {
+	self.Called(ctx, cancel, n)
+}
+
+func (self *mockPodLifecycleManager) SetNodeDown(down bool) {
+	self.Called(down)
+}
+
+func (self *mockPodLifecycleManager) TerminatePods() {
+	self.Called()
+}
+
+func (self *mockPodLifecycleManager) Ready() error {
+	retvals := self.Called()
+	return retvals.Error(0)
+}
+
+func (self *mockPodLifecycleManager) Healthy() error {
+	retvals := self.Called()
+	return retvals.Error(0)
 }
 
 func TestRunInternalCleanShutdown(t *testing.T) {
@@ -55,9 +119,15 @@ func TestRunInternalCleanShutdown(t *testing.T) {
 	nlm.wg.Add(1)
 
 	plm := &mockPodLifecycleManager{}
-	plm.On("Run", mock.Anything, mock.Anything).Once().Return(nil)
-
-	runner := &Runner{"test-node", fake.NewSimpleClientset(), nlm, plm, testutils.GetFakeLogger()}
+	plm.On("Run", mock.Anything, mock.Anything, n).Once().Return(nil)
+
+	runner := &Runner{
+		nodeName:  "test-node",
+		k8sClient: fake.NewSimpleClientset(),
+		nlm:       nlm,
+		plm:       plm,
+		logger:    testutils.GetFakeLogger(),
+	}
 
 	go func() {
 		runner.Run("skel.yml")
@@ -75,3 +145,37 @@ func TestRunInternalCleanShutdown(t *testing.T) {
 	testWg.Wait()
 	nlm.AssertExpectations(t)
 }
+
+func TestNodeLifetime(t *testing.T) {
+	cases := map[string]struct {
+		flag             time.Duration
+		annotation       string
+		expectedLifetime time.Duration
+	}{
+		"unset": {},
+		"flag": {
+			flag:             time.Hour,
+			annotation:       "60",
+			expectedLifetime: time.Hour,
+		},
+		"annotation": {
+			annotation:       "60",
+			expectedLifetime: time.Minute,
+		},
+		"invalid annotation": {
+			annotation: "asdf",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			n := &corev1.Node{}
+			if tc.annotation != "" {
+				n.ObjectMeta.Annotations = map[string]string{util.NodeLifetimeAnnotation: tc.annotation}
+			}
+			runner := &Runner{opts: Options{NodeLifetime: tc.flag}, logger: testutils.GetFakeLogger()}
+
+			assert.Equal(t, tc.expectedLifetime, runner.nodeLifetime(n))
+		})
+	}
+}